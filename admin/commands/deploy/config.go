@@ -0,0 +1,177 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultRuntime is the App Engine runtime used when none is configured.
+	defaultRuntime = "go121"
+	// defaultLocation is the bucket location used when none is configured.
+	defaultLocation = "US"
+)
+
+var (
+	// Dependency injections for testing.
+	readFile = ioutil.ReadFile
+
+	// Wrapped errors for testing.
+	errConfig = errors.New("invalid deployment config")
+
+	regExProject = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
+	// defaultEnv is the configuration of the service when it is not
+	// overridden by the deployment config. It matches the example app.yaml.
+	defaultEnv = map[string]string{
+		"SIGNED_URL_DURATION":            "60m",
+		"SEED_VALIDITY_DURATION":         "24h",
+		"VERIFY_SEED":                    "true",
+		"VERIFY_SEED_SIGNATURE":          "true",
+		"VERIFY_SEED_SIGNATURE_FALLBACK": "true",
+		"VERIFY_SEED_HASH":               "true",
+		"VERIFY_SIGN_HASH":               "true",
+		"VERIFY_OBJECT_EXISTS":           "true",
+	}
+
+	// knownEnv lists the environment variables understood by the service, so
+	// that misspelled variables are reported rather than silently ignored. It
+	// must be extended whenever the endpoints read a new variable, which
+	// TestKnownEnv checks.
+	knownEnv = map[string]bool{
+		"BUCKET":                         true,
+		"SIGNED_URL_DURATION":            true,
+		"SEED_VALIDITY_DURATION":         true,
+		"VERIFY_SEED":                    true,
+		"VERIFY_SEED_SIGNATURE":          true,
+		"VERIFY_SEED_SIGNATURE_FALLBACK": true,
+		"VERIFY_SEED_HASH":               true,
+		"VERIFY_SIGN_HASH":               true,
+		"VERIFY_OBJECT_EXISTS":           true,
+		"ENVIRONMENT":                    true,
+		"ENVIRONMENT_PROJECTS":           true,
+		"MAX_REQUEST_BYTES":              true,
+		"REQUEST_TIMEOUT":                true,
+		"MIN_CLIENT_VERSION":             true,
+		"HASH_ALGORITHMS":                true,
+		"ALLOWLIST_MAX_AGE":              true,
+		"RELEASE_OBJECTS":                true,
+		"MAINTENANCE_MODE":               true,
+		"MAINTENANCE_END":                true,
+		"MAINTENANCE_MESSAGE":            true,
+		"BULK_SEED_USERS":                true,
+		"BULK_SEED_MAX":                  true,
+		"ANALYTICS_BUCKET":               true,
+		"ACCESS_LOG_PREFIX":              true,
+	}
+)
+
+// Config declares the resources that make up a Fresnel backend. It is read
+// from a YAML file.
+type Config struct {
+	Project   string            `yaml:"project"`   // The GCP project to deploy to.
+	Region    string            `yaml:"region"`    // The App Engine region, such as us-central.
+	Bucket    string            `yaml:"bucket"`    // The bucket holding the allowlist and the files to be signed.
+	Location  string            `yaml:"location"`  // The location of the bucket. Defaults to US.
+	Source    string            `yaml:"source"`    // The path to the appengine folder of this repository.
+	Runtime   string            `yaml:"runtime"`   // The App Engine Go runtime. Defaults to go121.
+	Allowlist string            `yaml:"allowlist"` // If set, this file is uploaded as the allowlist.
+	Health    string            `yaml:"health"`    // If set, the cron schedule for allowlist health checks.
+	Env       map[string]string `yaml:"env"`       // Environment variables, overriding the defaults.
+}
+
+// loadConfig reads the deployment config at path, applies defaults and
+// validates it.
+func loadConfig(path string) (*Config, error) {
+	b, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile(%q) returned %v", path, err)
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%w: yaml.Unmarshal(%q) returned %v", errConfig, path, err)
+	}
+	if c.Location == "" {
+		c.Location = defaultLocation
+	}
+	if c.Runtime == "" {
+		c.Runtime = defaultRuntime
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validate returns an error if the config is missing required values or
+// contains values that the service does not understand.
+func (c *Config) validate() error {
+	if !regExProject.MatchString(c.Project) {
+		return fmt.Errorf("%w: project %q is not a valid project ID", errConfig, c.Project)
+	}
+	if c.Region == "" {
+		return fmt.Errorf("%w: region is required", errConfig)
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("%w: bucket is required", errConfig)
+	}
+	if c.Source == "" {
+		return fmt.Errorf("%w: source is required", errConfig)
+	}
+	for k, v := range c.Env {
+		if !knownEnv[k] {
+			return fmt.Errorf("%w: unknown environment variable %q", errConfig, k)
+		}
+		if k == "BUCKET" && v != c.Bucket {
+			return fmt.Errorf("%w: env BUCKET(%q) does not match bucket(%q)", errConfig, v, c.Bucket)
+		}
+	}
+	return nil
+}
+
+// env returns the environment variables for the service, sorted by name. The
+// defaults are overridden by the configured values, and BUCKET is always set.
+func (c *Config) env() [][2]string {
+	merged := map[string]string{"BUCKET": c.Bucket}
+	for k, v := range defaultEnv {
+		merged[k] = v
+	}
+	for k, v := range c.Env {
+		merged[k] = v
+	}
+	var keys []string
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var env [][2]string
+	for _, k := range keys {
+		env = append(env, [2]string{k, merged[k]})
+	}
+	return env
+}
+
+// serviceAccount returns the service account that the service runs as. App
+// Engine services run as the App Engine default service account.
+func (c *Config) serviceAccount() string {
+	return fmt.Sprintf("%s@appspot.gserviceaccount.com", c.Project)
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer func() { readFile = ioutil.ReadFile }()
+
+	tests := []struct {
+		desc    string
+		content string
+		readErr error
+		out     *Config
+		want    error
+	}{
+		{
+			desc:    "read error",
+			readErr: errors.New("error"),
+		},
+		{
+			desc:    "defaults",
+			content: `{"project": "fresnel-prod", "region": "us-central", "bucket": "images", "source": "appengine"}`,
+			out:     &Config{Project: "fresnel-prod", Region: "us-central", Bucket: "images", Location: "US", Source: "appengine", Runtime: "go121"},
+		},
+		{
+			desc:    "invalid project",
+			content: `{"project": "Fresnel", "region": "us-central", "bucket": "images", "source": "appengine"}`,
+			want:    errConfig,
+		},
+		{
+			desc:    "missing region",
+			content: `{"project": "fresnel-prod", "bucket": "images", "source": "appengine"}`,
+			want:    errConfig,
+		},
+		{
+			desc:    "missing bucket",
+			content: `{"project": "fresnel-prod", "region": "us-central", "source": "appengine"}`,
+			want:    errConfig,
+		},
+		{
+			desc:    "missing source",
+			content: `{"project": "fresnel-prod", "region": "us-central", "bucket": "images"}`,
+			want:    errConfig,
+		},
+		{
+			desc:    "unknown environment variable",
+			content: `{"project": "fresnel-prod", "region": "us-central", "bucket": "images", "source": "appengine", "env": {"VERIFY_SEEDS": "true"}}`,
+			want:    errConfig,
+		},
+		{
+			desc:    "conflicting bucket",
+			content: `{"project": "fresnel-prod", "region": "us-central", "bucket": "images", "source": "appengine", "env": {"BUCKET": "other"}}`,
+			want:    errConfig,
+		},
+	}
+	for _, tt := range tests {
+		readFile = func(string) ([]byte, error) { return []byte(tt.content), tt.readErr }
+		out, err := loadConfig("deploy.yaml")
+		if tt.readErr != nil {
+			if err == nil {
+				t.Errorf("%s: loadConfig() returned nil, want error", tt.desc)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: loadConfig() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if diff := cmp.Diff(tt.out, out); diff != "" {
+			t.Errorf("%s: loadConfig() mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	c := &Config{Bucket: "images", Env: map[string]string{"VERIFY_SEED": "false", "ENVIRONMENT": "prod"}}
+	got := map[string]string{}
+	var last string
+	for _, kv := range c.env() {
+		if kv[0] < last {
+			t.Errorf("env() is not sorted: %q follows %q", kv[0], last)
+		}
+		last = kv[0]
+		got[kv[0]] = kv[1]
+	}
+	for k, want := range map[string]string{"BUCKET": "images", "VERIFY_SEED": "false", "ENVIRONMENT": "prod", "SIGNED_URL_DURATION": "60m"} {
+		if got[k] != want {
+			t.Errorf("env() %s got: %q, want: %q", k, got[k], want)
+		}
+	}
+}
+
+// devEnv lists the variables read by the endpoints that only apply to local
+// development servers, and so are not accepted in deployment configs.
+var devEnv = map[string]bool{
+	"SIGNER":                true,
+	"DEV_USER":              true,
+	"STORAGE_EMULATOR_HOST": true,
+}
+
+// runtimeEnv lists the variables read by the endpoints that App Engine sets
+// itself, and so are not accepted in deployment configs.
+var runtimeEnv = map[string]bool{
+	"GAE_ENV": true,
+}
+
+func TestKnownEnv(t *testing.T) {
+	files, err := filepath.Glob("../../../appengine/endpoints/*.go")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("filepath.Glob() returned %d files, %v", len(files), err)
+	}
+	getenv := regexp.MustCompile(`os\.Getenv\("([A-Z0-9_]+)"\)`)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile(%q) returned %v", f, err)
+		}
+		for _, m := range getenv.FindAllSubmatch(b, -1) {
+			if v := string(m[1]); !knownEnv[v] && !devEnv[v] && !runtimeEnv[v] {
+				t.Errorf("%s reads %s, which is missing from knownEnv", filepath.Base(f), v)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deploy defines the deploy subcommand, which provisions the Google
+// Cloud resources that make up a Fresnel backend from a declarative config.
+package deploy
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/subcommands"
+)
+
+var (
+	// The name of this binary, set in init.
+	binaryName = ""
+	// Dependency injections for testing.
+	writeFile  = ioutil.WriteFile
+	removeFile = os.Remove
+
+	// Wrapped errors for testing.
+	errStep = errors.New("deployment step failed")
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&deployCmd{}, "")
+}
+
+// deployCmd represents the deploy subcommand.
+type deployCmd struct {
+	// config is the path to the deployment config.
+	config string
+
+	// dryRun prints the commands that would be run without running them. This
+	// value is defaulted to false by flag.
+	dryRun bool
+}
+
+// Ensure deployCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*deployCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (*deployCmd) Name() string {
+	return "deploy"
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (*deployCmd) Synopsis() string {
+	return "provision the Google Cloud resources for a Fresnel backend"
+}
+
+// Usage returns a long string explaining the subcommand and its usage.
+func (*deployCmd) Usage() string {
+	return fmt.Sprintf(`deploy --config=[path] [flags...]
+
+Provision the Google Cloud resources for a Fresnel backend, as declared in a
+YAML config: the App Engine application and service, the cloud bucket, and the
+IAM bindings that allow the service to read the bucket and sign URLs and seeds.
+Deployment uses the gcloud CLI, which must be installed and authenticated.
+Resources that already exist are reused, so deploy can be run again to apply
+changes to the config.
+
+Flags:
+  --config [path] - The path to the deployment config.
+  --dry_run       - Print the commands that would be run without running them.
+
+Example #1: Deploy the backend declared in deploy.yaml.
+  '%s deploy --config=deploy.yaml'
+
+Example #2: Review the deployment before making any changes.
+  '%s deploy --config=deploy.yaml --dry_run'
+
+Defaults:
+`, binaryName, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *deployCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.config, "config", "", "The path to the deployment config.")
+	f.BoolVar(&c.dryRun, "dry_run", false, "Print the commands that would be run without running them.")
+}
+
+// Execute runs the command and returns an ExitStatus.
+func (c *deployCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.config == "" {
+		deck.Errorf("--config is required")
+		return subcommands.ExitUsageError
+	}
+	conf, err := loadConfig(c.config)
+	if err != nil {
+		deck.Errorf("%v", err)
+		return subcommands.ExitUsageError
+	}
+	steps := plan(conf)
+	if c.dryRun {
+		for n, s := range steps {
+			console.Printf("# [%d/%d] %s\n%s", n+1, len(steps), s.desc, s)
+		}
+		return subcommands.ExitSuccess
+	}
+	if err := deploy(ctx, conf, steps); err != nil {
+		deck.Errorf("%v", err)
+		return subcommands.ExitFailure
+	}
+	console.Printf("Fresnel was deployed to project %q.", conf.Project)
+	return subcommands.ExitSuccess
+}
+
+// deploy generates the files needed to deploy the service described by conf,
+// and then runs steps in order, stopping at the first step that fails.
+func deploy(ctx context.Context, conf *Config, steps []step) error {
+	files := map[string]string{appFile: appYAML(conf)}
+	if conf.Health != "" {
+		files[cronFile] = cronYAML(conf)
+	}
+	for name, content := range files {
+		path := filepath.Join(conf.Source, name)
+		if err := writeFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+		defer removeFile(path)
+	}
+	for n, s := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		console.Printf("[%d/%d] %s", n+1, len(steps), s.desc)
+		if s.check != nil {
+			if _, err := runCommand(s.check); err == nil {
+				console.Printf("  Already exists, skipping.")
+				continue
+			}
+		}
+		deck.InfofA("Running %s", s).With(deck.V(1)).Go()
+		out, err := runCommand(s.cmd)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v\n%s", errStep, s, err, out)
+		}
+		deck.InfofA("%s", out).With(deck.V(2)).Go()
+	}
+	return nil
+}
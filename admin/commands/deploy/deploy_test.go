@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeploy(t *testing.T) {
+	defer func() {
+		runCommand = execCommand
+		writeFile = ioutil.WriteFile
+		removeFile = os.Remove
+	}()
+	conf := &Config{Project: "fresnel-prod", Source: "appengine", Health: "every 1 hours"}
+	steps := []step{
+		{desc: "exists", check: []string{"check", "exists"}, cmd: []string{"create", "exists"}},
+		{desc: "missing", check: []string{"check", "missing"}, cmd: []string{"create", "missing"}},
+		{desc: "update", cmd: []string{"update"}},
+	}
+
+	tests := []struct {
+		desc     string
+		writeErr error
+		fail     string
+		want     error
+		ran      []string
+	}{
+		{
+			desc:     "write error",
+			writeErr: errors.New("error"),
+		},
+		{
+			desc: "success",
+			ran:  []string{"check exists", "check missing", "create missing", "update"},
+		},
+		{
+			desc: "step error",
+			fail: "create missing",
+			want: errStep,
+			ran:  []string{"check exists", "check missing", "create missing"},
+		},
+	}
+	for _, tt := range tests {
+		var ran, written, removed []string
+		writeFile = func(path string, _ []byte, _ os.FileMode) error {
+			written = append(written, path)
+			return tt.writeErr
+		}
+		removeFile = func(path string) error {
+			removed = append(removed, path)
+			return nil
+		}
+		runCommand = func(args []string) ([]byte, error) {
+			cmd := strings.Join(args, " ")
+			ran = append(ran, cmd)
+			if cmd == "check missing" || cmd == tt.fail {
+				return []byte("output"), errors.New("error")
+			}
+			return nil, nil
+		}
+		err := deploy(context.Background(), conf, steps)
+		if tt.writeErr != nil {
+			if err == nil {
+				t.Errorf("%s: deploy() returned nil, want error", tt.desc)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: deploy() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if strings.Join(ran, ",") != strings.Join(tt.ran, ",") {
+			t.Errorf("%s: deploy() ran: %q, want: %q", tt.desc, ran, tt.ran)
+		}
+		if len(written) != 2 || len(removed) != len(written) {
+			t.Errorf("%s: deploy() wrote %q and removed %q, want both generated files removed", tt.desc, written, removed)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// appFile and cronFile are the names of the files generated in the source
+	// folder for deployment. They are removed once the deployment completes.
+	// gcloud only recognizes cron configurations named cron.yaml.
+	appFile  = "fresnel-app.yaml"
+	cronFile = "cron.yaml"
+)
+
+var (
+	// Dependency injections for testing.
+	runCommand = execCommand
+)
+
+// step is a single action taken to deploy the backend. When check is set, it
+// is run first, and the step is skipped if it succeeds. This allows a
+// deployment to be repeated against resources that already exist.
+type step struct {
+	desc  string
+	check []string
+	cmd   []string
+}
+
+// String returns the command run by the step as it would be typed in a shell.
+func (s step) String() string {
+	var args []string
+	for _, a := range s.cmd {
+		if a == "" || strings.ContainsAny(a, " \t\"'$\\") {
+			a = strconv.Quote(a)
+		}
+		args = append(args, a)
+	}
+	return strings.Join(args, " ")
+}
+
+// plan returns the steps that deploy the backend described by c.
+func plan(c *Config) []step {
+	project := "--project=" + c.Project
+	bucket := "gs://" + c.Bucket
+	member := "--member=serviceAccount:" + c.serviceAccount()
+	steps := []step{
+		{
+			desc: "Enable the required APIs",
+			cmd:  []string{"gcloud", "services", "enable", "appengine.googleapis.com", "iam.googleapis.com", "iamcredentials.googleapis.com", "storage.googleapis.com", project},
+		},
+		{
+			desc:  "Create the App Engine application",
+			check: []string{"gcloud", "app", "describe", project},
+			cmd:   []string{"gcloud", "app", "create", "--region=" + c.Region, project},
+		},
+		{
+			desc:  "Create the bucket",
+			check: []string{"gcloud", "storage", "buckets", "describe", bucket, project},
+			cmd:   []string{"gcloud", "storage", "buckets", "create", bucket, "--location=" + c.Location, "--uniform-bucket-level-access", project},
+		},
+		{
+			desc: "Allow the service to read the bucket",
+			cmd:  []string{"gcloud", "storage", "buckets", "add-iam-policy-binding", bucket, member, "--role=roles/storage.objectViewer", project},
+		},
+		{
+			// Signed URLs and seeds are signed using the SignBlob API, which
+			// requires the service account to create tokens for itself.
+			desc: "Allow the service to sign URLs and seeds",
+			cmd:  []string{"gcloud", "iam", "service-accounts", "add-iam-policy-binding", c.serviceAccount(), member, "--role=roles/iam.serviceAccountTokenCreator", project},
+		},
+	}
+	if c.Allowlist != "" {
+		steps = append(steps, step{
+			desc: "Upload the allowlist",
+			cmd:  []string{"gcloud", "storage", "cp", c.Allowlist, fmt.Sprintf("%s/%s", bucket, c.allowlistObject()), project},
+		})
+	}
+	steps = append(steps, step{
+		desc: "Deploy the service",
+		cmd:  []string{"gcloud", "app", "deploy", filepath.Join(c.Source, appFile), "--quiet", project},
+	})
+	if c.Health != "" {
+		steps = append(steps, step{
+			desc: "Schedule allowlist health checks",
+			cmd:  []string{"gcloud", "app", "deploy", filepath.Join(c.Source, cronFile), "--quiet", project},
+		})
+	}
+	return steps
+}
+
+// allowlistObject returns the path in the bucket where the service reads the
+// allowlist, taking the environment of the service into account.
+func (c *Config) allowlistObject() string {
+	env := c.Env["ENVIRONMENT"]
+	if env == "" {
+		for _, pair := range strings.Split(c.Env["ENVIRONMENT_PROJECTS"], ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && kv[0] == c.Project {
+				env = kv[1]
+			}
+		}
+	}
+	if env == "" {
+		return "appengine_config/pe_allowlist.yaml"
+	}
+	return fmt.Sprintf("appengine_config/%s/pe_allowlist.yaml", env)
+}
+
+// appYAML returns the app.yaml used to deploy the service.
+func appYAML(c *Config) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "runtime: %s\n", c.Runtime)
+	// The service relies on the App Engine bundled services for users and
+	// signing.
+	b.WriteString("app_engine_apis: true\n\n")
+	b.WriteString("handlers:\n- url: /.*\n  script: auto\n\n")
+	b.WriteString("env_variables:\n")
+	for _, kv := range c.env() {
+		fmt.Fprintf(&b, "  %s: %s\n", kv[0], strconv.Quote(kv[1]))
+	}
+	return b.String()
+}
+
+// cronYAML returns the cron.yaml used to schedule allowlist health checks.
+func cronYAML(c *Config) string {
+	return fmt.Sprintf("cron:\n- description: \"allowlist health check\"\n  url: /health/allowlist\n  schedule: %s\n", c.Health)
+}
+
+// execCommand runs args and returns its combined output.
+func execCommand(args []string) ([]byte, error) {
+	return exec.Command(args[0], args[1:]...).CombinedOutput()
+}
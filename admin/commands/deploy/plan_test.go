@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	base := Config{Project: "fresnel-prod", Region: "us-central", Bucket: "images", Location: "US", Source: "appengine", Runtime: "go121"}
+	withExtras := base
+	withExtras.Allowlist = "pe_allowlist.yaml"
+	withExtras.Health = "every 1 hours"
+
+	tests := []struct {
+		desc string
+		conf Config
+		want []string
+	}{
+		{
+			desc: "minimal",
+			conf: base,
+			want: []string{
+				"Enable the required APIs",
+				"Create the App Engine application",
+				"Create the bucket",
+				"Allow the service to read the bucket",
+				"Allow the service to sign URLs and seeds",
+				"Deploy the service",
+			},
+		},
+		{
+			desc: "allowlist and health checks",
+			conf: withExtras,
+			want: []string{
+				"Enable the required APIs",
+				"Create the App Engine application",
+				"Create the bucket",
+				"Allow the service to read the bucket",
+				"Allow the service to sign URLs and seeds",
+				"Upload the allowlist",
+				"Deploy the service",
+				"Schedule allowlist health checks",
+			},
+		},
+	}
+	for _, tt := range tests {
+		steps := plan(&tt.conf)
+		var got []string
+		for _, s := range steps {
+			got = append(got, s.desc)
+			if s.cmd[len(s.cmd)-1] != "--project=fresnel-prod" {
+				t.Errorf("%s: %q does not select the project", tt.desc, s)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: plan() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestAllowlistObject(t *testing.T) {
+	tests := []struct {
+		desc string
+		env  map[string]string
+		want string
+	}{
+		{
+			desc: "no environment",
+			want: "appengine_config/pe_allowlist.yaml",
+		},
+		{
+			desc: "explicit environment",
+			env:  map[string]string{"ENVIRONMENT": "prod"},
+			want: "appengine_config/prod/pe_allowlist.yaml",
+		},
+		{
+			desc: "environment from project",
+			env:  map[string]string{"ENVIRONMENT_PROJECTS": "fresnel-staging=staging, fresnel-prod=prod"},
+			want: "appengine_config/prod/pe_allowlist.yaml",
+		},
+	}
+	for _, tt := range tests {
+		c := &Config{Project: "fresnel-prod", Env: tt.env}
+		if got := c.allowlistObject(); got != tt.want {
+			t.Errorf("%s: allowlistObject() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestStepString(t *testing.T) {
+	s := step{cmd: []string{"gcloud", "storage", "cp", "my allowlist.yaml", "gs://images"}}
+	want := `gcloud storage cp "my allowlist.yaml" gs://images`
+	if got := s.String(); got != want {
+		t.Errorf("String() got: %q, want: %q", got, want)
+	}
+}
+
+func TestAppYAML(t *testing.T) {
+	c := &Config{Bucket: "images", Runtime: "go121", Env: map[string]string{"MIN_CLIENT_VERSION": "1.2.0"}}
+	got := appYAML(c)
+	for _, want := range []string{
+		"runtime: go121\n",
+		"app_engine_apis: true\n",
+		"  BUCKET: \"images\"\n",
+		"  MIN_CLIENT_VERSION: \"1.2.0\"\n",
+		"  VERIFY_SEED: \"true\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("appYAML() does not contain %q:\n%s", want, got)
+		}
+	}
+}
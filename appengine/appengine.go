@@ -19,15 +19,68 @@
 package main
 
 import (
+	"flag"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/google/fresnel/appengine/endpoints"
 	"google.golang.org/appengine"
 )
 
+var dev = flag.Bool("dev", false, "serve on localhost using a fake signer, for local development")
+
+// devDefaults are the environment variables set by -dev, unless they are
+// already set.
+var devDefaults = [][2]string{
+	// Serve on 127.0.0.1 rather than all interfaces.
+	{"RUN_WITH_DEVAPPSERVER", "1"},
+	// App Engine APIs are not available, so fail log flushes immediately
+	// rather than waiting on name resolution.
+	{"API_HOST", "127.0.0.1"},
+	{"API_PORT", "1"},
+	{"SIGNER", "fake"},
+	{"DEV_USER", "dev@localhost"},
+	{"BUCKET", "fresnel-dev"},
+}
+
 func main() {
+	flag.Parse()
+	if *dev {
+		for _, kv := range devDefaults {
+			if os.Getenv(kv[0]) == "" {
+				os.Setenv(kv[0], kv[1])
+			}
+		}
+		if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+			log.Print("STORAGE_EMULATOR_HOST is not set, Cloud Storage requests will be sent to Google Cloud")
+		}
+		log.Printf("serving on http://localhost:%s as %s", port(), os.Getenv("DEV_USER"))
+	}
+	if err := endpoints.Configure(); err != nil {
+		log.Fatal(err)
+	}
+
 	http.Handle("/sign", &endpoints.SignRequestHandler{})
 	http.Handle("/seed", &endpoints.SeedRequestHandler{})
+	http.Handle("/seed/bulk", &endpoints.BulkSeedRequestHandler{})
+	http.Handle("/health/allowlist", &endpoints.AllowlistHealthHandler{})
+	http.Handle("/keys", &endpoints.KeysHandler{})
+	http.Handle("/admin/summary", &endpoints.DownloadSummaryHandler{})
+	http.Handle("/admin/allowlist", &endpoints.AdminAllowlistHandler{})
+	http.Handle("/admin/activity", &endpoints.AdminActivityHandler{})
+	http.Handle("/admin/checks", &endpoints.AdminChecksHandler{})
+	http.Handle("/admin/ui/", &endpoints.AdminUIHandler{})
+	http.Handle("/openapi.json", &endpoints.OpenAPIHandler{})
+	http.Handle("/docs", &endpoints.DocsHandler{})
 
 	appengine.Main()
 }
+
+// port returns the port that appengine.Main listens on.
+func port() string {
+	if p := os.Getenv("PORT"); p != "" {
+		return p
+	}
+	return "8080"
+}
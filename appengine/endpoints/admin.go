@@ -0,0 +1,410 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/user"
+)
+
+// maxActivityRecords limits the number of seed and download records returned
+// by one activity request, so that busy deployments remain readable.
+const maxActivityRecords = 200
+
+var (
+	loginURL = user.LoginURL
+
+	// verifyVariables are the variables that enable the checks made on seed
+	// and sign requests, which are expected to be enforced in production.
+	verifyVariables = []string{"VERIFY_SEED", "VERIFY_SEED_SIGNATURE", "VERIFY_SEED_HASH", "VERIFY_SIGN_HASH"}
+)
+
+// AdminAllowlistHandler implements http.Handler and lists the entries of the
+// allowlist, so that they can be reviewed without access to the bucket. Only
+// application administrators may list the allowlist.
+type AdminAllowlistHandler struct{}
+
+func (AdminAllowlistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withDeadline(r)
+	defer cancel()
+	ctx := appengine.NewContext(r)
+	resp := adminAllowlist(ctx)
+	writeAdminJSON(ctx, w, resp, resp.ErrorCode, resp.Status)
+}
+
+// AdminActivityHandler implements http.Handler and reports the seeds and
+// signed URLs recently issued, as recorded in ANALYTICS_BUCKET. Only
+// application administrators may obtain the activity.
+type AdminActivityHandler struct{}
+
+func (AdminActivityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withDeadline(r)
+	defer cancel()
+	ctx := appengine.NewContext(r)
+	resp := adminActivity(ctx, r)
+	writeAdminJSON(ctx, w, resp, resp.ErrorCode, resp.Status)
+}
+
+// AdminChecksHandler implements http.Handler and checks the configuration of
+// the service for common mistakes. Only application administrators may
+// obtain the checks.
+type AdminChecksHandler struct{}
+
+func (AdminChecksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withDeadline(r)
+	defer cancel()
+	ctx := appengine.NewContext(r)
+	resp := adminChecks(ctx)
+	writeAdminJSON(ctx, w, resp, resp.ErrorCode, resp.Status)
+}
+
+// writeAdminJSON writes resp to w as JSON, along with the HTTP status that
+// corresponds to code. status describes code when it is not StatusSuccess.
+func writeAdminJSON(ctx context.Context, w http.ResponseWriter, resp interface{}, code models.StatusCode, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	b, err := json.Marshal(resp)
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(%T): %v", resp, err)
+		http.Error(w, fmt.Sprintf(`{"Status":"%s","ErrorCode":%d}`, err, models.StatusJSONError), http.StatusInternalServerError)
+		return
+	}
+	if code != models.StatusSuccess {
+		logWarningf(ctx, "refusing admin request: %s", status)
+		w.WriteHeader(httpStatus(code))
+	}
+	if _, err := w.Write(b); err != nil {
+		logErrorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// adminAllowlist authorizes an allowlist request and lists the entries of the
+// allowlist of the current environment, describing any that are not valid.
+func adminAllowlist(ctx context.Context) models.AdminAllowlist {
+	if code, err := authorizeAdmin(ctx); err != nil {
+		return models.AdminAllowlist{Status: err.Error(), ErrorCode: code}
+	}
+	b := os.Getenv("BUCKET")
+	if b == "" {
+		return models.AdminAllowlist{Status: "BUCKET environment variable not set", ErrorCode: models.StatusConfigError}
+	}
+	p, err := allowlistPath(ctx)
+	if err != nil {
+		return models.AdminAllowlist{Status: fmt.Sprintf("allowlistPath: %v", err), ErrorCode: models.StatusConfigError}
+	}
+	resp := models.AdminAllowlist{Path: p}
+	attrs, err := objectAttrs(ctx, b, p)
+	if err != nil {
+		return models.AdminAllowlist{Path: p, Status: fmt.Sprintf("objectAttrs(%s, %s): %v", b, p, err), ErrorCode: models.StatusConfigError}
+	}
+	resp.Updated = attrs.Updated
+	entries, err := allowlistEntries(ctx, b, p)
+	if err != nil {
+		return models.AdminAllowlist{Path: p, Status: err.Error(), ErrorCode: models.StatusConfigError}
+	}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, allowlistEntry(e))
+	}
+	resp.Status = "Success"
+	resp.ErrorCode = models.StatusSuccess
+	return resp
+}
+
+// allowlistEntry describes e, an entry of the allowlist as it was written.
+func allowlistEntry(e string) models.AllowlistEntry {
+	n := strings.TrimPrefix(strings.ToLower(e), string(models.HashSHA256)+":")
+	entry := models.AllowlistEntry{Algorithm: models.HashSHA256, Hash: n}
+	if i := strings.Index(n, ":"); i >= 0 {
+		entry.Algorithm, entry.Hash = models.HashAlgorithm(n[:i]), n[i+1:]
+	}
+	if err := validAllowlistEntry(n); err != nil {
+		entry.Problem = err.Error()
+	}
+	return entry
+}
+
+// adminActivity authorizes an activity request and reports the seeds and
+// signed URLs issued during the number of days in its days query parameter.
+func adminActivity(ctx context.Context, r *http.Request) models.AdminActivity {
+	if code, err := authorizeAdmin(ctx); err != nil {
+		return models.AdminActivity{Status: err.Error(), ErrorCode: code}
+	}
+	bucket := os.Getenv("ANALYTICS_BUCKET")
+	if bucket == "" {
+		return models.AdminActivity{Status: "ANALYTICS_BUCKET environment variable not set", ErrorCode: models.StatusConfigError}
+	}
+	days, err := summaryDays(r.URL.Query().Get("days"))
+	if err != nil {
+		return models.AdminActivity{Status: err.Error(), ErrorCode: models.StatusReqUnreadable}
+	}
+	until := time.Now().UTC()
+	resp := models.AdminActivity{Since: until.AddDate(0, 0, -days), Until: until}
+	var seedsTruncated, downloadsTruncated bool
+	if resp.Seeds, seedsTruncated, err = readSeedRecords(ctx, bucket, resp.Since, until); err != nil {
+		return models.AdminActivity{Status: err.Error(), ErrorCode: models.StatusConfigError}
+	}
+	if resp.Downloads, downloadsTruncated, err = readDownloadRecords(ctx, bucket, resp.Since, until); err != nil {
+		return models.AdminActivity{Status: err.Error(), ErrorCode: models.StatusConfigError}
+	}
+	resp.Truncated = seedsTruncated || downloadsTruncated
+	sort.Slice(resp.Seeds, func(i, j int) bool { return resp.Seeds[i].Time.After(resp.Seeds[j].Time) })
+	sort.Slice(resp.Downloads, func(i, j int) bool { return resp.Downloads[i].Time.After(resp.Downloads[j].Time) })
+	if len(resp.Seeds) > maxActivityRecords {
+		resp.Seeds = resp.Seeds[:maxActivityRecords]
+		resp.Truncated = true
+	}
+	if len(resp.Downloads) > maxActivityRecords {
+		resp.Downloads = resp.Downloads[:maxActivityRecords]
+		resp.Truncated = true
+	}
+	resp.Status = "Success"
+	resp.ErrorCode = models.StatusSuccess
+	return resp
+}
+
+// adminChecks authorizes a checks request and checks the configuration of
+// the service. The checks do not stop at the first problem, so that every
+// mistake is reported at once.
+func adminChecks(ctx context.Context) models.AdminChecks {
+	if code, err := authorizeAdmin(ctx); err != nil {
+		return models.AdminChecks{Status: err.Error(), ErrorCode: code}
+	}
+	resp := models.AdminChecks{Status: "Success", ErrorCode: models.StatusSuccess, Healthy: true}
+	check := func(name string, ok bool, format string, a ...interface{}) {
+		resp.Checks = append(resp.Checks, models.ConfigCheck{Name: name, OK: ok, Detail: fmt.Sprintf(format, a...)})
+		resp.Healthy = resp.Healthy && ok
+	}
+
+	if b := os.Getenv("BUCKET"); b == "" {
+		check("Bucket", false, "BUCKET is not set, seeds and signed URLs cannot be issued")
+	} else {
+		check("Bucket", true, "%s", b)
+	}
+	h := allowlistHealth(ctx)
+	if h.Healthy {
+		check("Allowlist", true, "%s lists %d entries, last updated %v", h.Path, h.Entries, h.Updated.Format(time.RFC1123))
+	} else {
+		check("Allowlist", false, "%s", strings.Join(h.Problems, "; "))
+	}
+	if algs, err := acceptedAlgorithms(); err != nil {
+		check("Hash algorithms", false, "%v", err)
+	} else {
+		check("Hash algorithms", true, "%q", algs)
+	}
+	if d, err := seedValidity(); err != nil {
+		check("Seed validity", false, "%v", err)
+	} else {
+		check("Seed validity", true, "%v", d)
+	}
+	if d, err := time.ParseDuration(os.Getenv("SIGNED_URL_DURATION")); err != nil {
+		check("Signed URL duration", false, "SIGNED_URL_DURATION is %q, which is not a valid duration", os.Getenv("SIGNED_URL_DURATION"))
+	} else {
+		check("Signed URL duration", true, "%v", d)
+	}
+	var unenforced []string
+	for _, v := range verifyVariables {
+		if os.Getenv(v) != "true" {
+			unenforced = append(unenforced, v)
+		}
+	}
+	if len(unenforced) > 0 {
+		check("Verification", false, "%s not set to 'true', so failures are logged but not enforced", strings.Join(unenforced, ", "))
+	} else {
+		check("Verification", true, "%s are enforced", strings.Join(verifyVariables, ", "))
+	}
+	if s := os.Getenv("SIGNER"); s == "fake" {
+		check("Signer", false, "SIGNER is 'fake', which is only suitable for local development")
+	} else {
+		check("Signer", true, "App Engine app identity")
+	}
+	m, err := maintenanceMode()
+	switch {
+	case err != nil:
+		check("Maintenance", false, "%v", err)
+	case m != nil:
+		check("Maintenance", false, "requests are refused with %q", m.message)
+	default:
+		check("Maintenance", true, "not in maintenance")
+	}
+	if b := os.Getenv("ANALYTICS_BUCKET"); b == "" {
+		check("Analytics", false, "ANALYTICS_BUCKET is not set, so recent activity is not recorded")
+	} else {
+		check("Analytics", true, "%s", b)
+	}
+	return resp
+}
+
+// adminPage is one of the pages of the administration UI.
+type adminPage struct {
+	Name  string
+	Title string
+}
+
+// adminPages are the pages of the administration UI, in the order they are
+// linked. The first is shown when no page is requested.
+var adminPages = []adminPage{
+	{Name: "checks", Title: "Configuration"},
+	{Name: "allowlist", Title: "Allowlist"},
+	{Name: "activity", Title: "Recent activity"},
+}
+
+// adminPageData is the data that adminUI renders. Only the field of the
+// requested page is set, and Error is set instead when it could not be
+// obtained.
+type adminPageData struct {
+	Page      adminPage
+	Pages     []adminPage
+	Error     string
+	Checks    *models.AdminChecks
+	Allowlist *models.AdminAllowlist
+	Activity  *models.AdminActivity
+}
+
+// AdminUIHandler implements http.Handler and serves a minimal administration
+// UI at /admin/ui/, rendering the responses of the admin endpoints as pages
+// so that the service can be operated from a browser. Users who are not
+// signed in are sent to sign in, and only application administrators are
+// shown the pages.
+type AdminUIHandler struct{}
+
+func (AdminUIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withDeadline(r)
+	defer cancel()
+	ctx := appengine.NewContext(r)
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/ui"), "/")
+	if name == "" {
+		name = adminPages[0].Name
+	}
+	data := adminPageData{Pages: adminPages}
+	for _, p := range adminPages {
+		if p.Name == name {
+			data.Page = p
+		}
+	}
+	if data.Page.Name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if currentUser(ctx) == nil {
+		login, err := loginURL(ctx, r.URL.String())
+		if err != nil {
+			logErrorf(ctx, "user.LoginURL(): %v", err)
+			http.Error(w, "sign in is required", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, login, http.StatusFound)
+		return
+	}
+
+	code, status := models.StatusSuccess, ""
+	switch data.Page.Name {
+	case "checks":
+		resp := adminChecks(ctx)
+		data.Checks, code, status = &resp, resp.ErrorCode, resp.Status
+	case "allowlist":
+		resp := adminAllowlist(ctx)
+		data.Allowlist, code, status = &resp, resp.ErrorCode, resp.Status
+	case "activity":
+		resp := adminActivity(ctx, r)
+		data.Activity, code, status = &resp, resp.ErrorCode, resp.Status
+	}
+	if code != models.StatusSuccess {
+		logWarningf(ctx, "could not render admin page %s: %s", data.Page.Name, status)
+		data = adminPageData{Page: data.Page, Pages: adminPages, Error: status}
+	}
+
+	var b bytes.Buffer
+	if err := adminUI.Execute(&b, data); err != nil {
+		logErrorf(ctx, "adminUI.Execute(): %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(httpStatus(code))
+	if _, err := w.Write(b.Bytes()); err != nil {
+		logErrorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// adminUI renders the pages of the administration UI. As with the API
+// documentation, pages are self-contained and load no third party scripts.
+var adminUI = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"hex":  func(b []byte) string { return fmt.Sprintf("%x", b) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Fresnel: {{.Page.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 80em; }
+nav a { margin-right: 1em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code { background: #f4f4f4; word-break: break-all; }
+.ok { color: #1a7f37; }
+.problem { color: #cf222e; }
+</style>
+</head>
+<body>
+<nav>{{range .Pages}}<a href="/admin/ui/{{.Name}}">{{.Title}}</a>{{end}}<a href="/docs">API</a></nav>
+<h1>{{.Page.Title}}</h1>
+{{if .Error}}<p class="problem">{{.Error}}</p>{{end}}
+{{with .Checks}}
+<p>{{if .Healthy}}<span class="ok">Every check passed.</span>{{else}}<span class="problem">Some checks failed.</span>{{end}}
+The same checks are available as JSON at <a href="/admin/checks">/admin/checks</a>.</p>
+<table>
+<tr><th>Check</th><th>Result</th><th>Detail</th></tr>
+{{range .Checks}}<tr><td>{{.Name}}</td><td>{{if .OK}}<span class="ok">OK</span>{{else}}<span class="problem">Problem</span>{{end}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Allowlist}}
+<p><code>{{.Path}}</code> lists {{len .Entries}} entries, last updated {{time .Updated}}.
+The allowlist is available as JSON at <a href="/admin/allowlist">/admin/allowlist</a>.</p>
+<table>
+<tr><th>Algorithm</th><th>Hash</th><th>Problem</th></tr>
+{{range .Entries}}<tr><td>{{.Algorithm}}</td><td><code>{{.Hash}}</code></td><td class="problem">{{.Problem}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Activity}}
+<p>Activity between {{time .Since}} and {{time .Until}}, most recent first.
+{{if .Truncated}}Older records were left out.{{end}}
+The activity is available as JSON at <a href="/admin/activity">/admin/activity</a>.</p>
+<h2>Seeds</h2>
+<table>
+<tr><th>Time</th><th>User</th><th>Host</th><th>Version</th><th>Batch</th><th>Seeds</th><th>Hash</th></tr>
+{{range .Seeds}}<tr><td>{{time .Time}}</td><td>{{.User}}</td><td>{{.Hostname}}</td><td>{{.Version}}</td><td>{{.Batch}}</td><td>{{.Count}}</td><td><code>{{.Algorithm}}:{{hex .Hash}}</code></td></tr>
+{{end}}</table>
+<h2>Signed URLs</h2>
+<table>
+<tr><th>Time</th><th>User</th><th>Object</th><th>Size</th><th>Hash</th></tr>
+{{range .Downloads}}<tr><td>{{time .Time}}</td><td>{{.User}}</td><td><code>{{.Path}}</code></td><td>{{.Size}}</td><td><code>{{.Algorithm}}:{{hex .Hash}}</code></td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,338 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/fresnel/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+// discardLogs replaces the App Engine loggers, which require an App Engine
+// context, and returns a function that restores them.
+func discardLogs() func() {
+	discard := func(context.Context, string, ...interface{}) {}
+	logErrorf, logWarningf, logInfof = discard, discard, discard
+	return func() {
+		logErrorf, logWarningf, logInfof = log.Errorf, log.Warningf, log.Infof
+	}
+}
+
+func adminUser(context.Context) *user.User {
+	return &user.User{Email: "admin@example.com", Admin: true}
+}
+
+func TestAllowlistEntry(t *testing.T) {
+	const sha256Hash = "314aaa98adcbd86339fb4eece6050b8ae2d38ff8ebb416e231bb7724c99b830d"
+	tests := []struct {
+		desc        string
+		entry       string
+		want        models.AllowlistEntry
+		wantProblem bool
+	}{
+		{
+			desc:  "sha256",
+			entry: sha256Hash,
+			want:  models.AllowlistEntry{Algorithm: models.HashSHA256, Hash: sha256Hash},
+		},
+		{
+			desc:  "prefixed sha256",
+			entry: "SHA256:" + strings.ToUpper(sha256Hash),
+			want:  models.AllowlistEntry{Algorithm: models.HashSHA256, Hash: sha256Hash},
+		},
+		{
+			desc:        "wrong size",
+			entry:       "sha512:" + sha256Hash,
+			want:        models.AllowlistEntry{Algorithm: models.HashSHA512, Hash: sha256Hash},
+			wantProblem: true,
+		},
+		{
+			desc:        "not hex",
+			entry:       "not-a-hash",
+			want:        models.AllowlistEntry{Algorithm: models.HashSHA256, Hash: "not-a-hash"},
+			wantProblem: true,
+		},
+	}
+	for _, tt := range tests {
+		got := allowlistEntry(tt.entry)
+		if (got.Problem != "") != tt.wantProblem {
+			t.Errorf("%s: allowlistEntry(%q) problem got: %q, want problem: %t", tt.desc, tt.entry, got.Problem, tt.wantProblem)
+		}
+		got.Problem = ""
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: allowlistEntry(%q) returned unexpected diff (-want +got):\n%s", tt.desc, tt.entry, diff)
+		}
+	}
+}
+
+func TestAdminAllowlist(t *testing.T) {
+	defer resetServices()
+	defer fakeBucket(map[string][]byte{
+		"appengine_config/pe_allowlist.yaml": []byte("- '314aaa98adcbd86339fb4eece6050b8ae2d38ff8ebb416e231bb7724c99b830d'\n- 'bad'\n"),
+	})()
+	updated := time.Now().Add(-time.Hour)
+	objectAttrs = func(context.Context, string, string) (*storage.ObjectAttrs, error) {
+		return &storage.ObjectAttrs{Updated: updated}, nil
+	}
+	defer func() { objectAttrs = bucketObjectAttrs }()
+	defer discardLogs()()
+	tests := []struct {
+		desc        string
+		user        func(context.Context) *user.User
+		envVars     map[string]string
+		want        models.StatusCode
+		wantEntries int
+	}{
+		{
+			desc: "no user",
+			user: func(context.Context) *user.User { return nil },
+			want: models.StatusInvalidUser,
+		},
+		{
+			desc:    "not an administrator",
+			user:    devUser("user@example.com"),
+			envVars: map[string]string{"BUCKET": "bucket"},
+			want:    models.StatusNotAdmin,
+		},
+		{
+			desc: "no bucket",
+			user: adminUser,
+			want: models.StatusConfigError,
+		},
+		{
+			desc:        "success",
+			user:        adminUser,
+			envVars:     map[string]string{"BUCKET": "bucket"},
+			want:        models.StatusSuccess,
+			wantEntries: 2,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		currentUser = tt.user
+		got := adminAllowlist(context.Background())
+		if got.ErrorCode != tt.want {
+			t.Errorf("%s: adminAllowlist() got: %v, want code: %d", tt.desc, got, tt.want)
+		}
+		if len(got.Entries) != tt.wantEntries {
+			t.Errorf("%s: adminAllowlist() got %d entries, want: %d", tt.desc, len(got.Entries), tt.wantEntries)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestAdminActivity(t *testing.T) {
+	defer resetServices()
+	defer fakeBucket(make(map[string][]byte))()
+	currentUser = adminUser
+	cleanup, err := prepEnvVariables(map[string]string{"ANALYTICS_BUCKET": "analytics"})
+	if err != nil {
+		t.Fatalf("prepEnvVariables() err: %v", err)
+	}
+	defer cleanup()
+	defer discardLogs()()
+
+	ctx := context.Background()
+	u := &user.User{Email: "operator@example.com"}
+	recordSeeds(ctx, models.SeedRequest{Hash: []byte{1}, Hostname: "station-1", Batch: "NYC"}, u, models.HashSHA256, 1)
+	recordSeeds(ctx, models.SeedRequest{Hash: []byte{1}, Hostname: "duplicator"}, u, models.HashSHA256, 20)
+	recordDownload(ctx, models.SignRequest{Seed: models.Seed{Username: u.Email}, Hash: []byte{1}, Path: "boot.wim"}, models.SignResponse{Size: 10})
+	records.flush()
+
+	got := adminActivity(ctx, httptest.NewRequest(http.MethodGet, "/admin/activity?days=1", nil))
+	if got.ErrorCode != models.StatusSuccess {
+		t.Fatalf("adminActivity() got: %v, want success", got)
+	}
+	if len(got.Seeds) != 2 || len(got.Downloads) != 1 || got.Truncated {
+		t.Fatalf("adminActivity() got %d seeds and %d downloads (truncated: %t), want 2 and 1", len(got.Seeds), len(got.Downloads), got.Truncated)
+	}
+	if got.Seeds[0].Count != 20 || got.Seeds[1].Hostname != "station-1" || got.Seeds[1].Batch != "NYC" {
+		t.Errorf("adminActivity() seeds got: %+v, want most recent first", got.Seeds)
+	}
+	if got.Seeds[0].User != u.Email || got.Downloads[0].Path != "boot.wim" {
+		t.Errorf("adminActivity() got: %+v, want records of %s", got, u.Email)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/activity?days=0", nil)
+	if got := adminActivity(ctx, r); got.ErrorCode != models.StatusReqUnreadable {
+		t.Errorf("adminActivity() with invalid days got: %v, want code: %d", got, models.StatusReqUnreadable)
+	}
+}
+
+func TestAdminChecks(t *testing.T) {
+	defer resetServices()
+	defer fakeBucket(map[string][]byte{
+		"appengine_config/pe_allowlist.yaml": []byte("- '314aaa98adcbd86339fb4eece6050b8ae2d38ff8ebb416e231bb7724c99b830d'\n"),
+	})()
+	objectAttrs = func(context.Context, string, string) (*storage.ObjectAttrs, error) {
+		return &storage.ObjectAttrs{Updated: time.Now()}, nil
+	}
+	defer func() { objectAttrs = bucketObjectAttrs }()
+	defer discardLogs()()
+	healthy := map[string]string{
+		"BUCKET":                 "bucket",
+		"ANALYTICS_BUCKET":       "analytics",
+		"SEED_VALIDITY_DURATION": "24h",
+		"SIGNED_URL_DURATION":    "60m",
+		"VERIFY_SEED":            "true",
+		"VERIFY_SEED_SIGNATURE":  "true",
+		"VERIFY_SEED_HASH":       "true",
+		"VERIFY_SIGN_HASH":       "true",
+	}
+	unenforced := make(map[string]string)
+	for k, v := range healthy {
+		unenforced[k] = v
+	}
+	unenforced["VERIFY_SIGN_HASH"] = "false"
+
+	tests := []struct {
+		desc        string
+		user        func(context.Context) *user.User
+		envVars     map[string]string
+		want        models.StatusCode
+		wantHealthy bool
+		wantFailed  []string
+	}{
+		{
+			desc:    "not an administrator",
+			user:    devUser("user@example.com"),
+			envVars: healthy,
+			want:    models.StatusNotAdmin,
+		},
+		{
+			desc:        "healthy",
+			user:        adminUser,
+			envVars:     healthy,
+			want:        models.StatusSuccess,
+			wantHealthy: true,
+		},
+		{
+			desc:       "verification not enforced",
+			user:       adminUser,
+			envVars:    unenforced,
+			want:       models.StatusSuccess,
+			wantFailed: []string{"Verification"},
+		},
+		{
+			desc:       "unconfigured",
+			user:       adminUser,
+			want:       models.StatusSuccess,
+			wantFailed: []string{"Bucket", "Allowlist", "Seed validity", "Signed URL duration", "Verification", "Analytics"},
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		currentUser = tt.user
+		got := adminChecks(context.Background())
+		if got.ErrorCode != tt.want || got.Healthy != tt.wantHealthy {
+			t.Errorf("%s: adminChecks() got: %+v, want code %d and healthy %t", tt.desc, got, tt.want, tt.wantHealthy)
+		}
+		var failed []string
+		for _, c := range got.Checks {
+			if !c.OK {
+				failed = append(failed, c.Name)
+			}
+		}
+		if diff := cmp.Diff(tt.wantFailed, failed); diff != "" {
+			t.Errorf("%s: adminChecks() failed checks returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestAdminUIHandler(t *testing.T) {
+	defer resetServices()
+	defer discardLogs()()
+	defer func() { loginURL = user.LoginURL }()
+	tests := []struct {
+		desc         string
+		path         string
+		user         func(context.Context) *user.User
+		loginErr     error
+		wantStatus   int
+		wantLocation string
+		wantText     string
+	}{
+		{
+			desc:       "unknown page",
+			path:       "/admin/ui/unknown",
+			user:       adminUser,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			desc:         "signed out",
+			path:         "/admin/ui/",
+			user:         func(context.Context) *user.User { return nil },
+			wantStatus:   http.StatusFound,
+			wantLocation: "/login",
+		},
+		{
+			desc:       "sign in unavailable",
+			path:       "/admin/ui/",
+			user:       func(context.Context) *user.User { return nil },
+			loginErr:   errors.New("unavailable"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "not an administrator",
+			path:       "/admin/ui/checks",
+			user:       devUser("user@example.com"),
+			wantStatus: http.StatusForbidden,
+			wantText:   "user is not an administrator",
+		},
+		{
+			desc:       "checks",
+			path:       "/admin/ui/",
+			user:       adminUser,
+			wantStatus: http.StatusOK,
+			wantText:   "Some checks failed.",
+		},
+	}
+	for _, tt := range tests {
+		currentUser = tt.user
+		loginURL = func(context.Context, string) (string, error) { return "/login", tt.loginErr }
+		w := httptest.NewRecorder()
+		AdminUIHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: ServeHTTP() status got: %d, want: %d", tt.desc, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Location"); got != tt.wantLocation {
+			t.Errorf("%s: ServeHTTP() location got: %q, want: %q", tt.desc, got, tt.wantLocation)
+		}
+		if !strings.Contains(w.Body.String(), tt.wantText) {
+			t.Errorf("%s: ServeHTTP() wrote %q, want it to contain %q", tt.desc, w.Body.String(), tt.wantText)
+		}
+	}
+}
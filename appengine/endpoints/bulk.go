@@ -0,0 +1,155 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine/user"
+)
+
+const (
+	// defaultBulkSeedMax is the number of seeds that a bulk request may ask for
+	// when BULK_SEED_MAX is not set.
+	defaultBulkSeedMax = 50
+	// nonceSize is the number of random bytes in the nonce of a bulk seed.
+	nonceSize = 16
+)
+
+var (
+	// Dependency injections for testing.
+	randRead = rand.Read
+
+	// Wrapped errors for bulk requests.
+	errBulkNotAllowed = errors.New("bulk seed request not allowed")
+)
+
+// BulkSeedRequestHandler implements http.Handler for bulk seed requests, which
+// issue several seeds for the same seed file in one call. They are intended
+// for duplicators that provision many devices from one verified master, and
+// are only accepted from users listed in BULK_SEED_USERS.
+type BulkSeedRequestHandler struct{}
+
+func (BulkSeedRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handleSeedRequest(w, r, true)
+}
+
+// unmarshalBulkSeedRequest parses a JSON object passed in an http request in
+// to a models.BulkSeedRequest object.
+func unmarshalBulkSeedRequest(r *http.Request) (models.BulkSeedRequest, error) {
+	var br models.BulkSeedRequest
+	body, err := readBody(r)
+	if err != nil {
+		return br, fmt.Errorf("error reading request body: %w", err)
+	}
+	if len(body) == 0 {
+		return br, fmt.Errorf("received empty bulk seed request")
+	}
+	if err := json.Unmarshal(body, &br); err != nil {
+		return br, fmt.Errorf("unable to unmarshal JSON request: %v", err)
+	}
+	return br, nil
+}
+
+// authorizeBulk ensures that the email address of u is listed in
+// BULK_SEED_USERS, and that count is no more than BULK_SEED_MAX. Bulk requests
+// are refused when BULK_SEED_USERS is not set.
+func authorizeBulk(u *user.User, count int) error {
+	allowed := false
+	for _, a := range strings.Split(os.Getenv("BULK_SEED_USERS"), ",") {
+		a = strings.TrimSpace(a)
+		if a != "" && (strings.EqualFold(a, u.Email) || strings.EqualFold(a, u.String())) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s is not listed in BULK_SEED_USERS", errBulkNotAllowed, u.String())
+	}
+	limit, err := bulkSeedMax()
+	if err != nil {
+		return err
+	}
+	if count < 1 || count > limit {
+		return fmt.Errorf("%w: %d seeds requested, between 1 and %d may be issued at once", errBulkNotAllowed, count, limit)
+	}
+	return nil
+}
+
+// bulkSeedMax returns the number of seeds that may be issued by a single bulk
+// request, as configured by BULK_SEED_MAX.
+func bulkSeedMax() (int, error) {
+	v := os.Getenv("BULK_SEED_MAX")
+	if v == "" {
+		return defaultBulkSeedMax, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("BULK_SEED_MAX=%q is not a positive integer", v)
+	}
+	return n, nil
+}
+
+// issueBulkSeeds issues count seeds for hash to u, and writes them to w as a
+// models.BulkSeedResponse. Each seed carries a random nonce and is signed
+// separately, so that no two devices are provisioned with the same seed. It
+// returns whether the seeds were issued.
+func issueBulkSeeds(ctx context.Context, w http.ResponseWriter, hash []byte, u *user.User, count int, alg models.HashAlgorithm, algs []models.HashAlgorithm) bool {
+	errSeedResp := `{"Status":"%s","ErrorCode":%d}`
+	resp := models.BulkSeedResponse{
+		Status:     "success",
+		ErrorCode:  models.StatusSuccess,
+		Algorithm:  alg,
+		Algorithms: algs,
+	}
+	for n := 0; n < count; n++ {
+		s := generateSeed(hash, u)
+		s.Nonce = make([]byte, nonceSize)
+		if _, err := randRead(s.Nonce); err != nil {
+			logErrorf(ctx, "rand.Read(): %v", err)
+			http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusSeedError), http.StatusInternalServerError)
+			return false
+		}
+		sr, err := signSeed(ctx, s)
+		if err != nil {
+			logErrorf(ctx, "signSeed(): %v", err)
+			http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusSignError), http.StatusInternalServerError)
+			return false
+		}
+		sr.Algorithm = alg
+		resp.Seeds = append(resp.Seeds, sr)
+	}
+	jsonResponse, err := json.Marshal(resp)
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(BulkSeedResponse): %v", err)
+		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusJSONError), http.StatusInternalServerError)
+		return false
+	}
+	if _, err := w.Write(jsonResponse); err != nil {
+		logErrorf(ctx, "failed to write response to client: %v", err)
+		return false
+	}
+	logInfof(ctx, "successfully issued %d seeds to %s for %s hash %x", count, u.String(), alg, hash)
+	return true
+}
@@ -0,0 +1,240 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine/user"
+)
+
+func TestAuthorizeBulk(t *testing.T) {
+	u := &user.User{Email: "dup@example.com"}
+	tests := []struct {
+		desc    string
+		envVars map[string]string
+		count   int
+		wantErr error
+	}{
+		{
+			desc:    "not configured",
+			count:   2,
+			wantErr: errBulkNotAllowed,
+		},
+		{
+			desc:    "not listed",
+			envVars: map[string]string{"BULK_SEED_USERS": "other@example.com"},
+			count:   2,
+			wantErr: errBulkNotAllowed,
+		},
+		{
+			desc:    "listed",
+			envVars: map[string]string{"BULK_SEED_USERS": "other@example.com, DUP@example.com"},
+			count:   defaultBulkSeedMax,
+		},
+		{
+			desc:    "too many",
+			envVars: map[string]string{"BULK_SEED_USERS": "dup@example.com"},
+			count:   defaultBulkSeedMax + 1,
+			wantErr: errBulkNotAllowed,
+		},
+		{
+			desc:    "none",
+			envVars: map[string]string{"BULK_SEED_USERS": "dup@example.com"},
+			wantErr: errBulkNotAllowed,
+		},
+		{
+			desc:    "configured maximum",
+			envVars: map[string]string{"BULK_SEED_USERS": "dup@example.com", "BULK_SEED_MAX": "5"},
+			count:   6,
+			wantErr: errBulkNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		if err := authorizeBulk(u, tt.count); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: authorizeBulk() err got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestBulkSeedMax(t *testing.T) {
+	tests := []struct {
+		desc    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{desc: "default", want: defaultBulkSeedMax},
+		{desc: "configured", value: "10", want: 10},
+		{desc: "zero", value: "0", wantErr: true},
+		{desc: "not a number", value: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(map[string]string{"BULK_SEED_MAX": tt.value})
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		got, err := bulkSeedMax()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: bulkSeedMax() err got: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: bulkSeedMax() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestUnmarshalBulkSeedRequest(t *testing.T) {
+	tests := []struct {
+		desc      string
+		body      string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			desc:      "valid request",
+			body:      fmt.Sprintf(`{"Request":{"Hash":"%s"},"Count":3}`, testHash),
+			wantCount: 3,
+		},
+		{
+			desc:    "empty",
+			wantErr: true,
+		},
+		{
+			desc:    "invalid json",
+			body:    "this should fail",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/seed/bulk", strings.NewReader(tt.body))
+		br, err := unmarshalBulkSeedRequest(req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unmarshalBulkSeedRequest() err got: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if br.Count != tt.wantCount {
+			t.Errorf("%s: unmarshalBulkSeedRequest() count got: %d, want: %d", tt.desc, br.Count, tt.wantCount)
+		}
+	}
+}
+
+func TestIssueBulkSeeds(t *testing.T) {
+	defer discardLogs()()
+	defer func() {
+		signSeed = signSeedResponse
+		randRead = rand.Read
+	}()
+	signSeed = func(_ context.Context, s models.Seed) (models.SeedResponse, error) {
+		if s.Username == "fail@example.com" {
+			return models.SeedResponse{}, errors.New("test")
+		}
+		return models.SeedResponse{Status: "success", Seed: s, Signature: s.Nonce}, nil
+	}
+	tests := []struct {
+		desc       string
+		user       string
+		randErr    error
+		wantStatus int
+		wantSeeds  int
+	}{
+		{
+			desc:       "success",
+			user:       "dup@example.com",
+			wantStatus: http.StatusOK,
+			wantSeeds:  3,
+		},
+		{
+			desc:       "sign error",
+			user:       "fail@example.com",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:       "nonce error",
+			user:       "dup@example.com",
+			randErr:    errors.New("test"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		randRead = func(b []byte) (int, error) {
+			if tt.randErr != nil {
+				return 0, tt.randErr
+			}
+			return rand.Read(b)
+		}
+		w := httptest.NewRecorder()
+		issueBulkSeeds(context.Background(), w, []byte(testHash), &user.User{Email: tt.user}, 3, models.HashSHA256, []models.HashAlgorithm{models.HashSHA256})
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: issueBulkSeeds() status got: %d, want: %d", tt.desc, w.Code, tt.wantStatus)
+		}
+		var resp models.BulkSeedResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: json.Unmarshal() err: %v", tt.desc, err)
+			continue
+		}
+		if len(resp.Seeds) != tt.wantSeeds {
+			t.Errorf("%s: issueBulkSeeds() got %d seeds, want: %d", tt.desc, len(resp.Seeds), tt.wantSeeds)
+		}
+		nonces := make(map[string]bool)
+		for _, s := range resp.Seeds {
+			if len(s.Seed.Nonce) != nonceSize || nonces[string(s.Seed.Nonce)] {
+				t.Errorf("%s: issueBulkSeeds() seed has missing or repeated nonce %x", tt.desc, s.Seed.Nonce)
+			}
+			nonces[string(s.Seed.Nonce)] = true
+			if s.Algorithm != models.HashSHA256 {
+				t.Errorf("%s: issueBulkSeeds() seed algorithm got: %q, want: %q", tt.desc, s.Algorithm, models.HashSHA256)
+			}
+		}
+	}
+}
+
+func TestBulkSeedRequestHandlerNotAllowed(t *testing.T) {
+	defer resetServices()
+	defer discardLogs()()
+	currentUser = devUser("dup@example.com")
+	body := fmt.Sprintf(`{"Request":{"Hash":"%s"},"Count":3}`, testHash)
+	w := httptest.NewRecorder()
+	BulkSeedRequestHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/seed/bulk", bytes.NewReader([]byte(body))))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("ServeHTTP() status got: %d, want: %d", w.Code, http.StatusForbidden)
+	}
+	var resp models.BulkSeedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json.Unmarshal() err: %v", err)
+	}
+	if resp.ErrorCode != models.StatusBulkNotAllowed {
+		t.Errorf("ServeHTTP() ErrorCode got: %d, want: %d", resp.ErrorCode, models.StatusBulkNotAllowed)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// gzipThreshold is the smallest response body that is worth compressing.
+const gzipThreshold = 1024
+
+// writeCacheable writes body to w with the given status. Successful responses
+// carry an ETag derived from the body, and a request whose If-None-Match
+// header matches it receives 304 Not Modified without a body. Bodies are gzip
+// compressed for clients that accept it. The ETag is weak because it
+// identifies the uncompressed body regardless of the encoding used to send it.
+func writeCacheable(w http.ResponseWriter, r *http.Request, status int, body []byte) error {
+	h := w.Header()
+	h.Set("Vary", "Accept-Encoding")
+	if status == http.StatusOK {
+		sum := sha256.Sum256(body)
+		etag := fmt.Sprintf(`W/"%s"`, hex.EncodeToString(sum[:16]))
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", "no-cache")
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+	if len(body) >= gzipThreshold && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		if _, err := zw.Write(body); err != nil {
+			return fmt.Errorf("gzip.Write(): %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("gzip.Close(): %v", err)
+		}
+		h.Set("Content-Encoding", "gzip")
+		body = b.Bytes()
+	}
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	return err
+}
+
+// etagMatch reports whether an If-None-Match header value matches etag,
+// using the weak comparison required for If-None-Match.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether an Accept-Encoding header value permits a gzip
+// encoded response.
+func acceptsGzip(header string) bool {
+	for _, c := range strings.Split(header, ",") {
+		parts := strings.Split(c, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		refused := false
+		for _, p := range parts[1:] {
+			p = strings.ReplaceAll(p, " ", "")
+			if p == "q=0" || strings.HasPrefix(p, "q=0.") && strings.Trim(p[len("q=0."):], "0") == "" {
+				refused = true
+			}
+		}
+		if !refused {
+			return true
+		}
+	}
+	return false
+}
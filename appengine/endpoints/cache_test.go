@@ -0,0 +1,155 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteCacheable(t *testing.T) {
+	large := strings.Repeat("fresnel", gzipThreshold)
+	etag := func(body string) string {
+		w := httptest.NewRecorder()
+		writeCacheable(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, []byte(body))
+		return w.Header().Get("ETag")
+	}
+	tests := []struct {
+		desc        string
+		status      int
+		body        string
+		ifNoneMatch string
+		encoding    string
+		wantStatus  int
+		wantGzip    bool
+		wantETag    bool
+	}{
+		{
+			desc:       "small body",
+			status:     http.StatusOK,
+			body:       "{}",
+			encoding:   "gzip",
+			wantStatus: http.StatusOK,
+			wantETag:   true,
+		},
+		{
+			desc:       "large body compressed",
+			status:     http.StatusOK,
+			body:       large,
+			encoding:   "deflate, gzip;q=0.8",
+			wantStatus: http.StatusOK,
+			wantGzip:   true,
+			wantETag:   true,
+		},
+		{
+			desc:       "large body without gzip",
+			status:     http.StatusOK,
+			body:       large,
+			encoding:   "gzip;q=0",
+			wantStatus: http.StatusOK,
+			wantETag:   true,
+		},
+		{
+			desc:        "matching etag",
+			status:      http.StatusOK,
+			body:        large,
+			ifNoneMatch: `"other", ` + etag(large),
+			encoding:    "gzip",
+			wantStatus:  http.StatusNotModified,
+			wantETag:    true,
+		},
+		{
+			desc:        "stale etag",
+			status:      http.StatusOK,
+			body:        large,
+			ifNoneMatch: etag("{}"),
+			wantStatus:  http.StatusOK,
+			wantETag:    true,
+		},
+		{
+			desc:        "error is not cacheable",
+			status:      http.StatusServiceUnavailable,
+			body:        "{}",
+			ifNoneMatch: "*",
+			wantStatus:  http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		r.Header.Set("Accept-Encoding", tt.encoding)
+		w := httptest.NewRecorder()
+		if err := writeCacheable(w, r, tt.status, []byte(tt.body)); err != nil {
+			t.Errorf("%s: writeCacheable() err: %v", tt.desc, err)
+			continue
+		}
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: writeCacheable() status got: %d, want: %d", tt.desc, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("ETag") != ""; got != tt.wantETag {
+			t.Errorf("%s: writeCacheable() ETag set got: %t, want: %t", tt.desc, got, tt.wantETag)
+		}
+		if got := w.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+			t.Errorf("%s: writeCacheable() gzip got: %t, want: %t", tt.desc, got, tt.wantGzip)
+		}
+		if tt.wantStatus == http.StatusNotModified {
+			if w.Body.Len() != 0 {
+				t.Errorf("%s: writeCacheable() wrote %d bytes with 304", tt.desc, w.Body.Len())
+			}
+			continue
+		}
+		var body io.Reader = w.Body
+		if tt.wantGzip {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("%s: gzip.NewReader() err: %v", tt.desc, err)
+				continue
+			}
+			body = zr
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Errorf("%s: io.ReadAll() err: %v", tt.desc, err)
+		}
+		if string(got) != tt.body {
+			t.Errorf("%s: writeCacheable() body got %d bytes, want %d", tt.desc, len(got), len(tt.body))
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, br", false},
+		{"br, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) got: %t, want: %t", tt.header, got, tt.want)
+		}
+	}
+}
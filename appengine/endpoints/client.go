@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine/user"
+)
+
+// emailScope is the OAuth scope that tokens must be issued with for the
+// principal they identify to be accepted.
+const emailScope = "https://www.googleapis.com/auth/userinfo.email"
+
+var (
+	// App Engine user services, replaced for testing.
+	signedInUser = user.Current
+	oauthUser    = user.CurrentOAuth
+
+	// Wrapped errors for client version checks.
+	errClientTooOld = errors.New("client version too old")
+)
+
+// requestUser returns the user signed in to the app. Requests made without
+// interaction, such as by a scheduled job impersonating a service account,
+// carry an OAuth token instead, and the principal that the token was issued
+// to is returned. That is the service account itself, or the user it acts as
+// through domain-wide delegation, and it is the principal that seeds are
+// issued to. Nil is returned when the request identifies neither.
+func requestUser(ctx context.Context) *user.User {
+	if u := signedInUser(ctx); u != nil {
+		return u
+	}
+	u, err := oauthUser(ctx, emailScope)
+	if err != nil {
+		logWarningf(ctx, "user.CurrentOAuth(): %v", err)
+		return nil
+	}
+	if u != nil {
+		logInfof(ctx, "request authenticated by OAuth token as %s", u.String())
+	}
+	return u
+}
+
+// logClient records the identity of the client making a request.
+func logClient(ctx context.Context, r *http.Request) {
+	logInfof(ctx, "request from client %q, version %q", r.UserAgent(), r.Header.Get(models.ClientVersionHeader))
+}
+
+// devVersion is the version reported by builds of the CLI that were not
+// given a release version at build time.
+const devVersion = "dev"
+
+// checkClientVersion enforces the minimum client version configured using the
+// MIN_CLIENT_VERSION environment variable. When required is true, requests
+// that do not identify their version are treated as too old. Other callers,
+// such as installers that do not send a version, are only checked when they
+// identify one. Versions that cannot be parsed are refused.
+func checkClientVersion(r *http.Request, required bool) error {
+	minVersion := os.Getenv("MIN_CLIENT_VERSION")
+	if minVersion == "" {
+		return nil
+	}
+	v := r.Header.Get(models.ClientVersionHeader)
+	if v == "" {
+		if required {
+			return fmt.Errorf("%w: no version provided, minimum is %q", errClientTooOld, minVersion)
+		}
+		return nil
+	}
+	// Development builds do not carry a release version. As clients choose
+	// the version they report, they are only exempt from the minimum on
+	// development servers.
+	if v == devVersion && devMode {
+		return nil
+	}
+	c, err := compareVersions(v, minVersion)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errClientTooOld, err)
+	}
+	if c < 0 {
+		return fmt.Errorf("%w: %q is older than minimum %q", errClientTooOld, v, minVersion)
+	}
+	return nil
+}
+
+// compareVersions compares two dotted version strings, such as '1.2.3' or
+// 'v1.2', numerically. It returns -1 if a is older than b, 1 if a is newer than
+// b and 0 if they are equal. Pre-release and build suffixes, such as those of
+// '1.2.3-rc1' and '1.2.3+abc', are ignored. An error is returned if either
+// version cannot be parsed.
+func compareVersions(a, b string) (int, error) {
+	as, err := versionParts(a)
+	if err != nil {
+		return 0, err
+	}
+	bs, err := versionParts(b)
+	if err != nil {
+		return 0, err
+	}
+	for len(as) < len(bs) {
+		as = append(as, 0)
+	}
+	for len(bs) < len(as) {
+		bs = append(bs, 0)
+	}
+	for i := range as {
+		switch {
+		case as[i] < bs[i]:
+			return -1, nil
+		case as[i] > bs[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// versionParts splits a version string into its numeric components, after
+// removing any pre-release or build suffix.
+func versionParts(v string) ([]int, error) {
+	core := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q is not a valid version", v)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
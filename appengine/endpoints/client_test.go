@@ -0,0 +1,209 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine/user"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a       string
+		b       string
+		want    int
+		wantErr bool
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.2.3", b: "1.10.0", want: -1},
+		{a: "2.0", b: "1.9.9", want: 1},
+		{a: "1.2.3-rc1", b: "1.2.3", want: 0},
+		{a: "1.2.3-rc1", b: "1.2.4", want: -1},
+		{a: "1.2.3+abc", b: "1.2.1", want: 1},
+		{a: "dev", b: "1.0.0", wantErr: true},
+		{a: "1.x.3", b: "1.0.0", wantErr: true},
+		{a: "1.2.3", b: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := compareVersions(tt.a, tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("compareVersions(%q, %q) err: %v, want error: %t", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("compareVersions(%q, %q) got: %d, want: %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckClientVersion(t *testing.T) {
+	tests := []struct {
+		desc     string
+		min      string
+		version  string
+		required bool
+		devMode  bool
+		want     error
+	}{
+		{
+			desc:     "no minimum",
+			version:  "0.1.0",
+			required: true,
+			want:     nil,
+		},
+		{
+			desc:     "meets minimum",
+			min:      "1.2.0",
+			version:  "1.2.0",
+			required: true,
+			want:     nil,
+		},
+		{
+			desc:     "below minimum",
+			min:      "1.2.0",
+			version:  "1.1.9",
+			required: true,
+			want:     errClientTooOld,
+		},
+		{
+			desc:     "pre-release of minimum",
+			min:      "1.2.0",
+			version:  "1.2.0-rc1",
+			required: true,
+			want:     nil,
+		},
+		{
+			desc:     "pre-release below minimum",
+			min:      "1.2.0",
+			version:  "1.1.9-rc1",
+			required: true,
+			want:     errClientTooOld,
+		},
+		{
+			desc:     "unparseable version",
+			min:      "1.2.0",
+			version:  "latest",
+			required: true,
+			want:     errClientTooOld,
+		},
+		{
+			desc:     "development build",
+			min:      "1.2.0",
+			version:  "dev",
+			required: true,
+			want:     errClientTooOld,
+		},
+		{
+			desc:     "development build on development server",
+			min:      "1.2.0",
+			version:  "dev",
+			required: true,
+			devMode:  true,
+			want:     nil,
+		},
+		{
+			desc:     "missing version required",
+			min:      "1.2.0",
+			required: true,
+			want:     errClientTooOld,
+		},
+		{
+			desc:     "missing version optional",
+			min:      "1.2.0",
+			required: false,
+			want:     nil,
+		},
+	}
+	defer func() { devMode = false }()
+	for _, tt := range tests {
+		devMode = tt.devMode
+		cleanup, err := prepEnvVariables(map[string]string{"MIN_CLIENT_VERSION": tt.min})
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/seed", nil)
+		if tt.version != "" {
+			r.Header.Set(models.ClientVersionHeader, tt.version)
+		}
+		got := checkClientVersion(r, tt.required)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: checkClientVersion() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestRequestUser(t *testing.T) {
+	defer func() { signedInUser = user.Current; oauthUser = user.CurrentOAuth }()
+	defer discardLogs()()
+	tests := []struct {
+		desc      string
+		signedIn  *user.User
+		oauth     *user.User
+		oauthErr  error
+		wantUser  string
+		wantScope string
+	}{
+		{
+			desc:     "signed in",
+			signedIn: &user.User{Email: "operator@example.com"},
+			oauth:    &user.User{Email: "writer@project.iam.gserviceaccount.com"},
+			wantUser: "operator@example.com",
+		},
+		{
+			desc:      "service account",
+			oauth:     &user.User{Email: "writer@project.iam.gserviceaccount.com"},
+			wantUser:  "writer@project.iam.gserviceaccount.com",
+			wantScope: emailScope,
+		},
+		{
+			desc:      "invalid token",
+			oauthErr:  errors.New("invalid token"),
+			wantScope: emailScope,
+		},
+		{
+			desc:      "anonymous",
+			wantScope: emailScope,
+		},
+	}
+	for _, tt := range tests {
+		signedInUser = func(context.Context) *user.User { return tt.signedIn }
+		scope := ""
+		oauthUser = func(_ context.Context, scopes ...string) (*user.User, error) {
+			scope = scopes[0]
+			return tt.oauth, tt.oauthErr
+		}
+		got := ""
+		if u := requestUser(context.Background()); u != nil {
+			got = u.String()
+		}
+		if got != tt.wantUser {
+			t.Errorf("%s: requestUser() got: %q, want: %q", tt.desc, got, tt.wantUser)
+		}
+		if scope != tt.wantScope {
+			t.Errorf("%s: requestUser() requested scope %q, want: %q", tt.desc, scope, tt.wantScope)
+		}
+	}
+}
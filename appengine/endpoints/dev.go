@@ -0,0 +1,181 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/user"
+)
+
+const (
+	// devKeyName is the key name reported for seeds signed by the fake signer.
+	devKeyName = "fresnel-dev"
+	// devServiceAccount is the service account reported by the fake signer.
+	devServiceAccount = "fresnel-dev@localhost"
+)
+
+var (
+	// App Engine services used by the endpoints. Configure replaces these with
+	// local equivalents for development.
+	publicCertificates = appengine.PublicCertificates
+	signBytes          = appengine.SignBytes
+	serviceAccount     = appengine.ServiceAccount
+	currentUser        = requestUser
+	signURL            = signedURL
+	isAppEngine        = appengine.IsAppEngine
+	// devMode is set by Configure when the fake signer is used, and relaxes
+	// checks that only apply to releases of the CLI.
+	devMode = false
+
+	errConfigure = errors.New("configuration error")
+)
+
+// Configure selects the services used by the endpoints from the environment.
+// It must be called before the endpoints begin serving requests.
+//
+// SIGNER selects how seeds and URLs are signed. The default, "appengine",
+// uses the App Engine app identity. "fake" signs with a key generated at
+// startup, which is only suitable for local development. When the fake
+// signer is used, DEV_USER sets the identity of every request, and URLs
+// point at the Cloud Storage emulator configured by STORAGE_EMULATOR_HOST.
+// The fake signer and DEV_USER are refused when running on App Engine, where
+// they would sign seeds with a throwaway key and trust every caller.
+func Configure() error {
+	switch s := os.Getenv("SIGNER"); s {
+	case "", "appengine":
+		if os.Getenv("DEV_USER") != "" {
+			return fmt.Errorf("%w: DEV_USER requires SIGNER=fake", errConfigure)
+		}
+		return nil
+	case "fake":
+		if onAppEngine() {
+			return fmt.Errorf("%w: SIGNER=fake and DEV_USER are only for local development, not App Engine", errConfigure)
+		}
+		d, err := newDevSigner()
+		if err != nil {
+			return err
+		}
+		publicCertificates = d.publicCertificates
+		signBytes = d.signBytes
+		serviceAccount = d.serviceAccount
+		devMode = true
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			signURL = emulatorURL(host)
+		}
+		if email := os.Getenv("DEV_USER"); email != "" {
+			currentUser = devUser(email)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown SIGNER %q, want appengine or fake", errConfigure, s)
+	}
+}
+
+// onAppEngine determines whether the endpoints are served by App Engine,
+// which sets GAE_ENV in every runtime.
+func onAppEngine() bool {
+	return isAppEngine() || os.Getenv("GAE_ENV") != ""
+}
+
+// devSigner signs with a self-signed certificate that is generated when it
+// is created, in place of the App Engine app identity.
+type devSigner struct {
+	key  *rsa.PrivateKey
+	cert appengine.Certificate
+}
+
+func newDevSigner() (*devSigner, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.GenerateKey: %v", err)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{CommonName: devServiceAccount},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("x509.CreateCertificate: %v", err)
+	}
+	return &devSigner{
+		key: key,
+		cert: appengine.Certificate{
+			KeyName: devKeyName,
+			Data:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}, nil
+}
+
+func (d *devSigner) publicCertificates(context.Context) ([]appengine.Certificate, error) {
+	return []appengine.Certificate{d.cert}, nil
+}
+
+// signBytes signs b the same way as appengine.SignBytes, using
+// RSASSA-PKCS1-v1_5 with SHA-256.
+func (d *devSigner) signBytes(_ context.Context, b []byte) (string, []byte, error) {
+	h := sha256.Sum256(b)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, d.key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", nil, fmt.Errorf("rsa.SignPKCS1v15: %v", err)
+	}
+	return devKeyName, sig, nil
+}
+
+func (d *devSigner) serviceAccount(context.Context) (string, error) {
+	return devServiceAccount, nil
+}
+
+// devUser returns a replacement for user.Current that authenticates every
+// request as email.
+func devUser(email string) func(context.Context) *user.User {
+	return func(context.Context) *user.User {
+		return &user.User{Email: email, AuthDomain: "localhost"}
+	}
+}
+
+// emulatorURL returns a replacement for signedURL that links directly to
+// objects in the Cloud Storage emulator at host. The emulator does not
+// check signatures, so the URLs are not signed.
+func emulatorURL(host string) func(context.Context, string, string, time.Time) (string, error) {
+	return func(_ context.Context, bucket, file string, _ time.Time) (string, error) {
+		u, err := url.Parse(host)
+		if err != nil || u.Host == "" {
+			u = &url.URL{Scheme: "http", Host: host}
+		}
+		u.Path = fmt.Sprintf("/storage/v1/b/%s/o/%s", bucket, file)
+		u.RawPath = fmt.Sprintf("/storage/v1/b/%s/o/%s", url.PathEscape(bucket), url.PathEscape(file))
+		u.RawQuery = "alt=media"
+		return u.String(), nil
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+// resetServices restores the App Engine services replaced by Configure.
+func resetServices() {
+	publicCertificates = appengine.PublicCertificates
+	signBytes = appengine.SignBytes
+	serviceAccount = appengine.ServiceAccount
+	currentUser = requestUser
+	signURL = signedURL
+	isAppEngine = appengine.IsAppEngine
+	devMode = false
+}
+
+func TestConfigure(t *testing.T) {
+	defer resetServices()
+	tests := []struct {
+		desc      string
+		envVars   map[string]string
+		appEngine bool
+		wantSA    string
+		wantUser  string
+		wantURL   string
+		wantErr   error
+	}{
+		{
+			desc: "default",
+		},
+		{
+			desc:    "appengine",
+			envVars: map[string]string{"SIGNER": "appengine"},
+		},
+		{
+			desc:    "dev user without fake signer",
+			envVars: map[string]string{"DEV_USER": "dev@localhost"},
+			wantErr: errConfigure,
+		},
+		{
+			desc:    "unknown signer",
+			envVars: map[string]string{"SIGNER": "kms"},
+			wantErr: errConfigure,
+		},
+		{
+			desc:    "fake",
+			envVars: map[string]string{"SIGNER": "fake"},
+			wantSA:  devServiceAccount,
+		},
+		{
+			desc:      "fake on app engine",
+			envVars:   map[string]string{"SIGNER": "fake"},
+			appEngine: true,
+			wantErr:   errConfigure,
+		},
+		{
+			desc:    "fake with user on app engine runtime",
+			envVars: map[string]string{"SIGNER": "fake", "DEV_USER": "dev@localhost", "GAE_ENV": "standard"},
+			wantErr: errConfigure,
+		},
+		{
+			desc:     "fake with user and emulator",
+			envVars:  map[string]string{"SIGNER": "fake", "DEV_USER": "dev@localhost", "STORAGE_EMULATOR_HOST": "localhost:4443"},
+			wantSA:   devServiceAccount,
+			wantUser: "dev@localhost",
+			wantURL:  "http://localhost:4443/storage/v1/b/bucket/o/boot.wim?alt=media",
+		},
+	}
+	for _, tt := range tests {
+		resetServices()
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		appEngine := tt.appEngine
+		isAppEngine = func() bool { return appEngine }
+		err = Configure()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Configure() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if wantDev := tt.wantSA != ""; devMode != wantDev {
+			t.Errorf("%s: Configure() devMode got: %t, want: %t", tt.desc, devMode, wantDev)
+		}
+		if tt.wantSA != "" {
+			if got, err := serviceAccount(context.Background()); got != tt.wantSA || err != nil {
+				t.Errorf("%s: serviceAccount() got: %q, %v, want: %q, <nil>", tt.desc, got, err, tt.wantSA)
+			}
+		}
+		if tt.wantUser != "" {
+			if u := currentUser(context.Background()); u == nil || u.Email != tt.wantUser {
+				t.Errorf("%s: currentUser() got: %v, want: %q", tt.desc, u, tt.wantUser)
+			}
+		}
+		if tt.wantURL != "" {
+			if got, err := signURL(context.Background(), "bucket", "boot.wim", time.Now()); got != tt.wantURL || err != nil {
+				t.Errorf("%s: signURL() got: %q, %v, want: %q, <nil>", tt.desc, got, err, tt.wantURL)
+			}
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestDevSigner(t *testing.T) {
+	d, err := newDevSigner()
+	if err != nil {
+		t.Fatalf("newDevSigner() returned %v", err)
+	}
+	publicCertificates = d.publicCertificates
+	defer resetServices()
+	defer discardLogs()()
+
+	seed := models.Seed{Username: "dev@localhost", Issued: time.Now()}
+	sig, err := signSeedBytes(context.Background(), d, seed)
+	if err != nil {
+		t.Fatalf("signing seed returned %v", err)
+	}
+	if err := validSeedSignature(context.Background(), seed, sig); err != nil {
+		t.Errorf("validSeedSignature() with a seed signed by the fake signer returned %v", err)
+	}
+	seed.Username = "someone@localhost"
+	if err := validSeedSignature(context.Background(), seed, sig); err == nil {
+		t.Errorf("validSeedSignature() with a modified seed returned nil, want error")
+	}
+}
+
+// signSeedBytes signs the JSON encoding of seed with d.
+func signSeedBytes(ctx context.Context, d *devSigner, seed models.Seed) ([]byte, error) {
+	b, err := json.Marshal(seed)
+	if err != nil {
+		return nil, err
+	}
+	_, sig, err := d.signBytes(ctx, b)
+	return sig, err
+}
+
+func TestEmulatorURL(t *testing.T) {
+	tests := []struct {
+		desc string
+		host string
+		file string
+		want string
+	}{
+		{
+			desc: "host and port",
+			host: "localhost:4443",
+			file: "boot.wim",
+			want: "http://localhost:4443/storage/v1/b/bucket/o/boot.wim?alt=media",
+		},
+		{
+			desc: "url",
+			host: "https://127.0.0.1:9023",
+			file: "boot.wim",
+			want: "https://127.0.0.1:9023/storage/v1/b/bucket/o/boot.wim?alt=media",
+		},
+		{
+			desc: "nested object",
+			host: "localhost:4443",
+			file: "release/boot.wim",
+			want: "http://localhost:4443/storage/v1/b/bucket/o/release%2Fboot.wim?alt=media",
+		},
+	}
+	for _, tt := range tests {
+		got, err := emulatorURL(tt.host)(context.Background(), "bucket", tt.file, time.Now())
+		if err != nil {
+			t.Errorf("%s: emulatorURL(%q)() returned %v", tt.desc, tt.host, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: emulatorURL(%q)() got: %q, want: %q", tt.desc, tt.host, got, tt.want)
+		}
+	}
+}
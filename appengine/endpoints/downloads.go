@@ -0,0 +1,534 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	golog "log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/fresnel/models"
+	"google.golang.org/api/iterator"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/user"
+)
+
+const (
+	// recordPrefix is the prefix of the download records stored in
+	// ANALYTICS_BUCKET. Records are grouped by the day they were made on.
+	recordPrefix = "downloads/"
+	// seedRecordPrefix is the prefix of the seed records stored in
+	// ANALYTICS_BUCKET, which are grouped in the same way.
+	seedRecordPrefix = "seeds/"
+	// defaultSummaryDays is the number of days summarized when none are requested.
+	defaultSummaryDays = 7
+	// maxSummaryDays limits the number of days of records read by one request.
+	maxSummaryDays = 90
+	// maxRecordObjects limits the number of record objects read by one
+	// request, so that busy periods cannot exhaust the request deadline.
+	maxRecordObjects = 2000
+	// recordFlushInterval is the longest that an instance buffers records
+	// before storing them.
+	recordFlushInterval = time.Minute
+	// maxBufferedRecords is the number of buffered records at which they are
+	// stored without waiting for recordFlushInterval.
+	maxBufferedRecords = 100
+	// recordWriteTimeout bounds the time taken to store buffered records.
+	recordWriteTimeout = 30 * time.Second
+)
+
+var (
+	listObjects = bucketObjects
+	writeObject = bucketWriteObject
+
+	// records buffers the download and seed records of this instance.
+	records = &recordBuffer{}
+
+	// Wrapped errors for testing.
+	errNotAdmin    = errors.New("user is not an administrator")
+	errSummaryDays = errors.New("invalid number of days")
+	errTooManyRecs = errors.New("too many records")
+)
+
+// recordDownload buffers a models.DownloadRecord to be stored in
+// ANALYTICS_BUCKET for the signed URL issued in resp. Nothing is recorded when
+// ANALYTICS_BUCKET is not set, and failing to store the record does not fail
+// the sign request.
+func recordDownload(ctx context.Context, req models.SignRequest, resp models.SignResponse) {
+	bucket := os.Getenv("ANALYTICS_BUCKET")
+	if bucket == "" {
+		return
+	}
+	rec := models.DownloadRecord{
+		Time:      time.Now().UTC(),
+		User:      req.Seed.Username,
+		Hash:      req.Hash,
+		Algorithm: algorithmOf(req.Algorithm),
+		Path:      req.Path,
+		Size:      resp.Size,
+		URLID:     urlID(resp.SignedURL),
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(%#v): %v", rec, err)
+		return
+	}
+	records.add(recordPrefix+rec.Time.Format("2006/01/02/"), b)
+	logInfof(ctx, "recorded download of %s by %s", rec.Path, rec.User)
+}
+
+// recordSeeds buffers a models.SeedRecord to be stored in ANALYTICS_BUCKET for
+// count seeds issued to u for the request sr. As with downloads, nothing is
+// recorded when ANALYTICS_BUCKET is not set, and failing to store the record
+// does not fail the seed request.
+func recordSeeds(ctx context.Context, sr models.SeedRequest, u *user.User, alg models.HashAlgorithm, count int) {
+	bucket := os.Getenv("ANALYTICS_BUCKET")
+	if bucket == "" {
+		return
+	}
+	rec := models.SeedRecord{
+		Time:      time.Now().UTC(),
+		User:      u.String(),
+		Hash:      sr.Hash,
+		Algorithm: alg,
+		Hostname:  sr.Hostname,
+		Version:   sr.Version,
+		Batch:     sr.Batch,
+		Count:     count,
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(%#v): %v", rec, err)
+		return
+	}
+	records.add(seedRecordPrefix+rec.Time.Format("2006/01/02/"), b)
+	logInfof(ctx, "recorded %d seeds issued to %s", count, rec.User)
+}
+
+// recordBuffer collects the records made by an instance, so that they are
+// stored in ANALYTICS_BUCKET as one object per prefix and day rather than with
+// a write during every request. Stored objects hold one JSON encoded record
+// per line.
+type recordBuffer struct {
+	mu      sync.Mutex
+	pending map[string][][]byte // Encoded records by prefix, such as downloads/2006/01/02/.
+	count   int
+	timer   *time.Timer
+}
+
+// add buffers the encoded record rec to be stored with prefix. The buffer is
+// flushed in the background once it is full or recordFlushInterval has passed.
+func (rb *recordBuffer) add(prefix string, rec []byte) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.pending == nil {
+		rb.pending = make(map[string][][]byte)
+	}
+	rb.pending[prefix] = append(rb.pending[prefix], rec)
+	rb.count++
+	switch {
+	case rb.count >= maxBufferedRecords:
+		go rb.flush()
+	case rb.timer == nil:
+		rb.timer = time.AfterFunc(recordFlushInterval, rb.flush)
+	}
+}
+
+// flush stores the buffered records, writing one object for each prefix.
+// Records that cannot be stored are logged and dropped, as they are not
+// associated with a request that could be failed.
+func (rb *recordBuffer) flush() {
+	rb.mu.Lock()
+	pending := rb.pending
+	rb.pending, rb.count = nil, 0
+	if rb.timer != nil {
+		rb.timer.Stop()
+		rb.timer = nil
+	}
+	rb.mu.Unlock()
+
+	bucket := os.Getenv("ANALYTICS_BUCKET")
+	if bucket == "" || len(pending) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recordWriteTimeout)
+	defer cancel()
+	for prefix, recs := range pending {
+		name := fmt.Sprintf("%s%d.json", prefix, time.Now().UnixNano())
+		if err := writeObject(ctx, bucket, name, bytes.Join(recs, []byte("\n"))); err != nil {
+			golog.Printf("writeObject(%s, %s) failed, dropping %d records: %v", bucket, name, len(recs), err)
+		}
+	}
+}
+
+// urlID returns the hex encoded SHA-256 digest of the signature in a signed
+// URL, or an empty string if it has none.
+func urlID(signed string) string {
+	u, err := url.Parse(signed)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	sig := q.Get("X-Goog-Signature")
+	if sig == "" {
+		sig = q.Get("Signature")
+	}
+	if sig == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(sig))
+	return hex.EncodeToString(h[:])
+}
+
+// DownloadSummaryHandler implements http.Handler for download summaries. It
+// aggregates the download records stored by the sign endpoint so that release
+// managers can follow the adoption of new installer builds. Only application
+// administrators may obtain a summary.
+type DownloadSummaryHandler struct{}
+
+func (DownloadSummaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withDeadline(r)
+	defer cancel()
+	ctx := appengine.NewContext(r)
+	w.Header().Set("Content-Type", "application/json")
+
+	resp := downloadSummary(ctx, r)
+	if resp.ErrorCode != models.StatusSuccess {
+		logWarningf(ctx, "could not summarize downloads: %s", resp.Status)
+		w.WriteHeader(httpStatus(resp.ErrorCode))
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(DownloadSummary): %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(b); err != nil {
+		logErrorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// downloadSummary authorizes a summary request and summarizes the downloads
+// made during the number of days in its days query parameter.
+func downloadSummary(ctx context.Context, r *http.Request) models.DownloadSummary {
+	if code, err := authorizeAdmin(ctx); err != nil {
+		return models.DownloadSummary{Status: err.Error(), ErrorCode: code}
+	}
+	bucket := os.Getenv("ANALYTICS_BUCKET")
+	if bucket == "" {
+		return models.DownloadSummary{Status: "ANALYTICS_BUCKET environment variable not set", ErrorCode: models.StatusConfigError}
+	}
+	days, err := summaryDays(r.URL.Query().Get("days"))
+	if err != nil {
+		return models.DownloadSummary{Status: err.Error(), ErrorCode: models.StatusReqUnreadable}
+	}
+	until := time.Now().UTC()
+	resp, err := summarizeDownloads(ctx, bucket, until.AddDate(0, 0, -days), until)
+	if err != nil {
+		return models.DownloadSummary{Status: err.Error(), ErrorCode: models.StatusConfigError}
+	}
+	resp.Status = "Success"
+	resp.ErrorCode = models.StatusSuccess
+	return resp
+}
+
+// authorizeAdmin returns an error and the code to reject the request with
+// unless it was made by an administrator of the application.
+func authorizeAdmin(ctx context.Context) (models.StatusCode, error) {
+	u := currentUser(ctx)
+	if u == nil {
+		return models.StatusInvalidUser, errors.New("no user")
+	}
+	if !u.Admin {
+		return models.StatusNotAdmin, fmt.Errorf("%w: %s", errNotAdmin, u)
+	}
+	return models.StatusSuccess, nil
+}
+
+// summaryDays parses the number of days to summarize, which defaults to
+// defaultSummaryDays when d is empty.
+func summaryDays(d string) (int, error) {
+	if d == "" {
+		return defaultSummaryDays, nil
+	}
+	days, err := strconv.Atoi(d)
+	if err != nil || days < 1 || days > maxSummaryDays {
+		return 0, fmt.Errorf("%w: %q, must be between 1 and %d", errSummaryDays, d, maxSummaryDays)
+	}
+	return days, nil
+}
+
+// summarizeDownloads aggregates the download records made between since and
+// until by image. When ACCESS_LOG_PREFIX is set, the bucket access logs
+// stored with that prefix are read to determine how much of each image was
+// served with the signed URLs.
+func summarizeDownloads(ctx context.Context, bucket string, since, until time.Time) (models.DownloadSummary, error) {
+	sum := models.DownloadSummary{Since: since, Until: until}
+	records, truncated, err := readDownloadRecords(ctx, bucket, since, until)
+	if err != nil {
+		return sum, err
+	}
+	if truncated {
+		return sum, fmt.Errorf("%w: more than %d objects of records since %v, summarize fewer days", errTooManyRecs, maxRecordObjects, since)
+	}
+	var served map[string]int64
+	if prefix := os.Getenv("ACCESS_LOG_PREFIX"); prefix != "" {
+		if served, err = readAccessLogs(ctx, bucket, prefix, since, until); err != nil {
+			return sum, err
+		}
+		sum.Correlated = true
+	}
+
+	type image struct {
+		path string
+		alg  models.HashAlgorithm
+		hash string
+	}
+	images := make(map[image]*models.ImageDownloads)
+	users := make(map[image]map[string]bool)
+	for _, rec := range records {
+		k := image{rec.Path, rec.Algorithm, string(rec.Hash)}
+		img, ok := images[k]
+		if !ok {
+			img = &models.ImageDownloads{Path: rec.Path, Hash: rec.Hash, Algorithm: rec.Algorithm}
+			images[k] = img
+			users[k] = make(map[string]bool)
+		}
+		img.Signed++
+		users[k][strings.ToLower(rec.User)] = true
+		if rec.URLID == "" {
+			continue
+		}
+		img.BytesServed += served[rec.URLID]
+		if rec.Size > 0 && served[rec.URLID] >= rec.Size {
+			img.Completed++
+		}
+	}
+	for k, img := range images {
+		img.Users = len(users[k])
+		sum.Images = append(sum.Images, *img)
+	}
+	sort.Slice(sum.Images, func(i, j int) bool {
+		a, b := sum.Images[i], sum.Images[j]
+		if a.Signed != b.Signed {
+			return a.Signed > b.Signed
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return hex.EncodeToString(a.Hash) < hex.EncodeToString(b.Hash)
+	})
+	return sum, nil
+}
+
+// readDownloadRecords reads the download records made between since and
+// until from bucket. It reports whether older records were left out.
+func readDownloadRecords(ctx context.Context, bucket string, since, until time.Time) ([]models.DownloadRecord, bool, error) {
+	var records []models.DownloadRecord
+	truncated, err := readRecords(ctx, bucket, recordPrefix, since, until, func(name string, b []byte) {
+		var rec models.DownloadRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			logWarningf(ctx, "skipping unreadable download record %s: %v", name, err)
+			return
+		}
+		if rec.Time.Before(since) || rec.Time.After(until) {
+			return
+		}
+		records = append(records, rec)
+	})
+	return records, truncated, err
+}
+
+// readSeedRecords reads the seed records made between since and until from
+// bucket. It reports whether older records were left out.
+func readSeedRecords(ctx context.Context, bucket string, since, until time.Time) ([]models.SeedRecord, bool, error) {
+	var records []models.SeedRecord
+	truncated, err := readRecords(ctx, bucket, seedRecordPrefix, since, until, func(name string, b []byte) {
+		var rec models.SeedRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			logWarningf(ctx, "skipping unreadable seed record %s: %v", name, err)
+			return
+		}
+		if rec.Time.Before(since) || rec.Time.After(until) {
+			return
+		}
+		records = append(records, rec)
+	})
+	return records, truncated, err
+}
+
+// readRecords calls add with the name of each object stored with prefix in
+// bucket for the days between since and until, and each record it holds. Days
+// are read from the most recent, and reading stops once maxRecordObjects
+// objects were read, which is reported as truncated.
+func readRecords(ctx context.Context, bucket, prefix string, since, until time.Time, add func(name string, b []byte)) (truncated bool, err error) {
+	days := summaryDates(since, until)
+	read := 0
+	for d := len(days) - 1; d >= 0; d-- {
+		p := prefix + days[d].Format("2006/01/02/")
+		names, err := listObjects(ctx, bucket, p)
+		if err != nil {
+			return false, fmt.Errorf("listObjects(%s, %s): %v", bucket, p, err)
+		}
+		if read+len(names) > maxRecordObjects {
+			return true, nil
+		}
+		read += len(names)
+		for _, name := range names {
+			b, err := readObject(ctx, bucket, name)
+			if err != nil {
+				return false, err
+			}
+			for _, rec := range bytes.Split(b, []byte("\n")) {
+				if len(bytes.TrimSpace(rec)) > 0 {
+					add(name, rec)
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// readAccessLogs reads the Cloud Storage usage logs stored with prefix for
+// the days between since and until, and returns the number of bytes that
+// were served for each signed URL, keyed by urlID.
+// https://cloud.google.com/storage/docs/access-logs
+func readAccessLogs(ctx context.Context, bucket, prefix string, since, until time.Time) (map[string]int64, error) {
+	served := make(map[string]int64)
+	for _, day := range summaryDates(since, until) {
+		p := prefix + "_usage_" + day.Format("2006_01_02_")
+		names, err := listObjects(ctx, bucket, p)
+		if err != nil {
+			return nil, fmt.Errorf("listObjects(%s, %s): %v", bucket, p, err)
+		}
+		for _, name := range names {
+			b, err := readObject(ctx, bucket, name)
+			if err != nil {
+				return nil, err
+			}
+			if err := parseAccessLog(b, served); err != nil {
+				logWarningf(ctx, "skipping unreadable access log %s: %v", name, err)
+			}
+		}
+	}
+	return served, nil
+}
+
+// parseAccessLog adds the bytes served by successful downloads in a usage log
+// to served. Columns are located using the header of the log, as their order
+// is not guaranteed.
+func parseAccessLog(b []byte, served map[string]int64) error {
+	rows, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[name] = i
+	}
+	for _, name := range []string{"cs_method", "cs_uri", "sc_status", "sc_bytes"} {
+		if _, ok := col[name]; !ok {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+	for _, row := range rows[1:] {
+		if len(row) != len(rows[0]) || row[col["cs_method"]] != http.MethodGet {
+			continue
+		}
+		if s := row[col["sc_status"]]; s != strconv.Itoa(http.StatusOK) && s != strconv.Itoa(http.StatusPartialContent) {
+			continue
+		}
+		id := urlID(row[col["cs_uri"]])
+		if id == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(row[col["sc_bytes"]], 10, 64)
+		if err != nil {
+			continue
+		}
+		served[id] += n
+	}
+	return nil
+}
+
+// summaryDates returns midnight UTC of each day between since and until.
+func summaryDates(since, until time.Time) []time.Time {
+	var days []time.Time
+	since = since.UTC()
+	for d := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC); !d.After(until); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// readObject returns the contents of the object f in bucket b.
+func readObject(ctx context.Context, b, f string) ([]byte, error) {
+	r, err := bucketFileFinder(ctx, b, f)
+	if err != nil {
+		return nil, fmt.Errorf("bucketFileFinder(%s, %s): %v", b, f, err)
+	}
+	return ioutil.ReadAll(r)
+}
+
+func bucketObjects(ctx context.Context, b string, prefix string) ([]string, error) {
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	it := client.Bucket(b).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+}
+
+func bucketWriteObject(ctx context.Context, b string, f string, data []byte) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(b).Object(f).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,321 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/appengine/user"
+)
+
+// fakeBucket replaces the bucket helpers with an in-memory bucket, returning
+// a func that restores them.
+func fakeBucket(objects map[string][]byte) func() {
+	writeObject = func(_ context.Context, _, f string, data []byte) error {
+		objects[f] = data
+		return nil
+	}
+	listObjects = func(_ context.Context, _, prefix string) ([]string, error) {
+		var names []string
+		for n := range objects {
+			if strings.HasPrefix(n, prefix) {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	bucketFileFinder = func(_ context.Context, _, f string) (io.Reader, error) {
+		b, ok := objects[f]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return bytes.NewReader(b), nil
+	}
+	return func() {
+		writeObject = bucketWriteObject
+		listObjects = bucketObjects
+		bucketFileFinder = bucketFileHandle
+	}
+}
+
+func TestRecordBuffer(t *testing.T) {
+	objects := make(map[string][]byte)
+	defer fakeBucket(objects)()
+	cleanup, err := prepEnvVariables(map[string]string{"ANALYTICS_BUCKET": "analytics"})
+	if err != nil {
+		t.Fatalf("prepEnvVariables() err: %v", err)
+	}
+	defer cleanup()
+
+	rb := &recordBuffer{}
+	rb.add("downloads/2026/01/02/", []byte(`{"n":1}`))
+	rb.add("downloads/2026/01/02/", []byte(`{"n":2}`))
+	rb.add("seeds/2026/01/02/", []byte(`{"n":3}`))
+	if len(objects) != 0 {
+		t.Fatalf("add() stored %d objects before flush(), want 0", len(objects))
+	}
+	rb.flush()
+	got := make(map[string]string)
+	for name, b := range objects {
+		got[name[:strings.LastIndex(name, "/")+1]] = string(b)
+	}
+	want := map[string]string{
+		"downloads/2026/01/02/": "{\"n\":1}\n{\"n\":2}",
+		"seeds/2026/01/02/":     "{\"n\":3}",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("flush() returned unexpected diff (-want +got):\n%s", diff)
+	}
+	if rb.timer != nil || rb.count != 0 {
+		t.Errorf("flush() left %d records buffered", rb.count)
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	objects := make(map[string][]byte)
+	defer fakeBucket(objects)()
+	now := time.Now().UTC()
+	yesterday := now.AddDate(0, 0, -1)
+	objects[seedRecordPrefix+yesterday.Format("2006/01/02/")+"1.json"] = []byte("{}")
+	for i := 0; i < maxRecordObjects; i++ {
+		objects[fmt.Sprintf("%s%s%d.json", seedRecordPrefix, now.Format("2006/01/02/"), i)] = []byte("{}\n{}\n")
+	}
+
+	tests := []struct {
+		desc          string
+		since         time.Time
+		wantRecords   int
+		wantTruncated bool
+	}{
+		{"within limit", now, 2 * maxRecordObjects, false},
+		{"older days left out", yesterday, 2 * maxRecordObjects, true},
+	}
+	for _, tt := range tests {
+		n := 0
+		truncated, err := readRecords(context.Background(), "analytics", seedRecordPrefix, tt.since, now, func(string, []byte) { n++ })
+		if err != nil {
+			t.Errorf("%s: readRecords() err: %v", tt.desc, err)
+		}
+		if n != tt.wantRecords || truncated != tt.wantTruncated {
+			t.Errorf("%s: readRecords() read %d records (truncated: %t), want %d (truncated: %t)", tt.desc, n, truncated, tt.wantRecords, tt.wantTruncated)
+		}
+	}
+}
+
+func TestURLID(t *testing.T) {
+	tests := []struct {
+		desc   string
+		signed string
+		want   bool
+	}{
+		{desc: "v4", signed: "https://storage.googleapis.com/b/o?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Signature=abc", want: true},
+		{desc: "v2", signed: "https://storage.googleapis.com/b/o?GoogleAccessId=sa&Expires=1&Signature=abc", want: true},
+		{desc: "access log uri", signed: "/b/o?X-Goog-Signature=abc", want: true},
+		{desc: "unsigned", signed: "http://localhost:4443/b/o"},
+		{desc: "unparsable", signed: "%zz"},
+	}
+	for _, tt := range tests {
+		if got := urlID(tt.signed); (got != "") != tt.want {
+			t.Errorf("%s: urlID(%q) got: %q, want id: %t", tt.desc, tt.signed, got, tt.want)
+		}
+	}
+	if urlID("https://a/b/o?X-Goog-Signature=abc") != urlID("/b/o?X-Goog-Signature=abc") {
+		t.Errorf("urlID() differs between a signed URL and its access log entry")
+	}
+}
+
+func TestParseAccessLog(t *testing.T) {
+	id := urlID("/b/o?X-Goog-Signature=abc")
+	tests := []struct {
+		desc    string
+		log     string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{
+			desc: "downloads",
+			log: "\"sc_bytes\",\"cs_method\",\"cs_uri\",\"sc_status\"\n" +
+				"\"100\",\"GET\",\"/b/o?X-Goog-Signature=abc\",\"206\"\n" +
+				"\"50\",\"GET\",\"/b/o?X-Goog-Signature=abc\",\"206\"\n" +
+				"\"0\",\"HEAD\",\"/b/o?X-Goog-Signature=abc\",\"200\"\n" +
+				"\"10\",\"GET\",\"/b/o?X-Goog-Signature=abc\",\"403\"\n" +
+				"\"10\",\"GET\",\"/b/o\",\"200\"\n",
+			want: map[string]int64{id: 150},
+		},
+		{
+			desc: "empty",
+			want: map[string]int64{},
+		},
+		{
+			desc:    "missing column",
+			log:     "\"cs_method\",\"cs_uri\",\"sc_status\"\n",
+			want:    map[string]int64{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got := make(map[string]int64)
+		if err := parseAccessLog([]byte(tt.log), got); (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseAccessLog() err got: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: parseAccessLog() returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestSummarizeDownloads(t *testing.T) {
+	defer discardLogs()()
+	objects := make(map[string][]byte)
+	defer fakeBucket(objects)()
+	cleanup, err := prepEnvVariables(map[string]string{"ANALYTICS_BUCKET": "analytics"})
+	if err != nil {
+		t.Fatalf("prepEnvVariables() err: %v", err)
+	}
+	defer cleanup()
+
+	sign := func(user, path, sig string, hash byte) {
+		req := models.SignRequest{Seed: models.Seed{Username: user}, Path: path, Hash: []byte{hash}}
+		resp := models.SignResponse{SignedURL: "https://storage.googleapis.com/b/" + path + "?X-Goog-Signature=" + sig, Size: 100}
+		recordDownload(context.Background(), req, resp)
+	}
+	sign("a@example.com", "boot.wim", "1", 1)
+	sign("A@example.com", "boot.wim", "2", 1)
+	sign("b@example.com", "boot.wim", "3", 1)
+	sign("b@example.com", "boot.wim", "4", 2)
+	sign("b@example.com", "install.wim", "5", 2)
+	records.flush()
+	now := time.Now().UTC()
+	objects["logs_usage_"+now.Format("2006_01_02_15")+"_00_00_1_v0"] = []byte(
+		"\"cs_method\",\"cs_uri\",\"sc_status\",\"sc_bytes\"\n" +
+			"\"GET\",\"/b/boot.wim?X-Goog-Signature=1\",\"200\",\"100\"\n" +
+			"\"GET\",\"/b/boot.wim?X-Goog-Signature=2\",\"206\",\"40\"\n" +
+			"\"GET\",\"/b/boot.wim?X-Goog-Signature=4\",\"200\",\"100\"\n")
+
+	tests := []struct {
+		desc      string
+		logPrefix string
+		want      []models.ImageDownloads
+	}{
+		{
+			desc: "records only",
+			want: []models.ImageDownloads{
+				{Path: "boot.wim", Hash: []byte{1}, Algorithm: models.HashSHA256, Signed: 3, Users: 2},
+				{Path: "boot.wim", Hash: []byte{2}, Algorithm: models.HashSHA256, Signed: 1, Users: 1},
+				{Path: "install.wim", Hash: []byte{2}, Algorithm: models.HashSHA256, Signed: 1, Users: 1},
+			},
+		},
+		{
+			desc:      "correlated with access logs",
+			logPrefix: "logs",
+			want: []models.ImageDownloads{
+				{Path: "boot.wim", Hash: []byte{1}, Algorithm: models.HashSHA256, Signed: 3, Users: 2, Completed: 1, BytesServed: 140},
+				{Path: "boot.wim", Hash: []byte{2}, Algorithm: models.HashSHA256, Signed: 1, Users: 1, Completed: 1, BytesServed: 100},
+				{Path: "install.wim", Hash: []byte{2}, Algorithm: models.HashSHA256, Signed: 1, Users: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(map[string]string{"ACCESS_LOG_PREFIX": tt.logPrefix})
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		got, err := summarizeDownloads(context.Background(), "analytics", now.Add(-time.Hour), time.Now().UTC())
+		if err != nil {
+			t.Errorf("%s: summarizeDownloads() err: %v", tt.desc, err)
+		}
+		if got.Correlated != (tt.logPrefix != "") {
+			t.Errorf("%s: summarizeDownloads() Correlated got: %t, want: %t", tt.desc, got.Correlated, tt.logPrefix != "")
+		}
+		if diff := cmp.Diff(tt.want, got.Images); diff != "" {
+			t.Errorf("%s: summarizeDownloads() returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestDownloadSummary(t *testing.T) {
+	defer resetServices()
+	defer fakeBucket(make(map[string][]byte))()
+	admin := func(context.Context) *user.User {
+		return &user.User{Email: "admin@example.com", Admin: true}
+	}
+	tests := []struct {
+		desc    string
+		user    func(context.Context) *user.User
+		envVars map[string]string
+		query   string
+		want    models.StatusCode
+	}{
+		{
+			desc: "no user",
+			user: func(context.Context) *user.User { return nil },
+			want: models.StatusInvalidUser,
+		},
+		{
+			desc:    "not an administrator",
+			user:    devUser("user@example.com"),
+			envVars: map[string]string{"ANALYTICS_BUCKET": "analytics"},
+			want:    models.StatusNotAdmin,
+		},
+		{
+			desc: "no bucket",
+			user: admin,
+			want: models.StatusConfigError,
+		},
+		{
+			desc:    "invalid days",
+			user:    admin,
+			envVars: map[string]string{"ANALYTICS_BUCKET": "analytics"},
+			query:   "?days=1000",
+			want:    models.StatusReqUnreadable,
+		},
+		{
+			desc:    "success",
+			user:    admin,
+			envVars: map[string]string{"ANALYTICS_BUCKET": "analytics"},
+			query:   "?days=30",
+			want:    models.StatusSuccess,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() err: %v", tt.desc, err)
+		}
+		currentUser = tt.user
+		r := httptest.NewRequest("GET", "/admin/summary"+tt.query, nil)
+		if got := downloadSummary(context.Background(), r); got.ErrorCode != tt.want {
+			t.Errorf("%s: downloadSummary() got: %v, want code: %d", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("%s: cleanup() err: %v", tt.desc, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+// AllowlistHealthHandler implements http.Handler for allowlist health checks.
+// Unhealthy allowlists receive a 503 response and are logged as errors, so
+// that either an uptime check or a log-based alert can be used to notice an
+// allowlist that was not updated alongside a new release. Only administrators
+// and cron are told where the allowlist is stored and what its problems are.
+type AllowlistHealthHandler struct{}
+
+func (AllowlistHealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	w.Header().Set("Content-Type", "application/json")
+
+	h := allowlistHealth(ctx)
+	status := http.StatusOK
+	if h.Healthy {
+		log.Infof(ctx, "allowlist %s is healthy with %d entries, last updated %v", h.Path, h.Entries, h.Updated)
+	} else {
+		// Scheduled checks are made by cron, which only records the response
+		// status, so problems are always logged.
+		log.Errorf(ctx, "allowlist %s is unhealthy (cron: %t): %s", h.Path, r.Header.Get("X-Appengine-Cron") == "true", strings.Join(h.Problems, "; "))
+		status = http.StatusServiceUnavailable
+	}
+	if !healthDetails(ctx, r) {
+		h = redactHealth(h)
+	}
+
+	jsonResponse, err := json.Marshal(h)
+	if err != nil {
+		log.Errorf(ctx, "json.Marshal(%#v): %v", h, err)
+		http.Error(w, fmt.Sprintf(`{"Healthy":false,"Problems":["%v"]}`, err), http.StatusInternalServerError)
+		return
+	}
+	if err := writeCacheable(w, r, status, jsonResponse); err != nil {
+		log.Errorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// healthDetails reports whether r may be told where the allowlist is stored
+// and what is wrong with it, which is limited to administrators and cron. App
+// Engine removes the X-Appengine-Cron header from external requests.
+func healthDetails(ctx context.Context, r *http.Request) bool {
+	if r.Header.Get("X-Appengine-Cron") == "true" {
+		return true
+	}
+	_, err := authorizeAdmin(ctx)
+	return err == nil
+}
+
+// redactHealth removes the location of the allowlist and the descriptions of
+// its problems from h, which name the bucket and its objects. The problems
+// remain in the logs.
+func redactHealth(h models.AllowlistHealth) models.AllowlistHealth {
+	h.Path = ""
+	if n := len(h.Problems); n > 0 {
+		h.Problems = []string{fmt.Sprintf("%d problems were found, which are described in the application logs", n)}
+	}
+	return h
+}
+
+// allowlistHealth reports when the allowlist was last modified and whether it
+// parses cleanly. The allowlist is read from the bucket rather than the cache
+// so that the report reflects its current contents. It is also reported as
+// unhealthy when it is older than ALLOWLIST_MAX_AGE, or older than any of the
+// objects listed in RELEASE_OBJECTS, which indicates that a release was
+// published without its hash being added to the allowlist.
+func allowlistHealth(ctx context.Context) models.AllowlistHealth {
+	h := models.AllowlistHealth{}
+	problem := func(format string, a ...interface{}) models.AllowlistHealth {
+		h.Problems = append(h.Problems, fmt.Sprintf(format, a...))
+		return h
+	}
+	b := os.Getenv("BUCKET")
+	if b == "" {
+		return problem("BUCKET environment variable not set")
+	}
+	p, err := allowlistPath(ctx)
+	if err != nil {
+		return problem("allowlistPath: %v", err)
+	}
+	h.Path = p
+
+	attrs, err := objectAttrs(ctx, b, p)
+	if err != nil {
+		return problem("objectAttrs(%s, %s): %v", b, p, err)
+	}
+	h.Updated = attrs.Updated
+
+	ah, err := getAllowlist(ctx, b, p)
+	if err != nil {
+		return problem("getAllowlist: %v", err)
+	}
+	h.Entries = len(ah)
+	if h.Entries == 0 {
+		problem("allowlist has no entries")
+	}
+	for e := range ah {
+		if err := validAllowlistEntry(e); err != nil {
+			problem("invalid entry %q: %v", e, err)
+		}
+	}
+
+	if v := os.Getenv("ALLOWLIST_MAX_AGE"); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil {
+			problem("ALLOWLIST_MAX_AGE is %q, which is not a valid duration", v)
+		} else if age := time.Since(h.Updated); age > maxAge {
+			problem("allowlist was last updated %v ago, more than %v", age.Round(time.Minute), maxAge)
+		}
+	}
+
+	for _, r := range strings.Split(os.Getenv("RELEASE_OBJECTS"), ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		ra, err := objectAttrs(ctx, b, r)
+		if err != nil {
+			problem("objectAttrs(%s, %s): %v", b, r, err)
+			continue
+		}
+		if ra.Updated.After(h.Updated) {
+			problem("release %s was updated at %v, after the allowlist", r, ra.Updated)
+		}
+	}
+
+	h.Healthy = len(h.Problems) == 0
+	return h
+}
+
+// validAllowlistEntry returns an error if e, an entry returned by getAllowlist,
+// is not the hex encoding of a hash produced by a supported algorithm.
+func validAllowlistEntry(e string) error {
+	alg, h := models.HashSHA256, e
+	if i := strings.Index(e, ":"); i >= 0 {
+		alg, h = models.HashAlgorithm(e[:i]), e[i+1:]
+	}
+	size, ok := supportedHash[alg]
+	if !ok {
+		return fmt.Errorf("unsupported hash algorithm %q", alg)
+	}
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString: %v", err)
+	}
+	if len(b) != size {
+		return fmt.Errorf("%s hash has size %d, want %d", alg, len(b), size)
+	}
+	return nil
+}
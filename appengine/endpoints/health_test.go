@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine/user"
+)
+
+func TestAllowlistHealth(t *testing.T) {
+	defer discardLogs()()
+	released := time.Now().Add(-time.Hour)
+	updated := released.Add(-time.Hour)
+	contents := "- '314aaa98adcbd86339fb4eece6050b8ae2d38ff8ebb416e231bb7724c99b830d'\n"
+	objectAttrs = func(_ context.Context, _ string, path string) (*storage.ObjectAttrs, error) {
+		switch path {
+		case "release/boot.wim":
+			return &storage.ObjectAttrs{Updated: released}, nil
+		case "release/missing.wim":
+			return nil, storage.ErrObjectNotExist
+		}
+		return &storage.ObjectAttrs{Updated: updated}, nil
+	}
+	defer func() {
+		objectAttrs = bucketObjectAttrs
+		bucketFileFinder = bucketFileHandle
+	}()
+
+	tests := []struct {
+		desc     string
+		envVars  map[string]string
+		contents string
+		readErr  error
+		entries  int
+		problems int
+	}{
+		{
+			desc:     "no bucket",
+			problems: 1,
+		},
+		{
+			desc:     "healthy",
+			envVars:  map[string]string{"BUCKET": "bucket"},
+			contents: contents,
+			entries:  1,
+		},
+		{
+			desc:     "unreadable",
+			envVars:  map[string]string{"BUCKET": "bucket"},
+			readErr:  errors.New("error"),
+			problems: 1,
+		},
+		{
+			desc:     "does not parse",
+			envVars:  map[string]string{"BUCKET": "bucket"},
+			contents: "not: [a list",
+			problems: 1,
+		},
+		{
+			desc:     "empty",
+			envVars:  map[string]string{"BUCKET": "bucket"},
+			contents: "[]",
+			problems: 1,
+		},
+		{
+			desc:     "invalid entries",
+			envVars:  map[string]string{"BUCKET": "bucket"},
+			contents: contents + "- 'beef'\n- 'md5:beef'\n",
+			entries:  3,
+			problems: 2,
+		},
+		{
+			desc:     "within max age",
+			envVars:  map[string]string{"BUCKET": "bucket", "ALLOWLIST_MAX_AGE": "720h"},
+			contents: contents,
+			entries:  1,
+		},
+		{
+			desc:     "older than max age",
+			envVars:  map[string]string{"BUCKET": "bucket", "ALLOWLIST_MAX_AGE": "1h"},
+			contents: contents,
+			entries:  1,
+			problems: 1,
+		},
+		{
+			desc:     "invalid max age",
+			envVars:  map[string]string{"BUCKET": "bucket", "ALLOWLIST_MAX_AGE": "a week"},
+			contents: contents,
+			entries:  1,
+			problems: 1,
+		},
+		{
+			desc:     "release after allowlist",
+			envVars:  map[string]string{"BUCKET": "bucket", "RELEASE_OBJECTS": "release/boot.wim, release/missing.wim"},
+			contents: contents,
+			entries:  1,
+			problems: 2,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		bucketFileFinder = func(context.Context, string, string) (io.Reader, error) {
+			return strings.NewReader(tt.contents), tt.readErr
+		}
+		got := allowlistHealth(context.Background())
+		if got.Healthy != (tt.problems == 0) {
+			t.Errorf("%s: allowlistHealth() healthy: %t, problems: %q", tt.desc, got.Healthy, got.Problems)
+		}
+		if len(got.Problems) != tt.problems {
+			t.Errorf("%s: allowlistHealth() got %d problems: %q, want: %d", tt.desc, len(got.Problems), got.Problems, tt.problems)
+		}
+		if got.Entries != tt.entries {
+			t.Errorf("%s: allowlistHealth() entries: %d, want: %d", tt.desc, got.Entries, tt.entries)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestHealthDetails(t *testing.T) {
+	defer resetServices()
+	tests := []struct {
+		desc string
+		cron bool
+		user func(context.Context) *user.User
+		want bool
+	}{
+		{
+			desc: "no user",
+			user: func(context.Context) *user.User { return nil },
+		},
+		{
+			desc: "not an administrator",
+			user: devUser("user@example.com"),
+		},
+		{
+			desc: "administrator",
+			user: adminUser,
+			want: true,
+		},
+		{
+			desc: "cron",
+			cron: true,
+			user: func(context.Context) *user.User { return nil },
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/health/allowlist", nil)
+		if tt.cron {
+			r.Header.Set("X-Appengine-Cron", "true")
+		}
+		currentUser = tt.user
+		if got := healthDetails(context.Background(), r); got != tt.want {
+			t.Errorf("%s: healthDetails() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHealth(t *testing.T) {
+	h := models.AllowlistHealth{
+		Path:     "allowlist.yaml",
+		Entries:  1,
+		Problems: []string{"objectAttrs(bucket, release/boot.wim): error", "allowlist has no entries"},
+	}
+	got := redactHealth(h)
+	if got.Path != "" || got.Entries != 1 || len(got.Problems) != 1 || strings.Contains(got.Problems[0], "bucket") {
+		t.Errorf("redactHealth(%+v) got: %+v, want no path and a summary of 2 problems", h, got)
+	}
+}
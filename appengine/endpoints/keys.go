@@ -0,0 +1,49 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+// KeysHandler implements http.Handler for public key discovery. It serves the
+// certificates that seeds are currently signed with, so that seed files can be
+// verified offline, such as when auditing media found in the field.
+type KeysHandler struct{}
+
+func (KeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	certs, err := publicCertificates(ctx)
+	if err != nil {
+		logErrorf(ctx, "appengine.PublicCertificates(): %v", err)
+		http.Error(w, fmt.Sprintf("unable to obtain public certificates: %v", err), http.StatusInternalServerError)
+		return
+	}
+	b, err := json.Marshal(models.KeysResponse{AppID: appID(ctx), Certs: certs})
+	if err != nil {
+		logErrorf(ctx, "json.Marshal(KeysResponse): %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeCacheable(w, r, http.StatusOK, b); err != nil {
+		logErrorf(ctx, "failed to write response to client: %v", err)
+	}
+}
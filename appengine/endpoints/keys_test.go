@@ -0,0 +1,76 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+func TestKeysHandler(t *testing.T) {
+	defer discardLogs()()
+	defer resetServices()
+	defer func() { appID = appengine.AppID }()
+	appID = func(context.Context) string { return "fresnel-test" }
+	certs := []appengine.Certificate{{KeyName: "key1", Data: []byte("cert1")}, {KeyName: "key2", Data: []byte("cert2")}}
+	tests := []struct {
+		desc       string
+		certs      []appengine.Certificate
+		certErr    error
+		wantStatus int
+	}{
+		{
+			desc:       "success",
+			certs:      certs,
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "certificate error",
+			certErr:    errors.New("test"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		publicCertificates = func(context.Context) ([]appengine.Certificate, error) {
+			return tt.certs, tt.certErr
+		}
+		w := httptest.NewRecorder()
+		KeysHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: ServeHTTP() status got: %d, want: %d", tt.desc, w.Code, tt.wantStatus)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var got models.KeysResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: json.Unmarshal() err: %v", tt.desc, err)
+			continue
+		}
+		if got.AppID != "fresnel-test" || len(got.Certs) != len(tt.certs) {
+			t.Errorf("%s: ServeHTTP() got: %+v, want %d certs for %q", tt.desc, got, len(tt.certs), "fresnel-test")
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("%s: ServeHTTP() did not set an ETag", tt.desc)
+		}
+	}
+}
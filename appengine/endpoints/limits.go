@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/fresnel/models"
+)
+
+const (
+	// defaultMaxRequestBytes is used when MAX_REQUEST_BYTES is not set. Seeds
+	// carry up to four public certificates, so sign requests are a few KB.
+	defaultMaxRequestBytes = int64(64 * 1024)
+	// defaultRequestTimeout is used when REQUEST_TIMEOUT is not set.
+	defaultRequestTimeout = 30 * time.Second
+)
+
+var (
+	// Wrapped errors for request limits.
+	errBodyTooLarge = errors.New("request body too large")
+	errTimeout      = errors.New("request timed out")
+)
+
+// maxRequestBytes returns the maximum permitted request body size in bytes
+// from the MAX_REQUEST_BYTES environment variable, or a default if it is
+// unset or invalid.
+func maxRequestBytes() int64 {
+	m := os.Getenv("MAX_REQUEST_BYTES")
+	if m == "" {
+		return defaultMaxRequestBytes
+	}
+	n, err := strconv.ParseInt(m, 10, 64)
+	if err != nil || n < 1 {
+		return defaultMaxRequestBytes
+	}
+	return n
+}
+
+// requestTimeout returns the deadline applied to each request from the
+// REQUEST_TIMEOUT environment variable, or a default if it is unset or
+// invalid.
+func requestTimeout() time.Duration {
+	t := os.Getenv("REQUEST_TIMEOUT")
+	if t == "" {
+		return defaultRequestTimeout
+	}
+	d, err := time.ParseDuration(t)
+	if err != nil || d <= 0 {
+		return defaultRequestTimeout
+	}
+	return d
+}
+
+// withDeadline returns a copy of the request whose context expires after the
+// configured request timeout, along with the function that releases it.
+func withDeadline(r *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout())
+	return r.WithContext(ctx), cancel
+}
+
+// readBody reads the request body, enforcing the configured size limit and
+// the deadline present in the request context. Slow or oversized bodies
+// return errTimeout or errBodyTooLarge respectively.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	limit := maxRequestBytes()
+
+	type result struct {
+		body []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// Read one byte past the limit so that oversized bodies are detected.
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		done <- result{body: b, err: err}
+	}()
+
+	select {
+	case <-r.Context().Done():
+		return nil, fmt.Errorf("%w: %v", errTimeout, r.Context().Err())
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+		if int64(len(res.body)) > limit {
+			return nil, fmt.Errorf("%w: exceeds %d bytes", errBodyTooLarge, limit)
+		}
+		return res.body, nil
+	}
+}
+
+// httpStatus maps a models.StatusCode to the HTTP status code that should be
+// returned to the client.
+func httpStatus(code models.StatusCode) int {
+	switch code {
+	case models.StatusSuccess:
+		return http.StatusOK
+	case models.StatusReqTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case models.StatusReqTimeout:
+		return http.StatusRequestTimeout
+	case models.StatusObjectNotFound:
+		return http.StatusNotFound
+	case models.StatusObjectChanged:
+		return http.StatusPreconditionFailed
+	case models.StatusClientTooOld:
+		return http.StatusUpgradeRequired
+	case models.StatusUnsupportedHash:
+		return http.StatusBadRequest
+	case models.StatusMaintenance:
+		return http.StatusServiceUnavailable
+	case models.StatusBulkNotAllowed, models.StatusNotAdmin:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// limitStatus returns the models.StatusCode for errors produced by readBody,
+// or def if the error is not related to request limits.
+func limitStatus(err error, def models.StatusCode) models.StatusCode {
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		return models.StatusReqTooLarge
+	case errors.Is(err, errTimeout):
+		return models.StatusReqTimeout
+	}
+	return def
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+)
+
+// blockingReader is an io.Reader that never returns, simulating a slow client.
+type blockingReader struct {
+	done chan struct{}
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	<-b.done
+	return 0, io.EOF
+}
+
+func TestReadBody(t *testing.T) {
+	cleanup, err := prepEnvVariables(map[string]string{"MAX_REQUEST_BYTES": "8"})
+	if err != nil {
+		t.Fatalf("prepEnvVariables() returned %v", err)
+	}
+	defer cleanup()
+
+	slow := &blockingReader{done: make(chan struct{})}
+	defer close(slow.done)
+
+	tests := []struct {
+		desc    string
+		body    io.Reader
+		timeout time.Duration
+		want    error
+	}{
+		{
+			desc:    "within limit",
+			body:    bytes.NewReader([]byte("12345678")),
+			timeout: time.Minute,
+			want:    nil,
+		},
+		{
+			desc:    "too large",
+			body:    bytes.NewReader([]byte("123456789")),
+			timeout: time.Minute,
+			want:    errBodyTooLarge,
+		},
+		{
+			desc:    "timeout",
+			body:    slow,
+			timeout: 10 * time.Millisecond,
+			want:    errTimeout,
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/sign", tt.body)
+		ctx, cancel := context.WithTimeout(r.Context(), tt.timeout)
+		_, got := readBody(r.WithContext(ctx))
+		cancel()
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: readBody() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code models.StatusCode
+		want int
+	}{
+		{models.StatusSuccess, http.StatusOK},
+		{models.StatusReqTooLarge, http.StatusRequestEntityTooLarge},
+		{models.StatusReqTimeout, http.StatusRequestTimeout},
+		{models.StatusObjectNotFound, http.StatusNotFound},
+		{models.StatusObjectChanged, http.StatusPreconditionFailed},
+		{models.StatusClientTooOld, http.StatusUpgradeRequired},
+		{models.StatusUnsupportedHash, http.StatusBadRequest},
+		{models.StatusMaintenance, http.StatusServiceUnavailable},
+		{models.StatusBulkNotAllowed, http.StatusForbidden},
+		{models.StatusNotAdmin, http.StatusForbidden},
+		{models.StatusSignError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := httpStatus(tt.code); got != tt.want {
+			t.Errorf("httpStatus(%d) got: %d, want: %d", tt.code, got, tt.want)
+		}
+	}
+}
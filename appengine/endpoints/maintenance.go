@@ -0,0 +1,73 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultMaintenanceMessage is returned during maintenance when
+// MAINTENANCE_MESSAGE is not set.
+const defaultMaintenanceMessage = "the service is undergoing planned maintenance, please try again later"
+
+// maintenanceWindow describes planned maintenance of the backend.
+type maintenanceWindow struct {
+	message string
+	end     time.Time
+}
+
+// maintenanceMode returns the maintenance window that is in effect, or nil
+// when MAINTENANCE_MODE is not set to true. The message and expected end of
+// maintenance are configured using MAINTENANCE_MESSAGE and MAINTENANCE_END,
+// an RFC 3339 time. An invalid end time is returned as an error alongside a
+// window with no end time, so that requests are still refused.
+func maintenanceMode() (*maintenanceWindow, error) {
+	if os.Getenv("MAINTENANCE_MODE") != "true" {
+		return nil, nil
+	}
+	m := &maintenanceWindow{message: os.Getenv("MAINTENANCE_MESSAGE")}
+	if m.message == "" {
+		m.message = defaultMaintenanceMessage
+	}
+	e := os.Getenv("MAINTENANCE_END")
+	if e == "" {
+		return m, nil
+	}
+	end, err := time.Parse(time.RFC3339, e)
+	if err != nil {
+		return m, fmt.Errorf("MAINTENANCE_END %q is not an RFC 3339 time: %v", e, err)
+	}
+	m.end = end
+	return m, nil
+}
+
+// setRetryAfter sets the Retry-After header of w to the number of seconds
+// until end, so that generic HTTP clients also back off. Nothing is set when
+// end is unknown or has passed.
+func setRetryAfter(w http.ResponseWriter, end time.Time) {
+	if end.IsZero() {
+		return
+	}
+	d := time.Until(end)
+	if d <= 0 {
+		return
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10))
+}
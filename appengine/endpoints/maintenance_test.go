@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	end := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc    string
+		envVars map[string]string
+		want    *maintenanceWindow
+		wantErr bool
+	}{
+		{
+			desc: "not set",
+		},
+		{
+			desc:    "not enabled",
+			envVars: map[string]string{"MAINTENANCE_MODE": "false", "MAINTENANCE_MESSAGE": "upgrading"},
+		},
+		{
+			desc:    "default message",
+			envVars: map[string]string{"MAINTENANCE_MODE": "true"},
+			want:    &maintenanceWindow{message: defaultMaintenanceMessage},
+		},
+		{
+			desc: "message and end",
+			envVars: map[string]string{
+				"MAINTENANCE_MODE":    "true",
+				"MAINTENANCE_MESSAGE": "Rotating signing keys.",
+				"MAINTENANCE_END":     "2026-10-17T06:00:00Z",
+			},
+			want: &maintenanceWindow{message: "Rotating signing keys.", end: end},
+		},
+		{
+			desc: "invalid end",
+			envVars: map[string]string{
+				"MAINTENANCE_MODE":    "true",
+				"MAINTENANCE_MESSAGE": "upgrading",
+				"MAINTENANCE_END":     "tomorrow",
+			},
+			want:    &maintenanceWindow{message: "upgrading"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		got, err := maintenanceMode()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: maintenanceMode() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: maintenanceMode() got: %+v, want: %+v", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	tests := []struct {
+		desc string
+		end  time.Time
+		want bool
+	}{
+		{
+			desc: "unknown end",
+		},
+		{
+			desc: "ended",
+			end:  time.Now().Add(-time.Hour),
+		},
+		{
+			desc: "future end",
+			end:  time.Now().Add(time.Hour),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		setRetryAfter(w, tt.end)
+		got := w.Header().Get("Retry-After")
+		if (got != "") != tt.want {
+			t.Errorf("%s: setRetryAfter() set Retry-After to %q, want set: %t", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+// openAPIVersion is the version of the OpenAPI specification produced by
+// openAPISpec.
+const openAPIVersion = "3.0.3"
+
+// statusCodes describes each models.StatusCode, in the order they are listed
+// in the specification.
+var statusCodes = []struct {
+	code models.StatusCode
+	desc string
+}{
+	{models.StatusSuccess, "the request succeeded"},
+	{models.StatusConfigError, "the server is misconfigured"},
+	{models.StatusReqUnreadable, "the request body could not be read"},
+	{models.StatusJSONError, "the request or response could not be encoded as JSON"},
+	{models.StatusSignError, "the seed or URL could not be signed, or the seed was not valid"},
+	{models.StatusSeedError, "the seed request was not valid"},
+	{models.StatusSeedInvalidHash, "the hash is not in the allowlist"},
+	{models.StatusInvalidUser, "the user is not signed in"},
+	{models.StatusReqTooLarge, "the request body exceeds MAX_REQUEST_BYTES"},
+	{models.StatusReqTimeout, "the request was not received within REQUEST_TIMEOUT"},
+	{models.StatusObjectChanged, "the object does not match the pinned generation or MD5"},
+	{models.StatusObjectNotFound, "the object does not exist"},
+	{models.StatusClientTooOld, "the client is older than MIN_CLIENT_VERSION"},
+	{models.StatusUnsupportedHash, "the hash algorithm is not accepted, see Algorithms"},
+	{models.StatusMaintenance, "the server is in maintenance mode, see Status and MaintenanceEnd"},
+	{models.StatusBulkNotAllowed, "the user is not listed in BULK_SEED_USERS, or Count is out of range"},
+	{models.StatusNotAdmin, "the user is not an administrator of the application"},
+}
+
+// operation describes an endpoint in the specification. Request and Response
+// are zero values of the models exchanged with the endpoint, and codes lists
+// the error codes that it may return.
+type operation struct {
+	path     string
+	method   string
+	summary  string
+	desc     string
+	request  interface{}
+	response interface{}
+	codes    []models.StatusCode
+}
+
+// operations lists the endpoints described by the specification.
+var operations = []operation{
+	{
+		path:    "/seed",
+		method:  http.MethodPost,
+		summary: "Obtain a signed seed",
+		desc: "Issues a seed for the hash of a seed file to the signed in user. " +
+			"The seed and its signature are written to provisioned media, and are " +
+			"later used to authorize /sign requests.",
+		request:  models.SeedRequest{},
+		response: models.SeedResponse{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusReqUnreadable, models.StatusJSONError,
+			models.StatusSignError, models.StatusSeedError, models.StatusInvalidUser,
+			models.StatusReqTooLarge, models.StatusReqTimeout, models.StatusClientTooOld,
+			models.StatusUnsupportedHash, models.StatusMaintenance,
+		},
+	},
+	{
+		path:    "/seed/bulk",
+		method:  http.MethodPost,
+		summary: "Obtain several signed seeds",
+		desc: "Issues Count seeds for the hash of a seed file in one request, for " +
+			"duplicators that provision many devices from one verified master. " +
+			"Each seed carries its own nonce and signature. Only users listed in " +
+			"BULK_SEED_USERS may make bulk requests.",
+		request:  models.BulkSeedRequest{},
+		response: models.BulkSeedResponse{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusReqUnreadable, models.StatusJSONError,
+			models.StatusSignError, models.StatusSeedError, models.StatusInvalidUser,
+			models.StatusReqTooLarge, models.StatusReqTimeout, models.StatusClientTooOld,
+			models.StatusUnsupportedHash, models.StatusMaintenance, models.StatusBulkNotAllowed,
+		},
+	},
+	{
+		path:    "/sign",
+		method:  http.MethodPost,
+		summary: "Obtain a signed URL",
+		desc: "Returns a signed URL for an object in the bucket, authorized by a " +
+			"seed previously issued by /seed.",
+		request:  models.SignRequest{},
+		response: models.SignResponse{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusReqUnreadable, models.StatusJSONError,
+			models.StatusSignError, models.StatusReqTooLarge, models.StatusReqTimeout,
+			models.StatusObjectChanged, models.StatusObjectNotFound, models.StatusClientTooOld,
+			models.StatusMaintenance,
+		},
+	},
+	{
+		path:    "/health/allowlist",
+		method:  http.MethodGet,
+		summary: "Check the allowlist",
+		desc: "Reports whether the allowlist is present, parses cleanly and is " +
+			"up to date. Unhealthy allowlists receive a 503 response. The path " +
+			"and problems are only described to administrators and cron.",
+		response: models.AllowlistHealth{},
+	},
+	{
+		path:    "/keys",
+		method:  http.MethodGet,
+		summary: "Obtain the public certificates",
+		desc: "Returns the public certificates that seeds are currently signed " +
+			"with, so that seed files can be verified offline.",
+		response: models.KeysResponse{},
+	},
+	{
+		path:    "/admin/summary",
+		method:  http.MethodGet,
+		summary: "Summarize image downloads",
+		desc: "Aggregates the signed URLs issued for each image by installer build, " +
+			"over the number of days in the days query parameter. Only application " +
+			"administrators may obtain a summary.",
+		response: models.DownloadSummary{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusReqUnreadable, models.StatusInvalidUser,
+			models.StatusNotAdmin,
+		},
+	},
+	{
+		path:    "/admin/allowlist",
+		method:  http.MethodGet,
+		summary: "List the allowlist",
+		desc: "Lists each entry of the allowlist of the current environment, " +
+			"describing those that are not valid. Only application administrators " +
+			"may list the allowlist.",
+		response: models.AdminAllowlist{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusInvalidUser, models.StatusNotAdmin,
+		},
+	},
+	{
+		path:    "/admin/activity",
+		method:  http.MethodGet,
+		summary: "Report recent activity",
+		desc: "Reports the seeds and signed URLs issued over the number of days in " +
+			"the days query parameter, most recent first, as recorded in " +
+			"ANALYTICS_BUCKET. Only application administrators may obtain the activity.",
+		response: models.AdminActivity{},
+		codes: []models.StatusCode{
+			models.StatusConfigError, models.StatusReqUnreadable, models.StatusInvalidUser,
+			models.StatusNotAdmin,
+		},
+	},
+	{
+		path:    "/admin/checks",
+		method:  http.MethodGet,
+		summary: "Check the configuration",
+		desc: "Checks the environment variables and allowlist of the service for " +
+			"common mistakes. Only application administrators may obtain the checks.",
+		response: models.AdminChecks{},
+		codes:    []models.StatusCode{models.StatusInvalidUser, models.StatusNotAdmin},
+	},
+}
+
+// spec models an OpenAPI document. Only the parts of the specification used
+// to describe the endpoints are included.
+type spec struct {
+	OpenAPI    string                       `json:"openapi"`
+	Info       specInfo                     `json:"info"`
+	Paths      map[string]map[string]specOp `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type specOp struct {
+	Summary     string                  `json:"summary"`
+	Description string                  `json:"description"`
+	Parameters  []specParam             `json:"parameters,omitempty"`
+	RequestBody *specBody               `json:"requestBody,omitempty"`
+	Responses   map[string]specResponse `json:"responses"`
+}
+
+type specParam struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type specBody struct {
+	Required bool                     `json:"required"`
+	Content  map[string]specMediaType `json:"content"`
+}
+
+type specResponse struct {
+	Description string                   `json:"description"`
+	Content     map[string]specMediaType `json:"content,omitempty"`
+}
+
+type specMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema models an OpenAPI schema object.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+// docsPage renders the specification as a self-contained page, so that it
+// can be read without loading third party scripts.
+var docsPage = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"typeOf": typeOf,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Info.Title}} API</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code { background: #f4f4f4; }
+pre { white-space: pre-wrap; margin: 0; font-family: inherit; }
+</style>
+</head>
+<body>
+<h1>{{.Info.Title}} API</h1>
+<p>{{.Info.Description}}</p>
+<p>The OpenAPI {{.OpenAPI}} specification is available at <a href="openapi.json">/openapi.json</a>.</p>
+<h2>Endpoints</h2>
+{{range $path, $ops := .Paths}}{{range $method, $op := $ops}}
+<h3><code>{{$method}} {{$path}}</code></h3>
+<p><b>{{$op.Summary}}.</b> {{$op.Description}}</p>
+{{with $op.RequestBody}}<p>Request: {{range .Content}}{{typeOf .Schema}}{{end}}</p>{{end}}
+<table>
+<tr><th>Status</th><th>Response</th><th>Description</th></tr>
+{{range $status, $resp := $op.Responses}}<tr><td>{{$status}}</td><td>{{range $resp.Content}}{{typeOf .Schema}}{{end}}</td><td>{{$resp.Description}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+<h2>Types</h2>
+{{range $name, $schema := .Components.Schemas}}
+<h3 id="{{$name}}">{{$name}}</h3>
+<table>
+<tr><th>Field</th><th>Type</th><th>Description</th></tr>
+{{range $field, $prop := $schema.Properties}}<tr><td><code>{{$field}}</code></td><td>{{typeOf $prop}}</td><td><pre>{{$prop.Description}}</pre></td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// DocsHandler implements http.Handler and serves the OpenAPI specification of
+// the endpoints as a human readable page.
+type DocsHandler struct{}
+
+func (DocsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	var b bytes.Buffer
+	if err := docsPage.Execute(&b, openAPISpec()); err != nil {
+		log.Errorf(ctx, "docsPage.Execute(): %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := writeCacheable(w, r, http.StatusOK, b.Bytes()); err != nil {
+		log.Errorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// typeOf returns a short description of the type of sc for display.
+func typeOf(sc *schema) string {
+	switch {
+	case sc == nil:
+		return ""
+	case len(sc.AllOf) == 1:
+		return typeOf(sc.AllOf[0])
+	case sc.Ref != "":
+		return strings.TrimPrefix(sc.Ref, "#/components/schemas/")
+	case sc.Items != nil:
+		return "[]" + typeOf(sc.Items)
+	case sc.AdditionalProperties != nil:
+		return "map[string]" + typeOf(sc.AdditionalProperties)
+	case len(sc.Enum) > 0 && sc.Type == "string":
+		var e []string
+		for _, v := range sc.Enum {
+			e = append(e, fmt.Sprint(v))
+		}
+		return "string (" + strings.Join(e, ", ") + ")"
+	case sc.Format != "":
+		return sc.Type + " (" + sc.Format + ")"
+	}
+	return sc.Type
+}
+
+// OpenAPIHandler implements http.Handler and serves the OpenAPI specification
+// of the endpoints as JSON.
+type OpenAPIHandler struct{}
+
+func (OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	b, err := json.MarshalIndent(openAPISpec(), "", "  ")
+	if err != nil {
+		log.Errorf(ctx, "json.MarshalIndent(openAPISpec()): %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeCacheable(w, r, http.StatusOK, b); err != nil {
+		log.Errorf(ctx, "failed to write response to client: %v", err)
+	}
+}
+
+// openAPISpec generates the OpenAPI specification for operations from the
+// types in models.
+func openAPISpec() *spec {
+	s := &spec{
+		OpenAPI: openAPIVersion,
+		Info: specInfo{
+			Title: "Fresnel",
+			Description: "Fresnel issues seeds to provisioning clients, and signed URLs " +
+				"for the objects needed to install an operating system to machines " +
+				"booted from provisioned media. Byte fields are base64 encoded, and " +
+				"times are RFC 3339 strings.",
+			Version: "1",
+		},
+		Paths: make(map[string]map[string]specOp),
+	}
+	s.Components.Schemas = make(map[string]*schema)
+
+	version := &specParam{
+		Name:        models.ClientVersionHeader,
+		In:          "header",
+		Description: "The release version of the client, required by /seed when MIN_CLIENT_VERSION is set.",
+		Schema:      &schema{Type: "string"},
+	}
+	for _, o := range operations {
+		op := specOp{
+			Summary:     o.summary,
+			Description: o.desc,
+			Responses:   make(map[string]specResponse),
+		}
+		resp := map[string]specMediaType{"application/json": {Schema: s.schemaOf(reflect.TypeOf(o.response))}}
+		if o.request != nil {
+			op.Parameters = []specParam{*version}
+			op.RequestBody = &specBody{
+				Required: true,
+				Content:  map[string]specMediaType{"application/json": {Schema: s.schemaOf(reflect.TypeOf(o.request))}},
+			}
+			op.Responses[strconv.Itoa(http.StatusOK)] = specResponse{Description: describeCodes([]models.StatusCode{models.StatusSuccess}), Content: resp}
+		} else if len(o.codes) > 0 {
+			op.Responses[strconv.Itoa(http.StatusOK)] = specResponse{Description: describeCodes([]models.StatusCode{models.StatusSuccess}), Content: resp}
+		} else {
+			op.Responses[strconv.Itoa(http.StatusOK)] = specResponse{Description: "Healthy.", Content: resp}
+			op.Responses[strconv.Itoa(http.StatusServiceUnavailable)] = specResponse{Description: "Unhealthy.", Content: resp}
+		}
+		// Errors are grouped by the HTTP status that they are returned with.
+		byStatus := make(map[int][]models.StatusCode)
+		for _, c := range o.codes {
+			byStatus[httpStatus(c)] = append(byStatus[httpStatus(c)], c)
+		}
+		for status, codes := range byStatus {
+			op.Responses[strconv.Itoa(status)] = specResponse{Description: describeCodes(codes), Content: resp}
+		}
+		if s.Paths[o.path] == nil {
+			s.Paths[o.path] = make(map[string]specOp)
+		}
+		s.Paths[o.path][strings.ToLower(o.method)] = op
+	}
+	return s
+}
+
+// describeCodes returns a description of the ErrorCode values in codes.
+func describeCodes(codes []models.StatusCode) string {
+	var d []string
+	for _, c := range codes {
+		for _, sc := range statusCodes {
+			if sc.code == c {
+				d = append(d, fmt.Sprintf("%d: %s", c, sc.desc))
+			}
+		}
+	}
+	return "ErrorCode " + strings.Join(d, "; ") + "."
+}
+
+// schemaOf returns the schema for t. Structs are added to the components of
+// s and referenced by name.
+func (s *spec) schemaOf(t reflect.Type) *schema {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf([]byte(nil)):
+		return &schema{Type: "string", Format: "byte"}
+	case reflect.TypeOf(models.HashAlgorithm("")):
+		return &schema{Type: "string", Enum: []interface{}{models.HashSHA256, models.HashSHA512}}
+	case reflect.TypeOf(models.StatusCode(0)):
+		sc := &schema{Type: "integer", Format: "int32"}
+		var d []string
+		for _, c := range statusCodes {
+			sc.Enum = append(sc.Enum, c.code)
+			d = append(d, fmt.Sprintf("%d: %s", c.code, c.desc))
+		}
+		sc.Description = strings.Join(d, "\n")
+		return sc
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return &schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int64"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Ptr:
+		return s.schemaOf(t.Elem())
+	case reflect.Struct:
+		if _, ok := s.Components.Schemas[t.Name()]; !ok {
+			obj := &schema{Type: "object", Properties: make(map[string]*schema)}
+			// Register the schema before its fields, to allow for recursion.
+			s.Components.Schemas[t.Name()] = obj
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+					continue
+				}
+				p := s.schemaOf(f.Type)
+				if d := f.Tag.Get("doc"); d != "" {
+					if p.Ref != "" {
+						// Siblings of $ref are ignored, so references are
+						// wrapped to retain the description.
+						p = &schema{AllOf: []*schema{p}}
+					}
+					if p.Description != "" {
+						d = d + "\n" + p.Description
+					}
+					p.Description = d
+				}
+				obj.Properties[f.Name] = p
+			}
+		}
+		return &schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &schema{Description: t.String()}
+}
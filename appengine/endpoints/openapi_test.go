@@ -0,0 +1,182 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	s := openAPISpec()
+	for _, o := range operations {
+		op, ok := s.Paths[o.path][strings.ToLower(o.method)]
+		if !ok {
+			t.Errorf("openAPISpec() is missing %s %s", o.method, o.path)
+			continue
+		}
+		if _, ok := op.Responses["200"]; !ok {
+			t.Errorf("openAPISpec() %s %s has no 200 response", o.method, o.path)
+		}
+		for _, c := range o.codes {
+			if _, ok := op.Responses[strconv.Itoa(httpStatus(c))]; !ok {
+				t.Errorf("openAPISpec() %s %s has no response for ErrorCode %d", o.method, o.path, c)
+			}
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		schema string
+		field  string
+		want   string
+	}{
+		{
+			desc:   "bytes",
+			schema: "SeedRequest",
+			field:  "Hash",
+			want:   "string (byte)",
+		},
+		{
+			desc:   "enum",
+			schema: "SeedRequest",
+			field:  "Algorithm",
+			want:   "string (sha256, sha512)",
+		},
+		{
+			desc:   "time",
+			schema: "SignResponse",
+			field:  "Expires",
+			want:   "string (date-time)",
+		},
+		{
+			desc:   "reference",
+			schema: "SignRequest",
+			field:  "Seed",
+			want:   "Seed",
+		},
+		{
+			desc:   "external type",
+			schema: "Seed",
+			field:  "Certs",
+			want:   "[]Certificate",
+		},
+		{
+			desc:   "status code",
+			schema: "SeedResponse",
+			field:  "ErrorCode",
+			want:   "integer (int32)",
+		},
+	}
+	for _, tt := range tests {
+		sc, ok := s.Components.Schemas[tt.schema]
+		if !ok {
+			t.Errorf("%s: openAPISpec() is missing schema %s", tt.desc, tt.schema)
+			continue
+		}
+		if got := typeOf(sc.Properties[tt.field]); got != tt.want {
+			t.Errorf("%s: openAPISpec() %s.%s got: %q, want: %q", tt.desc, tt.schema, tt.field, got, tt.want)
+		}
+	}
+}
+
+// TestModelsDocumented ensures that every field exchanged with the endpoints
+// is described in the specification.
+func TestModelsDocumented(t *testing.T) {
+	for _, m := range []interface{}{
+		models.SeedRequest{},
+		models.SeedResponse{},
+		models.SignRequest{},
+		models.SignResponse{},
+		models.Seed{},
+		models.AllowlistHealth{},
+		models.KeysResponse{},
+		models.BulkSeedRequest{},
+		models.BulkSeedResponse{},
+		models.DownloadSummary{},
+		models.ImageDownloads{},
+		models.DownloadRecord{},
+		models.SeedRecord{},
+		models.AdminAllowlist{},
+		models.AllowlistEntry{},
+		models.AdminActivity{},
+		models.AdminChecks{},
+		models.ConfigCheck{},
+	} {
+		typ := reflect.TypeOf(m)
+		for i := 0; i < typ.NumField(); i++ {
+			if typ.Field(i).Tag.Get("doc") == "" {
+				t.Errorf("%s.%s has no doc tag", typ.Name(), typ.Field(i).Name)
+			}
+		}
+	}
+}
+
+func TestStatusCodesDescribed(t *testing.T) {
+	for c := models.StatusConfigError; c <= models.StatusNotAdmin; c++ {
+		if !strings.Contains(describeCodes([]models.StatusCode{c}), strconv.Itoa(int(c))+":") {
+			t.Errorf("describeCodes(%d) has no description", c)
+		}
+	}
+}
+
+func TestOpenAPIHandlers(t *testing.T) {
+	tests := []struct {
+		desc        string
+		handler     http.Handler
+		contentType string
+		want        string
+	}{
+		{
+			desc:        "openapi.json",
+			handler:     OpenAPIHandler{},
+			contentType: "application/json",
+			want:        `"openapi": "` + openAPIVersion + `"`,
+		},
+		{
+			desc:        "docs",
+			handler:     DocsHandler{},
+			contentType: "text/html; charset=utf-8",
+			want:        "<code>post /seed</code>",
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: ServeHTTP() status got: %d, want: %d", tt.desc, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: ServeHTTP() Content-Type got: %q, want: %q", tt.desc, got, tt.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: ServeHTTP() body does not contain %q", tt.desc, tt.want)
+		}
+	}
+
+	var s map[string]interface{}
+	w := httptest.NewRecorder()
+	OpenAPIHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Errorf("OpenAPIHandler returned invalid JSON: %v", err)
+	}
+}
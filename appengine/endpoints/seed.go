@@ -17,14 +17,16 @@ package endpoints
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -34,44 +36,117 @@ import (
 	"google.golang.org/appengine/user"
 )
 
+const (
+	// allowlistDir is the folder in the bucket that holds allowlists.
+	allowlistDir = "appengine_config"
+	// allowlistFile is the name of the allowlist in allowlistDir, or in a
+	// sub-folder of allowlistDir that is named after the environment.
+	allowlistFile = "pe_allowlist.yaml"
+)
+
 var (
-	signSeed      = signSeedResponse
-	supportedHash = map[int]bool{
-		sha256.Size: true,
+	signSeed    = signSeedResponse
+	appID       = appengine.AppID
+	logErrorf   = log.Errorf
+	logWarningf = log.Warningf
+	logInfof    = log.Infof
+	// supportedHash maps the hash algorithms that the server can accept to the
+	// size of the hashes they produce.
+	supportedHash = map[models.HashAlgorithm]int{
+		models.HashSHA256: sha256.Size,
+		models.HashSHA512: sha512.Size,
 	}
+	regExEnvironment = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 )
 
 // SeedRequestHandler implements http.Handler for signed URL requests.
 type SeedRequestHandler struct{}
 
 func (SeedRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handleSeedRequest(w, r, false)
+}
+
+// handleSeedRequest validates a seed request and issues the seeds that it
+// asks for. Bulk requests are answered with a models.BulkSeedResponse, and
+// others with a models.SeedResponse. Both share the fields used to describe
+// a rejected request, so rejections are written the same way for either.
+func handleSeedRequest(w http.ResponseWriter, r *http.Request, bulk bool) {
+	r, cancel := withDeadline(r)
+	defer cancel()
 	ctx := appengine.NewContext(r)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Seed to be used during error conditions
 	errSeedResp := `{"Status":"%s","ErrorCode":%d}`
 
-	sr, err := unmarshalSeedRequest(r)
+	logClient(ctx, r)
+	m, err := maintenanceMode()
+	if err != nil {
+		logErrorf(ctx, "maintenanceMode(): %v", err)
+	}
+	if m != nil {
+		logWarningf(ctx, "refusing seed request during maintenance: %s", m.message)
+		setRetryAfter(w, m.end)
+		writeSeedResponse(ctx, w, models.SeedResponse{
+			Status:         m.message,
+			ErrorCode:      models.StatusMaintenance,
+			MaintenanceEnd: m.end,
+		})
+		return
+	}
+	if err := checkClientVersion(r, true); err != nil {
+		logWarningf(ctx, "checkClientVersion(): %v", err)
+		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusClientTooOld), httpStatus(models.StatusClientTooOld))
+		return
+	}
+
+	sr, count, err := unmarshalSeedRequests(r, bulk)
 	if err != nil {
-		log.Errorf(ctx, "unmarshalSeedRequest(): %v", err)
-		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusJSONError), http.StatusInternalServerError)
+		logErrorf(ctx, "unmarshalSeedRequests(): %v", err)
+		code := limitStatus(err, models.StatusJSONError)
+		http.Error(w, fmt.Sprintf(errSeedResp, err, code), httpStatus(code))
 		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx)
 	if u == nil {
-		log.Errorf(ctx, "seed requested without user information in context: #%s", ctx)
+		logErrorf(ctx, "seed requested without user information in context: #%s", ctx)
 		http.Error(w, fmt.Sprintf(errSeedResp, "no user", models.StatusInvalidUser), http.StatusInternalServerError)
 		return
 	}
+	if bulk {
+		if err := authorizeBulk(u, count); err != nil {
+			logWarningf(ctx, "authorizeBulk(): %v", err)
+			http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusBulkNotAllowed), httpStatus(models.StatusBulkNotAllowed))
+			return
+		}
+	}
+
+	algs, err := acceptedAlgorithms()
+	if err != nil {
+		logErrorf(ctx, "acceptedAlgorithms(): %v", err)
+		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusConfigError), http.StatusInternalServerError)
+		return
+	}
+	alg := algorithmOf(sr.Algorithm)
+	if err := validAlgorithm(alg, sr.Hash, algs); err != nil {
+		logWarningf(ctx, "validAlgorithm(): %v", err)
+		writeSeedResponse(ctx, w, models.SeedResponse{
+			Status:     err.Error(),
+			ErrorCode:  models.StatusUnsupportedHash,
+			Algorithm:  alg,
+			Algorithms: algs,
+		})
+		return
+	}
 
 	hashCheck := os.Getenv("VERIFY_SEED_HASH")
 	if hashCheck != "true" {
-		log.Infof(ctx, "VERIFY_SEED_HASH is not set to true, hash validation will be logged but not enforced")
+		logInfof(ctx, "VERIFY_SEED_HASH is not set to true, hash validation will be logged but not enforced")
 	}
 	acceptedHashes, err := populateAllowlist(ctx)
 	if err != nil {
-		log.Errorf(ctx, "failed to populate hash allowlist: %v", err)
+		logErrorf(ctx, "failed to populate hash allowlist: %v", err)
 		if hashCheck == "true" {
 			http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusSeedError), http.StatusInternalServerError)
 			return
@@ -79,41 +154,62 @@ func (SeedRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := validateSeedRequest(u, sr, acceptedHashes); err != nil {
-		log.Errorf(ctx, "validateSeedRequest(%s,%#v,%#v): %v", u.String(), sr, acceptedHashes, err)
+		logErrorf(ctx, "validateSeedRequest(%s,%#v,%#v): %v", u.String(), sr, acceptedHashes, err)
 		if !strings.Contains(err.Error(), "not in allowlist") || hashCheck == "true" {
 			http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusReqUnreadable), http.StatusInternalServerError)
 			return
 		}
 	}
-	log.Infof(ctx, "validated seed request from %s with hash %x", u.String(), sr.Hash)
+	logInfof(ctx, "validated seed request from %s with %s hash %x (host: %q, os: %q, version: %q, batch: %q, fields: %q)", u.String(), alg, sr.Hash, sr.Hostname, sr.OS, sr.Version, sr.Batch, sr.Fields)
 
+	if bulk {
+		if issueBulkSeeds(ctx, w, sr.Hash, u, count, alg, algs) {
+			recordSeeds(ctx, sr, u, alg, count)
+		}
+		return
+	}
 	s := generateSeed(sr.Hash, u)
-	log.Infof(ctx, "successfully generated Seed: %#v", s)
+	logInfof(ctx, "successfully generated Seed: %#v", s)
 
 	resp, err := signSeed(ctx, s)
 	if err != nil {
-		log.Errorf(ctx, "signSeed(): %v", err)
+		logErrorf(ctx, "signSeed(): %v", err)
 		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusSignError), http.StatusInternalServerError)
 		return
 	}
-	log.Infof(ctx, "successfully signed seed: %+v", resp.Seed)
+	logInfof(ctx, "successfully signed seed: %+v", resp.Seed)
+	resp.Algorithm = alg
+	resp.Algorithms = algs
+
+	if err := writeSeedResponse(ctx, w, resp); err != nil {
+		return
+	}
 
+	if resp.ErrorCode == models.StatusSuccess {
+		logInfof(ctx, "successfully processed SeedRequest with response: %+v", resp)
+		recordSeeds(ctx, sr, u, alg, 1)
+	}
+}
+
+// writeSeedResponse writes resp to w as JSON, along with the HTTP status that
+// corresponds to its ErrorCode.
+func writeSeedResponse(ctx context.Context, w http.ResponseWriter, resp models.SeedResponse) error {
 	jsonResponse, err := json.Marshal(resp)
 	if err != nil {
 		es := fmt.Sprintf("json.Marshall(%v): %v", resp, err)
-		log.Errorf(ctx, es)
-		http.Error(w, fmt.Sprintf(errSeedResp, err, models.StatusJSONError), http.StatusInternalServerError)
-		return
+		logErrorf(ctx, es)
+		http.Error(w, fmt.Sprintf(`{"Status":"%s","ErrorCode":%d}`, err, models.StatusJSONError), http.StatusInternalServerError)
+		return err
 	}
 
-	if _, err = w.Write(jsonResponse); err != nil {
-		log.Errorf(ctx, fmt.Sprintf("failed to write response to client: %s", err))
-		return
+	if resp.ErrorCode != models.StatusSuccess {
+		w.WriteHeader(httpStatus(resp.ErrorCode))
 	}
-
-	if resp.ErrorCode == models.StatusSuccess {
-		log.Infof(ctx, "successfully processed SeedRequest with response: %+v", resp)
+	if _, err = w.Write(jsonResponse); err != nil {
+		logErrorf(ctx, fmt.Sprintf("failed to write response to client: %s", err))
+		return err
 	}
+	return nil
 }
 
 // generateSeed generates an object that contains the response to the media generation tool
@@ -127,14 +223,26 @@ func generateSeed(hash []byte, u *user.User) models.Seed {
 
 }
 
+// unmarshalSeedRequests parses the request in r, which is a
+// models.BulkSeedRequest when bulk is true, and returns the seed request and
+// the number of seeds that it asks for.
+func unmarshalSeedRequests(r *http.Request, bulk bool) (models.SeedRequest, int, error) {
+	if !bulk {
+		sr, err := unmarshalSeedRequest(r)
+		return sr, 1, err
+	}
+	br, err := unmarshalBulkSeedRequest(r)
+	return br.Request, br.Count, err
+}
+
 // unmarshalSeedRequest parses a JSON object passed in an http request in to a models.SeedRequest object.
 func unmarshalSeedRequest(r *http.Request) (models.SeedRequest, error) {
 	var seedRequest models.SeedRequest
 
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
 		return models.SeedRequest{},
-			fmt.Errorf("error reading request body: %v", err)
+			fmt.Errorf("error reading request body: %w", err)
 	}
 
 	if len(body) == 0 {
@@ -156,18 +264,91 @@ func validateSeedRequest(u *user.User, sr models.SeedRequest, ah map[string]bool
 	if len(u.String()) < 1 {
 		return fmt.Errorf("no username detected: %s", u.String())
 	}
+	if err := validateFields(sr.Fields); err != nil {
+		return err
+	}
 
-	h := hex.EncodeToString(sr.Hash)
-	if _, ok := ah[h]; ok {
+	if _, ok := ah[allowlistKey(algorithmOf(sr.Algorithm), sr.Hash)]; ok {
 		return nil
 	}
 
 	return fmt.Errorf("request hash %v not in allowlist: %#v", hex.EncodeToString(sr.Hash), ah)
 }
 
+// validateFields checks that the additional fields of a seed request are
+// within the limits published in models, so that they can be logged safely.
+func validateFields(fields map[string]string) error {
+	if len(fields) > models.MaxSeedFields {
+		return fmt.Errorf("request has %d fields, at most %d are accepted", len(fields), models.MaxSeedFields)
+	}
+	for k, v := range fields {
+		if k == "" || len(k) > models.MaxSeedFieldKey {
+			return fmt.Errorf("field name %q must be 1-%d characters", k, models.MaxSeedFieldKey)
+		}
+		if len(v) > models.MaxSeedFieldValue {
+			return fmt.Errorf("field %q is longer than %d characters", k, models.MaxSeedFieldValue)
+		}
+	}
+	return nil
+}
+
+// acceptedAlgorithms returns the hash algorithms accepted in seed requests, in
+// order of preference, as configured by HASH_ALGORITHMS. Only SHA-256 is
+// accepted when it is not set.
+func acceptedAlgorithms() ([]models.HashAlgorithm, error) {
+	v := os.Getenv("HASH_ALGORITHMS")
+	if v == "" {
+		return []models.HashAlgorithm{models.HashSHA256}, nil
+	}
+	var algs []models.HashAlgorithm
+	for _, a := range strings.Split(v, ",") {
+		alg := models.HashAlgorithm(strings.ToLower(strings.TrimSpace(a)))
+		if _, ok := supportedHash[alg]; !ok {
+			return nil, fmt.Errorf("HASH_ALGORITHMS contains unsupported algorithm %q", alg)
+		}
+		algs = append(algs, alg)
+	}
+	return algs, nil
+}
+
+// algorithmOf returns the hash algorithm identified by a, which is SHA-256
+// for requests from clients that do not identify one.
+func algorithmOf(a models.HashAlgorithm) models.HashAlgorithm {
+	if a == "" {
+		return models.HashSHA256
+	}
+	return a
+}
+
+// validAlgorithm ensures that alg is one of the accepted algorithms, and that
+// hash has the size produced by it.
+func validAlgorithm(alg models.HashAlgorithm, hash []byte, accepted []models.HashAlgorithm) error {
+	for _, a := range accepted {
+		if a != alg {
+			continue
+		}
+		if len(hash) != supportedHash[alg] {
+			return fmt.Errorf("%s hash has size %d, want %d", alg, len(hash), supportedHash[alg])
+		}
+		return nil
+	}
+	return fmt.Errorf("hash algorithm %q is not accepted, use one of %v", alg, accepted)
+}
+
+// allowlistKey returns the allowlist entry that permits hash. SHA-256 hashes
+// are listed by their hex encoding alone, while hashes computed with other
+// algorithms are prefixed with the algorithm, as in 'sha512:<hex>'.
+func allowlistKey(alg models.HashAlgorithm, hash []byte) string {
+	h := hex.EncodeToString(hash)
+	if alg == models.HashSHA256 {
+		return h
+	}
+	return fmt.Sprintf("%s:%s", alg, h)
+}
+
 // signSeed will generate a seed response from a valid seed.
 func signSeedResponse(ctx context.Context, s models.Seed) (models.SeedResponse, error) {
-	certs, err := appengine.PublicCertificates(ctx)
+	certs, err := publicCertificates(ctx)
 	if err != nil {
 		return models.SeedResponse{}, fmt.Errorf("appengine.PublicCertificates(): %v", err)
 	}
@@ -188,7 +369,7 @@ func signSeedResponse(ctx context.Context, s models.Seed) (models.SeedResponse,
 
 	log.Infof(ctx, "marshalled with a total byte size of: %v", binary.Size(jsonSeed))
 
-	_, sig, err := appengine.SignBytes(ctx, jsonSeed)
+	_, sig, err := signBytes(ctx, jsonSeed)
 	if err != nil {
 		return models.SeedResponse{},
 			fmt.Errorf("sign failed: %v", err)
@@ -197,15 +378,40 @@ func signSeedResponse(ctx context.Context, s models.Seed) (models.SeedResponse,
 	// nil out hash so it's not sent to the client, the client will regenerate hash and send with sign requests.
 	s.Hash = nil
 
+	// The expiry is informational, so a missing validity period does not
+	// prevent the seed from being issued.
+	var expires time.Time
+	d, err := seedValidity()
+	if err != nil {
+		log.Warningf(ctx, "unable to determine seed expiry: %v", err)
+	} else {
+		expires = s.Issued.Add(d)
+	}
+
 	return models.SeedResponse{
 			Status:    "success",
 			ErrorCode: models.StatusSuccess,
 			Seed:      s,
 			Signature: sig,
+			ExpiresAt: expires,
 		},
 		nil
 }
 
+// seedValidity returns the period for which issued seeds are accepted, as
+// configured by SEED_VALIDITY_DURATION.
+func seedValidity() (time.Duration, error) {
+	validityPeriod := os.Getenv("SEED_VALIDITY_DURATION")
+	if validityPeriod == "" {
+		return 0, errors.New("SEED_VALIDITY_DURATION environment variable is not present")
+	}
+	d, err := time.ParseDuration(validityPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("time.parseDuration(%s): %v", validityPeriod, err)
+	}
+	return d, nil
+}
+
 // populateAllowlist will return a map of hashes allowed to request a seed or signed url.
 func populateAllowlist(ctx context.Context) (map[string]bool, error) {
 	b := os.Getenv("BUCKET")
@@ -213,14 +419,20 @@ func populateAllowlist(ctx context.Context) (map[string]bool, error) {
 		return nil, errors.New("BUCKET environment variable not set")
 	}
 
-	var err error
-	ih, found := c.Get("acceptedHashes")
+	p, err := allowlistPath(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("allowlistPath: %v", err)
+	}
+	// The cache key includes the path so that a change of environment is
+	// never served a stale allowlist from another environment.
+	key := "acceptedHashes/" + p
+	ih, found := c.Get(key)
 	if !found {
-		ih, err = getAllowlist(ctx, b, "appengine_config/pe_allowlist.yaml")
+		ih, err = getAllowlist(ctx, b, p)
 		if err != nil {
 			return nil, fmt.Errorf("retrieving allowlist returned error: %v", err)
 		}
-		c.Set("acceptedHashes", ih, time.Duration(5*time.Minute))
+		c.Set(key, ih, time.Duration(5*time.Minute))
 	}
 
 	ah, ok := ih.(map[string]bool)
@@ -229,3 +441,48 @@ func populateAllowlist(ctx context.Context) (map[string]bool, error) {
 	}
 	return ah, nil
 }
+
+// environment returns the name of the environment the application is serving,
+// such as 'prod' or 'staging'. ENVIRONMENT takes precedence. Otherwise the
+// project ID is looked up in ENVIRONMENT_PROJECTS, a comma separated list of
+// project=environment pairs. An empty string is returned when neither is
+// configured.
+func environment(ctx context.Context) (string, error) {
+	env := os.Getenv("ENVIRONMENT")
+	if env == "" {
+		projects := os.Getenv("ENVIRONMENT_PROJECTS")
+		if projects == "" {
+			return "", nil
+		}
+		id := appID(ctx)
+		for _, pair := range strings.Split(projects, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && kv[0] == id {
+				env = kv[1]
+				break
+			}
+		}
+		if env == "" {
+			logWarningf(ctx, "project %q is not listed in ENVIRONMENT_PROJECTS, using the default allowlist", id)
+			return "", nil
+		}
+	}
+	if !regExEnvironment.MatchString(env) {
+		return "", fmt.Errorf("environment %q contains unsupported characters", env)
+	}
+	return env, nil
+}
+
+// allowlistPath returns the bucket relative path of the allowlist for the
+// current environment. When no environment is configured, the shared
+// allowlist in allowlistDir is used.
+func allowlistPath(ctx context.Context) (string, error) {
+	env, err := environment(ctx)
+	if err != nil {
+		return "", err
+	}
+	if env == "" {
+		return path.Join(allowlistDir, allowlistFile), nil
+	}
+	return path.Join(allowlistDir, env, allowlistFile), nil
+}
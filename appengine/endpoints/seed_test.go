@@ -25,9 +25,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
 	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/log"
 	"google.golang.org/appengine/user"
 )
 
@@ -46,10 +49,20 @@ func TestValidateSeedRequestSuccess(t *testing.T) {
 			user.User{Email: "test@googleplex.com"},
 			models.SeedRequest{Hash: []byte("00000000000000000000000000000000")},
 		},
+		{
+			"valid sha512 request",
+			user.User{Email: "test@googleplex.com"},
+			models.SeedRequest{Hash: []byte("00000000000000000000000000000000"), Algorithm: models.HashSHA512},
+		},
+		{
+			"valid request with fields",
+			user.User{Email: "test@googleplex.com"},
+			models.SeedRequest{Hash: []byte("00000000000000000000000000000000"), Fields: map[string]string{"cost_center": "1234"}},
+		},
 	}
 	for _, tt := range testGood {
 		ah := make(map[string]bool)
-		ah[hex.EncodeToString(tt.req.Hash)] = true
+		ah[allowlistKey(algorithmOf(tt.req.Algorithm), tt.req.Hash)] = true
 		err := validateSeedRequest(&tt.u, tt.req, ah)
 		if err != nil {
 			t.Errorf("%s: validateSeedRequest returned: %s; expected nil", tt.desc, err)
@@ -76,12 +89,24 @@ func TestValidateSeedRequestFailure(t *testing.T) {
 			models.SeedRequest{Hash: []byte("0000000000000000000000000000000000")},
 			"not in allowlist",
 		},
+		{
+			"hash listed for another algorithm",
+			user.User{Email: "test@googleplex.com"},
+			models.SeedRequest{Hash: []byte("00000000000000000000000000000000"), Algorithm: models.HashSHA512},
+			"not in allowlist",
+		},
 		{
 			"no user",
 			user.User{},
 			models.SeedRequest{Hash: []byte("00000000000000000000000000000000")},
 			"no username detected",
 		},
+		{
+			"field too long",
+			user.User{Email: "test@googleplex.com"},
+			models.SeedRequest{Hash: []byte("00000000000000000000000000000000"), Fields: map[string]string{"site": strings.Repeat("a", models.MaxSeedFieldValue+1)}},
+			"longer than",
+		},
 	}
 	ah := make(map[string]bool)
 	ah[hex.EncodeToString([]byte("00000000000000000000000000000000"))] = true
@@ -96,6 +121,109 @@ func TestValidateSeedRequestFailure(t *testing.T) {
 	}
 }
 
+func TestAcceptedAlgorithms(t *testing.T) {
+	tests := []struct {
+		desc    string
+		envVars map[string]string
+		want    []models.HashAlgorithm
+		wantErr bool
+	}{
+		{
+			desc: "not set",
+			want: []models.HashAlgorithm{models.HashSHA256},
+		},
+		{
+			desc:    "preference order",
+			envVars: map[string]string{"HASH_ALGORITHMS": "SHA512, sha256"},
+			want:    []models.HashAlgorithm{models.HashSHA512, models.HashSHA256},
+		},
+		{
+			desc:    "unsupported algorithm",
+			envVars: map[string]string{"HASH_ALGORITHMS": "sha256,md5"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		got, err := acceptedAlgorithms()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: acceptedAlgorithms() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: acceptedAlgorithms() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestValidAlgorithm(t *testing.T) {
+	accepted := []models.HashAlgorithm{models.HashSHA512, models.HashSHA256}
+	tests := []struct {
+		desc    string
+		alg     models.HashAlgorithm
+		size    int
+		wantErr bool
+	}{
+		{
+			desc: "sha256",
+			alg:  models.HashSHA256,
+			size: 32,
+		},
+		{
+			desc: "sha512",
+			alg:  models.HashSHA512,
+			size: 64,
+		},
+		{
+			desc:    "wrong size",
+			alg:     models.HashSHA512,
+			size:    32,
+			wantErr: true,
+		},
+		{
+			desc:    "not accepted",
+			alg:     models.HashAlgorithm("md5"),
+			size:    16,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		err := validAlgorithm(tt.alg, make([]byte, tt.size), accepted)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validAlgorithm() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllowlistKey(t *testing.T) {
+	tests := []struct {
+		desc string
+		alg  models.HashAlgorithm
+		want string
+	}{
+		{
+			desc: "sha256",
+			alg:  models.HashSHA256,
+			want: "beef",
+		},
+		{
+			desc: "sha512",
+			alg:  models.HashSHA512,
+			want: "sha512:beef",
+		},
+	}
+	for _, tt := range tests {
+		if got := allowlistKey(tt.alg, []byte{0xbe, 0xef}); got != tt.want {
+			t.Errorf("%s: allowlistKey() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestUnmarshalSeedRequestSuccess(t *testing.T) {
 	testGood := []struct {
 		desc string
@@ -193,7 +321,7 @@ func generateTestSeedRequest() ([]byte, error) {
 	if err != nil {
 		return []byte(nil), fmt.Errorf("could not create test hash prepTestHash returned: %v", err)
 	}
-	sr := models.SeedRequest{Hash: h}
+	sr := models.SeedRequest{Hash: h, Hostname: "station-1", OS: "linux", Version: "1.2.3"}
 	return json.Marshal(sr)
 }
 
@@ -269,3 +397,108 @@ func serveHTTPFailSign(t *testing.T, inst aetest.Instance) (*httptest.ResponseRe
 
 	return serveHTTPValid(t, inst)
 }
+
+func TestSeedValidity(t *testing.T) {
+	tests := []struct {
+		desc    string
+		envVars map[string]string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			desc:    "not set",
+			wantErr: true,
+		},
+		{
+			desc:    "invalid duration",
+			envVars: map[string]string{"SEED_VALIDITY_DURATION": "one day"},
+			wantErr: true,
+		},
+		{
+			desc:    "valid duration",
+			envVars: map[string]string{"SEED_VALIDITY_DURATION": "24h"},
+			want:    24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		got, err := seedValidity()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: seedValidity() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: seedValidity() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
+
+func TestAllowlistPath(t *testing.T) {
+	appID = func(context.Context) string { return "fresnel-staging" }
+	defer func() { appID = appengine.AppID }()
+	// The App Engine logger requires an App Engine context.
+	var warnings []string
+	logWarningf = func(_ context.Context, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	defer func() { logWarningf = log.Warningf }()
+
+	tests := []struct {
+		desc     string
+		envVars  map[string]string
+		want     string
+		wantErr  bool
+		wantWarn bool
+	}{
+		{
+			desc: "no environment",
+			want: "appengine_config/pe_allowlist.yaml",
+		},
+		{
+			desc:    "explicit environment",
+			envVars: map[string]string{"ENVIRONMENT": "prod"},
+			want:    "appengine_config/prod/pe_allowlist.yaml",
+		},
+		{
+			desc:    "environment from project",
+			envVars: map[string]string{"ENVIRONMENT_PROJECTS": "fresnel-prod=prod, fresnel-staging=staging"},
+			want:    "appengine_config/staging/pe_allowlist.yaml",
+		},
+		{
+			desc:     "unlisted project",
+			envVars:  map[string]string{"ENVIRONMENT_PROJECTS": "fresnel-prod=prod"},
+			want:     "appengine_config/pe_allowlist.yaml",
+			wantWarn: true,
+		},
+		{
+			desc:    "invalid environment",
+			envVars: map[string]string{"ENVIRONMENT": "../prod"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		warnings = nil
+		got, err := allowlistPath(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: allowlistPath() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if (len(warnings) > 0) != tt.wantWarn {
+			t.Errorf("%s: allowlistPath() warnings: %q, want warning: %t", tt.desc, warnings, tt.wantWarn)
+		}
+		if got != tt.want {
+			t.Errorf("%s: allowlistPath() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
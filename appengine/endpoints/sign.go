@@ -17,6 +17,7 @@
 package endpoints
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/rsa"
@@ -32,6 +33,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/fresnel/models"
@@ -46,6 +48,16 @@ var (
 	c                = cache.New(5*time.Minute, 90*time.Minute)
 	macRegEx         = "([^0-9,a-f,A-F,:])"	
 	bucketFileFinder = bucketFileHandle
+	objectAttrs      = bucketObjectAttrs
+
+	// sharedClient is the Cloud Storage client reused by every request. It is
+	// created on first use by storageClient.
+	sharedClient *storage.Client
+	clientMu     sync.Mutex
+
+	// Wrapped errors for testing.
+	errObjectChanged  = errors.New("object does not match the pinned version")
+	errObjectNotFound = errors.New("no such object")
 )
 
 // SignRequestHandler implements http.Handler for signed URL requests.
@@ -54,13 +66,18 @@ type SignRequestHandler struct{}
 func (SignRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	errResp := `{"Status":"%s","ErrorCode":%d}`
 
+	r, cancel := withDeadline(r)
+	defer cancel()
 	ctx := appengine.NewContext(r)
 	w.Header().Set("Content-Type", "application/json")
 
 	resp := signResponse(ctx, r)
 
+	if resp.ErrorCode == models.StatusMaintenance {
+		setRetryAfter(w, resp.MaintenanceEnd)
+	}
 	if resp.ErrorCode != models.StatusSuccess {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(httpStatus(resp.ErrorCode))
 	}
 
 	jsonResponse, err := json.Marshal(resp)
@@ -81,6 +98,21 @@ func (SignRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // signResponse processes a signed URL request and provides a valid response to the client.
 func signResponse(ctx context.Context, r *http.Request) models.SignResponse {
+	logClient(ctx, r)
+	m, err := maintenanceMode()
+	if err != nil {
+		log.Errorf(ctx, "maintenanceMode(): %v", err)
+	}
+	if m != nil {
+		log.Warningf(ctx, "refusing sign request during maintenance: %s", m.message)
+		return models.SignResponse{Status: m.message, ErrorCode: models.StatusMaintenance, MaintenanceEnd: m.end}
+	}
+	// Installers do not identify a version, so only clients that do are checked.
+	if err := checkClientVersion(r, false); err != nil {
+		log.Warningf(ctx, "checkClientVersion(): %v", err)
+		return models.SignResponse{Status: err.Error(), ErrorCode: models.StatusClientTooOld}
+	}
+
 	bucket := os.Getenv("BUCKET")
 	if bucket == "" {
 		log.Errorf(ctx, "BUCKET environment variable not set for %v", ctx)
@@ -106,6 +138,7 @@ func signResponse(ctx context.Context, r *http.Request) models.SignResponse {
 
 	if resp.ErrorCode == models.StatusSuccess {
 		log.Infof(ctx, "successfully processed SignRequest for seed issued to %#v at:%#v Response: %q", req.Seed.Username, req.Seed.Issued, resp.SignedURL)
+		recordDownload(ctx, req, resp)
 	}
 	return resp
 }
@@ -129,7 +162,25 @@ func ProcessSignRequest(ctx context.Context, r *http.Request, bucket string, dur
 		}, req
 	}
 
-	url, err := signedURL(ctx, bucket, req.Path, duration)
+	attrs, err := validObject(ctx, bucket, req)
+	if err != nil {
+		// Only a mismatch with the pinned object is a failed precondition;
+		// failing to read the metadata is not the client's to resolve.
+		code := models.StatusSignError
+		switch {
+		case errors.Is(err, errObjectNotFound):
+			code = models.StatusObjectNotFound
+		case errors.Is(err, errObjectChanged):
+			code = models.StatusObjectChanged
+		}
+		return models.SignResponse{
+			Status:    err.Error(),
+			ErrorCode: code,
+		}, req
+	}
+
+	expires := time.Now().Add(duration)
+	url, err := signURL(ctx, bucket, req.Path, expires)
 	if err != nil {
 		return models.SignResponse{
 			Status:    err.Error(),
@@ -137,22 +188,29 @@ func ProcessSignRequest(ctx context.Context, r *http.Request, bucket string, dur
 		}, req
 	}
 
-	return models.SignResponse{
+	resp := models.SignResponse{
 		Status:    "Success",
 		ErrorCode: models.StatusSuccess,
 		SignedURL: url,
-	}, req
+		Expires:   expires,
+	}
+	if attrs != nil {
+		resp.Size = attrs.Size
+		resp.MD5 = attrs.MD5
+		resp.CRC32C = attrs.CRC32C
+	}
+	return resp, req
 }
 
 // unmarshalSignRequest takes an incoming request, returning a models.SignRequest and
 // and a models.StatusCode code representing whether it was read successfully.
 func unmarshalSignRequest(r *http.Request) (models.SignRequest, models.StatusCode, error) {
 	var signRequest models.SignRequest
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
 		return models.SignRequest{},
-			models.StatusReqUnreadable,
-			errors.New("unable to read HTTP request body")
+			limitStatus(err, models.StatusReqUnreadable),
+			fmt.Errorf("unable to read HTTP request body: %w", err)
 	}
 
 	if len(body) == 0 {
@@ -196,7 +254,7 @@ func validSignRequest(ctx context.Context, sr models.SignRequest) error {
 	if hashCheck != "true" {
 		log.Infof(ctx, "VERIFY_SIGN_HASH is not set to true, hash validation will be logged but not enforced")
 	}
-	err := validSignHash(ctx, sr.Hash)
+	err := validSignHash(ctx, algorithmOf(sr.Algorithm), sr.Hash)
 	if err != nil {
 		log.Warningf(ctx, "failed to validate sign request hash: %v", err)
 	}
@@ -218,9 +276,9 @@ func validSignRequest(ctx context.Context, sr models.SignRequest) error {
 }
 
 // validSignHash takes the current context and the hash submitted with the sign
-// request and determines if the submitted hash is in a list of acceptable hashes
-// which is stored in a cloud bucket.
-func validSignHash(ctx context.Context, requestHash []byte) error {
+// request, computed using alg, and determines if the submitted hash is in a list
+// of acceptable hashes which is stored in a cloud bucket.
+func validSignHash(ctx context.Context, alg models.HashAlgorithm, requestHash []byte) error {
 	b := os.Getenv("BUCKET")
 	if b == "" {
 		return fmt.Errorf("BUCKET environment variable not set for %v", ctx)
@@ -231,7 +289,7 @@ func validSignHash(ctx context.Context, requestHash []byte) error {
 	}
 	log.Infof(ctx, "retrieved acceptable hashes: %#v", acceptedHashes)
 
-	h := hex.EncodeToString(requestHash)
+	h := allowlistKey(alg, requestHash)
 	if _, ok := acceptedHashes[h]; ok {
 		log.Infof(ctx, "%v passed validation", h)
 		return nil
@@ -257,13 +315,9 @@ func validSeed(ctx context.Context, seed models.Seed, sig []byte) error {
 	}
 
 	// Check that the seed is not expired or invalid.
-	validityPeriod := os.Getenv("SEED_VALIDITY_DURATION")
-	if validityPeriod == "" {
-		return errors.New("SEED_VALIDITY_DURATION environment variable is not present")
-	}
-	d, err := time.ParseDuration(validityPeriod)
+	d, err := seedValidity()
 	if err != nil {
-		return fmt.Errorf("time.parseDuration(%s): %v", validityPeriod, err)
+		return err
 	}
 	expires := seed.Issued.Add(d)
 	now := time.Now()
@@ -291,40 +345,40 @@ func validSeed(ctx context.Context, seed models.Seed, sig []byte) error {
 func validSeedSignature(ctx context.Context, seed models.Seed, sig []byte) error {
 	// Check the seed signature using the App Identity.
 	// https://cloud.google.com/appengine/docs/standard/go/appidentity/
-	certs, err := appengine.PublicCertificates(ctx)
+	certs, err := publicCertificates(ctx)
 	if err != nil {
 		return fmt.Errorf("appengine.PublicCertificates(%+v): %v", ctx, err)
 	}
 
 	enableFallback := os.Getenv("VERIFY_SEED_SIGNATURE_FALLBACK")
 	if enableFallback == "true" {
-		log.Infof(ctx, "VERIFY_SEED_SIGNATURE_FALLBACK=%s, adding certificates from seed for fallback verification", enableFallback)
+		logInfof(ctx, "VERIFY_SEED_SIGNATURE_FALLBACK=%s, adding certificates from seed for fallback verification", enableFallback)
 		certs = append(certs, seed.Certs...)
 	}
 
-	log.Infof(ctx, "attempting signature verification using %d certs", len(certs))
+	logInfof(ctx, "attempting signature verification using %d certs", len(certs))
 	for _, cert := range certs {
 		block, _ := pem.Decode(cert.Data)
 		if block == nil {
-			log.Infof(ctx, "pem.Decode returned an empty block for data %q.", cert.Data)
+			logInfof(ctx, "pem.Decode returned an empty block for data %q.", cert.Data)
 			continue
 		}
 
 		x509Cert, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
-			log.Infof(ctx, "x509.ParseCertificate(%s): %v.", block.Bytes, err)
+			logInfof(ctx, "x509.ParseCertificate(%s): %v.", block.Bytes, err)
 			continue
 		}
 
 		pubkey, ok := x509Cert.PublicKey.(*rsa.PublicKey)
 		if !ok {
-			log.Infof(ctx, "certificate '%v' issued by '%v' is does not contain an RSA public key.", x509Cert.Subject, x509Cert.Issuer)
+			logInfof(ctx, "certificate '%v' issued by '%v' is does not contain an RSA public key.", x509Cert.Subject, x509Cert.Issuer)
 			continue
 		}
 
 		jsonSeed, err := json.Marshal(seed)
 		if err != nil {
-			log.Warningf(ctx, "failed to marshal seed for signature verification: %v", err)
+			logWarningf(ctx, "failed to marshal seed for signature verification: %v", err)
 			continue
 		}
 		seedHash := crypto.SHA256
@@ -332,22 +386,53 @@ func validSeedSignature(ctx context.Context, seed models.Seed, sig []byte) error
 		h.Write(jsonSeed)
 		hashed := h.Sum(nil)
 		if err := rsa.VerifyPKCS1v15(pubkey, seedHash, hashed, sig); err != nil {
-			log.Infof(ctx, "unable to verify seed %#v with signature %q using certificate '%#v'", seed, sig, x509Cert.Subject)
+			logInfof(ctx, "unable to verify seed %#v with signature %q using certificate '%#v'", seed, sig, x509Cert.Subject)
 			continue
 		}
 
-		log.Infof(ctx, "successfully verified signature using certificate '%#v'", x509Cert.Subject)
+		logInfof(ctx, "successfully verified signature using certificate '%#v'", x509Cert.Subject)
 		return nil
 	}
 
 	return fmt.Errorf("unable to verify signature for seed issued on '%v' to %s", seed.Issued, seed.Username)
 }
 
+// validObject obtains the metadata for the object at the requested path.
+// It confirms that the object exists when VERIFY_OBJECT_EXISTS is enabled,
+// and that it still matches the generation and/or MD5 digest supplied with
+// the sign request. This ensures that clients are not handed signed URLs that
+// will always fail, and that an object replaced after the client validated it
+// is never signed. When no check is required, failing to obtain metadata is
+// not an error, and nil metadata is returned.
+func validObject(ctx context.Context, bucket string, sr models.SignRequest) (*storage.ObjectAttrs, error) {
+	pinned := sr.Generation != 0 || len(sr.MD5) > 0
+	required := pinned || os.Getenv("VERIFY_OBJECT_EXISTS") == "true"
+	attrs, err := objectAttrs(ctx, bucket, sr.Path)
+	if err != nil {
+		if !required {
+			logWarningf(ctx, "unable to obtain metadata for %s: %v", sr.Path, err)
+			return nil, nil
+		}
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("%w: %s", errObjectNotFound, sr.Path)
+		}
+		return nil, fmt.Errorf("objectAttrs(%s, %s): %v", bucket, sr.Path, err)
+	}
+	if sr.Generation != 0 && attrs.Generation != sr.Generation {
+		return nil, fmt.Errorf("%w: %s generation is %d, requested %d", errObjectChanged, sr.Path, attrs.Generation, sr.Generation)
+	}
+	if len(sr.MD5) > 0 && !bytes.Equal(attrs.MD5, sr.MD5) {
+		return nil, fmt.Errorf("%w: %s md5 is %x, requested %x", errObjectChanged, sr.Path, attrs.MD5, sr.MD5)
+	}
+	return attrs, nil
+}
+
 // signedURL takes a bucket name and relative file path, and returns an
-// equivalent signed URL using the appengine built-in service account.
+// equivalent signed URL that is valid until expires using the appengine
+// built-in service account.
 // https://cloud.google.com/appengine/docs/standard/go/appidentity/
-func signedURL(ctx context.Context, bucket, file string, duration time.Duration) (string, error) {
-	sa, err := appengine.ServiceAccount(ctx)
+func signedURL(ctx context.Context, bucket, file string, expires time.Time) (string, error) {
+	sa, err := serviceAccount(ctx)
 	if err != nil {
 		return "", fmt.Errorf("appengine.ServiceAccount: %v", err)
 	}
@@ -355,17 +440,34 @@ func signedURL(ctx context.Context, bucket, file string, duration time.Duration)
 	return storage.SignedURL(bucket, file, &storage.SignedURLOptions{
 		GoogleAccessID: sa,
 		SignBytes: func(b []byte) ([]byte, error) {
-			_, sig, err := appengine.SignBytes(ctx, b)
+			_, sig, err := signBytes(ctx, b)
 			return sig, err
 		},
 		Method:  "GET",
-		Expires: time.Now().Add(time.Minute * duration),
+		Expires: expires,
 	})
 }
 
 // getAllowlist returns a map of hashes and whether they are acceptable.
 func getAllowlist(ctx context.Context, b string, f string) (map[string]bool, error) {
-	log.Infof(ctx, "reading acceptable hashes from cloud bucket")
+	wls, err := allowlistEntries(ctx, b, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// SHA-256 entries may optionally be prefixed with the algorithm, and are
+	// stored without it to match entries that predate other algorithms.
+	mwl := make(map[string]bool)
+	for _, e := range wls {
+		mwl[strings.TrimPrefix(strings.ToLower(e), string(models.HashSHA256)+":")] = true
+	}
+	return mwl, nil
+}
+
+// allowlistEntries returns the entries of the allowlist f in bucket b, as
+// they were written.
+func allowlistEntries(ctx context.Context, b string, f string) ([]string, error) {
+	logInfof(ctx, "reading acceptable hashes from cloud bucket")
 	h, err := bucketFileFinder(ctx, b, f)
 	if err != nil {
 		return nil, fmt.Errorf("bucketFileFinder(%s, %s): %v", b, f, err)
@@ -380,20 +482,48 @@ func getAllowlist(ctx context.Context, b string, f string) (map[string]bool, err
 	if err := yaml.Unmarshal(y, &wls); err != nil {
 		return nil, fmt.Errorf("failed parsing allowlist: %v", err)
 	}
+	return wls, nil
+}
 
-	mwl := make(map[string]bool)
-	for _, e := range wls {
-		mwl[strings.ToLower(e)] = true
+// storageClient returns the Cloud Storage client shared by all requests,
+// creating it on first use. Clients are safe for concurrent use and hold
+// connections open, so they are reused rather than created per request.
+func storageClient() (*storage.Client, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if sharedClient == nil {
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cloud storage client: %v", err)
+		}
+		sharedClient = client
 	}
-	return mwl, nil
+	return sharedClient, nil
 }
 
+// bucketFileHandle reads the object f in bucket b, closing the object reader
+// before returning its contents.
 func bucketFileHandle(ctx context.Context, b string, f string) (io.Reader, error) {
-	client, err := storage.NewClient(ctx)
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Bucket(b).Object(f).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func bucketObjectAttrs(ctx context.Context, b string, f string) (*storage.ObjectAttrs, error) {
+	client, err := storageClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cloud storage client: %v", err)
+		return nil, err
 	}
-	bh := client.Bucket(b)
-	fh := bh.Object(f)
-	return fh.NewReader(ctx)
+	return client.Bucket(b).Object(f).Attrs(ctx)
 }
@@ -16,6 +16,7 @@ package endpoints
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -28,6 +29,7 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/google/fresnel/models"
 )
 
@@ -168,3 +170,81 @@ func TestUnmarshalSignRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestValidObject(t *testing.T) {
+	defer discardLogs()()
+	current := &storage.ObjectAttrs{Generation: 42, MD5: []byte{0x01, 0x02}}
+	objectAttrs = func(_ context.Context, _ string, path string) (*storage.ObjectAttrs, error) {
+		if path == "missing.wim" {
+			return nil, storage.ErrObjectNotExist
+		}
+		return current, nil
+	}
+	defer func() { objectAttrs = bucketObjectAttrs }()
+
+	tests := []struct {
+		desc    string
+		envVars map[string]string
+		req     models.SignRequest
+		want    error
+	}{
+		{
+			desc: "missing object without existence check",
+			req:  models.SignRequest{Path: "missing.wim"},
+			want: nil,
+		},
+		{
+			desc:    "missing object",
+			envVars: map[string]string{"VERIFY_OBJECT_EXISTS": "true"},
+			req:     models.SignRequest{Path: "missing.wim"},
+			want:    errObjectNotFound,
+		},
+		{
+			desc:    "existing object",
+			envVars: map[string]string{"VERIFY_OBJECT_EXISTS": "true"},
+			req:     models.SignRequest{Path: "image.wim"},
+			want:    nil,
+		},
+		{
+			desc: "not pinned",
+			req:  models.SignRequest{Path: "image.wim"},
+			want: nil,
+		},
+		{
+			desc: "matching generation",
+			req:  models.SignRequest{Path: "image.wim", Generation: 42},
+			want: nil,
+		},
+		{
+			desc: "replaced object",
+			req:  models.SignRequest{Path: "image.wim", Generation: 41},
+			want: errObjectChanged,
+		},
+		{
+			desc: "matching digest",
+			req:  models.SignRequest{Path: "image.wim", MD5: []byte{0x01, 0x02}},
+			want: nil,
+		},
+		{
+			desc: "mismatched digest",
+			req:  models.SignRequest{Path: "image.wim", Generation: 42, MD5: []byte{0x03}},
+			want: errObjectChanged,
+		},
+	}
+	for _, tt := range tests {
+		cleanup, err := prepEnvVariables(tt.envVars)
+		if err != nil {
+			t.Fatalf("%s: prepEnvVariables() returned %v", tt.desc, err)
+		}
+		attrs, got := validObject(context.Background(), bucket, tt.req)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: validObject() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got == nil && tt.req.Path != "missing.wim" && attrs != current {
+			t.Errorf("%s: validObject() attrs got: %+v, want: %+v", tt.desc, attrs, current)
+		}
+		if err := cleanup(); err != nil {
+			t.Errorf("%s: cleanup() returned %v", tt.desc, err)
+		}
+	}
+}
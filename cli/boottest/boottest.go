@@ -0,0 +1,249 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package boottest boots provisioned media in an emulator to check that its
+// firmware finds and starts a bootloader, catching unbootable media before it
+// is shipped. QEMU is used with OVMF, so that media is booted using UEFI.
+package boottest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+)
+
+var (
+	// Dependency injections for testing.
+	lookPath = exec.LookPath
+	stat     = os.Stat
+	emulate  = runEmulator
+	newVars  = copyVars
+
+	// ErrUnavailable indicates that QEMU or OVMF could not be found, so the
+	// test could not be performed.
+	ErrUnavailable = errors.New("boot test unavailable")
+	// ErrUnbootable indicates that the media did not start a bootloader.
+	ErrUnbootable = errors.New("media is not bootable")
+
+	// emulators are the names of the QEMU binaries that can be used.
+	emulators = []string{"qemu-system-x86_64", `C:\Program Files\qemu\qemu-system-x86_64.exe`}
+
+	// firmware are the locations where OVMF is installed by common packages
+	// of QEMU and EDK II.
+	firmware = []ovmf{
+		{code: "/usr/share/OVMF/OVMF_CODE.fd", vars: "/usr/share/OVMF/OVMF_VARS.fd"},
+		{code: "/usr/share/ovmf/OVMF.fd"},
+		{code: "/usr/share/edk2/ovmf/OVMF_CODE.fd", vars: "/usr/share/edk2/ovmf/OVMF_VARS.fd"},
+		{code: "/usr/share/qemu/OVMF.fd"},
+		{code: "/usr/share/qemu/edk2-x86_64-code.fd", vars: "/usr/share/qemu/edk2-i386-vars.fd"},
+		{code: "/opt/homebrew/share/qemu/edk2-x86_64-code.fd", vars: "/opt/homebrew/share/qemu/edk2-i386-vars.fd"},
+		{code: "/usr/local/share/qemu/edk2-x86_64-code.fd", vars: "/usr/local/share/qemu/edk2-i386-vars.fd"},
+		{code: `C:\Program Files\qemu\share\edk2-x86_64-code.fd`, vars: `C:\Program Files\qemu\share\edk2-i386-vars.fd`},
+	}
+)
+
+// ovmf is an installation of OVMF. Most packages split the firmware into an
+// image of its code and a template of its variable store, which QEMU cannot
+// load with -bios, and both are then attached as flash instead.
+type ovmf struct {
+	code string
+	vars string // The template of the variable store, empty for images that include it.
+}
+
+const (
+	// started is logged by OVMF when it hands off to a boot option.
+	started = "BdsDxe: starting Boot"
+	// failed is logged by OVMF when a boot option cannot be loaded.
+	failed = "BdsDxe: failed to load Boot"
+	// shell is the description of the boot option that OVMF falls back to
+	// when no media can be booted.
+	shell = "EFI Internal Shell"
+)
+
+// Run boots the disk at path, which is opened read-only, and waits up to d for
+// the firmware to start a bootloader from it. The emulator is stopped as soon
+// as a bootloader starts, and nothing that it writes reaches the disk. Run
+// returns ErrUnavailable when QEMU or OVMF cannot be found.
+func Run(ctx context.Context, path string, d time.Duration) (err error) {
+	qemu, err := find(emulators, lookPath)
+	if err != nil {
+		return fmt.Errorf("%w: QEMU was not found: %v", ErrUnavailable, err)
+	}
+	fw, err := findFirmware(firmware)
+	if err != nil {
+		return fmt.Errorf("%w: OVMF was not found: %v", ErrUnavailable, err)
+	}
+	// The firmware writes to its variable store while booting, so a copy of
+	// the template is attached.
+	if fw.vars != "" {
+		vars, err := newVars(fw.vars)
+		if err != nil {
+			return fmt.Errorf("%w: copying the OVMF variable store %q returned %v", ErrUnavailable, fw.vars, err)
+		}
+		defer os.Remove(vars)
+		fw.vars = vars
+	}
+	// The disk is read directly, so the contents written through its file
+	// systems must be on the disk first.
+	if err := flush(); err != nil {
+		return fmt.Errorf("flushing writes to %q returned %v", path, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	deck.InfofA("Boot testing %q using %q and %q.", path, qemu, fw.code).With(deck.V(2)).Go()
+	out, wait, err := emulate(ctx, qemu, args(path, fw))
+	if err != nil {
+		return fmt.Errorf("%w: starting %q returned %v", ErrUnavailable, qemu, err)
+	}
+	// Stop the emulator once the result is known, ignoring the error that is
+	// caused by stopping it.
+	defer func() {
+		cancel()
+		out.Close()
+		wait()
+	}()
+	return check(out, d)
+}
+
+// find returns the first of candidates that exists according to exists.
+func find(candidates []string, exists func(string) (string, error)) (string, error) {
+	var errs []string
+	for _, c := range candidates {
+		p, err := exists(c)
+		if err == nil {
+			return p, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", errors.New(strings.Join(errs, "; "))
+}
+
+// findFirmware returns the first of candidates whose files all exist.
+func findFirmware(candidates []ovmf) (ovmf, error) {
+	var errs []string
+	for _, c := range candidates {
+		_, err := stat(c.code)
+		if err == nil && c.vars != "" {
+			_, err = stat(c.vars)
+		}
+		if err == nil {
+			return c, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return ovmf{}, errors.New(strings.Join(errs, "; "))
+}
+
+// copyVars copies the variable store template at path to a temporary file,
+// and returns the path of the copy.
+func copyVars(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := ioutil.TempFile("", "OVMF_VARS-*.fd")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// escape escapes the commas in path, which separate the options of -drive.
+func escape(path string) string {
+	return strings.ReplaceAll(path, ",", ",,")
+}
+
+// args returns the QEMU arguments that boot the disk at path using the
+// firmware fw, with the firmware console on standard output. Only the disk
+// is attached, so that the firmware cannot boot from anything else. Writes are
+// kept in a temporary snapshot rather than made to the disk.
+func args(path string, fw ovmf) []string {
+	a := []string{
+		"-machine", "q35",
+		"-m", "2048",
+		"-nodefaults",
+		"-display", "none",
+		"-serial", "stdio",
+		"-nic", "none",
+		"-no-reboot",
+	}
+	if fw.vars == "" {
+		a = append(a, "-bios", fw.code)
+	} else {
+		a = append(a,
+			"-drive", fmt.Sprintf("if=pflash,format=raw,unit=0,readonly=on,file=%s", escape(fw.code)),
+			"-drive", fmt.Sprintf("if=pflash,format=raw,unit=1,file=%s", escape(fw.vars)),
+		)
+	}
+	return append(a, "-drive", fmt.Sprintf("file=%s,format=raw,media=disk,snapshot=on", escape(path)))
+}
+
+// check reads the firmware console from out until a boot option is started
+// or the output ends, which happens when the emulator stops or d elapses.
+func check(out io.Reader, d time.Duration) error {
+	var failures []string
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		deck.InfofA("boot test: %s", line).With(deck.V(4)).Go()
+		switch {
+		case strings.Contains(line, started) && strings.Contains(line, shell):
+			return fmt.Errorf("%w: the firmware fell back to the UEFI shell: %s", ErrUnbootable, strings.Join(failures, "; "))
+		case strings.Contains(line, started):
+			deck.InfofA("Boot test passed: %s", line).With(deck.V(2)).Go()
+			return nil
+		case strings.Contains(line, failed):
+			failures = append(failures, line)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnbootable, strings.Join(failures, "; "))
+	}
+	return fmt.Errorf("%w: no bootloader was started within %v", ErrUnbootable, d)
+}
+
+// runEmulator starts name with args and returns its standard output, which is
+// closed when the process exits, and a function that waits for it to exit.
+// The process is killed when ctx is done.
+func runEmulator(ctx context.Context, name string, args []string) (io.ReadCloser, func() error, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return out, cmd.Wait, nil
+}
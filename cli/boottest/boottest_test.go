@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boottest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	bootOutput = "BdsDxe: loading Boot0001 \"UEFI QEMU HARDDISK QM00001 \" from PciRoot(0x0)/Pci(0x1F,0x2)\r\n" +
+		"BdsDxe: starting Boot0001 \"UEFI QEMU HARDDISK QM00001 \" from PciRoot(0x0)/Pci(0x1F,0x2)\r\n"
+	shellOutput = "BdsDxe: failed to load Boot0001 \"UEFI QEMU HARDDISK QM00001 \" from PciRoot(0x0)/Pci(0x1F,0x2): Not Found\r\n" +
+		"BdsDxe: loading Boot0002 \"EFI Internal Shell\" from Fv(7CB8BDC9-F8EB-4F34-AAEA-3EE4AF6516A1)\r\n" +
+		"BdsDxe: starting Boot0002 \"EFI Internal Shell\" from Fv(7CB8BDC9-F8EB-4F34-AAEA-3EE4AF6516A1)\r\n"
+	failedOutput = "BdsDxe: failed to load Boot0001 \"UEFI QEMU HARDDISK QM00001 \" from PciRoot(0x0)/Pci(0x1F,0x2): Not Found\r\n"
+)
+
+func TestRun(t *testing.T) {
+	defer func() {
+		lookPath = exec.LookPath
+		stat = os.Stat
+		emulate = runEmulator
+		newVars = copyVars
+	}()
+	found := func(p string) (string, error) { return p, nil }
+	tests := []struct {
+		desc       string
+		lookPath   func(string) (string, error)
+		statErr    error
+		varsErr    error
+		emulateErr error
+		output     string
+		want       error
+	}{
+		{
+			desc:     "no qemu",
+			lookPath: func(string) (string, error) { return "", errors.New("not found") },
+			want:     ErrUnavailable,
+		},
+		{
+			desc:     "no firmware",
+			lookPath: found,
+			statErr:  os.ErrNotExist,
+			want:     ErrUnavailable,
+		},
+		{
+			desc:     "variable store error",
+			lookPath: found,
+			varsErr:  errors.New("error"),
+			want:     ErrUnavailable,
+		},
+		{
+			desc:       "emulator error",
+			lookPath:   found,
+			emulateErr: errors.New("error"),
+			want:       ErrUnavailable,
+		},
+		{
+			desc:     "boots",
+			lookPath: found,
+			output:   bootOutput,
+		},
+		{
+			desc:     "falls back to shell",
+			lookPath: found,
+			output:   shellOutput,
+			want:     ErrUnbootable,
+		},
+		{
+			desc:     "fails to load",
+			lookPath: found,
+			output:   failedOutput,
+			want:     ErrUnbootable,
+		},
+		{
+			desc:     "no output",
+			lookPath: found,
+			want:     ErrUnbootable,
+		},
+	}
+	for _, tt := range tests {
+		lookPath = tt.lookPath
+		statErr := tt.statErr
+		stat = func(string) (os.FileInfo, error) { return nil, statErr }
+		varsErr := tt.varsErr
+		newVars = func(string) (string, error) { return filepath.Join(t.TempDir(), "vars.fd"), varsErr }
+		var gotArgs []string
+		output, emulateErr := tt.output, tt.emulateErr
+		emulate = func(_ context.Context, _ string, args []string) (io.ReadCloser, func() error, error) {
+			gotArgs = args
+			return ioutil.NopCloser(strings.NewReader(output)), func() error { return nil }, emulateErr
+		}
+		got := Run(context.Background(), "/dev/sdz", time.Second)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Run() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if gotArgs != nil && !strings.Contains(strings.Join(gotArgs, " "), "file=/dev/sdz,format=raw,media=disk,snapshot=on") {
+			t.Errorf("%s: Run() started the emulator with %v, want the disk attached as a snapshot", tt.desc, gotArgs)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		desc   string
+		fw     ovmf
+		want   []string
+		absent []string
+	}{
+		{
+			desc:   "combined image",
+			fw:     ovmf{code: "ovmf.fd"},
+			want:   []string{"-bios ovmf.fd", `file=\\.\PhysicalDrive1,,2,format=raw`, "-nodefaults", "-nic none"},
+			absent: []string{"pflash"},
+		},
+		{
+			desc: "code and variables",
+			fw:   ovmf{code: "OVMF_CODE.fd", vars: "vars,1.fd"},
+			want: []string{
+				"if=pflash,format=raw,unit=0,readonly=on,file=OVMF_CODE.fd",
+				"if=pflash,format=raw,unit=1,file=vars,,1.fd",
+				`file=\\.\PhysicalDrive1,,2,format=raw`,
+			},
+			absent: []string{"-bios"},
+		},
+	}
+	for _, tt := range tests {
+		got := strings.Join(args(`\\.\PhysicalDrive1,2`, tt.fw), " ")
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: args() got: %q, want contains: %q", tt.desc, got, want)
+			}
+		}
+		for _, absent := range tt.absent {
+			if strings.Contains(got, absent) {
+				t.Errorf("%s: args() got: %q, want no %q", tt.desc, got, absent)
+			}
+		}
+	}
+}
+
+func TestFindFirmware(t *testing.T) {
+	defer func() { stat = os.Stat }()
+	dir := t.TempDir()
+	for _, name := range []string{"OVMF.fd", "OVMF_CODE.fd"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("firmware"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", name, err)
+		}
+	}
+	stat = os.Stat
+	codeOnly := ovmf{code: filepath.Join(dir, "OVMF_CODE.fd"), vars: filepath.Join(dir, "OVMF_VARS.fd")}
+	combined := ovmf{code: filepath.Join(dir, "OVMF.fd")}
+	got, err := findFirmware([]ovmf{codeOnly, combined})
+	if err != nil || got != combined {
+		t.Errorf("findFirmware() got: (%v, %v), want: (%v, nil), skipping code without variables", got, err, combined)
+	}
+	if _, err := findFirmware([]ovmf{codeOnly}); err == nil {
+		t.Errorf("findFirmware() returned nil, want error for code without variables")
+	}
+}
+
+func TestCopyVars(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "OVMF_VARS.fd")
+	if err := ioutil.WriteFile(template, []byte("variables"), 0444); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", template, err)
+	}
+	got, err := copyVars(template)
+	if err != nil {
+		t.Fatalf("copyVars(%q) returned %v", template, err)
+	}
+	defer os.Remove(got)
+	b, err := ioutil.ReadFile(got)
+	if err != nil || string(b) != "variables" {
+		t.Errorf("copyVars(%q) copied (%q, %v), want: %q", template, b, err, "variables")
+	}
+	f, err := os.OpenFile(got, os.O_WRONLY, 0)
+	if err != nil {
+		t.Errorf("copyVars(%q) returned a copy that cannot be written: %v", template, err)
+	} else {
+		f.Close()
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture implements the capture subcommand, which archives the
+// contents of a provisioned device so that field failures can be reproduced.
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flag"
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errCapture = errors.New("capture error")
+	errDevice  = errors.New("device error")
+	errFile    = errors.New("file error")
+	errSearch  = errors.New("search error")
+
+	// Dependency injections for testing.
+	search  = storageSearch
+	capture = installer.Capture
+	now     = time.Now
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&captureCmd{}, "")
+}
+
+// captureCmd is the capture subcommand, which archives the contents of a
+// device, such as one that fails to boot in the field, for troubleshooting.
+type captureCmd struct {
+	// mode is what is captured: installer.CaptureBlock or
+	// installer.CaptureFiles.
+	mode string
+
+	// output is the path that the capture is written to. It defaults to a
+	// name derived from the device and the time of the capture.
+	output string
+
+	// note is recorded in the metadata of the capture, such as a ticket or a
+	// description of the failure.
+	note string
+}
+
+// Ensure captureCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*captureCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *captureCmd) Name() string {
+	return "capture"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*captureCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *captureCmd) Synopsis() string {
+	return "Archive the contents of a device for troubleshooting"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *captureCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [device]
+
+Archive the contents of a provisioned device into a gzip compressed tar file,
+so that engineering can examine or reproduce a device that fails in the field.
+In block mode every byte of the device is captured, and the capture can be
+written to lab media with the 'restore' command. In files mode only the files
+on its FAT32 and NTFS partitions are captured, which is smaller, and can be
+extracted with tar. Each capture includes metadata.json, which describes the
+device, the capture and the build of %s that made it. Nothing is written to
+the device. This operation requires permission to read from devices, such as
+'sudo' on Linux/Mac or 'run as administrator' on Windows.
+
+Flags:
+  --mode   - What to capture: 'block' (default) or 'files'.
+  --output - The file to write the capture to. Defaults to [device]-[time].tar.gz.
+  --note   - A note recorded in the metadata, such as a ticket or the failure seen.
+
+Example: 'capture every byte of device sdc for ticket 1234'
+  - '%s capture --note="ticket 1234: no boot device found" --output=sdc.tar.gz sdc'
+
+Defaults:
+`, c.Name(), binaryName, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *captureCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.mode, "mode", installer.CaptureBlock, fmt.Sprintf("what to capture, %q or %q", installer.CaptureBlock, installer.CaptureFiles))
+	f.StringVar(&c.output, "output", "", "the file to write the capture to, defaults to [device]-[time].tar.gz")
+	f.StringVar(&c.note, "note", "", "a note recorded in the metadata of the capture, such as a ticket or the failure seen")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *captureCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		console.Printf("A single device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if c.mode != installer.CaptureBlock && c.mode != installer.CaptureFiles {
+		console.Printf("--mode must be %q or %q.\nusage: %s %s\n", installer.CaptureBlock, installer.CaptureFiles, binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(f.Arg(0)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run captures the device with the identifier id to the output file. A
+// capture that does not complete is removed, so that it is not mistaken for a
+// complete one.
+func (c *captureCmd) run(id string) (err error) {
+	devices, err := search(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSearch, err)
+	}
+	var d installer.Device
+	for _, dev := range devices {
+		if dev.Identifier() == id {
+			d = dev
+		}
+	}
+	if d == nil {
+		return fmt.Errorf("%w: device %q was not found, use the 'list' command to list available devices", errDevice, id)
+	}
+	path := c.output
+	if path == "" {
+		path = fmt.Sprintf("%s-%s.tar.gz", id, now().Format("20060102-150405"))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errFile, err)
+	}
+	defer func() {
+		if err2 := f.Close(); err2 != nil && err == nil {
+			err = fmt.Errorf("%w: Close() for %q returned %v", errFile, path, err2)
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	console.Printf("Capturing the %s of %q (%s) to %q.", c.mode, id, d.FriendlyName(), path)
+	meta, err := capture(d, c.mode, c.note, f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCapture, err)
+	}
+	console.Printf("Captured %s from %q to %q.", humanize.Bytes(uint64(meta.Bytes)), id, path)
+	deck.InfofA("Captured %q to %q: %+v", id, path, meta).With(deck.V(1)).Go()
+	return nil
+}
+
+// storageSearch wraps storage.Search and returns the devices that match id,
+// including fixed devices, as they are only read from.
+func storageSearch(id string) ([]installer.Device, error) {
+	devices, err := storage.Search(id, 0, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%q) returned %v", id, err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flag"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
+func TestExecute(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		capture = installer.Capture
+		now = time.Now
+	}()
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	dir := t.TempDir()
+	tests := []struct {
+		desc       string
+		args       []string
+		devices    []installer.Device
+		searchErr  error
+		captureErr error
+		wantFile   string
+		want       subcommands.ExitStatus
+	}{
+		{
+			desc: "no device",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "unknown mode",
+			args: []string{"--mode=partitions", "sdb"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"sdb"},
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "not found",
+			args:    []string{"sdb"},
+			devices: []installer.Device{&fakeDevice{id: "sdc"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:       "capture error removes output",
+			args:       []string{"--output=" + filepath.Join(dir, "failed.tar.gz"), "sdb"},
+			devices:    []installer.Device{&fakeDevice{id: "sdb"}},
+			captureErr: errors.New("error"),
+			want:       subcommands.ExitFailure,
+		},
+		{
+			desc:     "success",
+			args:     []string{"--mode=files", "--note=ticket 1234", "--output=" + filepath.Join(dir, "sdb.tar.gz"), "sdb"},
+			devices:  []installer.Device{&fakeDevice{id: "sdc"}, &fakeDevice{id: "sdb"}},
+			wantFile: filepath.Join(dir, "sdb.tar.gz"),
+			want:     subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		c := &captureCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		devices, searchErr, captureErr := tt.devices, tt.searchErr, tt.captureErr
+		search = func(string) ([]installer.Device, error) { return devices, searchErr }
+		var captured, mode, note string
+		capture = func(d installer.Device, m, n string, w io.Writer) (*installer.CaptureMetadata, error) {
+			captured, mode, note = d.Identifier(), m, n
+			if captureErr != nil {
+				return nil, captureErr
+			}
+			_, err := w.Write([]byte("capture"))
+			return &installer.CaptureMetadata{Bytes: 7}, err
+		}
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.captureErr != nil {
+			if _, err := os.Stat(c.output); !os.IsNotExist(err) {
+				t.Errorf("%s: Execute() left %q in place: %v", tt.desc, c.output, err)
+			}
+		}
+		if tt.want != subcommands.ExitSuccess {
+			continue
+		}
+		if captured != "sdb" || mode != installer.CaptureFiles || note != "ticket 1234" {
+			t.Errorf("%s: Execute() captured %q (mode: %q, note: %q), want: %q (mode: %q, note: %q)", tt.desc, captured, mode, note, "sdb", installer.CaptureFiles, "ticket 1234")
+		}
+		if b, err := ioutil.ReadFile(tt.wantFile); err != nil || string(b) != "capture" {
+			t.Errorf("%s: Execute() wrote %q, %v, want: %q", tt.desc, b, err, "capture")
+		}
+	}
+}
+
+func TestDefaultOutput(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		capture = installer.Capture
+		now = time.Now
+	}()
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	search = func(string) ([]installer.Device, error) { return []installer.Device{&fakeDevice{id: "sdb"}}, nil }
+	capture = func(installer.Device, string, string, io.Writer) (*installer.CaptureMetadata, error) {
+		return &installer.CaptureMetadata{}, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd() returned %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("os.Chdir() returned %v", err)
+	}
+	defer os.Chdir(wd)
+	c := &captureCmd{mode: installer.CaptureBlock}
+	if err := c.run("sdb"); err != nil {
+		t.Fatalf("run() returned %v", err)
+	}
+	want := "sdb-20260102-030405.tar.gz"
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("run() did not write %q: %v", want, err)
+	}
+}
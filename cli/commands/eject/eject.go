@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eject implements the eject subcommand, which dismounts and ejects
+// removable devices so that they can be safely removed.
+package eject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errDevice = errors.New("device error")
+	errEject  = errors.New("eject error")
+	errSearch = errors.New("search error")
+
+	// Dependency injections for testing.
+	search = storageSearch
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&ejectCmd{}, "")
+}
+
+// ejectCmd is the eject subcommand, which dismounts and ejects removable
+// devices, such as after a write was cancelled part way through.
+type ejectCmd struct {
+	// allDrives ejects every removable device.
+	allDrives bool
+
+	// dismountOnly dismounts devices without ejecting them, leaving them
+	// available to be mounted again.
+	dismountOnly bool
+}
+
+// Ensure ejectCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*ejectCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *ejectCmd) Name() string {
+	return "eject"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*ejectCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *ejectCmd) Synopsis() string {
+	return "Dismount and eject removable devices so they can be safely removed"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *ejectCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [device...]
+
+Dismount each partition of removable devices and eject them, so that they can
+be safely removed without re-running the write command, such as after a write
+was cancelled or another tool was used. Only removable devices can be ejected.
+Devices are ejected in turn, and the remaining devices are still ejected when
+one of them fails. This operation may require elevated permissions, such as
+'sudo' on Linux/Mac or 'run as administrator' on Windows.
+
+Flags:
+  --all           - Eject every removable device.
+  --dismount_only - Dismount devices without ejecting them.
+
+Example: 'eject devices sdc and sdd'
+  - '%s eject sdc sdd'
+
+Example: 'eject every removable device'
+  - '%s eject --all'
+
+Defaults:
+`, c.Name(), binaryName, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *ejectCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.allDrives, "all", false, "eject every removable device")
+	f.BoolVar(&c.dismountOnly, "dismount_only", false, "dismount devices without ejecting them")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *ejectCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if (f.NArg() == 0) == !c.allDrives {
+		console.Printf("Either devices or '--all' must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(f.Args()); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run ejects the removable devices identified by ids, or every removable
+// device when allDrives is set.
+func (c *ejectCmd) run(ids []string) error {
+	devices, err := search()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSearch, err)
+	}
+	targets := devices
+	if !c.allDrives {
+		found := make(map[string]installer.Device)
+		for _, d := range devices {
+			found[d.Identifier()] = d
+		}
+		targets = nil
+		for _, id := range ids {
+			d, ok := found[id]
+			if !ok {
+				return fmt.Errorf("%w: removable device %q was not found, use the 'list' command to list available devices", errDevice, id)
+			}
+			targets = append(targets, d)
+		}
+	}
+	if len(targets) == 0 {
+		console.Printf("No removable devices were found.")
+		return nil
+	}
+	failed := 0
+	for _, d := range targets {
+		if err := c.eject(d); err != nil {
+			failed++
+			console.Printf("Could not eject %q: %v", d.Identifier(), err)
+			deck.Errorf("Could not eject %q: %v", d.Identifier(), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d devices could not be ejected", errEject, failed, len(targets))
+	}
+	return nil
+}
+
+// eject dismounts d and, unless dismountOnly is set, ejects it. Partitions
+// are detected again first, so that those mounted since d was found are
+// dismounted too.
+func (c *ejectCmd) eject(d installer.Device) error {
+	deck.InfofA("Refreshing partition information for %q prior to dismount.", d.Identifier()).With(deck.V(2)).Go()
+	if err := d.DetectPartitions(false); err != nil {
+		return fmt.Errorf("DetectPartitions() returned %v", err)
+	}
+	console.Printf("Dismounting device %q (%s).", d.Identifier(), d.FriendlyName())
+	deck.InfofA("Dismounting device %q.", d.Identifier()).With(deck.V(1)).Go()
+	if err := d.Dismount(); err != nil {
+		return fmt.Errorf("Dismount() returned %v", err)
+	}
+	if c.dismountOnly {
+		return nil
+	}
+	console.Printf("Ejecting device %q (%s).", d.Identifier(), d.FriendlyName())
+	deck.InfofA("Ejecting device %q.", d.Identifier()).With(deck.V(1)).Go()
+	if err := d.Eject(); err != nil {
+		return fmt.Errorf("Eject() returned %v", err)
+	}
+	return nil
+}
+
+// storageSearch wraps storage.Search and returns the removable devices.
+func storageSearch() ([]installer.Device, error) {
+	devices, err := storage.Search("", 0, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search() returned %v", err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eject
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id        string
+	detectErr error
+	dmErr     error
+	ejectErr  error
+
+	dismounted bool
+	ejected    bool
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
+func (f *fakeDevice) DetectPartitions(bool) error {
+	return f.detectErr
+}
+
+func (f *fakeDevice) Dismount() error {
+	f.dismounted = true
+	return f.dmErr
+}
+
+func (f *fakeDevice) Eject() error {
+	f.ejected = true
+	return f.ejectErr
+}
+
+func TestExecute(t *testing.T) {
+	defer func() { search = storageSearch }()
+	tests := []struct {
+		desc         string
+		args         []string
+		devices      []*fakeDevice
+		searchErr    error
+		want         subcommands.ExitStatus
+		wantEjected  []bool // Whether each of devices was ejected.
+		wantDismount []bool // Whether each of devices was dismounted.
+	}{
+		{
+			desc: "no device",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "devices and --all",
+			args: []string{"--all", "sdb"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"sdb"},
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:         "not found",
+			args:         []string{"sdb"},
+			devices:      []*fakeDevice{{id: "sdc"}},
+			want:         subcommands.ExitFailure,
+			wantEjected:  []bool{false},
+			wantDismount: []bool{false},
+		},
+		{
+			desc: "no removable devices",
+			args: []string{"--all"},
+			want: subcommands.ExitSuccess,
+		},
+		{
+			desc:         "detect error",
+			args:         []string{"sdb"},
+			devices:      []*fakeDevice{{id: "sdb", detectErr: errors.New("error")}},
+			want:         subcommands.ExitFailure,
+			wantEjected:  []bool{false},
+			wantDismount: []bool{false},
+		},
+		{
+			desc:         "dismount error continues with other devices",
+			args:         []string{"sdb", "sdc"},
+			devices:      []*fakeDevice{{id: "sdb", dmErr: errors.New("error")}, {id: "sdc"}},
+			want:         subcommands.ExitFailure,
+			wantEjected:  []bool{false, true},
+			wantDismount: []bool{true, true},
+		},
+		{
+			desc:         "eject error",
+			args:         []string{"sdb"},
+			devices:      []*fakeDevice{{id: "sdb", ejectErr: errors.New("error")}},
+			want:         subcommands.ExitFailure,
+			wantEjected:  []bool{true},
+			wantDismount: []bool{true},
+		},
+		{
+			desc:         "one of several",
+			args:         []string{"sdc"},
+			devices:      []*fakeDevice{{id: "sdb"}, {id: "sdc"}},
+			want:         subcommands.ExitSuccess,
+			wantEjected:  []bool{false, true},
+			wantDismount: []bool{false, true},
+		},
+		{
+			desc:         "all",
+			args:         []string{"--all"},
+			devices:      []*fakeDevice{{id: "sdb"}, {id: "sdc"}},
+			want:         subcommands.ExitSuccess,
+			wantEjected:  []bool{true, true},
+			wantDismount: []bool{true, true},
+		},
+		{
+			desc:         "dismount only",
+			args:         []string{"--dismount_only", "sdb"},
+			devices:      []*fakeDevice{{id: "sdb"}},
+			want:         subcommands.ExitSuccess,
+			wantEjected:  []bool{false},
+			wantDismount: []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		c := &ejectCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		devices := []installer.Device{}
+		for _, d := range tt.devices {
+			devices = append(devices, d)
+		}
+		searchErr := tt.searchErr
+		search = func() ([]installer.Device, error) { return devices, searchErr }
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		for n, d := range tt.devices {
+			if d.dismounted != tt.wantDismount[n] || d.ejected != tt.wantEjected[n] {
+				t.Errorf("%s: Execute() for %q dismounted: %t, ejected: %t, want: %t, %t", tt.desc, d.id, d.dismounted, d.ejected, tt.wantDismount[n], tt.wantEjected[n])
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package endpoints implements the endpoints subcommand, which reports the
+// default endpoints compiled into the CLI.
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/subcommands"
+)
+
+var (
+	binaryName string
+
+	// Dependency injections for testing.
+	output    = io.Writer(os.Stdout)
+	endpoints = config.Endpoints
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&endpointsCmd{}, "")
+}
+
+// endpointsCmd is the endpoints subcommand, which reports the default
+// endpoints compiled into the CLI, so that a branded build can be confirmed
+// to target the intended deployment.
+type endpointsCmd struct{}
+
+// Ensure endpointsCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*endpointsCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *endpointsCmd) Name() string {
+	return "endpoints"
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *endpointsCmd) Synopsis() string {
+	return "Report the default endpoints compiled into the CLI"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *endpointsCmd) Usage() string {
+	return fmt.Sprintf(`%s
+
+Report the seed, image and config servers compiled into the CLI for its
+built-in distributions, and whether each is still the placeholder in the
+source. Organizations set them when building the CLI, such as with
+'-ldflags "-X github.com/google/fresnel/cli/config.DefaultSeedServer=https://fresnel.example.com/seed"'.
+Configuration files and --env do not change the values reported.
+
+Example: 'report the compiled-in endpoints'
+  - '%s endpoints'
+`, c.Name(), binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *endpointsCmd) SetFlags(f *flag.FlagSet) {}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *endpointsCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintf(output, "Unexpected arguments %v.\nusage: %s %s\n", f.Args(), binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := write(output, endpoints()); err != nil {
+		deck.Errorf("write() returned %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// write writes a line for each of eps to w, naming the variable that sets
+// those that are still placeholders.
+func write(w io.Writer, eps []config.Endpoint) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range eps {
+		line := fmt.Sprintf("%s:\t%s", e.Name, e.Value)
+		if e.Placeholder {
+			line += fmt.Sprintf("\t(placeholder, set %s when building)", e.Variable)
+		}
+		if _, err := fmt.Fprintln(tw, line); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
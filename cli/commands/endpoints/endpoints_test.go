@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/subcommands"
+)
+
+func TestExecute(t *testing.T) {
+	stdout := output
+	defer func() { output = stdout; endpoints = config.Endpoints }()
+	tests := []struct {
+		desc      string
+		args      []string
+		endpoints []config.Endpoint
+		want      subcommands.ExitStatus
+		wantText  []string
+		skipText  string
+	}{
+		{
+			desc: "unexpected argument",
+			args: []string{"extra"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "placeholder",
+			endpoints: []config.Endpoint{
+				{Name: "Seed server", Variable: "config.DefaultSeedServer", Value: "https://appengine.address.com/seed", Placeholder: true},
+			},
+			want:     subcommands.ExitSuccess,
+			wantText: []string{"Seed server:", "https://appengine.address.com/seed", "set config.DefaultSeedServer"},
+		},
+		{
+			desc: "injected",
+			endpoints: []config.Endpoint{
+				{Name: "Seed server", Variable: "config.DefaultSeedServer", Value: "https://fresnel.example.com/seed"},
+			},
+			want:     subcommands.ExitSuccess,
+			wantText: []string{"https://fresnel.example.com/seed"},
+			skipText: "placeholder",
+		},
+	}
+	for _, tt := range tests {
+		endpoints = func() []config.Endpoint { return tt.endpoints }
+		c := &endpointsCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		var b bytes.Buffer
+		output = &b
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		for _, w := range tt.wantText {
+			if !strings.Contains(b.String(), w) {
+				t.Errorf("%s: Execute() wrote %q, want it to contain %q", tt.desc, b.String(), w)
+			}
+		}
+		if tt.skipText != "" && strings.Contains(b.String(), tt.skipText) {
+			t.Errorf("%s: Execute() wrote %q, want it not to contain %q", tt.desc, b.String(), tt.skipText)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+)
+
+// distroColumns are the columns used when printing distributions.
+var distroColumns = []console.Column{
+	{Title: "Distribution", Key: "Name"},
+	{Title: "OS", Key: "OS"},
+	{Title: "Tracks", Key: "Tracks"},
+	{Title: "Config Tracks", Key: "ConfTracks"},
+	{Title: "Seed", Key: "Seed"},
+	{Title: "FFU", Key: "FFU"},
+	{Title: "Apply", Key: "Apply"},
+}
+
+// printDistros writes the available distributions and their tracks to w in
+// the requested format. Tracks are separated by commas.
+func printDistros(distros []config.Distro, w io.Writer, format console.Format) error {
+	fw, err := console.NewFormatWriter(format)
+	if err != nil {
+		return err
+	}
+	r := &console.Report{Columns: distroColumns}
+	for _, d := range distros {
+		r.Rows = append(r.Rows, []string{
+			d.Name,
+			string(d.OS),
+			strings.Join(d.Tracks, ","),
+			strings.Join(d.ConfTracks, ","),
+			strconv.FormatBool(d.Seed),
+			strconv.FormatBool(d.FFU),
+			strconv.FormatBool(d.Apply),
+		})
+	}
+	return fw.Write(w, r)
+}
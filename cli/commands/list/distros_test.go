@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+)
+
+func TestPrintDistros(t *testing.T) {
+	distros := []config.Distro{
+		{Name: "linux", OS: "linux", Tracks: []string{"default"}},
+		{Name: "windowsffu", OS: "windows", Tracks: []string{"default", "stable"}, ConfTracks: []string{"default"}, FFU: true},
+	}
+	tests := []struct {
+		desc    string
+		format  console.Format
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc:    "unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+		{
+			desc:   "csv",
+			format: console.FormatCSV,
+			want: []string{
+				"Name,OS,Tracks,ConfTracks,Seed,FFU,Apply",
+				"linux,linux,default,,false,false,false",
+				`windowsffu,windows,"default,stable",default,false,true,false`,
+			},
+		},
+		{
+			desc:   "json",
+			format: console.FormatJSON,
+			want:   []string{`"Name":"windowsffu"`, `"Tracks":"default,stable"`, `"FFU":"true"`},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := printDistros(distros, &buf, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: printDistros() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s: printDistros() got: %q, want it to contain %q", tt.desc, buf.String(), w)
+			}
+		}
+	}
+}
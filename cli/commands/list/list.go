@@ -18,23 +18,39 @@ package list
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"flag"
+	"github.com/google/fresnel/cli/config"
 	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/units"
 	"github.com/google/deck"
 	"github.com/google/subcommands"
 	"github.com/google/winops/storage"
 )
 
+// exitNoDevices is returned by list when failEmpty is set and no suitable
+// devices were found. It is distinct from the statuses used by subcommands so
+// that scripts can tell an empty result apart from a failure.
+const exitNoDevices subcommands.ExitStatus = 3
+
 var (
 	// The name of this binary, set in init.
 	binaryName = ""
 	// Dependency injections for testing.
-	search = storage.Search
+	search         = storage.Search
+	checkWritable  = installer.CheckWritable
+	usbPermissions = config.HasWritePermissions
+	readMarker     = installer.ReadMarker
+
+	// Wrapped errors for testing.
+	errInput = errors.New("invalid input")
 )
 
 func init() {
@@ -48,20 +64,68 @@ type listCmd struct {
 	// available devices. It is defaulted to false by flag.
 	listFixed bool
 
-	// minSize is the minimum size device to search for in GB. For convenience,
-	// this value is defaulted to to a reasonable minimum size by flag.
-	minSize int
+	// minSize is the minimum size device to search for, such as "8G". Values
+	// without a suffix are in GB. For convenience, this value is defaulted to
+	// to a reasonable minimum size by flag.
+	minSize units.Value
+
+	// maxSize is the largest size device to search for, such as "1.5T". Values
+	// without a suffix are in GB. For convenience, this value is set to
+	// 'no limit (0)' by default by flag.
+	maxSize units.Value
+
+	// model limits the devices listed to those whose model contains this
+	// value, ignoring case.
+	model string
 
-	// maxSize is the largest size device to search for in GB. For convenience,
-	// this value is set to 'no limit (0)' by default by flag.
-	maxSize int
+	// sort determines the order in which devices are listed, by id, name or
+	// size. Devices are listed in the order they are found when empty.
+	sort string
 
 	// json silences any unnecessary text output and returns the device list in JSON.
-	// This value is defaulted to false by flag.
+	// This value is defaulted to false by flag. It is an alias for an output of
+	// json.
 	json bool
-}
 
-var oneGB = 1073741824
+	// failEmpty causes list to exit with exitNoDevices rather than success
+	// when no suitable devices are found. This value is defaulted to false by
+	// flag.
+	failEmpty bool
+
+	// watch causes list to keep running, refreshing the device list as devices
+	// are inserted and removed until interrupted. This value is defaulted to
+	// false by flag.
+	watch bool
+
+	// readyTimeout is how long to wait for devices inserted while watching to
+	// become ready before they are listed.
+	readyTimeout time.Duration
+
+	// searchTimeout is how long a search for devices is given to complete. In
+	// watch mode, a search that takes longer is reported and watching
+	// continues.
+	searchTimeout time.Duration
+
+	// listDistros lists the distributions available for provisioning and their
+	// tracks rather than devices. This value is defaulted to false by flag.
+	listDistros bool
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones before they are listed.
+	configFile string
+
+	// distro is the distribution that devices are expected to be provisioned
+	// with. When set, the marker that it writes alongside seeds is read from
+	// each device to show when the seed on it expires.
+	distro string
+
+	// conf is the configuration of distro, set when it is provided.
+	conf installer.Configuration
+
+	// output is the format of the device list: table, json, csv or yaml.
+	// Formats other than table silence any unnecessary text output.
+	output string
+}
 
 // Ensure listCommand implements the subcommands.Command interface.
 var _ subcommands.Command = (*listCmd)(nil)
@@ -71,6 +135,12 @@ func (*listCmd) Name() string {
 	return "list"
 }
 
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*listCmd) AccessesDevices() bool {
+	return true
+}
+
 // Synopsis returns a short string (less than one line) describing the subcommand.
 func (*listCmd) Synopsis() string {
 	return "list available devices suitable for provisioning with an installer"
@@ -84,8 +154,20 @@ List available devices suitable for provisioning with an installer.
 
 Flags:
   --show_fixed    - Includes fixed disks when searching for suitable devices.
-  --minimum [int] - The minimum size in GB to consider when searching.
-  --maximum [int] - The maximum size in GB to consider when searching.
+  --minimum [size] - The minimum size to consider when searching, such as '512M' or '8G'.
+  --maximum [size] - The maximum size to consider when searching, such as '1.5T'.
+                     Sizes without a suffix are in GB.
+  --model [string] - Only list devices whose model contains this value.
+  --sort [string]  - Sort devices by 'id', 'name' or 'size'.
+  --output [string] - The format of the device list: 'table', 'json', 'csv' or 'yaml'.
+  --fail_empty    - Exit with status 3 when no suitable devices are found.
+  --watch         - Refresh the list as devices are inserted and removed, until interrupted.
+                    In JSON mode, an event is written for each device that is added or removed.
+  --ready_timeout [duration] - How long to wait for devices inserted while watching to settle.
+  --search_timeout [duration] - How long to wait for a search for devices to complete, 0 for no limit.
+  --list_distros  - List the distributions and tracks available for provisioning instead of devices.
+  --config [path] - Include the distributions in a YAML or JSON file with --list_distros or --distro.
+  --distro [string] - Show when the seeds written to devices for this distribution expire.
 
 Example #1: Perform a standard search with defaults (removable media only > 2GB)
   '%s list'
@@ -93,9 +175,27 @@ Example #1: Perform a standard search with defaults (removable media only > 2GB)
 Example #2: Limit search to larger devices.
   '%s list --minimum=8'
 
-Example #3: Search fixed devices and removable devices.
+Example #3: Limit search to small SD cards.
+  '%s list --minimum=512M --maximum=4G'
+
+Example #4: Search fixed devices and removable devices.
   '%s list --show_fixed'
 
+Example #5: List only Cruzer devices, smallest first.
+  '%s list --model=cruzer --sort=size'
+
+Example #6: Export the device list for a spreadsheet.
+  '%s list --output=csv'
+
+Example #7: Monitor devices as they are inserted and removed.
+  '%s list --watch'
+
+Example #8: List the distributions available to the write command as JSON.
+  '%s list --list_distros --output=json'
+
+Example #9: Show when the seeds on devices provisioned with windows expire.
+  '%s list --distro=windows'
+
 Example output:
 
 DEVICE |  MODEL  | SIZE  | INSTALLER PRESENT
@@ -104,39 +204,102 @@ DEVICE |  MODEL  | SIZE  | INSTALLER PRESENT
  disk3 | Cruzer  | 64 GB | Present
 
 Defaults:
-`, binaryName, binaryName, binaryName)
+`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
 }
 
 // SetFlags adds the flags for this command to the specified set.
 func (c *listCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.listFixed, "show_fixed", false, "Also display fixed drives.")
-	f.IntVar(&c.minSize, "minimum", 2, "The minimum size [in GB] of drives to search for.")
-	f.IntVar(&c.maxSize, "maximum", 0, "The maximum size [in GB] drives to search for.")
-	f.BoolVar(&c.json, "json", false, "Display the device list in JSON with no additional output")
+	c.minSize = units.Value{Size: 2 * units.GB, Unit: units.GB}
+	c.maxSize = units.Value{Unit: units.GB}
+	f.Var(&c.minSize, "minimum", "The minimum size of drives to search for, such as '8G' [GB if no suffix].")
+	f.Var(&c.maxSize, "maximum", "The maximum size of drives to search for, such as '1.5T' [GB if no suffix].")
+	f.StringVar(&c.model, "model", "", "Only list devices whose model contains this value, ignoring case.")
+	f.StringVar(&c.sort, "sort", "", fmt.Sprintf("Sort devices by one of %v.", sortKeys))
+	f.BoolVar(&c.json, "json", false, "Display the device list in JSON with no additional output, alias for '--output=json'")
+	f.BoolVar(&c.failEmpty, "fail_empty", false, fmt.Sprintf("Exit with status %d when no suitable devices are found.", exitNoDevices))
+	f.BoolVar(&c.watch, "watch", false, "Refresh the device list as devices are inserted and removed, until interrupted.")
+	f.DurationVar(&c.readyTimeout, "ready_timeout", installer.DefaultReadyTimeout, "How long to wait for devices inserted while watching to become ready before they are listed.")
+	f.DurationVar(&c.searchTimeout, "search_timeout", installer.DefaultSearchTimeout, "How long to wait for a search for devices to complete, 0 for no limit.")
+	f.BoolVar(&c.listDistros, "list_distros", false, "List the distributions and tracks available for provisioning instead of devices.")
+	f.StringVar(&c.configFile, "config", "", "A YAML or JSON file of distributions to merge over the built-in ones when listing them or reading markers, defaults to "+config.DefaultConfigPath())
+	f.StringVar(&c.distro, "distro", "", "Read the marker written by this distribution from each device, to show when the seed on it expires.")
+	f.StringVar(&c.output, "output", string(console.FormatTable), fmt.Sprintf("The format of the device list, one of %v.", console.Formats))
 }
 
 // Execute runs the command and returns an ExitStatus.
-func (c *listCmd) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+func (c *listCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	// Validate the sort key before searching, which can take some time.
+	if err := sortDevices(nil, c.sort); err != nil {
+		deck.Errorf("%v", err)
+		return subcommands.ExitUsageError
+	}
+	format := console.FormatTable
+	switch {
+	case c.json:
+		format = console.FormatJSON
+	case c.output != "":
+		var err error
+		if format, err = console.ParseFormat(c.output); err != nil {
+			deck.Errorf("%v", err)
+			return subcommands.ExitUsageError
+		}
+	}
 	// Scan for the available drives. Warn that this may take a while.
-	if c.json {
+	if format != console.FormatTable {
 		// Turning on verbose will silence console output
 		console.Verbose = true
 	}
 
-	console.Print("Searching for devices. This may take up to one minute...\n")
+	if c.listDistros {
+		if err := config.LoadDistributions(c.configFile); err != nil {
+			deck.Errorf("%v", err)
+			return subcommands.ExitFailure
+		}
+		if err := printDistros(config.Distributions(), os.Stdout, format); err != nil {
+			deck.Errorf("printDistros(%q) returned %v", format, err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	if c.distro != "" {
+		if err := config.LoadDistributions(c.configFile); err != nil {
+			deck.Errorf("%v", err)
+			return subcommands.ExitFailure
+		}
+		conf, err := config.New(false, false, false, false, false, false, false, false, false, nil, c.distro, "", "", "")
+		if err != nil {
+			deck.Errorf("config.New(distro: %s) returned %v", c.distro, err)
+			return subcommands.ExitUsageError
+		}
+		c.conf = conf
+	}
+
+	if c.watch {
+		return c.watchDevices(ctx, format, os.Stdout)
+	}
+
+	if c.searchTimeout > 0 {
+		console.Printf("Searching for devices. This may take up to %v...", c.searchTimeout)
+	} else {
+		console.Printf("Searching for devices. This may take some time...")
+	}
 	deck.InfoA("Searching for devices.").With(deck.V(1)).Go()
-	devices, err := search("", uint64(c.minSize*oneGB), uint64(c.maxSize*oneGB), !c.listFixed)
+	available, err := installer.Search(ctx, c.searchTimeout, c.find)
 	if err != nil {
-		deck.Errorf("storage.Search(%d, %d, %t) returned %v", c.minSize, c.maxSize, !c.listFixed, err)
+		deck.Errorf("%v", err)
 		return subcommands.ExitFailure
 	}
-	// Wrap devices in an []console.TargetDevice.
-	available := []console.TargetDevice{}
-	for _, d := range devices {
-		available = append(available, d)
-	}
 
-	console.PrintDevices(available, os.Stdout, c.json)
+	if err := console.PrintDevices(available, os.Stdout, format); err != nil {
+		deck.Errorf("console.PrintDevices(%q) returned %v", format, err)
+		return subcommands.ExitFailure
+	}
+	if len(available) == 0 && c.failEmpty {
+		deck.InfoA("No suitable devices were found.").With(deck.V(1)).Go()
+		return exitNoDevices
+	}
 
 	// Provide contextual help for next steps.
 	console.Printf(`
@@ -148,3 +311,76 @@ For additional examples, see the help for the windows subcommand, '%s help windo
 
 	return subcommands.ExitSuccess
 }
+
+// listedDevice is a device found by a search, which reports whether it is
+// write protected.
+type listedDevice struct {
+	*storage.Device
+	protected bool
+}
+
+// WriteProtected determines whether the device cannot be written to.
+func (d *listedDevice) WriteProtected() bool {
+	return d.protected
+}
+
+// markedDevice is a listed device whose marker was read, which reports when
+// the seed on it expires.
+type markedDevice struct {
+	*listedDevice
+	expiry time.Time
+}
+
+// SeedExpiry returns when the seed on the device expires, or the zero time
+// when it is unknown.
+func (d *markedDevice) SeedExpiry() time.Time {
+	return d.expiry
+}
+
+// mark reads the marker of d when a distribution was provided. Markers that
+// cannot be read leave the expiry of the seed unknown, as devices that were
+// never provisioned have none.
+func (c *listCmd) mark(d *listedDevice) console.TargetDevice {
+	if c.conf == nil {
+		return d
+	}
+	md := &markedDevice{listedDevice: d}
+	m, err := readMarker(d.Device, c.conf)
+	if err != nil {
+		deck.InfofA("No marker was read from %q: %v", d.Identifier(), err).With(deck.V(1)).Go()
+		return md
+	}
+	md.expiry = m.SeedExpiry
+	return md
+}
+
+// find searches for suitable devices, returning them filtered and sorted
+// according to the flags provided.
+func (c *listCmd) find() ([]console.TargetDevice, error) {
+	devices, err := search("", uint64(c.minSize.Size), uint64(c.maxSize.Size), !c.listFixed)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%v, %v, %t) returned %v", c.minSize.Size, c.maxSize.Size, !c.listFixed, err)
+	}
+	// Wrap devices in an []console.TargetDevice, marking those that cannot be
+	// written to. A policy that denies writes to removable media protects
+	// every device.
+	policyErr := usbPermissions()
+	if policyErr != nil {
+		deck.Warningf("Every device is read-only: %v", policyErr)
+	}
+	available := []console.TargetDevice{}
+	for _, d := range devices {
+		ld := &listedDevice{Device: d, protected: policyErr != nil}
+		if err := checkWritable(d.Identifier()); err != nil {
+			deck.Warningf("%v", err)
+			ld.protected = true
+		}
+		available = append(available, c.mark(ld))
+	}
+	// Filter and sort before output so that all formats agree.
+	available = filterDevices(available, c.model)
+	if err := sortDevices(available, c.sort); err != nil {
+		return nil, err
+	}
+	return available, nil
+}
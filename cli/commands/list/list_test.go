@@ -16,9 +16,17 @@ package list
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/models"
 	"github.com/google/subcommands"
 	"github.com/google/winops/storage"
 )
@@ -50,9 +58,51 @@ func TestUsage(t *testing.T) {
 func TestExecute(t *testing.T) {
 	tests := []struct {
 		desc       string
+		sort       string
+		output     string
+		failEmpty  bool
 		fakeSearch func(string, uint64, uint64, bool) ([]*storage.Device, error)
 		want       subcommands.ExitStatus
 	}{
+		{
+			desc:       "invalid sort",
+			sort:       "color",
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) { return nil, nil },
+			want:       subcommands.ExitUsageError,
+		},
+		{
+			desc:       "invalid output",
+			output:     "xml",
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) { return nil, nil },
+			want:       subcommands.ExitUsageError,
+		},
+		{
+			desc:   "csv output",
+			output: "csv",
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+				return []*storage.Device{&storage.Device{}}, nil
+			},
+			want: subcommands.ExitSuccess,
+		},
+		{
+			desc:       "no devices",
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) { return nil, nil },
+			want:       subcommands.ExitSuccess,
+		},
+		{
+			desc:       "no devices with fail_empty",
+			failEmpty:  true,
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) { return nil, nil },
+			want:       exitNoDevices,
+		},
+		{
+			desc:      "devices with fail_empty",
+			failEmpty: true,
+			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+				return []*storage.Device{&storage.Device{}}, nil
+			},
+			want: subcommands.ExitSuccess,
+		},
 		{
 			desc:       "search error",
 			fakeSearch: func(string, uint64, uint64, bool) ([]*storage.Device, error) { return nil, fmt.Errorf("error") },
@@ -68,10 +118,176 @@ func TestExecute(t *testing.T) {
 	}
 	for _, tt := range tests {
 		search = tt.fakeSearch
-		list := &listCmd{}
+		list := &listCmd{sort: tt.sort, output: tt.output, failEmpty: tt.failEmpty}
 		got := list.Execute(context.Background(), nil, nil)
 		if got != tt.want {
 			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
 		}
 	}
 }
+
+func TestSizeFlags(t *testing.T) {
+	tests := []struct {
+		desc    string
+		args    []string
+		wantMin uint64
+		wantMax uint64
+		wantErr bool
+	}{
+		{
+			desc:    "defaults",
+			wantMin: uint64(2 * units.GB),
+			wantMax: 0,
+		},
+		{
+			desc:    "bare numbers are GB",
+			args:    []string{"--minimum=8", "--maximum=64"},
+			wantMin: uint64(8 * units.GB),
+			wantMax: uint64(64 * units.GB),
+		},
+		{
+			desc:    "suffixes",
+			args:    []string{"--minimum=512M", "--maximum=1.5T"},
+			wantMin: uint64(512 * units.MB),
+			wantMax: uint64(units.TB + 512*units.GB),
+		},
+		{
+			desc:    "negative minimum",
+			args:    []string{"--minimum=-1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var gotMin, gotMax uint64
+		search = func(_ string, minSize, maxSize uint64, _ bool) ([]*storage.Device, error) {
+			gotMin, gotMax = minSize, maxSize
+			return nil, nil
+		}
+		list := &listCmd{}
+		f := flag.NewFlagSet("list", flag.ContinueOnError)
+		list.SetFlags(f)
+		if err := f.Parse(tt.args); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Parse(%v) returned %v, want error: %t", tt.desc, tt.args, err, tt.wantErr)
+			continue
+		} else if err != nil {
+			continue
+		}
+		list.Execute(context.Background(), f, nil)
+		if gotMin != tt.wantMin || gotMax != tt.wantMax {
+			t.Errorf("%s: search() got: %d, %d, want: %d, %d", tt.desc, gotMin, gotMax, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestFindWriteProtected(t *testing.T) {
+	defer func() {
+		checkWritable = installer.CheckWritable
+		usbPermissions = config.HasWritePermissions
+	}()
+	search = func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+		return []*storage.Device{&storage.Device{}}, nil
+	}
+	tests := []struct {
+		desc      string
+		writable  error
+		policy    error
+		protected bool
+	}{
+		{
+			desc: "writable",
+		},
+		{
+			desc:      "device write protected",
+			writable:  fmt.Errorf("%w: locked", installer.ErrWriteProtected),
+			protected: true,
+		},
+		{
+			desc:      "writes denied by policy",
+			policy:    config.ErrWritePerms,
+			protected: true,
+		},
+	}
+	for _, tt := range tests {
+		checkWritable = func(string) error { return tt.writable }
+		usbPermissions = func() error { return tt.policy }
+		got, err := (&listCmd{}).find()
+		if err != nil {
+			t.Errorf("%s: find() returned %v", tt.desc, err)
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%s: find() got %d devices, want: 1", tt.desc, len(got))
+			continue
+		}
+		p, ok := got[0].(console.ProtectedDevice)
+		if !ok || p.WriteProtected() != tt.protected {
+			t.Errorf("%s: find() write protected: %t, want: %t", tt.desc, ok && p.WriteProtected(), tt.protected)
+		}
+	}
+}
+
+// fakeConfig satisfies installer.Configuration for reading markers.
+type fakeConfig struct {
+	installer.Configuration
+}
+
+func TestFindMarked(t *testing.T) {
+	defer func() {
+		checkWritable = installer.CheckWritable
+		usbPermissions = config.HasWritePermissions
+		readMarker = installer.ReadMarker
+	}()
+	search = func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+		return []*storage.Device{&storage.Device{}}, nil
+	}
+	checkWritable = func(string) error { return nil }
+	usbPermissions = func() error { return nil }
+	expiry := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc       string
+		conf       installer.Configuration
+		marker     *models.BatchMarker
+		markerErr  error
+		wantMarked bool
+		want       time.Time
+	}{
+		{
+			desc: "no distro",
+		},
+		{
+			desc:       "no marker",
+			conf:       &fakeConfig{},
+			markerErr:  errors.New("error"),
+			wantMarked: true,
+		},
+		{
+			desc:       "marked",
+			conf:       &fakeConfig{},
+			marker:     &models.BatchMarker{SeedExpiry: expiry},
+			wantMarked: true,
+			want:       expiry,
+		},
+	}
+	for _, tt := range tests {
+		readMarker = func(installer.Device, installer.Configuration) (*models.BatchMarker, error) {
+			return tt.marker, tt.markerErr
+		}
+		got, err := (&listCmd{conf: tt.conf}).find()
+		if err != nil {
+			t.Errorf("%s: find() returned %v", tt.desc, err)
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%s: find() got %d devices, want: 1", tt.desc, len(got))
+			continue
+		}
+		m, ok := got[0].(console.MarkedDevice)
+		if ok != tt.wantMarked {
+			t.Errorf("%s: find() marked: %t, want: %t", tt.desc, ok, tt.wantMarked)
+			continue
+		}
+		if ok && !m.SeedExpiry().Equal(tt.want) {
+			t.Errorf("%s: find() seed expiry: %v, want: %v", tt.desc, m.SeedExpiry(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/fresnel/cli/console"
+)
+
+// sortKeys lists the accepted values for the sort flag.
+var sortKeys = []string{"id", "name", "size"}
+
+// filterDevices returns the devices whose model contains model, ignoring case.
+// All devices are returned when model is empty.
+func filterDevices(devices []console.TargetDevice, model string) []console.TargetDevice {
+	if model == "" {
+		return devices
+	}
+	model = strings.ToLower(model)
+	filtered := []console.TargetDevice{}
+	for _, d := range devices {
+		if strings.Contains(strings.ToLower(d.FriendlyName()), model) {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// sortDevices sorts devices in place by the provided key, one of sortKeys.
+// Devices are left in the order they were found when key is empty. Ties are
+// broken by identifier so that the output is stable.
+func sortDevices(devices []console.TargetDevice, key string) error {
+	var less func(a, b console.TargetDevice) bool
+	switch key {
+	case "":
+		return nil
+	case "id":
+		less = func(a, b console.TargetDevice) bool { return false }
+	case "name":
+		less = func(a, b console.TargetDevice) bool {
+			return strings.ToLower(a.FriendlyName()) < strings.ToLower(b.FriendlyName())
+		}
+	case "size":
+		less = func(a, b console.TargetDevice) bool { return a.Size() < b.Size() }
+	default:
+		return fmt.Errorf("%w: cannot sort by %q, must be one of %v", errInput, key, sortKeys)
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		if less(devices[i], devices[j]) {
+			return true
+		}
+		if less(devices[j], devices[i]) {
+			return false
+		}
+		return idLess(devices[i].Identifier(), devices[j].Identifier())
+	})
+	return nil
+}
+
+// idLess compares device identifiers such that trailing numbers are ordered
+// numerically, placing 'disk2' before 'disk10'.
+func idLess(a, b string) bool {
+	aPrefix, aNum := splitID(a)
+	bPrefix, bNum := splitID(b)
+	if aPrefix != bPrefix || aNum < 0 || bNum < 0 {
+		return a < b
+	}
+	return aNum < bNum
+}
+
+// splitID splits an identifier into its prefix and trailing number. The
+// number is -1 when the identifier does not end in one.
+func splitID(id string) (string, int) {
+	i := len(id)
+	for i > 0 && id[i-1] >= '0' && id[i-1] <= '9' {
+		i--
+	}
+	n, err := strconv.Atoi(id[i:])
+	if err != nil {
+		return id, -1
+	}
+	return id[:i], n
+}
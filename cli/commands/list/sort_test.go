@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeDevice implements console.TargetDevice for testing.
+type fakeDevice struct {
+	id   string
+	name string
+	size uint64
+}
+
+func (f *fakeDevice) Identifier() string   { return f.id }
+func (f *fakeDevice) FriendlyName() string { return f.name }
+func (f *fakeDevice) Size() uint64         { return f.size }
+
+// ids returns the identifiers of devices, in order.
+func ids(devices []console.TargetDevice) []string {
+	out := []string{}
+	for _, d := range devices {
+		out = append(out, d.Identifier())
+	}
+	return out
+}
+
+func testDevices() []console.TargetDevice {
+	return []console.TargetDevice{
+		&fakeDevice{id: "disk10", name: "SanDisk Cruzer", size: 64},
+		&fakeDevice{id: "disk2", name: "Generic Reader", size: 16},
+		&fakeDevice{id: "disk3", name: "sandisk ultra", size: 16},
+		&fakeDevice{id: "disk1", name: "Kingston", size: 32},
+	}
+}
+
+func TestSortDevices(t *testing.T) {
+	tests := []struct {
+		desc    string
+		key     string
+		want    []string
+		wantErr error
+	}{
+		{
+			desc: "unsorted",
+			key:  "",
+			want: []string{"disk10", "disk2", "disk3", "disk1"},
+		},
+		{
+			desc: "by id",
+			key:  "id",
+			want: []string{"disk1", "disk2", "disk3", "disk10"},
+		},
+		{
+			desc: "by name",
+			key:  "name",
+			want: []string{"disk2", "disk1", "disk10", "disk3"},
+		},
+		{
+			desc: "by size",
+			key:  "size",
+			want: []string{"disk2", "disk3", "disk1", "disk10"},
+		},
+		{
+			desc:    "unknown key",
+			key:     "model",
+			want:    []string{"disk10", "disk2", "disk3", "disk1"},
+			wantErr: errInput,
+		},
+	}
+	for _, tt := range tests {
+		devices := testDevices()
+		if err := sortDevices(devices, tt.key); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: sortDevices() returned %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if diff := cmp.Diff(tt.want, ids(devices)); diff != "" {
+			t.Errorf("%s: sortDevices() returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestFilterDevices(t *testing.T) {
+	tests := []struct {
+		desc  string
+		model string
+		want  []string
+	}{
+		{
+			desc:  "no filter",
+			model: "",
+			want:  []string{"disk10", "disk2", "disk3", "disk1"},
+		},
+		{
+			desc:  "case insensitive",
+			model: "SANDISK",
+			want:  []string{"disk10", "disk3"},
+		},
+		{
+			desc:  "no match",
+			model: "lexar",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		got := filterDevices(testDevices(), tt.model)
+		if diff := cmp.Diff(tt.want, ids(got)); diff != "" {
+			t.Errorf("%s: filterDevices() returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
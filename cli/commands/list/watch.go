@@ -0,0 +1,211 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+)
+
+var (
+	// watchInterval is the time between searches in watch mode.
+	watchInterval = 2 * time.Second
+	// Dependency injections for testing.
+	waitReady = installer.WaitReady
+)
+
+// Events written in JSON mode when watching for devices.
+const (
+	eventAdded   = "added"
+	eventRemoved = "removed"
+)
+
+// deviceEvent is written as a line of JSON in watch mode each time a device is
+// added or removed.
+type deviceEvent struct {
+	Event string
+	Time  time.Time
+	ID    string
+	Name  string
+	Size  string
+}
+
+// searchResult is the outcome of a search for devices made while watching.
+type searchResult struct {
+	devices []console.TargetDevice
+	err     error
+}
+
+// watchDevices searches for devices repeatedly until ctx is cancelled. In
+// JSON mode, an event is written to w for each device that is added or
+// removed, starting with an added event for each device that is present when
+// watching begins. Otherwise, the full device list is written each time it
+// changes. Each search runs in the background, one at a time, so that a
+// search blocked by a device that does not respond is reported rather than
+// preventing watching from being stopped.
+func (c *listCmd) watchDevices(ctx context.Context, format console.Format, w io.Writer) subcommands.ExitStatus {
+	console.Printf("Watching for devices, press Ctrl+C to stop.")
+	deck.InfoA("Watching for devices.").With(deck.V(1)).Go()
+	// The channel is buffered so that a search completing after watching has
+	// stopped does not block.
+	results := make(chan searchResult, 1)
+	start := func() <-chan time.Time {
+		go func() {
+			devices, err := c.find()
+			results <- searchResult{devices, err}
+		}()
+		if c.searchTimeout <= 0 {
+			return nil
+		}
+		return time.After(c.searchTimeout)
+	}
+	timeout := start()
+	var next <-chan time.Time
+	var known []console.TargetDevice
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return subcommands.ExitSuccess
+		case <-timeout:
+			deck.Warningf("Search for devices has not completed after %v, a device may not be responding. Devices already found remain listed.", c.searchTimeout)
+			timeout = nil
+		case r := <-results:
+			timeout = nil
+			next = time.After(watchInterval)
+			if r.err != nil {
+				// Searches can fail transiently while devices are being inserted
+				// or removed, so watching continues.
+				deck.Warningf("Search for devices failed, retrying: %v", r.err)
+				continue
+			}
+			added, removed := diffDevices(known, r.devices)
+			// Added devices are only reported once they are ready, and are
+			// otherwise checked again after the next search.
+			added, devices := c.readyDevices(added, r.devices)
+			if first || len(added) > 0 || len(removed) > 0 {
+				if err := writeChanges(w, format, devices, added, removed); err != nil {
+					deck.Errorf("%v", err)
+					return subcommands.ExitFailure
+				}
+			}
+			known = devices
+			first = false
+		case <-next:
+			next = nil
+			timeout = start()
+		}
+	}
+}
+
+// readyDevices waits for each added device to become ready, returning the
+// added devices and the full device list without those that did not.
+func (c *listCmd) readyDevices(added, devices []console.TargetDevice) ([]console.TargetDevice, []console.TargetDevice) {
+	notReady := make(map[string]bool)
+	ready := []console.TargetDevice{}
+	for _, d := range added {
+		det, ok := d.(installer.Detector)
+		if !ok {
+			ready = append(ready, d)
+			continue
+		}
+		if err := waitReady(det, c.readyTimeout); err != nil {
+			deck.Warningf("Device %q is not ready and will be checked again: %v", d.Identifier(), err)
+			notReady[d.Identifier()] = true
+			continue
+		}
+		ready = append(ready, d)
+	}
+	if len(notReady) == 0 {
+		return ready, devices
+	}
+	remaining := []console.TargetDevice{}
+	for _, d := range devices {
+		if !notReady[d.Identifier()] {
+			remaining = append(remaining, d)
+		}
+	}
+	return ready, remaining
+}
+
+// diffDevices returns the devices in current that are not in previous, and
+// the devices in previous that are not in current, by identifier.
+func diffDevices(previous, current []console.TargetDevice) (added, removed []console.TargetDevice) {
+	prev := make(map[string]bool)
+	for _, d := range previous {
+		prev[d.Identifier()] = true
+	}
+	cur := make(map[string]bool)
+	for _, d := range current {
+		cur[d.Identifier()] = true
+		if !prev[d.Identifier()] {
+			added = append(added, d)
+		}
+	}
+	for _, d := range previous {
+		if !cur[d.Identifier()] {
+			removed = append(removed, d)
+		}
+	}
+	return added, removed
+}
+
+// writeChanges writes a change in the device list to w. In JSON mode, an event
+// is written for each added and removed device. Otherwise, the full list is
+// written.
+func writeChanges(w io.Writer, format console.Format, devices, added, removed []console.TargetDevice) error {
+	if format != console.FormatJSON {
+		if format == console.FormatTable {
+			fmt.Fprintf(w, "\n%s: %d device(s) found.\n", time.Now().Format(time.RFC3339), len(devices))
+		}
+		if err := console.PrintDevices(devices, w, format); err != nil {
+			return fmt.Errorf("console.PrintDevices(%q) returned %v", format, err)
+		}
+		fmt.Fprintln(w)
+		return nil
+	}
+	enc := json.NewEncoder(w)
+	now := time.Now()
+	for _, change := range []struct {
+		event   string
+		devices []console.TargetDevice
+	}{
+		{eventRemoved, removed},
+		{eventAdded, added},
+	} {
+		for _, d := range change.devices {
+			e := deviceEvent{
+				Event: change.event,
+				Time:  now,
+				ID:    d.Identifier(),
+				Name:  d.FriendlyName(),
+				Size:  humanize.Bytes(d.Size()),
+			}
+			if err := enc.Encode(e); err != nil {
+				return fmt.Errorf("writing %s event for %q: %v", change.event, d.Identifier(), err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package list
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeSearches returns a search function that returns each result in turn.
+// Once they are exhausted, the context is cancelled and the search blocks
+// until release is closed, like a search of a device that does not respond.
+func fakeSearches(cancel context.CancelFunc, release <-chan struct{}, results ...[]*storage.Device) func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+	call := 0
+	return func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+		if call >= len(results) {
+			cancel()
+			<-release
+			return nil, nil
+		}
+		r := results[call]
+		call++
+		if r == nil {
+			return nil, errors.New("transient error")
+		}
+		return r, nil
+	}
+}
+
+func TestWatchDevices(t *testing.T) {
+	watchInterval = time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	waitReady = func(installer.Detector, time.Duration) error { return nil }
+	defer func() { waitReady = installer.WaitReady }()
+	one := &storage.Device{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	// The device is present, a search fails, the device is still present and
+	// is then removed.
+	search = fakeSearches(cancel, release, []*storage.Device{one}, nil, []*storage.Device{one}, []*storage.Device{})
+
+	var got bytes.Buffer
+	c := &listCmd{}
+	if status := c.watchDevices(ctx, console.FormatJSON, &got); status != subcommands.ExitSuccess {
+		t.Fatalf("watchDevices() got: %d, want: %d", status, subcommands.ExitSuccess)
+	}
+	var events []string
+	dec := json.NewDecoder(&got)
+	for dec.More() {
+		var e deviceEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("Decode() returned %v", err)
+		}
+		events = append(events, e.Event+" "+e.ID)
+	}
+	want := []string{eventAdded + " " + one.Identifier(), eventRemoved + " " + one.Identifier()}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("watchDevices() returned unexpected events (-want +got):\n%s", diff)
+	}
+}
+
+func TestWatchDevicesTable(t *testing.T) {
+	watchInterval = time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	waitReady = func(installer.Detector, time.Duration) error { return nil }
+	defer func() { waitReady = installer.WaitReady }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	// The list is only written when it changes.
+	search = fakeSearches(cancel, release, []*storage.Device{}, []*storage.Device{}, []*storage.Device{&storage.Device{}})
+
+	var got bytes.Buffer
+	c := &listCmd{}
+	c.watchDevices(ctx, console.FormatTable, &got)
+	if n := strings.Count(got.String(), "device(s) found"); n != 2 {
+		t.Errorf("watchDevices() wrote the device list %d times, want: 2", n)
+	}
+}
+
+func TestWatchDevicesNotReady(t *testing.T) {
+	watchInterval = time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	// The device is not ready the first time it is found.
+	calls := 0
+	waitReady = func(installer.Detector, time.Duration) error {
+		calls++
+		if calls == 1 {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+	defer func() { waitReady = installer.WaitReady }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	one := &storage.Device{}
+	search = fakeSearches(cancel, release, []*storage.Device{one}, []*storage.Device{one})
+
+	var got bytes.Buffer
+	c := &listCmd{}
+	c.watchDevices(ctx, console.FormatJSON, &got)
+	if n := strings.Count(got.String(), eventAdded); n != 1 {
+		t.Errorf("watchDevices() wrote %d added events, want: 1", n)
+	}
+	if calls != 2 {
+		t.Errorf("watchDevices() checked readiness %d times, want: 2", calls)
+	}
+}
+
+func TestWatchDevicesSearchTimeout(t *testing.T) {
+	watchInterval = time.Millisecond
+	defer func() { watchInterval = 2 * time.Second }()
+	waitReady = func(installer.Detector, time.Duration) error { return nil }
+	defer func() { waitReady = installer.WaitReady }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	// The second search never completes, and watching is stopped after it
+	// times out.
+	calls := 0
+	search = func(string, uint64, uint64, bool) ([]*storage.Device, error) {
+		calls++
+		if calls > 1 {
+			time.AfterFunc(50*time.Millisecond, cancel)
+			<-release
+		}
+		return []*storage.Device{&storage.Device{}}, nil
+	}
+
+	var got bytes.Buffer
+	c := &listCmd{searchTimeout: 10 * time.Millisecond}
+	if status := c.watchDevices(ctx, console.FormatJSON, &got); status != subcommands.ExitSuccess {
+		t.Fatalf("watchDevices() got: %d, want: %d", status, subcommands.ExitSuccess)
+	}
+	if n := strings.Count(got.String(), eventAdded); n != 1 {
+		t.Errorf("watchDevices() wrote %d added events, want: 1", n)
+	}
+	if calls != 2 {
+		t.Errorf("watchDevices() searched %d times while a search was blocked, want: 2", calls)
+	}
+}
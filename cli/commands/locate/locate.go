@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package locate implements the locate subcommand, which blinks the activity
+// LED of a device so that it can be physically identified.
+package locate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errDevice = errors.New("device error")
+	errSearch = errors.New("search error")
+
+	// Dependency injections for testing.
+	search = storageSearch
+	locate = installer.Locate
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&locateCmd{}, "")
+}
+
+// locateCmd is the locate subcommand, which blinks the activity LED of a
+// device to help identify it before it is written to.
+type locateCmd struct {
+	// duration is how long the device is blinked. Zero blinks it until the
+	// command is interrupted.
+	duration time.Duration
+}
+
+// Ensure locateCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*locateCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *locateCmd) Name() string {
+	return "locate"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*locateCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *locateCmd) Synopsis() string {
+	return "Blink the activity LED of a device to identify it"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *locateCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [device]
+
+Blink the activity LED of a device by reading from it intermittently, so that
+it can be physically identified among several identical devices before it is
+written to. Nothing is written to the device. Devices without an activity LED
+cannot be located. This operation requires permission to read from devices,
+such as 'sudo' on Linux/Mac or 'run as administrator' on Windows.
+
+Flags:
+  --duration [duration] - How long to blink the device, such as '1m'. 0 blinks
+                          it until the command is interrupted with Ctrl-C.
+
+Example: 'blink device sdc for 30 seconds'
+  - '%s locate sdc'
+
+Defaults:
+`, c.Name(), binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *locateCmd) SetFlags(f *flag.FlagSet) {
+	f.DurationVar(&c.duration, "duration", 30*time.Second, "how long to blink the device, 0 blinks it until interrupted")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *locateCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		console.Printf("A single device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(ctx, f.Arg(0)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run finds the device with the identifier id and blinks it.
+func (c *locateCmd) run(ctx context.Context, id string) error {
+	devices, err := search(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSearch, err)
+	}
+	for _, d := range devices {
+		if d.Identifier() != id {
+			continue
+		}
+		wait := "Press Ctrl-C to stop."
+		if c.duration > 0 {
+			wait = fmt.Sprintf("Blinking for %v, press Ctrl-C to stop early.", c.duration)
+		}
+		console.Printf("Blinking the activity LED of %q (%s). %s", id, d.FriendlyName(), wait)
+		return locate(ctx, d, c.duration)
+	}
+	return fmt.Errorf("%w: device %q was not found, use the 'list' command to list available devices", errDevice, id)
+}
+
+// storageSearch wraps storage.Search and returns the devices that match id,
+// including fixed devices, as they are only read from.
+func storageSearch(id string) ([]installer.Device, error) {
+	devices, err := storage.Search(id, 0, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%q) returned %v", id, err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
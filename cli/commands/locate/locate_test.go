@@ -0,0 +1,115 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package locate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"flag"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
+func TestExecute(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		locate = installer.Locate
+	}()
+	tests := []struct {
+		desc      string
+		args      []string
+		devices   []installer.Device
+		searchErr error
+		locateErr error
+		want      subcommands.ExitStatus
+	}{
+		{
+			desc: "no device",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "too many devices",
+			args: []string{"sdb", "sdc"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"sdb"},
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "not found",
+			args:    []string{"sdb"},
+			devices: []installer.Device{&fakeDevice{id: "sdc"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "locate error",
+			args:      []string{"sdb"},
+			devices:   []installer.Device{&fakeDevice{id: "sdb"}},
+			locateErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "success",
+			args:    []string{"--duration=1m", "sdb"},
+			devices: []installer.Device{&fakeDevice{id: "sdc"}, &fakeDevice{id: "sdb"}},
+			want:    subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		c := &locateCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		devices, searchErr, locateErr := tt.devices, tt.searchErr, tt.locateErr
+		search = func(string) ([]installer.Device, error) { return devices, searchErr }
+		var located string
+		var duration time.Duration
+		locate = func(_ context.Context, d installer.Device, dur time.Duration) error {
+			located, duration = d.Identifier(), dur
+			return locateErr
+		}
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.want == subcommands.ExitSuccess && (located != "sdb" || duration != time.Minute) {
+			t.Errorf("%s: Execute() located %q for %v, want: %q for %v", tt.desc, located, duration, "sdb", time.Minute)
+		}
+	}
+}
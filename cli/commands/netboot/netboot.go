@@ -0,0 +1,260 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netboot implements the netboot subcommand, which stages an
+// installer in a directory to be served for network boot instead of writing
+// it to a device.
+package netboot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/deck/backends/logger"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errConfig    = errors.New("config error")
+	errElevation = errors.New("elevation error")
+	errFinalize  = errors.New("finalize error")
+	errInstaller = errors.New("installer error")
+	errRetrieve  = errors.New("retrieve error")
+	errStage     = errors.New("stage error")
+
+	// Dependency injections for testing.
+	execute           = run
+	newInstaller      = installerNew
+	loadDistributions = config.LoadDistributions
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&netbootCmd{}, "")
+}
+
+// stager is the subset of installer.Installer used to stage an installer.
+type stager interface {
+	Retrieve() error
+	Stage(string) error
+	Finalize([]installer.Device, bool) error
+}
+
+// installerNew wraps installer.New and returns an appropriate interface.
+func installerNew(config installer.Configuration) (stager, error) {
+	return installer.New(config)
+}
+
+// netbootCmd is the netboot subcommand to download an installer and stage its
+// contents in a directory for PXE or UEFI HTTP boot.
+type netbootCmd struct {
+	// cleanup determines whether temporary files generated during staging are
+	// cleaned up afterwards. Defaults to true.
+	cleanup bool
+
+	// distro specifies the OS distribution to be staged. The available values
+	// are determined by the config package.
+	distro string
+
+	// track specifies the distribution track or variant of the image to stage.
+	track string
+
+	// ffu determines whether the FFU configuration is staged alongside the
+	// installer.
+	ffu bool
+
+	// confTrack specifies the track of the configuration file to stage. It is
+	// only used with ffu.
+	confTrack string
+
+	// preserve keeps the modification times and, on Windows, the basic
+	// attributes of files extracted from the ISO. Defaults to true.
+	preserve bool
+
+	// seedServer permits overriding the default server used to obtain a seed
+	// for distributions that require them.
+	seedServer string
+
+	// env selects a deployment of the backend, such as 'dev' for a server
+	// running locally. It cannot be combined with seedServer.
+	env string
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones.
+	configFile string
+
+	// info causes console messages to be displayed with debugging information
+	// included.
+	info bool
+
+	// v controls the level of log verbosity.
+	v int
+
+	// debug is a comma separated list of the debug categories to log,
+	// regardless of v.
+	debug string
+}
+
+// Ensure netbootCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*netbootCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *netbootCmd) Name() string {
+	return "netboot"
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *netbootCmd) Synopsis() string {
+	return "Stage an installer in a directory for network boot"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *netbootCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [directory]
+
+Download an installer and extract it to a directory that can be served by a
+PXE/TFTP or HTTP server, instead of writing it to a storage device. A seed is
+obtained for distributions that require one, and an iPXE script named
+boot.ipxe is generated that boots the installer using wimboot. wimboot itself
+is not included and must be served from the same directory.
+
+The directory must be empty or not yet exist. Only ISO based distributions
+can be staged. This operation requires permission to mount images, such as
+'sudo' on Linux/Mac or 'run as administrator' on Windows.
+
+Flags:
+  --distro     - The os distribution to be staged, such as 'windows'.
+  --track      - The track (variant) of the installer to stage.
+  --ffu        - Also stage the FFU configuration for the distribution.
+  --conf_track - The track (variant) of the configuration to stage.
+  --preserve   - Keep the modification times and attributes of extracted files.
+  --env        - The backend environment to obtain seeds from, such as 'dev'.
+  --config     - A YAML or JSON file of distributions to merge over the built-in ones.
+  --cleanup    - Cleanup temporary files after staging completes.
+  --info       - Display console messages with debugging information included.
+  --v          - Controls the level of info log verbosity.
+  --debug      - Log debugging messages for network, storage, seed and/or copy, such as 'network,copy'.
+
+Example: 'stage a windows installer in /srv/tftp/windows'
+  - '%s netboot -distro=windows -track=stable /srv/tftp/windows'
+
+Defaults:
+`, c.Name(), binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *netbootCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.cleanup, "cleanup", true, "cleanup temporary files after staging is complete")
+	f.StringVar(&c.distro, "distro", "", "the os distribution to be staged, such as 'windows'")
+	f.StringVar(&c.track, "track", "stable", "track (variant) of the installer to stage")
+	f.BoolVar(&c.ffu, "ffu", false, "also stage the ffu configuration for the distribution")
+	f.StringVar(&c.confTrack, "conf_track", "", "track (variant) of the configuration file to stage, only valid with --ffu")
+	f.BoolVar(&c.preserve, "preserve", true, "keep the modification times and, on Windows, the attributes of files extracted from the ISO")
+	f.StringVar(&c.seedServer, "seed_server", "", "override the default server to use for obtaining seeds, only used for debugging")
+	f.StringVar(&c.env, "env", "", "backend environment to obtain seeds and signed URLs from, such as 'dev' for a local server")
+	f.StringVar(&c.configFile, "config", "", "a YAML or JSON file of distributions to merge over the built-in ones, defaults to "+config.DefaultConfigPath())
+	f.BoolVar(&c.info, "info", false, "display console messages with debugging information included")
+	f.IntVar(&c.v, "v", 1, "controls the level of info log verbosity")
+	f.StringVar(&c.debug, "debug", "", "log debugging messages for these comma separated categories regardless of -v: "+debug.Names()+" or all")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *netbootCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.info || c.v > 1 {
+		console.Verbose = true
+	}
+	if console.Verbose {
+		deck.Add(logger.Init(os.Stdout, 0))
+	}
+	deck.SetVerbosity(c.v)
+	if err := debug.Enable(c.debug); err != nil {
+		console.Printf("%v\nusage: %s %s\n", err, binaryName, c.Usage())
+		deck.Error(err)
+		return subcommands.ExitUsageError
+	}
+
+	if f.NArg() != 1 {
+		console.Printf("A single staging directory must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := execute(c, f.Arg(0)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	console.Printf("Staged %s %s in %q. Serve it with wimboot to boot from the network.", c.distro, c.track, f.Arg(0))
+	deck.InfofA("%s completed successfully.", binaryName).With(deck.V(1)).Go()
+	return subcommands.ExitSuccess
+}
+
+// run retrieves the installer and stages it in dir.
+func run(c *netbootCmd, dir string) (err error) {
+	if c.env != "" && c.seedServer != "" {
+		return fmt.Errorf("%w: --env and --seed_server cannot be used together", errConfig)
+	}
+	if !c.ffu {
+		c.confTrack = ""
+	}
+	if err := loadDistributions(c.configFile); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	conf, err := config.New(c.cleanup, false, false, c.ffu, false, false, false, false, c.preserve, nil, c.distro, c.track, c.confTrack, c.seedServer)
+	if err != nil {
+		return fmt.Errorf("%w: config.New(cleanup: %t, ffu: %t, preserve: %t, distro: %s, track: %s, confTrack: %s, seedServer: %s) returned %v",
+			errConfig, c.cleanup, c.ffu, c.preserve, c.distro, c.track, c.confTrack, c.seedServer, err)
+	}
+	if err := conf.UseEnvironment(c.env); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.CanMount(); err != nil {
+		return fmt.Errorf("%w: the netboot command cannot continue: %v", errElevation, err)
+	}
+	i, err := newInstaller(conf)
+	if err != nil {
+		return fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
+	}
+	// Finalize without devices only removes the cache, or reports where it
+	// was retained when cleanup is disabled.
+	defer func() {
+		if err2 := i.Finalize(nil, false); err2 != nil {
+			if err == nil {
+				err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
+			} else {
+				err = fmt.Errorf("%w: %v\nFinalize() returned %v", errFinalize, err, err2)
+			}
+		}
+	}()
+	console.Printf("Retrieving %s %s installer...", c.distro, c.track)
+	if err := i.Retrieve(); err != nil {
+		return fmt.Errorf("%w: %v", errRetrieve, err)
+	}
+	console.Printf("Staging installer in %q...", dir)
+	if err := i.Stage(dir); err != nil {
+		return fmt.Errorf("%w: %v", errStage, err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netboot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+)
+
+type fakeStager struct {
+	retErr   error
+	stageErr error
+	finErr   error
+
+	staged    string
+	finalized bool
+}
+
+func (f *fakeStager) Retrieve() error { return f.retErr }
+
+func (f *fakeStager) Stage(dir string) error {
+	f.staged = dir
+	return f.stageErr
+}
+
+func (f *fakeStager) Finalize([]installer.Device, bool) error {
+	f.finalized = true
+	return f.finErr
+}
+
+func TestExecute(t *testing.T) {
+	defer func() { execute = run }()
+	tests := []struct {
+		desc    string
+		args    []string
+		execErr error
+		want    subcommands.ExitStatus
+	}{
+		{
+			desc: "no directory",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "too many directories",
+			args: []string{"one", "two"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "unknown debug category",
+			args: []string{"--debug=disks", "dir"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:    "run error",
+			args:    []string{"dir"},
+			execErr: errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc: "success",
+			args: []string{"dir"},
+			want: subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		c := &netbootCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		execErr := tt.execErr
+		execute = func(*netbootCmd, string) error { return execErr }
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	capabilityCmd := config.CapabilityCmd
+	defer func() {
+		config.CapabilityCmd = capabilityCmd
+		newInstaller = installerNew
+		loadDistributions = config.LoadDistributions
+	}()
+	tests := []struct {
+		desc          string
+		args          []string
+		loadErr       error
+		capabilityErr error
+		newErr        error
+		stager        *fakeStager
+		wantFinalize  bool
+		want          error
+	}{
+		{
+			desc: "unknown distro",
+			args: []string{"--distro=unknown"},
+			want: errConfig,
+		},
+		{
+			desc:    "config file error",
+			args:    []string{"--distro=windows", "--config=distros.yaml"},
+			loadErr: errors.New("error"),
+			want:    errConfig,
+		},
+		{
+			desc: "environment with seed server",
+			args: []string{"--distro=windows", "--env=dev", "--seed_server=seed.foo.com"},
+			want: errConfig,
+		},
+		{
+			desc: "unknown environment",
+			args: []string{"--distro=windows", "--env=staging"},
+			want: errConfig,
+		},
+		{
+			desc:          "cannot mount",
+			args:          []string{"--distro=windows"},
+			capabilityErr: errors.New("error"),
+			want:          errElevation,
+		},
+		{
+			desc:   "installer error",
+			args:   []string{"--distro=windows"},
+			newErr: errors.New("error"),
+			want:   errInstaller,
+		},
+		{
+			desc:         "retrieve error",
+			args:         []string{"--distro=windows"},
+			stager:       &fakeStager{retErr: errors.New("error")},
+			wantFinalize: true,
+			want:         errRetrieve,
+		},
+		{
+			desc:         "stage error",
+			args:         []string{"--distro=windows"},
+			stager:       &fakeStager{stageErr: errors.New("error")},
+			wantFinalize: true,
+			want:         errStage,
+		},
+		{
+			desc:         "finalize error",
+			args:         []string{"--distro=windows"},
+			stager:       &fakeStager{finErr: errors.New("error")},
+			wantFinalize: true,
+			want:         errFinalize,
+		},
+		{
+			desc:         "no cleanup",
+			args:         []string{"--distro=windows", "--cleanup=false"},
+			stager:       &fakeStager{},
+			wantFinalize: true,
+		},
+		{
+			desc:         "success",
+			args:         []string{"--distro=windows"},
+			stager:       &fakeStager{},
+			wantFinalize: true,
+		},
+	}
+	for _, tt := range tests {
+		c := &netbootCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		loadErr := tt.loadErr
+		loadDistributions = func(string) error { return loadErr }
+		capabilityErr := tt.capabilityErr
+		config.CapabilityCmd = func(config.Capability) error { return capabilityErr }
+		s, newErr := tt.stager, tt.newErr
+		newInstaller = func(installer.Configuration) (stager, error) { return s, newErr }
+
+		got := run(c, "dir")
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: run() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if s == nil {
+			continue
+		}
+		if s.finalized != tt.wantFinalize {
+			t.Errorf("%s: run() finalized: %t, want: %t", tt.desc, s.finalized, tt.wantFinalize)
+		}
+		if tt.want == nil && s.staged != "dir" {
+			t.Errorf("%s: run() staged in %q, want: %q", tt.desc, s.staged, "dir")
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package receive implements the receive subcommand, which duplicates a
+// device served by the stream subcommand of another station to local devices.
+package receive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errConfig    = errors.New("config error")
+	errDevice    = errors.New("device error")
+	errFinalize  = errors.New("finalize error")
+	errInstaller = errors.New("installer error")
+	errManifest  = errors.New("manifest error")
+	errReceive   = errors.New("receive error")
+	errReseed    = errors.New("reseed error")
+	errSearch    = errors.New("search error")
+
+	// Dependency injections for testing.
+	search             = storageSearch
+	fetchManifest      = installer.FetchStreamManifest
+	receive            = installer.Receive
+	prompt             = console.PromptUser
+	funcUSBPermissions = config.HasWritePermissions
+	newInstaller       = installerNew
+	loadDistributions  = config.LoadDistributions
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&receiveCmd{}, "")
+}
+
+// reseeder is the subset of installer.Installer used to replace the seeds of
+// received devices.
+type reseeder interface {
+	Reseed([]installer.Device) error
+	Finalize([]installer.Device, bool) error
+}
+
+// installerNew wraps installer.New and returns an appropriate interface.
+func installerNew(config installer.Configuration) (reseeder, error) {
+	return installer.New(config)
+}
+
+// receiveCmd is the receive subcommand, which writes a device streamed by
+// another station to local devices, so that a single download serves several
+// offices.
+type receiveCmd struct {
+	// distro specifies the OS distribution of the streamed device, which
+	// determines how the seeds of the received devices are replaced.
+	distro string
+
+	// track specifies the distribution track or variant of the streamed
+	// device.
+	track string
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones.
+	configFile string
+
+	// warning determines whether a confirmation prompt is displayed before
+	// the devices are overwritten. Defaults to true.
+	warning bool
+
+	// stateDir is the directory that the progress of each transfer is
+	// recorded in, so that interrupted transfers resume where they stopped.
+	stateDir string
+}
+
+// Ensure receiveCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*receiveCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *receiveCmd) Name() string {
+	return "receive"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*receiveCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *receiveCmd) Synopsis() string {
+	return "Duplicate a device streamed by another station with 'stream'"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *receiveCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [source] [device(s)...]
+
+Duplicate a device that another station serves with 'stream' to one or more
+removable devices, byte for byte. Each chunk of the stream is downloaded once,
+checked against the manifest of hashes signed by the streaming station, and
+written to every device. The devices must be at least as large as the
+partitions streamed, and their contents are destroyed. Progress is recorded so that an
+interrupted transfer resumes where it stopped when it is run again, and every
+device is read back and compared with the manifest once it is written.
+
+The distribution and track of the streamed device must be specified. Each
+device would otherwise hold a copy of the seed of the streamed device, so the
+seeds of the received devices are replaced with distinct seeds obtained in a
+single bulk seed request, which requires permission to make bulk seed requests.
+
+The token of the streaming station must be set in the %s
+environment variable. This operation requires permission to write to devices,
+such as 'sudo' on Linux/Mac or 'run as administrator' on Windows.
+
+Flags:
+  --distro [distro] - The os distribution of the streamed device, such as 'windows'.
+  --track [track] - The track (variant) of the streamed device.
+  --config [path] - A YAML or JSON file of distributions to merge over the built-in ones.
+  --warning - Display a confirmation prompt before the devices are overwritten.
+  --state_dir [path] - The directory that the progress of transfers is recorded in.
+
+Example: 'duplicate the device streamed by station-nyc to sdc and sdd'
+  - '%s receive --distro=windows --track=stable http://station-nyc:8080 sdc sdd'
+
+Defaults:
+`, c.Name(), installer.StreamTokenEnv, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *receiveCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.distro, "distro", "", "the os distribution of the streamed device, such as 'windows'")
+	f.StringVar(&c.track, "track", "stable", "track (variant) of the streamed device")
+	f.StringVar(&c.configFile, "config", "", "a YAML or JSON file of distributions to merge over the built-in ones, defaults to "+config.DefaultConfigPath())
+	f.BoolVar(&c.warning, "warning", true, "display a confirmation prompt before the devices are overwritten")
+	f.StringVar(&c.stateDir, "state_dir", os.TempDir(), "the directory that the progress of transfers is recorded in, so that interrupted transfers resume")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *receiveCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 2 {
+		console.Printf("A source and at least one device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if c.distro == "" {
+		console.Printf("The distribution of the streamed device must be specified with --distro.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(f.Arg(0), f.Args()[1:]); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run writes the device streamed from source to the removable devices with
+// the identifiers ids, and then replaces their seeds.
+func (c *receiveCmd) run(source string, ids []string) (err error) {
+	if err := funcUSBPermissions(); err != nil {
+		if errors.Is(err, config.ErrWritePerms) {
+			console.Printf("%v\nSee %s for more information.", err, config.WritePolicyHelp)
+		}
+		return fmt.Errorf("%w: %v", config.ErrUSBwriteAccess, err)
+	}
+	token := os.Getenv(installer.StreamTokenEnv)
+	if token == "" {
+		return fmt.Errorf("%w: %s must be set to the token of the streaming station", errConfig, installer.StreamTokenEnv)
+	}
+	if err := loadDistributions(c.configFile); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	conf, err := config.New(true, false, false, false, false, false, false, false, false, ids, c.distro, c.track, "", "")
+	if err != nil {
+		return fmt.Errorf("%w: config.New(devices: %v, distro: %s, track: %s) returned %v", errConfig, ids, c.distro, c.track, err)
+	}
+	m, err := fetchManifest(source, token)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errManifest, err)
+	}
+	console.Printf("%q streams %q (%s, %s), hashed on %s.", source, m.Device, m.Name, units.Size(m.Size), m.Created.Format("2006-01-02 15:04 MST"))
+	// Only removable devices are searched, so that a stream is never written
+	// over a fixed disk.
+	var devices []installer.Device
+	for _, id := range ids {
+		found, err := search(id)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errSearch, err)
+		}
+		var d installer.Device
+		for _, dev := range found {
+			if dev.Identifier() == id {
+				d = dev
+			}
+		}
+		if d == nil {
+			return fmt.Errorf("%w: removable device %q was not found, use the 'list' command to list available devices", errDevice, id)
+		}
+		devices = append(devices, d)
+	}
+	if c.warning {
+		targets := make([]console.TargetDevice, 0, len(devices))
+		for _, d := range devices {
+			targets = append(targets, d)
+		}
+		if err := console.PrintDevices(targets, os.Stdout, console.FormatTable); err != nil {
+			return fmt.Errorf("%w: %v", errDevice, err)
+		}
+		if err := prompt(); err != nil {
+			return err
+		}
+	}
+	// The installer is created before the transfer, so that an expired
+	// sign-in is reported before any device is written.
+	i, err := newInstaller(conf)
+	if err != nil {
+		return fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
+	}
+	// Partitions mounted to replace the seeds are dismounted again afterwards.
+	defer func() {
+		if err2 := i.Finalize(devices, true); err2 != nil && err == nil {
+			err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
+		}
+	}()
+	console.Printf("Receiving %q to %v.", source, ids)
+	if err := receive(source, token, m, devices, c.stateDir); err != nil {
+		return fmt.Errorf("%w: %v", errReceive, err)
+	}
+	if err := i.Reseed(devices); err != nil {
+		return fmt.Errorf("%w: %v", errReseed, err)
+	}
+	console.Printf("Duplicated %q from %q to %v.", m.Device, source, ids)
+	return nil
+}
+
+// storageSearch wraps storage.Search and returns the removable devices that
+// match id.
+func storageSearch(id string) ([]installer.Device, error) {
+	devices, err := storage.Search(id, 0, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%q) returned %v", id, err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
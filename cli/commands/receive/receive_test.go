@@ -0,0 +1,233 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+// fakeInstaller records the devices that are reseeded and finalized.
+type fakeInstaller struct {
+	reseedErr   error
+	finalizeErr error
+	reseeded    []string
+	finalized   bool
+}
+
+func (f *fakeInstaller) Reseed(devices []installer.Device) error {
+	for _, d := range devices {
+		f.reseeded = append(f.reseeded, d.Identifier())
+	}
+	return f.reseedErr
+}
+
+func (f *fakeInstaller) Finalize([]installer.Device, bool) error {
+	f.finalized = true
+	return f.finalizeErr
+}
+
+func TestExecute(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		fetchManifest = installer.FetchStreamManifest
+		receive = installer.Receive
+		prompt = console.PromptUser
+		funcUSBPermissions = config.HasWritePermissions
+		newInstaller = installerNew
+		loadDistributions = config.LoadDistributions
+	}()
+	tests := []struct {
+		desc        string
+		args        []string
+		token       string
+		permsErr    error
+		loadErr     error
+		manifestErr error
+		devices     []installer.Device
+		searchErr   error
+		promptErr   error
+		newErr      error
+		receiveErr  error
+		reseedErr   error
+		finalizeErr error
+		want        subcommands.ExitStatus
+	}{
+		{
+			desc: "no device",
+			args: []string{"--distro=windows", "http://station:8080"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "no distro",
+			args: []string{"http://station:8080", "sdd"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:  "unknown distro",
+			args:  []string{"--distro=unknown", "http://station:8080", "sdd"},
+			token: "secret",
+			want:  subcommands.ExitFailure,
+		},
+		{
+			desc:    "config file error",
+			args:    []string{"--distro=windows", "--config=distros.yaml", "http://station:8080", "sdd"},
+			token:   "secret",
+			loadErr: errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:     "write policy",
+			args:     []string{"--distro=windows", "http://station:8080", "sdd"},
+			token:    "secret",
+			permsErr: config.ErrWritePerms,
+			want:     subcommands.ExitFailure,
+		},
+		{
+			desc: "no token",
+			args: []string{"--distro=windows", "http://station:8080", "sdd"},
+			want: subcommands.ExitFailure,
+		},
+		{
+			desc:        "manifest error",
+			args:        []string{"--distro=windows", "http://station:8080", "sdd"},
+			token:       "secret",
+			manifestErr: errors.New("error"),
+			want:        subcommands.ExitFailure,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"--distro=windows", "http://station:8080", "sdd"},
+			token:     "secret",
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "not found",
+			args:    []string{"--distro=windows", "http://station:8080", "sdd", "sde"},
+			token:   "secret",
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "declined",
+			args:      []string{"--distro=windows", "http://station:8080", "sdd"},
+			token:     "secret",
+			devices:   []installer.Device{&fakeDevice{id: "sdd"}},
+			promptErr: errors.New("canceled"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:       "receive error",
+			args:       []string{"--distro=windows", "--warning=false", "http://station:8080", "sdd"},
+			token:      "secret",
+			devices:    []installer.Device{&fakeDevice{id: "sdd"}},
+			receiveErr: errors.New("error"),
+			want:       subcommands.ExitFailure,
+		},
+		{
+			desc:    "installer error",
+			args:    []string{"--distro=windows", "--warning=false", "http://station:8080", "sdd"},
+			token:   "secret",
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			newErr:  errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "reseed error",
+			args:      []string{"--distro=windows", "--warning=false", "http://station:8080", "sdd"},
+			token:     "secret",
+			devices:   []installer.Device{&fakeDevice{id: "sdd"}},
+			reseedErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:        "finalize error",
+			args:        []string{"--distro=windows", "--warning=false", "http://station:8080", "sdd"},
+			token:       "secret",
+			devices:     []installer.Device{&fakeDevice{id: "sdd"}},
+			finalizeErr: errors.New("error"),
+			want:        subcommands.ExitFailure,
+		},
+		{
+			desc:    "success",
+			args:    []string{"--distro=windows", "--warning=false", "--state_dir=state", "http://station:8080", "sdd", "sde"},
+			token:   "secret",
+			devices: []installer.Device{&fakeDevice{id: "sdd"}, &fakeDevice{id: "sde"}},
+			want:    subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		t.Setenv(installer.StreamTokenEnv, tt.token)
+		c := &receiveCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		permsErr, loadErr, manifestErr, devices, searchErr, promptErr, newErr, receiveErr := tt.permsErr, tt.loadErr, tt.manifestErr, tt.devices, tt.searchErr, tt.promptErr, tt.newErr, tt.receiveErr
+		funcUSBPermissions = func() error { return permsErr }
+		loadDistributions = func(string) error { return loadErr }
+		inst := &fakeInstaller{reseedErr: tt.reseedErr, finalizeErr: tt.finalizeErr}
+		newInstaller = func(installer.Configuration) (reseeder, error) { return inst, newErr }
+		fetchManifest = func(string, string) (*installer.StreamManifest, error) {
+			if manifestErr != nil {
+				return nil, manifestErr
+			}
+			return &installer.StreamManifest{Device: "sdc", Size: 4 << 30, Created: time.Now()}, nil
+		}
+		search = func(string) ([]installer.Device, error) { return devices, searchErr }
+		prompt = func() error { return promptErr }
+		var received []string
+		var state string
+		receive = func(source, token string, m *installer.StreamManifest, ds []installer.Device, dir string) error {
+			for _, d := range ds {
+				received = append(received, d.Identifier())
+			}
+			state = dir
+			return receiveErr
+		}
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.want == subcommands.ExitSuccess && (len(received) != 2 || received[0] != "sdd" || received[1] != "sde" || state != "state") {
+			t.Errorf("%s: Execute() received to %v with state in %q, want: [sdd sde] with state in %q", tt.desc, received, state, "state")
+		}
+		if tt.want == subcommands.ExitSuccess && (len(inst.reseeded) != 2 || !inst.finalized) {
+			t.Errorf("%s: Execute() reseeded %v and finalized: %t, want: [sdd sde] and finalized", tt.desc, inst.reseeded, inst.finalized)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restore implements the restore subcommand, which writes a device
+// captured with the capture subcommand to lab media.
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errConfig    = errors.New("config error")
+	errDevice    = errors.New("device error")
+	errFile      = errors.New("file error")
+	errFinalize  = errors.New("finalize error")
+	errInstaller = errors.New("installer error")
+	errReseed    = errors.New("reseed error")
+	errRestore   = errors.New("restore error")
+	errSearch    = errors.New("search error")
+
+	// Dependency injections for testing.
+	search             = storageSearch
+	restore            = installer.Restore
+	prompt             = console.PromptUser
+	funcUSBPermissions = config.HasWritePermissions
+	newInstaller       = installerNew
+	loadDistributions  = config.LoadDistributions
+)
+
+// reseeder is the subset of installer.Installer used to replace the seed of
+// a restored device.
+type reseeder interface {
+	Reseed([]installer.Device) error
+	Finalize([]installer.Device, bool) error
+}
+
+// installerNew wraps installer.New and returns an appropriate interface.
+func installerNew(config installer.Configuration) (reseeder, error) {
+	return installer.New(config)
+}
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&restoreCmd{}, "")
+}
+
+// restoreCmd is the restore subcommand, which writes a block capture of a
+// device to lab media so that a failure seen in the field can be reproduced.
+type restoreCmd struct {
+	// distro specifies the OS distribution of the captured device. When set,
+	// the seed of the restored device is replaced with one issued for it.
+	distro string
+
+	// track specifies the distribution track or variant of the captured
+	// device.
+	track string
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones.
+	configFile string
+
+	// warning determines whether a confirmation prompt is displayed before
+	// the device is overwritten. Defaults to true.
+	warning bool
+}
+
+// Ensure restoreCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*restoreCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *restoreCmd) Name() string {
+	return "restore"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*restoreCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *restoreCmd) Synopsis() string {
+	return "Write a device captured with 'capture' to lab media"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *restoreCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [capture] [device]
+
+Write a capture made with 'capture --mode=block' to a removable device, byte
+for byte, so that a device that fails in the field can be reproduced on lab
+media. The device must be at least as large as the one that was captured, and
+its contents are destroyed. The contents written are checked against the hash
+recorded when they were captured. Captures made with '--mode=files' cannot be
+restored, and are extracted with tar instead. This operation requires
+permission to write to devices, such as 'sudo' on Linux/Mac or 'run as
+administrator' on Windows.
+
+The restored device holds the seed of the captured device. When the
+distribution of the captured device is given with --distro, that seed is
+replaced with one issued to the current user by a bulk seed request, so that
+the lab media can be told apart from the field device.
+
+Flags:
+  --distro [distro] - The os distribution of the captured device, to replace its seed.
+  --track [track] - The track (variant) of the captured device.
+  --config [path] - A YAML or JSON file of distributions to merge over the built-in ones.
+  --warning - Display a confirmation prompt before the device is overwritten.
+
+Example: 'reproduce the capture of a field device on lab device sdd'
+  - '%s restore sdc.tar.gz sdd'
+
+Defaults:
+`, c.Name(), binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *restoreCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.distro, "distro", "", "the os distribution of the captured device, such as 'windows', whose seed is replaced when set")
+	f.StringVar(&c.track, "track", "stable", "track (variant) of the captured device")
+	f.StringVar(&c.configFile, "config", "", "a YAML or JSON file of distributions to merge over the built-in ones, defaults to "+config.DefaultConfigPath())
+	f.BoolVar(&c.warning, "warning", true, "display a confirmation prompt before the device is overwritten")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *restoreCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		console.Printf("A capture and a single device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(f.Arg(0), f.Arg(1)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run writes the capture at path to the removable device with the identifier
+// id, and replaces its seed when the distribution of the capture is known.
+func (c *restoreCmd) run(path, id string) (err error) {
+	if err := funcUSBPermissions(); err != nil {
+		if errors.Is(err, config.ErrWritePerms) {
+			console.Printf("%v\nSee %s for more information.", err, config.WritePolicyHelp)
+		}
+		return fmt.Errorf("%w: %v", config.ErrUSBwriteAccess, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errFile, err)
+	}
+	defer f.Close()
+	// Only removable devices are searched, so that a capture is never written
+	// over a fixed disk.
+	devices, err := search(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSearch, err)
+	}
+	var d installer.Device
+	for _, dev := range devices {
+		if dev.Identifier() == id {
+			d = dev
+		}
+	}
+	if d == nil {
+		return fmt.Errorf("%w: removable device %q was not found, use the 'list' command to list available devices", errDevice, id)
+	}
+	// The installer is created before the device is written, so that an
+	// expired sign-in is reported first.
+	var i reseeder
+	if c.distro != "" {
+		if err := loadDistributions(c.configFile); err != nil {
+			return fmt.Errorf("%w: %v", errConfig, err)
+		}
+		var conf *config.Configuration
+		conf, err = config.New(true, false, false, false, false, false, false, false, false, []string{id}, c.distro, c.track, "", "")
+		if err != nil {
+			return fmt.Errorf("%w: config.New(device: %s, distro: %s, track: %s) returned %v", errConfig, id, c.distro, c.track, err)
+		}
+		if i, err = newInstaller(conf); err != nil {
+			return fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
+		}
+		// The partition mounted to replace the seed is dismounted again
+		// afterwards.
+		defer func() {
+			if err2 := i.Finalize([]installer.Device{d}, true); err2 != nil && err == nil {
+				err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
+			}
+		}()
+	}
+	if c.warning {
+		if err := console.PrintDevices([]console.TargetDevice{d}, os.Stdout, console.FormatTable); err != nil {
+			return fmt.Errorf("%w: %v", errDevice, err)
+		}
+		if err := prompt(); err != nil {
+			return err
+		}
+	}
+	console.Printf("Restoring %q to %q (%s).", path, id, d.FriendlyName())
+	meta, err := restore(f, d)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRestore, err)
+	}
+	console.Printf("Restored %q, captured from %q (%s) on %s, to %q.", path, meta.Device, meta.Name, meta.Captured.Format("2006-01-02 15:04 MST"), id)
+	if meta.Note != "" {
+		console.Printf("Note: %s", meta.Note)
+	}
+	if i == nil {
+		return nil
+	}
+	if err := i.Reseed([]installer.Device{d}); err != nil {
+		return fmt.Errorf("%w: %v", errReseed, err)
+	}
+	return nil
+}
+
+// storageSearch wraps storage.Search and returns the removable devices that
+// match id.
+func storageSearch(id string) ([]installer.Device, error) {
+	devices, err := storage.Search(id, 0, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%q) returned %v", id, err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
+// fakeInstaller records the restored device that is reseeded, and whether
+// it was finalized.
+type fakeInstaller struct {
+	reseedErr   error
+	finalizeErr error
+	reseeded    string
+	finalized   bool
+}
+
+func (f *fakeInstaller) Reseed(devices []installer.Device) error {
+	f.reseeded = devices[0].Identifier()
+	return f.reseedErr
+}
+
+func (f *fakeInstaller) Finalize([]installer.Device, bool) error {
+	f.finalized = true
+	return f.finalizeErr
+}
+
+func TestExecute(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		restore = installer.Restore
+		prompt = console.PromptUser
+		funcUSBPermissions = config.HasWritePermissions
+		newInstaller = installerNew
+		loadDistributions = config.LoadDistributions
+	}()
+	capture := filepath.Join(t.TempDir(), "sdc.tar.gz")
+	if err := ioutil.WriteFile(capture, []byte("capture"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", capture, err)
+	}
+	tests := []struct {
+		desc        string
+		args        []string
+		permsErr    error
+		devices     []installer.Device
+		searchErr   error
+		promptErr   error
+		loadErr     error
+		newErr      error
+		restoreErr  error
+		reseedErr   error
+		finalizeErr error
+		wantReseed  bool
+		want        subcommands.ExitStatus
+	}{
+		{
+			desc: "no device",
+			args: []string{capture},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:     "write policy",
+			args:     []string{capture, "sdd"},
+			permsErr: config.ErrWritePerms,
+			want:     subcommands.ExitFailure,
+		},
+		{
+			desc: "missing capture",
+			args: []string{capture + ".missing", "sdd"},
+			want: subcommands.ExitFailure,
+		},
+		{
+			desc:      "search error",
+			args:      []string{capture, "sdd"},
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "not found",
+			args:    []string{capture, "sdd"},
+			devices: []installer.Device{&fakeDevice{id: "sdc"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "declined",
+			args:      []string{capture, "sdd"},
+			devices:   []installer.Device{&fakeDevice{id: "sdd"}},
+			promptErr: errors.New("canceled"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:       "restore error",
+			args:       []string{"--warning=false", capture, "sdd"},
+			devices:    []installer.Device{&fakeDevice{id: "sdd"}},
+			restoreErr: errors.New("error"),
+			want:       subcommands.ExitFailure,
+		},
+		{
+			desc:    "success",
+			args:    []string{"--warning=false", capture, "sdd"},
+			devices: []installer.Device{&fakeDevice{id: "sdc"}, &fakeDevice{id: "sdd"}},
+			want:    subcommands.ExitSuccess,
+		},
+		{
+			desc:    "unknown distro",
+			args:    []string{"--warning=false", "--distro=unknown", capture, "sdd"},
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:    "config file error",
+			args:    []string{"--warning=false", "--distro=windows", "--config=distros.yaml", capture, "sdd"},
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			loadErr: errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:    "installer error",
+			args:    []string{"--warning=false", "--distro=windows", capture, "sdd"},
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			newErr:  errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "reseed error",
+			args:      []string{"--warning=false", "--distro=windows", capture, "sdd"},
+			devices:   []installer.Device{&fakeDevice{id: "sdd"}},
+			reseedErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:        "finalize error",
+			args:        []string{"--warning=false", "--distro=windows", capture, "sdd"},
+			devices:     []installer.Device{&fakeDevice{id: "sdd"}},
+			finalizeErr: errors.New("error"),
+			want:        subcommands.ExitFailure,
+		},
+		{
+			desc:       "success with reseed",
+			args:       []string{"--warning=false", "--distro=windows", capture, "sdd"},
+			devices:    []installer.Device{&fakeDevice{id: "sdd"}},
+			wantReseed: true,
+			want:       subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		c := &restoreCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		permsErr, devices, searchErr, promptErr, loadErr, newErr, restoreErr := tt.permsErr, tt.devices, tt.searchErr, tt.promptErr, tt.loadErr, tt.newErr, tt.restoreErr
+		funcUSBPermissions = func() error { return permsErr }
+		loadDistributions = func(string) error { return loadErr }
+		inst := &fakeInstaller{reseedErr: tt.reseedErr, finalizeErr: tt.finalizeErr}
+		newInstaller = func(installer.Configuration) (reseeder, error) { return inst, newErr }
+		search = func(string) ([]installer.Device, error) { return devices, searchErr }
+		prompt = func() error { return promptErr }
+		var restored, contents string
+		restore = func(r io.Reader, d installer.Device) (*installer.CaptureMetadata, error) {
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			restored, contents = d.Identifier(), string(b)
+			return &installer.CaptureMetadata{Device: "sdc", Captured: time.Now(), Note: "ticket 1234"}, restoreErr
+		}
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.want == subcommands.ExitSuccess && (restored != "sdd" || contents != "capture") {
+			t.Errorf("%s: Execute() restored %q to %q, want: %q to %q", tt.desc, contents, restored, "capture", "sdd")
+		}
+		if tt.want == subcommands.ExitSuccess && (inst.reseeded == "sdd") != tt.wantReseed {
+			t.Errorf("%s: Execute() reseeded %q, want reseeded: %t", tt.desc, inst.reseeded, tt.wantReseed)
+		}
+		if tt.wantReseed && !inst.finalized {
+			t.Errorf("%s: Execute() did not finalize the reseeded device", tt.desc)
+		}
+	}
+}
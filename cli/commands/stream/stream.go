@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stream implements the stream subcommand, which serves the contents
+// of a provisioned device over the network to stations that duplicate it with
+// the receive subcommand.
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// defaultListen is the address that devices are streamed on by default.
+const defaultListen = ":8080"
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errDevice = errors.New("device error")
+	errSearch = errors.New("search error")
+	errSource = errors.New("source error")
+	errServe  = errors.New("serve error")
+
+	// Dependency injections for testing.
+	search    = storageSearch
+	newSource = sourceNew
+	serve     = (*http.Server).ListenAndServe
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&streamCmd{}, "")
+}
+
+// source is the subset of installer.StreamSource used to stream a device.
+type source interface {
+	http.Handler
+	Manifest() installer.StreamManifest
+	Close() error
+}
+
+// sourceNew wraps installer.NewStreamSource and returns an appropriate
+// interface.
+func sourceNew(d installer.Device, chunkSize int64, token string) (source, error) {
+	s, err := installer.NewStreamSource(d, chunkSize, token)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// streamCmd is the stream subcommand, which serves a provisioned device so
+// that a single download can be duplicated to devices in other offices.
+type streamCmd struct {
+	// listen is the address that the device is served on, such as ':8080'.
+	listen string
+
+	// chunkSize is the size of the chunks that the device is streamed in,
+	// such as '32M'. Values without a suffix are in MB.
+	chunkSize units.Value
+}
+
+// Ensure streamCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*streamCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *streamCmd) Name() string {
+	return "stream"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*streamCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *streamCmd) Synopsis() string {
+	return "Serve a provisioned device to stations that duplicate it with 'receive'"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *streamCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [device]
+
+Serve the partitions of a provisioned removable device over HTTP, so that
+stations in other offices can duplicate it to their own devices with 'receive'
+rather than each downloading and provisioning the image. Only the part of the
+device up to the end of its last partition is served. The device is read once
+to hash it in chunks, and each chunk is checked against its hash again before it
+is served, so that a device that changes or fails while it is streamed is not
+duplicated. Nothing is written to the device.
+
+Receiving stations must present the token in the %s environment
+variable. When it is not set, a token is generated and displayed. The token
+authenticates requests and the manifest of chunk hashes, but the contents of
+the device are not encrypted, so devices should only be streamed over trusted
+networks or a VPN. Streaming continues until interrupted.
+
+Flags:
+  --listen [address] - The address to serve the device on, such as ':8080'.
+  --chunk_size [size] - The size of the chunks that the device is streamed in, such as '32M'.
+
+Example: 'serve the provisioned device sdc to other offices'
+  - '%s stream sdc'
+
+Defaults:
+`, c.Name(), installer.StreamTokenEnv, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *streamCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.listen, "listen", defaultListen, "the address to serve the device on, such as ':8080'")
+	c.chunkSize = units.Value{Size: installer.DefaultStreamChunkSize, Unit: units.MB}
+	f.Var(&c.chunkSize, "chunk_size", "the size of the chunks that the device is streamed in, such as '32M' [MB if no suffix], a multiple of 512 bytes")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *streamCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		console.Printf("A single device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(ctx, f.Arg(0)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run serves the removable device with the identifier id until ctx is done.
+func (c *streamCmd) run(ctx context.Context, id string) error {
+	token := os.Getenv(installer.StreamTokenEnv)
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("%w: generating a token returned %v", errServe, err)
+		}
+		token = hex.EncodeToString(b)
+		console.Printf("%s is not set, receiving stations must set it to %q.", installer.StreamTokenEnv, token)
+	}
+	// Only removable devices are searched, so that the contents of a fixed
+	// disk are never served.
+	devices, err := search(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSearch, err)
+	}
+	var d installer.Device
+	for _, dev := range devices {
+		if dev.Identifier() == id {
+			d = dev
+		}
+	}
+	if d == nil {
+		return fmt.Errorf("%w: removable device %q was not found, use the 'list' command to list available devices", errDevice, id)
+	}
+	console.Printf("Hashing %q (%s) to stream it.", id, d.FriendlyName())
+	src, err := newSource(d, int64(c.chunkSize.Size), token)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSource, err)
+	}
+	defer src.Close()
+	m := src.Manifest()
+	host, _ := os.Hostname()
+	console.Printf("Streaming %q (%s, %d chunks) on %s until interrupted. On each receiving station, with %s set, run:\n  %s receive http://%s%s [devices]",
+		id, units.Size(m.Size), len(m.Chunks), c.listen, installer.StreamTokenEnv, binaryName, host, port(c.listen))
+	deck.InfofA("Streaming %q with manifest %q on %s.", id, m.ID(), c.listen).With(deck.V(1)).Go()
+
+	srv := &http.Server{Addr: c.listen, Handler: src}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			srv.Shutdown(context.Background())
+		case <-done:
+		}
+	}()
+	if err := serve(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%w: %v", errServe, err)
+	}
+	console.Printf("Stopped streaming %q.", id)
+	return nil
+}
+
+// port returns the port of listen, including its colon, or an empty string
+// when it has none.
+func port(listen string) string {
+	if i := strings.LastIndex(listen, ":"); i >= 0 {
+		return listen[i:]
+	}
+	return ""
+}
+
+// storageSearch wraps storage.Search and returns the removable devices that
+// match id.
+func storageSearch(id string) ([]installer.Device, error) {
+	devices, err := storage.Search(id, 0, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search(%q) returned %v", id, err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
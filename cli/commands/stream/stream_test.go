@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+// fakeDevice inherits all members of storage.Device through embedding.
+// Unimplemented members will panic if called.
+type fakeDevice struct {
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
+// fakeSource is a device that is streamed.
+type fakeSource struct {
+	http.Handler
+	closed bool
+}
+
+func (f *fakeSource) Manifest() installer.StreamManifest {
+	return installer.StreamManifest{Size: 8 << 30, Chunks: make([]string, 256)}
+}
+
+func (f *fakeSource) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestExecute(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		newSource = sourceNew
+		serve = (*http.Server).ListenAndServe
+	}()
+	t.Setenv(installer.StreamTokenEnv, "")
+	tests := []struct {
+		desc      string
+		args      []string
+		devices   []installer.Device
+		searchErr error
+		sourceErr error
+		serveErr  error
+		want      subcommands.ExitStatus
+	}{
+		{
+			desc: "no device",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"sdc"},
+			searchErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:    "not found",
+			args:    []string{"sdc"},
+			devices: []installer.Device{&fakeDevice{id: "sdd"}},
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:      "source error",
+			args:      []string{"sdc"},
+			devices:   []installer.Device{&fakeDevice{id: "sdc"}},
+			sourceErr: errors.New("error"),
+			want:      subcommands.ExitFailure,
+		},
+		{
+			desc:     "serve error",
+			args:     []string{"sdc"},
+			devices:  []installer.Device{&fakeDevice{id: "sdc"}},
+			serveErr: errors.New("address in use"),
+			want:     subcommands.ExitFailure,
+		},
+		{
+			desc:     "interrupted",
+			args:     []string{"--listen=:9000", "sdc"},
+			devices:  []installer.Device{&fakeDevice{id: "sdc"}},
+			serveErr: http.ErrServerClosed,
+			want:     subcommands.ExitSuccess,
+		},
+	}
+	for _, tt := range tests {
+		c := &streamCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		devices, searchErr, sourceErr, serveErr := tt.devices, tt.searchErr, tt.sourceErr, tt.serveErr
+		search = func(string) ([]installer.Device, error) { return devices, searchErr }
+		src := &fakeSource{}
+		var token string
+		newSource = func(d installer.Device, chunkSize int64, tok string) (source, error) {
+			token = tok
+			if sourceErr != nil {
+				return nil, sourceErr
+			}
+			return src, nil
+		}
+		var addr string
+		serve = func(s *http.Server) error {
+			addr = s.Addr
+			return serveErr
+		}
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.want != subcommands.ExitSuccess {
+			continue
+		}
+		if addr != ":9000" || len(token) != 32 || !src.closed {
+			t.Errorf("%s: Execute() served on %q with token %q, closed: %t, want: %q with a generated token, closed", tt.desc, addr, token, src.closed, ":9000")
+		}
+	}
+}
+
+func TestExecuteToken(t *testing.T) {
+	defer func() {
+		search = storageSearch
+		newSource = sourceNew
+		serve = (*http.Server).ListenAndServe
+	}()
+	t.Setenv(installer.StreamTokenEnv, "shared")
+	search = func(string) ([]installer.Device, error) { return []installer.Device{&fakeDevice{id: "sdc"}}, nil }
+	var token string
+	newSource = func(d installer.Device, chunkSize int64, tok string) (source, error) {
+		token = tok
+		return &fakeSource{}, nil
+	}
+	// Streaming stops when the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	serve = func(s *http.Server) error {
+		cancel()
+		<-ctx.Done()
+		return http.ErrServerClosed
+	}
+	c := &streamCmd{}
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.SetFlags(f)
+	f.Parse([]string{"sdc"})
+	if got := c.Execute(ctx, f); got != subcommands.ExitSuccess {
+		t.Errorf("Execute() got: %d, want: %d", got, subcommands.ExitSuccess)
+	}
+	if token != os.Getenv(installer.StreamTokenEnv) {
+		t.Errorf("Execute() streamed with token %q, want: %q", token, "shared")
+	}
+}
+
+func TestPort(t *testing.T) {
+	tests := []struct {
+		listen string
+		want   string
+	}{
+		{":8080", ":8080"},
+		{"10.0.0.1:9000", ":9000"},
+		{"localhost", ""},
+	}
+	for _, tt := range tests {
+		if got := port(tt.listen); got != tt.want {
+			t.Errorf("port(%q) got: %q, want: %q", tt.listen, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify implements the verify subcommand, which audits devices to
+// find out whether they hold a correctly provisioned, current installer.
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/deck/backends/logger"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/models"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errAudit     = errors.New("audit error")
+	errConfig    = errors.New("config error")
+	errDevice    = errors.New("device error")
+	errElevation = errors.New("elevation error")
+	errFailed    = errors.New("verification failed")
+	errFinalize  = errors.New("finalize error")
+	errInstaller = errors.New("installer error")
+	errKeys      = errors.New("keys error")
+	errRetrieve  = errors.New("retrieve error")
+	errSearch    = errors.New("search error")
+
+	// Dependency injections for testing.
+	execute           = run
+	search            = storageSearch
+	newInstaller      = installerNew
+	loadDistributions = config.LoadDistributions
+	output            = io.Writer(os.Stdout)
+)
+
+// reportColumns are the columns of the report of a verification.
+var reportColumns = []console.Column{
+	{Title: "Device", Key: "device"},
+	{Title: "Check", Key: "check"},
+	{Title: "Result", Key: "result"},
+	{Title: "Detail", Key: "detail"},
+}
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&verifyCmd{}, "")
+}
+
+// auditor is the subset of installer.Installer used to audit devices.
+type auditor interface {
+	Retrieve() error
+	Audit(installer.Device, *models.KeysResponse) ([]installer.AuditCheck, error)
+	Finalize([]installer.Device, bool) error
+}
+
+// installerNew wraps installer.New and returns an appropriate interface.
+func installerNew(config installer.Configuration) (auditor, error) {
+	return installer.New(config)
+}
+
+// verifyCmd is the verify subcommand, which checks devices against the
+// current installer for a distribution and track without writing to them.
+type verifyCmd struct {
+	// distro specifies the OS distribution that devices are expected to hold.
+	distro string
+
+	// track specifies the distribution track or variant that devices are
+	// expected to hold.
+	track string
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones.
+	configFile string
+
+	// cacheDir is a directory that the image is cached in, and reused from
+	// when it was cached by an earlier run.
+	cacheDir string
+
+	// cleanup determines whether the image is removed once verification
+	// completes. Defaults to true.
+	cleanup bool
+
+	// keys is the path of a file of certificates cached by the verify-seed
+	// command, used to check the signature of seeds.
+	keys string
+
+	// output is the format of the report: table, json, csv or yaml. Formats
+	// other than table silence any unnecessary text output.
+	output string
+
+	// info causes console messages to be displayed with debugging information
+	// included.
+	info bool
+
+	// v controls the level of log verbosity.
+	v int
+
+	// debug is a comma separated list of the debug categories to log,
+	// regardless of v.
+	debug string
+}
+
+// Ensure verifyCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*verifyCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *verifyCmd) Name() string {
+	return "verify"
+}
+
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*verifyCmd) AccessesDevices() bool {
+	return true
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *verifyCmd) Synopsis() string {
+	return "Check that devices hold a correctly provisioned, current installer"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *verifyCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [device...]
+
+Check whether devices hold a correctly provisioned copy of the current
+installer for a distribution and track, such as when auditing media that has
+been in storage. The current image is retrieved, and each device is checked
+for the expected partition label, contents that match the image, and a seed
+that is present and unexpired. When certificates cached by the verify-seed
+command are provided with --keys, the signature of the seed is checked too.
+Nothing is written to the devices.
+
+A report with the result of each check for each device is written to standard
+output. The command fails when any check fails. This operation requires
+permission to mount devices and images, such as 'sudo' on Linux/Mac or 'run as
+administrator' on Windows.
+
+Flags:
+  --distro     - The os distribution that devices are expected to hold, such as 'windows'.
+  --track      - The track (variant) of the installer that devices are expected to hold.
+  --config     - A YAML or JSON file of distributions to merge over the built-in ones.
+  --cache_dir  - Cache the image in this directory, reusing it when already present.
+  --cleanup    - Cleanup temporary files after verification completes.
+  --keys       - A file of certificates cached by verify-seed, to check seed signatures.
+  --output     - The format of the report: 'table', 'json', 'csv' or 'yaml'.
+  --info       - Display console messages with debugging information included.
+  --v          - Controls the level of info log verbosity.
+  --debug      - Log debugging messages for network, storage, seed and/or copy, such as 'storage'.
+
+Example: 'check that devices sdc and sdd hold the stable windows installer'
+  - '%s verify --distro=windows --track=stable --keys=keys.json --output=json sdc sdd'
+
+Defaults:
+`, c.Name(), binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *verifyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.distro, "distro", "", "the os distribution that devices are expected to hold, such as 'windows'")
+	f.StringVar(&c.track, "track", "stable", "track (variant) of the installer that devices are expected to hold")
+	f.StringVar(&c.configFile, "config", "", "a YAML or JSON file of distributions to merge over the built-in ones, defaults to "+config.DefaultConfigPath())
+	f.StringVar(&c.cacheDir, "cache_dir", "", "cache the image in this directory, reusing it when it is already present")
+	f.BoolVar(&c.cleanup, "cleanup", true, "cleanup temporary files after verification is complete")
+	f.StringVar(&c.keys, "keys", "", "a file of certificates cached by the verify-seed command, used to check the signature of seeds")
+	f.StringVar(&c.output, "output", string(console.FormatTable), fmt.Sprintf("the format of the report, one of %v", console.Formats))
+	f.BoolVar(&c.info, "info", false, "display console messages with debugging information included")
+	f.IntVar(&c.v, "v", 1, "controls the level of info log verbosity")
+	f.StringVar(&c.debug, "debug", "", "log debugging messages for these comma separated categories regardless of -v: "+debug.Names()+" or all")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *verifyCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	format, err := console.ParseFormat(c.output)
+	if err != nil {
+		console.Printf("%v\nusage: %s %s\n", err, binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if c.info || c.v > 1 {
+		console.Verbose = true
+	}
+	if console.Verbose {
+		deck.Add(logger.Init(os.Stdout, 0))
+	}
+	deck.SetVerbosity(c.v)
+	if err := debug.Enable(c.debug); err != nil {
+		console.Printf("%v\nusage: %s %s\n", err, binaryName, c.Usage())
+		deck.Error(err)
+		return subcommands.ExitUsageError
+	}
+	// Only the report is written when it is to be consumed by other tools.
+	if format != console.FormatTable {
+		console.Verbose = true
+	}
+
+	if f.NArg() == 0 {
+		console.Printf("At least one device must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	report, err := execute(c, f.Args())
+	if report != nil {
+		if err2 := writeReport(report, format); err2 != nil {
+			deck.Errorf("writeReport(%q) returned %v", format, err2)
+			return subcommands.ExitFailure
+		}
+	}
+	if err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	deck.InfofA("%s completed successfully.", binaryName).With(deck.V(1)).Go()
+	return subcommands.ExitSuccess
+}
+
+// writeReport writes report to output in format.
+func writeReport(report *console.Report, format console.Format) error {
+	fw, err := console.NewFormatWriter(format)
+	if err != nil {
+		return err
+	}
+	if err := fw.Write(output, report); err != nil {
+		return err
+	}
+	// Terminate the report, as only the table writer ends with a new line.
+	if format == console.FormatJSON {
+		_, err = fmt.Fprintln(output)
+	}
+	return err
+}
+
+// run retrieves the current image and audits each of the devices identified
+// by ids with it. A report of the checks made is returned alongside an error
+// when a check failed, so that it can still be written.
+func run(c *verifyCmd, ids []string) (report *console.Report, err error) {
+	if err := loadDistributions(c.configFile); err != nil {
+		return nil, fmt.Errorf("%w: %v", errConfig, err)
+	}
+	conf, err := config.New(c.cleanup, false, false, false, false, false, false, false, false, ids, c.distro, c.track, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: config.New(cleanup: %t, devices: %v, distro: %s, track: %s) returned %v",
+			errConfig, c.cleanup, ids, c.distro, c.track, err)
+	}
+	if err := conf.UseCacheDir(c.cacheDir); err != nil {
+		return nil, fmt.Errorf("%w: %v", errConfig, err)
+	}
+	var keys *models.KeysResponse
+	if c.keys != "" {
+		if keys, err = readKeys(c.keys); err != nil {
+			return nil, err
+		}
+	}
+	if err := conf.CanMount(); err != nil {
+		return nil, fmt.Errorf("%w: the verify command cannot continue: %v", errElevation, err)
+	}
+	devices, err := targets(ids)
+	if err != nil {
+		return nil, err
+	}
+	i, err := newInstaller(conf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
+	}
+	// Partitions mounted for auditing are dismounted again afterwards.
+	defer func() {
+		if err2 := i.Finalize(devices, true); err2 != nil {
+			if err == nil {
+				err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
+			} else {
+				err = fmt.Errorf("%w: %v\nFinalize() returned %v", errFinalize, err, err2)
+			}
+		}
+	}()
+	console.Printf("Retrieving %s %s installer...", c.distro, c.track)
+	if err := i.Retrieve(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errRetrieve, err)
+	}
+	report = &console.Report{Columns: reportColumns}
+	failed := 0
+	for _, d := range devices {
+		console.Printf("Verifying device %q...", d.FriendlyName())
+		deck.InfofA("Verifying device %q against %s %s.", d.Identifier(), c.distro, c.track).With(deck.V(1)).Go()
+		checks, err := i.Audit(d, keys)
+		if err != nil {
+			return report, fmt.Errorf("%w: Audit(%q) returned %v", errAudit, d.Identifier(), err)
+		}
+		ok := true
+		for _, check := range checks {
+			report.Rows = append(report.Rows, []string{d.Identifier(), check.Name, check.Result, check.Detail})
+			if check.Result == installer.AuditFail {
+				ok = false
+			}
+		}
+		if !ok {
+			failed++
+			deck.Warningf("Device %q failed verification against %s %s.", d.Identifier(), c.distro, c.track)
+		}
+	}
+	if failed > 0 {
+		return report, fmt.Errorf("%w: %d of %d devices do not hold the current %s %s installer", errFailed, failed, len(devices), c.distro, c.track)
+	}
+	return report, nil
+}
+
+// targets returns the devices identified by ids.
+func targets(ids []string) ([]installer.Device, error) {
+	available, err := search()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSearch, err)
+	}
+	found := make(map[string]installer.Device)
+	for _, d := range available {
+		found[d.Identifier()] = d
+	}
+	var devices []installer.Device
+	for _, id := range ids {
+		d, ok := found[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: device %q was not found, use the 'list' command to list available devices", errDevice, id)
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// readKeys reads certificates cached by the verify-seed command from path.
+func readKeys(path string) (*models.KeysResponse, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading keys: %v", errKeys, err)
+	}
+	keys := &models.KeysResponse{}
+	if err := json.Unmarshal(b, keys); err != nil {
+		return nil, fmt.Errorf("%w: %q is not a keys file: %v", errKeys, path, err)
+	}
+	return keys, nil
+}
+
+// storageSearch wraps storage.Search and returns every device, including
+// fixed devices, as they are only read from.
+func storageSearch() ([]installer.Device, error) {
+	devices, err := storage.Search("", 0, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Search() returned %v", err)
+	}
+	results := []installer.Device{}
+	for _, d := range devices {
+		results = append(results, d)
+	}
+	return results, nil
+}
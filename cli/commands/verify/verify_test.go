@@ -0,0 +1,323 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/models"
+	"github.com/google/subcommands"
+	"github.com/google/winops/storage"
+)
+
+type fakeDevice struct {
+	// storage.Device is embedded, fakeDevice inherits all its members.
+	storage.Device
+
+	id string
+}
+
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return "USB " + f.id
+}
+
+type fakeAuditor struct {
+	retErr   error
+	auditErr error
+	finErr   error
+	checks   map[string][]installer.AuditCheck
+
+	keys      *models.KeysResponse
+	finalized bool
+}
+
+func (f *fakeAuditor) Retrieve() error { return f.retErr }
+
+func (f *fakeAuditor) Audit(d installer.Device, keys *models.KeysResponse) ([]installer.AuditCheck, error) {
+	f.keys = keys
+	return f.checks[d.Identifier()], f.auditErr
+}
+
+func (f *fakeAuditor) Finalize([]installer.Device, bool) error {
+	f.finalized = true
+	return f.finErr
+}
+
+// passed returns checks that all passed.
+func passed() []installer.AuditCheck {
+	return []installer.AuditCheck{
+		{Name: installer.CheckLabel, Result: installer.AuditPass},
+		{Name: installer.CheckContents, Result: installer.AuditPass},
+		{Name: installer.CheckSeed, Result: installer.AuditPass},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	stdout := output
+	defer func() {
+		execute = run
+		output = stdout
+		console.Verbose = false
+	}()
+	report := &console.Report{Columns: reportColumns, Rows: [][]string{{"sdc", "label", "pass", "partition \"sdc1\" is labelled \"WINPE\""}}}
+	tests := []struct {
+		desc       string
+		args       []string
+		report     *console.Report
+		execErr    error
+		want       subcommands.ExitStatus
+		wantOutput bool
+	}{
+		{
+			desc: "no device",
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "unknown output format",
+			args: []string{"--output=xml", "sdc"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc: "unknown debug category",
+			args: []string{"--debug=disks", "sdc"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:    "run error",
+			args:    []string{"sdc"},
+			execErr: errors.New("error"),
+			want:    subcommands.ExitFailure,
+		},
+		{
+			desc:       "failed check",
+			args:       []string{"--output=json", "sdc"},
+			report:     report,
+			execErr:    errFailed,
+			want:       subcommands.ExitFailure,
+			wantOutput: true,
+		},
+		{
+			desc:       "success",
+			args:       []string{"--output=json", "sdc"},
+			report:     report,
+			want:       subcommands.ExitSuccess,
+			wantOutput: true,
+		},
+	}
+	for _, tt := range tests {
+		c := &verifyCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		report, execErr := tt.report, tt.execErr
+		execute = func(*verifyCmd, []string) (*console.Report, error) { return report, execErr }
+		var b bytes.Buffer
+		output = &b
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if !tt.wantOutput {
+			continue
+		}
+		var rows []map[string]string
+		if err := json.Unmarshal(b.Bytes(), &rows); err != nil {
+			t.Errorf("%s: Execute() wrote %q, which is not JSON: %v", tt.desc, b.String(), err)
+			continue
+		}
+		if len(rows) != 1 || rows[0]["result"] != "pass" {
+			t.Errorf("%s: Execute() wrote %v, want the report", tt.desc, rows)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	capabilityCmd := config.CapabilityCmd
+	defer func() {
+		config.CapabilityCmd = capabilityCmd
+		search = storageSearch
+		newInstaller = installerNew
+		loadDistributions = config.LoadDistributions
+	}()
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.json")
+	if err := ioutil.WriteFile(keysFile, []byte(`{"AppID":"fresnel"}`), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", keysFile, err)
+	}
+	badKeys := filepath.Join(dir, "bad.json")
+	if err := ioutil.WriteFile(badKeys, []byte("not json"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", badKeys, err)
+	}
+	failed := passed()
+	failed[2].Result = installer.AuditFail
+
+	tests := []struct {
+		desc          string
+		args          []string
+		loadErr       error
+		capabilityErr error
+		searchErr     error
+		newErr        error
+		auditor       *fakeAuditor
+		wantRows      int
+		wantKeys      bool
+		wantFinalize  bool
+		want          error
+	}{
+		{
+			desc: "unknown distro",
+			args: []string{"--distro=unknown", "sdc"},
+			want: errConfig,
+		},
+		{
+			desc:    "config file error",
+			args:    []string{"--distro=windows", "--config=distros.yaml", "sdc"},
+			loadErr: errors.New("error"),
+			want:    errConfig,
+		},
+		{
+			desc: "missing keys",
+			args: []string{"--distro=windows", "--keys=" + filepath.Join(dir, "missing.json"), "sdc"},
+			want: errKeys,
+		},
+		{
+			desc: "invalid keys",
+			args: []string{"--distro=windows", "--keys=" + badKeys, "sdc"},
+			want: errKeys,
+		},
+		{
+			desc:          "cannot mount",
+			args:          []string{"--distro=windows", "sdc"},
+			capabilityErr: errors.New("error"),
+			want:          errElevation,
+		},
+		{
+			desc:      "search error",
+			args:      []string{"--distro=windows", "sdc"},
+			searchErr: errors.New("error"),
+			want:      errSearch,
+		},
+		{
+			desc: "unknown device",
+			args: []string{"--distro=windows", "sdz"},
+			want: errDevice,
+		},
+		{
+			desc:   "installer error",
+			args:   []string{"--distro=windows", "sdc"},
+			newErr: errors.New("error"),
+			want:   errInstaller,
+		},
+		{
+			desc:         "retrieve error",
+			args:         []string{"--distro=windows", "sdc"},
+			auditor:      &fakeAuditor{retErr: errors.New("error")},
+			wantFinalize: true,
+			want:         errRetrieve,
+		},
+		{
+			desc:         "audit error",
+			args:         []string{"--distro=windows", "sdc"},
+			auditor:      &fakeAuditor{auditErr: errors.New("error")},
+			wantFinalize: true,
+			want:         errAudit,
+		},
+		{
+			desc:         "finalize error",
+			args:         []string{"--distro=windows", "sdc"},
+			auditor:      &fakeAuditor{finErr: errors.New("error"), checks: map[string][]installer.AuditCheck{"sdc": passed()}},
+			wantRows:     3,
+			wantFinalize: true,
+			want:         errFinalize,
+		},
+		{
+			desc:         "failed check",
+			args:         []string{"--distro=windows", "sdc", "sdd"},
+			auditor:      &fakeAuditor{checks: map[string][]installer.AuditCheck{"sdc": passed(), "sdd": failed}},
+			wantRows:     6,
+			wantFinalize: true,
+			want:         errFailed,
+		},
+		{
+			desc:         "success",
+			args:         []string{"--distro=windows", "sdc", "sdd"},
+			auditor:      &fakeAuditor{checks: map[string][]installer.AuditCheck{"sdc": passed(), "sdd": passed()}},
+			wantRows:     6,
+			wantFinalize: true,
+		},
+		{
+			desc:         "success with keys",
+			args:         []string{"--distro=windows", "--keys=" + keysFile, "sdc"},
+			auditor:      &fakeAuditor{checks: map[string][]installer.AuditCheck{"sdc": passed()}},
+			wantRows:     3,
+			wantKeys:     true,
+			wantFinalize: true,
+		},
+	}
+	for _, tt := range tests {
+		c := &verifyCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		loadErr := tt.loadErr
+		loadDistributions = func(string) error { return loadErr }
+		capabilityErr := tt.capabilityErr
+		config.CapabilityCmd = func(config.Capability) error { return capabilityErr }
+		searchErr := tt.searchErr
+		search = func() ([]installer.Device, error) {
+			return []installer.Device{&fakeDevice{id: "sdc"}, &fakeDevice{id: "sdd"}}, searchErr
+		}
+		a, newErr := tt.auditor, tt.newErr
+		newInstaller = func(installer.Configuration) (auditor, error) { return a, newErr }
+
+		report, got := run(c, f.Args())
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: run() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		rows := 0
+		if report != nil {
+			rows = len(report.Rows)
+		}
+		if rows != tt.wantRows {
+			t.Errorf("%s: run() reported %d rows, want: %d", tt.desc, rows, tt.wantRows)
+		}
+		if a == nil {
+			continue
+		}
+		if a.finalized != tt.wantFinalize {
+			t.Errorf("%s: run() finalized: %t, want: %t", tt.desc, a.finalized, tt.wantFinalize)
+		}
+		if (a.keys != nil) != tt.wantKeys {
+			t.Errorf("%s: run() audited with keys: %v, want keys: %t", tt.desc, a.keys, tt.wantKeys)
+		}
+	}
+}
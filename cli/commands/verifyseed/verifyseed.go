@@ -0,0 +1,233 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verifyseed implements the verify-seed subcommand, which checks the
+// signature of a seed file offline, using certificates saved from the /keys
+// endpoint of the seed server.
+package verifyseed
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/client"
+	"github.com/google/fresnel/models"
+	"github.com/google/subcommands"
+)
+
+var (
+	binaryName string
+
+	// Wrapped errors for testing.
+	errFetch  = errors.New("fetch error")
+	errFile   = errors.New("file error")
+	errInput  = errors.New("input error")
+	errVerify = errors.New("verification error")
+
+	// Dependency injections for testing.
+	connect = client.Connect
+	now     = time.Now
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&verifySeedCmd{}, "")
+}
+
+// verifySeedCmd is the verify-seed subcommand, which validates a seed file
+// against cached public certificates, such as when auditing media found in
+// the field.
+type verifySeedCmd struct {
+	// keys is the path of the file that the certificates are cached in.
+	keys string
+	// fetch is the URL of the /keys endpoint to refresh keys from. When empty,
+	// the cached certificates are used without contacting the server.
+	fetch string
+	// file is the path of the seed file that the seed was issued for, such as
+	// sources/boot.wim on the provisioned media.
+	file string
+	// hash is the hex encoded hash of the seed file, used instead of file.
+	hash string
+}
+
+// Ensure verifySeedCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*verifySeedCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *verifySeedCmd) Name() string {
+	return "verify-seed"
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *verifySeedCmd) Synopsis() string {
+	return "Verify the signature of a seed file offline"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *verifySeedCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...] [seed.json]
+
+Verify that a seed file was signed by the seed server, using certificates
+cached from its /keys endpoint. The signature covers the hash of the seed file
+that the seed was issued for, such as sources/boot.wim on the provisioned
+media, so it must be provided with --file or --hash. Certificates are cached
+with --fetch while online, and verification does not contact the server.
+
+Flags:
+  --keys [path]  - The file that certificates are cached in. Required.
+  --fetch [url]  - The /keys endpoint to refresh the cached certificates from.
+                   The seed file may be omitted to only refresh them.
+  --file [path]  - The seed file that the seed was issued for.
+  --hash [hex]   - The hash of the seed file, in place of --file.
+
+Example: 'cache certificates, then verify media mounted at /media/installer'
+  - '%s verify-seed --keys keys.json --fetch https://appengine.address.com/keys'
+  - '%s verify-seed --keys keys.json --file /media/installer/sources/boot.wim /media/installer/seed/seed.json'
+
+Defaults:
+`, c.Name(), binaryName, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *verifySeedCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.keys, "keys", "", "the file that certificates are cached in")
+	f.StringVar(&c.fetch, "fetch", "", "the /keys endpoint to refresh the cached certificates from")
+	f.StringVar(&c.file, "file", "", "the seed file that the seed was issued for")
+	f.StringVar(&c.hash, "hash", "", "the hex encoded hash of the seed file, in place of --file")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *verifySeedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.keys == "" || f.NArg() > 1 || (f.NArg() == 0 && c.fetch == "") {
+		console.Printf("A keys file and a single seed file must be specified.\nusage: %s %s\n", binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := c.run(f.Arg(0)); err != nil {
+		console.Printf("%s completed with errors: %v", binaryName, err)
+		deck.Errorf("%s completed with errors: %v", binaryName, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// run refreshes the cached certificates if requested, then verifies the seed
+// file at path, if any.
+func (c *verifySeedCmd) run(path string) error {
+	if c.fetch != "" {
+		if err := c.fetchKeys(); err != nil {
+			return err
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	if (c.file == "") == (c.hash == "") {
+		return fmt.Errorf("%w: exactly one of --file or --hash must be specified", errInput)
+	}
+	b, err := ioutil.ReadFile(c.keys)
+	if err != nil {
+		return fmt.Errorf("%w: reading keys: %v", errFile, err)
+	}
+	keys := models.KeysResponse{}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("%w: %q is not a keys file: %v", errFile, c.keys, err)
+	}
+	if b, err = ioutil.ReadFile(path); err != nil {
+		return fmt.Errorf("%w: reading seed: %v", errFile, err)
+	}
+	sf := models.SeedFile{}
+	if err := json.Unmarshal(b, &sf); err != nil {
+		return fmt.Errorf("%w: %q is not a seed file: %v", errFile, path, err)
+	}
+	if sf.Algorithm == "" {
+		sf.Algorithm = models.HashSHA256
+	}
+	hash, err := c.seedHash(sf.Algorithm)
+	if err != nil {
+		return err
+	}
+	key, err := client.VerifySeed(sf.Seed, sf.Signature, hash, keys.Certs)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errVerify, err)
+	}
+	console.Printf("Seed %q is valid, signed by %s with key %q.", path, keys.AppID, key)
+	console.Printf("Issued to %s on %s.", sf.Seed.Username, sf.Seed.Issued.Local().Format(time.RFC1123))
+	switch {
+	case sf.ExpiresAt.IsZero():
+		console.Printf("The expiry of the seed is unknown.")
+	case sf.ExpiresAt.Before(now()):
+		console.Printf("The seed expired on %s.", sf.ExpiresAt.Local().Format(time.RFC1123))
+	default:
+		console.Printf("The seed expires on %s.", sf.ExpiresAt.Local().Format(time.RFC1123))
+	}
+	return nil
+}
+
+// fetchKeys obtains the current certificates from the /keys endpoint and
+// caches them in the keys file.
+func (c *verifySeedCmd) fetchKeys() error {
+	cl, err := connect(c.fetch, "")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errFetch, err)
+	}
+	keys, err := cl.Keys()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errFetch, err)
+	}
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(keys) returned %v", err)
+	}
+	if err := ioutil.WriteFile(c.keys, b, 0644); err != nil {
+		return fmt.Errorf("%w: writing keys: %v", errFile, err)
+	}
+	console.Printf("Cached %d certificates for %s in %q.", len(keys.Certs), keys.AppID, c.keys)
+	return nil
+}
+
+// seedHash returns the hash of the seed file computed using alg, or the hash
+// provided with --hash.
+func (c *verifySeedCmd) seedHash(alg models.HashAlgorithm) ([]byte, error) {
+	if c.hash != "" {
+		h, err := hex.DecodeString(c.hash)
+		if err != nil {
+			return nil, fmt.Errorf("%w: --hash is not hex: %v", errInput, err)
+		}
+		return h, nil
+	}
+	h, err := client.NewHash(alg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInput, err)
+	}
+	f, err := os.Open(c.file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errFile, err)
+	}
+	defer f.Close()
+	if err := client.HashReader(h, f); err != nil {
+		return nil, fmt.Errorf("%w: hashing %q: %v", errFile, c.file, err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifyseed
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/client"
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+// fakeDoer responds to every request with body.
+type fakeDoer struct {
+	body []byte
+}
+
+func (d *fakeDoer) Do(*http.Request) (*http.Response, error) {
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(d.body))}, nil
+}
+
+// writeJSON writes the JSON encoding of v to a file named name in dir.
+func writeJSON(t *testing.T, dir, name string, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() err: %v", err)
+	}
+	return p
+}
+
+// testSeed returns a certificate and a seed file signed with its key for a
+// seed file with the contents of contents.
+func testSeed(t *testing.T, contents []byte) (appengine.Certificate, models.SeedFile) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() err: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fresnel-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() err: %v", err)
+	}
+	cert := appengine.Certificate{KeyName: "key1", Data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+	hash := sha256.Sum256(contents)
+	seed := models.Seed{Issued: time.Now().UTC(), Username: "user@example.com", Certs: []appengine.Certificate{cert}, Hash: hash[:]}
+	b, err := json.Marshal(seed)
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("rsa.SignPKCS1v15() err: %v", err)
+	}
+	seed.Hash = nil
+	return cert, models.SeedFile{Seed: seed, Signature: sig, ExpiresAt: seed.Issued.Add(24 * time.Hour), Algorithm: models.HashSHA256}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	contents := []byte("boot.wim contents")
+	bootWim := filepath.Join(dir, "boot.wim")
+	if err := ioutil.WriteFile(bootWim, contents, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() err: %v", err)
+	}
+	cert, sf := testSeed(t, contents)
+	otherCert, _ := testSeed(t, contents)
+	seedPath := writeJSON(t, dir, "seed.json", sf)
+	keys := writeJSON(t, dir, "keys.json", models.KeysResponse{AppID: "fresnel", Certs: []appengine.Certificate{cert}})
+	otherKeys := writeJSON(t, dir, "other.json", models.KeysResponse{AppID: "fresnel", Certs: []appengine.Certificate{otherCert}})
+	sum := sha256.Sum256(contents)
+	served, err := json.Marshal(models.KeysResponse{AppID: "fresnel", Certs: []appengine.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+
+	tests := []struct {
+		desc    string
+		cmd     verifySeedCmd
+		seed    string
+		connErr error
+		wantErr error
+	}{
+		{
+			desc: "valid with file",
+			cmd:  verifySeedCmd{keys: keys, file: bootWim},
+			seed: seedPath,
+		},
+		{
+			desc: "valid with hash",
+			cmd:  verifySeedCmd{keys: keys, hash: hex.EncodeToString(sum[:])},
+			seed: seedPath,
+		},
+		{
+			desc: "fetch only",
+			cmd:  verifySeedCmd{keys: filepath.Join(dir, "fetched.json"), fetch: "https://fresnel.example.com/keys"},
+		},
+		{
+			desc: "fetch and verify",
+			cmd:  verifySeedCmd{keys: filepath.Join(dir, "fetched.json"), fetch: "https://fresnel.example.com/keys", file: bootWim},
+			seed: seedPath,
+		},
+		{
+			desc:    "fetch error",
+			cmd:     verifySeedCmd{keys: filepath.Join(dir, "fetched.json"), fetch: "https://fresnel.example.com/keys"},
+			connErr: errors.New("test"),
+			wantErr: errFetch,
+		},
+		{
+			desc:    "no hash",
+			cmd:     verifySeedCmd{keys: keys},
+			seed:    seedPath,
+			wantErr: errInput,
+		},
+		{
+			desc:    "bad hash",
+			cmd:     verifySeedCmd{keys: keys, hash: "zz"},
+			seed:    seedPath,
+			wantErr: errInput,
+		},
+		{
+			desc:    "missing keys",
+			cmd:     verifySeedCmd{keys: filepath.Join(dir, "missing.json"), file: bootWim},
+			seed:    seedPath,
+			wantErr: errFile,
+		},
+		{
+			desc:    "seed is not json",
+			cmd:     verifySeedCmd{keys: keys, file: bootWim},
+			seed:    bootWim,
+			wantErr: errFile,
+		},
+		{
+			desc:    "wrong seed file",
+			cmd:     verifySeedCmd{keys: keys, hash: hex.EncodeToString([]byte("other"))},
+			seed:    seedPath,
+			wantErr: errVerify,
+		},
+		{
+			desc:    "unknown key",
+			cmd:     verifySeedCmd{keys: otherKeys, file: bootWim},
+			seed:    seedPath,
+			wantErr: errVerify,
+		},
+	}
+	defer func() { connect = client.Connect }()
+	for _, tt := range tests {
+		os.Remove(filepath.Join(dir, "fetched.json"))
+		connect = func(url, _ string) (*client.Client, error) {
+			if tt.connErr != nil {
+				return nil, tt.connErr
+			}
+			return client.New(url, &fakeDoer{body: served}), nil
+		}
+		if err := tt.cmd.run(tt.seed); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: run() err got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version implements the version subcommand, which reports the build
+// of the CLI for users and fleet dashboards.
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flag"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	buildinfo "github.com/google/fresnel/cli/version"
+	"github.com/google/subcommands"
+)
+
+var (
+	binaryName string
+
+	// Dependency injections for testing.
+	output = io.Writer(os.Stdout)
+)
+
+func init() {
+	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	subcommands.Register(&versionCmd{}, "")
+}
+
+// versionCmd is the version subcommand, which reports the version, source
+// revision and build date of the CLI and the revision of its built-in
+// distributions.
+type versionCmd struct {
+	// json writes the report as JSON, for consumption by other tools.
+	json bool
+}
+
+// Ensure versionCmd implements the subcommands.Command interface.
+var _ subcommands.Command = (*versionCmd)(nil)
+
+// Name returns the name of the subcommand.
+func (c *versionCmd) Name() string {
+	return "version"
+}
+
+// Synopsis returns a short string (less than one line) describing the subcommand.
+func (c *versionCmd) Synopsis() string {
+	return "Report the version and build information of the CLI"
+}
+
+// Usage returns a long string explaining the subcommand and giving usage information.
+func (c *versionCmd) Usage() string {
+	return fmt.Sprintf(`%s [flags...]
+
+Report the version of the CLI, the commit and date it was built from, the Go
+version and platform it was built for, and the revision of the distributions
+built into it. Configuration files do not change the revision reported.
+'%s --version' reports the same information as text.
+
+Flags:
+  --json - Write the report as JSON, such as for fleet dashboards.
+
+Example: 'report the version as JSON'
+  - '%s version --json'
+
+Defaults:
+`, c.Name(), binaryName, binaryName)
+}
+
+// SetFlags adds the flags for this command to the specified set.
+func (c *versionCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.json, "json", false, "write the report as JSON")
+}
+
+// Execute executes the command and returns an ExitStatus.
+func (c *versionCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintf(output, "Unexpected arguments %v.\nusage: %s %s\n", f.Args(), binaryName, c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := Write(output, c.json); err != nil {
+		deck.Errorf("Write() returned %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// Write writes the build information of the CLI to w, as JSON when asJSON is
+// set and otherwise as text.
+func Write(w io.Writer, asJSON bool) error {
+	info := buildinfo.Get(config.DefaultsRevision())
+	if !asJSON {
+		_, err := io.WriteString(w, info.String())
+		return err
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent() returned %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
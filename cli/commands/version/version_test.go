@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"flag"
+	"github.com/google/fresnel/cli/config"
+	buildinfo "github.com/google/fresnel/cli/version"
+	"github.com/google/subcommands"
+)
+
+func TestExecute(t *testing.T) {
+	stdout := output
+	defer func() { output = stdout }()
+	tests := []struct {
+		desc     string
+		args     []string
+		want     subcommands.ExitStatus
+		wantJSON bool
+		wantText string
+	}{
+		{
+			desc: "unexpected argument",
+			args: []string{"extra"},
+			want: subcommands.ExitUsageError,
+		},
+		{
+			desc:     "text",
+			want:     subcommands.ExitSuccess,
+			wantText: "Config revision: " + config.DefaultsRevision(),
+		},
+		{
+			desc:     "json",
+			args:     []string{"--json"},
+			want:     subcommands.ExitSuccess,
+			wantJSON: true,
+		},
+	}
+	for _, tt := range tests {
+		c := &versionCmd{}
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		c.SetFlags(f)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		var b bytes.Buffer
+		output = &b
+		if got := c.Execute(context.Background(), f); got != tt.want {
+			t.Errorf("%s: Execute() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+		if tt.wantText != "" && !strings.Contains(b.String(), tt.wantText) {
+			t.Errorf("%s: Execute() wrote %q, want it to contain %q", tt.desc, b.String(), tt.wantText)
+		}
+		if !tt.wantJSON {
+			continue
+		}
+		got := buildinfo.Info{}
+		if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+			t.Errorf("%s: Execute() wrote %q, which is not JSON: %v", tt.desc, b.String(), err)
+			continue
+		}
+		if got.Version != buildinfo.Version || got.ConfigRevision != config.DefaultsRevision() {
+			t.Errorf("%s: Execute() wrote %+v, want version %q and config revision %q", tt.desc, got, buildinfo.Version, config.DefaultsRevision())
+		}
+	}
+}
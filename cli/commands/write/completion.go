@@ -0,0 +1,151 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+)
+
+const (
+	// hookTimeout is how long the completion command may run before it is
+	// stopped.
+	hookTimeout = time.Minute
+
+	// bellChar sounds the terminal bell when written to the console.
+	bellChar = "\a"
+)
+
+// bellInterval separates repeated bells so that each is heard.
+var bellInterval = 300 * time.Millisecond
+
+// complete signals the result of provisioning devices to the user by each of
+// the means requested by flag.
+func (c *writeCmd) complete(devices []string, result error) {
+	c.notifyResult(devices, result)
+	c.soundBell(result)
+	c.runCompletion(devices, result)
+}
+
+// completed returns the identifiers of the devices that the last run found,
+// or those requested by f when it stopped before any were found.
+func (c *writeCmd) completed(f *flag.FlagSet) []string {
+	if len(c.targets) > 0 {
+		return c.targets
+	}
+	return f.Args()
+}
+
+// soundBell sounds the terminal bell once for success, and three times for
+// failure, when requested.
+func (c *writeCmd) soundBell(result error) {
+	if !c.beep {
+		return
+	}
+	count := 1
+	if result != nil {
+		count = 3
+	}
+	for n := 0; n < count; n++ {
+		fmt.Fprint(bell, bellChar)
+		if n < count-1 {
+			time.Sleep(bellInterval)
+		}
+	}
+}
+
+// runCompletion runs the completion command, when one was provided. The
+// result is passed to the command in the following environment variables:
+//
+//	FRESNEL_RESULT  - 'success' or 'failure'.
+//	FRESNEL_ERROR   - The error that occurred, empty on success.
+//	FRESNEL_DEVICES - The devices found for provisioning, separated by
+//	                  commas. These are the devices requested when the run
+//	                  stopped before any were found, and empty when all
+//	                  suitable devices were requested and none were found.
+//	FRESNEL_DISTRO  - The distribution that was provisioned.
+//	FRESNEL_TRACK   - The track that was provisioned.
+//	FRESNEL_BATCH   - The batch that was provisioned, empty when none was
+//	                  named.
+func (c *writeCmd) runCompletion(devices []string, result error) {
+	if c.onComplete == "" {
+		return
+	}
+	status, msg := "success", ""
+	if result != nil {
+		status, msg = "failure", result.Error()
+	}
+	env := []string{
+		"FRESNEL_RESULT=" + status,
+		"FRESNEL_ERROR=" + msg,
+		"FRESNEL_DEVICES=" + strings.Join(devices, ","),
+		"FRESNEL_DISTRO=" + c.distro,
+		"FRESNEL_TRACK=" + c.track,
+		"FRESNEL_BATCH=" + c.batch,
+	}
+	deck.InfofA("Running completion command %q.", c.onComplete).With(deck.V(1)).Go()
+	if err := runHook(c.onComplete, env); err != nil {
+		deck.Warningf("Completion command %q returned %v", c.onComplete, err)
+	}
+}
+
+// shellRun runs command using the shell of the platform, with env added to
+// the environment of the current process.
+func shellRun(command string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// notifyResult shows a desktop notification describing the result of
+// provisioning devices, when one was requested and can be shown.
+func (c *writeCmd) notifyResult(devices []string, result error) {
+	if !c.notify {
+		return
+	}
+	if !notifyEnabled() {
+		deck.InfofA("Skipping desktop notification, as this session is not interactive.").With(deck.V(1)).Go()
+		return
+	}
+	targets := "all suitable devices"
+	if len(devices) > 0 {
+		targets = strings.Join(devices, ", ")
+	}
+	title := fmt.Sprintf("%s completed successfully", binaryName)
+	message := fmt.Sprintf("Finished writing %s to %s.", c.distro, targets)
+	if result != nil {
+		title = fmt.Sprintf("%s completed with errors", binaryName)
+		message = fmt.Sprintf("Writing %s to %s failed: %v", c.distro, targets, result)
+	}
+	if err := notifySend(title, message); err != nil {
+		deck.Warningf("notify.Send(%q) returned %v", title, err)
+	}
+}
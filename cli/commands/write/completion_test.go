@@ -0,0 +1,228 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNotifyResult(t *testing.T) {
+	tests := []struct {
+		desc      string
+		notify    bool
+		enabled   bool
+		devices   []string
+		result    error
+		wantSent  bool
+		wantTitle string
+		wantMsg   string
+	}{
+		{
+			desc:    "not requested",
+			notify:  false,
+			enabled: true,
+		},
+		{
+			desc:    "non-interactive",
+			notify:  true,
+			enabled: false,
+		},
+		{
+			desc:      "success",
+			notify:    true,
+			enabled:   true,
+			devices:   []string{"sdy", "sdz"},
+			wantSent:  true,
+			wantTitle: "completed successfully",
+			wantMsg:   "sdy, sdz",
+		},
+		{
+			desc:      "failure",
+			notify:    true,
+			enabled:   true,
+			result:    errors.New("disk full"),
+			wantSent:  true,
+			wantTitle: "completed with errors",
+			wantMsg:   "all suitable devices failed: disk full",
+		},
+	}
+	for _, tt := range tests {
+		var sent bool
+		var title, message string
+		enabled := tt.enabled
+		notifyEnabled = func() bool { return enabled }
+		notifySend = func(t, m string) error {
+			sent, title, message = true, t, m
+			return nil
+		}
+		c := &writeCmd{distro: "windows", notify: tt.notify}
+		c.notifyResult(tt.devices, tt.result)
+		if sent != tt.wantSent {
+			t.Errorf("%s: notifyResult() sent: %t, want: %t", tt.desc, sent, tt.wantSent)
+		}
+		if !strings.Contains(title, tt.wantTitle) || !strings.Contains(message, tt.wantMsg) {
+			t.Errorf("%s: notifyResult() sent (%q, %q), want title containing %q and message containing %q", tt.desc, title, message, tt.wantTitle, tt.wantMsg)
+		}
+	}
+}
+
+func TestSoundBell(t *testing.T) {
+	tests := []struct {
+		desc   string
+		beep   bool
+		result error
+		want   string
+	}{
+		{
+			desc: "not requested",
+			beep: false,
+			want: "",
+		},
+		{
+			desc: "success",
+			beep: true,
+			want: "\a",
+		},
+		{
+			desc:   "failure",
+			beep:   true,
+			result: errors.New("error"),
+			want:   "\a\a\a",
+		},
+	}
+	bellInterval = 0
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		bell = &buf
+		c := &writeCmd{beep: tt.beep}
+		c.soundBell(tt.result)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: soundBell() wrote %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRunCompletion(t *testing.T) {
+	tests := []struct {
+		desc       string
+		onComplete string
+		batch      string
+		devices    []string
+		result     error
+		wantRun    bool
+		wantEnv    []string
+	}{
+		{
+			desc: "not requested",
+		},
+		{
+			desc:       "success",
+			onComplete: "light green",
+			devices:    []string{"1", "2"},
+			wantRun:    true,
+			wantEnv:    []string{"FRESNEL_RESULT=success", "FRESNEL_ERROR=", "FRESNEL_DEVICES=1,2", "FRESNEL_DISTRO=windows", "FRESNEL_TRACK=stable", "FRESNEL_BATCH="},
+		},
+		{
+			desc:       "failure",
+			onComplete: "light red",
+			result:     errors.New("disk full"),
+			wantRun:    true,
+			wantEnv:    []string{"FRESNEL_RESULT=failure", "FRESNEL_ERROR=disk full", "FRESNEL_DEVICES=", "FRESNEL_DISTRO=windows", "FRESNEL_TRACK=stable", "FRESNEL_BATCH="},
+		},
+		{
+			desc:       "batch",
+			onComplete: "light green",
+			batch:      "NYC-onboarding-June",
+			wantRun:    true,
+			wantEnv:    []string{"FRESNEL_RESULT=success", "FRESNEL_ERROR=", "FRESNEL_DEVICES=", "FRESNEL_DISTRO=windows", "FRESNEL_TRACK=stable", "FRESNEL_BATCH=NYC-onboarding-June"},
+		},
+		{
+			desc:       "command error is not fatal",
+			onComplete: "missing",
+			wantRun:    true,
+			wantEnv:    []string{"FRESNEL_RESULT=success", "FRESNEL_ERROR=", "FRESNEL_DEVICES=", "FRESNEL_DISTRO=windows", "FRESNEL_TRACK=stable", "FRESNEL_BATCH="},
+		},
+	}
+	for _, tt := range tests {
+		var ran string
+		var gotEnv []string
+		runHook = func(command string, env []string) error {
+			ran, gotEnv = command, env
+			return errors.New("error")
+		}
+		c := &writeCmd{distro: "windows", track: "stable", batch: tt.batch, onComplete: tt.onComplete}
+		c.runCompletion(tt.devices, tt.result)
+		if (ran != "") != tt.wantRun || ran != tt.onComplete {
+			t.Errorf("%s: runCompletion() ran %q, want: %q", tt.desc, ran, tt.onComplete)
+		}
+		if tt.wantRun && !reflect.DeepEqual(gotEnv, tt.wantEnv) {
+			t.Errorf("%s: runCompletion() env got: %v, want: %v", tt.desc, gotEnv, tt.wantEnv)
+		}
+	}
+}
+
+func TestCompleted(t *testing.T) {
+	tests := []struct {
+		desc    string
+		args    []string
+		targets []string
+		want    []string
+	}{
+		{
+			desc: "stopped before search",
+			args: []string{"sdb"},
+			want: []string{"sdb"},
+		},
+		{
+			desc:    "all devices",
+			targets: []string{"sdb", "sdc"},
+			want:    []string{"sdb", "sdc"},
+		},
+		{
+			desc:    "named devices",
+			args:    []string{"sdb", "sdc"},
+			targets: []string{"sdb", "sdc"},
+			want:    []string{"sdb", "sdc"},
+		},
+	}
+	for _, tt := range tests {
+		f := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatalf("%s: Parse(%v) returned %v", tt.desc, tt.args, err)
+		}
+		c := &writeCmd{targets: tt.targets}
+		if got := c.completed(f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: completed() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestShellRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell syntax differs on Windows")
+	}
+	if err := shellRun(`test "$FRESNEL_RESULT" = success`, []string{"FRESNEL_RESULT=success"}); err != nil {
+		t.Errorf("shellRun() returned %v", err)
+	}
+	if err := shellRun(`test "$FRESNEL_RESULT" = success`, []string{"FRESNEL_RESULT=failure"}); err == nil {
+		t.Errorf("shellRun() returned nil for a failing command")
+	}
+}
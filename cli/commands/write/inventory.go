@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+)
+
+// Results recorded for each device in an inventory.
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultSkipped = "skipped"
+)
+
+// inventoryColumns are the columns of an inventory report.
+var inventoryColumns = []console.Column{
+	{Title: "Device", Key: "device"},
+	{Title: "Serial", Key: "serial"},
+	{Title: "Model", Key: "model"},
+	{Title: "Size", Key: "size_bytes"},
+	{Title: "Image Hash", Key: "image_sha256"},
+	{Title: "Seed Expiry", Key: "seed_expiry"},
+	{Title: "Result", Key: "result"},
+	{Title: "Error", Key: "error"},
+	{Title: "Duration", Key: "duration"},
+	{Title: "Files", Key: "files"},
+	{Title: "Contents Digest", Key: "contents_sha256"},
+}
+
+// InventoryRecord describes the outcome of provisioning a single device.
+type InventoryRecord struct {
+	Device     string
+	Serial     string // Empty when the device reports no serial number to the OS.
+	Model      string
+	Size       uint64
+	ImageHash  string    // The SHA-256 hash of the image, empty when not retrieved.
+	SeedExpiry time.Time // Zero when no seed was written or its expiry is unknown.
+	Result     string    // One of 'success', 'failure' or 'skipped'.
+	Error      string
+	Duration   time.Duration
+	Files      int    // The number of files written from an ISO image, zero for other images.
+	Contents   string // The digest of the content manifest, empty when none was recorded.
+}
+
+// newRecord returns a record describing the result of provisioning device.
+// The image hash and seed expiry are obtained from i when it is available.
+func newRecord(i ImageInstaller, device installer.Device, result string, err error, d time.Duration) InventoryRecord {
+	r := InventoryRecord{
+		Device:   device.Identifier(),
+		Serial:   serialNumber(device.Identifier()),
+		Model:    device.FriendlyName(),
+		Size:     device.Size(),
+		Result:   result,
+		Duration: d,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if i != nil {
+		r.ImageHash = i.ImageHash()
+		if result == resultSuccess {
+			r.SeedExpiry = i.SeedExpiry()
+			if m := i.Contents(); m != nil {
+				r.Files = len(m.Files)
+				r.Contents = m.Digest
+			}
+		}
+	}
+	return r
+}
+
+// inventoryReport returns records as a report, one row per device.
+func inventoryReport(records []InventoryRecord) *console.Report {
+	r := &console.Report{Columns: inventoryColumns}
+	for _, rec := range records {
+		expiry := ""
+		if !rec.SeedExpiry.IsZero() {
+			expiry = rec.SeedExpiry.UTC().Format(time.RFC3339)
+		}
+		r.Rows = append(r.Rows, []string{
+			rec.Device,
+			rec.Serial,
+			rec.Model,
+			strconv.FormatUint(rec.Size, 10),
+			rec.ImageHash,
+			expiry,
+			rec.Result,
+			rec.Error,
+			rec.Duration.Round(time.Second).String(),
+			strconv.Itoa(rec.Files),
+			rec.Contents,
+		})
+	}
+	return r
+}
+
+// emitResult reports rec as a result event, with the same fields as the
+// inventory.
+func emitResult(rec InventoryRecord) {
+	if !console.EventsEnabled() {
+		return
+	}
+	r := inventoryReport([]InventoryRecord{rec})
+	details := make(map[string]string)
+	for n, c := range r.Columns {
+		if v := r.Rows[0][n]; v != "" {
+			details[c.Key] = v
+		}
+	}
+	console.Emit(console.Event{Type: console.EventResult, Status: rec.Result, Device: rec.Device, Details: details, Error: rec.Error})
+}
+
+// writeInventory writes records to path in format.
+func writeInventory(path string, format console.Format, records []InventoryRecord) (err error) {
+	fw, err := console.NewFormatWriter(format)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInventory, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("%w: os.Create(%q) returned %v", errInventory, path, err)
+	}
+	defer func() {
+		if err2 := f.Close(); err2 != nil && err == nil {
+			err = fmt.Errorf("%w: Close() for %q returned %v", errInventory, path, err2)
+		}
+	}()
+	if err := fw.Write(f, inventoryReport(records)); err != nil {
+		return fmt.Errorf("%w: writing %q returned %v", errInventory, path, err)
+	}
+	deck.InfofA("Wrote inventory of %d devices to %q.", len(records), path).With(deck.V(1)).Go()
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/schema"
+	"github.com/google/fresnel/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewRecord(t *testing.T) {
+	expiry := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	contents := &models.ContentManifest{Digest: "ef01", Files: []models.ContentFile{{Path: "bootmgr"}, {Path: "sources/boot.wim"}}}
+	inst := &fakeInstaller{imageHash: "abcd", expiry: expiry, contents: contents}
+	tests := []struct {
+		desc   string
+		inst   ImageInstaller
+		device installer.Device
+		result string
+		err    error
+		want   InventoryRecord
+	}{
+		{
+			desc:   "skipped without installer",
+			device: &fakeDevice{id: "1"},
+			result: resultSkipped,
+			want:   InventoryRecord{Device: "1", Model: "Fake Device", Result: resultSkipped},
+		},
+		{
+			desc:   "failure omits seed expiry",
+			inst:   inst,
+			device: &fakeDevice{id: "1"},
+			result: resultFailure,
+			err:    errors.New("disk full"),
+			want:   InventoryRecord{Device: "1", Model: "Fake Device", ImageHash: "abcd", Result: resultFailure, Error: "disk full", Duration: time.Minute},
+		},
+		{
+			desc:   "success with serial",
+			inst:   inst,
+			device: &fakeDevice{id: "2"},
+			result: resultSuccess,
+			want:   InventoryRecord{Device: "2", Serial: "SN123", Model: "Fake Device", ImageHash: "abcd", SeedExpiry: expiry, Result: resultSuccess, Duration: time.Minute, Files: 2, Contents: "ef01"},
+		},
+	}
+	defer func() { serialNumber = installer.SerialNumber }()
+	serialNumber = func(id string) string {
+		if id == "2" {
+			return "SN123"
+		}
+		return ""
+	}
+	for _, tt := range tests {
+		d := time.Duration(0)
+		if tt.inst != nil {
+			d = time.Minute
+		}
+		got := newRecord(tt.inst, tt.device, tt.result, tt.err, d)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: newRecord() mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestWriteInventory(t *testing.T) {
+	records := []InventoryRecord{
+		{
+			Device:     "1",
+			Serial:     "SN123",
+			Model:      "Flash Drive",
+			Size:       16000000000,
+			ImageHash:  "abcd",
+			SeedExpiry: time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC),
+			Result:     resultSuccess,
+			Duration:   4*time.Minute + 2*time.Second + 300*time.Millisecond,
+			Files:      2,
+			Contents:   "ef01",
+		},
+		{
+			Device: "2",
+			Model:  "Flash Drive",
+			Result: resultSkipped,
+		},
+	}
+	tests := []struct {
+		desc   string
+		path   string
+		format console.Format
+		want   string
+		err    error
+	}{
+		{
+			desc:   "csv",
+			format: console.FormatCSV,
+			want: "device,serial,model,size_bytes,image_sha256,seed_expiry,result,error,duration,files,contents_sha256\n" +
+				"1,SN123,Flash Drive,16000000000,abcd,2026-06-01T12:00:00Z,success,,4m2s,2,ef01\n" +
+				"2,,Flash Drive,0,,,skipped,,0s,0,\n",
+		},
+		{
+			desc:   "json",
+			format: console.FormatJSON,
+			want: `[{"device":"1","serial":"SN123","model":"Flash Drive","size_bytes":"16000000000","image_sha256":"abcd","seed_expiry":"2026-06-01T12:00:00Z","result":"success","error":"","duration":"4m2s","files":"2","contents_sha256":"ef01"},` +
+				`{"device":"2","serial":"","model":"Flash Drive","size_bytes":"0","image_sha256":"","seed_expiry":"","result":"skipped","error":"","duration":"0s","files":"0","contents_sha256":""}]`,
+		},
+		{
+			desc:   "unknown format",
+			format: console.Format("xml"),
+			err:    errInventory,
+		},
+		{
+			desc:   "missing directory",
+			path:   filepath.Join("does", "not", "exist.csv"),
+			format: console.FormatCSV,
+			err:    errInventory,
+		},
+	}
+	for _, tt := range tests {
+		path := tt.path
+		if path == "" {
+			path = filepath.Join(t.TempDir(), "inventory")
+		}
+		err := writeInventory(path, tt.format, records)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: writeInventory() got: %v, want: %v", tt.desc, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(%q) returned %v", tt.desc, path, err)
+		}
+		if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+			t.Errorf("%s: writeInventory() mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+// TestInventorySchema checks that the inventory schema describes every column
+// of the inventory.
+func TestInventorySchema(t *testing.T) {
+	var want []string
+	for _, c := range inventoryColumns {
+		want = append(want, c.Key)
+	}
+	sort.Strings(want)
+	got, err := schema.Properties(schema.Inventory)
+	if err != nil {
+		t.Fatalf("schema.Properties(%q) returned %v", schema.Inventory, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory schema properties got: %v, want: %v", got, want)
+	}
+}
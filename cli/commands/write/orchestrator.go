@@ -0,0 +1,508 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/boottest"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/resources"
+	"github.com/google/fresnel/cli/trace"
+	"github.com/google/fresnel/models"
+)
+
+// Configuration represents config.Configuration.
+type Configuration interface {
+	installer.Configuration
+	Devices() []string
+	Distro() string
+	Track() string
+	UpdateDevices([]string)
+	Warning() bool
+}
+
+// ImageInstaller represents installer.Installer.
+type ImageInstaller interface {
+	Cache() string
+	Finalize([]installer.Device, bool) error
+	Retrieve() error
+	Prepare(installer.Device) error
+	Provision(installer.Device) error
+	Verify(installer.Device) error
+	StoreLog(d installer.Device, log, report []byte) error
+	ImageHash() string
+	SeedExpiry() time.Time
+	Contents() *models.ContentManifest
+}
+
+// UI presents progress to the user and obtains their confirmation.
+type UI interface {
+	Printf(format string, v ...interface{})
+	PrintDevices([]installer.Device) error
+	Prompt() error
+}
+
+// consoleUI implements UI using the console package.
+type consoleUI struct{}
+
+func (consoleUI) Printf(format string, v ...interface{}) {
+	console.Printf(format, v...)
+}
+
+func (consoleUI) PrintDevices(targets []installer.Device) error {
+	// The table is replaced by an event when events are enabled.
+	if console.EventsEnabled() {
+		ids := []string{}
+		for _, device := range targets {
+			ids = append(ids, device.Identifier())
+		}
+		console.Emit(console.Event{Type: console.EventTargets, Devices: ids})
+		return nil
+	}
+	// Wrap targets in the interface required for the console.
+	devices := []console.TargetDevice{}
+	for _, device := range targets {
+		devices = append(devices, device)
+	}
+	return console.PrintDevices(devices, os.Stdout, console.FormatTable)
+}
+
+func (consoleUI) Prompt() error {
+	return console.PromptUser()
+}
+
+// Orchestrator provisions one or more devices with an installer. Each step is
+// available separately so that callers other than the write subcommand can
+// reuse them, and its dependencies are provided by the caller so that each
+// step can be tested in isolation.
+type Orchestrator struct {
+	// Search returns the devices that are available for provisioning.
+	Search func(deviceID string, minSize, maxSize uint64, removableOnly bool) ([]installer.Device, error)
+	// NewInstaller returns an installer for a configuration.
+	NewInstaller func(installer.Configuration) (ImageInstaller, error)
+	// WaitReady blocks until a device is ready to be provisioned.
+	WaitReady func(installer.Detector, time.Duration) error
+	// CheckWritable, when set, returns an error explaining how to clear the
+	// write protection of a device. Protected devices are not available.
+	CheckWritable func(deviceID string) error
+	// BootTest, when set, boots each device after it is provisioned to check
+	// that it is bootable. Devices are not tested when it returns
+	// boottest.ErrUnavailable.
+	BootTest func(installer.Device) error
+	// Identify, when set, blinks the activity LED of a device until ctx is
+	// done. It is used to identify devices while the user is prompted.
+	Identify func(ctx context.Context, d installer.Device) error
+	// MediaLog, when set, returns the log of the run so far, which is stored
+	// with the report of each device on it after it is provisioned.
+	MediaLog func() []byte
+	// UI displays progress and prompts the user.
+	UI UI
+
+	MinSize       uint64        // The minimum size of devices to search for.
+	MaxSize       uint64        // The maximum size of devices to search for, 0 for no limit.
+	RemovableOnly bool          // Whether to exclude fixed devices from the search.
+	ReadyTimeout  time.Duration // How long to wait for each device to be ready.
+	SearchTimeout time.Duration // How long to wait for the search, 0 for no limit.
+	Dismount      bool          // Whether to dismount devices once they are finalized.
+	Update        bool          // Whether devices are being updated rather than provisioned.
+	Verify        bool          // Whether to read back and verify each device after it is provisioned.
+
+	// Inventory is the outcome for each of the targets of the last call to
+	// Provision, in the order they were provisioned.
+	Inventory []InventoryRecord
+	// Warnings are the steps of the last call to Provision that failed after
+	// every target was provisioned, which do not fail provisioning.
+	Warnings []Warning
+}
+
+// Severity classifies how the failure of a step affects the outcome of a run.
+type Severity string
+
+const (
+	// SeverityFatal failures leave devices unusable, and fail the run.
+	SeverityFatal Severity = "fatal"
+	// SeverityWarning failures are of post-provisioning convenience steps,
+	// such as ejecting devices or removing the cache, that leave devices
+	// usable.
+	SeverityWarning Severity = "warning"
+)
+
+// stepSeverity is the severity of the failure of each step of provisioning,
+// when every target was provisioned. Steps that are not listed are fatal.
+var stepSeverity = map[string]Severity{
+	"finalize": SeverityWarning,
+}
+
+// severity returns the severity of the failure of step.
+func severity(step string) Severity {
+	if s, ok := stepSeverity[step]; ok {
+		return s
+	}
+	return SeverityFatal
+}
+
+// Warning describes a step that failed with SeverityWarning, and how the user
+// can complete it themselves.
+type Warning struct {
+	Step     string
+	Err      error
+	Guidance string
+}
+
+// String describes the warning and its guidance.
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %v\n%s", w.Step, w.Err, w.Guidance)
+}
+
+// Run searches for the devices requested by conf, confirms them with the
+// user and provisions them. When all is set, every available device is
+// provisioned.
+func (o *Orchestrator) Run(conf Configuration, all bool) error {
+	targets, err := o.Targets(conf, all)
+	resources.Sample("search")
+	if err != nil {
+		return err
+	}
+	if err := o.Confirm(conf, targets); err != nil {
+		return err
+	}
+	return o.Provision(conf, targets)
+}
+
+// Targets returns the available devices that match those requested by conf.
+// When all is set, conf is first updated to request every available device.
+// The device policy of the distribution further narrows the search,
+// regardless of the limits set on the Orchestrator.
+func (o *Orchestrator) Targets(conf Configuration, all bool) ([]installer.Device, error) {
+	policy := conf.DevicePolicy()
+	minSize, maxSize := o.MinSize, o.MaxSize
+	if uint64(policy.MinSize) > minSize {
+		minSize = uint64(policy.MinSize)
+	}
+	if policy.MaxSize > 0 && (maxSize == 0 || uint64(policy.MaxSize) < maxSize) {
+		maxSize = uint64(policy.MaxSize)
+	}
+	removableOnly := o.RemovableOnly || policy.RemovableOnly
+
+	// Pull a list of suitable devices.
+	o.UI.Printf("Searching for available devices... ")
+	deck.InfofA("Searching for available devices with device policy: %v", policy).With(deck.V(1)).Go()
+	available, err := installer.Search(context.Background(), o.SearchTimeout, func() ([]installer.Device, error) {
+		return o.Search("", minSize, maxSize, removableOnly)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSearch, err)
+	}
+	available, protected := o.writable(available)
+
+	// If the --all flag was specified, update the target list.
+	if all {
+		// Build a list of the device names.
+		ids := []string{}
+		for _, d := range available {
+			ids = append(ids, d.Identifier())
+		}
+		conf.UpdateDevices(ids)
+	}
+
+	// Build a simple map of available devices for lookups.
+	verified := make(map[string]installer.Device)
+	for _, d := range available {
+		verified[d.Identifier()] = d
+	}
+	// Check if the requested devices are available and build a list of targets.
+	targets := []installer.Device{}
+	for _, t := range conf.Devices() {
+		if err, ok := protected[t]; ok {
+			return nil, fmt.Errorf("%w: requested device %q cannot be written to: %v", errDevice, t, err)
+		}
+		d, ok := verified[t]
+		if !ok {
+			return nil, fmt.Errorf("%w: requested device %q is not suitable for provisioning %s (%v), available devices %v", errDevice, t, conf.Distro(), policy, verified)
+		}
+		targets = append(targets, d)
+	}
+	return targets, nil
+}
+
+// writable returns the devices that are not write protected, and why each of
+// those that are cannot be written to, by identifier.
+func (o *Orchestrator) writable(devices []installer.Device) ([]installer.Device, map[string]error) {
+	if o.CheckWritable == nil {
+		return devices, nil
+	}
+	protected := make(map[string]error)
+	var out []installer.Device
+	for _, d := range devices {
+		if err := o.CheckWritable(d.Identifier()); err != nil {
+			deck.Warningf("Device %q is not available: %v", d.Identifier(), err)
+			protected[d.Identifier()] = err
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, protected
+}
+
+// Confirm displays the targets to the user and, when conf calls for a
+// warning, prompts them to continue.
+func (o *Orchestrator) Confirm(conf Configuration, targets []installer.Device) error {
+	deck.InfofA("Configuration to be applied:\n%s", conf).With(deck.V(3)).Go()
+	// Adjust wording based on whether or not we're doing an update.
+	writeType := "provisioned"
+	if o.Update {
+		writeType = "updated"
+	}
+	o.UI.Printf("The following devices will be %s with the latest %s [%s] installer:\n", writeType, conf.Distro(), conf.Track())
+	deck.InfofA("Devices %v will be %s with the latest %s [%s] installer.\n", conf.Devices(), writeType, conf.Distro(), conf.Track()).With(deck.V(2)).Go()
+
+	// Display information about the device(s) and warn the user.
+	if err := o.UI.PrintDevices(targets); err != nil {
+		return fmt.Errorf("PrintDevices() returned %v", err)
+	}
+	if conf.Warning() {
+		stop := o.identify(targets)
+		err := o.UI.Prompt()
+		stop()
+		if err != nil {
+			return fmt.Errorf("Prompt() returned %v", err)
+		}
+	}
+	return nil
+}
+
+// identify blinks each of the targets until the function that it returns is
+// called, which waits for them to stop being read from. Devices that cannot
+// be blinked are logged and otherwise ignored.
+func (o *Orchestrator) identify(targets []installer.Device) func() {
+	if o.Identify == nil || len(targets) == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, d := range targets {
+		wg.Add(1)
+		go func(d installer.Device) {
+			defer wg.Done()
+			if err := o.Identify(ctx, d); err != nil {
+				deck.Warningf("Unable to blink %q: %v", d.FriendlyName(), err)
+			}
+		}(d)
+	}
+	o.UI.Printf("The activity LEDs of these devices are blinking to help identify them.")
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Provision retrieves the image for conf once and provisions each of the
+// targets with it. The targets are finalized even when provisioning fails.
+// The outcome for each target is recorded in the Inventory.
+func (o *Orchestrator) Provision(conf Configuration, targets []installer.Device) (err error) {
+	o.Inventory = nil
+	o.Warnings = nil
+	span := trace.Begin("provision", trace.Attr("distribution", conf.Distro()), trace.Attr("track", conf.Track()))
+	defer func() { span.End(err) }()
+	// Initialize the installer.
+	i, err := o.NewInstaller(conf)
+	if err != nil {
+		o.skip(nil, targets)
+		return fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
+	}
+
+	// Defer dismounts, power-off, and cleanup. Finalize only performs these
+	// actions if configuration states to do so. Cleanup is performed only after
+	// the last device has been finalized. Devices that were provisioned remain
+	// usable when these fail, so they are warnings unless provisioning failed.
+	defer func(devices []installer.Device) {
+		defer resources.Sample("finalize")
+		console.Emit(console.Event{Type: "finalize", Status: console.StatusStarted})
+		err2 := i.Finalize(devices, o.Dismount)
+		switch {
+		case err2 == nil:
+			console.EmitStep("finalize", "", nil)
+		case err == nil && severity("finalize") == SeverityWarning:
+			o.warn(Warning{
+				Step:     "finalize",
+				Err:      fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2),
+				Guidance: finalizeGuidance(devices, i.Cache()),
+			})
+		case err == nil:
+			console.EmitStep("finalize", "", err2)
+			err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
+		default:
+			console.EmitStep("finalize", "", err2)
+			// The earlier error remains wrapped, as it is the cause of the
+			// failure that callers act on.
+			err = fmt.Errorf("%w\n%v: Finalize() returned %v", err, errFinalize, err2)
+		}
+	}(targets)
+
+	// Retrieve the image. This step occurs only once for n>0 devices. The
+	// tokens of signed image URLs are not displayed.
+	image := imagefile.Redact(conf.ImagePath())
+	o.UI.Printf("\nRetrieving image...\n    %s ->\n    %s", image, i.Cache())
+	deck.InfofA("Retrieving image...\n    %s ->\n    %s\n\n", image, i.Cache()).With(deck.V(1)).Go()
+	retrieve := trace.Begin("retrieve", trace.Attr("image", image))
+	console.Emit(console.Event{Type: "retrieve", Status: console.StatusStarted})
+	err = i.Retrieve()
+	retrieve.End(err)
+	console.EmitStep("retrieve", "", err)
+	resources.Sample("retrieve")
+	if err != nil {
+		o.skip(i, targets)
+		return fmt.Errorf("%w: Retrieve() returned %v", errRetrieve, err)
+	}
+	// Prepare and provision devices. This step occurs once per device.
+	for n, device := range targets {
+		start := time.Now()
+		err := o.ProvisionDevice(i, device)
+		if err == nil && o.MediaLog != nil {
+			err = o.storeLog(i, device, time.Since(start))
+		}
+		resources.Sample(fmt.Sprintf("device %q", device.Identifier()))
+		if err != nil {
+			o.record(newRecord(i, device, resultFailure, err, time.Since(start)))
+			o.skip(i, targets[n+1:])
+			return err
+		}
+		o.record(newRecord(i, device, resultSuccess, nil, time.Since(start)))
+	}
+	return nil
+}
+
+// skip records targets as skipped in the Inventory.
+func (o *Orchestrator) skip(i ImageInstaller, targets []installer.Device) {
+	for _, device := range targets {
+		o.record(newRecord(i, device, resultSkipped, nil, 0))
+	}
+}
+
+// record adds rec to the Inventory and reports it as a result event.
+func (o *Orchestrator) record(rec InventoryRecord) {
+	o.Inventory = append(o.Inventory, rec)
+	emitResult(rec)
+}
+
+// warn records w in the Warnings, and reports it to the user and as an event.
+func (o *Orchestrator) warn(w Warning) {
+	o.Warnings = append(o.Warnings, w)
+	o.UI.Printf("\nWarning: %v", w)
+	deck.Warningf("%s failed after provisioning succeeded: %v", w.Step, w.Err)
+	console.Emit(console.Event{Type: w.Step, Status: console.StatusWarning, Error: w.Err.Error(), Details: map[string]string{"guidance": w.Guidance}})
+}
+
+// finalizeGuidance explains how to finish finalizing devices by hand, when
+// dismounting, ejecting or removing the cache at cache failed.
+func finalizeGuidance(devices []installer.Device, cache string) string {
+	ids := make([]string, 0, len(devices))
+	for _, d := range devices {
+		ids = append(ids, d.Identifier())
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "The devices were provisioned successfully and can be used.")
+	if len(ids) > 0 {
+		fmt.Fprintf(&b, " Close any programs using them, then run '%s eject %s' before removing them.", binaryName, strings.Join(ids, " "))
+	}
+	if cache != "" {
+		fmt.Fprintf(&b, " If %q remains, it can be deleted.", cache)
+	}
+	return b.String()
+}
+
+// ProvisionDevice waits for device to be ready, then prepares and provisions
+// it using an installer whose image has already been retrieved.
+func (o *Orchestrator) ProvisionDevice(i ImageInstaller, device installer.Device) (err error) {
+	span := trace.Begin("device", trace.Attr("device.id", device.Identifier()), trace.Attr("device.name", device.FriendlyName()))
+	defer func() { span.End(err) }()
+	deck.InfofA("Waiting up to %v for device %q to be ready...", o.ReadyTimeout, device.FriendlyName()).With(deck.V(1)).Go()
+	if err := o.WaitReady(device, o.ReadyTimeout); err != nil {
+		return fmt.Errorf("%w: %v", errPrepare, err)
+	}
+	o.UI.Printf("\nPreparing device %q...", device.FriendlyName())
+	deck.InfofA("Preparing device %q...", device.FriendlyName()).With(deck.V(1)).Go()
+	// Prepare the device.
+	if err := step("prepare", device.Identifier(), func() error { return i.Prepare(device) }); err != nil {
+		return fmt.Errorf("%w: Prepare(%q) returned %v: ", errPrepare, device.FriendlyName(), err)
+	}
+	o.UI.Printf("Provisioning device %q...", device.FriendlyName())
+	deck.InfofA("Provisioning device %q...", device.FriendlyName()).With(deck.V(1)).Go()
+	// Provision the device.
+	if err := step("provision", device.Identifier(), func() error { return i.Provision(device) }); err != nil {
+		return fmt.Errorf("%w: Provision(%q) returned %v", errProvision, device.FriendlyName(), err)
+	}
+	if o.Verify {
+		o.UI.Printf("Verifying device %q...", device.FriendlyName())
+		deck.InfofA("Verifying device %q...", device.FriendlyName()).With(deck.V(1)).Go()
+		if err := step("verify", device.Identifier(), func() error { return i.Verify(device) }); err != nil {
+			return fmt.Errorf("%w: Verify(%q) returned %v", errVerify, device.FriendlyName(), err)
+		}
+	}
+	if o.BootTest == nil {
+		return nil
+	}
+	o.UI.Printf("Boot testing device %q...", device.FriendlyName())
+	deck.InfofA("Boot testing device %q...", device.FriendlyName()).With(deck.V(1)).Go()
+	if err := step("boot_test", device.Identifier(), func() error { return o.BootTest(device) }); err != nil {
+		if errors.Is(err, boottest.ErrUnavailable) {
+			o.UI.Printf("Skipping boot test of %q: %v", device.FriendlyName(), err)
+			deck.Warningf("Skipping boot test of %q: %v", device.FriendlyName(), err)
+			return nil
+		}
+		return fmt.Errorf("%w: boot test of %q returned %v", errBootTest, device.FriendlyName(), err)
+	}
+	return nil
+}
+
+// storeLog stores the log of the run and the report of device, which was
+// provisioned successfully in d, on device.
+func (o *Orchestrator) storeLog(i ImageInstaller, device installer.Device, d time.Duration) error {
+	fw, err := console.NewFormatWriter(console.FormatJSON)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errProvision, err)
+	}
+	var report bytes.Buffer
+	if err := fw.Write(&report, inventoryReport([]InventoryRecord{newRecord(i, device, resultSuccess, nil, d)})); err != nil {
+		return fmt.Errorf("%w: writing the report of %q returned %v", errProvision, device.FriendlyName(), err)
+	}
+	deck.InfofA("Storing the log of this run on %q...", device.FriendlyName()).With(deck.V(1)).Go()
+	if err := step("store_log", device.Identifier(), func() error { return i.StoreLog(device, o.MediaLog(), report.Bytes()) }); err != nil {
+		return fmt.Errorf("%w: StoreLog(%q) returned %v", errProvision, device.FriendlyName(), err)
+	}
+	return nil
+}
+
+// step runs f within a trace span named name, and emits events for device
+// when it starts and ends.
+func step(name, device string, f func() error) error {
+	span := trace.Begin(name)
+	console.Emit(console.Event{Type: name, Status: console.StatusStarted, Device: device})
+	err := f()
+	span.End(err)
+	console.EmitStep(name, device, err)
+	return err
+}
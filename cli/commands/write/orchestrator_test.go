@@ -0,0 +1,662 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package write
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/boottest"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/units"
+)
+
+// fakeConfig inherits all members of installer.Configuration through
+// embedding. Unimplemented members will panic if called.
+type fakeConfig struct {
+	installer.Configuration
+
+	devices []string
+	warning bool
+	policy  config.DevicePolicy
+}
+
+func (c *fakeConfig) DevicePolicy() config.DevicePolicy {
+	return c.policy
+}
+
+func (c *fakeConfig) Devices() []string {
+	return c.devices
+}
+
+func (c *fakeConfig) Distro() string {
+	return "windows"
+}
+
+func (c *fakeConfig) Track() string {
+	return "stable"
+}
+
+func (c *fakeConfig) ImagePath() string {
+	return "https://foo.bar/installer.iso"
+}
+
+func (c *fakeConfig) UpdateDevices(devices []string) {
+	c.devices = devices
+}
+
+func (c *fakeConfig) Warning() bool {
+	return c.warning
+}
+
+func (c *fakeConfig) String() string {
+	return "fakeConfig"
+}
+
+// fakeUI records the devices displayed and whether the user was prompted.
+type fakeUI struct {
+	printErr  error
+	promptErr error
+
+	printed  []installer.Device
+	prompted bool
+}
+
+func (u *fakeUI) Printf(string, ...interface{}) {}
+
+func (u *fakeUI) PrintDevices(devices []installer.Device) error {
+	u.printed = devices
+	return u.printErr
+}
+
+func (u *fakeUI) Prompt() error {
+	u.prompted = true
+	return u.promptErr
+}
+
+// recordingInstaller records the devices it prepares, provisions and
+// finalizes.
+type recordingInstaller struct {
+	fakeInstaller
+
+	prepared    []string
+	provisioned []string
+	verified    []string
+	logged      []string
+	finalized   []string
+}
+
+func (i *recordingInstaller) Cache() string {
+	return "cache"
+}
+
+func (i *recordingInstaller) Prepare(d installer.Device) error {
+	i.prepared = append(i.prepared, d.Identifier())
+	return i.prepErr
+}
+
+func (i *recordingInstaller) Provision(d installer.Device) error {
+	i.provisioned = append(i.provisioned, d.Identifier())
+	return i.provErr
+}
+
+func (i *recordingInstaller) Verify(d installer.Device) error {
+	i.verified = append(i.verified, d.Identifier())
+	return i.verErr
+}
+
+func (i *recordingInstaller) StoreLog(d installer.Device, log, report []byte) error {
+	if !bytes.Contains(report, []byte(d.Identifier())) {
+		return fmt.Errorf("report %q does not describe %q", report, d.Identifier())
+	}
+	i.logged = append(i.logged, d.Identifier()+":"+string(log))
+	return i.logErr
+}
+
+func (i *recordingInstaller) Finalize(devices []installer.Device, _ bool) error {
+	for _, d := range devices {
+		i.finalized = append(i.finalized, d.Identifier())
+	}
+	return i.finErr
+}
+
+func ids(devices []installer.Device) []string {
+	out := []string{}
+	for _, d := range devices {
+		out = append(out, d.Identifier())
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTargets(t *testing.T) {
+	available := []installer.Device{&fakeDevice{id: "1"}, &fakeDevice{id: "2"}, &fakeDevice{id: "3"}}
+	tests := []struct {
+		desc      string
+		searchErr error
+		requested []string
+		protected []string
+		all       bool
+		want      []string
+		wantErr   error
+	}{
+		{
+			desc:      "search error",
+			searchErr: errors.New("error"),
+			wantErr:   errSearch,
+		},
+		{
+			desc:      "unavailable device",
+			requested: []string{"1", "4"},
+			wantErr:   errDevice,
+		},
+		{
+			desc:      "requested order",
+			requested: []string{"3", "1"},
+			want:      []string{"3", "1"},
+		},
+		{
+			desc:      "all devices",
+			requested: []string{"2"},
+			all:       true,
+			want:      []string{"1", "2", "3"},
+		},
+		{
+			desc:      "write protected device",
+			requested: []string{"1", "2"},
+			protected: []string{"2"},
+			wantErr:   errDevice,
+		},
+		{
+			desc:      "all devices excludes write protected",
+			all:       true,
+			protected: []string{"2"},
+			want:      []string{"1", "3"},
+		},
+	}
+	for _, tt := range tests {
+		var gotFixed bool
+		protected := make(map[string]bool)
+		for _, id := range tt.protected {
+			protected[id] = true
+		}
+		o := &Orchestrator{
+			Search: func(_ string, _, _ uint64, removableOnly bool) ([]installer.Device, error) {
+				gotFixed = !removableOnly
+				return available, tt.searchErr
+			},
+			CheckWritable: func(id string) error {
+				if protected[id] {
+					return installer.ErrWriteProtected
+				}
+				return nil
+			},
+			UI:            &fakeUI{},
+			RemovableOnly: true,
+		}
+		conf := &fakeConfig{devices: tt.requested}
+		got, err := o.Targets(conf, tt.all)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Targets() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if gotFixed {
+			t.Errorf("%s: Targets() searched fixed devices, want removable only", tt.desc)
+		}
+		if err != nil {
+			continue
+		}
+		if !equal(ids(got), tt.want) {
+			t.Errorf("%s: Targets() got: %v, want: %v", tt.desc, ids(got), tt.want)
+		}
+	}
+}
+
+func TestTargetsSearchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	o := &Orchestrator{
+		Search: func(string, uint64, uint64, bool) ([]installer.Device, error) {
+			<-release
+			return nil, nil
+		},
+		UI:            &fakeUI{},
+		SearchTimeout: 10 * time.Millisecond,
+	}
+	if _, err := o.Targets(&fakeConfig{devices: []string{"1"}}, false); !errors.Is(err, errSearch) {
+		t.Errorf("Targets() err: %v, want: %v", err, errSearch)
+	}
+}
+
+func TestTargetsDevicePolicy(t *testing.T) {
+	tests := []struct {
+		desc          string
+		minSize       uint64
+		maxSize       uint64
+		policy        config.DevicePolicy
+		wantMin       uint64
+		wantMax       uint64
+		wantRemovable bool
+	}{
+		{
+			desc:    "no policy",
+			minSize: uint64(2 * units.GB),
+			maxSize: uint64(64 * units.GB),
+			wantMin: uint64(2 * units.GB),
+			wantMax: uint64(64 * units.GB),
+		},
+		{
+			desc:    "policy raises minimum",
+			minSize: uint64(2 * units.GB),
+			policy:  config.DevicePolicy{MinSize: 8 * units.GB},
+			wantMin: uint64(8 * units.GB),
+		},
+		{
+			desc:    "flags raise minimum",
+			minSize: uint64(16 * units.GB),
+			policy:  config.DevicePolicy{MinSize: 8 * units.GB},
+			wantMin: uint64(16 * units.GB),
+		},
+		{
+			desc:    "policy sets maximum",
+			policy:  config.DevicePolicy{MaxSize: 32 * units.GB},
+			wantMax: uint64(32 * units.GB),
+		},
+		{
+			desc:    "policy lowers maximum",
+			maxSize: uint64(64 * units.GB),
+			policy:  config.DevicePolicy{MaxSize: 32 * units.GB},
+			wantMax: uint64(32 * units.GB),
+		},
+		{
+			desc:    "flags lower maximum",
+			maxSize: uint64(16 * units.GB),
+			policy:  config.DevicePolicy{MaxSize: 32 * units.GB},
+			wantMax: uint64(16 * units.GB),
+		},
+		{
+			desc:          "policy bars fixed devices",
+			policy:        config.DevicePolicy{RemovableOnly: true},
+			wantRemovable: true,
+		},
+	}
+	for _, tt := range tests {
+		var gotMin, gotMax uint64
+		var gotRemovable bool
+		o := &Orchestrator{
+			Search: func(_ string, minSize, maxSize uint64, removableOnly bool) ([]installer.Device, error) {
+				gotMin, gotMax, gotRemovable = minSize, maxSize, removableOnly
+				return nil, nil
+			},
+			UI:      &fakeUI{},
+			MinSize: tt.minSize,
+			MaxSize: tt.maxSize,
+		}
+		if _, err := o.Targets(&fakeConfig{policy: tt.policy}, false); err != nil {
+			t.Errorf("%s: Targets() returned unexpected error: %v", tt.desc, err)
+		}
+		if gotMin != tt.wantMin || gotMax != tt.wantMax || gotRemovable != tt.wantRemovable {
+			t.Errorf("%s: Targets() searched (%d, %d, %t), want (%d, %d, %t)", tt.desc, gotMin, gotMax, gotRemovable, tt.wantMin, tt.wantMax, tt.wantRemovable)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	targets := []installer.Device{&fakeDevice{id: "1"}}
+	tests := []struct {
+		desc         string
+		ui           *fakeUI
+		warning      bool
+		wantPrompted bool
+		wantErr      bool
+	}{
+		{
+			desc:    "print error",
+			ui:      &fakeUI{printErr: errors.New("error")},
+			warning: true,
+			wantErr: true,
+		},
+		{
+			desc:         "prompt declined",
+			ui:           &fakeUI{promptErr: errors.New("error")},
+			warning:      true,
+			wantPrompted: true,
+			wantErr:      true,
+		},
+		{
+			desc:         "prompt accepted",
+			ui:           &fakeUI{},
+			warning:      true,
+			wantPrompted: true,
+		},
+		{
+			desc: "no warning",
+			ui:   &fakeUI{},
+		},
+	}
+	for _, tt := range tests {
+		o := &Orchestrator{UI: tt.ui}
+		err := o.Confirm(&fakeConfig{devices: []string{"1"}, warning: tt.warning}, targets)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Confirm() err: %v, want err: %t", tt.desc, err, tt.wantErr)
+		}
+		if tt.ui.prompted != tt.wantPrompted {
+			t.Errorf("%s: Confirm() prompted: %t, want: %t", tt.desc, tt.ui.prompted, tt.wantPrompted)
+		}
+		if !tt.wantErr && !equal(ids(tt.ui.printed), []string{"1"}) {
+			t.Errorf("%s: Confirm() printed: %v, want: [1]", tt.desc, ids(tt.ui.printed))
+		}
+	}
+}
+
+func TestConfirmIdentify(t *testing.T) {
+	targets := []installer.Device{&fakeDevice{id: "1"}, &fakeDevice{id: "2"}}
+	for _, warning := range []bool{true, false} {
+		var mu sync.Mutex
+		identified := []string{}
+		active := 0
+		o := &Orchestrator{
+			UI: &fakeUI{},
+			Identify: func(ctx context.Context, d installer.Device) error {
+				mu.Lock()
+				identified = append(identified, d.Identifier())
+				active++
+				mu.Unlock()
+				<-ctx.Done()
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			},
+		}
+		if err := o.Confirm(&fakeConfig{devices: []string{"1", "2"}, warning: warning}, targets); err != nil {
+			t.Fatalf("Confirm(warning: %t) returned %v", warning, err)
+		}
+		sort.Strings(identified)
+		want := []string{}
+		if warning {
+			want = []string{"1", "2"}
+		}
+		if !equal(identified, want) {
+			t.Errorf("Confirm(warning: %t) identified: %v, want: %v", warning, identified, want)
+		}
+		if active != 0 {
+			t.Errorf("Confirm(warning: %t) returned while %d devices were blinking", warning, active)
+		}
+	}
+}
+
+func TestProvisionEvents(t *testing.T) {
+	verbose := console.Verbose
+	defer func() { console.Verbose = verbose; console.DisableEvents() }()
+	var b bytes.Buffer
+	console.EnableEvents(&b)
+	inst := &recordingInstaller{fakeInstaller: fakeInstaller{provErr: errors.New("error")}}
+	o := &Orchestrator{
+		NewInstaller: func(installer.Configuration) (ImageInstaller, error) { return inst, nil },
+		WaitReady:    func(installer.Detector, time.Duration) error { return nil },
+		UI:           &fakeUI{},
+	}
+	if err := o.Provision(&fakeConfig{}, []installer.Device{&fakeDevice{id: "1"}, &fakeDevice{id: "2"}}); !errors.Is(err, errProvision) {
+		t.Errorf("Provision() got: %v, want: %v", err, errProvision)
+	}
+	want := []string{
+		"retrieve started ",
+		"retrieve completed ",
+		"prepare started 1",
+		"prepare completed 1",
+		"provision started 1",
+		"provision failed 1",
+		"result failure 1",
+		"result skipped 2",
+		"finalize started ",
+		"finalize completed ",
+	}
+	var got []string
+	d := json.NewDecoder(&b)
+	for d.More() {
+		e := console.Event{}
+		if err := d.Decode(&e); err != nil {
+			t.Fatalf("Provision() wrote an event that is not JSON: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s", e.Type, e.Status, e.Device))
+	}
+	if !equal(got, want) {
+		t.Errorf("Provision() events got: %q, want: %q", got, want)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	targets := []installer.Device{&fakeDevice{id: "1"}, &fakeDevice{id: "2"}}
+	tests := []struct {
+		desc            string
+		inst            *recordingInstaller
+		newErr          error
+		readyErr        error
+		verify          bool
+		mediaLog        bool
+		bootTest        bool
+		bootErr         error
+		want            error
+		wantProvisioned []string
+		wantFinalized   []string
+		wantResults     []string
+		wantWarnings    int
+	}{
+		{
+			desc:        "installer error",
+			newErr:      errors.New("error"),
+			want:        errInstaller,
+			wantResults: []string{"skipped", "skipped"},
+		},
+		{
+			desc:          "retrieve error",
+			inst:          &recordingInstaller{fakeInstaller: fakeInstaller{retErr: errors.New("error")}},
+			want:          errRetrieve,
+			wantFinalized: []string{"1", "2"},
+			wantResults:   []string{"skipped", "skipped"},
+		},
+		{
+			desc:          "device not ready",
+			inst:          &recordingInstaller{},
+			readyErr:      errors.New("error"),
+			want:          errPrepare,
+			wantFinalized: []string{"1", "2"},
+			wantResults:   []string{"failure", "skipped"},
+		},
+		{
+			desc:            "provision error stops at first device",
+			inst:            &recordingInstaller{fakeInstaller: fakeInstaller{provErr: errors.New("error")}},
+			want:            errProvision,
+			wantProvisioned: []string{"1"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"failure", "skipped"},
+		},
+		{
+			desc:            "finalize error after success is a warning",
+			inst:            &recordingInstaller{fakeInstaller: fakeInstaller{finErr: errors.New("error")}},
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+			wantWarnings:    1,
+		},
+		{
+			desc:            "finalize error after failure keeps the failure",
+			inst:            &recordingInstaller{fakeInstaller: fakeInstaller{provErr: errors.New("error"), finErr: errors.New("error")}},
+			want:            errProvision,
+			wantProvisioned: []string{"1"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"failure", "skipped"},
+		},
+		{
+			desc:            "verification failure stops at first device",
+			inst:            &recordingInstaller{fakeInstaller: fakeInstaller{verErr: errors.New("error")}},
+			verify:          true,
+			want:            errVerify,
+			wantProvisioned: []string{"1"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"failure", "skipped"},
+		},
+		{
+			desc:            "verification success",
+			inst:            &recordingInstaller{},
+			verify:          true,
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+		},
+		{
+			desc:            "store log failure stops at first device",
+			inst:            &recordingInstaller{fakeInstaller: fakeInstaller{logErr: errors.New("error")}},
+			mediaLog:        true,
+			want:            errProvision,
+			wantProvisioned: []string{"1"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"failure", "skipped"},
+		},
+		{
+			desc:            "store log success",
+			inst:            &recordingInstaller{},
+			mediaLog:        true,
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+		},
+		{
+			desc:            "unbootable stops at first device",
+			inst:            &recordingInstaller{},
+			bootTest:        true,
+			bootErr:         boottest.ErrUnbootable,
+			want:            errBootTest,
+			wantProvisioned: []string{"1"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"failure", "skipped"},
+		},
+		{
+			desc:            "boot test unavailable",
+			inst:            &recordingInstaller{},
+			bootTest:        true,
+			bootErr:         fmt.Errorf("%w: QEMU was not found", boottest.ErrUnavailable),
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+		},
+		{
+			desc:            "boot test success",
+			inst:            &recordingInstaller{},
+			bootTest:        true,
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+		},
+		{
+			desc:            "success",
+			inst:            &recordingInstaller{},
+			wantProvisioned: []string{"1", "2"},
+			wantFinalized:   []string{"1", "2"},
+			wantResults:     []string{"success", "success"},
+		},
+	}
+	for _, tt := range tests {
+		inst, newErr, readyErr, bootErr := tt.inst, tt.newErr, tt.readyErr, tt.bootErr
+		o := &Orchestrator{
+			NewInstaller: func(installer.Configuration) (ImageInstaller, error) {
+				if newErr != nil {
+					return nil, newErr
+				}
+				return inst, nil
+			},
+			WaitReady: func(installer.Detector, time.Duration) error { return readyErr },
+			UI:        &fakeUI{},
+			Verify:    tt.verify,
+		}
+		if tt.mediaLog {
+			o.MediaLog = func() []byte { return []byte("log") }
+		}
+		var tested []string
+		if tt.bootTest {
+			o.BootTest = func(d installer.Device) error {
+				tested = append(tested, d.Identifier())
+				return bootErr
+			}
+		}
+		got := o.Provision(&fakeConfig{}, targets)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Provision() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		var results []string
+		for _, r := range o.Inventory {
+			results = append(results, r.Result)
+		}
+		if !equal(results, tt.wantResults) {
+			t.Errorf("%s: Provision() inventory results: %v, want: %v", tt.desc, results, tt.wantResults)
+		}
+		if len(o.Warnings) != tt.wantWarnings {
+			t.Errorf("%s: Provision() warnings: %v, want: %d", tt.desc, o.Warnings, tt.wantWarnings)
+		}
+		for _, w := range o.Warnings {
+			if !errors.Is(w.Err, errFinalize) || !strings.Contains(w.Guidance, "eject 1 2") {
+				t.Errorf("%s: Provision() warning: %+v, want a finalize error with guidance to eject the devices", tt.desc, w)
+			}
+		}
+		if inst == nil {
+			continue
+		}
+		if !equal(inst.provisioned, tt.wantProvisioned) {
+			t.Errorf("%s: Provision() provisioned: %v, want: %v", tt.desc, inst.provisioned, tt.wantProvisioned)
+		}
+		if !equal(inst.finalized, tt.wantFinalized) {
+			t.Errorf("%s: Provision() finalized: %v, want: %v", tt.desc, inst.finalized, tt.wantFinalized)
+		}
+		if tt.verify && !equal(inst.verified, inst.provisioned) {
+			t.Errorf("%s: Provision() verified: %v, want: %v", tt.desc, inst.verified, inst.provisioned)
+		}
+		if !tt.verify && len(inst.verified) > 0 {
+			t.Errorf("%s: Provision() verified %v without verification", tt.desc, inst.verified)
+		}
+		var wantLogged []string
+		if tt.mediaLog {
+			for _, d := range inst.provisioned {
+				wantLogged = append(wantLogged, d+":log")
+			}
+		}
+		if !equal(inst.logged, wantLogged) {
+			t.Errorf("%s: Provision() stored logs: %v, want: %v", tt.desc, inst.logged, wantLogged)
+		}
+		if tt.bootTest && !equal(tested, inst.provisioned) {
+			t.Errorf("%s: Provision() boot tested: %v, want: %v", tt.desc, tested, inst.provisioned)
+		}
+	}
+}
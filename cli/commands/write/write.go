@@ -16,18 +16,28 @@
 package write
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"flag"
+	"github.com/google/fresnel/cli/boottest"
 	"github.com/google/fresnel/cli/config"
 	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
 	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/notify"
+	"github.com/google/fresnel/cli/resources"
+	"github.com/google/fresnel/cli/trace"
+	"github.com/google/fresnel/cli/units"
 	"github.com/google/deck/backends/logger"
 	"github.com/google/deck"
 	"github.com/google/subcommands"
@@ -35,29 +45,78 @@ import (
 )
 
 const (
-	oneGB   int = 1073741824 // Represents one GB of data.
-	minSize int = 2          // The default minimum size for available storage.
+	minSize = 2 * units.GB // The default minimum size for available storage.
+)
+
+// Exit codes reported for each category of failure, so that scripts can tell
+// them apart. Usage errors are reported with subcommands.ExitUsageError (2),
+// and failures that fall in no category with subcommands.ExitFailure (1).
+const (
+	exitConfig     subcommands.ExitStatus = 10 // The flags or configuration file are invalid.
+	exitPermission subcommands.ExitStatus = 11 // Elevation is required, or policy prevents writes.
+	exitDevice     subcommands.ExitStatus = 12 // A device was not found or is not suitable.
+	exitDownload   subcommands.ExitStatus = 13 // The image could not be obtained.
+	exitPrepare    subcommands.ExitStatus = 14 // A device could not be prepared.
+	exitProvision  subcommands.ExitStatus = 15 // A device could not be provisioned.
+	exitVerify     subcommands.ExitStatus = 16 // A device failed verification or its boot test.
+	exitFinalize   subcommands.ExitStatus = 17 // Devices could not be finalized.
+	exitReport     subcommands.ExitStatus = 18 // The inventory or trace could not be written.
 )
 
 var (
 	binaryName string
 
 	// Wrapped errors for testing.
+	errBootTest  = errors.New("boot test error")
 	errConfig    = errors.New("config error")
 	errDevice    = errors.New("device error")
 	errInstaller = errors.New("installer error")
+	errInventory = errors.New("inventory error")
 	errElevation = errors.New("elevation error")
 	errFinalize  = errors.New("finalize error")
 	errPrepare   = errors.New("prepare error")
 	errProvision = errors.New("provision error")
 	errRetrieve  = errors.New("retrieve error")
 	errSearch    = errors.New("search error")
+	errVerify    = errors.New("verification error")
+
+	// exitCodes maps the errors returned by run to exit codes. When an error
+	// wraps several, the first listed is used.
+	exitCodes = []struct {
+		err  error
+		code subcommands.ExitStatus
+	}{
+		{errConfig, exitConfig},
+		{errElevation, exitPermission},
+		{config.ErrUSBwriteAccess, exitPermission},
+		{errSearch, exitDevice},
+		{errDevice, exitDevice},
+		{errInstaller, exitDownload},
+		{errRetrieve, exitDownload},
+		{errPrepare, exitPrepare},
+		{errProvision, exitProvision},
+		{errVerify, exitVerify},
+		{errBootTest, exitVerify},
+		{errFinalize, exitFinalize},
+		{errInventory, exitReport},
+		{trace.ErrTrace, exitReport},
+	}
 
 	// Dependency Injections for testing
 	execute            = run
 	search             = storageSearch
 	newInstaller       = installerNew
+	waitReady          = installer.WaitReady
+	checkWritable      = installer.CheckWritable
+	serialNumber       = installer.SerialNumber
+	bootTest           = bootTestDevice
+	locateDevice       = installer.Locate
 	funcUSBPermissions = config.HasWritePermissions
+	loadDistributions  = config.LoadDistributions
+	notifyEnabled      = notify.Enabled
+	notifySend         = notify.Send
+	runHook            = shellRun
+	bell               = io.Writer(os.Stdout)
 )
 
 func init() {
@@ -93,6 +152,17 @@ type writeCmd struct {
 	// are cleaned up after provisioning. Defaults to true.
 	cleanup bool
 
+	// cacheDir is a directory of files retained by an earlier run with
+	// cleanup disabled, which are reused rather than downloaded again. It is
+	// never removed.
+	cacheDir string
+
+	// cacheMaxAge and cacheMaxSize limit the entries kept in cacheDir. Entries
+	// unused for longer than cacheMaxAge are evicted, as are the least
+	// recently used entries beyond cacheMaxSize. Zero values are unlimited.
+	cacheMaxAge  time.Duration
+	cacheMaxSize units.Value
+
 	// dismount determines whether devices are dismounted after provisioning
 	// to limit accidental writes afterwords. The default value is specified
 	// when initializing the subcommand.
@@ -125,11 +195,25 @@ type writeCmd struct {
 	// in the configuration for the distribution.
 	seedServer string
 
+	// env selects a deployment of the backend, such as 'dev' for a server
+	// running locally. It overrides the seed and sign servers configured for
+	// the distribution, and cannot be combined with seedServer.
+	env string
+
+	// configFile is a YAML or JSON file of distributions that are merged over
+	// the built-in ones. When empty, the file at config.DefaultConfigPath is
+	// used if it exists.
+	configFile string
+
 	// warning provides a confirmation prompt before devices are overwritten. It
 	// defaults to true. Warnings are automatically skipped when all devices
 	// already have an installer, as no data loss is possible.
 	warning bool
 
+	// identify blinks the activity LEDs of the devices that will be
+	// overwritten while the confirmation prompt is displayed.
+	identify bool
+
 	// eject powers off and ejects a device after writing the image. The default
 	// value is specified when the subcommand is initialized.
 	eject bool
@@ -139,6 +223,93 @@ type writeCmd struct {
 	// type. It is typically used by non-admin users.
 	update bool
 
+	// sparse skips writing zero-filled regions of raw images. Skipped regions
+	// are zeroed on the device instead, which is only faster than writing them
+	// when the device offloads zeroing. Otherwise the kernel writes the zeros.
+	sparse bool
+
+	// trim discards the contents of a device before a raw image is written.
+	trim bool
+
+	// persistence adds an ext4 persistence partition after raw images of
+	// linux distributions, so that live images keep changes across boots. It
+	// is persistenceSize large, or fills the remainder of the device when that
+	// is zero, and is labeled persistenceLabel.
+	persistence      bool
+	persistenceSize  units.Value
+	persistenceLabel string
+
+	// rollback provisions the previous known-good image for the track, as
+	// listed in the image manifest, rather than the current one.
+	rollback bool
+
+	// preserve keeps the modification times and, on Windows, the basic
+	// attributes of files copied from ISO images. Defaults to true.
+	preserve bool
+
+	// contents stores the manifest of files written from ISO images on the
+	// media alongside the seed, as well as in the cache.
+	contents bool
+
+	// logOnMedia stores a redacted copy of the log and report of the run in a
+	// hidden directory on media provisioned from ISO images, so that how the
+	// media was created travels with it.
+	logOnMedia bool
+
+	// unattend is an autounattend.xml answer file that is copied to the root
+	// of media provisioned from Windows ISO images for unattended installs.
+	unattend string
+
+	// batch names the batch that media is provisioned in, such as
+	// 'NYC-onboarding-June'. It tags logs, the seed request, the media and the
+	// completion command so that batches can be reconciled with asset records.
+	batch string
+
+	// impersonate is a service account that is impersonated to obtain seeds,
+	// so that media can be provisioned by scheduled jobs without interaction.
+	// If impersonateUser is also set, the service account acts as that user
+	// through domain-wide delegation, and seeds are issued to them.
+	impersonate     string
+	impersonateUser string
+
+	// proxy is the URL of a proxy that images and seeds are obtained through,
+	// such as 'http://user@proxy.example.com:3128'. When empty, the proxy of
+	// the configuration file, or those of the environment, are used.
+	proxy string
+
+	// seedFields are values of the form 'key=value', such as a cost center,
+	// that are sent with seed requests in addition to those of the
+	// distribution.
+	seedFields stringList
+
+	// scanCommand is a command, such as 'clamscan --no-summary {path}', that
+	// the image and any other file written to media is scanned with before
+	// devices are touched. Provisioning fails when it exits with a status
+	// other than zero.
+	scanCommand string
+
+	// notify shows a desktop notification when provisioning completes or
+	// fails. Notifications are never shown in non-interactive sessions.
+	notify bool
+
+	// beep sounds an audible cue when provisioning completes, once for success
+	// and three times for failure.
+	beep bool
+
+	// onComplete is a command that is run when provisioning completes or fails,
+	// such as to signal a light at a depot bench. The result is provided to it
+	// in environment variables.
+	onComplete string
+
+	// inventory is the path that a report of the devices provisioned is
+	// written to, in inventoryFormat, for attaching to deployment tickets.
+	inventory       string
+	inventoryFormat string
+
+	// trace is the path that timing spans for the download, preparation, copy
+	// and seeding of each device are written to, as OTLP JSON.
+	trace string
+
 	// info causes console messages to be displayed with debugging information
 	// included.
 	info bool
@@ -151,28 +322,122 @@ type writeCmd struct {
 	// maximum. It is most often used for simplicity when troubleshooting.
 	verbose bool
 
+	// debug is a comma separated list of the debug categories to log, such as
+	// 'network,storage', regardless of v.
+	debug string
+
+	// debugResources tracks open handles, mounts and goroutines between the
+	// phases of provisioning, and reports those that leaked at exit.
+	debugResources bool
+
+	// container runs in a privileged container, such as on an imaging server.
+	// Devices must be named, and may be given as device nodes, which are
+	// checked to be whole disks. Prompts and notifications are skipped, and
+	// distributions are read from a bind mounted config.ContainerConfigPath.
+	container bool
+
+	// json replaces the human-readable output with newline-delimited JSON
+	// events describing progress and the result for each device, for
+	// automation wrappers.
+	json bool
+
 	// listFixed determines whether we want to consider fixed drives when
 	// determining available devices. It is defaulted to false by flag.
 	// If listFixed is specified, the all flag is disallowed.
 	listFixed bool
 
-	// minSize is the minimum size device to search for in GB. For convenience,
-	// this value is defaulted to minSize.
-	minSize int
+	// minSize is the minimum size device to search for, such as "8G". Values
+	// without a suffix are in GB. For convenience, this value is defaulted to
+	// minSize.
+	minSize units.Value
 
-	// maxSize is the largest size device to search for in GB. For convenience,
+	// maxSize is the largest size device to search for, such as "1.5T". Values
+	// without a suffix are in GB. For convenience,
 	// this value is set to 'no limit (0)' by default by flag.
-	maxSize int
+	maxSize units.Value
+
+	// readyTimeout is how long to wait for each device to become ready before
+	// it is prepared. Newly inserted devices can report transient errors while
+	// drivers settle. Zero checks each device once without waiting.
+	readyTimeout time.Duration
+
+	// searchTimeout is how long the search for available devices is given to
+	// complete. Zero waits without limit.
+	searchTimeout time.Duration
+
+	// bootTest boots each device in an emulator after it is provisioned, and
+	// fails if its firmware does not start a bootloader. The test is skipped
+	// when QEMU and OVMF are not installed.
+	bootTest bool
+
+	// bootTestTimeout is how long the emulator is given to start a bootloader.
+	bootTestTimeout time.Duration
+
+	// verify reads each device back after it is provisioned and compares it
+	// with the image, to detect media that silently corrupts writes.
+	verify bool
+
+	// failOnWarnings fails the command when post-provisioning steps, such as
+	// ejecting devices or removing the cache, fail after every device was
+	// provisioned successfully. Otherwise these are reported as warnings.
+	failOnWarnings bool
+
+	// warnings are the warnings of the last run, reported when it completes.
+	warnings []Warning
+
+	// targets are the identifiers of the devices that the last run found and
+	// attempted to provision, reported when it completes.
+	targets []string
 }
 
 // Ensure writeCommand implements the subcommands.Command interface.
 var _ subcommands.Command = (*writeCmd)(nil)
 
+// stringList is a flag that may be repeated, collecting each of its values.
+type stringList []string
+
+// String returns the values of the flag separated by commas.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a value each time the flag is provided.
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// logBuffer collects the log of a run, so that it can be stored on media.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write adds p to the log.
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// Bytes returns a copy of the log written so far.
+func (l *logBuffer) Bytes() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]byte(nil), l.buf.Bytes()...)
+}
+
 // Name returns the name of the subcommand.
 func (c *writeCmd) Name() string {
 	return c.name
 }
 
+// AccessesDevices reports that the subcommand accesses devices, so that it is
+// delegated to the Windows build of the binary when running under WSL.
+func (*writeCmd) AccessesDevices() bool {
+	return true
+}
+
 // Synopsis returns a short string (less than one line) describing the subcommand.
 func (c *writeCmd) Synopsis() string {
 	d := c.distro
@@ -194,21 +459,59 @@ Flags:
   --all        - Provision all suitable devices that are attached to this system.
   --a          - Alias for --all
   --cleanup    - Cleanup temporary files after provisioning completes.
+  --cache_dir [path] - Reuse the files retained in a directory by an earlier run with --cleanup=false.
+  --cache_max_age [duration] - Evict entries of the cache directory that have not been used for this long.
+  --cache_max_size [size] - Evict the least recently used entries of the cache directory beyond this size.
   --dismount   - Dismount devices after provisioning completes.
   --eject      - Eject/PowerOff devices after provisioning completes.
 	--ffu        - Place the split ffu files on the media after provisioning completes.
   --warning    - Display a confirmation prompt before non-installers are overwritten.
+  --identify   - Blink the activity LEDs of devices while the confirmation prompt is displayed.
   --distro     - The os distribution to be provisioned, typically 'windows' or 'linux'
+  --config [path] - Load distributions from a YAML or JSON file, merged over the built-in ones.
   --track      - The track (variant) of the installer to provision.
 	--conf_track - The track (variant) of the configuration to provision.
 	--update     - Attempts to perform a device refresh only (for non-admin users).
+  --sparse     - Zero rather than write zero-filled regions of raw images, implies --trim.
+  --trim       - Discard the contents of devices before writing raw images.
+  --persistence - Add an ext4 persistence partition after linux raw images, for persistent live media.
+  --persistence_size [size] - The size of the persistence partition, such as '4G', filling the device by default.
+  --persistence_label [string] - The label of the persistence partition, such as 'casper-rw' for Ubuntu.
+  --rollback   - Provision the previous known-good image for the track.
+  --preserve   - Keep the modification times and attributes of files copied from ISOs.
+  --content_manifest - Store a manifest of the files written from ISOs, with sizes and hashes, on the media.
+  --log_on_media - Store a redacted copy of the log and report of this run in a hidden directory on the media.
+  --unattend [path] - Copy an autounattend.xml answer file to Windows media for unattended installs.
+  --batch [name] - Tag logs, seed requests and media with a batch name, such as 'NYC-onboarding-June'.
+  --impersonate_service_account [email] - Obtain seeds as a service account, without interaction.
+  --impersonate_user [email] - The user the service account acts as through domain-wide delegation.
+  --proxy [url] - Obtain images and seeds through a proxy, such as 'http://user@proxy.example.com:3128'.
+  --seed_field [key=value] - Send a value with seed requests, such as 'cost_center=1234'. May be repeated.
+  --scan_command [command] - Scan files with this command before writing them, such as 'clamscan --no-summary {path}'.
+  --notify     - Show a desktop notification when provisioning completes or fails.
+  --beep       - Sound an audible cue when provisioning completes or fails.
+  --on_complete [command] - Run a command when provisioning completes or fails.
+  --inventory [path] - Write a report of the devices provisioned and their results to a file.
+  --inventory_format [format] - The format of the inventory, one of csv, json or yaml.
+  --trace [path] - Write timing spans for each step of provisioning to a file, as OTLP JSON.
+  --container  - Run non-interactively in a privileged container, with named device nodes such as /dev/sdb.
+  --json       - Write progress and results as newline-delimited JSON events instead of text.
   --info       - Display console messages with debugging information included.
   --verbose    - Increase info log verbosity to maximum, used as an alias for '--v 5'.
   --v          - Controls the level of info log verbosity.
+  --debug [categories] - Log debugging messages for network, storage, seed and/or copy, such as 'network,storage'.
+  --debug_resources - Log open handles, mounts and goroutines between phases, and report leaks at exit.
 
   --show_fixed    - Includes fixed disks when searching for suitable devices.
-  --minimum [int] - The minimum size in GB to consider when searching.
-  --maximum [int] - The maximum size in GB to consider when searching.
+  --minimum [size] - The minimum size to consider when searching, such as '512M' or '8G'.
+  --maximum [size] - The maximum size to consider when searching, such as '1.5T'.
+                     Sizes without a suffix are in GB.
+  --ready_timeout [duration] - How long to wait for newly inserted devices to settle, such as '30s'.
+  --search_timeout [duration] - How long to wait for the search for devices to complete, 0 for no limit.
+  --boot_test  - Boot devices in QEMU after provisioning to check that they start a bootloader.
+  --boot_test_timeout [duration] - How long the emulator is given to start a bootloader.
+  --verify     - Read devices back after provisioning and compare them with the image.
+  --fail_on_warnings - Fail when devices cannot be ejected or the cache removed after provisioning succeeds.
 
 Use the 'list' command to list available devices or use the '--all' flag to
 write to all suitable devices.
@@ -237,21 +540,63 @@ func (c *writeCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.allDrives, "all", false, "write the installer to all suitable storage devices")
 	f.BoolVar(&c.allDrives, "a", false, "write the installer to all suitable flash drives (shorthand)")
 	f.BoolVar(&c.cleanup, "cleanup", true, "cleanup temporary files after provisioning is complete")
+	f.StringVar(&c.cacheDir, "cache_dir", "", "reuse the files retained in this directory by an earlier run with --cleanup=false, rather than downloading them")
+	f.DurationVar(&c.cacheMaxAge, "cache_max_age", 0, "evict entries of --cache_dir that have not been used for this long, 0 keeps them regardless of age")
+	c.cacheMaxSize = units.Value{Unit: units.GB}
+	f.Var(&c.cacheMaxSize, "cache_max_size", "evict the least recently used entries of --cache_dir beyond this size, such as '50G' [GB if no suffix], 0 does not limit the size")
 	f.BoolVar(&c.eject, "eject", c.eject, "eject/power-off devices after provisioning is complete")
 	f.BoolVar(&c.ffu, "ffu", c.ffu, "place the split ffu files onto storage devices after initial provisioning")
 	f.BoolVar(&c.warning, "warning", true, "display a confirmation prompt before non-installer storage devices are overwritten")
+	f.BoolVar(&c.identify, "identify", false, "blink the activity LEDs of devices while the confirmation prompt is displayed")
 	f.BoolVar(&c.update, "update", c.update, "attempts to perform a device refresh only for non-admin users")
+	f.BoolVar(&c.sparse, "sparse", false, "zero rather than write zero-filled regions of raw images, only faster on devices that offload zeroing, implies --trim")
+	f.BoolVar(&c.trim, "trim", false, "discard the contents of devices before writing raw images")
+	f.BoolVar(&c.rollback, "rollback", false, "provision the previous known-good image for the track, as listed in the image manifest")
+	f.BoolVar(&c.preserve, "preserve", true, "keep the modification times and, on Windows, the attributes of files copied from ISO images")
+	f.BoolVar(&c.contents, "content_manifest", false, "store a manifest of the files written from ISO images, with their sizes and hashes, on the media alongside the seed")
+	f.BoolVar(&c.logOnMedia, "log_on_media", false, "store a redacted copy of the log and report of this run in a hidden directory on media provisioned from ISO images")
+	f.StringVar(&c.unattend, "unattend", "", "copy this .xml answer file to the root of media provisioned from Windows ISO images as autounattend.xml")
+	f.StringVar(&c.batch, "batch", "", "a name for the batch being provisioned, used to tag logs, seed requests, the media and the completion command")
+	f.StringVar(&c.impersonate, "impersonate_service_account", "", "obtain seeds and signed URLs as this service account, using the credentials of the environment, rather than as the signed-in user")
+	f.StringVar(&c.impersonateUser, "impersonate_user", "", "the user that the --impersonate_service_account acts as through domain-wide delegation, who seeds are issued to")
+	f.StringVar(&c.proxy, "proxy", "", "obtain images and seeds through the proxy at this URL, such as 'http://user@proxy.example.com:3128', rather than the proxy of the configuration file or of the HTTPS_PROXY environment variable")
+	f.StringVar(&c.scanCommand, "scan_command", "", "scan the image and other files with this command before they are written to media, failing when it exits with a status other than zero; {path} is replaced by the path of each file, which is otherwise appended")
+	f.Var(&c.seedFields, "seed_field", "a value of the form key=value to send with seed requests, such as a cost center, in addition to those of the distribution; may be repeated")
 	f.StringVar(&c.distro, "distro", c.distro, "the os distribution to be provisioned, typically 'windows' or 'linux'")
 	f.StringVar(&c.track, "track", c.track, "track (variant) of the installer to provision")
 	f.StringVar(&c.confTrack, "conf_track", c.track, "track (variant) of the configuration file to provision, only valid with FFU based distros")
 	f.StringVar(&c.seedServer, "seed_server", "", "override the default server to use for obtaining seeds, only used for debugging")
+	f.StringVar(&c.env, "env", "", "backend environment to obtain seeds and signed URLs from, such as 'dev' for a local server")
+	f.StringVar(&c.configFile, "config", "", "a YAML or JSON file of distributions to merge over the built-in ones, defaults to "+config.DefaultConfigPath())
+	f.BoolVar(&c.notify, "notify", false, "show a desktop notification when provisioning completes or fails")
+	f.BoolVar(&c.beep, "beep", false, "sound an audible cue when provisioning completes or fails")
+	f.StringVar(&c.onComplete, "on_complete", "", "a command to run when provisioning completes or fails, with the result provided in FRESNEL_* environment variables")
+	f.StringVar(&c.inventory, "inventory", "", "write a report of each device provisioned, its image, seed expiry and result to this path")
+	f.StringVar(&c.inventoryFormat, "inventory_format", string(console.FormatCSV), "the format of the --inventory report, one of csv, json or yaml")
+	f.StringVar(&c.trace, "trace", "", "write timing spans for the download, preparation, copy and seeding of each device to this path as OTLP JSON")
+	f.BoolVar(&c.container, "container", false, "run in a privileged container: devices must be named and whole disks, prompts and notifications are skipped, and distributions are read from "+config.ContainerConfigPath)
+	f.BoolVar(&c.json, "json", false, "write progress and the result for each device as newline-delimited JSON events, with no other output, requires --warning=false")
 	f.BoolVar(&c.info, "info", false, "display console messages with debugging information included")
 	f.IntVar(&c.v, "v", 1, "controls the level of info log verbosity")
 	f.BoolVar(&c.verbose, "verbose", false, "increase info log verbosity to maximum, alias for '-v 5'")
+	f.StringVar(&c.debug, "debug", "", "log debugging messages for these comma separated categories regardless of -v: "+debug.Names()+" or all")
+	f.BoolVar(&c.debugResources, "debug_resources", false, "log open handles, mounts and goroutines between the phases of provisioning, and report those that leaked at exit")
 	// Search related flags.
 	f.BoolVar(&c.listFixed, "show_fixed", false, "also consider fixed drives, cannot be combined with --all")
-	f.IntVar(&c.minSize, "minimum", minSize, "minimum size [in GB] of drives to consider as available")
-	f.IntVar(&c.maxSize, "maximum", 0, "maximum size [in GB] drives to consider as available")
+	c.minSize = units.Value{Size: minSize, Unit: units.GB}
+	c.maxSize = units.Value{Unit: units.GB}
+	f.Var(&c.minSize, "minimum", "minimum size of drives to consider as available, such as '8G' [GB if no suffix]")
+	f.DurationVar(&c.readyTimeout, "ready_timeout", installer.DefaultReadyTimeout, "how long to wait for each device to become ready before writing to it, 0 checks once without waiting")
+	f.DurationVar(&c.searchTimeout, "search_timeout", installer.DefaultSearchTimeout, "how long to wait for the search for available devices to complete, 0 waits without limit")
+	f.BoolVar(&c.bootTest, "boot_test", false, "boot devices in QEMU with OVMF after provisioning to check that they start a bootloader, skipped when QEMU is not installed")
+	f.DurationVar(&c.bootTestTimeout, "boot_test_timeout", time.Minute, "how long the emulator is given to start a bootloader when --boot_test is set")
+	f.Var(&c.maxSize, "maximum", "maximum size of drives to consider as available, such as '1.5T' [GB if no suffix]")
+	f.BoolVar(&c.persistence, "persistence", false, "add an ext4 persistence partition after raw images of linux distributions, so that live images keep changes across boots; cannot be combined with --verify")
+	c.persistenceSize = units.Value{Unit: units.GB}
+	f.Var(&c.persistenceSize, "persistence_size", "the size of the persistence partition, such as '4G' [GB if no suffix], filling the remainder of the device when 0")
+	f.StringVar(&c.persistenceLabel, "persistence_label", "", "the label of the persistence partition, defaults to '"+config.DefaultPersistenceLabel+"' for live-boot, 'casper-rw' suits Ubuntu")
+	f.BoolVar(&c.verify, "verify", false, "read devices back after provisioning and compare their contents with the image, to detect silent corruption")
+	f.BoolVar(&c.failOnWarnings, "fail_on_warnings", false, "fail when dismounting or ejecting devices, or removing the cache, fails after every device was provisioned, rather than warning")
 
 	// Special case flag handling.
 
@@ -265,15 +610,6 @@ func (c *writeCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.dismount, "dismount", d, "dismount devices after provisioning is complete")
 }
 
-// imageInstaller represents installer.Installer.
-type imageInstaller interface {
-	Cache() string
-	Finalize([]installer.Device, bool) error
-	Retrieve() error
-	Prepare(installer.Device) error
-	Provision(installer.Device) error
-}
-
 // Execute executes the command and returns an ExitStatus.
 func (c *writeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) (exitStatus subcommands.ExitStatus) {
 	// Enable turning verbosity up past log.V(1) for the cli with a single bool
@@ -282,19 +618,29 @@ func (c *writeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 	if c.verbose {
 		c.v = 5
 	}
-	if c.info || c.v > 1 {
+	if c.json {
+		// Events are the only output, so that they can be parsed.
+		console.EnableEvents(os.Stdout)
+		defer console.DisableEvents()
+	} else if c.info || c.v > 1 {
 		console.Verbose = true
-	}
-
-	if console.Verbose {
 		deck.Add(logger.Init(os.Stdout, 0))
 	}
 
 	// Verbosity will need to be a flag in main
 	deck.SetVerbosity(c.v)
+	if err := debug.Enable(c.debug); err != nil {
+		console.Printf("%v\nusage: %s %s\n", err, os.Args[0], c.Usage())
+		deck.Error(err)
+		return subcommands.ExitUsageError
+	}
+	if c.debugResources {
+		resources.Enable()
+		defer reportLeaks()
+	}
 
 	// Log startup for upstream consumption by dashboards.
-	deck.InfofA("%s is initializing.\n", binaryName).With(deck.V(1)).Go()
+	deck.InfofA("%s is initializing%s.\n", binaryName, c.batchTag()).With(deck.V(1)).Go()
 
 	// Check if any devices were specified.
 	if f.NArg() == 0 && !c.allDrives {
@@ -322,131 +668,236 @@ func (c *writeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 	// begin provisioning.
 	if err := execute(c, f); err != nil {
 		console.Printf("%s completed with errors: %v", binaryName, err)
-		deck.Errorf("%s completed with errors: %v", binaryName, err)
-		return subcommands.ExitFailure
+		deck.Errorf("%s completed with errors%s: %v", binaryName, c.batchTag(), err)
+		console.EmitStep(console.EventComplete, "", err)
+		c.complete(c.completed(f), err)
+		return exitCode(err)
 	}
 
 	// Log completion for upstream consumption by dashboards.
-	console.Printf("%s completed successfully.", binaryName)
-	deck.InfofA("%s completed successfully.", binaryName).With(deck.V(1)).Go()
+	if len(c.warnings) > 0 {
+		console.Printf("%s completed successfully with %d warnings:\n  %s", binaryName, len(c.warnings), strings.Join(warningText(c.warnings), "\n  "))
+		deck.InfofA("%s completed successfully%s with %d warnings.", binaryName, c.batchTag(), len(c.warnings)).With(deck.V(1)).Go()
+	} else {
+		console.Printf("%s completed successfully.", binaryName)
+		deck.InfofA("%s completed successfully%s.", binaryName, c.batchTag()).With(deck.V(1)).Go()
+	}
+	console.Emit(console.Event{Type: console.EventComplete, Status: console.StatusCompleted, Warnings: warningText(c.warnings)})
+	c.complete(c.completed(f), nil)
 	return subcommands.ExitSuccess
 }
 
 func run(c *writeCmd, f *flag.FlagSet) (err error) {
+	c.warnings = nil
+	c.targets = nil
+	// Check for policy preventing writes to removable media before anything
+	// else, as no other problem can be resolved by the user.
 	if err := funcUSBPermissions(); err != nil {
 		if errors.Is(err, config.ErrWritePerms) {
-			console.Print(err)
+			console.Printf("%v\nSee %s for more information.", err, config.WritePolicyHelp)
 		}
 		deck.Warning(err)
-		return config.ErrUSBwriteAccess
+		return fmt.Errorf("%w: %v", config.ErrUSBwriteAccess, err)
+	}
+	if c.container {
+		if c.allDrives {
+			return fmt.Errorf("%w: --container requires devices to be named, --all cannot be used", errConfig)
+		}
+		// Nobody is present to respond to prompts or see notifications.
+		c.warning, c.identify, c.notify = false, false, false
+		if c.configFile == "" {
+			c.configFile = config.ContainerConfigPath
+		}
+	}
+	// Load any distributions defined outside of the binary.
+	if err := loadDistributions(c.configFile); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
 	}
 	// Generate a writer configuration.
-	conf, err := config.New(c.cleanup, c.warning, c.eject, c.ffu, c.update, f.Args(), c.distro, c.track, c.confTrack, c.seedServer)
+	conf, err := config.New(c.cleanup, c.warning, c.eject, c.ffu, c.update, c.sparse, c.trim || c.sparse, c.rollback, c.preserve, f.Args(), c.distro, c.track, c.confTrack, c.seedServer)
 	if err != nil {
-		return fmt.Errorf("%w: config.New(cleanup: %t, warning: %t, eject: %t, ffu: %t, devices: %v, distro: %s, track: %s, seedServer: %s) returned %v",
-			errConfig, c.cleanup, c.warning, c.eject, c.ffu, f.Args(), c.distro, c.track, c.seedServer, err)
+		return fmt.Errorf("%w: config.New(cleanup: %t, warning: %t, eject: %t, ffu: %t, sparse: %t, trim: %t, rollback: %t, preserve: %t, devices: %v, distro: %s, track: %s, seedServer: %s) returned %v",
+			errConfig, c.cleanup, c.warning, c.eject, c.ffu, c.sparse, c.trim || c.sparse, c.rollback, c.preserve, f.Args(), c.distro, c.track, c.seedServer, err)
 	}
-	// Write requires elevated permissions, Update does not.
-	if !c.update && !conf.Elevated() {
-		return fmt.Errorf("%w: elevated permissions are required to use the %q command, try again using 'sudo' (Linux/Mac) or 'run as administrator' (Windows)", errElevation, c.name)
+	if c.env != "" && c.seedServer != "" {
+		return fmt.Errorf("%w: --env and --seed_server cannot be used together", errConfig)
 	}
-
-	// Pull a list of suitable devices.
-	console.Printf("Searching for available devices... ")
-	deck.InfofA("Searching for available devices... ").With(deck.V(1)).Go()
-	available, err := search("", uint64(c.minSize*oneGB), uint64(c.maxSize*oneGB), !c.listFixed)
-	if err != nil {
-		return fmt.Errorf("%w: %v", errSearch, err)
+	if c.container {
+		if err := conf.CheckWholeDisks(); err != nil {
+			return fmt.Errorf("%w: %v", errDevice, err)
+		}
 	}
-
-	// If the --all flag was specified, update the target list.
-	if c.allDrives {
-		// Build a list of the device names.
-		all := []string{}
-		for _, d := range available {
-			all = append(all, d.Identifier())
+	// Prompts and bells would be mixed in with the events written to stdout.
+	if c.json && (c.warning || c.beep) {
+		return fmt.Errorf("%w: --json cannot be used with --warning or --beep", errConfig)
+	}
+	if err := conf.UseEnvironment(c.env); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseBatch(c.batch); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseCacheDir(c.cacheDir); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseCacheLimits(c.cacheMaxAge, c.cacheMaxSize.Size); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	conf.StoreContentsOnMedia(c.contents)
+	conf.StoreLogOnMedia(c.logOnMedia)
+	if err := conf.UseUnattend(c.unattend); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseImpersonation(c.impersonate, c.impersonateUser); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseProxy(c.proxy); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseSeedFields(c.seedFields); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	if err := conf.UseScanner(c.scanCommand); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	// The persistence partition changes the partition table that was written,
+	// which could then not be compared with the image.
+	if c.persistence && c.verify {
+		return fmt.Errorf("%w: --persistence cannot be used with --verify", errConfig)
+	}
+	if err := conf.UsePersistence(c.persistence, c.persistenceSize.Size, c.persistenceLabel); err != nil {
+		return fmt.Errorf("%w: %v", errConfig, err)
+	}
+	// Write requires the ability to write to devices, Update does not. Any
+	// further capabilities are checked by each operation that needs them.
+	if !c.update {
+		if err := conf.CanWriteDevice(); err != nil {
+			return fmt.Errorf("%w: the %q command cannot continue: %v", errElevation, c.name, err)
 		}
-		conf.UpdateDevices(all)
 	}
-
-	// Build a simple map of available devices for lookups.
-	verified := make(map[string]installer.Device)
-	for _, d := range available {
-		verified[d.Identifier()] = d
+	var format console.Format
+	if c.inventory != "" {
+		if format, err = console.ParseFormat(c.inventoryFormat); err != nil || format == console.FormatTable {
+			return fmt.Errorf("%w: --inventory_format %q must be one of csv, json or yaml", errConfig, c.inventoryFormat)
+		}
 	}
-	// Check if the requested devices are available and build a list of targets.
-	targets := []installer.Device{}
-	for _, t := range conf.Devices() {
-		d, ok := verified[t]
-		if !ok {
-			return fmt.Errorf("%w: requested device %q is not suitable for provisioning, available devices %v", errDevice, t, verified)
+	if c.trace != "" {
+		if err := trace.Enable(); err != nil {
+			return err
 		}
-		targets = append(targets, d)
+		defer trace.Disable()
 	}
-
-	deck.InfofA("Configuration to be applied:\n%s", conf).With(deck.V(3)).Go()
-	// Adjust wording based on whether or not we're doing an update.
-	writeType := "provisioned"
-	if c.update {
-		writeType = "updated"
+	o := c.orchestrator()
+	if c.logOnMedia {
+		l := &logBuffer{}
+		deck.Add(logger.Init(l, 0))
+		o.MediaLog = l.Bytes
 	}
-	console.Printf("The following devices will be %s with the latest %s [%s] installer:\n", writeType, conf.Distro(), conf.Track())
-	deck.InfofA("Devices %v will be %s with the latest %s [%s] installer.\n", writeType, conf.Devices(), conf.Distro(), conf.Track()).With(deck.V(2)).Go()
-
-	// Wrap targets in the interface required for the prompt.
-	devices := []console.TargetDevice{}
-	for _, device := range targets {
-		devices = append(devices, device)
+	err = o.Run(conf, c.allDrives)
+	c.warnings = o.Warnings
+	for _, rec := range o.Inventory {
+		c.targets = append(c.targets, rec.Device)
 	}
-	// Display information about the device(s) and warn the user.
-	console.PrintDevices(devices, os.Stdout, false)
-	if conf.Warning() {
-		if err := console.PromptUser(); err != nil {
-			return fmt.Errorf("console.PromptUser() returned %v", err)
-		}
+	if err == nil && c.failOnWarnings && len(o.Warnings) > 0 {
+		err = fmt.Errorf("%w (--fail_on_warnings is set)", o.Warnings[0].Err)
 	}
-
-	// Initialize the installer.
-	i, err := newInstaller(conf)
-	if err != nil {
-		return fmt.Errorf("%w: installer.New() returned %v", errInstaller, err)
-	}
-
-	// Defer dismounts, power-off, and cleanup. Finalize only performs these
-	// actions if configuration states to do so. Cleanup is performed only after
-	// the last device has been finalized.
-	defer func(devices []installer.Device) {
-		if err2 := i.Finalize(devices, c.dismount); err2 != nil {
-			if err == nil {
-				err = fmt.Errorf("%w: Finalize() returned %v", errFinalize, err2)
-			} else {
-				err = fmt.Errorf("%w: %v\nFinalize() returned %v", errFinalize, err, err2)
-			}
+	if c.inventory != "" {
+		if err2 := writeInventory(c.inventory, format, o.Inventory); err2 != nil {
+			err = appendError(err, err2)
+		} else {
+			console.Printf("An inventory of %d devices was written to %q.", len(o.Inventory), c.inventory)
 		}
-	}(targets)
-
-	// Retrieve the image. This step occurs only once for n>0 devices.
-	console.Printf("\nRetrieving image...\n    %s ->\n    %s", conf.ImagePath(), i.Cache())
-	deck.InfofA("Retrieving image...\n    %s ->\n    %s\n\n", conf.ImagePath(), i.Cache()).With(deck.V(1)).Go()
-	if err := i.Retrieve(); err != nil {
-		return fmt.Errorf("%w: Retrieve() returned %v", errRetrieve, err)
-	}
-	// Prepare and provision devices. This step occurs once per device.
-	for _, device := range targets {
-		console.Printf("\nPreparing device %q...", device.FriendlyName())
-		deck.InfofA("Preparing device %q...", device.FriendlyName()).With(deck.V(1)).Go()
-		// Prepare the device.
-		if err := i.Prepare(device); err != nil {
-			return fmt.Errorf("%w: Prepare(%q) returned %v: ", errPrepare, device.FriendlyName(), err)
+	}
+	if c.trace != "" {
+		if err2 := trace.Write(c.trace); err2 != nil {
+			err = appendError(err, err2)
+		} else {
+			console.Printf("A trace of provisioning was written to %q.", c.trace)
 		}
-		console.Printf("Provisioning device %q...", device.FriendlyName())
-		deck.InfofA("Provisioning device %q...", device.FriendlyName()).With(deck.V(1)).Go()
-		// Provision the device.
-		if err := i.Provision(device); err != nil {
-			return fmt.Errorf("%w: Provision(%q) returned %v", errProvision, device.FriendlyName(), err)
+	}
+	return err
+}
+
+// exitCode returns the exit code for the category of err.
+func exitCode(err error) subcommands.ExitStatus {
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
 		}
 	}
-	return nil
+	return subcommands.ExitFailure
+}
+
+// appendError returns err2 appended to err, retaining err for errors.Is.
+func appendError(err, err2 error) error {
+	if err == nil {
+		return err2
+	}
+	return fmt.Errorf("%w\n%v", err, err2)
+}
+
+// warningText describes each of warnings.
+func warningText(warnings []Warning) []string {
+	var out []string
+	for _, w := range warnings {
+		out = append(out, w.String())
+	}
+	return out
+}
+
+// reportLeaks reports the resources that were held at exit but not at
+// startup, and stops tracking them.
+func reportLeaks() {
+	defer resources.Disable()
+	leaks := resources.Leaks()
+	if len(leaks) == 0 {
+		console.Print("No leaked resources were found.")
+		deck.InfofA("No leaked resources were found.").With(deck.V(1)).Go()
+		return
+	}
+	console.Printf("%d leaked resources were found:\n  %s", len(leaks), strings.Join(leaks, "\n  "))
+	for _, l := range leaks {
+		deck.Warningf("Leaked resource: %s", l)
+	}
+}
+
+// batchTag returns a suffix naming the batch being provisioned for log
+// messages, so that the logs of a run can be attributed to it. It is empty
+// when no batch was named.
+func (c *writeCmd) batchTag() string {
+	if c.batch == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for batch %q", c.batch)
+}
+
+// orchestrator returns an Orchestrator configured by the flags of c.
+func (c *writeCmd) orchestrator() *Orchestrator {
+	var test func(installer.Device) error
+	if c.bootTest {
+		test = func(d installer.Device) error { return bootTest(d, c.bootTestTimeout) }
+	}
+	var identify func(context.Context, installer.Device) error
+	if c.identify {
+		identify = func(ctx context.Context, d installer.Device) error { return locateDevice(ctx, d, 0) }
+	}
+	return &Orchestrator{
+		Search:        search,
+		NewInstaller:  newInstaller,
+		WaitReady:     waitReady,
+		CheckWritable: checkWritable,
+		BootTest:      test,
+		Identify:      identify,
+		UI:            consoleUI{},
+		MinSize:       uint64(c.minSize.Size),
+		MaxSize:       uint64(c.maxSize.Size),
+		RemovableOnly: !c.listFixed,
+		ReadyTimeout:  c.readyTimeout,
+		SearchTimeout: c.searchTimeout,
+		Dismount:      c.dismount,
+		Update:        c.update,
+		Verify:        c.verify,
+	}
 }
 
 // storageSearch wraps storage.Search and returns an appropriate interface.
@@ -463,7 +914,13 @@ func storageSearch(deviceID string, minSize, maxSize uint64, removableOnly bool)
 	return results, nil
 }
 
+// bootTestDevice boots device in an emulator, allowing up to d for it to start
+// a bootloader.
+func bootTestDevice(device installer.Device, d time.Duration) error {
+	return boottest.Run(context.Background(), installer.DevicePath(device.Identifier()), d)
+}
+
 // installerNew wraps installer.New and returns an appropriate interface.
-func installerNew(config installer.Configuration) (imageInstaller, error) {
+func installerNew(config installer.Configuration) (ImageInstaller, error) {
 	return installer.New(config)
 }
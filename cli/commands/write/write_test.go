@@ -17,14 +17,18 @@ package write
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"flag"
 	"github.com/google/fresnel/cli/config"
 	"github.com/google/fresnel/cli/console"
 	"github.com/google/fresnel/cli/installer"
+	"github.com/google/fresnel/cli/trace"
+	"github.com/google/fresnel/models"
 	"github.com/google/subcommands"
 	"github.com/google/winops/storage"
 )
@@ -114,6 +118,30 @@ func TestExecute(t *testing.T) {
 			verbose: true,
 			want:    subcommands.ExitSuccess,
 		},
+		{
+			desc:    "debug categories",
+			cmd:     &writeCmd{},
+			args:    []string{"--debug=network,storage", "1"},
+			execute: func(c *writeCmd, f *flag.FlagSet) error { return nil },
+			logDir:  filepath.Dir(filepath.Join(os.TempDir(), binaryName)),
+			want:    subcommands.ExitSuccess,
+		},
+		{
+			desc:    "unknown debug category",
+			cmd:     &writeCmd{},
+			args:    []string{"--debug=disks", "1"},
+			execute: func(c *writeCmd, f *flag.FlagSet) error { return nil },
+			logDir:  filepath.Dir(filepath.Join(os.TempDir(), binaryName)),
+			want:    subcommands.ExitUsageError,
+		},
+		{
+			desc:    "debug resources",
+			cmd:     &writeCmd{},
+			args:    []string{"--debug_resources", "1"},
+			execute: func(c *writeCmd, f *flag.FlagSet) error { return nil },
+			logDir:  filepath.Dir(filepath.Join(os.TempDir(), binaryName)),
+			want:    subcommands.ExitSuccess,
+		},
 		{
 			desc:    "no drives specified but --all flag specified",
 			cmd:     &writeCmd{},
@@ -212,6 +240,10 @@ func (f *fakeDevice) Identifier() string {
 	return f.id
 }
 
+func (f *fakeDevice) FriendlyName() string {
+	return "Fake Device"
+}
+
 func (f *fakeDevice) Partition(label string) error {
 	return f.partErr
 }
@@ -236,6 +268,12 @@ type fakeInstaller struct {
 	provErr error // Returned when Provision() is called.
 	retErr  error // Returned when Retrieve() is called.
 	finErr  error // Returned when Finalize() is called.
+	verErr  error // Returned when Verify() is called.
+	logErr  error // Returned when StoreLog() is called.
+
+	imageHash string                  // Returned when ImageHash() is called.
+	expiry    time.Time               // Returned when SeedExpiry() is called.
+	contents  *models.ContentManifest // Returned when Contents() is called.
 }
 
 func (i *fakeInstaller) Prepare(installer.Device) error {
@@ -254,39 +292,159 @@ func (i *fakeInstaller) Finalize([]installer.Device, bool) error {
 	return i.finErr
 }
 
+func (i *fakeInstaller) Verify(installer.Device) error {
+	return i.verErr
+}
+
+func (i *fakeInstaller) StoreLog(installer.Device, []byte, []byte) error {
+	return i.logErr
+}
+
+func (i *fakeInstaller) ImageHash() string {
+	return i.imageHash
+}
+
+func (i *fakeInstaller) SeedExpiry() time.Time {
+	return i.expiry
+}
+
+func (i *fakeInstaller) Contents() *models.ContentManifest {
+	return i.contents
+}
+
 func TestRun(t *testing.T) {
 	tests := []struct {
 		desc          string
 		cmd           *writeCmd
-		isElevatedCmd func() (bool, error)
+		capabilityCmd func(config.Capability) error
 		searchCmd     func(string, uint64, uint64, bool) ([]installer.Device, error)
-		newInstCmd    func(config installer.Configuration) (imageInstaller, error)
+		newInstCmd    func(config installer.Configuration) (ImageInstaller, error)
+		permsErr      error    // Returned when checking removable media write policy.
+		readyErr      error    // Returned when waiting for a device to be ready.
+		loadErr       error    // Returned when loading distributions.
 		args          []string // Commandline arguments to be passed
 		want          error
 	}{
+		{
+			desc:    "config file error",
+			cmd:     &writeCmd{distro: "windows"},
+			args:    []string{"--config=distros.yaml"},
+			loadErr: errors.New("error"),
+			want:    errConfig,
+		},
+		{
+			desc:     "write policy",
+			cmd:      &writeCmd{distro: "windows"},
+			permsErr: config.ErrWritePerms,
+			want:     config.ErrUSBwriteAccess,
+		},
 		{
 			desc:          "config.New error",
 			cmd:           &writeCmd{},
-			isElevatedCmd: func() (bool, error) { return false, nil },
+			capabilityCmd: func(config.Capability) error { return errors.New("error") },
+			want:          errConfig,
+		},
+		{
+			desc:          "unknown environment",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--env=staging"},
+			want:          errConfig,
+		},
+		{
+			desc:          "environment with seed server",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--env=dev", "--seed_server=seed.foo.com"},
+			want:          errConfig,
+		},
+		{
+			desc:          "invalid batch",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--batch=NYC onboarding"},
+			want:          errConfig,
+		},
+		{
+			desc:          "impersonated user without service account",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--impersonate_user=operator@example.com"},
+			want:          errConfig,
+		},
+		{
+			desc:          "cache limits without cache directory",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--cache_max_size=50G"},
+			want:          errConfig,
+		},
+		{
+			desc:          "container with all devices",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--container", "--all"},
+			want:          errConfig,
+		},
+		{
+			desc:          "container without device node",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--container", "/dev/fresnel0"},
+			want:          errDevice,
+		},
+		{
+			desc:          "json with warning",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--json"},
+			want:          errConfig,
+		},
+		{
+			desc:          "json with beep",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--json", "--warning=false", "--beep"},
+			want:          errConfig,
+		},
+		{
+			desc:          "persistence with verify",
+			cmd:           &writeCmd{distro: "linux"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--persistence", "--verify"},
+			want:          errConfig,
+		},
+		{
+			desc:          "missing answer file",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--unattend", "missing/autounattend.xml"},
+			want:          errConfig,
+		},
+		{
+			desc:          "invalid inventory format",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			args:          []string{"--inventory=inventory.txt", "--inventory_format=table"},
 			want:          errConfig,
 		},
 		{
 			desc:          "elevation error",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return false, nil },
+			capabilityCmd: func(config.Capability) error { return errors.New("error") },
 			want:          errElevation,
 		},
 		{
 			desc:          "search failure",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd:     func(string, uint64, uint64, bool) ([]installer.Device, error) { return nil, errors.New("error") },
 			want:          errSearch,
 		},
 		{
 			desc:          "unsuitable device",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd:     func(string, uint64, uint64, bool) ([]installer.Device, error) { return nil, nil },
 			args:          []string{"4"},
 			want:          errDevice,
@@ -294,35 +452,49 @@ func TestRun(t *testing.T) {
 		{
 			desc:          "new.Installer error",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) { return nil, errors.New("") },
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) { return nil, errors.New("") },
 			args:       []string{"--warning=false", "1"},
 			want:       errInstaller,
 		},
 		{
 			desc:          "retrieve error",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{retErr: errors.New("error")}, nil
 			},
 			args: []string{"--warning=false", "1"},
 			want: errRetrieve,
 		},
+		{
+			desc:          "device not ready",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
+				return []installer.Device{&fakeDevice{id: "1"}}, nil
+			},
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
+				return &fakeInstaller{}, nil
+			},
+			readyErr: errors.New("error"),
+			args:     []string{"--warning=false", "1"},
+			want:     errPrepare,
+		},
 		{
 			desc:          "prepare error",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{prepErr: errors.New("error")}, nil
 			},
 			args: []string{"--warning=false", "1"},
@@ -331,75 +503,113 @@ func TestRun(t *testing.T) {
 		{
 			desc:          "provision error",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{provErr: errors.New("error")}, nil
 			},
 			args: []string{"--warning=false", "1"},
 			want: errProvision,
 		},
 		{
-			desc:          "finalize error",
+			desc:          "finalize error is a warning",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{finErr: errors.New("error")}, nil
 			},
 			args: []string{"--warning=false", "1"},
-			want: errFinalize,
+			want: nil,
 		},
 		{
-			desc:          "finalize error",
+			desc:          "finalize error with fail_on_warnings",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{finErr: errors.New("error")}, nil
 			},
-			args: []string{"--warning=false", "1"},
+			args: []string{"--warning=false", "--fail_on_warnings", "1"},
 			want: errFinalize,
 		},
 		{
 			desc:          "success",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{}, nil
 			},
 			args: []string{"--warning=false", "1"},
 			want: nil,
 		},
+		{
+			desc:          "trace write error",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
+				return []installer.Device{&fakeDevice{id: "1"}}, nil
+			},
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
+				return &fakeInstaller{}, nil
+			},
+			args: []string{"--warning=false", "--trace=" + filepath.Join(t.TempDir(), "missing", "trace.json"), "1"},
+			want: trace.ErrTrace,
+		},
+		{
+			desc:          "trace",
+			cmd:           &writeCmd{distro: "windows"},
+			capabilityCmd: func(config.Capability) error { return nil },
+			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
+				return []installer.Device{&fakeDevice{id: "1"}}, nil
+			},
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
+				return &fakeInstaller{}, nil
+			},
+			args: []string{"--warning=false", "--trace=" + filepath.Join(t.TempDir(), "trace.json"), "1"},
+			want: nil,
+		},
 		{
 			desc:          "--all flag provided",
 			cmd:           &writeCmd{distro: "windows"},
-			isElevatedCmd: func() (bool, error) { return true, nil },
+			capabilityCmd: func(config.Capability) error { return nil },
 			searchCmd: func(string, uint64, uint64, bool) ([]installer.Device, error) {
 				return []installer.Device{&fakeDevice{id: "1"}, &fakeDevice{id: "2"}}, nil
 			},
-			newInstCmd: func(config installer.Configuration) (imageInstaller, error) {
+			newInstCmd: func(config installer.Configuration) (ImageInstaller, error) {
 				return &fakeInstaller{}, nil
 			},
 			args: []string{"--warning=false", "--all"},
 			want: nil,
 		},
 	}
+	defer func() {
+		loadDistributions = config.LoadDistributions
+		checkWritable = installer.CheckWritable
+		serialNumber = installer.SerialNumber
+	}()
+	checkWritable = func(string) error { return nil }
+	serialNumber = func(string) string { return "" }
 	for _, tt := range tests {
 		// Perform substitutions, generate the flagSet and set Flags.
-		config.IsElevatedCmd = tt.isElevatedCmd
-		funcUSBPermissions = func() error { return nil }
+		config.CapabilityCmd = tt.capabilityCmd
+		permsErr := tt.permsErr
+		funcUSBPermissions = func() error { return permsErr }
+		loadErr := tt.loadErr
+		loadDistributions = func(string) error { return loadErr }
 		search = tt.searchCmd
 		newInstaller = tt.newInstCmd
+		readyErr := tt.readyErr
+		waitReady = func(installer.Detector, time.Duration) error { return readyErr }
 
 		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
 		write := tt.cmd
@@ -415,3 +625,54 @@ func TestRun(t *testing.T) {
 		}
 	}
 }
+
+func TestOrchestratorBootTest(t *testing.T) {
+	defer func() { bootTest = bootTestDevice }()
+	var got time.Duration
+	bootTest = func(_ installer.Device, d time.Duration) error {
+		got = d
+		return nil
+	}
+	if o := (&writeCmd{}).orchestrator(); o.BootTest != nil {
+		t.Errorf("orchestrator() without --boot_test set BootTest")
+	}
+	o := (&writeCmd{bootTest: true, bootTestTimeout: time.Minute}).orchestrator()
+	if o.BootTest == nil {
+		t.Fatalf("orchestrator() with --boot_test did not set BootTest")
+	}
+	if err := o.BootTest(&fakeDevice{id: "1"}); err != nil {
+		t.Errorf("BootTest() returned %v", err)
+	}
+	if got != time.Minute {
+		t.Errorf("BootTest() used a timeout of %v, want: %v", got, time.Minute)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want subcommands.ExitStatus
+	}{
+		{desc: "config", err: fmt.Errorf("%w: bad flag", errConfig), want: exitConfig},
+		{desc: "elevation", err: fmt.Errorf("%w: not root", errElevation), want: exitPermission},
+		{desc: "policy", err: fmt.Errorf("%w: denied", config.ErrUSBwriteAccess), want: exitPermission},
+		{desc: "search", err: fmt.Errorf("%w: lsblk failed", errSearch), want: exitDevice},
+		{desc: "device", err: fmt.Errorf("%w: not found", errDevice), want: exitDevice},
+		{desc: "installer", err: fmt.Errorf("%w: connect failed", errInstaller), want: exitDownload},
+		{desc: "retrieve", err: fmt.Errorf("%w: download failed", errRetrieve), want: exitDownload},
+		{desc: "prepare", err: fmt.Errorf("%w: wipe failed", errPrepare), want: exitPrepare},
+		{desc: "provision", err: fmt.Errorf("%w: copy failed", errProvision), want: exitProvision},
+		{desc: "verify", err: fmt.Errorf("%w: mismatch", errVerify), want: exitVerify},
+		{desc: "boot test", err: fmt.Errorf("%w: unbootable", errBootTest), want: exitVerify},
+		{desc: "finalize", err: fmt.Errorf("%w: eject failed", errFinalize), want: exitFinalize},
+		{desc: "inventory", err: fmt.Errorf("%w: disk full", errInventory), want: exitReport},
+		{desc: "provision before inventory", err: appendError(fmt.Errorf("%w: copy failed", errProvision), errInventory), want: exitProvision},
+		{desc: "uncategorized", err: errors.New("error"), want: subcommands.ExitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) got: %d, want: %d", tt.desc, tt.err, got, tt.want)
+		}
+	}
+}
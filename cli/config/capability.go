@@ -0,0 +1,73 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Capability is a privileged operation that may be required to provision a
+// device. Capabilities are probed when they are needed rather than once at
+// startup, as the operations they guard differ by platform and image type.
+type Capability string
+
+const (
+	// WriteDevice is the ability to open a device for writing, such as when
+	// wiping it or writing a raw image to it.
+	WriteDevice Capability = "write to devices"
+	// Mount is the ability to partition, format and mount a device.
+	Mount Capability = "partition and mount devices"
+)
+
+var (
+	// CapabilityCmd injects the command used to probe a capability. It is
+	// implemented separately for each platform.
+	CapabilityCmd = probeCapability
+
+	// ErrCapability indicates that the user lacks a capability that is required
+	// for an operation.
+	ErrCapability = errors.New("missing capability")
+)
+
+// Can returns nil if the user has the capability, or an error wrapping
+// ErrCapability that explains how to obtain it on the current platform.
+func (c *Configuration) Can(capability Capability) error {
+	if err := CapabilityCmd(capability); err != nil {
+		return fmt.Errorf("%w: unable to %s: %v", ErrCapability, capability, err)
+	}
+	return nil
+}
+
+// CanWriteDevice returns nil if the user can open devices for writing.
+func (c *Configuration) CanWriteDevice() error {
+	return c.Can(WriteDevice)
+}
+
+// CanMount returns nil if the user can partition, format and mount devices.
+func (c *Configuration) CanMount() error {
+	return c.Can(Mount)
+}
+
+// Capabilities returns the capabilities the user currently has.
+func (c *Configuration) Capabilities() []Capability {
+	granted := []Capability{}
+	for _, capability := range []Capability{WriteDevice, Mount} {
+		if CapabilityCmd(capability) == nil {
+			granted = append(granted, capability)
+		}
+	}
+	return granted
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		desc     string
+		probe    func(Capability) error
+		want     error
+		wantCaps int
+	}{
+		{
+			desc:     "all granted",
+			probe:    func(Capability) error { return nil },
+			want:     nil,
+			wantCaps: 2,
+		},
+		{
+			desc: "mount missing",
+			probe: func(c Capability) error {
+				if c == Mount {
+					return errors.New("error")
+				}
+				return nil
+			},
+			want:     ErrCapability,
+			wantCaps: 1,
+		},
+	}
+	for _, tt := range tests {
+		CapabilityCmd = tt.probe
+		c := &Configuration{}
+		if err := c.CanWriteDevice(); err != nil {
+			t.Errorf("%s: CanWriteDevice() returned %v", tt.desc, err)
+		}
+		if got := c.CanMount(); !errors.Is(got, tt.want) {
+			t.Errorf("%s: CanMount() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got := c.Capabilities(); len(got) != tt.wantCaps {
+			t.Errorf("%s: Capabilities() got: %v, want %d capabilities", tt.desc, got, tt.wantCaps)
+		}
+	}
+	CapabilityCmd = probeCapability
+}
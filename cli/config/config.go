@@ -18,10 +18,20 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"os/user"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/models"
 )
 
 var (
@@ -29,12 +39,11 @@ var (
 	currentUser = user.Current
 
 	// Wrapped errors for testing.
-	errDistro    = errors.New(`distribution selection error`)
-	errDevice    = errors.New(`device error`)
-	errElevation = errors.New(`elevation detection error: attempting to re-run with admin privileges`)
-	errInput     = errors.New("invalid or missing input")
-	errSeed      = errors.New("seed error")
-	errTrack     = errors.New("track error")
+	errDistro = errors.New(`distribution selection error`)
+	errDevice = errors.New(`device error`)
+	errInput  = errors.New("invalid or missing input")
+	errSeed   = errors.New("seed error")
+	errTrack  = errors.New("track error")
 
 	// Exported errors
 
@@ -45,7 +54,13 @@ var (
 	regExDevicePath = regexp.MustCompile(`^[a-zA-Z0-9/]`)
 	regExDeviceID   = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
 	regExFQDN       = regexp.MustCompile(`^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.){2,}([A-Za-z0-9/]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9]){2,}$`)
-	regExFileName   = regexp.MustCompile(`[\w,\s-]+\.[A-Za-z.]+`)
+	regExBatch      = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}$`)
+	regExEmail      = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	regExSeedField  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._-]{0,63}$`)
+
+	// serverSchemes are the URL schemes of the servers that images and
+	// configurations can be obtained from.
+	serverSchemes = map[string]bool{"https": true, "http": true, "gs": true, "s3": true, "file": true}
 )
 
 // OperatingSystem is used to indicate the OS of the media to be generated.
@@ -62,16 +77,95 @@ const (
 // required to obtain the resources required to install it.
 type distribution struct {
 	os          OperatingSystem
-	confFile    string // The final name of the config file.
-	confServer  string // The FFU configs are obtained here.
-	imageServer string // The base image is obtained here.
-	label       string // If set, is used to set partition labels.
-	name        string // Friendly name: e.g. Corp Windows.
-	seedDest    string // The relative path where the seed should be written.
-	seedFile    string // This file is hashed when obtainng a seed.
-	seedServer  string // If set, a seed is obtained from here.
+	apply       bool          // If set, the image is applied as a bootable Windows disk. Experimental.
+	applyIndex  int           // The index of the image to apply from a WIM. Defaults to 1.
+	checksums   bool          // If set, each image is published with a companion .sha256 file that it must match.
+	confFile    string        // The final name of the config file.
+	confServer  string        // The FFU configs are obtained here.
+	digests     string        // If set, the image manifest listing required digests is obtained here, relative to imageServer.
+	imageServer string        // The base image is obtained here.
+	mirrors     []string      // Alternate image servers with the same layout as imageServer, tried in order when it fails.
+	probe       bool          // If set, the image server that responds fastest to a HEAD request is tried first.
+	stripDomain bool          // If set, the domain of Windows accounts is removed from the username used for seeds.
+	label       string        // If set, is used to set partition labels.
+	name        string        // Friendly name: e.g. Corp Windows.
+	seedDest    string        // The relative path where the seed should be written.
+	seedFile    string        // This file is hashed when obtainng a seed.
+	seedHash    string        // The algorithm used to hash seedFile, such as sha512. Defaults to sha256.
+	seedServer  string        // If set, a seed is obtained from here.
+	shelfLife   time.Duration // Expected time between provisioning and first use.
+	signServer  string        // If set, signed URLs for the manifest are obtained here.
 	images      map[string]string
 	configs     map[string]string // Contains config file names.
+	seedTracks  map[string]bool   // Whether each listed track requires a seed. Unlisted tracks require one when seedServer is set.
+	seedFields  map[string]string // Additional values sent with seed requests, such as a cost center.
+	manifest    []string          // Bucket paths to be signed and written alongside the seed.
+	seedFiles   []string          // Further files hashed to obtain seeds, which are bundled with that of seedFile.
+	partitions  []PartitionRule   // Places files from ISO images on partitions other than the boot partition.
+	devices     DevicePolicy      // Constrains the devices that the distribution can be provisioned on.
+
+	// signedImages, if set, is the path in the bucket of signServer that
+	// images are stored under. Images are then downloaded through signed URLs
+	// obtained from signServer, rather than from imageServer.
+	signedImages string
+
+	// dataFileSystem is the file system of the data partition that devices
+	// are given when partition rules place files on it, NTFS or exFAT.
+	// Defaults to NTFS.
+	dataFileSystem string
+}
+
+const (
+	// BootPartition is the role of the FAT32 partition that ISO images are
+	// written to, and which devices boot from.
+	BootPartition = "boot"
+	// DataPartition is the role of an NTFS partition on the same device,
+	// typically used for files that do not fit on FAT32, such as drivers or
+	// large WIM files.
+	DataPartition = "data"
+)
+
+// PartitionRule places the files of an ISO image that match Glob on the
+// partition with Role. Glob uses path.Match syntax with forward slashes and is
+// compared without regard to case. A rule also matches every file beneath a
+// matching directory. Files that match no rule are written to the boot
+// partition.
+type PartitionRule struct {
+	Glob string
+	Role string
+}
+
+// DevicePolicy constrains the devices that a distribution can be provisioned
+// on, regardless of the flags that are used. Zero values impose no constraint.
+// FileSystem is the file system of the partition that is reused when devices
+// are updated rather than provisioned, and defaults to FAT32.
+type DevicePolicy struct {
+	MinSize       units.Size
+	MaxSize       units.Size
+	RemovableOnly bool
+	FileSystem    string
+}
+
+// String describes the constraints of the policy, such as
+// 'at least 8G, removable only'.
+func (p DevicePolicy) String() string {
+	var c []string
+	if p.MinSize > 0 {
+		c = append(c, "at least "+p.MinSize.String())
+	}
+	if p.MaxSize > 0 {
+		c = append(c, "at most "+p.MaxSize.String())
+	}
+	if p.RemovableOnly {
+		c = append(c, "removable only")
+	}
+	if p.FileSystem != "" {
+		c = append(c, p.FileSystem+" for updates")
+	}
+	if len(c) == 0 {
+		return "any device"
+	}
+	return strings.Join(c, ", ")
 }
 
 // Configuration represents the state of all flags and selections provided
@@ -83,23 +177,59 @@ type Configuration struct {
 	dismount  bool
 	ffu       bool
 	update    bool
+	sparse    bool // Skip writing zero-filled regions of raw images.
+	trim      bool // Discard the contents of devices before raw writes.
+	rollback  bool // Provision the previous known-good image for the track.
+	preserve  bool // Keep the modification times and attributes of copied files.
 	eject     bool
-	elevated  bool // If the user is running as root.
 	track     string
 	confTrack string
 	warning   bool
+
+	batch        string        // The operator-defined batch that media is provisioned in.
+	cacheDir     string        // A directory of retained files to reuse, rather than a temporary cache.
+	cacheMaxAge  time.Duration // How long an unused entry is kept in the cache directory.
+	cacheMaxSize units.Size    // The size beyond which entries are evicted from the cache directory.
+	contents     bool          // Whether the manifest of the files written is also stored on the media.
+	logOnMedia   bool          // Whether a redacted copy of the log and report of the run is stored on the media.
+
+	impersonate string // A service account impersonated to authenticate to the seed and sign servers.
+	delegate    string // A user that the impersonated service account acts as by domain-wide delegation.
+
+	proxy string // The proxy that requests are sent through, rather than those of the environment.
+
+	seedFields map[string]string // Values sent with seed requests, overriding those of the distribution.
+
+	scanner []string // A command that scans files before they are written to media, and its arguments.
+
+	persistence      bool       // Whether a persistence partition is added after raw images.
+	persistenceSize  units.Size // The size of the persistence partition, 0 for the remainder of the device.
+	persistenceLabel string     // The label of the persistence partition.
+
+	unattend     string // An answer file copied to media provisioned from ISO images.
+	unattendDest string // The name that the answer file is given at the root of the media.
+}
+
+// environment defines the servers used by a deployment of the backend.
+type environment struct {
+	seedServer string
+	signServer string
 }
 
 // New generates a new configuration from flags passed on the command line.
 // It performs sanity checks on those parameters.
-func New(cleanup, warning, eject, ffu, update bool, devices []string, os, track, confTrack, seedServer string) (*Configuration, error) {
+func New(cleanup, warning, eject, ffu, update, sparse, trim, rollback, preserve bool, devices []string, os, track, confTrack, seedServer string) (*Configuration, error) {
 	// Create a partial config using known good values.
 	conf := &Configuration{
-		cleanup: cleanup,
-		warning: warning,
-		ffu:     ffu,
-		eject:   eject,
-		update:  update,
+		cleanup:  cleanup,
+		warning:  warning,
+		ffu:      ffu,
+		eject:    eject,
+		update:   update,
+		sparse:   sparse,
+		trim:     trim,
+		rollback: rollback,
+		preserve: preserve,
 	}
 	if len(devices) > 0 {
 		if err := conf.addDeviceList(devices); err != nil {
@@ -120,17 +250,14 @@ func New(cleanup, warning, eject, ffu, update bool, devices []string, os, track,
 			return nil, err
 		}
 	}
+	// Previous images are only known from the image manifest.
+	if rollback && conf.distro.digests == "" {
+		return nil, fmt.Errorf("%w: rollback requires an image manifest, which is not configured for %q", errInput, os)
+	}
 	// Sanity check the seed server and override if instructed to do so by flag.
 	if err := conf.addSeedServer(seedServer); err != nil {
 		return nil, err
 	}
-	// Determine if the user is running with elevated permissions.
-	elevated, err := isElevated()
-	if err != nil {
-		return nil, fmt.Errorf("%w: isElevated() returned %v", errElevation, err)
-	}
-	conf.elevated = elevated
-
 	return conf, nil
 }
 
@@ -143,6 +270,17 @@ func (c *Configuration) addDistro(choice string) error {
 		}
 		return fmt.Errorf("%w: image %q is not in %v", errDistro, choice, opts)
 	}
+	if err := validateDistro(distro); err != nil {
+		return err
+	}
+	// The chosen distro is known, set it and return successfully.
+	c.distro = &distro
+	return nil
+}
+
+// validateDistro returns an error if the options of a distribution conflict
+// with each other or are not understood.
+func validateDistro(distro distribution) error {
 	// If a seed server is configured, it must be accompanied by a seedFile.
 	if distro.seedServer != "" && distro.seedFile == "" {
 		return fmt.Errorf("%w: seedServer(%q) specified without a seedFile(%q)", errInput, distro.seedServer, distro.seedFile)
@@ -153,9 +291,123 @@ func (c *Configuration) addDistro(choice string) error {
 	if distro.seedFile != "" && distro.seedDest == "" {
 		return fmt.Errorf("%w: seedFile(%q) specified without a destination(%q)", errSeed, distro.seedFile, distro.seedDest)
 	}
+	// Further seed files are bundled with the seed of seedFile, and each is
+	// requested separately, so they must be distinct.
+	if len(distro.seedFiles) > 0 && distro.seedFile == "" {
+		return fmt.Errorf("%w: seedFiles(%v) specified without a seedFile", errSeed, distro.seedFiles)
+	}
+	seen := map[string]bool{distro.seedFile: true}
+	for _, f := range distro.seedFiles {
+		if f == "" || seen[f] {
+			return fmt.Errorf("%w: seed file %q is empty or listed more than once", errSeed, f)
+		}
+		seen[f] = true
+	}
+	// A manifest is signed using the seed, so both a seed and a sign server
+	// are required in order to generate one.
+	if len(distro.manifest) > 0 && (distro.seedServer == "" || distro.signServer == "") {
+		return fmt.Errorf("%w: manifest(%v) requires both a seedServer(%q) and a signServer(%q)", errInput, distro.manifest, distro.seedServer, distro.signServer)
+	}
+	// Signed URLs for images are requested with a seed for the digest that
+	// the image manifest lists, so the manifest is required as well.
+	if distro.signedImages != "" && (distro.seedServer == "" || distro.signServer == "" || distro.digests == "") {
+		return fmt.Errorf("%w: signedImages(%q) requires a seedServer(%q), a signServer(%q) and digests(%q)", errInput, distro.signedImages, distro.seedServer, distro.signServer, distro.digests)
+	}
 
-	// The chosen distro is known, set it and return successfully.
-	c.distro = &distro
+	// Applying an image produces a bootable Windows disk, which is only
+	// supported for Windows distributions.
+	if distro.apply && distro.os != windows {
+		return fmt.Errorf("%w: apply is only supported for %q distributions, got %q", errInput, windows, distro.os)
+	}
+	if distro.applyIndex < 0 {
+		return fmt.Errorf("%w: applyIndex(%d) must not be negative", errInput, distro.applyIndex)
+	}
+	for _, r := range distro.partitions {
+		if r.Role != BootPartition && r.Role != DataPartition {
+			return fmt.Errorf("%w: partition rule %q has unknown role %q", errInput, r.Glob, r.Role)
+		}
+		if _, err := path.Match(r.Glob, ""); err != nil {
+			return fmt.Errorf("%w: partition rule %q is not a valid pattern: %v", errInput, r.Glob, err)
+		}
+	}
+
+	// Seed requirements can only be set for tracks that have an image.
+	for track := range distro.seedTracks {
+		if _, ok := distro.images[track]; !ok {
+			return fmt.Errorf("%w: seed requirement set for unknown track %q", errInput, track)
+		}
+	}
+
+	for _, m := range distro.mirrors {
+		if m == "" || m == distro.imageServer {
+			return fmt.Errorf("%w: mirror %q must be a server other than the imageServer(%q)", errInput, m, distro.imageServer)
+		}
+		if err := validateServer(m); err != nil {
+			return err
+		}
+	}
+	for _, s := range []string{distro.imageServer, distro.confServer} {
+		if err := validateServer(s); err != nil {
+			return err
+		}
+	}
+	for track, image := range distro.images {
+		if !isImageURL(image) {
+			if distro.imageServer == "" {
+				return fmt.Errorf("%w: image %q for track %q is relative, but there is no imageServer", errInput, imagefile.Redact(image), track)
+			}
+			continue
+		}
+		if err := validateServer(imagefile.Redact(image)); err != nil {
+			return err
+		}
+		// Companion checksums are found by appending to the path of the
+		// image, which would invalidate the signature of a signed URL.
+		if distro.checksums && imagefile.Signed(image) {
+			return fmt.Errorf("%w: checksums cannot be obtained for the signed URL of track %q, use digests instead", errInput, track)
+		}
+	}
+	if distro.digests != "" && distro.imageServer == "" {
+		return fmt.Errorf("%w: digests(%q) are relative to the imageServer, which is not set", errInput, distro.digests)
+	}
+	if distro.probe && len(distro.mirrors) == 0 {
+		return fmt.Errorf("%w: probeMirrors requires at least one mirror", errInput)
+	}
+	if err := validateSeedFields(distro.seedFields); err != nil {
+		return err
+	}
+	if p := distro.devices; p.MaxSize > 0 && p.MinSize > p.MaxSize {
+		return fmt.Errorf("%w: device policy minimum size %v exceeds the maximum %v", errInput, p.MinSize, p.MaxSize)
+	}
+	switch distro.dataFileSystem {
+	case "", "NTFS", "exFAT":
+	default:
+		return fmt.Errorf("%w: data file system %q is not NTFS or exFAT", errInput, distro.dataFileSystem)
+	}
+	switch distro.devices.FileSystem {
+	case "", "FAT32", "NTFS":
+	default:
+		return fmt.Errorf("%w: device policy file system %q is not FAT32 or NTFS", errInput, distro.devices.FileSystem)
+	}
+	return nil
+}
+
+// validateServer checks that a server that images or configurations are
+// obtained from, if set, uses a scheme that they can be retrieved with.
+func validateServer(server string) error {
+	if server == "" {
+		return nil
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("%w: server %q is not a valid URL: %v", errInput, server, err)
+	}
+	if !serverSchemes[u.Scheme] {
+		return fmt.Errorf("%w: server %q must begin with one of https://, http://, gs://, s3:// or file://", errInput, server)
+	}
+	if (u.Scheme == "gs" || u.Scheme == "s3") && u.Host == "" {
+		return fmt.Errorf("%w: server %q does not name a bucket", errInput, server)
+	}
 	return nil
 }
 
@@ -166,14 +418,31 @@ func (c *Configuration) addDeviceList(devices []string) error {
 		return fmt.Errorf("%w: no devices were specified", errInput)
 	}
 	// Check that the device IDs appear valid. Throw an error if a partition
-	// or drive letter was specified.
+	// or drive letter was specified. Device nodes, such as those passed to a
+	// container, are accepted in place of their IDs.
+	ids := make([]string, 0, len(devices))
 	for _, d := range devices {
+		d = strings.TrimPrefix(d, "/dev/")
 		if !regExDeviceID.Match([]byte(d)) {
-			return fmt.Errorf("%w: device(%q) must be a device ID (sda[#]), number(1-9) or disk identifier(disk[#])", errDevice, d)
+			return fmt.Errorf("%w: device(%q) must be a device ID (sda[#]), device node (/dev/sda), number(1-9) or disk identifier(disk[#])", errDevice, d)
 		}
+		ids = append(ids, d)
 	}
 	// Set devices in config.
-	c.devices = devices
+	c.devices = ids
+	return nil
+}
+
+// CheckWholeDisks returns an error if any of the devices to be provisioned is
+// not available as a device node, or is a partition rather than a whole disk.
+// It guards against device nodes that were passed to a container by mistake,
+// as devices are otherwise only checked against those that a search finds.
+func (c *Configuration) CheckWholeDisks() error {
+	for _, d := range c.devices {
+		if err := checkWholeDisk(d); err != nil {
+			return fmt.Errorf("%w: %v", errDevice, err)
+		}
+	}
 	return nil
 }
 
@@ -196,6 +465,274 @@ func (c *Configuration) addSeedServer(fqdn string) error {
 	return nil
 }
 
+// UseEnvironment points the seed and sign servers of the chosen distribution
+// at the named environment. An empty name leaves the distribution defaults
+// in place.
+func (c *Configuration) UseEnvironment(name string) error {
+	if name == "" {
+		return nil
+	}
+	env, ok := environments[name]
+	if !ok {
+		var opts []string
+		for o := range environments {
+			opts = append(opts, o)
+		}
+		sort.Strings(opts)
+		return fmt.Errorf("%w: environment %q is not in %v", errInput, name, opts)
+	}
+	if c.distro.seedServer != "" && env.seedServer != "" {
+		c.distro.seedServer = env.seedServer
+	}
+	if c.distro.signServer != "" && env.signServer != "" {
+		c.distro.signServer = env.signServer
+	}
+	return nil
+}
+
+// UseBatch names the batch that media is provisioned in, so that logs, the
+// seed server and the media itself can be tagged with it. Names are limited
+// to 64 letters, digits, dots, dashes and underscores. An empty name clears
+// the batch.
+func (c *Configuration) UseBatch(name string) error {
+	if name != "" && !regExBatch.MatchString(name) {
+		return fmt.Errorf("%w: batch %q must be 1-64 letters, digits, '.', '-' or '_', starting with a letter or digit", errInput, name)
+	}
+	c.batch = name
+	return nil
+}
+
+// UseSeedFields adds values to seed requests, such as a cost center or site
+// code required by the seed server, from pairs of the form 'key=value'. They
+// override the values of the same name set by the distribution. An empty list
+// leaves only those of the distribution.
+func (c *Configuration) UseSeedFields(pairs []string) error {
+	fields := make(map[string]string)
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("%w: seed field %q must be of the form key=value", errInput, p)
+		}
+		fields[k] = v
+	}
+	if err := validateSeedFields(fields); err != nil {
+		return err
+	}
+	c.seedFields = fields
+	return nil
+}
+
+// ScanPath is replaced by the path of the file being scanned in the arguments
+// of a scanner command. The path is appended to the arguments when none of
+// them contain it.
+const ScanPath = "{path}"
+
+// UseScanner scans each file before it is written to media with command, such
+// as 'clamscan --no-summary {path}'. Arguments containing spaces are enclosed
+// in double quotes. Files are rejected when the command exits with a status
+// other than zero. An empty command disables scanning.
+func (c *Configuration) UseScanner(command string) error {
+	args, err := splitCommand(command)
+	if err != nil {
+		return fmt.Errorf("%w: scanner command %q: %v", errInput, command, err)
+	}
+	c.scanner = args
+	return nil
+}
+
+// splitCommand splits a command line into its arguments at spaces outside of
+// double quotes, removing the quotes.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	quoted, started := false, false
+	for _, r := range command {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// validateSeedFields checks that fields are within the limits that the seed
+// server accepts, so that requests are not rejected after devices are wiped.
+func validateSeedFields(fields map[string]string) error {
+	if len(fields) > models.MaxSeedFields {
+		return fmt.Errorf("%w: %d seed fields set, at most %d are accepted", errInput, len(fields), models.MaxSeedFields)
+	}
+	for k, v := range fields {
+		if !regExSeedField.MatchString(k) {
+			return fmt.Errorf("%w: seed field name %q must be 1-%d letters, digits, '.', '-' or '_', starting with a letter", errInput, k, models.MaxSeedFieldKey)
+		}
+		if len(v) > models.MaxSeedFieldValue {
+			return fmt.Errorf("%w: seed field %q is longer than %d characters", errInput, k, models.MaxSeedFieldValue)
+		}
+	}
+	return nil
+}
+
+// UseImpersonation authenticates to the seed and sign servers as the service
+// account, rather than as the signed-in user, so that seeds can be obtained
+// without interaction. If user is provided, the service account acts as that
+// user through domain-wide delegation, and seeds are issued to the user
+// rather than the service account. Empty values restore interactive
+// authentication.
+func (c *Configuration) UseImpersonation(account, user string) error {
+	if account == "" && user != "" {
+		return fmt.Errorf("%w: user %q can only be impersonated through a service account", errInput, user)
+	}
+	for _, e := range []string{account, user} {
+		if e != "" && !regExEmail.MatchString(e) {
+			return fmt.Errorf("%w: %q is not an email address", errInput, e)
+		}
+	}
+	c.impersonate = account
+	c.delegate = user
+	return nil
+}
+
+// UseCacheDir points the installer at a directory of files retained by an
+// earlier run with cleanup disabled, so that they are reused rather than
+// downloaded again. The directory is created if it does not exist, and is
+// never removed. An empty dir leaves a temporary cache in use.
+func (c *Configuration) UseCacheDir(dir string) error {
+	if dir == "" {
+		c.cacheDir = ""
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("%w: cache directory %q: %v", errInput, dir, err)
+	}
+	c.cacheDir = abs
+	return nil
+}
+
+// UseCacheLimits limits the entries kept in the cache directory. Entries that
+// have not been used for longer than maxAge are evicted, as are the least
+// recently used entries once the cache exceeds maxSize. Zero values do not
+// limit the cache. Limits require a cache directory, and UseCacheDir must be
+// called first.
+func (c *Configuration) UseCacheLimits(maxAge time.Duration, maxSize units.Size) error {
+	if maxAge < 0 {
+		return fmt.Errorf("%w: cache age %v is negative", errInput, maxAge)
+	}
+	if c.cacheDir == "" && (maxAge != 0 || maxSize != 0) {
+		return fmt.Errorf("%w: cache limits require a cache directory", errInput)
+	}
+	c.cacheMaxAge = maxAge
+	c.cacheMaxSize = maxSize
+	return nil
+}
+
+// StoreContentsOnMedia determines whether the manifest of the files written
+// from ISO images is stored on the media, in addition to the cache.
+func (c *Configuration) StoreContentsOnMedia(store bool) {
+	c.contents = store
+}
+
+// StoreLogOnMedia determines whether a redacted copy of the log and report of
+// the run is stored in a hidden directory on media provisioned from ISO
+// images, so that how it was created travels with it.
+func (c *Configuration) StoreLogOnMedia(store bool) {
+	c.logOnMedia = store
+}
+
+// DefaultPersistenceLabel is the label that live-boot, used by Debian live
+// images, looks for on persistence partitions.
+const DefaultPersistenceLabel = "persistence"
+
+// maxExt4Label is the longest label that an ext4 file system can have.
+const maxExt4Label = 16
+
+// UsePersistence adds an ext4 partition labeled label after raw images of
+// linux distributions are written, so that live images keep changes across
+// boots. The partition is size large, or fills the remainder of the device
+// when size is zero. An empty label uses DefaultPersistenceLabel, while
+// Ubuntu images look for 'casper-rw' or 'writable'.
+func (c *Configuration) UsePersistence(persist bool, size units.Size, label string) error {
+	if !persist {
+		if size != 0 || label != "" {
+			return fmt.Errorf("%w: a persistence size or label requires persistence", errInput)
+		}
+		return nil
+	}
+	if c.distro == nil || c.distro.os != linux {
+		return fmt.Errorf("%w: persistence is only supported for %q distributions", errInput, linux)
+	}
+	if label == "" {
+		label = DefaultPersistenceLabel
+	}
+	if len(label) > maxExt4Label {
+		return fmt.Errorf("%w: persistence label %q is longer than %d characters", errInput, label, maxExt4Label)
+	}
+	c.persistence = true
+	c.persistenceSize = size
+	c.persistenceLabel = label
+	return nil
+}
+
+// UseUnattend copies the answer file at path to the root of media provisioned
+// from ISO images as autounattend.xml, which Windows Setup looks for on
+// removable media, so that installs proceed without interaction. Linux
+// installers only read answer files that their boot parameters point to, so
+// only Windows distributions are supported. An empty path disables it.
+func (c *Configuration) UseUnattend(path string) error {
+	if path == "" {
+		c.unattend, c.unattendDest = "", ""
+		return nil
+	}
+	if c.distro == nil {
+		return fmt.Errorf("%w: an answer file requires a distribution", errInput)
+	}
+	dest, err := unattendDest(path, c.distro.os)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%w: answer file %q: %v", errInput, path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("%w: answer file %q: %v", errInput, path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: answer file %q is not a file", errInput, path)
+	}
+	c.unattend, c.unattendDest = abs, dest
+	return nil
+}
+
+// unattendDest returns the name that Windows Setup looks for the answer file
+// at path by. Answer files are only supported for Windows distributions.
+func unattendDest(path string, system OperatingSystem) (string, error) {
+	if system != windows {
+		return "", fmt.Errorf("%w: answer files are only supported for %q distributions, not %q", errInput, windows, system)
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".xml") {
+		return "", fmt.Errorf("%w: %q is not an autounattend.xml file", errInput, path)
+	}
+	return "autounattend.xml", nil
+}
+
 func validateTrack(track string, distro map[string]string) (string, error) {
 	// Check that a default is available in the distro.
 	if _, ok := distro["default"]; !ok {
@@ -243,14 +780,64 @@ func (c *Configuration) ConfTrack() string {
 }
 
 // ImagePath returns the full path to the raw image for this configuration.
+// Images that are configured as URLs, such as pre-signed URLs, are obtained
+// from that URL rather than from the image server.
 func (c *Configuration) ImagePath() string {
-	return fmt.Sprintf(`%s/%s`, c.distro.imageServer, c.distro.images[c.track])
+	image := c.distro.images[c.track]
+	if isImageURL(image) {
+		return image
+	}
+	return fmt.Sprintf(`%s/%s`, c.distro.imageServer, image)
 }
 
-// ImageFile returns the filename of the raw image for this configuration.
+// isImageURL reports whether image is a URL that the image is obtained from
+// directly, rather than a path relative to the image server. Its scheme is
+// checked by validateDistro.
+func isImageURL(image string) bool {
+	u, err := url.Parse(image)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// ImageMirrors returns the full path to the raw image for this configuration
+// on each of the mirrors of the image server, in the order they are tried
+// when the image server fails. Images configured as URLs have no mirrors.
+func (c *Configuration) ImageMirrors() []string {
+	if isImageURL(c.distro.images[c.track]) {
+		return nil
+	}
+	var paths []string
+	for _, m := range c.distro.mirrors {
+		paths = append(paths, fmt.Sprintf(`%s/%s`, m, c.distro.images[c.track]))
+	}
+	return paths
+}
+
+// ProbeMirrors returns whether the image server or mirror that responds
+// fastest is tried first, rather than the image server.
+func (c *Configuration) ProbeMirrors() bool {
+	return c.distro.probe
+}
+
+// DigestsPath returns the full path to the image manifest for the
+// distribution, which lists the digest of the image required for each track.
+// It is empty if the distribution does not publish one.
+func (c *Configuration) DigestsPath() string {
+	if c.distro.digests == "" {
+		return ""
+	}
+	return fmt.Sprintf(`%s/%s`, c.distro.imageServer, c.distro.digests)
+}
+
+// Checksums returns whether each image of the distribution is published with
+// a companion .sha256 file containing its digest.
+func (c *Configuration) Checksums() bool {
+	return c.distro.checksums
+}
+
+// ImageFile returns the filename of the raw image for this configuration,
+// without any query string of the image path.
 func (c *Configuration) ImageFile() string {
-	// Return the filename only.
-	return filepath.Base(c.distro.images[c.track])
+	return imagefile.Name(c.distro.images[c.track])
 }
 
 // Cleanup returns whether or not the cleanup of temp files was requested by
@@ -259,6 +846,60 @@ func (c *Configuration) Cleanup() bool {
 	return c.cleanup
 }
 
+// CacheDir returns the directory of retained files to reuse, or an empty
+// string when a temporary cache is used.
+func (c *Configuration) CacheDir() string {
+	return c.cacheDir
+}
+
+// CacheMaxAge returns how long an unused entry is kept in the cache
+// directory, or zero when entries are kept regardless of age.
+func (c *Configuration) CacheMaxAge() time.Duration {
+	return c.cacheMaxAge
+}
+
+// CacheMaxSize returns the size beyond which the least recently used entries
+// are evicted from the cache directory, or zero when its size is not limited.
+func (c *Configuration) CacheMaxSize() units.Size {
+	return c.cacheMaxSize
+}
+
+// ContentsOnMedia returns whether the manifest of the files written from ISO
+// images is stored on the media.
+func (c *Configuration) ContentsOnMedia() bool {
+	return c.contents
+}
+
+// LogOnMedia returns whether a redacted copy of the log and report of the run
+// is stored on the media.
+func (c *Configuration) LogOnMedia() bool {
+	return c.logOnMedia
+}
+
+// Unattend returns the path of the answer file copied to media provisioned
+// from ISO images, or an empty string when there is none.
+func (c *Configuration) Unattend() string {
+	return c.unattend
+}
+
+// UnattendDest returns the name that the answer file is given at the root of
+// the media.
+func (c *Configuration) UnattendDest() string {
+	return c.unattendDest
+}
+
+// Impersonate returns the service account impersonated to authenticate to the
+// seed and sign servers, or an empty string when the signed-in user is used.
+func (c *Configuration) Impersonate() string {
+	return c.impersonate
+}
+
+// Delegate returns the user that the impersonated service account acts as, or
+// an empty string when it acts as itself.
+func (c *Configuration) Delegate() string {
+	return c.delegate
+}
+
 // Devices returns the devices to be provisioned.
 func (c *Configuration) Devices() []string {
 	return c.devices
@@ -282,7 +923,7 @@ func (c *Configuration) ConfFile() string {
 // FFUConfFile returns the name of the config file.
 func (c *Configuration) FFUConfFile() string {
 	// Return the filename only.
-	return filepath.Base(c.distro.configs[c.confTrack])
+	return imagefile.Name(c.distro.configs[c.confTrack])
 }
 
 // FFUConfPath returns the path to the config.
@@ -301,6 +942,52 @@ func (c *Configuration) UpdateOnly() bool {
 	return c.update
 }
 
+// SparseWrite returns whether zero-filled regions of raw images should be
+// zeroed on the device rather than written.
+func (c *Configuration) SparseWrite() bool {
+	return c.sparse
+}
+
+// Trim returns whether the contents of a device should be discarded before a
+// raw image is written to it.
+func (c *Configuration) Trim() bool {
+	return c.trim
+}
+
+// Rollback returns whether the previous known-good image for the track should
+// be provisioned rather than the current one.
+func (c *Configuration) Rollback() bool {
+	return c.rollback
+}
+
+// Batch returns the operator-defined batch that media is provisioned in, or
+// an empty string when no batch was named.
+func (c *Configuration) Batch() string {
+	return c.batch
+}
+
+// PreserveAttributes returns whether the modification times and, on Windows,
+// the basic attributes of files are kept when they are copied to devices.
+func (c *Configuration) PreserveAttributes() bool {
+	return c.preserve
+}
+
+// Apply returns whether the image for the chosen distribution should be
+// applied directly to the device, making it a bootable Windows disk rather
+// than an installer.
+func (c *Configuration) Apply() bool {
+	return c.distro.apply
+}
+
+// ApplyIndex returns the index of the image to apply from a WIM. Index 1 is
+// used when none is configured.
+func (c *Configuration) ApplyIndex() int {
+	if c.distro.applyIndex == 0 {
+		return 1
+	}
+	return c.distro.applyIndex
+}
+
 // Warning returns whether or not a warning should be presented prior to
 // destructive operations.
 func (c *Configuration) Warning() bool {
@@ -312,21 +999,138 @@ func (c *Configuration) SeedServer() string {
 	return c.distro.seedServer
 }
 
+// SeedRequired returns whether a seed must be written for the selected track.
+// A seed is required for every track when a seed server is configured, unless
+// the distribution declares otherwise for the track, such as for lab tracks
+// that are never used to install production machines.
+func (c *Configuration) SeedRequired() bool {
+	if c.distro.seedServer == "" {
+		return false
+	}
+	if required, ok := c.distro.seedTracks[c.track]; ok {
+		return required
+	}
+	return true
+}
+
+// Persistence returns whether a persistence partition is added after raw
+// images are written.
+func (c *Configuration) Persistence() bool {
+	return c.persistence
+}
+
+// PersistenceSize returns the size of the persistence partition, which fills
+// the remainder of the device when it is zero.
+func (c *Configuration) PersistenceSize() units.Size {
+	return c.persistenceSize
+}
+
+// PersistenceLabel returns the label of the persistence partition.
+func (c *Configuration) PersistenceLabel() string {
+	return c.persistenceLabel
+}
+
+// Scanner returns the command that files are scanned with before they are
+// written to media, and its arguments. It is empty when files are not
+// scanned.
+func (c *Configuration) Scanner() []string {
+	return c.scanner
+}
+
+// SeedFields returns the additional values sent with seed requests: those of
+// the distribution, overridden by those set with UseSeedFields. It is nil when
+// there are none.
+func (c *Configuration) SeedFields() map[string]string {
+	if len(c.distro.seedFields) == 0 && len(c.seedFields) == 0 {
+		return nil
+	}
+	fields := make(map[string]string)
+	for k, v := range c.distro.seedFields {
+		fields[k] = v
+	}
+	for k, v := range c.seedFields {
+		fields[k] = v
+	}
+	return fields
+}
+
 // SeedFile returns the path to the file that is to be hashed when obtaining
 // a seed.
 func (c *Configuration) SeedFile() string {
 	return c.distro.seedFile
 }
 
+// SeedFiles returns the paths to every file that is hashed to obtain seeds,
+// starting with SeedFile. A seed is obtained for each of them, and those of
+// files after the first are bundled alongside the seed of SeedFile. It is
+// empty when seeds are not configured.
+func (c *Configuration) SeedFiles() []string {
+	if c.distro.seedFile == "" {
+		return nil
+	}
+	return append([]string{c.distro.seedFile}, c.distro.seedFiles...)
+}
+
+// SeedHash returns the hash algorithm that should be used first when hashing
+// SeedFile to obtain a seed. It is empty when not configured.
+func (c *Configuration) SeedHash() string {
+	return c.distro.seedHash
+}
+
 // SeedDest returns the relative path where a seed should be written.
 func (c *Configuration) SeedDest() string {
 	return c.distro.seedDest
 }
 
-// Elevated identifies if the user is running the binary with elevated
-// permissions.
-func (c *Configuration) Elevated() bool {
-	return c.elevated
+// SeedShelfLife returns the expected time between provisioning and first use
+// of media for the chosen distribution. It is zero when not configured.
+func (c *Configuration) SeedShelfLife() time.Duration {
+	return c.distro.shelfLife
+}
+
+// StripDomain returns whether the domain of Windows accounts should be removed
+// from the username used to request seeds, which is otherwise DOMAIN\user.
+func (c *Configuration) StripDomain() bool {
+	return c.distro.stripDomain
+}
+
+// SignServer returns the configured sign server for the chosen distribution.
+func (c *Configuration) SignServer() string {
+	return c.distro.signServer
+}
+
+// SignedImages returns the path in the bucket of the sign server that images
+// are stored under, or an empty string when images are obtained from the
+// image server directly.
+func (c *Configuration) SignedImages() string {
+	return c.distro.signedImages
+}
+
+// ManifestFiles returns the bucket paths for which signed URLs should be
+// written to the bootstrap manifest.
+func (c *Configuration) ManifestFiles() []string {
+	return c.distro.manifest
+}
+
+// DevicePolicy returns the constraints on the devices that the distribution
+// can be provisioned on.
+func (c *Configuration) DevicePolicy() DevicePolicy {
+	return c.distro.devices
+}
+
+// PartitionRules returns the rules that place files from ISO images on
+// partitions other than the boot partition, in the order they are evaluated.
+func (c *Configuration) PartitionRules() []PartitionRule {
+	return c.distro.partitions
+}
+
+// DataFileSystem returns the file system of the data partition that devices
+// are given when partition rules place files on it.
+func (c *Configuration) DataFileSystem() string {
+	if c.distro.dataFileSystem == "" {
+		return "NTFS"
+	}
+	return c.distro.dataFileSystem
 }
 
 // String implements the fmt.Stringer interface. This allows config to be passed to
@@ -335,19 +1139,41 @@ func (c *Configuration) String() string {
 	return fmt.Sprintf(`  Configuration:
   -------------
   Cleanup     : %t
-  Elevated    : %t
+  CacheDir    : %q
+  CacheMaxAge : %v
+  CacheMaxSize: %v
+  Contents    : %t
+  LogOnMedia  : %t
+  Unattend    : %q
   Update      : %t
+  SparseWrite : %t
+  Trim        : %t
+  Rollback    : %t
+  Preserve    : %t
   Warning     : %t
+  Batch       : %q
 
   Distribution: %q
   Label       : %q
+  Apply       : %t
+  ApplyIndex  : %d
   Track       : %q
   ImagePath   : %q
   ImageFile   : %q
+  DigestsPath : %q
+  Checksums   : %t
 
   SeedServer  : %q
-  SeedFile    : %q
+  SeedRequired: %t
+  StripDomain : %t
+  SeedFiles   : %q
+  SeedHash    : %q
   SeedDest    : %q
+  ShelfLife   : %v
+  SignServer  : %q
+  SignedImages: %q
+  Manifest    : %v
+  Devices     : %v
 
   confTrack   : %q
   confFile    : %q
@@ -355,29 +1181,47 @@ func (c *Configuration) String() string {
   FFUConfFile : %q
 
   Targets     : %v
-  PowerOff    : %t`,
+  PowerOff    : %t
+  Capabilities: %q`,
 		c.Cleanup(),
-		c.Elevated(),
+		c.CacheDir(),
+		c.CacheMaxAge(),
+		c.CacheMaxSize(),
+		c.ContentsOnMedia(),
+		c.LogOnMedia(),
+		c.Unattend(),
 		c.UpdateOnly(),
+		c.SparseWrite(),
+		c.Trim(),
+		c.Rollback(),
+		c.PreserveAttributes(),
 		c.Warning(),
+		c.Batch(),
 		c.Distro(),
 		c.DistroLabel(),
+		c.Apply(),
+		c.ApplyIndex(),
 		c.Track(),
-		c.ImagePath(),
+		imagefile.Redact(c.ImagePath()),
 		c.ImageFile(),
+		c.DigestsPath(),
+		c.Checksums(),
 		c.SeedServer(),
-		c.SeedFile(),
+		c.SeedRequired(),
+		c.StripDomain(),
+		c.SeedFiles(),
+		c.SeedHash(),
 		c.SeedDest(),
+		c.SeedShelfLife(),
+		c.SignServer(),
+		c.SignedImages(),
+		c.ManifestFiles(),
+		c.DevicePolicy(),
 		c.ConfTrack(),
 		c.ConfFile(),
 		c.FFUConfPath(),
 		c.FFUConfFile(),
 		c.Devices(),
-		c.PowerOff())
-}
-
-// isElevated determines if the current user is running the binary with elevated
-// permissions, such as 'sudo' (Linux) or 'run as administrator' (Windows).
-func isElevated() (bool, error) {
-	return IsElevatedCmd()
+		c.PowerOff(),
+		c.Capabilities())
 }
@@ -17,10 +17,62 @@
 
 package config
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
 var (
-	// IsElevatedCmd is not required on Darwin.
-	IsElevatedCmd = func() (bool, error) { return true, nil }
+	// Dependency injections for testing.
+	geteuid = os.Geteuid
+
+	// regExWholeDisk matches the identifiers of whole disks, as opposed to
+	// their partitions, such as disk2s1.
+	regExWholeDisk = regexp.MustCompile(`^disk[0-9]+$`)
 
-	// HasWritePermissions is not supported on Darwin.
-	HasWritePermissions = func() error { return nil }
+	// darwinPolicies are the configuration profiles that restrict removable
+	// media on macOS. Profiles installed by MDM are written to Managed
+	// Preferences, either for all users or for each user.
+	darwinPolicies = []writePolicy{
+		{
+			desc:  "a configuration profile restricts external disks",
+			glob:  "Library/Managed Preferences/com.apple.systemuiserver.plist",
+			match: matchAll(regexp.MustCompile(`harddisk-external`), regexp.MustCompile(`deny|read-only`)),
+		},
+		{
+			desc:  "a configuration profile restricts external disks",
+			glob:  "Library/Managed Preferences/*/com.apple.systemuiserver.plist",
+			match: matchAll(regexp.MustCompile(`harddisk-external`), regexp.MustCompile(`deny|read-only`)),
+		},
+	}
 )
+
+// probeCapability determines if the current user has a capability on Darwin.
+// Removable media is partitioned and mounted by diskutil on behalf of the
+// logged in user, but raw device nodes are only writable by root.
+func probeCapability(capability Capability) error {
+	if capability == Mount || geteuid() == 0 {
+		return nil
+	}
+	return errors.New("root is required, try again using 'sudo'")
+}
+
+// checkWholeDisk returns an error if id is a partition rather than a whole
+// disk, or has no device node.
+func checkWholeDisk(id string) error {
+	if !regExWholeDisk.MatchString(id) {
+		return fmt.Errorf("%q is a partition, provide the whole disk instead, such as disk2", id)
+	}
+	if _, err := os.Stat("/dev/" + id); err != nil {
+		return fmt.Errorf("device node for %q is not available: %v", id, err)
+	}
+	return nil
+}
+
+// HasWritePermissions determines if the local machine is blocked from writing
+// to removable media by a configuration profile.
+func HasWritePermissions() error {
+	return checkPolicies(darwinPolicies)
+}
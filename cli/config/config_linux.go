@@ -17,16 +17,117 @@
 
 package config
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
 var (
-	// IsElevatedCmd injects the command to determine the elevation state of the
-	// user context.
-	IsElevatedCmd = isRoot
+	// Dependency injections for testing.
+	geteuid     = os.Geteuid
+	getgroups   = os.Getgroups
+	lookupGroup = user.LookupGroup
+	statDevice  = os.Stat
+
+	// Where device nodes are found, and where the kernel describes them.
+	devDir   = "/dev"
+	sysBlock = "/sys/class/block"
 
-	// HasWritePermissions is not supported on Linux.
-	HasWritePermissions = func() error { return nil }
+	// udevUSB matches udev rules that apply to USB devices, and
+	// udevDeauthorize those that assign 0 to their authorized attribute. Each
+	// matches a whole comma separated key of the rule, so that comparisons
+	// such as ATTR{authorized}=="0" and keys that merely mention USB do not.
+	udevUSB         = regexp.MustCompile(`(^|,)\s*(SUBSYSTEMS?|DRIVERS?|ENV\{ID_BUS\})=="usb(-storage)?"\s*(,|$)`)
+	udevDeauthorize = regexp.MustCompile(`(^|,)\s*ATTR\{authorized\}\s*:?=\s*"0"\s*(,|$)`)
+
+	// linuxPolicies are the ways removable media is commonly restricted on
+	// Linux. They are hints rather than a complete list, as policy may also be
+	// enforced by tools that leave no configuration behind.
+	linuxPolicies = []writePolicy{
+		{
+			desc:  "the usb-storage kernel module is disabled",
+			glob:  "etc/modprobe.d/*.conf",
+			match: matchLine(regexp.MustCompile(`^(blacklist\s+usb[-_]storage|install\s+usb[-_]storage\s+/bin/(true|false))\b`)),
+		},
+		{
+			desc:  "a udev rule deauthorizes USB devices",
+			glob:  "etc/udev/rules.d/*.rules",
+			match: matchLine(udevUSB, udevDeauthorize),
+		},
+		{
+			desc:  "a polkit rule denies access to removable media",
+			glob:  "etc/polkit-1/rules.d/*.rules",
+			match: matchAll(regexp.MustCompile(`org\.freedesktop\.udisks2`), regexp.MustCompile(`polkit\.Result\.NO\b`)),
+		},
+	}
 )
 
-// isRoot always returns true on Linux, as sudo is built-in to all commands.
-func isRoot() (bool, error) {
-	return true, nil
+// diskGroup is the group that owns block devices on most distributions.
+const diskGroup = "disk"
+
+// probeCapability determines if the current user has a capability on Linux.
+// Root has all capabilities. Members of the disk group may also write to
+// devices, but only root may partition and mount them.
+func probeCapability(capability Capability) error {
+	if geteuid() == 0 {
+		return nil
+	}
+	if capability == WriteDevice && inDiskGroup() {
+		return nil
+	}
+	return errors.New("root is required, try again using 'sudo'")
+}
+
+// inDiskGroup determines if the current user is a member of the disk group.
+func inDiskGroup() bool {
+	g, err := lookupGroup(diskGroup)
+	if err != nil {
+		return false
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return false
+	}
+	groups, err := getgroups()
+	if err != nil {
+		return false
+	}
+	for _, id := range groups {
+		if id == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWholeDisk returns an error if id is not a block device node, or is a
+// partition, which the kernel marks with a partition attribute in sysfs.
+func checkWholeDisk(id string) error {
+	node := filepath.Join(devDir, id)
+	fi, err := statDevice(node)
+	if err != nil {
+		return fmt.Errorf("device node %q is not available, containers must be given it with --device or --privileged: %v", node, err)
+	}
+	if fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return fmt.Errorf("%q is not a block device", node)
+	}
+	sys := filepath.Join(sysBlock, id)
+	if _, err := os.Stat(sys); err != nil {
+		return fmt.Errorf("%q is not described in %q, which must be mounted: %v", node, sysBlock, err)
+	}
+	if _, err := os.Stat(filepath.Join(sys, "partition")); err == nil {
+		return fmt.Errorf("%q is a partition, provide the whole disk instead", node)
+	}
+	return nil
+}
+
+// HasWritePermissions determines if the local machine is blocked from writing
+// to removable media by kernel module, udev or polkit configuration.
+func HasWritePermissions() error {
+	return checkPolicies(linuxPolicies)
 }
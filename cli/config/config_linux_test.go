@@ -18,16 +18,210 @@ package config
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
 	"testing"
 )
 
-func TestIsRoot(t *testing.T) {
-	got, err := isRoot()
-	if !errors.Is(err, nil) {
-		t.Errorf("isRoot() err: %v, want err: %v", err, nil)
+func TestProbeCapability(t *testing.T) {
+	tests := []struct {
+		desc       string
+		euid       int
+		groups     []int
+		groupsErr  error
+		capability Capability
+		wantErr    bool
+	}{
+		{
+			desc:       "root can write",
+			euid:       0,
+			capability: WriteDevice,
+		},
+		{
+			desc:       "root can mount",
+			euid:       0,
+			capability: Mount,
+		},
+		{
+			desc:       "user cannot write",
+			euid:       1000,
+			groups:     []int{1000},
+			capability: WriteDevice,
+			wantErr:    true,
+		},
+		{
+			desc:       "disk group can write",
+			euid:       1000,
+			groups:     []int{1000, 6},
+			capability: WriteDevice,
+		},
+		{
+			desc:       "disk group cannot mount",
+			euid:       1000,
+			groups:     []int{1000, 6},
+			capability: Mount,
+			wantErr:    true,
+		},
+		{
+			desc:       "groups error",
+			euid:       1000,
+			groupsErr:  errors.New("error"),
+			capability: WriteDevice,
+			wantErr:    true,
+		},
 	}
-	if got != true {
-		t.Errorf("isRoot() got: %t, want: %t", got, true)
+	lookupGroup = func(string) (*user.Group, error) { return &user.Group{Gid: "6", Name: diskGroup}, nil }
+	for _, tt := range tests {
+		euid, groups, groupsErr := tt.euid, tt.groups, tt.groupsErr
+		geteuid = func() int { return euid }
+		getgroups = func() ([]int, error) { return groups, groupsErr }
+		err := probeCapability(tt.capability)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: probeCapability(%q) err: %v, want err: %t", tt.desc, tt.capability, err, tt.wantErr)
+		}
 	}
+}
+
+func TestLinuxPolicies(t *testing.T) {
+	tests := []struct {
+		desc     string
+		path     string
+		contents string
+		want     error
+	}{
+		{
+			desc:     "no policy",
+			path:     "etc/modprobe.d/usb.conf",
+			contents: "options usb-storage quirks=0781:5406:u\n",
+			want:     nil,
+		},
+		{
+			desc:     "module disabled",
+			path:     "etc/modprobe.d/usb.conf",
+			contents: "install usb-storage /bin/true\n",
+			want:     ErrWritePerms,
+		},
+		{
+			desc:     "udev deauthorized",
+			path:     "etc/udev/rules.d/99-usb.rules",
+			contents: `ACTION=="add", SUBSYSTEMS=="usb", ATTR{authorized}="0"` + "\n",
+			want:     ErrWritePerms,
+		},
+		{
+			desc:     "udev deauthorized with final assignment",
+			path:     "etc/udev/rules.d/99-usb.rules",
+			contents: `SUBSYSTEM=="usb", ENV{DEVTYPE}=="usb_device", ATTR{authorized}:="0"` + "\n",
+			want:     ErrWritePerms,
+		},
+		{
+			desc:     "udev compares authorized",
+			path:     "etc/udev/rules.d/99-usb.rules",
+			contents: `SUBSYSTEM=="usb", ATTR{authorized}=="0", RUN+="/usr/bin/logger usb"` + "\n",
+			want:     nil,
+		},
+		{
+			desc:     "udev mentions usb elsewhere",
+			path:     "etc/udev/rules.d/99-tpm.rules",
+			contents: `KERNEL=="tpm0", RUN+="/usr/lib/usb-notify", ATTR{authorized}="0"` + "\n",
+			want:     nil,
+		},
+		{
+			desc:     "udev commented out",
+			path:     "etc/udev/rules.d/99-usb.rules",
+			contents: `# SUBSYSTEMS=="usb", ATTR{authorized}="0"` + "\n",
+			want:     nil,
+		},
+		{
+			desc:     "polkit denied",
+			path:     "etc/polkit-1/rules.d/50-udisks.rules",
+			contents: "if (action.id.indexOf('org.freedesktop.udisks2.') == 0) { return polkit.Result.NO; }\n",
+			want:     ErrWritePerms,
+		},
+	}
+	for _, tt := range tests {
+		root, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+		}
+		path := filepath.Join(root, tt.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+		policyRoot = root
+		got := HasWritePermissions()
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: HasWritePermissions() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		os.RemoveAll(root)
+	}
+	policyRoot = "/"
+}
+
+// fakeNode describes a device node with mode.
+type fakeNode struct {
+	os.FileInfo
+	mode os.FileMode
+}
+
+func (n fakeNode) Mode() os.FileMode {
+	return n.mode
+}
 
+func TestCheckWholeDisks(t *testing.T) {
+	defer func() { statDevice = os.Stat; sysBlock = "/sys/class/block" }()
+	sysBlock = t.TempDir()
+	for _, dir := range []string{"sdb", filepath.Join("sdb1", "partition")} {
+		if err := os.MkdirAll(filepath.Join(sysBlock, dir), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", dir, err)
+		}
+	}
+	block := func(string) (os.FileInfo, error) { return fakeNode{mode: os.ModeDevice}, nil }
+	tests := []struct {
+		desc    string
+		devices []string
+		stat    func(string) (os.FileInfo, error)
+		want    error
+	}{
+		{
+			desc:    "whole disk",
+			devices: []string{"sdb"},
+			stat:    block,
+		},
+		{
+			desc:    "partition",
+			devices: []string{"sdb", "sdb1"},
+			stat:    block,
+			want:    errDevice,
+		},
+		{
+			desc:    "missing node",
+			devices: []string{"sdb"},
+			stat:    func(string) (os.FileInfo, error) { return nil, os.ErrNotExist },
+			want:    errDevice,
+		},
+		{
+			desc:    "character device",
+			devices: []string{"sdb"},
+			stat:    func(string) (os.FileInfo, error) { return fakeNode{mode: os.ModeDevice | os.ModeCharDevice}, nil },
+			want:    errDevice,
+		},
+		{
+			desc:    "not described in sysfs",
+			devices: []string{"sdc"},
+			stat:    block,
+			want:    errDevice,
+		},
+	}
+	for _, tt := range tests {
+		statDevice = tt.stat
+		c := Configuration{devices: tt.devices}
+		if got := c.CheckWholeDisks(); !errors.Is(got, tt.want) {
+			t.Errorf("%s: CheckWholeDisks() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
 }
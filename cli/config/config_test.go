@@ -17,8 +17,15 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/units"
 )
 
 var (
@@ -87,16 +94,16 @@ func equal(left, right []string) bool {
 
 func TestNew(t *testing.T) {
 	tests := []struct {
-		desc           string
-		fakeIsElevated func() (bool, error)
-		ffu            bool
-		devices        []string
-		os             string
-		track          string
-		confTrack      string
-		seedServer     string
-		out            *Configuration
-		want           error
+		desc       string
+		ffu        bool
+		rollback   bool
+		devices    []string
+		os         string
+		track      string
+		confTrack  string
+		seedServer string
+		out        *Configuration
+		want       error
 	}{
 		{
 			desc:    "bad devices",
@@ -117,14 +124,21 @@ func TestNew(t *testing.T) {
 			want:    errTrack,
 		},
 		{
-			desc:           "bad ffu track",
-			devices:        []string{"disk1"},
-			ffu:            true,
-			os:             "windowsffu",
-			confTrack:      "foo",
-			track:          "foo",
-			fakeIsElevated: func() (bool, error) { return true, nil },
-			want:           errTrack,
+			desc:      "bad ffu track",
+			devices:   []string{"disk1"},
+			ffu:       true,
+			os:        "windowsffu",
+			confTrack: "foo",
+			track:     "foo",
+			want:      errTrack,
+		},
+		{
+			desc:     "rollback without manifest",
+			devices:  []string{"disk1"},
+			os:       "windows",
+			track:    "stable",
+			rollback: true,
+			want:     errInput,
 		},
 		{
 			desc:       "bad seed server",
@@ -135,48 +149,35 @@ func TestNew(t *testing.T) {
 			want:       errSeed,
 		},
 		{
-			desc:           "isElevated error",
-			devices:        []string{"disk1"},
-			os:             "windows",
-			track:          "stable",
-			fakeIsElevated: func() (bool, error) { return false, errors.New("error") },
-			want:           errElevation,
-		},
-		{
-			desc:           "valid config",
-			devices:        []string{"disk1"},
-			os:             "windows",
-			track:          "stable",
-			fakeIsElevated: func() (bool, error) { return true, nil },
+			desc:    "valid config",
+			devices: []string{"disk1"},
+			os:      "windows",
+			track:   "stable",
 			out: &Configuration{
-				distro:   &goodDistro,
-				track:    "stable",
-				devices:  []string{"disk1"},
-				elevated: true,
+				distro:  &goodDistro,
+				track:   "stable",
+				devices: []string{"disk1"},
 			},
 			want: nil,
 		},
 		{
-			desc:           "valid config with ffu",
-			devices:        []string{"disk1"},
-			os:             "windowsffu",
-			ffu:            true,
-			confTrack:      "unstable",
-			track:          "unstable",
-			fakeIsElevated: func() (bool, error) { return true, nil },
+			desc:      "valid config with ffu",
+			devices:   []string{"disk1"},
+			os:        "windowsffu",
+			ffu:       true,
+			confTrack: "unstable",
+			track:     "unstable",
 			out: &Configuration{
 				distro:    &goodDistro,
 				track:     "unstable",
 				confTrack: "unstable",
 				devices:   []string{"disk1"},
-				elevated:  true,
 			},
 			want: nil,
 		},
 	}
 	for _, tt := range tests {
-		IsElevatedCmd = tt.fakeIsElevated
-		c, got := New(false, false, false, tt.ffu, false, tt.devices, tt.os, tt.track, tt.confTrack, tt.seedServer)
+		c, got := New(false, false, false, tt.ffu, false, false, false, tt.rollback, false, tt.devices, tt.os, tt.track, tt.confTrack, tt.seedServer)
 		if got == tt.want {
 			continue
 		}
@@ -198,6 +199,51 @@ func TestAddDistro(t *testing.T) {
 	noSeedFile.seedServer = `http://foo.bar.com`
 	noSeedDest := noSeedFile
 	noSeedDest.seedFile = "fake.wim"
+	extraSeedsOnly := goodDistro
+	extraSeedsOnly.seedFiles = []string{"sources/install.wim"}
+	duplicateSeeds := noSeedDest
+	duplicateSeeds.seedDest = "seed"
+	duplicateSeeds.seedFiles = []string{"fake.wim"}
+	noSignServer := noSeedDest
+	noSignServer.seedDest = "seed"
+	noSignServer.manifest = []string{"sources/boot.wim"}
+	applyLinux := goodDistro
+	applyLinux.os = linux
+	applyLinux.apply = true
+	badIndex := goodDistro
+	badIndex.os = windows
+	badIndex.applyIndex = -1
+	badRole := goodDistro
+	badRole.partitions = []PartitionRule{{Glob: "drivers", Role: "recovery"}}
+	badGlob := goodDistro
+	badGlob.partitions = []PartitionRule{{Glob: "drivers/[", Role: DataPartition}}
+	badSizes := goodDistro
+	badSizes.devices = DevicePolicy{MinSize: 16 * units.GB, MaxSize: 8 * units.GB}
+	badFileSystem := goodDistro
+	badFileSystem.devices = DevicePolicy{FileSystem: "ext4"}
+	badSeedTrack := goodDistro
+	badSeedTrack.seedTracks = map[string]bool{"lab": false}
+	badMirror := goodDistro
+	badMirror.mirrors = []string{goodDistro.imageServer}
+	probeWithoutMirrors := goodDistro
+	probeWithoutMirrors.probe = true
+	badScheme := goodDistro
+	badScheme.imageServer = "ftp://foo.bar.com/images"
+	badMirrorScheme := goodDistro
+	badMirrorScheme.mirrors = []string{"smb://mirror.bar.com/images"}
+	signedWithoutDigests := noSignServer
+	signedWithoutDigests.manifest = nil
+	signedWithoutDigests.signServer = `https://foo.bar.com/sign`
+	signedWithoutDigests.signedImages = "images/windows"
+	badDataFileSystem := goodDistro
+	badDataFileSystem.dataFileSystem = "FAT32"
+	noImageServer := goodDistro
+	noImageServer.imageServer = ""
+	signedChecksums := goodDistro
+	signedChecksums.checksums = true
+	signedChecksums.images = map[string]string{"default": "https://storage.googleapis.com/b/a.iso?X-Goog-Signature=abc"}
+	badImageScheme := goodDistro
+	badImageScheme.images = map[string]string{"default": "ftp://foo.bar.com/a.iso?sig=abc"}
 
 	tests := []struct {
 		desc    string
@@ -233,6 +279,139 @@ func TestAddDistro(t *testing.T) {
 			out:     Configuration{},
 			want:    errSeed,
 		},
+		{
+			desc:    "manifest without signServer",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": noSignServer},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "apply for linux",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": applyLinux},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "negative applyIndex",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badIndex},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "unknown partition role",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badRole},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "bad partition glob",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badGlob},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "device policy minimum exceeds maximum",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badSizes},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "device policy with unsupported file system",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badFileSystem},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "mirror of the image server",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badMirror},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "signedImages without digests",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": signedWithoutDigests},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "unsupported data file system",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badDataFileSystem},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "relative image without imageServer",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": noImageServer},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "checksums for a signed image",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": signedChecksums},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "unsupported image scheme",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badImageScheme},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "further seed files without a seed file",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": extraSeedsOnly},
+			out:     Configuration{},
+			want:    errSeed,
+		},
+		{
+			desc:    "duplicate seed files",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": duplicateSeeds},
+			out:     Configuration{},
+			want:    errSeed,
+		},
+		{
+			desc:    "unsupported image server scheme",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badScheme},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "unsupported mirror scheme",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badMirrorScheme},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "probe without mirrors",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": probeWithoutMirrors},
+			out:     Configuration{},
+			want:    errInput,
+		},
+		{
+			desc:    "seed requirement for unknown track",
+			choice:  "baz",
+			distros: map[string]distribution{"baz": badSeedTrack},
+			out:     Configuration{},
+			want:    errInput,
+		},
 		{
 			desc:    "good choice",
 			choice:  "good",
@@ -278,10 +457,16 @@ func TestAddDeviceList(t *testing.T) {
 		},
 		{
 			desc:    "bad linux path",
-			devices: []string{"/dev/sda", "sdb1", "sdb"},
+			devices: []string{"/dev/disk/by-id/usb-1", "sdb1", "sdb"},
 			out:     Configuration{},
 			want:    errDevice,
 		},
+		{
+			desc:    "device node",
+			devices: []string{"/dev/sda", "sdb"},
+			out:     Configuration{devices: []string{"sda", "sdb"}},
+			want:    nil,
+		},
 		{
 			desc:    "good linux path",
 			devices: []string{"sda"},
@@ -357,6 +542,335 @@ func TestAddSeedServer(t *testing.T) {
 	}
 }
 
+func TestUseEnvironment(t *testing.T) {
+	seeded := distribution{seedServer: "https://seed.foo.com/seed", signServer: "https://seed.foo.com/sign"}
+	tests := []struct {
+		desc     string
+		env      string
+		distro   distribution
+		wantSeed string
+		wantSign string
+		want     error
+	}{
+		{
+			desc:     "no environment",
+			distro:   seeded,
+			wantSeed: seeded.seedServer,
+			wantSign: seeded.signServer,
+		},
+		{
+			desc:     "prod",
+			env:      "prod",
+			distro:   seeded,
+			wantSeed: seeded.seedServer,
+			wantSign: seeded.signServer,
+		},
+		{
+			desc:     "dev",
+			env:      "dev",
+			distro:   seeded,
+			wantSeed: "http://localhost:8080/seed",
+			wantSign: "http://localhost:8080/sign",
+		},
+		{
+			desc:   "dev without seed",
+			env:    "dev",
+			distro: goodDistro,
+		},
+		{
+			desc:     "unknown",
+			env:      "staging",
+			distro:   seeded,
+			wantSeed: seeded.seedServer,
+			wantSign: seeded.signServer,
+			want:     errInput,
+		},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &tt.distro}
+		got := c.UseEnvironment(tt.env)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: UseEnvironment(%q) got: '%v', want: '%v'", tt.desc, tt.env, got, tt.want)
+		}
+		if c.SeedServer() != tt.wantSeed || c.SignServer() != tt.wantSign {
+			t.Errorf("%s: UseEnvironment(%q) servers got: (%q, %q), want: (%q, %q)", tt.desc, tt.env, c.SeedServer(), c.SignServer(), tt.wantSeed, tt.wantSign)
+		}
+	}
+}
+
+func TestUseBatch(t *testing.T) {
+	tests := []struct {
+		desc string
+		name string
+		want error
+	}{
+		{desc: "none", name: ""},
+		{desc: "valid", name: "NYC-onboarding-June"},
+		{desc: "dots and underscores", name: "site_1.2026"},
+		{desc: "space", name: "NYC onboarding", want: errInput},
+		{desc: "separator", name: "../batch", want: errInput},
+		{desc: "leading dash", name: "-batch", want: errInput},
+		{desc: "too long", name: strings.Repeat("a", 65), want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{batch: "previous"}
+		got := c.UseBatch(tt.name)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: UseBatch(%q) got: '%v', want: '%v'", tt.desc, tt.name, got, tt.want)
+		}
+		if got == nil && c.Batch() != tt.name {
+			t.Errorf("%s: UseBatch(%q) Batch() got: %q, want: %q", tt.desc, tt.name, c.Batch(), tt.name)
+		}
+	}
+}
+
+func TestValidateServer(t *testing.T) {
+	tests := []struct {
+		desc   string
+		server string
+		want   error
+	}{
+		{desc: "none"},
+		{desc: "https", server: "https://images.example.com/windows"},
+		{desc: "http", server: "http://localhost:8080/images"},
+		{desc: "gcs", server: "gs://corp-images/windows"},
+		{desc: "s3", server: "s3://corp-images/windows"},
+		{desc: "file", server: "file:///srv/images"},
+		{desc: "no scheme", server: "images.example.com/windows", want: errInput},
+		{desc: "unsupported scheme", server: "ftp://images.example.com", want: errInput},
+		{desc: "gcs without bucket", server: "gs:///windows", want: errInput},
+		{desc: "s3 without bucket", server: "s3:///windows", want: errInput},
+		{desc: "invalid", server: "https://images example com/%zz", want: errInput},
+	}
+	for _, tt := range tests {
+		if got := validateServer(tt.server); !errors.Is(got, tt.want) {
+			t.Errorf("%s: validateServer(%q) got: '%v', want: '%v'", tt.desc, tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestUseSeedFields(t *testing.T) {
+	distro := &distribution{seedFields: map[string]string{"site": "NYC", "cost_center": "0000"}}
+	tests := []struct {
+		desc  string
+		pairs []string
+		out   map[string]string
+		want  error
+	}{
+		{desc: "none", out: map[string]string{"site": "NYC", "cost_center": "0000"}},
+		{desc: "added", pairs: []string{"ticket=INC-42"}, out: map[string]string{"site": "NYC", "cost_center": "0000", "ticket": "INC-42"}},
+		{desc: "override", pairs: []string{"cost_center=1234"}, out: map[string]string{"site": "NYC", "cost_center": "1234"}},
+		{desc: "empty value", pairs: []string{"site="}, out: map[string]string{"site": "", "cost_center": "0000"}},
+		{desc: "value with separator", pairs: []string{"note=a=b"}, out: map[string]string{"site": "NYC", "cost_center": "0000", "note": "a=b"}},
+		{desc: "no separator", pairs: []string{"cost_center"}, want: errInput},
+		{desc: "invalid name", pairs: []string{"cost center=1234"}, want: errInput},
+		{desc: "value too long", pairs: []string{"note=" + strings.Repeat("a", 257)}, want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: distro}
+		err := c.UseSeedFields(tt.pairs)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UseSeedFields(%v) got: '%v', want: '%v'", tt.desc, tt.pairs, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if got := c.SeedFields(); !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("%s: SeedFields() got: %v, want: %v", tt.desc, got, tt.out)
+		}
+	}
+}
+
+func TestUseScanner(t *testing.T) {
+	tests := []struct {
+		desc    string
+		command string
+		out     []string
+		want    error
+	}{
+		{desc: "none"},
+		{desc: "arguments", command: "clamscan --no-summary {path}", out: []string{"clamscan", "--no-summary", ScanPath}},
+		{desc: "extra spaces", command: "  scan   -q ", out: []string{"scan", "-q"}},
+		{desc: "quoted", command: `"C:\Program Files\Windows Defender\MpCmdRun.exe" -Scan -File "{path}"`, out: []string{`C:\Program Files\Windows Defender\MpCmdRun.exe`, "-Scan", "-File", ScanPath}},
+		{desc: "empty quotes", command: `scan ""`, out: []string{"scan", ""}},
+		{desc: "unterminated quote", command: `scan "{path}`, want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{}
+		err := c.UseScanner(tt.command)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UseScanner(%q) got: '%v', want: '%v'", tt.desc, tt.command, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if got := c.Scanner(); !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("%s: Scanner() got: %q, want: %q", tt.desc, got, tt.out)
+		}
+	}
+}
+
+func TestUsePersistence(t *testing.T) {
+	tests := []struct {
+		desc      string
+		os        OperatingSystem
+		persist   bool
+		size      units.Size
+		label     string
+		wantLabel string
+		want      error
+	}{
+		{desc: "disabled", os: windows},
+		{desc: "size without persistence", os: linux, size: units.GB, want: errInput},
+		{desc: "label without persistence", os: linux, label: "casper-rw", want: errInput},
+		{desc: "windows", os: windows, persist: true, want: errInput},
+		{desc: "default label", os: linux, persist: true, size: 4 * units.GB, wantLabel: DefaultPersistenceLabel},
+		{desc: "label", os: linux, persist: true, label: "casper-rw", wantLabel: "casper-rw"},
+		{desc: "label too long", os: linux, persist: true, label: "persistence-partition", want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &distribution{os: tt.os}}
+		err := c.UsePersistence(tt.persist, tt.size, tt.label)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UsePersistence() got: '%v', want: '%v'", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if c.Persistence() != tt.persist || c.PersistenceSize() != tt.size || c.PersistenceLabel() != tt.wantLabel {
+			t.Errorf("%s: UsePersistence() got: (%t, %v, %q), want: (%t, %v, %q)", tt.desc, c.Persistence(), c.PersistenceSize(), c.PersistenceLabel(), tt.persist, tt.size, tt.wantLabel)
+		}
+	}
+}
+
+func TestUseUnattend(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"autounattend.xml", "preseed.cfg", "ks.cfg", "notes.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("answers"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "answers.xml"), 0755); err != nil {
+		t.Fatalf("os.Mkdir() returned %v", err)
+	}
+	tests := []struct {
+		desc     string
+		os       OperatingSystem
+		path     string
+		wantDest string
+		want     error
+	}{
+		{desc: "disabled", os: windows},
+		{desc: "autounattend", os: windows, path: "autounattend.xml", wantDest: "autounattend.xml"},
+		{desc: "preseed", os: linux, path: "preseed.cfg", want: errInput},
+		{desc: "kickstart", os: linux, path: "ks.cfg", want: errInput},
+		{desc: "preseed for windows", os: windows, path: "preseed.cfg", want: errInput},
+		{desc: "autounattend for linux", os: linux, path: "autounattend.xml", want: errInput},
+		{desc: "unknown kind", os: windows, path: "notes.txt", want: errInput},
+		{desc: "missing", os: windows, path: "unattend.xml", want: errInput},
+		{desc: "directory", os: windows, path: "answers.xml", want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &distribution{os: tt.os}}
+		path := tt.path
+		if path != "" {
+			path = filepath.Join(dir, path)
+		}
+		err := c.UseUnattend(path)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UseUnattend() got: '%v', want: '%v'", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if c.Unattend() != path || c.UnattendDest() != tt.wantDest {
+			t.Errorf("%s: UseUnattend() got: (%q, %q), want: (%q, %q)", tt.desc, c.Unattend(), c.UnattendDest(), path, tt.wantDest)
+		}
+	}
+}
+
+func TestUseImpersonation(t *testing.T) {
+	tests := []struct {
+		desc    string
+		account string
+		user    string
+		want    error
+	}{
+		{desc: "none"},
+		{desc: "service account", account: "writer@project.iam.gserviceaccount.com"},
+		{desc: "delegated", account: "writer@project.iam.gserviceaccount.com", user: "operator@example.com"},
+		{desc: "user without account", user: "operator@example.com", want: errInput},
+		{desc: "invalid account", account: "writer", want: errInput},
+		{desc: "invalid user", account: "writer@project.iam.gserviceaccount.com", user: "operator @example.com", want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{impersonate: "previous@example.com", delegate: "previous@example.com"}
+		got := c.UseImpersonation(tt.account, tt.user)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: UseImpersonation(%q, %q) got: '%v', want: '%v'", tt.desc, tt.account, tt.user, got, tt.want)
+		}
+		if got != nil {
+			continue
+		}
+		if c.Impersonate() != tt.account || c.Delegate() != tt.user {
+			t.Errorf("%s: UseImpersonation(%q, %q) got: (%q, %q), want: (%q, %q)", tt.desc, tt.account, tt.user, c.Impersonate(), c.Delegate(), tt.account, tt.user)
+		}
+	}
+}
+
+func TestUseCacheDir(t *testing.T) {
+	abs, err := filepath.Abs("cache")
+	if err != nil {
+		t.Fatalf("filepath.Abs() returned %v", err)
+	}
+	tests := []struct {
+		desc string
+		dir  string
+		want string
+	}{
+		{desc: "none", dir: ""},
+		{desc: "relative", dir: "cache", want: abs},
+		{desc: "absolute", dir: abs, want: abs},
+	}
+	for _, tt := range tests {
+		c := Configuration{cacheDir: "previous"}
+		if err := c.UseCacheDir(tt.dir); err != nil {
+			t.Errorf("%s: UseCacheDir(%q) returned %v", tt.desc, tt.dir, err)
+		}
+		if c.CacheDir() != tt.want {
+			t.Errorf("%s: UseCacheDir(%q) CacheDir() got: %q, want: %q", tt.desc, tt.dir, c.CacheDir(), tt.want)
+		}
+	}
+}
+
+func TestUseCacheLimits(t *testing.T) {
+	tests := []struct {
+		desc    string
+		dir     string
+		maxAge  time.Duration
+		maxSize units.Size
+		want    error
+	}{
+		{desc: "no limits", dir: ""},
+		{desc: "limits", dir: "/cache", maxAge: 24 * time.Hour, maxSize: 50 * units.GB},
+		{desc: "negative age", dir: "/cache", maxAge: -time.Hour, want: errInput},
+		{desc: "no directory", dir: "", maxSize: units.GB, want: errInput},
+	}
+	for _, tt := range tests {
+		c := Configuration{cacheDir: tt.dir}
+		err := c.UseCacheLimits(tt.maxAge, tt.maxSize)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: UseCacheLimits(%v, %v) got: %v, want: %v", tt.desc, tt.maxAge, tt.maxSize, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if c.CacheMaxAge() != tt.maxAge || c.CacheMaxSize() != tt.maxSize {
+			t.Errorf("%s: UseCacheLimits(%v, %v) got: (%v, %v)", tt.desc, tt.maxAge, tt.maxSize, c.CacheMaxAge(), c.CacheMaxSize())
+		}
+	}
+}
+
 func TestValidateTrack(t *testing.T) {
 	badDistro := distribution{
 		imageServer: imageServer,
@@ -452,6 +966,41 @@ func TestImage(t *testing.T) {
 	}
 }
 
+func TestImageURL(t *testing.T) {
+	signed := "https://storage.googleapis.com/b/a.iso?X-Goog-Signature=abc"
+	distro := distribution{
+		imageServer: `https://foo.bar.com`,
+		mirrors:     []string{`https://mirror1.bar.com`},
+		images:      map[string]string{"default": signed},
+	}
+	c := Configuration{track: "default", distro: &distro}
+	if got := c.ImagePath(); got != signed {
+		t.Errorf("ImagePath() got: %q, want: %q", got, signed)
+	}
+	if got := c.ImageMirrors(); got != nil {
+		t.Errorf("ImageMirrors() got: %v, want: nil", got)
+	}
+	if got, want := c.ImageFile(), "a.iso"; got != want {
+		t.Errorf("ImageFile() got: %q, want: %q", got, want)
+	}
+	if got := c.String(); strings.Contains(got, "X-Goog-Signature") {
+		t.Errorf("String() got: %q, want the signature redacted", got)
+	}
+}
+
+func TestImageMirrors(t *testing.T) {
+	distro := distribution{
+		imageServer: `https://foo.bar.com`,
+		mirrors:     []string{`https://mirror1.bar.com`, `https://mirror2.bar.com/images`},
+		images:      map[string]string{"default": "nested/test_installer.img"},
+	}
+	want := []string{`https://mirror1.bar.com/nested/test_installer.img`, `https://mirror2.bar.com/images/nested/test_installer.img`}
+	c := Configuration{track: "default", distro: &distro}
+	if got := c.ImageMirrors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ImageMirrors() got: %v, want: %v", got, want)
+	}
+}
+
 func TestImageFile(t *testing.T) {
 	tests := []struct {
 		desc   string
@@ -488,6 +1037,21 @@ func TestImageFile(t *testing.T) {
 			images: map[string]string{"default": "nested/compressed-img.img.gz"},
 			want:   "compressed-img.img.gz",
 		},
+		{
+			desc:   "version number",
+			images: map[string]string{"default": "nested/debian-12.5.0-amd64.iso"},
+			want:   "debian-12.5.0-amd64.iso",
+		},
+		{
+			desc:   "query string",
+			images: map[string]string{"default": "nested/test_iso.iso?generation=2&path=a/b.img"},
+			want:   "test_iso.iso",
+		},
+		{
+			desc:   "escaped",
+			images: map[string]string{"default": "nested/test%20iso.iso"},
+			want:   "test iso.iso",
+		},
 	}
 	for _, tt := range tests {
 		c := Configuration{
@@ -549,6 +1113,63 @@ func TestPowerOff(t *testing.T) {
 	}
 }
 
+func TestSparseWrite(t *testing.T) {
+	want := true
+	c := Configuration{sparse: want}
+	if got := c.SparseWrite(); got != want {
+		t.Errorf("SparseWrite() got: %t, want: %t", got, want)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	want := true
+	c := Configuration{trim: want}
+	if got := c.Trim(); got != want {
+		t.Errorf("Trim() got: %t, want: %t", got, want)
+	}
+}
+
+func TestPreserveAttributes(t *testing.T) {
+	want := true
+	c := Configuration{preserve: want}
+	if got := c.PreserveAttributes(); got != want {
+		t.Errorf("PreserveAttributes() got: %t, want: %t", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	want := true
+	c := Configuration{distro: &distribution{apply: want}}
+	if got := c.Apply(); got != want {
+		t.Errorf("Apply() got: %t, want: %t", got, want)
+	}
+}
+
+func TestApplyIndex(t *testing.T) {
+	tests := []struct {
+		desc  string
+		index int
+		want  int
+	}{
+		{
+			desc:  "not configured",
+			index: 0,
+			want:  1,
+		},
+		{
+			desc:  "configured",
+			index: 3,
+			want:  3,
+		},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &distribution{applyIndex: tt.index}}
+		if got := c.ApplyIndex(); got != tt.want {
+			t.Errorf("%s: ApplyIndex() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestWarning(t *testing.T) {
 	want := true
 	c := Configuration{warning: want}
@@ -569,6 +1190,44 @@ func TestSeedServer(t *testing.T) {
 	}
 }
 
+func TestSeedRequired(t *testing.T) {
+	tests := []struct {
+		desc   string
+		distro distribution
+		track  string
+		want   bool
+	}{
+		{
+			desc:   "no seed server",
+			distro: distribution{seedTracks: map[string]bool{"lab": true}},
+			track:  "lab",
+		},
+		{
+			desc:   "track not listed",
+			distro: distribution{seedServer: "https://seed.foo.com", seedTracks: map[string]bool{"lab": false}},
+			track:  "stable",
+			want:   true,
+		},
+		{
+			desc:   "track not required",
+			distro: distribution{seedServer: "https://seed.foo.com", seedTracks: map[string]bool{"lab": false}},
+			track:  "lab",
+		},
+		{
+			desc:   "track required",
+			distro: distribution{seedServer: "https://seed.foo.com", seedTracks: map[string]bool{"lab": true}},
+			track:  "lab",
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &tt.distro, track: tt.track}
+		if got := c.SeedRequired(); got != tt.want {
+			t.Errorf("%s: SeedRequired() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestSeedFile(t *testing.T) {
 	seedFile := `sources/base.wim`
 	distro := distribution{
@@ -581,6 +1240,32 @@ func TestSeedFile(t *testing.T) {
 	}
 }
 
+func TestSeedFiles(t *testing.T) {
+	tests := []struct {
+		desc   string
+		distro distribution
+		want   []string
+	}{
+		{desc: "none"},
+		{desc: "single", distro: distribution{seedFile: "sources/boot.wim"}, want: []string{"sources/boot.wim"}},
+		{desc: "several", distro: distribution{seedFile: "sources/boot.wim", seedFiles: []string{"sources/install.wim"}}, want: []string{"sources/boot.wim", "sources/install.wim"}},
+	}
+	for _, tt := range tests {
+		c := Configuration{distro: &tt.distro}
+		if got := c.SeedFiles(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: SeedFiles() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSeedHash(t *testing.T) {
+	want := "sha512"
+	c := Configuration{distro: &distribution{seedHash: want}}
+	if got := c.SeedHash(); got != want {
+		t.Errorf("SeedHash() got: %q, want: %q", got, want)
+	}
+}
+
 func TestSeedDest(t *testing.T) {
 	seedDest := "test"
 	distro := distribution{
@@ -593,6 +1278,61 @@ func TestSeedDest(t *testing.T) {
 	}
 }
 
+func TestSeedShelfLife(t *testing.T) {
+	want := 72 * time.Hour
+	c := Configuration{distro: &distribution{shelfLife: want}}
+	if got := c.SeedShelfLife(); got != want {
+		t.Errorf("SeedShelfLife() got: %v, want: %v", got, want)
+	}
+}
+
+func TestSignServer(t *testing.T) {
+	want := `https://sign.foo.com`
+	c := Configuration{distro: &distribution{signServer: want}}
+	if got := c.SignServer(); got != want {
+		t.Errorf("SignServer() got: %q, want: %q", got, want)
+	}
+}
+
+func TestManifestFiles(t *testing.T) {
+	want := []string{"sources/boot.wim", "sources/install.wim"}
+	c := Configuration{distro: &distribution{manifest: want}}
+	if got := c.ManifestFiles(); !equal(got, want) {
+		t.Errorf("ManifestFiles() got: %v, want: %v", got, want)
+	}
+}
+
+func TestDevicePolicy(t *testing.T) {
+	want := DevicePolicy{MinSize: 8 * units.GB, RemovableOnly: true}
+	c := Configuration{distro: &distribution{devices: want}}
+	if got := c.DevicePolicy(); got != want {
+		t.Errorf("DevicePolicy() got: %v, want: %v", got, want)
+	}
+}
+
+func TestDevicePolicyString(t *testing.T) {
+	tests := []struct {
+		desc   string
+		policy DevicePolicy
+		want   string
+	}{
+		{
+			desc: "no constraints",
+			want: "any device",
+		},
+		{
+			desc:   "all constraints",
+			policy: DevicePolicy{MinSize: 8 * units.GB, MaxSize: 64 * units.GB, RemovableOnly: true, FileSystem: "NTFS"},
+			want:   "at least " + (8 * units.GB).String() + ", at most " + (64 * units.GB).String() + ", removable only, NTFS for updates",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("%s: String() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestString(t *testing.T) {
 	want := "test-distro"
 	distro := distribution{
@@ -18,25 +18,35 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strings"
-	"syscall"
 
 	win "golang.org/x/sys/windows"
 	"github.com/google/glazier/go/registry"
 )
 
 var (
-
-	// IsElevatedCmd injects the command to determine the elevation state of the
-	// user context.
-	IsElevatedCmd      = isAdmin
+	// Dependency injections for testing.
+	isAdminCmd         = isAdmin
 	funcUSBPermissions = HasWritePermissions
 
 	denyWriteRegKey = `SOFTWARE\Policies\Microsoft\Windows\RemovableStorageDevices\{53f5630d-b6bf-11d0-94f2-00a0c91efb8b}`
 )
 
+// probeCapability determines if the current user has a capability on
+// Windows. Both writing to and partitioning devices require a session that
+// was started with 'run as administrator'.
+func probeCapability(Capability) error {
+	admin, err := isAdminCmd()
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return errors.New("administrator rights are required, try again using 'run as administrator'")
+	}
+	return nil
+}
+
 // isAdmin determines if the current user is running the binary with elevated
 // permissions on Windows.
 func isAdmin() (bool, error) {
@@ -54,6 +64,7 @@ func isAdmin() (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("sid error: %v", err)
 	}
+	defer win.FreeSid(sid)
 
 	token := win.Token(0)
 	defer token.Close()
@@ -62,37 +73,7 @@ func isAdmin() (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("Token Membership Error: %v", err)
 	}
-
-	// user is currently an admin
-	if member {
-		return true, nil
-	}
-
-	if err := runAsAdmin(); err != nil {
-		return false, fmt.Errorf("runAsAdmin Error: %v", err)
-	}
-
-	return false, errElevation
-}
-
-// If not run in an Admin session, try to re-open in one.
-func runAsAdmin() error {
-	verb := "runas"
-	exe, _ := os.Executable()
-	cwd, _ := os.Getwd()
-	args := strings.Join(os.Args[1:], " ")
-
-	verbPtr, _ := syscall.UTF16PtrFromString(verb)
-	exePtr, _ := syscall.UTF16PtrFromString(exe)
-	cwdPtr, _ := syscall.UTF16PtrFromString(cwd)
-	argPtr, _ := syscall.UTF16PtrFromString(args)
-
-	var showCmd int32 = 1 //SW_NORMAL
-
-	if err := win.ShellExecute(0, verbPtr, exePtr, argPtr, cwdPtr, showCmd); err != nil {
-		return (err)
-	}
-	return nil
+	return member, nil
 }
 
 // HasWritePermissions determines if the local machine is blocked from writing to removable media via policy.
@@ -102,7 +83,13 @@ func HasWritePermissions() error {
 		return err
 	}
 	if v == 1 {
-		return ErrWritePerms
+		return fmt.Errorf("%w: the 'Removable Disks: Deny write access' group policy is enabled", ErrWritePerms)
 	}
 	return nil
 }
+
+// checkWholeDisk always succeeds, as devices are identified by disk number on
+// Windows, which always refers to a whole disk.
+func checkWholeDisk(string) error {
+	return nil
+}
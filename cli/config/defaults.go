@@ -14,7 +14,34 @@
 
 package config
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/google/fresnel/cli/units"
+)
+
+// The endpoints of the built-in distributions. The values here are
+// placeholders, which organizations replace with their own deployment when
+// building the CLI rather than by patching this file, such as with
+// '-ldflags "-X github.com/google/fresnel/cli/config.DefaultSeedServer=https://fresnel.example.com/seed"'.
+// They are reported by the endpoints sub-command.
+const (
+	placeholderSeedServer  = "https://appengine.address.com/seed"
+	placeholderImageServer = "https://image.host.com/folder"
+	placeholderConfServer  = "https://config.host.com/folder"
+)
+
+var (
+	// DefaultSeedServer is the seed server of the built-in windows
+	// distribution.
+	DefaultSeedServer = placeholderSeedServer
+	// DefaultImageServer is the image server of the built-in windows and
+	// windowsffu distributions.
+	DefaultImageServer = placeholderImageServer
+	// DefaultConfServer is the server of the FFU configurations of the
+	// built-in windowsffu distribution.
+	DefaultConfServer = placeholderConfServer
+)
 
 // distributions configures the options for different operating system
 // installers.
@@ -26,21 +53,23 @@ var (
 			os:          windows,
 			label:       "INSTALLER",
 			name:        "windows",
-			seedServer:  "https://appengine.address.com/seed",
+			seedServer:  DefaultSeedServer,
 			seedFile:    "sources/boot.wim",
 			seedDest:    "seed",
-			imageServer: "https://image.host.com/folder",
+			imageServer: DefaultImageServer,
 			images: map[string]string{
 				"default": "installer_img.iso",
 				"stable":  "installer_img.iso",
 			},
+			// The installer image does not fit on smaller devices.
+			devices: DevicePolicy{MinSize: 8 * units.GB},
 		},
 		"windowsffu": distribution{
 			os:          windows,
 			label:       "INSTALLER",
 			name:        "windows",
-			imageServer: "https://image.host.com/folder",
-			confServer:  "https://config.host.com/folder",
+			imageServer: DefaultImageServer,
+			confServer:  DefaultConfServer,
 			images: map[string]string{
 				"default":  "installer_img.iso",
 				"stable":   "installer_img.iso",
@@ -63,6 +92,19 @@ var (
 		},
 	}
 
+	// environments override the servers of a distribution, so that the same
+	// distribution can be provisioned against another deployment of the
+	// backend. Only servers that are configured for the distribution are
+	// overridden.
+	environments = map[string]environment{
+		"prod": environment{},
+		// dev targets the server started by 'go run ./appengine -dev'.
+		"dev": environment{
+			seedServer: "http://localhost:8080/seed",
+			signServer: "http://localhost:8080/sign",
+		},
+	}
+
 	// ErrUSBwriteAccess contains the Error message visible to users when USB write access is forbidden.
 	ErrUSBwriteAccess = fmt.Errorf("contact IT helpdesk for help")
 )
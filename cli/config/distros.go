@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// defaultsRevision identifies the distributions built into the CLI. It is
+// computed before any configuration file is merged over them.
+var defaultsRevision = revision(distributions)
+
+// Distro describes a distribution that is available for provisioning, so that
+// callers can present the available choices without duplicating the defaults.
+type Distro struct {
+	// Name is the name used to select the distribution, such as with --distro.
+	Name string
+	// OS is the operating system of the installer, windows or linux.
+	OS OperatingSystem
+	// Tracks are the image tracks available for the distribution, in order.
+	Tracks []string
+	// ConfTracks are the FFU configuration tracks available for the
+	// distribution, in order. Empty unless FFU is set.
+	ConfTracks []string
+	// Seed is set when a seed is obtained while provisioning the distribution.
+	Seed bool
+	// FFU is set when the distribution is provisioned with an FFU configuration.
+	FFU bool
+	// Apply is set when images are applied to devices rather than written as
+	// installers.
+	Apply bool
+}
+
+// Distributions returns each of the configured distributions and the tracks
+// available for them, ordered by name.
+func Distributions() []Distro {
+	distros := []Distro{}
+	for name, d := range distributions {
+		distros = append(distros, Distro{
+			Name:       name,
+			OS:         d.os,
+			Tracks:     tracks(d.images),
+			ConfTracks: tracks(d.configs),
+			Seed:       d.seedServer != "",
+			FFU:        len(d.configs) > 0,
+			Apply:      d.apply,
+		})
+	}
+	sort.Slice(distros, func(i, j int) bool { return distros[i].Name < distros[j].Name })
+	return distros
+}
+
+// tracks returns the tracks available in m in order, with default first.
+func tracks(m map[string]string) []string {
+	out := []string{}
+	for t := range m {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i] == "default" || out[j] == "default" {
+			return out[i] == "default"
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// DefaultsRevision identifies the revision of the distributions built into the
+// CLI, so that deployed binaries can be told apart by their configuration as
+// well as their version. It changes whenever a built-in distribution does, and
+// is unaffected by configuration files.
+func DefaultsRevision() string {
+	return defaultsRevision
+}
+
+// revision returns the first 12 hex digits of the SHA-256 hash of distros.
+// Maps are formatted in key order, so the result is stable between runs.
+func revision(distros map[string]distribution) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", distros)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Endpoint describes one of the endpoints compiled into the CLI.
+type Endpoint struct {
+	// Name describes the endpoint, such as 'Seed server'.
+	Name string
+	// Variable is the variable that sets the endpoint at build time, such as
+	// 'github.com/google/fresnel/cli/config.DefaultSeedServer'.
+	Variable string
+	// Value is the compiled-in value of the endpoint.
+	Value string
+	// Placeholder is set when Value is the placeholder in the source, meaning
+	// that no endpoint was injected when the CLI was built.
+	Placeholder bool
+}
+
+// Endpoints returns the endpoints compiled into the CLI, in the order they
+// are reported. Configuration files do not change them.
+func Endpoints() []Endpoint {
+	const pkg = "github.com/google/fresnel/cli/config."
+	return []Endpoint{
+		{Name: "Seed server", Variable: pkg + "DefaultSeedServer", Value: DefaultSeedServer, Placeholder: DefaultSeedServer == placeholderSeedServer},
+		{Name: "Image server", Variable: pkg + "DefaultImageServer", Value: DefaultImageServer, Placeholder: DefaultImageServer == placeholderImageServer},
+		{Name: "Config server", Variable: pkg + "DefaultConfServer", Value: DefaultConfServer, Placeholder: DefaultConfServer == placeholderConfServer},
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistributions(t *testing.T) {
+	defaults := distributions
+	defer func() { distributions = defaults }()
+	distributions = map[string]distribution{
+		"windowsffu": distribution{
+			os:         windows,
+			seedServer: "https://appengine.address.com/seed",
+			images:     map[string]string{"stable": "a.iso", "default": "a.iso", "beta": "a.iso"},
+			configs:    map[string]string{"default": "a.yaml"},
+		},
+		"linux": distribution{
+			os:     linux,
+			images: map[string]string{"default": "a.img"},
+		},
+	}
+	want := []Distro{
+		{
+			Name:       "linux",
+			OS:         linux,
+			Tracks:     []string{"default"},
+			ConfTracks: []string{},
+		},
+		{
+			Name:       "windowsffu",
+			OS:         windows,
+			Tracks:     []string{"default", "beta", "stable"},
+			ConfTracks: []string{"default"},
+			Seed:       true,
+			FFU:        true,
+		},
+	}
+	if got := Distributions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Distributions() got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	base := func() map[string]distribution {
+		return map[string]distribution{
+			"windows": distribution{os: windows, images: map[string]string{"default": "a.iso", "stable": "a.iso", "beta": "b.iso"}},
+			"linux":   distribution{os: linux, images: map[string]string{"default": "a.img"}},
+		}
+	}
+	changed := base()
+	changed["linux"] = distribution{os: linux, images: map[string]string{"default": "b.img"}}
+	tests := []struct {
+		desc    string
+		distros map[string]distribution
+		same    bool
+	}{
+		{desc: "same distributions", distros: base(), same: true},
+		{desc: "changed image", distros: changed},
+		{desc: "removed distribution", distros: map[string]distribution{"linux": base()["linux"]}},
+	}
+	want := revision(base())
+	if len(want) != 12 {
+		t.Fatalf("revision() got: %q, want 12 hex digits", want)
+	}
+	for _, tt := range tests {
+		if got := revision(tt.distros); (got == want) != tt.same {
+			t.Errorf("%s: revision() got: %q, base: %q, want same: %t", tt.desc, got, want, tt.same)
+		}
+	}
+	if got := DefaultsRevision(); got != revision(distributions) {
+		t.Errorf("DefaultsRevision() got: %q, want: %q", got, revision(distributions))
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	seed := DefaultSeedServer
+	defer func() { DefaultSeedServer = seed }()
+	tests := []struct {
+		desc            string
+		seedServer      string
+		wantPlaceholder bool
+	}{
+		{desc: "placeholder", seedServer: placeholderSeedServer, wantPlaceholder: true},
+		{desc: "injected", seedServer: "https://fresnel.example.com/seed"},
+	}
+	for _, tt := range tests {
+		DefaultSeedServer = tt.seedServer
+		got := Endpoints()
+		if len(got) != 3 {
+			t.Fatalf("%s: Endpoints() returned %d endpoints, want 3", tt.desc, len(got))
+		}
+		if got[0].Value != tt.seedServer || got[0].Placeholder != tt.wantPlaceholder {
+			t.Errorf("%s: Endpoints()[0] got: %+v, want value %q with placeholder %t", tt.desc, got[0], tt.seedServer, tt.wantPlaceholder)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/fresnel/cli/units"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileName is the name of the configuration file in the user
+// configuration directory that is read when no file is specified.
+const configFileName = "distributions.yaml"
+
+// ContainerConfigPath is where the configuration file is read from in
+// container mode, which containers are expected to bind mount it to.
+const ContainerConfigPath = "/etc/fresnel/" + configFileName
+
+var (
+	// Dependency injections for testing.
+	readFile      = ioutil.ReadFile
+	userConfigDir = os.UserConfigDir
+
+	// Wrapped errors for testing.
+	errConfigFile = errors.New("configuration file error")
+)
+
+// configFile models a file of distribution definitions. Distributions are
+// keyed by the name used to select them, such as with --distro. Proxy is used
+// for every distribution, unless a proxy is provided by flag.
+type configFile struct {
+	Proxy         string                  `yaml:"proxy"`
+	Distributions map[string]distroConfig `yaml:"distributions"`
+}
+
+// distroConfig models a distribution in a configuration file. Fields that are
+// not set leave the built-in value of a distribution of the same name in
+// place, so that a file only needs to declare what it changes.
+type distroConfig struct {
+	OS          OperatingSystem   `yaml:"os"`
+	Name        string            `yaml:"name"`
+	Label       string            `yaml:"label"`
+	ImageServer string            `yaml:"imageServer"`
+	Mirrors     []string          `yaml:"mirrors"`
+	Probe       *bool             `yaml:"probeMirrors"`
+	Images      map[string]string `yaml:"images"`
+	Digests     string            `yaml:"digests"`
+	Checksums   *bool             `yaml:"checksums"`
+	ConfServer  string            `yaml:"confServer"`
+	ConfFile    string            `yaml:"confFile"`
+	Configs     map[string]string `yaml:"configs"`
+	SeedServer  string            `yaml:"seedServer"`
+	SeedFile    seedFileList      `yaml:"seedFile"`
+	SeedDest    string            `yaml:"seedDest"`
+	SeedHash    string            `yaml:"seedHash"`
+	SeedTracks  map[string]bool   `yaml:"seedTracks"`
+	SeedFields  map[string]string `yaml:"seedFields"`
+	ShelfLife   string            `yaml:"shelfLife"`
+	StripDomain *bool             `yaml:"stripDomain"`
+	SignServer  string            `yaml:"signServer"`
+	Manifest    []string          `yaml:"manifest"`
+	Partitions  []PartitionRule   `yaml:"partitions"`
+	Devices     *devicesConfig    `yaml:"devices"`
+
+	SignedImages   string `yaml:"signedImages"`
+	DataFileSystem string `yaml:"dataFileSystem"`
+	Apply          *bool  `yaml:"apply"`
+	ApplyIndex     int    `yaml:"applyIndex"`
+}
+
+// seedFileList models the seedFile of a distribution in a configuration file,
+// which is either a single path or a list of paths that seeds are obtained
+// for, such as [sources/boot.wim, sources/install.wim].
+type seedFileList []string
+
+// UnmarshalYAML accepts either a single path or a list of paths.
+func (l *seedFileList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		if single != "" {
+			*l = seedFileList{single}
+		}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("seedFile must be a path or a list of paths: %v", err)
+	}
+	*l = list
+	return nil
+}
+
+// devicesConfig models a DevicePolicy in a configuration file. Sizes are
+// strings such as '8G'.
+type devicesConfig struct {
+	MinSize       string `yaml:"minSize"`
+	MaxSize       string `yaml:"maxSize"`
+	RemovableOnly bool   `yaml:"removableOnly"`
+	FileSystem    string `yaml:"fileSystem"`
+}
+
+// DefaultConfigPath returns the configuration file that is read when none is
+// specified, in the configuration directory of the user, or an empty string
+// when the directory is not known.
+func DefaultConfigPath() string {
+	dir, err := userConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "fresnel", configFileName)
+}
+
+// LoadDistributions reads distribution definitions from the YAML or JSON file
+// at path, and merges them over the built-in distributions. Distributions that
+// are not built in are added. When path is empty, the file at
+// DefaultConfigPath is read if it exists. Every distribution in the file is
+// validated, and none are changed if any of them is invalid.
+func LoadDistributions(path string) error {
+	required := path != ""
+	if !required {
+		if path = DefaultConfigPath(); path == "" {
+			return nil
+		}
+	}
+	b, err := readFile(path)
+	if err != nil {
+		if !required && os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("%w: %v", errConfigFile, err)
+	}
+	cf := configFile{}
+	if err := yaml.UnmarshalStrict(b, &cf); err != nil {
+		return fmt.Errorf("%w: %q: %v", errConfigFile, path, err)
+	}
+	if cf.Proxy != "" {
+		if _, err := parseProxy(cf.Proxy); err != nil {
+			return fmt.Errorf("%w: %q: %v", errConfigFile, path, err)
+		}
+	}
+	merged := make(map[string]distribution, len(distributions)+len(cf.Distributions))
+	for name, d := range distributions {
+		merged[name] = d
+	}
+	for name, dc := range cf.Distributions {
+		d, err := dc.merge(merged[name])
+		if err != nil {
+			return fmt.Errorf("%w: %q: distribution %q: %v", errConfigFile, path, name, err)
+		}
+		if err := validateDistro(d); err != nil {
+			return fmt.Errorf("%w: %q: distribution %q: %v", errConfigFile, path, name, err)
+		}
+		merged[name] = d
+	}
+	distributions = merged
+	fileProxy = cf.Proxy
+	return nil
+}
+
+// merge returns d with the fields that are set in dc replaced. Maps and
+// lists replace those of d entirely rather than being combined with them.
+func (dc distroConfig) merge(d distribution) (distribution, error) {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	if dc.OS != "" {
+		d.os = dc.OS
+	}
+	set(&d.name, dc.Name)
+	set(&d.label, dc.Label)
+	set(&d.imageServer, dc.ImageServer)
+	set(&d.digests, dc.Digests)
+	set(&d.confServer, dc.ConfServer)
+	set(&d.confFile, dc.ConfFile)
+	set(&d.seedServer, dc.SeedServer)
+	if len(dc.SeedFile) > 0 {
+		d.seedFile, d.seedFiles = dc.SeedFile[0], dc.SeedFile[1:]
+	}
+	set(&d.seedDest, dc.SeedDest)
+	set(&d.seedHash, dc.SeedHash)
+	set(&d.signServer, dc.SignServer)
+	set(&d.signedImages, dc.SignedImages)
+	set(&d.dataFileSystem, dc.DataFileSystem)
+	if dc.Images != nil {
+		d.images = dc.Images
+	}
+	if dc.Configs != nil {
+		d.configs = dc.Configs
+	}
+	if dc.Manifest != nil {
+		d.manifest = dc.Manifest
+	}
+	if dc.Mirrors != nil {
+		d.mirrors = dc.Mirrors
+	}
+	if dc.Probe != nil {
+		d.probe = *dc.Probe
+	}
+	if dc.SeedTracks != nil {
+		d.seedTracks = dc.SeedTracks
+	}
+	if dc.SeedFields != nil {
+		d.seedFields = dc.SeedFields
+	}
+	if dc.Partitions != nil {
+		d.partitions = dc.Partitions
+	}
+	if dc.StripDomain != nil {
+		d.stripDomain = *dc.StripDomain
+	}
+	if dc.Checksums != nil {
+		d.checksums = *dc.Checksums
+	}
+	if dc.Apply != nil {
+		d.apply = *dc.Apply
+	}
+	if dc.ApplyIndex != 0 {
+		d.applyIndex = dc.ApplyIndex
+	}
+	if dc.ShelfLife != "" {
+		sl, err := time.ParseDuration(dc.ShelfLife)
+		if err != nil {
+			return d, fmt.Errorf("%w: shelfLife: %v", errInput, err)
+		}
+		d.shelfLife = sl
+	}
+	if dc.Devices != nil {
+		p := DevicePolicy{RemovableOnly: dc.Devices.RemovableOnly, FileSystem: dc.Devices.FileSystem}
+		var err error
+		if dc.Devices.MinSize != "" {
+			if p.MinSize, err = units.Parse(dc.Devices.MinSize, units.GB); err != nil {
+				return d, fmt.Errorf("%w: devices minSize: %v", errInput, err)
+			}
+		}
+		if dc.Devices.MaxSize != "" {
+			if p.MaxSize, err = units.Parse(dc.Devices.MaxSize, units.GB); err != nil {
+				return d, fmt.Errorf("%w: devices maxSize: %v", errInput, err)
+			}
+		}
+		d.devices = p
+	}
+	switch d.os {
+	case windows, linux:
+	default:
+		return d, fmt.Errorf("%w: os %q is not %q or %q", errInput, d.os, windows, linux)
+	}
+	// Images configured as URLs, such as pre-signed URLs, do not require an
+	// image server, which validateDistro checks the other images against.
+	if len(d.images) == 0 {
+		return d, fmt.Errorf("%w: at least one image is required", errInput)
+	}
+	return d, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/units"
+)
+
+func TestDefaultConfigPath(t *testing.T) {
+	defer func() { userConfigDir = os.UserConfigDir }()
+	userConfigDir = func() (string, error) { return filepath.Join("home", "user", ".config"), nil }
+	if got, want := DefaultConfigPath(), filepath.Join("home", "user", ".config", "fresnel", configFileName); got != want {
+		t.Errorf("DefaultConfigPath() got: %q, want: %q", got, want)
+	}
+	userConfigDir = func() (string, error) { return "", errors.New("test") }
+	if got := DefaultConfigPath(); got != "" {
+		t.Errorf("DefaultConfigPath() got: %q, want: %q", got, "")
+	}
+}
+
+func TestLoadDistributions(t *testing.T) {
+	defaults := distributions
+	defer func() {
+		distributions = defaults
+		readFile = ioutil.ReadFile
+		userConfigDir = os.UserConfigDir
+	}()
+	userConfigDir = func() (string, error) { return "config", nil }
+	builtIn := map[string]distribution{
+		"windows": distribution{
+			os:          windows,
+			label:       "INSTALLER",
+			imageServer: "https://images.example.com",
+			images:      map[string]string{"default": "installer.iso"},
+			seedServer:  "https://seed.example.com/seed",
+			seedFile:    "sources/boot.wim",
+			seedDest:    "seed",
+		},
+	}
+	tests := []struct {
+		desc    string
+		path    string
+		content string
+		readErr error
+		check   func(map[string]distribution) error
+		wantErr error
+	}{
+		{
+			desc:    "no default file",
+			readErr: os.ErrNotExist,
+		},
+		{
+			desc:    "missing file",
+			path:    "missing.yaml",
+			readErr: os.ErrNotExist,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "merge over default",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"label": "CORP", "images": {"stable": "stable.iso"}, "stripDomain": true, "checksums": true, "shelfLife": "48h"}}}`,
+			check: func(m map[string]distribution) error {
+				d := m["windows"]
+				if d.label != "CORP" || d.images["stable"] != "stable.iso" || len(d.images) != 1 || !d.stripDomain || !d.checksums || d.shelfLife != 48*time.Hour {
+					return errors.New("fields were not replaced")
+				}
+				if d.seedServer != "https://seed.example.com/seed" || d.imageServer != "https://images.example.com" {
+					return errors.New("unset fields were not retained")
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "new distribution",
+			content: `{"distributions": {"corplinux": {"os": "linux", "imageServer": "https://images.example.com/linux", "images": {"default": "linux.img.gz"}, "devices": {"minSize": "16G", "removableOnly": true}, "partitions": [{"glob": "drivers", "role": "data"}]}}}`,
+			check: func(m map[string]distribution) error {
+				d, ok := m["corplinux"]
+				if !ok {
+					return errors.New("distribution was not added")
+				}
+				if d.devices.MinSize != 16*units.GB || !d.devices.RemovableOnly || len(d.partitions) != 1 {
+					return errors.New("fields were not set")
+				}
+				if _, ok := m["windows"]; !ok {
+					return errors.New("built-in distribution was removed")
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "mirrors",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"mirrors": ["https://mirror.example.com"], "probeMirrors": true}}}`,
+			check: func(m map[string]distribution) error {
+				d := m["windows"]
+				if len(d.mirrors) != 1 || d.mirrors[0] != "https://mirror.example.com" || !d.probe {
+					return errors.New("mirrors were not set")
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "signed images",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"signServer": "https://sign.example.com/sign", "digests": "digests.json", "signedImages": "images/windows"}}}`,
+			check: func(m map[string]distribution) error {
+				if got := m["windows"].signedImages; got != "images/windows" {
+					return fmt.Errorf("signedImages got: %q, want: %q", got, "images/windows")
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "data file system",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"dataFileSystem": "exFAT", "partitions": [{"glob": "sources/install.wim", "role": "data"}]}}}`,
+			check: func(m map[string]distribution) error {
+				if got := m["windows"].dataFileSystem; got != "exFAT" {
+					return fmt.Errorf("dataFileSystem got: %q, want: %q", got, "exFAT")
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "apply",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"apply": true, "applyIndex": 3}}}`,
+			check: func(m map[string]distribution) error {
+				d := m["windows"]
+				if !d.apply || d.applyIndex != 3 {
+					return fmt.Errorf("apply got: %t, %d, want: true, 3", d.apply, d.applyIndex)
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "apply to linux",
+			path:    "distros.yaml",
+			content: `{"distributions": {"corplinux": {"os": "linux", "imageServer": "https://images.example.com/linux", "images": {"default": "linux.img.gz"}, "apply": true}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "negative apply index",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"apply": true, "applyIndex": -1}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "seed file",
+			path:    "distros.yaml",
+			content: "distributions:\n  windows:\n    seedFile: sources/boot.wim\n    seedDest: seed\n",
+			check: func(m map[string]distribution) error {
+				d := m["windows"]
+				if d.seedFile != "sources/boot.wim" || len(d.seedFiles) != 0 {
+					return fmt.Errorf("seed files got: %q, %q", d.seedFile, d.seedFiles)
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "seed file list",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"seedFile": ["sources/boot.wim", "sources/install.wim"], "seedDest": "seed"}}}`,
+			check: func(m map[string]distribution) error {
+				d := m["windows"]
+				if d.seedFile != "sources/boot.wim" || len(d.seedFiles) != 1 || d.seedFiles[0] != "sources/install.wim" {
+					return fmt.Errorf("seed files got: %q, %q", d.seedFile, d.seedFiles)
+				}
+				return nil
+			},
+		},
+		{
+			desc:    "duplicate seed files",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"seedFile": ["sources/boot.wim", "sources/boot.wim"], "seedDest": "seed"}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "invalid seed file",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"seedFile": {"path": "sources/boot.wim"}}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "unknown field",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"imageserver2": "https://images.example.com"}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "new distribution without images",
+			path:    "distros.yaml",
+			content: `{"distributions": {"corplinux": {"os": "linux", "imageServer": "https://images.example.com"}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "unknown os",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"os": "plan9"}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "invalid shelf life",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"shelfLife": "soon"}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "invalid device size",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"devices": {"minSize": "large"}}}}`,
+			wantErr: errConfigFile,
+		},
+		{
+			desc:    "fails validation",
+			path:    "distros.yaml",
+			content: `{"distributions": {"windows": {"manifest": ["release/boot.wim"]}}}`,
+			wantErr: errConfigFile,
+		},
+	}
+	for _, tt := range tests {
+		distributions = builtIn
+		readFile = func(path string) ([]byte, error) {
+			want := tt.path
+			if want == "" {
+				want = filepath.Join("config", "fresnel", configFileName)
+			}
+			if path != want {
+				t.Errorf("%s: readFile() path got: %q, want: %q", tt.desc, path, want)
+			}
+			return []byte(tt.content), tt.readErr
+		}
+		err := LoadDistributions(tt.path)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: LoadDistributions() err got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if err != nil && len(distributions) != len(builtIn) {
+			t.Errorf("%s: LoadDistributions() changed distributions despite an error", tt.desc)
+		}
+		if tt.check != nil {
+			if err := tt.check(distributions); err != nil {
+				t.Errorf("%s: LoadDistributions() %v", tt.desc, err)
+			}
+		}
+	}
+}
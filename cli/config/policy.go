@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// policyRoot is prepended to the paths of policy files, for testing.
+	policyRoot = "/"
+
+	// WritePolicyHelp documents removable media write policies and how to
+	// request an exception to them.
+	WritePolicyHelp = "https://github.com/google/fresnel/blob/main/cli/README.md#removable-media-policy"
+)
+
+// writePolicy identifies configuration files that prevent writing to
+// removable media.
+type writePolicy struct {
+	desc  string            // A description of the policy for users.
+	glob  string            // The files that may contain the policy.
+	match func([]byte) bool // Determines whether a file enforces the policy.
+}
+
+// checkPolicies returns an error wrapping ErrWritePerms for the first policy
+// that is enforced. Files that cannot be read are skipped, as a policy that
+// cannot be read by the user cannot be verified.
+func checkPolicies(policies []writePolicy) error {
+	for _, p := range policies {
+		files, err := filepath.Glob(filepath.Join(policyRoot, p.glob))
+		if err != nil {
+			return fmt.Errorf("filepath.Glob(%q) returned %v", p.glob, err)
+		}
+		for _, f := range files {
+			contents, err := ioutil.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			if p.match(contents) {
+				return fmt.Errorf("%w: %s [%s]", ErrWritePerms, p.desc, f)
+			}
+		}
+	}
+	return nil
+}
+
+// matchLine returns a matcher for files with any uncommented line that
+// matches every one of res.
+func matchLine(res ...*regexp.Regexp) func([]byte) bool {
+	return func(contents []byte) bool {
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if matchEvery(line, res) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchEvery determines whether line matches every one of res.
+func matchEvery(line string, res []*regexp.Regexp) bool {
+	for _, re := range res {
+		if !re.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAll returns a matcher for files that match every one of res.
+func matchAll(res ...*regexp.Regexp) func([]byte) bool {
+	return func(contents []byte) bool {
+		for _, re := range res {
+			if !re.Match(contents) {
+				return false
+			}
+		}
+		return true
+	}
+}
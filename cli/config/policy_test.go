@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestCheckPolicies(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"comment.conf":  "# blacklist usb-storage\n",
+		"enforced.conf": "options foo\nblacklist usb-storage\n",
+		"both.rules":    "subject.action == 'org.freedesktop.udisks2.filesystem-mount'\nreturn polkit.Result.NO;\n",
+		"one.rules":     "subject.action == 'org.freedesktop.udisks2.filesystem-mount'\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", name, err)
+		}
+	}
+	policyRoot = root
+	defer func() { policyRoot = "/" }()
+
+	modprobe := matchLine(regexp.MustCompile(`^blacklist\s+usb[-_]storage\b`))
+	polkit := matchAll(regexp.MustCompile(`udisks2`), regexp.MustCompile(`polkit\.Result\.NO\b`))
+	tests := []struct {
+		desc     string
+		policies []writePolicy
+		want     error
+	}{
+		{
+			desc: "no files",
+			policies: []writePolicy{
+				{desc: "missing", glob: "*.missing", match: modprobe},
+			},
+			want: nil,
+		},
+		{
+			desc: "commented out",
+			policies: []writePolicy{
+				{desc: "comment", glob: "comment.conf", match: modprobe},
+			},
+			want: nil,
+		},
+		{
+			desc: "line enforced",
+			policies: []writePolicy{
+				{desc: "enforced", glob: "*.conf", match: modprobe},
+			},
+			want: ErrWritePerms,
+		},
+		{
+			desc: "partial match",
+			policies: []writePolicy{
+				{desc: "one", glob: "one.rules", match: polkit},
+			},
+			want: nil,
+		},
+		{
+			desc: "all enforced",
+			policies: []writePolicy{
+				{desc: "both", glob: "*.rules", match: polkit},
+			},
+			want: ErrWritePerms,
+		},
+	}
+	for _, tt := range tests {
+		got := checkPolicies(tt.policies)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: checkPolicies() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
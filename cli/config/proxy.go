@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// ProxyPasswordEnv names the environment variable that provides the password
+// for a proxy that names only a user, so that the password need not appear
+// in flags or configuration files.
+const ProxyPasswordEnv = "FRESNEL_PROXY_PASSWORD"
+
+var (
+	// Dependency injections for testing.
+	lookupEnv = os.LookupEnv
+
+	// fileProxy is the proxy set by the configuration file, which is used when
+	// none is provided by flag.
+	fileProxy string
+)
+
+// UseProxy sends every request made to obtain images and seeds through the
+// proxy at rawURL, such as 'http://user@proxy.example.com:3128'. When rawURL
+// is empty, the proxy of the configuration file is used if one is set, and
+// otherwise proxies are selected by the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// environment variables. A proxy that names a user without a password is
+// given the password in ProxyPasswordEnv, if set.
+func (c *Configuration) UseProxy(rawURL string) error {
+	if rawURL == "" {
+		rawURL = fileProxy
+	}
+	if rawURL == "" {
+		c.proxy = ""
+		return nil
+	}
+	u, err := parseProxy(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); !ok {
+			if pw, ok := lookupEnv(ProxyPasswordEnv); ok {
+				u.User = url.UserPassword(u.User.Username(), pw)
+			}
+		}
+	}
+	c.proxy = u.String()
+	return nil
+}
+
+// parseProxy parses and validates the URL of a proxy.
+func parseProxy(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: proxy %q: %v", errInput, rawURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("%w: proxy %q must be an http, https or socks5 URL", errInput, rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: proxy %q has no host", errInput, rawURL)
+	}
+	return u, nil
+}
+
+// Proxy returns the URL of the proxy that requests are sent through, or an
+// empty string when proxies are selected by the environment.
+func (c *Configuration) Proxy() string {
+	return c.proxy
+}
@@ -19,17 +19,16 @@ package console
 
 import (
 	"bufio"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docker/go-units"
 	"github.com/dustin/go-humanize"
-	"github.com/olekukonko/tablewriter"
 )
 
 var (
@@ -82,70 +81,90 @@ type TargetDevice interface {
 	Size() uint64
 }
 
-type rawDevice struct {
-	ID   string
-	Name string
-	Size string
+// ProtectedDevice is a TargetDevice that reports whether it is write
+// protected. Devices that do not implement it are assumed to be writable.
+type ProtectedDevice interface {
+	WriteProtected() bool
 }
 
-// PrintDevices takes a slice of target devices and prints relevant information
-// as a human-readable table to the console. If the json flag
-// is present the target devices will be printed as JSON rather than a table.
-func PrintDevices(targets []TargetDevice, w io.Writer, json bool) {
+// MarkedDevice is a TargetDevice whose media marker was read, which reports
+// when the seed on it expires. The zero time is reported when the marker
+// could not be read or does not record an expiry.
+type MarkedDevice interface {
+	SeedExpiry() time.Time
+}
 
-	if json {
-		Printjson(targets, w)
-		// Return immediately after raw output to ensure the output is proper JSON only.
-		return
-	}
+// deviceColumns are the columns used when printing devices.
+var deviceColumns = []Column{
+	{Title: "Device", Key: "ID"},
+	{Title: "Model", Key: "Name"},
+	{Title: "Size", Key: "Size"},
+	{Title: "Read-Only", Key: "ReadOnly"},
+}
+
+// expiryColumn is added to deviceColumns when the markers of devices were
+// read.
+var expiryColumn = Column{Title: "Seed Expiry", Key: "SeedExpiry"}
 
+// PrintDevices takes a slice of target devices and prints relevant information
+// to the console in the requested format. Formats other than a table are
+// intended to be consumed by other tools, and contain only the devices.
+func PrintDevices(targets []TargetDevice, w io.Writer, format Format) error {
+	fw, err := NewFormatWriter(format)
+	if err != nil {
+		return err
+	}
 	//Check if any devices exist.
-	if len(targets) == 0 {
+	if format == FormatTable && len(targets) == 0 {
 		fmt.Fprintf(w, "No matching devices were found.")
-		return
-	}
-
-	// Display the table to the user otherwise, output devices with table
-	table := tablewriter.NewWriter(w)
-	table.SetBorder(false)
-	table.SetAutoWrapText(false)
-	table.SetHeader([]string{"Device", "Model", "Size"})
-	table.SetHeaderColor(
-		tablewriter.Colors{tablewriter.FgGreenColor}, // Green text for device column.
-		tablewriter.Colors{},                         // No color change for model column.
-		tablewriter.Colors{},                         // No color change for size column.
-	)
+		return nil
+	}
+	marked := len(targets) > 0
+	for _, device := range targets {
+		if _, ok := device.(MarkedDevice); !ok {
+			marked = false
+		}
+	}
+	r := &Report{Columns: deviceColumns}
+	if marked {
+		r.Columns = append(append([]Column{}, deviceColumns...), expiryColumn)
+	}
 	for _, device := range targets {
-		table.Append([]string{
+		row := []string{
 			device.Identifier(),
 			device.FriendlyName(),
 			humanize.Bytes(device.Size()),
-		},
-		)
+			strconv.FormatBool(writeProtected(device)),
+		}
+		if marked {
+			row = append(row, seedExpiry(device.(MarkedDevice)))
+		}
+		r.Rows = append(r.Rows, row)
 	}
-	table.Render()
+	return fw.Write(w, r)
 }
 
-// Printjson takes a slice of target devices and prints relevant information
-// as JSON to the console when the json flag is present on the PrintDevices
-// function.
-func Printjson(targets []TargetDevice, w io.Writer) error {
-
-	result := []rawDevice{}
-	for _, device := range targets {
-		result = append(result, rawDevice{
-			ID:   device.Identifier(),
-			Name: device.FriendlyName(),
-			Size: humanize.Bytes(device.Size()),
-		})
+// seedExpiry describes when the seed on device expires, which is empty when
+// it is unknown.
+func seedExpiry(device MarkedDevice) string {
+	e := device.SeedExpiry()
+	if e.IsZero() {
+		return ""
 	}
+	return e.UTC().Format(time.RFC3339)
+}
 
-	output, err := json.Marshal(result)
-	if err != nil {
-		return err
-	}
-	fmt.Fprintf(w, "%s", output)
-	return nil
+// writeProtected determines whether device reports that it is write
+// protected.
+func writeProtected(device TargetDevice) bool {
+	p, ok := device.(ProtectedDevice)
+	return ok && p.WriteProtected()
+}
+
+// Printjson takes a slice of target devices and prints relevant information
+// as JSON to the console.
+func Printjson(targets []TargetDevice, w io.Writer) error {
+	return PrintDevices(targets, w, FormatJSON)
 }
 
 type progressReader struct {
@@ -169,7 +188,8 @@ type progressReader struct {
 // every 5 seconds. The messages include the supplied human readable operation.
 // The provided length can also be zero if it is unknown ahead of time. A
 // ProgressReader always outputs to the console, regardless of the value of
-// verbose.
+// verbose, unless events are enabled, in which case progress events are
+// emitted instead.
 func ProgressReader(reader io.Reader, operation string, length int64) io.Reader {
 	now := time.Now()
 	if length < 0 {
@@ -191,6 +211,9 @@ func ProgressReader(reader io.Reader, operation string, length int64) io.Reader
 func (pr *progressReader) Read(p []byte) (int, error) {
 	n, err := pr.reader.Read(p)
 	if err != nil {
+		if err == io.EOF {
+			pr.emit()
+		}
 		return n, err
 	}
 
@@ -203,6 +226,10 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 
 	// Prepare to log progress.
 	pr.lastLog = now
+	if EventsEnabled() {
+		pr.emit()
+		return n, nil
+	}
 	length := float64(pr.length) // in bytes.
 	read := float64(pr.read)     // in bytes.
 
@@ -244,3 +271,13 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 
 	return n, nil
 }
+
+// emit reports the progress of the read as an event.
+func (pr *progressReader) emit() {
+	Emit(Event{
+		Type:      EventProgress,
+		Operation: strings.TrimSpace(pr.operation),
+		Bytes:     pr.read,
+		Total:     pr.length,
+	})
+}
@@ -16,8 +16,13 @@ package console
 
 import (
 	"bytes"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/schema"
 )
 
 // fakeDevice inherits all members of target.Device through embedding.
@@ -42,6 +47,25 @@ func (f *fakeDevice) Size() uint64 {
 	return f.size
 }
 
+// markedDevice is a fakeDevice whose marker was read.
+type markedDevice struct {
+	fakeDevice
+	expiry time.Time
+}
+
+func (m *markedDevice) SeedExpiry() time.Time {
+	return m.expiry
+}
+
+// protectedDevice is a fakeDevice that is write protected.
+type protectedDevice struct {
+	fakeDevice
+}
+
+func (*protectedDevice) WriteProtected() bool {
+	return true
+}
+
 func TestPrintDevices(t *testing.T) {
 	deviceOne := &fakeDevice{
 		id:           "drive1",
@@ -61,51 +85,94 @@ func TestPrintDevices(t *testing.T) {
 	tests := []struct {
 		desc    string
 		devices []TargetDevice
-		json    bool
+		format  Format
 		want    string
 	}{
 		{
 			desc:    "no devices",
 			devices: []TargetDevice{},
-			json:    false,
+			format:  FormatTable,
 			want:    "No matching devices were found.",
 		},
 		{
 			desc:    "no devices with json",
 			devices: []TargetDevice{},
-			json:    true,
+			format:  FormatJSON,
 			want:    "[]",
 		},
 		{
 			desc:    "one device",
 			devices: []TargetDevice{deviceOne},
-			json:    false,
+			format:  FormatTable,
 			want:    deviceOne.Identifier(),
 		},
 		{
 			desc:    "one device with json",
 			devices: []TargetDevice{deviceOne},
-			json:    true,
+			format:  FormatJSON,
 			want:    "[{\"ID\":\"" + deviceOne.Identifier(),
 		},
 		{
 			desc:    "two devices",
 			devices: []TargetDevice{deviceOne, deviceTwo},
-			json:    false,
+			format:  FormatTable,
 			want:    deviceTwo.Identifier(),
 		},
 		{
 			desc:    "three devices",
 			devices: []TargetDevice{deviceOne, deviceTwo, deviceThree},
-			json:    false,
+			format:  FormatTable,
 			want:    deviceThree.Identifier(),
 		},
+		{
+			desc:    "two devices with csv",
+			devices: []TargetDevice{deviceOne, deviceTwo},
+			format:  FormatCSV,
+			want:    "ID,Name,Size,ReadOnly\ndrive1,foo super duper drive,",
+		},
+		{
+			desc:    "write protected device with csv",
+			devices: []TargetDevice{&protectedDevice{fakeDevice: *deviceOne}, deviceTwo},
+			format:  FormatCSV,
+			want:    "GB,true\ndrive2,bar bodacious drive,10 GB,false\n",
+		},
+		{
+			desc:    "marked devices with csv",
+			devices: []TargetDevice{&markedDevice{fakeDevice: *deviceOne, expiry: time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)}, &markedDevice{fakeDevice: *deviceTwo}},
+			format:  FormatCSV,
+			want:    "ID,Name,Size,ReadOnly,SeedExpiry\ndrive1,foo super duper drive,1.1 GB,false,2026-12-01T00:00:00Z\ndrive2,bar bodacious drive,10 GB,false,\n",
+		},
+		{
+			desc:    "one device with yaml",
+			devices: []TargetDevice{deviceOne},
+			format:  FormatYAML,
+			want:    "- ID: drive1\n  Name: foo super duper drive\n",
+		},
 	}
 	for _, tt := range tests {
 		var got bytes.Buffer
-		PrintDevices(tt.devices, &got, tt.json)
+		if err := PrintDevices(tt.devices, &got, tt.format); err != nil {
+			t.Errorf("%s: PrintDevices() returned %v", tt.desc, err)
+		}
 		if !strings.Contains(got.String(), tt.want) {
 			t.Errorf("%s: PrintDevices() got = %q, must contain = %q", tt.desc, got.String(), tt.want)
 		}
 	}
 }
+
+// TestDeviceSchema checks that the devices schema describes every column of
+// the device list.
+func TestDeviceSchema(t *testing.T) {
+	var want []string
+	for _, c := range append(deviceColumns, expiryColumn) {
+		want = append(want, c.Key)
+	}
+	sort.Strings(want)
+	got, err := schema.Properties(schema.Devices)
+	if err != nil {
+		t.Fatalf("schema.Properties(%q) returned %v", schema.Devices, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("devices schema properties got: %v, want: %v", got, want)
+	}
+}
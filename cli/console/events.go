@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// The statuses reported by events that mark the start and end of a step.
+const (
+	StatusStarted   = "started"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	// StatusWarning reports a step that failed without failing the command,
+	// such as ejecting a device that was provisioned successfully.
+	StatusWarning = "warning"
+)
+
+// The types of events that are not named after the step that they describe.
+const (
+	// EventProgress reports the progress of a long running read, such as a
+	// download, write or verification.
+	EventProgress = "progress"
+	// EventTargets lists the devices that are about to be provisioned.
+	EventTargets = "targets"
+	// EventResult reports the outcome for a single device.
+	EventResult = "result"
+	// EventComplete reports the outcome of the whole command.
+	EventComplete = "complete"
+)
+
+var (
+	// events receives events as newline-delimited JSON when enabled.
+	events   io.Writer
+	eventsMu sync.Mutex
+
+	// now is replaced for testing.
+	now = time.Now
+)
+
+// Event is a structured description of the progress of a command, for
+// consumption by automation wrappers. Fields that do not apply to the Type of
+// an event are omitted.
+type Event struct {
+	Time      time.Time         `json:"time"`
+	Type      string            `json:"type"`
+	Status    string            `json:"status,omitempty"`
+	Device    string            `json:"device,omitempty"`
+	Operation string            `json:"operation,omitempty"`
+	Bytes     int64             `json:"bytes,omitempty"`
+	Total     int64             `json:"total,omitempty"`
+	Devices   []string          `json:"devices,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Warnings  []string          `json:"warnings,omitempty"`
+}
+
+// EnableEvents writes each event emitted from now on to w as a line of JSON,
+// and silences the human-readable messages that would otherwise be mixed in
+// with them, including progress bars.
+func EnableEvents(w io.Writer) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	events = w
+	Verbose = true
+}
+
+// DisableEvents stops events from being written.
+func DisableEvents() {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	events = nil
+}
+
+// EventsEnabled returns whether events are being written.
+func EventsEnabled() bool {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	return events != nil
+}
+
+// Emit writes e when events are enabled, and otherwise does nothing. The time
+// of the event is set when it is not provided.
+func Emit(e Event) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if events == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	events.Write(append(b, '\n'))
+}
+
+// EmitStep reports the end of the step typ for device, as failed when err is
+// set and otherwise as completed.
+func EmitStep(typ, device string, err error) {
+	e := Event{Type: typ, Status: StatusCompleted, Device: device}
+	if err != nil {
+		e.Status = StatusFailed
+		e.Error = err.Error()
+	}
+	Emit(e)
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/schema"
+)
+
+func TestEmit(t *testing.T) {
+	verbose := Verbose
+	defer func() { Verbose = verbose; now = time.Now; DisableEvents() }()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return at }
+
+	// Events are discarded until they are enabled.
+	Emit(Event{Type: "prepare"})
+	var b bytes.Buffer
+	EnableEvents(&b)
+	if !Verbose || !EventsEnabled() {
+		t.Errorf("EnableEvents() got Verbose: %t, EventsEnabled(): %t, want: true, true", Verbose, EventsEnabled())
+	}
+	Emit(Event{Type: "prepare", Status: StatusStarted, Device: "sdb"})
+	EmitStep("prepare", "sdb", nil)
+	EmitStep("provision", "sdb", errors.New("error"))
+
+	want := []Event{
+		{Time: at, Type: "prepare", Status: StatusStarted, Device: "sdb"},
+		{Time: at, Type: "prepare", Status: StatusCompleted, Device: "sdb"},
+		{Time: at, Type: "provision", Status: StatusFailed, Device: "sdb", Error: "error"},
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("Emit() wrote %d lines, want: %d\n%s", len(lines), len(want), b.String())
+	}
+	for n, line := range lines {
+		got := Event{}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Errorf("Emit() line %d %q is not JSON: %v", n, line, err)
+			continue
+		}
+		if !got.Time.Equal(want[n].Time) || got.Type != want[n].Type || got.Status != want[n].Status || got.Device != want[n].Device || got.Error != want[n].Error {
+			t.Errorf("Emit() line %d got: %+v, want: %+v", n, got, want[n])
+		}
+	}
+}
+
+func TestProgressReaderEvents(t *testing.T) {
+	verbose := Verbose
+	defer func() { Verbose = verbose; DisableEvents() }()
+	var b bytes.Buffer
+	EnableEvents(&b)
+	r := ProgressReader(strings.NewReader("test content"), "\nDownload of test.iso", 12)
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		t.Fatalf("io.Copy() returned %v", err)
+	}
+	got := Event{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("ProgressReader() wrote %q, want a single event: %v", b.String(), err)
+	}
+	if got.Type != EventProgress || got.Operation != "Download of test.iso" || got.Bytes != 12 || got.Total != 12 {
+		t.Errorf("ProgressReader() got: %+v, want a progress event for 12 of 12 bytes of 'Download of test.iso'", got)
+	}
+}
+
+// TestEventSchema checks that the events schema describes every field of an
+// event, so that fields added to events are also added to the schema.
+func TestEventSchema(t *testing.T) {
+	b, err := json.Marshal(Event{
+		Time: time.Now(), Type: "t", Status: "s", Device: "d", Operation: "o", Bytes: 1, Total: 1,
+		Devices: []string{"d"}, Details: map[string]string{"k": "v"}, Error: "e", Warnings: []string{"w"},
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() returned %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("json.Unmarshal() returned %v", err)
+	}
+	var want []string
+	for f := range fields {
+		want = append(want, f)
+	}
+	sort.Strings(want)
+	got, err := schema.Properties(schema.Events)
+	if err != nil {
+		t.Fatalf("schema.Properties(%q) returned %v", schema.Events, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events schema properties got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v2"
+)
+
+// Format identifies an output format for structured results, such as the
+// devices found by a search.
+type Format string
+
+// Supported output formats.
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+	FormatYAML  Format = "yaml"
+)
+
+var (
+	// Formats lists the supported output formats.
+	Formats = []Format{FormatTable, FormatJSON, FormatCSV, FormatYAML}
+
+	// Wrapped errors for testing.
+	errFormat = errors.New("unsupported output format")
+)
+
+// ParseFormat returns the Format named by s, ignoring case.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q is not one of %v", errFormat, s, Formats)
+}
+
+// Column describes a column of a Report. Title is used as the table header,
+// and Key names the value in JSON, YAML and CSV output.
+type Column struct {
+	Title string
+	Key   string
+}
+
+// Report is a set of structured results, made up of rows of values for each
+// of its columns.
+type Report struct {
+	Columns []Column
+	Rows    [][]string
+}
+
+// FormatWriter writes a Report in a particular format.
+type FormatWriter interface {
+	Write(io.Writer, *Report) error
+}
+
+// NewFormatWriter returns the FormatWriter for the provided format.
+func NewFormatWriter(f Format) (FormatWriter, error) {
+	switch f {
+	case FormatTable:
+		return tableWriter{}, nil
+	case FormatJSON:
+		return jsonWriter{}, nil
+	case FormatCSV:
+		return csvWriter{}, nil
+	case FormatYAML:
+		return yamlWriter{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", errFormat, f)
+}
+
+// tableWriter writes a report as a human-readable table.
+type tableWriter struct{}
+
+func (tableWriter) Write(w io.Writer, r *Report) error {
+	table := tablewriter.NewWriter(w)
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	var header []string
+	var colors []tablewriter.Colors
+	for i, c := range r.Columns {
+		header = append(header, c.Title)
+		// The first column, which identifies each row, is green.
+		if i == 0 {
+			colors = append(colors, tablewriter.Colors{tablewriter.FgGreenColor})
+			continue
+		}
+		colors = append(colors, tablewriter.Colors{})
+	}
+	table.SetHeader(header)
+	table.SetHeaderColor(colors...)
+	table.AppendBulk(r.Rows)
+	table.Render()
+	return nil
+}
+
+// jsonWriter writes a report as a JSON array with an object for each row.
+// Keys are written in column order.
+type jsonWriter struct{}
+
+func (jsonWriter) Write(w io.Writer, r *Report) error {
+	var b bytes.Buffer
+	b.WriteString("[")
+	for i, row := range r.Rows {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("{")
+		for j, c := range r.Columns {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			k, err := json.Marshal(c.Key)
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(row[j])
+			if err != nil {
+				return err
+			}
+			b.Write(k)
+			b.WriteString(":")
+			b.Write(v)
+		}
+		b.WriteString("}")
+	}
+	b.WriteString("]")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// csvWriter writes a report as CSV, with a header row of column keys.
+type csvWriter struct{}
+
+func (csvWriter) Write(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, c := range r.Columns {
+		header = append(header, c.Key)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// yamlWriter writes a report as a YAML sequence with a mapping for each row.
+// Keys are written in column order.
+type yamlWriter struct{}
+
+func (yamlWriter) Write(w io.Writer, r *Report) error {
+	rows := []yaml.MapSlice{}
+	for _, row := range r.Rows {
+		m := yaml.MapSlice{}
+		for j, c := range r.Columns {
+			m = append(m, yaml.MapItem{Key: c.Key, Value: row[j]})
+		}
+		rows = append(rows, m)
+	}
+	out, err := yaml.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
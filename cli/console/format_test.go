@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package console
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr error
+	}{
+		{in: "table", want: FormatTable},
+		{in: "JSON", want: FormatJSON},
+		{in: "csv", want: FormatCSV},
+		{in: "yaml", want: FormatYAML},
+		{in: "xml", wantErr: errFormat},
+		{in: "", wantErr: errFormat},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseFormat(%q) returned %v, want: %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseFormat(%q) got: %q, want: %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatWriters(t *testing.T) {
+	report := &Report{
+		Columns: []Column{{Title: "Device", Key: "ID"}, {Title: "Note", Key: "Note"}},
+		Rows:    [][]string{{"disk1", "has, a comma"}, {"disk2", `has "quotes"`}},
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: FormatJSON,
+			want:   `[{"ID":"disk1","Note":"has, a comma"},{"ID":"disk2","Note":"has \"quotes\""}]`,
+		},
+		{
+			format: FormatCSV,
+			want:   "ID,Note\ndisk1,\"has, a comma\"\ndisk2,\"has \"\"quotes\"\"\"\n",
+		},
+	}
+	for _, tt := range tests {
+		fw, err := NewFormatWriter(tt.format)
+		if err != nil {
+			t.Fatalf("NewFormatWriter(%q) returned %v", tt.format, err)
+		}
+		var got bytes.Buffer
+		if err := fw.Write(&got, report); err != nil {
+			t.Errorf("%s: Write() returned %v", tt.format, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: Write() got: %q, want: %q", tt.format, got.String(), tt.want)
+		}
+	}
+	if _, err := NewFormatWriter("xml"); !errors.Is(err, errFormat) {
+		t.Errorf("NewFormatWriter(%q) returned %v, want: %v", "xml", err, errFormat)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug provides named categories of debugging log messages, so that
+// the messages needed to troubleshoot one area can be enabled without
+// raising the verbosity of every other area.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/deck"
+)
+
+// Category names a group of related debugging log messages.
+type Category string
+
+// Categories of debugging log messages.
+const (
+	// Network covers connections, downloads and requests to the seed and sign
+	// servers.
+	Network Category = "network"
+	// Storage covers the preparation, partitioning and mounting of devices.
+	Storage Category = "storage"
+	// Seed covers the seeds, manifests and markers written to media.
+	Seed Category = "seed"
+	// Copy covers the files copied from images to devices.
+	Copy Category = "copy"
+
+	// all enables every category.
+	all = "all"
+)
+
+var (
+	// Categories lists every category, in the order they are documented.
+	Categories = []Category{Network, Storage, Seed, Copy}
+
+	// enabled holds the categories enabled by Enable.
+	enabled = make(map[Category]bool)
+
+	// ErrCategory is returned when an unknown category is named.
+	ErrCategory = errors.New("unknown debug category")
+)
+
+// Enable enables the categories named in list, which is comma separated, such
+// as 'network,storage'. 'all' enables every category. Categories that are not
+// named are disabled, so an empty list disables them all.
+func Enable(list string) error {
+	e := make(map[Category]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == all {
+			for _, c := range Categories {
+				e[c] = true
+			}
+			continue
+		}
+		if !known(Category(name)) {
+			return fmt.Errorf("%w: %q, must be one of %s or %s", ErrCategory, name, Names(), all)
+		}
+		e[Category(name)] = true
+	}
+	enabled = e
+	return nil
+}
+
+// Enabled returns whether the category c is enabled.
+func Enabled(c Category) bool {
+	return enabled[c]
+}
+
+// Names returns the names of every category, comma separated.
+func Names() string {
+	names := make([]string, len(Categories))
+	for i, c := range Categories {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
+
+// V is a deck attribute that records c as the category of a message, and
+// sets its verbosity to v. Messages in enabled categories are logged at the
+// default verbosity regardless of v, so that they are included without
+// raising the verbosity of unrelated messages.
+//
+//	deck.InfofA("Requesting seed from %q.", server).With(debug.V(debug.Seed, 2)).Go()
+func V(c Category, v int) func(*deck.AttribStore) {
+	return func(a *deck.AttribStore) {
+		a.Store("Category", string(c))
+		if enabled[c] && v > 1 {
+			v = 1
+		}
+		deck.V(v)(a)
+	}
+}
+
+func known(c Category) bool {
+	for _, k := range Categories {
+		if k == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/deck"
+)
+
+func TestEnable(t *testing.T) {
+	tests := []struct {
+		desc    string
+		list    string
+		want    []Category
+		wantErr error
+	}{
+		{
+			desc: "none",
+		},
+		{
+			desc: "one",
+			list: "network",
+			want: []Category{Network},
+		},
+		{
+			desc: "several with spaces and case",
+			list: "Network, storage,",
+			want: []Category{Network, Storage},
+		},
+		{
+			desc: "all",
+			list: "all",
+			want: Categories,
+		},
+		{
+			desc:    "unknown",
+			list:    "network,disks",
+			wantErr: ErrCategory,
+		},
+	}
+	for _, tt := range tests {
+		if err := Enable("copy"); err != nil {
+			t.Fatalf("%s: Enable(copy) returned %v", tt.desc, err)
+		}
+		err := Enable(tt.list)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Enable(%q) err: %v, want: %v", tt.desc, tt.list, err, tt.wantErr)
+		}
+		if err != nil {
+			// A failed Enable leaves the enabled categories unchanged.
+			if !Enabled(Copy) {
+				t.Errorf("%s: Enable(%q) changed the enabled categories on error", tt.desc, tt.list)
+			}
+			continue
+		}
+		want := make(map[Category]bool)
+		for _, c := range tt.want {
+			want[c] = true
+		}
+		for _, c := range Categories {
+			if Enabled(c) != want[c] {
+				t.Errorf("%s: Enabled(%q) got: %t, want: %t", tt.desc, c, Enabled(c), want[c])
+			}
+		}
+	}
+	Enable("")
+}
+
+func TestV(t *testing.T) {
+	tests := []struct {
+		desc    string
+		enabled string
+		v       int
+		want    int
+	}{
+		{desc: "disabled", v: 3, want: 3},
+		{desc: "enabled", enabled: "seed", v: 3, want: 1},
+		{desc: "enabled below default", enabled: "seed", v: 0, want: 0},
+		{desc: "other category enabled", enabled: "copy", v: 2, want: 2},
+	}
+	for _, tt := range tests {
+		if err := Enable(tt.enabled); err != nil {
+			t.Fatalf("%s: Enable(%q) returned %v", tt.desc, tt.enabled, err)
+		}
+		a := &deck.AttribStore{}
+		V(Seed, tt.v)(a)
+		if got, _ := a.Load("Verbosity"); got != tt.want {
+			t.Errorf("%s: V(seed, %d) verbosity got: %v, want: %d", tt.desc, tt.v, got, tt.want)
+		}
+		if got, _ := a.Load("Category"); got != string(Seed) {
+			t.Errorf("%s: V(seed, %d) category got: %v, want: %q", tt.desc, tt.v, got, Seed)
+		}
+	}
+	Enable("")
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diskimage provides readers for virtual disk image formats, allowing
+// their contents to be written to a device as a raw disk without a separate
+// conversion step.
+package diskimage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	// Wrapped errors for testing.
+	errCorrupt     = errors.New("image is corrupt")
+	errFormat      = errors.New("unknown image format")
+	errUnsupported = errors.New("unsupported image feature")
+)
+
+// Image is a virtual disk image whose contents are read as a raw disk.
+type Image interface {
+	io.ReaderAt
+	io.Closer
+	// Size returns the size of the virtual disk in bytes.
+	Size() int64
+}
+
+// Open opens the virtual disk image at path. The format is determined from
+// the contents of the file rather than its extension. VHD (fixed and dynamic),
+// VHDX and qcow2 images are supported. Differencing images, and images that
+// depend on a backing file, are not.
+func Open(path string) (Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q) returned %w", path, err)
+	}
+	img, err := open(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%q: %w", path, err)
+	}
+	return img, nil
+}
+
+// file represents the source file of an image.
+type file interface {
+	io.ReaderAt
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// open detects the format of f and returns the appropriate reader.
+func open(f file) (Image, error) {
+	magic := make([]byte, 8)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	switch {
+	case bytes.Equal(magic[:4], qcow2Magic):
+		return openQCOW2(f)
+	case bytes.Equal(magic, vhdxMagic):
+		return openVHDX(f)
+	case bytes.Equal(magic, vhdCookie):
+		// Dynamic VHDs begin with a copy of the footer.
+		return openVHD(f)
+	}
+	// Fixed VHDs are raw disks with only a footer.
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Stat() returned %w", err)
+	}
+	if info.Size() >= vhdFooterSize {
+		if _, err := f.ReadAt(magic, info.Size()-vhdFooterSize); err != nil {
+			return nil, fmt.Errorf("reading footer: %w", err)
+		}
+		if bytes.Equal(magic, vhdCookie) {
+			return openVHD(f)
+		}
+	}
+	return nil, errFormat
+}
+
+// blockImage implements Image for formats that map the virtual disk in fixed
+// size blocks.
+type blockImage struct {
+	f         file
+	size      int64
+	blockSize int64
+	// readBlock fills p from offset off within block idx. p never extends past
+	// the end of the block.
+	readBlock func(idx, off int64, p []byte) error
+}
+
+// Size returns the size of the virtual disk in bytes.
+func (b *blockImage) Size() int64 {
+	return b.size
+}
+
+// Close closes the underlying file.
+func (b *blockImage) Close() error {
+	return b.f.Close()
+}
+
+// ReadAt implements io.ReaderAt over the virtual disk.
+func (b *blockImage) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	var err error
+	if remaining := b.size - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+		err = io.EOF
+	}
+	n := 0
+	for n < len(p) {
+		idx := (off + int64(n)) / b.blockSize
+		inBlock := (off + int64(n)) % b.blockSize
+		chunk := p[n:]
+		if left := b.blockSize - inBlock; int64(len(chunk)) > left {
+			chunk = chunk[:left]
+		}
+		if rerr := b.readBlock(idx, inBlock, chunk); rerr != nil {
+			return n, rerr
+		}
+		n += len(chunk)
+	}
+	return n, err
+}
+
+// readFull reads exactly len(p) bytes from f at off.
+func readFull(f io.ReaderAt, p []byte, off int64) error {
+	n, err := f.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == io.EOF || err == nil {
+		return fmt.Errorf("%w: short read of %d bytes at offset %d", errCorrupt, len(p), off)
+	}
+	return err
+}
+
+// zero sets all bytes of p to zero.
+func zero(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+}
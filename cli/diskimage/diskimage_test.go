@@ -0,0 +1,364 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskimage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testDisk returns the contents of a 4MB virtual disk, made up of a block of
+// data, a block of zeros, another block of data and a final block with only a
+// little data at its start.
+func testDisk() []byte {
+	d := make([]byte, 4*vhdxMB)
+	for i := 0; i < vhdxMB; i++ {
+		d[i] = byte(i % 251)
+		d[2*vhdxMB+i] = byte(i % 13)
+	}
+	copy(d[3*vhdxMB:], []byte("end of disk"))
+	return d
+}
+
+// writeTemp writes b to a temporary file and returns its path.
+func writeTemp(t *testing.T, dir string, b []byte) string {
+	t.Helper()
+	f, err := ioutil.TempFile(dir, "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempFile() returned %v`, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	return f.Name()
+}
+
+// vhdFooterBytes returns a VHD footer for a disk of size bytes.
+func vhdFooterBytes(size uint64, diskType uint32, dataOffset uint64) []byte {
+	b := make([]byte, vhdFooterSize)
+	copy(b, vhdCookie)
+	binary.BigEndian.PutUint32(b[12:], 0x00010000)
+	binary.BigEndian.PutUint64(b[16:], dataOffset)
+	binary.BigEndian.PutUint64(b[40:], size)
+	binary.BigEndian.PutUint64(b[48:], size)
+	binary.BigEndian.PutUint32(b[60:], diskType)
+	binary.BigEndian.PutUint32(b[64:], vhdChecksum(b, 64))
+	return b
+}
+
+// fixedVHD returns a fixed VHD of disk.
+func fixedVHD(disk []byte) []byte {
+	return append(append([]byte{}, disk...), vhdFooterBytes(uint64(len(disk)), vhdFixed, ^uint64(0))...)
+}
+
+// dynamicVHD returns a dynamic VHD of disk with 1MB blocks. Blocks that only
+// contain zeros are left unallocated.
+func dynamicVHD(disk []byte) []byte {
+	const blockSize = vhdxMB
+	entries := len(disk) / blockSize
+	footer := vhdFooterBytes(uint64(len(disk)), vhdDynamic, vhdFooterSize)
+
+	h := make([]byte, vhdDynHeaderSize)
+	copy(h, vhdDynCookie)
+	binary.BigEndian.PutUint64(h[8:], ^uint64(0))
+	binary.BigEndian.PutUint64(h[16:], vhdFooterSize+vhdDynHeaderSize)
+	binary.BigEndian.PutUint32(h[24:], 0x00010000)
+	binary.BigEndian.PutUint32(h[28:], uint32(entries))
+	binary.BigEndian.PutUint32(h[32:], blockSize)
+	binary.BigEndian.PutUint32(h[36:], vhdChecksum(h, 36))
+
+	img := append(append([]byte{}, footer...), h...)
+	batOffset := len(img)
+	img = append(img, make([]byte, (entries*4+511)/512*512)...)
+	for i := 0; i < entries; i++ {
+		block := disk[i*blockSize : (i+1)*blockSize]
+		if bytes.Equal(block, make([]byte, blockSize)) {
+			binary.BigEndian.PutUint32(img[batOffset+i*4:], vhdUnallocated)
+			continue
+		}
+		binary.BigEndian.PutUint32(img[batOffset+i*4:], uint32(len(img)/vhdSectorSize))
+		// A 1MB block has a 256 byte bitmap, padded to one sector.
+		img = append(img, bytes.Repeat([]byte{0xff}, vhdSectorSize)...)
+		img = append(img, block...)
+	}
+	return append(img, footer...)
+}
+
+// setVHDXChecksum sets the CRC-32C checksum of a VHDX structure.
+func setVHDXChecksum(b []byte) {
+	binary.LittleEndian.PutUint32(b[4:8], 0)
+	binary.LittleEndian.PutUint32(b[4:8], crc32.Checksum(b, crc32c))
+}
+
+// testVHDX returns a VHDX of disk with 1MB blocks. Blocks that only contain
+// zeros are marked as zero blocks.
+func testVHDX(disk []byte) []byte {
+	const (
+		metaOffset = 1 * vhdxMB
+		batOffset  = 2 * vhdxMB
+		dataOffset = 3 * vhdxMB
+	)
+	img := make([]byte, dataOffset)
+	copy(img, vhdxMagic)
+
+	h := make([]byte, vhdxHeaderSize)
+	copy(h, vhdxHeaderSig)
+	binary.LittleEndian.PutUint64(h[8:], 1)
+	binary.LittleEndian.PutUint16(h[66:], 1)
+	setVHDXChecksum(h)
+	copy(img[vhdxHeaderOffsets[0]:], h)
+	// The second header is older and should be ignored.
+	binary.LittleEndian.PutUint64(h[8:], 0)
+	copy(h[48:64], bytes.Repeat([]byte{1}, 16))
+	setVHDXChecksum(h)
+	copy(img[vhdxHeaderOffsets[1]:], h)
+
+	r := make([]byte, vhdxRegionTableSize)
+	copy(r, vhdxRegionSig)
+	binary.LittleEndian.PutUint32(r[8:], 2)
+	copy(r[16:], vhdxBATRegion)
+	binary.LittleEndian.PutUint64(r[32:], batOffset)
+	binary.LittleEndian.PutUint32(r[40:], vhdxMB)
+	copy(r[48:], vhdxMetadataRegion)
+	binary.LittleEndian.PutUint64(r[64:], metaOffset)
+	binary.LittleEndian.PutUint32(r[72:], vhdxMB)
+	setVHDXChecksum(r)
+	copy(img[vhdxRegionOffsets[0]:], r)
+	copy(img[vhdxRegionOffsets[1]:], r)
+
+	m := img[metaOffset : metaOffset+vhdxMB]
+	copy(m, vhdxMetadataSig)
+	binary.LittleEndian.PutUint16(m[10:], 3)
+	items := []struct {
+		id   []byte
+		data []byte
+	}{
+		{vhdxFileParameters, []byte{0, 0, 0x10, 0, 0, 0, 0, 0}},
+		{vhdxVirtualSize, make([]byte, 8)},
+		{vhdxLogicalSector, []byte{0, 2, 0, 0}},
+	}
+	binary.LittleEndian.PutUint64(items[1].data, uint64(len(disk)))
+	for i, item := range items {
+		e := m[32+i*32:]
+		off := 64*1024 + i*8
+		copy(e, item.id)
+		binary.LittleEndian.PutUint32(e[16:], uint32(off))
+		binary.LittleEndian.PutUint32(e[20:], uint32(len(item.data)))
+		copy(m[off:], item.data)
+	}
+
+	for i := 0; i < len(disk)/vhdxMB; i++ {
+		block := disk[i*vhdxMB : (i+1)*vhdxMB]
+		state := uint64(vhdxBlockZero)
+		if !bytes.Equal(block, make([]byte, vhdxMB)) {
+			state = vhdxBlockFullyPresent | uint64(len(img)/vhdxMB)<<20
+			img = append(img, block...)
+		}
+		binary.LittleEndian.PutUint64(img[batOffset+i*8:], state)
+	}
+	return img
+}
+
+// testQCOW2 returns a version 3 qcow2 image of disk with 64KB clusters. Zero
+// clusters are left unallocated, the first cluster is compressed and the
+// remainder are stored as is.
+func testQCOW2(t *testing.T, disk []byte) []byte {
+	t.Helper()
+	const (
+		clusterBits = 16
+		clusterSize = 1 << clusterBits
+		l1Offset    = clusterSize
+		l2Offset    = 2 * clusterSize
+	)
+	clusters := len(disk) / clusterSize
+	img := make([]byte, 3*clusterSize)
+	copy(img, qcow2Magic)
+	binary.BigEndian.PutUint32(img[4:], 3)
+	binary.BigEndian.PutUint32(img[20:], clusterBits)
+	binary.BigEndian.PutUint64(img[24:], uint64(len(disk)))
+	binary.BigEndian.PutUint32(img[36:], 1)
+	binary.BigEndian.PutUint64(img[40:], l1Offset)
+	binary.BigEndian.PutUint32(img[100:], 104)
+	binary.BigEndian.PutUint64(img[l1Offset:], l2Offset|1<<63)
+
+	for i := 0; i < clusters; i++ {
+		c := disk[i*clusterSize : (i+1)*clusterSize]
+		var e uint64
+		switch {
+		case bytes.Equal(c, make([]byte, clusterSize)):
+			// Leave the cluster unallocated.
+		case i == 0:
+			var buf bytes.Buffer
+			w, err := flate.NewWriter(&buf, flate.BestCompression)
+			if err != nil {
+				t.Fatalf("flate.NewWriter() returned %v", err)
+			}
+			w.Write(c)
+			w.Close()
+			// Start the compressed data mid-sector to exercise offset handling.
+			img = append(img, make([]byte, 100)...)
+			host := uint64(len(img))
+			sectors := (host%512 + uint64(buf.Len()) + 511) / 512
+			x := uint(62 - (clusterBits - 8))
+			e = qcow2Compressed | (sectors-1)<<x | host
+			img = append(img, buf.Bytes()...)
+			img = append(img, make([]byte, (clusterSize-len(img)%clusterSize)%clusterSize)...)
+		default:
+			e = uint64(len(img)) | 1<<63
+			img = append(img, c...)
+		}
+		binary.BigEndian.PutUint64(img[l2Offset+i*8:], e)
+	}
+	return img
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskimage")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir() returned %v`, err)
+	}
+	defer os.RemoveAll(dir)
+	disk := testDisk()
+
+	badChecksum := fixedVHD(disk)
+	badChecksum[len(badChecksum)-1] ^= 0xff
+	differencing := append(append([]byte{}, disk...), vhdFooterBytes(uint64(len(disk)), vhdDifferencing, 0)...)
+	backing := testQCOW2(t, disk)
+	binary.BigEndian.PutUint64(backing[8:], 4096)
+	// Hostile qcow2 headers, which must be rejected before the L1 table they
+	// describe is allocated.
+	hugeL1 := testQCOW2(t, disk)
+	binary.BigEndian.PutUint64(hugeL1[24:], 1<<49)
+	binary.BigEndian.PutUint32(hugeL1[36:], 1<<20)
+	overflow := testQCOW2(t, disk)
+	binary.BigEndian.PutUint64(overflow[24:], 1<<63-1)
+	binary.BigEndian.PutUint32(overflow[36:], 1<<32-1)
+	negative := testQCOW2(t, disk)
+	binary.BigEndian.PutUint64(negative[24:], 1<<64-1)
+	l1Offset := testQCOW2(t, disk)
+	binary.BigEndian.PutUint64(l1Offset[40:], 1<<62)
+
+	tests := []struct {
+		desc  string
+		image []byte
+		want  error
+	}{
+		{
+			desc:  "fixed vhd",
+			image: fixedVHD(disk),
+			want:  nil,
+		},
+		{
+			desc:  "dynamic vhd",
+			image: dynamicVHD(disk),
+			want:  nil,
+		},
+		{
+			desc:  "vhdx",
+			image: testVHDX(disk),
+			want:  nil,
+		},
+		{
+			desc:  "qcow2",
+			image: testQCOW2(t, disk),
+			want:  nil,
+		},
+		{
+			desc:  "unknown format",
+			image: disk,
+			want:  errFormat,
+		},
+		{
+			desc:  "vhd checksum mismatch",
+			image: badChecksum,
+			want:  errCorrupt,
+		},
+		{
+			desc:  "differencing vhd",
+			image: differencing,
+			want:  errUnsupported,
+		},
+		{
+			desc:  "qcow2 backing file",
+			image: backing,
+			want:  errUnsupported,
+		},
+		{
+			desc:  "qcow2 L1 table larger than file",
+			image: hugeL1,
+			want:  errCorrupt,
+		},
+		{
+			desc:  "qcow2 L1 table does not cover size",
+			image: overflow,
+			want:  errCorrupt,
+		},
+		{
+			desc:  "qcow2 negative size",
+			image: negative,
+			want:  errCorrupt,
+		},
+		{
+			desc:  "qcow2 L1 offset beyond file",
+			image: l1Offset,
+			want:  errCorrupt,
+		},
+	}
+	for _, tt := range tests {
+		img, err := Open(writeTemp(t, dir, tt.image))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Open() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if img.Size() != int64(len(disk)) {
+			t.Errorf("%s: Size() got: %d, want: %d", tt.desc, img.Size(), len(disk))
+		}
+		got, err := ioutil.ReadAll(io.NewSectionReader(img, 0, img.Size()))
+		if err != nil {
+			t.Errorf("%s: reading image returned %v", tt.desc, err)
+		}
+		if !bytes.Equal(got, disk) {
+			t.Errorf("%s: image contents do not match the virtual disk", tt.desc)
+		}
+		// Reads that span blocks and the end of the disk.
+		p := make([]byte, 100)
+		n, err := img.ReadAt(p, int64(len(disk)-50))
+		if n != 50 || err != io.EOF {
+			t.Errorf("%s: ReadAt() at end of disk got: %d, %v, want: 50, %v", tt.desc, n, err, io.EOF)
+		}
+		n, err = img.ReadAt(p, vhdxMB-50)
+		if err != nil || !bytes.Equal(p[:n], disk[vhdxMB-50:vhdxMB+50]) {
+			t.Errorf("%s: ReadAt() across blocks got: %d, %v", tt.desc, n, err)
+		}
+		img.Close()
+	}
+}
+
+func TestGUID(t *testing.T) {
+	want := []byte{0x66, 0x77, 0xC2, 0x2D, 0x23, 0xF6, 0x00, 0x42, 0x9D, 0x64, 0x11, 0x5E, 0x9B, 0xFD, 0x4A, 0x08}
+	if got := guid("2DC27766-F623-4200-9D64-115E9BFD4A08"); !bytes.Equal(got, want) {
+		t.Errorf("guid() got: %x, want: %x", got, want)
+	}
+}
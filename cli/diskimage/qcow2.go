@@ -0,0 +1,215 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskimage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	qcow2HeaderV2Size = 72
+	// Incompatible feature bits.
+	qcow2Corrupt      = 1 << 1
+	qcow2ExternalData = 1 << 2
+	qcow2Compression  = 1 << 3
+	qcow2ExtendedL2   = 1 << 4
+	qcow2KnownFeature = 1<<0 | qcow2Corrupt | qcow2ExternalData | qcow2Compression | qcow2ExtendedL2
+	// L1 and L2 table entry fields.
+	qcow2OffsetMask = 0x00fffffffffffe00
+	qcow2Compressed = 1 << 62
+	qcow2ZeroFlag   = 1 << 0
+)
+
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+// qcow2 reads the clusters of a qcow2 image through its two level lookup
+// table.
+type qcow2 struct {
+	f           file
+	clusterBits uint32
+	clusterSize int64
+	l1          []uint64
+
+	mu sync.Mutex // Protects the caches below.
+	// l2 caches the most recently read L2 table.
+	l2Offset uint64
+	l2       []uint64
+	// cluster caches the most recently decompressed cluster.
+	clusterEntry uint64
+	cluster      []byte
+}
+
+// openQCOW2 returns a reader for a version 2 or 3 qcow2 image.
+func openQCOW2(f file) (Image, error) {
+	h := make([]byte, qcow2HeaderV2Size)
+	if err := readFull(f, h, 0); err != nil {
+		return nil, fmt.Errorf("reading qcow2 header: %w", err)
+	}
+	version := binary.BigEndian.Uint32(h[4:8])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("%w: qcow2 version %d", errUnsupported, version)
+	}
+	if binary.BigEndian.Uint64(h[8:16]) != 0 {
+		return nil, fmt.Errorf("%w: qcow2 backing file", errUnsupported)
+	}
+	clusterBits := binary.BigEndian.Uint32(h[20:24])
+	if clusterBits < 9 || clusterBits > 21 {
+		return nil, fmt.Errorf("%w: qcow2 cluster bits %d", errCorrupt, clusterBits)
+	}
+	size := int64(binary.BigEndian.Uint64(h[24:32]))
+	if crypt := binary.BigEndian.Uint32(h[32:36]); crypt != 0 {
+		return nil, fmt.Errorf("%w: encrypted qcow2", errUnsupported)
+	}
+	l1Size := int64(binary.BigEndian.Uint32(h[36:40]))
+	l1Offset := int64(binary.BigEndian.Uint64(h[40:48]))
+
+	if version == 3 {
+		v3 := make([]byte, 8)
+		if err := readFull(f, v3, qcow2HeaderV2Size); err != nil {
+			return nil, fmt.Errorf("reading qcow2 v3 header: %w", err)
+		}
+		features := binary.BigEndian.Uint64(v3)
+		switch {
+		case features&^qcow2KnownFeature != 0:
+			return nil, fmt.Errorf("%w: qcow2 incompatible features %#x", errUnsupported, features)
+		case features&qcow2Corrupt != 0:
+			return nil, fmt.Errorf("%w: qcow2 is marked corrupt", errCorrupt)
+		case features&qcow2ExternalData != 0:
+			return nil, fmt.Errorf("%w: qcow2 external data file", errUnsupported)
+		case features&qcow2Compression != 0:
+			return nil, fmt.Errorf("%w: qcow2 compression type", errUnsupported)
+		case features&qcow2ExtendedL2 != 0:
+			return nil, fmt.Errorf("%w: qcow2 extended L2 entries", errUnsupported)
+		}
+	}
+
+	// The header is untrusted, so the L1 table is bounded by the entries that
+	// the virtual disk needs and by the file before it is allocated. Each L1
+	// entry covers at most 2^39 bytes, so none of this overflows.
+	clusterSize := int64(1) << clusterBits
+	entriesPerL2 := clusterSize / 8
+	perL1 := entriesPerL2 * clusterSize
+	if size < 0 {
+		return nil, fmt.Errorf("%w: qcow2 size %d", errCorrupt, uint64(size))
+	}
+	needed := size / perL1
+	if size%perL1 != 0 {
+		needed++
+	}
+	if l1Size < needed {
+		return nil, fmt.Errorf("%w: qcow2 L1 table with %d entries does not cover %d bytes", errCorrupt, l1Size, size)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Stat() returned %w", err)
+	}
+	if l1Offset < 0 || l1Offset > info.Size() || needed > (info.Size()-l1Offset)/8 {
+		return nil, fmt.Errorf("%w: qcow2 L1 table with %d entries at offset %d exceeds file size %d", errCorrupt, needed, l1Offset, info.Size())
+	}
+	// Entries beyond those needed, left when an image is shrunk, are not read.
+	raw := make([]byte, needed*8)
+	if err := readFull(f, raw, l1Offset); err != nil {
+		return nil, fmt.Errorf("reading qcow2 L1 table: %w", err)
+	}
+	q := &qcow2{
+		f:           f,
+		clusterBits: clusterBits,
+		clusterSize: clusterSize,
+		l1:          make([]uint64, needed),
+	}
+	for i := range q.l1 {
+		q.l1[i] = binary.BigEndian.Uint64(raw[i*8:])
+	}
+	return &blockImage{
+		f:         f,
+		size:      size,
+		blockSize: clusterSize,
+		readBlock: q.readCluster,
+	}, nil
+}
+
+// readCluster fills p from offset off within cluster idx.
+func (q *qcow2) readCluster(idx, off int64, p []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entriesPerL2 := q.clusterSize / 8
+	l2Offset := q.l1[idx/entriesPerL2] & qcow2OffsetMask
+	if l2Offset == 0 {
+		zero(p)
+		return nil
+	}
+	if l2Offset != q.l2Offset || q.l2 == nil {
+		raw := make([]byte, q.clusterSize)
+		if err := readFull(q.f, raw, int64(l2Offset)); err != nil {
+			return fmt.Errorf("reading qcow2 L2 table: %w", err)
+		}
+		q.l2 = make([]uint64, entriesPerL2)
+		for i := range q.l2 {
+			q.l2[i] = binary.BigEndian.Uint64(raw[i*8:])
+		}
+		q.l2Offset = l2Offset
+	}
+	e := q.l2[idx%entriesPerL2]
+
+	if e&qcow2Compressed != 0 {
+		c, err := q.decompress(e)
+		if err != nil {
+			return err
+		}
+		copy(p, c[off:])
+		return nil
+	}
+	host := e & qcow2OffsetMask
+	if e&qcow2ZeroFlag != 0 || host == 0 {
+		zero(p)
+		return nil
+	}
+	return readFull(q.f, p, int64(host)+off)
+}
+
+// decompress returns the contents of the compressed cluster described by the
+// L2 entry e.
+func (q *qcow2) decompress(e uint64) ([]byte, error) {
+	if q.cluster != nil && q.clusterEntry == e {
+		return q.cluster, nil
+	}
+	// The host offset occupies the low x bits of the entry, followed by the
+	// number of additional 512 byte sectors holding compressed data.
+	x := 62 - (q.clusterBits - 8)
+	host := e & (uint64(1)<<x - 1)
+	sectors := (e>>x)&(uint64(1)<<(q.clusterBits-8)-1) + 1
+	length := int64(sectors*512 - host%512)
+
+	raw := make([]byte, length)
+	n, err := q.f.ReadAt(raw, int64(host))
+	// Compressed data at the end of the file may be shorter than the sector
+	// count suggests.
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading qcow2 compressed cluster: %w", err)
+	}
+	c := make([]byte, q.clusterSize)
+	r := flate.NewReader(bytes.NewReader(raw[:n]))
+	defer r.Close()
+	if _, err := io.ReadFull(r, c); err != nil {
+		return nil, fmt.Errorf("%w: decompressing qcow2 cluster: %v", errCorrupt, err)
+	}
+	q.cluster, q.clusterEntry = c, e
+	return c, nil
+}
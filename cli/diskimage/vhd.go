@@ -0,0 +1,161 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	vhdFooterSize    = 512
+	vhdDynHeaderSize = 1024
+	vhdSectorSize    = 512
+	// Disk types from the footer.
+	vhdFixed        = 2
+	vhdDynamic      = 3
+	vhdDifferencing = 4
+	// vhdUnallocated marks blocks in the BAT that are not present in the file.
+	vhdUnallocated = 0xFFFFFFFF
+)
+
+var (
+	vhdCookie    = []byte("conectix")
+	vhdDynCookie = []byte("cxsparse")
+)
+
+// vhdFooter is the subset of the VHD footer used to read the image.
+type vhdFooter struct {
+	dataOffset  uint64
+	currentSize uint64
+	diskType    uint32
+}
+
+// parseVHDFooter parses and validates a VHD footer.
+func parseVHDFooter(b []byte) (*vhdFooter, error) {
+	if !bytes.Equal(b[0:8], vhdCookie) {
+		return nil, fmt.Errorf("%w: missing vhd footer", errCorrupt)
+	}
+	if got, want := binary.BigEndian.Uint32(b[64:68]), vhdChecksum(b, 64); got != want {
+		return nil, fmt.Errorf("%w: vhd footer checksum is %#x, want %#x", errCorrupt, got, want)
+	}
+	return &vhdFooter{
+		dataOffset:  binary.BigEndian.Uint64(b[16:24]),
+		currentSize: binary.BigEndian.Uint64(b[48:56]),
+		diskType:    binary.BigEndian.Uint32(b[60:64]),
+	}, nil
+}
+
+// vhdChecksum returns the ones' complement of the sum of all bytes in b,
+// excluding the four byte checksum field at offset field.
+func vhdChecksum(b []byte, field int) uint32 {
+	var sum uint32
+	for i, v := range b {
+		if i >= field && i < field+4 {
+			continue
+		}
+		sum += uint32(v)
+	}
+	return ^sum
+}
+
+// openVHD returns a reader for a fixed or dynamic VHD.
+func openVHD(f file) (Image, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Stat() returned %w", err)
+	}
+	b := make([]byte, vhdFooterSize)
+	if err := readFull(f, b, info.Size()-vhdFooterSize); err != nil {
+		return nil, fmt.Errorf("reading vhd footer: %w", err)
+	}
+	footer, err := parseVHDFooter(b)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(footer.currentSize)
+
+	switch footer.diskType {
+	case vhdFixed:
+		if size > info.Size()-vhdFooterSize {
+			return nil, fmt.Errorf("%w: vhd size %d exceeds file size %d", errCorrupt, size, info.Size())
+		}
+		return &blockImage{
+			f:         f,
+			size:      size,
+			blockSize: size,
+			readBlock: func(idx, off int64, p []byte) error {
+				return readFull(f, p, off)
+			},
+		}, nil
+	case vhdDynamic:
+		return openDynamicVHD(f, footer)
+	case vhdDifferencing:
+		return nil, fmt.Errorf("%w: differencing vhd", errUnsupported)
+	}
+	return nil, fmt.Errorf("%w: vhd disk type %d", errUnsupported, footer.diskType)
+}
+
+// openDynamicVHD returns a reader for a dynamic VHD, whose blocks are located
+// using the block allocation table (BAT).
+func openDynamicVHD(f file, footer *vhdFooter) (Image, error) {
+	h := make([]byte, vhdDynHeaderSize)
+	if err := readFull(f, h, int64(footer.dataOffset)); err != nil {
+		return nil, fmt.Errorf("reading vhd dynamic header: %w", err)
+	}
+	if !bytes.Equal(h[0:8], vhdDynCookie) {
+		return nil, fmt.Errorf("%w: missing vhd dynamic header", errCorrupt)
+	}
+	if got, want := binary.BigEndian.Uint32(h[36:40]), vhdChecksum(h, 36); got != want {
+		return nil, fmt.Errorf("%w: vhd dynamic header checksum is %#x, want %#x", errCorrupt, got, want)
+	}
+	tableOffset := int64(binary.BigEndian.Uint64(h[16:24]))
+	entries := int64(binary.BigEndian.Uint32(h[28:32]))
+	blockSize := int64(binary.BigEndian.Uint32(h[32:36]))
+	if blockSize == 0 || blockSize%vhdSectorSize != 0 {
+		return nil, fmt.Errorf("%w: vhd block size %d", errCorrupt, blockSize)
+	}
+	size := int64(footer.currentSize)
+	if entries*blockSize < size {
+		return nil, fmt.Errorf("%w: vhd BAT with %d entries does not cover %d bytes", errCorrupt, entries, size)
+	}
+
+	raw := make([]byte, entries*4)
+	if err := readFull(f, raw, tableOffset); err != nil {
+		return nil, fmt.Errorf("reading vhd BAT: %w", err)
+	}
+	bat := make([]uint32, entries)
+	for i := range bat {
+		bat[i] = binary.BigEndian.Uint32(raw[i*4:])
+	}
+	// Each block is preceded by a bitmap of the sectors it contains, padded to
+	// a sector boundary.
+	bitmapSize := (blockSize/vhdSectorSize/8 + vhdSectorSize - 1) / vhdSectorSize * vhdSectorSize
+
+	return &blockImage{
+		f:         f,
+		size:      size,
+		blockSize: blockSize,
+		readBlock: func(idx, off int64, p []byte) error {
+			sector := bat[idx]
+			if sector == vhdUnallocated {
+				zero(p)
+				return nil
+			}
+			return readFull(f, p, int64(sector)*vhdSectorSize+bitmapSize+off)
+		},
+	}, nil
+}
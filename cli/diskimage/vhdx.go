@@ -0,0 +1,238 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	vhdxHeaderSize      = 4 * 1024
+	vhdxRegionTableSize = 64 * 1024
+	vhdxMB              = 1024 * 1024
+	// Payload block states from the BAT.
+	vhdxBlockNotPresent       = 0
+	vhdxBlockUndefined        = 1
+	vhdxBlockZero             = 2
+	vhdxBlockUnmapped         = 3
+	vhdxBlockFullyPresent     = 6
+	vhdxBlockPartiallyPresent = 7
+)
+
+var (
+	vhdxMagic         = []byte("vhdxfile")
+	vhdxHeaderSig     = []byte("head")
+	vhdxRegionSig     = []byte("regi")
+	vhdxMetadataSig   = []byte("metadata")
+	vhdxHeaderOffsets = []int64{64 * 1024, 128 * 1024}
+	vhdxRegionOffsets = []int64{192 * 1024, 256 * 1024}
+
+	// Region and metadata item identifiers, in their on-disk byte order.
+	vhdxBATRegion      = guid("2DC27766-F623-4200-9D64-115E9BFD4A08")
+	vhdxMetadataRegion = guid("8B7CA206-4790-4B9A-B8FE-575F050F886E")
+	vhdxFileParameters = guid("CAA16737-FA36-4D43-B3B6-33F0AA44E76B")
+	vhdxVirtualSize    = guid("2FA54224-CD1B-4876-B211-5DBED83BF4B8")
+	vhdxLogicalSector  = guid("8141BF1D-A96F-4709-BA47-F233A8FAAB5F")
+
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// guid converts a GUID string to its mixed-endian on-disk representation.
+func guid(s string) []byte {
+	raw, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(raw) != 16 {
+		panic(fmt.Sprintf("invalid GUID %q", s))
+	}
+	// The first three fields are stored little-endian.
+	raw[0], raw[1], raw[2], raw[3] = raw[3], raw[2], raw[1], raw[0]
+	raw[4], raw[5] = raw[5], raw[4]
+	raw[6], raw[7] = raw[7], raw[6]
+	return raw
+}
+
+// vhdxChecksum validates the CRC-32C checksum stored at offset 4 of b, which
+// is calculated with the checksum field set to zero.
+func vhdxChecksum(b []byte) bool {
+	want := binary.LittleEndian.Uint32(b[4:8])
+	c := make([]byte, len(b))
+	copy(c, b)
+	binary.LittleEndian.PutUint32(c[4:8], 0)
+	return crc32.Checksum(c, crc32c) == want
+}
+
+// vhdxRegion is the location of a region within the file.
+type vhdxRegion struct {
+	offset int64
+	length int64
+}
+
+// openVHDX returns a reader for a VHDX image.
+func openVHDX(f file) (Image, error) {
+	// Of the two headers, the valid one with the highest sequence number is
+	// current.
+	var header []byte
+	var seq uint64
+	for _, off := range vhdxHeaderOffsets {
+		h := make([]byte, vhdxHeaderSize)
+		if err := readFull(f, h, off); err != nil {
+			continue
+		}
+		if !bytes.Equal(h[0:4], vhdxHeaderSig) || !vhdxChecksum(h) {
+			continue
+		}
+		if s := binary.LittleEndian.Uint64(h[8:16]); header == nil || s > seq {
+			header, seq = h, s
+		}
+	}
+	if header == nil {
+		return nil, fmt.Errorf("%w: no valid vhdx header", errCorrupt)
+	}
+	// A non-zero log GUID means the log must be replayed before the image is
+	// consistent.
+	if !bytes.Equal(header[48:64], make([]byte, 16)) {
+		return nil, fmt.Errorf("%w: vhdx log replay", errUnsupported)
+	}
+
+	regions, err := vhdxRegions(f)
+	if err != nil {
+		return nil, err
+	}
+	bat, ok := regions[string(vhdxBATRegion)]
+	if !ok {
+		return nil, fmt.Errorf("%w: vhdx is missing the BAT region", errCorrupt)
+	}
+	meta, ok := regions[string(vhdxMetadataRegion)]
+	if !ok {
+		return nil, fmt.Errorf("%w: vhdx is missing the metadata region", errCorrupt)
+	}
+	items, err := vhdxMetadata(f, meta)
+	if err != nil {
+		return nil, err
+	}
+	params, ok := items[string(vhdxFileParameters)]
+	if !ok || len(params) < 8 {
+		return nil, fmt.Errorf("%w: vhdx is missing file parameters", errCorrupt)
+	}
+	blockSize := int64(binary.LittleEndian.Uint32(params[0:4]))
+	if binary.LittleEndian.Uint32(params[4:8])&2 != 0 {
+		return nil, fmt.Errorf("%w: differencing vhdx", errUnsupported)
+	}
+	vs, ok := items[string(vhdxVirtualSize)]
+	if !ok || len(vs) < 8 {
+		return nil, fmt.Errorf("%w: vhdx is missing the virtual disk size", errCorrupt)
+	}
+	size := int64(binary.LittleEndian.Uint64(vs))
+	ls, ok := items[string(vhdxLogicalSector)]
+	if !ok || len(ls) < 4 {
+		return nil, fmt.Errorf("%w: vhdx is missing the logical sector size", errCorrupt)
+	}
+	sectorSize := int64(binary.LittleEndian.Uint32(ls))
+	if blockSize < vhdxMB || blockSize%vhdxMB != 0 || (sectorSize != 512 && sectorSize != 4096) {
+		return nil, fmt.Errorf("%w: vhdx block size %d, sector size %d", errCorrupt, blockSize, sectorSize)
+	}
+
+	// A sector bitmap entry follows every chunkRatio payload entries in the
+	// BAT.
+	chunkRatio := (int64(1) << 23) * sectorSize / blockSize
+	blocks := (size + blockSize - 1) / blockSize
+	entries := blocks + (blocks-1)/chunkRatio
+	if entries*8 > bat.length {
+		return nil, fmt.Errorf("%w: vhdx BAT of %d bytes does not cover %d blocks", errCorrupt, bat.length, blocks)
+	}
+	raw := make([]byte, entries*8)
+	if err := readFull(f, raw, bat.offset); err != nil {
+		return nil, fmt.Errorf("reading vhdx BAT: %w", err)
+	}
+
+	return &blockImage{
+		f:         f,
+		size:      size,
+		blockSize: blockSize,
+		readBlock: func(idx, off int64, p []byte) error {
+			e := binary.LittleEndian.Uint64(raw[(idx+idx/chunkRatio)*8:])
+			switch e & 7 {
+			case vhdxBlockNotPresent, vhdxBlockUndefined, vhdxBlockZero, vhdxBlockUnmapped:
+				zero(p)
+				return nil
+			case vhdxBlockFullyPresent:
+				return readFull(f, p, int64(e>>20)*vhdxMB+off)
+			case vhdxBlockPartiallyPresent:
+				return fmt.Errorf("%w: partially present vhdx block", errUnsupported)
+			}
+			return fmt.Errorf("%w: vhdx block %d has state %d", errCorrupt, idx, e&7)
+		},
+	}, nil
+}
+
+// vhdxRegions returns the regions listed in the first valid region table,
+// keyed by their GUID.
+func vhdxRegions(f file) (map[string]vhdxRegion, error) {
+	for _, off := range vhdxRegionOffsets {
+		t := make([]byte, vhdxRegionTableSize)
+		if err := readFull(f, t, off); err != nil {
+			continue
+		}
+		if !bytes.Equal(t[0:4], vhdxRegionSig) || !vhdxChecksum(t) {
+			continue
+		}
+		count := int(binary.LittleEndian.Uint32(t[8:12]))
+		if 16+count*32 > len(t) {
+			continue
+		}
+		regions := make(map[string]vhdxRegion)
+		for i := 0; i < count; i++ {
+			e := t[16+i*32 : 16+(i+1)*32]
+			regions[string(e[0:16])] = vhdxRegion{
+				offset: int64(binary.LittleEndian.Uint64(e[16:24])),
+				length: int64(binary.LittleEndian.Uint32(e[24:28])),
+			}
+		}
+		return regions, nil
+	}
+	return nil, fmt.Errorf("%w: no valid vhdx region table", errCorrupt)
+}
+
+// vhdxMetadata returns the contents of the metadata items in the metadata
+// region, keyed by their GUID.
+func vhdxMetadata(f file, r vhdxRegion) (map[string][]byte, error) {
+	m := make([]byte, r.length)
+	if err := readFull(f, m, r.offset); err != nil {
+		return nil, fmt.Errorf("reading vhdx metadata: %w", err)
+	}
+	if len(m) < 32 || !bytes.Equal(m[0:8], vhdxMetadataSig) {
+		return nil, fmt.Errorf("%w: missing vhdx metadata header", errCorrupt)
+	}
+	count := int(binary.LittleEndian.Uint16(m[10:12]))
+	items := make(map[string][]byte)
+	for i := 0; i < count; i++ {
+		start := 32 + i*32
+		if start+32 > len(m) {
+			return nil, fmt.Errorf("%w: vhdx metadata table is truncated", errCorrupt)
+		}
+		e := m[start : start+32]
+		off := int(binary.LittleEndian.Uint32(e[16:20]))
+		length := int(binary.LittleEndian.Uint32(e[20:24]))
+		if off+length > len(m) {
+			return nil, fmt.Errorf("%w: vhdx metadata item exceeds the region", errCorrupt)
+		}
+		items[string(e[0:16])] = m[off : off+length]
+	}
+	return items, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imagefile derives the names and types of image files from the URLs
+// and paths that they are obtained from.
+package imagefile
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// redacted replaces the query strings and credentials of URLs in logs.
+const redacted = "REDACTED"
+
+// signatureParams are the query parameters, in lower case, that carry the
+// signatures of pre-signed URLs: those of Cloud Storage, S3, CloudFront and
+// Azure shared access signatures.
+var signatureParams = []string{"x-goog-signature", "x-amz-signature", "signature", "sig"}
+
+// compressedExts are the extensions of the compression formats that are kept
+// together with the extension before them, as in installer.img.gz. Images in
+// these formats are decompressed as they are written.
+var compressedExts = map[string]bool{".gz": true, ".xz": true, ".zst": true}
+
+// Name returns the name of the file at the end of source, which is a URL, a
+// path relative to an image server or a local path. Query strings and
+// fragments, such as the signatures of pre-signed URLs, are not part of the
+// name, and escaped characters are unescaped. An empty string is returned
+// when source does not end in a file name.
+func Name(source string) string {
+	p := source
+	u, err := url.Parse(source)
+	switch {
+	case err != nil:
+		// Local paths can contain characters that are not valid in URLs,
+		// such as a bare '%', and are only stripped of anything that
+		// resembles a query string.
+		if n := strings.IndexAny(p, "?#"); n >= 0 {
+			p = p[:n]
+		}
+	case len(u.Scheme) == 1:
+		// The drive letters of Windows paths are parsed as schemes.
+	default:
+		p = u.Path
+	}
+	return base(p)
+}
+
+// Ext returns the extension of the file name name in lower case, which
+// identifies the type of image. The extensions of compression formats are
+// returned with the extension before them, such as '.img.gz', while other
+// dots in the name, such as those of version numbers, are ignored.
+func Ext(name string) string {
+	name = strings.ToLower(name)
+	ext := path.Ext(name)
+	if compressedExts[ext] {
+		ext = path.Ext(strings.TrimSuffix(name, ext)) + ext
+	}
+	return ext
+}
+
+// Compression returns the extension of the compression format of the file
+// name name in lower case, such as '.gz', or an empty string when the file is
+// not compressed.
+func Compression(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if compressedExts[ext] {
+		return ext
+	}
+	return ""
+}
+
+// FromContentDisposition returns the file name given by the
+// Content-Disposition header of a response, or an empty string when it gives
+// none. Only the final element of the name is returned, so that servers
+// cannot name files outside of the directory they are written to.
+func FromContentDisposition(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	return base(params["filename"])
+}
+
+// Signed reports whether source is an HTTP or HTTPS URL that carries its own
+// authorization in its query string, such as a pre-signed URL that expires
+// after a time. Signed URLs are requested without further credentials, and
+// their paths cannot be altered to obtain other files.
+func Signed(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.RawQuery == "" {
+		return false
+	}
+	for k := range u.Query() {
+		for _, p := range signatureParams {
+			if strings.EqualFold(k, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Redact returns source with the credentials and query string of a URL
+// replaced, so that it can be logged without the tokens of signed URLs. It
+// also identifies the file at source regardless of the signature it is
+// obtained with.
+func Redact(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		if n := strings.IndexAny(source, "?#"); n >= 0 {
+			return source[:n] + "?" + redacted
+		}
+		return source
+	}
+	if len(u.Scheme) == 1 {
+		return source
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		u.RawQuery, u.ForceQuery = redacted, false
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// base returns the final element of p, which is separated by forward or
+// backward slashes, or an empty string when p does not end in a file name.
+func base(p string) string {
+	p = strings.TrimRight(strings.ReplaceAll(p, `\`, "/"), "/")
+	if p == "" {
+		return ""
+	}
+	name := path.Base(p)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+)
+
+// applyLayout identifies the partitions of a device that has been laid out
+// for an applied image.
+type applyLayout struct {
+	system  string // The root of the EFI system partition.
+	windows string // The root of the partition that the image is applied to.
+}
+
+// prepareForApply prepares a device to have an image applied to it. The
+// device is only dismounted here, as the partition layout of the device is
+// replaced entirely when the image is applied. Elevated permissions are
+// required.
+func (i *Installer) prepareForApply(d Device) error {
+	deck.InfofA("Preparing %q for an applied image.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+	if !i.config.Apply() {
+		return fmt.Errorf("%q can only be applied to a device when apply is configured for the distribution: %w", i.config.ImageFile(), errProvision)
+	}
+	if err := i.config.CanWriteDevice(); err != nil {
+		return fmt.Errorf("%w: %v", errElevation, err)
+	}
+	if err := d.Dismount(); err != nil {
+		return fmt.Errorf("Dismount() for %q returned %v: %w", d.FriendlyName(), err, errDevice)
+	}
+	return nil
+}
+
+// provisionApply applies a WIM or FFU image directly to a device, making the
+// device itself a bootable Windows disk rather than an installer. FFU images
+// contain their own partition layout and are applied to the whole device,
+// after which the Windows partition is grown to fill the device.
+// For WIM images, the device is partitioned with an EFI system partition and
+// a Windows partition, the selected image is applied to the latter and boot
+// files are then added to the former.
+func (i *Installer) provisionApply(d Device) error {
+	if !i.config.Apply() {
+		return fmt.Errorf("%q can only be applied to a device when apply is configured for the distribution: %w", i.config.ImageFile(), errProvision)
+	}
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	if imagefile.Ext(path) == ".ffu" {
+		deck.InfofA("Applying FFU %q to %q.", path, d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+		console.Printf("Applying %s to %s. This can take some time.", i.config.ImageFile(), d.FriendlyName())
+		if err := applyFFU(path, d.Identifier()); err != nil {
+			return fmt.Errorf("applyFFU(%q, %q) returned %v: %w", path, d.Identifier(), err, errProvision)
+		}
+		// The device remains bootable when the layout cannot be extended, it
+		// only lacks the space beyond the disk the FFU was captured from.
+		deck.InfofA("Extending the Windows partition of %q.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+		if err := extendFFU(d.Identifier()); err != nil {
+			console.Printf("Warning: the Windows partition of %s was not extended to fill the device, and the space beyond the disk the FFU was captured from is unused: %v", d.FriendlyName(), err)
+			deck.Warningf("The Windows partition of %q could not be extended to fill the device: %v", d.FriendlyName(), err)
+		}
+		return nil
+	}
+
+	deck.InfofA("Partitioning %q for an applied image.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+	layout, err := partitionForApply(d.Identifier(), i.config.DistroLabel())
+	if err != nil {
+		return fmt.Errorf("partitionForApply(%q) returned %v: %w", d.Identifier(), err, errPartition)
+	}
+	deck.InfofA("Applying image %d of %q to %q.", i.config.ApplyIndex(), path, layout.windows).With(debug.V(debug.Storage, 2)).Go()
+	console.Printf("Applying %s to %s. This can take some time.", i.config.ImageFile(), d.FriendlyName())
+	if err := applyWIM(path, i.config.ApplyIndex(), layout.windows); err != nil {
+		return fmt.Errorf("applyWIM(%q, %d) returned %v: %w", path, i.config.ApplyIndex(), err, errProvision)
+	}
+	deck.InfofA("Adding boot files for %q to %q.", layout.windows, layout.system).With(debug.V(debug.Storage, 2)).Go()
+	if err := makeBootable(layout); err != nil {
+		return fmt.Errorf("makeBootable(%q) returned %v: %w", layout.windows, err, errProvision)
+	}
+	return nil
+}
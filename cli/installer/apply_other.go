@@ -0,0 +1,45 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package installer
+
+import "fmt"
+
+// partitionApply is only supported on windows.
+func partitionApply(id, label string) (*applyLayout, error) {
+	return nil, fmt.Errorf("partitioning for apply: %w", errUnsupported)
+}
+
+// dismApplyImage is only supported on windows.
+func dismApplyImage(path string, index int, dir string) error {
+	return fmt.Errorf("applying a WIM: %w", errUnsupported)
+}
+
+// dismApplyFFU is only supported on windows.
+func dismApplyFFU(path, id string) error {
+	return fmt.Errorf("applying an FFU: %w", errUnsupported)
+}
+
+// extendPartition is only supported on windows.
+func extendPartition(id string) error {
+	return fmt.Errorf("extending a partition: %w", errUnsupported)
+}
+
+// bcdboot is only supported on windows.
+func bcdboot(layout *applyLayout) error {
+	return fmt.Errorf("adding boot files: %w", errUnsupported)
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrepareForApply(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config *fakeConfig
+		device *fakeDevice
+		want   error
+	}{
+		{
+			desc:   "apply not configured",
+			config: &fakeConfig{imageFile: "install.wim", elevated: true},
+			device: &fakeDevice{},
+			want:   errProvision,
+		},
+		{
+			desc:   "not elevated",
+			config: &fakeConfig{imageFile: "install.wim", apply: true},
+			device: &fakeDevice{},
+			want:   errElevation,
+		},
+		{
+			desc:   "dismount error",
+			config: &fakeConfig{imageFile: "install.wim", apply: true, elevated: true},
+			device: &fakeDevice{dmErr: errors.New("error")},
+			want:   errDevice,
+		},
+		{
+			desc:   "success",
+			config: &fakeConfig{imageFile: "install.wim", apply: true, elevated: true},
+			device: &fakeDevice{},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		i := &Installer{config: tt.config}
+		if got := i.prepareForApply(tt.device); !errors.Is(got, tt.want) {
+			t.Errorf("%s: prepareForApply() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestProvisionApply(t *testing.T) {
+	layout := &applyLayout{system: `S:\`, windows: `W:\`}
+	tests := []struct {
+		desc      string
+		config    *fakeConfig
+		partition func(string, string) (*applyLayout, error)
+		wim       func(string, int, string) error
+		ffu       func(string, string) error
+		extend    func(string) error
+		boot      func(*applyLayout) error
+		want      error
+	}{
+		{
+			desc:   "apply not configured",
+			config: &fakeConfig{imageFile: "install.wim"},
+			want:   errProvision,
+		},
+		{
+			desc:   "ffu error",
+			config: &fakeConfig{imageFile: "disk.ffu", apply: true},
+			ffu:    func(string, string) error { return errors.New("error") },
+			want:   errProvision,
+		},
+		{
+			desc:   "ffu extend error",
+			config: &fakeConfig{imageFile: "disk.ffu", apply: true},
+			ffu:    func(string, string) error { return nil },
+			extend: func(string) error { return errors.New("error") },
+			want:   nil,
+		},
+		{
+			desc:   "ffu success",
+			config: &fakeConfig{imageFile: "disk.ffu", apply: true},
+			ffu:    func(string, string) error { return nil },
+			extend: func(string) error { return nil },
+			want:   nil,
+		},
+		{
+			desc:      "partition error",
+			config:    &fakeConfig{imageFile: "install.wim", apply: true},
+			partition: func(string, string) (*applyLayout, error) { return nil, errors.New("error") },
+			want:      errPartition,
+		},
+		{
+			desc:      "wim error",
+			config:    &fakeConfig{imageFile: "install.wim", apply: true},
+			partition: func(string, string) (*applyLayout, error) { return layout, nil },
+			wim:       func(string, int, string) error { return errors.New("error") },
+			want:      errProvision,
+		},
+		{
+			desc:      "boot error",
+			config:    &fakeConfig{imageFile: "install.wim", apply: true},
+			partition: func(string, string) (*applyLayout, error) { return layout, nil },
+			wim:       func(string, int, string) error { return nil },
+			boot:      func(*applyLayout) error { return errors.New("error") },
+			want:      errProvision,
+		},
+		{
+			desc:      "wim success",
+			config:    &fakeConfig{imageFile: "install.wim", apply: true, applyIndex: 2},
+			partition: func(string, string) (*applyLayout, error) { return layout, nil },
+			wim: func(_ string, index int, dir string) error {
+				if index != 2 || dir != layout.windows {
+					return errors.New("unexpected index or directory")
+				}
+				return nil
+			},
+			boot: func(*applyLayout) error { return nil },
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		partitionForApply = tt.partition
+		applyWIM = tt.wim
+		applyFFU = tt.ffu
+		extendFFU = tt.extend
+		makeBootable = tt.boot
+		i := &Installer{cache: "cache", config: tt.config}
+		if got := i.provisionApply(&fakeDevice{id: "1"}); !errors.Is(got, tt.want) {
+			t.Errorf("%s: provisionApply() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+	partitionForApply = partitionApply
+	applyWIM = dismApplyImage
+	applyFFU = dismApplyFFU
+	extendFFU = extendPartition
+	makeBootable = bcdboot
+}
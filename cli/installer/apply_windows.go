@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	win "golang.org/x/sys/windows"
+)
+
+// systemPartitionMB is the size of the EFI system partition created when a
+// WIM is applied.
+const systemPartitionMB = 260
+
+// partitionApply lays out the disk with the provided number as a GPT disk with
+// an EFI system partition and a Windows partition that fills the remainder of
+// the disk. Both partitions are assigned a free drive letter.
+func partitionApply(id, label string) (*applyLayout, error) {
+	letters, err := freeDriveLetters(2)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		label = "Windows"
+	}
+	script := fmt.Sprintf(`select disk %s
+clean
+convert gpt
+create partition efi size=%d
+format quick fs=fat32 label="System"
+assign letter=%s
+create partition primary
+format quick fs=ntfs label="%s"
+assign letter=%s
+exit
+`, id, systemPartitionMB, letters[0], label, letters[1])
+	if err := diskpart(script); err != nil {
+		return nil, err
+	}
+	return &applyLayout{
+		system:  letters[0] + `:\`,
+		windows: letters[1] + `:\`,
+	}, nil
+}
+
+// freeDriveLetters returns n drive letters that are not in use, starting
+// from the end of the alphabet to avoid letters that are likely to be claimed
+// by other devices.
+func freeDriveLetters(n int) ([]string, error) {
+	mask, err := win.GetLogicalDrives()
+	if err != nil {
+		return nil, fmt.Errorf("GetLogicalDrives() returned %v", err)
+	}
+	var letters []string
+	for l := 'Z'; l >= 'D' && len(letters) < n; l-- {
+		if mask&(1<<uint(l-'A')) == 0 {
+			letters = append(letters, string(l))
+		}
+	}
+	if len(letters) < n {
+		return nil, fmt.Errorf("found %d free drive letters, need %d", len(letters), n)
+	}
+	return letters, nil
+}
+
+// dismApplyImage applies the image with the provided index from a WIM to dir.
+func dismApplyImage(path string, index int, dir string) error {
+	return run("dism.exe", "/Apply-Image", "/ImageFile:"+path, fmt.Sprintf("/Index:%d", index), "/ApplyDir:"+dir)
+}
+
+// dismApplyFFU applies an FFU to the disk with the provided number.
+func dismApplyFFU(path, id string) error {
+	return run("dism.exe", "/Apply-FFU", "/ImageFile:"+path, "/ApplyDrive:"+devicePath(id))
+}
+
+// extendScript grows the largest basic data partition of a disk, which is the
+// Windows partition of an applied FFU, into any free space that follows it.
+// Partitions can only grow into adjacent space, so it fails, naming the
+// partition in the way, when a partition such as the recovery partition
+// follows the Windows partition and the free space lies beyond it.
+const extendScript = `$p = Get-Partition -DiskNumber %[1]s | Where-Object Type -eq 'Basic' | Sort-Object Size | Select-Object -Last 1
+if ($p -eq $null) { throw 'no basic data partition found' }
+$max = (Get-PartitionSupportedSize -DiskNumber %[1]s -PartitionNumber $p.PartitionNumber).SizeMax
+if ($max -gt $p.Size) { Resize-Partition -DiskNumber %[1]s -PartitionNumber $p.PartitionNumber -Size $max; return }
+$next = @(Get-Partition -DiskNumber %[1]s | Where-Object Offset -gt $p.Offset | Sort-Object Offset)
+if ($next.Count -gt 0 -and (Get-Disk -Number %[1]s).LargestFreeExtent -gt 0) { throw ('partition {0} ({1}) follows the Windows partition' -f $next[0].PartitionNumber, $next[0].Type) }`
+
+// extendPartition grows the Windows partition of an FFU applied to the disk
+// with the provided number to fill the disk. FFU images carry the partition
+// layout of the disk they were captured from, which is usually smaller than
+// the device.
+func extendPartition(id string) error {
+	return run("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(extendScript, id))
+}
+
+// bcdboot adds UEFI boot files for the applied image to the system partition.
+func bcdboot(layout *applyLayout) error {
+	return run("bcdboot.exe", filepath.Join(layout.windows, "Windows"), "/s", layout.system[:2], "/f", "UEFI")
+}
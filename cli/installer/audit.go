@@ -0,0 +1,245 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/client"
+	"github.com/google/fresnel/models"
+)
+
+// The checks made by Audit.
+const (
+	CheckLabel    = "label"
+	CheckContents = "contents"
+	CheckSeed     = "seed"
+)
+
+// The results of the checks made by Audit.
+const (
+	AuditPass = "pass"
+	AuditFail = "fail"
+	AuditSkip = "skipped"
+)
+
+// AuditCheck is the result of one of the checks made by Audit.
+type AuditCheck struct {
+	Name   string // One of CheckLabel, CheckContents or CheckSeed.
+	Result string // One of AuditPass, AuditFail or AuditSkip.
+	Detail string // Why the check failed or was skipped, or what passed.
+}
+
+// Audit checks whether d holds a correctly provisioned copy of the current
+// image without writing to it, such as when auditing media that has been in
+// storage. The image must already have been retrieved. The label of the
+// device, its contents and its seed are checked in turn, and the remaining
+// checks are skipped when one fails. The signature of the seed is checked
+// against keys, such as those cached by the verify-seed command, and only the
+// presence and expiry of the seed are checked when keys is nil. An error is
+// returned when the checks could not be made at all.
+func (i *Installer) Audit(d Device, keys *models.KeysResponse) ([]AuditCheck, error) {
+	if i.config == nil {
+		return nil, errConfig
+	}
+	switch ext := imagefile.Ext(i.config.ImageFile()); ext {
+	case ".img", ".img.gz", ".img.xz", ".img.zst", ".vhd", ".vhdx", ".qcow2":
+		// Raw images carry their own partitions, labels and files, so only
+		// their contents can be compared.
+		checks := []AuditCheck{{Name: CheckLabel, Result: AuditSkip, Detail: "raw images are not labelled"}}
+		checks = append(checks, auditResult(CheckContents, i.verifyRaw(d), "matches "+i.config.ImageFile()))
+		return append(checks, AuditCheck{Name: CheckSeed, Result: AuditSkip, Detail: "raw images are not seeded"}), nil
+	case ".iso":
+		return i.auditISO(d, keys)
+	default:
+		return nil, fmt.Errorf("auditing %q images: %w", ext, errUnsupported)
+	}
+}
+
+// auditISO audits a device provisioned with an ISO based image. The
+// partitions of the device are mounted beneath the cache, as they are when
+// provisioning.
+func (i *Installer) auditISO(d Device, keys *models.KeysResponse) ([]AuditCheck, error) {
+	i.written = nil
+	var checks []AuditCheck
+	// skip records the checks that follow one that failed.
+	skip := func(names ...string) []AuditCheck {
+		for _, name := range names {
+			checks = append(checks, AuditCheck{Name: name, Result: AuditSkip, Detail: "an earlier check failed"})
+		}
+		return checks
+	}
+	// Partitions smaller than a gigabyte are never provisioned, which avoids
+	// selecting an EFI partition.
+	fs := i.fileSystem()
+	deck.InfofA("Searching %q for a %q partition to audit.", d.FriendlyName(), fs).With(debug.V(debug.Storage, 2)).Go()
+	p, err := selectPart(d, uint64(units.GB), fs)
+	if err != nil {
+		checks = append(checks, AuditCheck{Name: CheckLabel, Result: AuditFail, Detail: fmt.Sprintf("no %s partition was found: %v", fs, err)})
+		return skip(CheckContents, CheckSeed), nil
+	}
+	if !strings.Contains(p.Label(), i.config.DistroLabel()) {
+		checks = append(checks, AuditCheck{Name: CheckLabel, Result: AuditFail, Detail: fmt.Sprintf("partition %q is labelled %q, want %q", p.Identifier(), p.Label(), i.config.DistroLabel())})
+		return skip(CheckContents, CheckSeed), nil
+	}
+	checks = append(checks, AuditCheck{Name: CheckLabel, Result: AuditPass, Detail: fmt.Sprintf("partition %q is labelled %q", p.Identifier(), p.Label())})
+
+	base := host.mountBase(i.cache)
+	deck.InfofA("Mounting %q for auditing.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	if err := p.Mount(base); err != nil {
+		return nil, fmt.Errorf("Mount() for %q returned %v: %w", p.Identifier(), err, errMount)
+	}
+	parts, err := i.rolePartitions(d, p, base)
+	if err != nil {
+		checks = append(checks, AuditCheck{Name: CheckContents, Result: AuditFail, Detail: err.Error()})
+		return skip(CheckSeed), nil
+	}
+	i.written = parts
+	contents := auditResult(CheckContents, i.verifyISO(), "matches "+i.config.ImageFile())
+	checks = append(checks, contents)
+	if contents.Result != AuditPass {
+		return skip(CheckSeed), nil
+	}
+	return append(checks, i.auditSeed(parts, keys)), nil
+}
+
+// auditSeed checks the seed written to the boot partition in parts. The seed
+// must be present, unexpired and, when keys are provided, signed with one of
+// them for the seed file on the device.
+func (i *Installer) auditSeed(parts map[string]partition, keys *models.KeysResponse) AuditCheck {
+	fail := func(format string, v ...interface{}) AuditCheck {
+		return AuditCheck{Name: CheckSeed, Result: AuditFail, Detail: fmt.Sprintf(format, v...)}
+	}
+	if i.config.SeedServer() == "" {
+		return AuditCheck{Name: CheckSeed, Result: AuditSkip, Detail: fmt.Sprintf("%s does not use seeds", i.config.Distro())}
+	}
+	dir := filepath.Join(host.root(parts[config.BootPartition].MountPoint()), i.config.SeedDest())
+	if !i.config.SeedRequired() {
+		marker := models.BatchMarker{}
+		if err := readJSON(filepath.Join(dir, batchDestFile), &marker); err != nil {
+			return fail("track %q does not require a seed, but no marker records that it was skipped: %v", i.config.Track(), err)
+		}
+		if !marker.SeedSkipped {
+			return fail("track %q does not require a seed, but the marker does not record that it was skipped", i.config.Track())
+		}
+		return AuditCheck{Name: CheckSeed, Result: AuditSkip, Detail: fmt.Sprintf("track %q does not require a seed", i.config.Track())}
+	}
+	sf := models.SeedFile{}
+	if err := readJSON(filepath.Join(dir, seedDestFile), &sf); err != nil {
+		return fail("%v", err)
+	}
+	if sf.ExpiresAt.IsZero() {
+		return fail("the expiry of the seed issued on %s is unknown", sf.Seed.Issued.UTC().Format(time.RFC3339))
+	}
+	if sf.ExpiresAt.Before(time.Now()) {
+		return fail("the seed expired on %s", sf.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	detail := fmt.Sprintf("issued to %s, expires on %s", sf.Seed.Username, sf.ExpiresAt.UTC().Format(time.RFC3339))
+	if keys == nil {
+		return AuditCheck{Name: CheckSeed, Result: AuditPass, Detail: detail + ", signature not checked"}
+	}
+	key, err := i.verifySeedFile(parts, i.config.SeedFile(), sf, keys)
+	if err != nil {
+		return fail("%v", err)
+	}
+	detail = fmt.Sprintf("%s, signed with key %q", detail, key)
+	if files := i.config.SeedFiles(); len(files) > 1 {
+		if err := i.auditBundle(parts, dir, files, keys); err != nil {
+			return fail("%v", err)
+		}
+		detail = fmt.Sprintf("%s, with %d bundled seeds", detail, len(files))
+	}
+	return AuditCheck{Name: CheckSeed, Result: AuditPass, Detail: detail}
+}
+
+// auditBundle checks that the seed bundle in dir holds an unexpired seed for
+// each of files, signed with one of keys for the file on the device.
+func (i *Installer) auditBundle(parts map[string]partition, dir string, files []string, keys *models.KeysResponse) error {
+	bundle := models.SeedBundle{}
+	if err := readJSON(filepath.Join(dir, bundleDestFile), &bundle); err != nil {
+		return err
+	}
+	seeds := make(map[string]models.SeedFile)
+	for _, b := range bundle.Seeds {
+		seeds[b.File] = b.SeedFile
+	}
+	for _, f := range files {
+		sf, ok := seeds[f]
+		if !ok {
+			return fmt.Errorf("the seed bundle has no seed for %q", f)
+		}
+		if !sf.ExpiresAt.IsZero() && sf.ExpiresAt.Before(time.Now()) {
+			return fmt.Errorf("the bundled seed for %q expired on %s", f, sf.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		if _, err := i.verifySeedFile(parts, f, sf, keys); err != nil {
+			return fmt.Errorf("bundled seed for %q: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// verifySeedFile checks that sf is signed with one of keys for file, and
+// returns the ID of the key. The seed was issued for the file in the image,
+// which the contents check has shown is the same as the one on the device.
+func (i *Installer) verifySeedFile(parts map[string]partition, file string, sf models.SeedFile, keys *models.KeysResponse) (string, error) {
+	if sf.Algorithm == "" {
+		sf.Algorithm = models.HashSHA256
+	}
+	role := partitionRole(filepath.ToSlash(file), i.config.PartitionRules())
+	p, ok := parts[role]
+	if !ok {
+		return "", fmt.Errorf("the %s partition holding %q was not found", role, file)
+	}
+	hash, err := fileHash(filepath.Join(host.root(p.MountPoint()), file), sf.Algorithm)
+	if err != nil {
+		return "", fmt.Errorf("hashing the seed file: %v", err)
+	}
+	return client.VerifySeed(sf.Seed, sf.Signature, hash, keys.Certs)
+}
+
+// auditResult returns the result of a check that failed with err, or passed
+// as described by pass when err is nil.
+func auditResult(name string, err error, pass string) AuditCheck {
+	if err != nil {
+		return AuditCheck{Name: name, Result: AuditFail, Detail: err.Error()}
+	}
+	return AuditCheck{Name: name, Result: AuditPass, Detail: pass}
+}
+
+// readJSON reads the JSON file at path into v.
+func readJSON(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%q was not found", filepath.Base(path))
+	}
+	if err != nil {
+		return fmt.Errorf("reading %q returned %v", path, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%q is not valid: %v", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"github.com/google/winops/storage"
+	"google.golang.org/appengine"
+)
+
+// testSeed returns a certificate and a seed file signed with its key for a
+// seed file with the contents of contents.
+func testSeed(t *testing.T, contents []byte) (appengine.Certificate, models.SeedFile) {
+	t.Helper()
+	key, cert := testKey(t)
+	return cert, signedSeed(t, key, contents)
+}
+
+// testKey returns a key and a certificate for it.
+func testKey(t *testing.T) (*rsa.PrivateKey, appengine.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() err: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fresnel-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() err: %v", err)
+	}
+	return key, appengine.Certificate{KeyName: "key1", Data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// signedSeed returns a seed file signed with key for a seed file with the
+// contents of contents.
+func signedSeed(t *testing.T, key *rsa.PrivateKey, contents []byte) models.SeedFile {
+	t.Helper()
+	hash := sha256.Sum256(contents)
+	seed := models.Seed{Issued: time.Now().UTC(), Username: "user@example.com", Hash: hash[:]}
+	b, err := json.Marshal(seed)
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("rsa.SignPKCS1v15() err: %v", err)
+	}
+	seed.Hash = nil
+	return models.SeedFile{Seed: seed, Signature: sig, ExpiresAt: seed.Issued.Add(24 * time.Hour), Algorithm: models.HashSHA256}
+}
+
+// writeJSON writes the JSON encoding of v to path.
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() returned %v", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	defer func() {
+		mount = mountISO
+		selectPart = selectPartition
+	}()
+	files := map[string]string{
+		"bootmgr":          "boot manager",
+		"sources/boot.wim": "boot image",
+	}
+	iso := t.TempDir()
+	for name, contents := range files {
+		p := filepath.Join(iso, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(p), err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", p, err)
+		}
+	}
+	key, cert := testKey(t)
+	seed := signedSeed(t, key, []byte(files["sources/boot.wim"]))
+	_, other := testSeed(t, []byte(files["sources/boot.wim"]))
+	expired := seed
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	keys := &models.KeysResponse{Certs: []appengine.Certificate{cert}}
+	bundle := &models.SeedBundle{Seeds: []models.BundledSeed{
+		{File: "sources/boot.wim", SeedFile: seed},
+		{File: "bootmgr", SeedFile: signedSeed(t, key, []byte(files["bootmgr"]))},
+	}}
+	_, otherBoot := testSeed(t, []byte(files["bootmgr"]))
+	otherBundle := &models.SeedBundle{Seeds: []models.BundledSeed{
+		{File: "sources/boot.wim", SeedFile: seed},
+		{File: "bootmgr", SeedFile: otherBoot},
+	}}
+
+	tests := []struct {
+		desc         string
+		imageFile    string
+		noSeedServer bool
+		noSeed       bool
+		selErr       error
+		label        string
+		contents     map[string]string
+		seed         *models.SeedFile
+		seedFiles    []string
+		bundle       *models.SeedBundle
+		marker       *models.BatchMarker
+		keys         *models.KeysResponse
+		want         []string // The results of the label, contents and seed checks.
+		wantErr      error
+	}{
+		{
+			desc:      "unsupported image",
+			imageFile: "fake.wim",
+			wantErr:   errUnsupported,
+		},
+		{
+			desc:   "no partition",
+			selErr: errors.New("error"),
+			want:   []string{AuditFail, AuditSkip, AuditSkip},
+		},
+		{
+			desc:  "wrong label",
+			label: "other",
+			want:  []string{AuditFail, AuditSkip, AuditSkip},
+		},
+		{
+			desc:     "contents differ",
+			contents: map[string]string{"bootmgr": "boot manager", "sources/boot.wim": "boot imagf"},
+			want:     []string{AuditPass, AuditFail, AuditSkip},
+		},
+		{
+			desc:         "no seed server",
+			noSeedServer: true,
+			want:         []string{AuditPass, AuditPass, AuditSkip},
+		},
+		{
+			desc: "missing seed",
+			want: []string{AuditPass, AuditPass, AuditFail},
+		},
+		{
+			desc: "expired seed",
+			seed: &expired,
+			want: []string{AuditPass, AuditPass, AuditFail},
+		},
+		{
+			desc: "seed without keys",
+			seed: &seed,
+			want: []string{AuditPass, AuditPass, AuditPass},
+		},
+		{
+			desc: "signed seed",
+			seed: &seed,
+			keys: keys,
+			want: []string{AuditPass, AuditPass, AuditPass},
+		},
+		{
+			desc: "seed signed with another key",
+			seed: &other,
+			keys: keys,
+			want: []string{AuditPass, AuditPass, AuditFail},
+		},
+		{
+			desc:      "bundled seeds",
+			seed:      &seed,
+			seedFiles: []string{"bootmgr"},
+			bundle:    bundle,
+			keys:      keys,
+			want:      []string{AuditPass, AuditPass, AuditPass},
+		},
+		{
+			desc:      "missing bundle",
+			seed:      &seed,
+			seedFiles: []string{"bootmgr"},
+			keys:      keys,
+			want:      []string{AuditPass, AuditPass, AuditFail},
+		},
+		{
+			desc:      "bundled seed signed with another key",
+			seed:      &seed,
+			seedFiles: []string{"bootmgr"},
+			bundle:    otherBundle,
+			keys:      keys,
+			want:      []string{AuditPass, AuditPass, AuditFail},
+		},
+		{
+			desc:   "skipped seed",
+			noSeed: true,
+			marker: &models.BatchMarker{SeedSkipped: true},
+			want:   []string{AuditPass, AuditPass, AuditSkip},
+		},
+		{
+			desc:   "skipped seed without marker",
+			noSeed: true,
+			want:   []string{AuditPass, AuditPass, AuditFail},
+		},
+	}
+	for _, tt := range tests {
+		boot := t.TempDir()
+		contents := files
+		if tt.contents != nil {
+			contents = tt.contents
+		}
+		for name, c := range contents {
+			p := filepath.Join(boot, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(p), err)
+			}
+			if err := ioutil.WriteFile(p, []byte(c), 0644); err != nil {
+				t.Fatalf("ioutil.WriteFile(%q) returned %v", p, err)
+			}
+		}
+		seedDir := filepath.Join(boot, "seed")
+		if err := os.MkdirAll(seedDir, 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", seedDir, err)
+		}
+		if tt.seed != nil {
+			writeJSON(t, filepath.Join(seedDir, seedDestFile), tt.seed)
+		}
+		if tt.bundle != nil {
+			writeJSON(t, filepath.Join(seedDir, bundleDestFile), tt.bundle)
+		}
+		if tt.marker != nil {
+			writeJSON(t, filepath.Join(seedDir, batchDestFile), tt.marker)
+		}
+		label := "WINPE"
+		if tt.label != "" {
+			label = tt.label
+		}
+		selErr := tt.selErr
+		selectPart = func(Device, uint64, storage.FileSystem) (partition, error) {
+			return &fakePartition{id: "sdz1", label: label, mount: boot}, selErr
+		}
+		mount = func(string) (isoHandler, error) { return &fakeHandler{mount: iso}, nil }
+		imageFile := "fake.iso"
+		if tt.imageFile != "" {
+			imageFile = tt.imageFile
+		}
+		seedServer := "https://seed.example.com"
+		if tt.noSeedServer {
+			seedServer = ""
+		}
+		i := &Installer{config: &fakeConfig{
+			imageFile:   imageFile,
+			distro:      "windows",
+			distroLabel: "WINPE",
+			track:       "stable",
+			seedDest:    "seed",
+			seedFile:    "sources/boot.wim",
+			seedFiles:   tt.seedFiles,
+			seedServer:  seedServer,
+			noSeed:      tt.noSeed,
+		}}
+		checks, err := i.Audit(&fakeDevice{id: "sdz", name: "USB Stick"}, tt.keys)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Audit() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		var got []string
+		for _, c := range checks {
+			got = append(got, c.Result)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Audit() got: %+v, want results: %v", tt.desc, checks, tt.want)
+			continue
+		}
+		for n := range got {
+			if got[n] != tt.want[n] {
+				t.Errorf("%s: Audit() got: %+v, want results: %v", tt.desc, checks, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/units"
+)
+
+var (
+	// Dependency injection for testing.
+	cacheNow = time.Now
+
+	// regExCacheEntry matches the names of the entries of a cache directory,
+	// so that nothing else in the directory is evicted.
+	regExCacheEntry = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// cacheEntry describes an entry of a cache directory, which holds the files
+// retrieved for one image.
+type cacheEntry struct {
+	path string
+	used time.Time // When the entry was last used.
+	size int64     // The combined size of the files in the entry.
+}
+
+// cacheKey returns the name of the entry of a cache directory for the image at
+// url with the expected hash. The hash is empty when neither an image manifest
+// nor a checksum publishes one, and the entry is then keyed by url alone, so
+// the image in such an entry is never reused.
+func cacheKey(url, hash string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + strings.ToLower(hash)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// useCacheEntry points the installer at the entry of the cache directory for
+// the configured image, creating it if needed. It is called once the expected
+// hash of the image is known, so that a new image published to the same url
+// is given an entry of its own. An image retained directly in the directory
+// by a run with --cleanup=false is reused in place, as it is checked against
+// the expected hash. Without an expected hash, a retained image cannot be told
+// apart from a newer one published to the same url, so it is removed and
+// downloaded again.
+func (i *Installer) useCacheEntry() error {
+	hash := i.digest
+	if i.pinned != nil {
+		hash = i.pinned.Digest
+	}
+	if _, err := os.Stat(filepath.Join(i.root, i.config.ImageFile())); err == nil && hash != "" {
+		deck.InfofA("Reusing files retained in %q.", i.root).With(deck.V(1)).Go()
+		i.cache = i.root
+		return nil
+	}
+	// Signed URLs differ each time they are issued, so entries are keyed by
+	// the URL without its query string.
+	entry := filepath.Join(i.root, cacheKey(imagefile.Redact(i.config.ImagePath()), hash))
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(entry, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", entry, err, errPath)
+	}
+	if hash == "" {
+		image := filepath.Join(entry, i.config.ImageFile())
+		if err := os.Remove(image); err == nil {
+			console.Printf("No digest or checksum is published for %s, so it is downloaded again rather than reused from the cache directory.", i.config.ImageFile())
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove(%q) returned %v: %w", image, err, errFile)
+		}
+	}
+	// The modification time of an entry records when it was last used, which
+	// is what entries are evicted by.
+	now := cacheNow()
+	if err := os.Chtimes(entry, now, now); err != nil {
+		return fmt.Errorf("os.Chtimes(%q) returned %v: %w", entry, err, errPath)
+	}
+	deck.InfofA("Using cache entry %q for %q.", entry, imagefile.Redact(i.config.ImagePath())).With(deck.V(1)).Go()
+	i.cache = entry
+	return nil
+}
+
+// evictCache removes the entries of the cache directory that have not been
+// used for longer than the maximum age, and then the least recently used
+// entries until the directory is within the maximum size. The entry in use is
+// never evicted.
+func (i *Installer) evictCache() error {
+	maxAge, maxSize := i.config.CacheMaxAge(), i.config.CacheMaxSize()
+	if i.root == "" || (maxAge == 0 && maxSize == 0) {
+		return nil
+	}
+	entries, err := cacheEntries(i.root)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].used.Before(entries[b].used) })
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	now := cacheNow()
+	for _, e := range entries {
+		if e.path == i.cache {
+			continue
+		}
+		expired := maxAge > 0 && now.Sub(e.used) > maxAge
+		over := maxSize > 0 && units.Size(total) > maxSize
+		if !expired && !over {
+			continue
+		}
+		deck.InfofA("Evicting %q (%v, last used %s) from the cache directory.", e.path, units.Size(e.size), e.used.Format(time.RFC1123)).With(deck.V(1)).Go()
+		if err := os.RemoveAll(e.path); err != nil {
+			return fmt.Errorf("os.RemoveAll(%q) returned %v: %w", e.path, err, errPath)
+		}
+		total -= e.size
+	}
+	if maxSize > 0 && units.Size(total) > maxSize {
+		deck.Warningf("Cache directory %q holds %v, more than its limit of %v, as the entry in use is not evicted.", i.root, units.Size(total), maxSize)
+	}
+	return nil
+}
+
+// cacheEntries lists the entries of the cache directory at root.
+func cacheEntries(root string) ([]cacheEntry, error) {
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadDir(%q) returned %v: %w", root, err, errPath)
+	}
+	var entries []cacheEntry
+	for _, info := range infos {
+		if !info.IsDir() || !regExCacheEntry.MatchString(info.Name()) {
+			continue
+		}
+		e := cacheEntry{path: filepath.Join(root, info.Name()), used: info.ModTime()}
+		err := filepath.Walk(e.path, func(_ string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() {
+				e.size += fi.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("filepath.Walk(%q) returned %v: %w", e.path, err, errPath)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/models"
+)
+
+func TestCacheKey(t *testing.T) {
+	url := "https://foo.bar.com/installer.iso"
+	key := cacheKey(url, "abcd")
+	if !regExCacheEntry.MatchString(key) {
+		t.Errorf("cacheKey(%q, %q) = %q, want a name matching %q", url, "abcd", key, regExCacheEntry)
+	}
+	tests := []struct {
+		desc string
+		url  string
+		hash string
+		same bool
+	}{
+		{desc: "same image", url: url, hash: "abcd", same: true},
+		{desc: "hash case", url: url, hash: "ABCD", same: true},
+		{desc: "new hash", url: url, hash: "ef01"},
+		{desc: "no hash", url: url},
+		{desc: "new url", url: "https://foo.bar.com/other.iso", hash: "abcd"},
+	}
+	for _, tt := range tests {
+		if got := cacheKey(tt.url, tt.hash); (got == key) != tt.same {
+			t.Errorf("%s: cacheKey(%q, %q) = %q, same as %q: %t, want: %t", tt.desc, tt.url, tt.hash, got, key, got == key, tt.same)
+		}
+	}
+}
+
+func TestUseCacheEntry(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cacheNow = func() time.Time { return now }
+	defer func() { cacheNow = time.Now }()
+	url := "https://foo.bar.com/installer.iso"
+
+	tests := []struct {
+		desc      string
+		legacy    bool
+		cached    bool
+		pinned    *models.TrackImage
+		digest    string
+		wantHash  string
+		wantReuse bool
+	}{
+		{desc: "no hash"},
+		{desc: "no hash with cached image", cached: true},
+		{desc: "checksum", digest: "abcd", wantHash: "abcd"},
+		{desc: "checksum with cached image", digest: "abcd", wantHash: "abcd", cached: true, wantReuse: true},
+		{desc: "manifest", pinned: &models.TrackImage{File: "installer.iso", Digest: "ef01"}, digest: "abcd", wantHash: "ef01"},
+		{desc: "retained image", legacy: true, digest: "abcd", wantReuse: true},
+		{desc: "retained image without hash", legacy: true},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		if tt.legacy {
+			if err := ioutil.WriteFile(filepath.Join(root, "installer.iso"), []byte("image"), 0644); err != nil {
+				t.Fatalf("%s: ioutil.WriteFile() returned %v", tt.desc, err)
+			}
+		}
+		if tt.cached {
+			entry := filepath.Join(root, cacheKey(url, tt.wantHash))
+			if err := os.Mkdir(entry, 0755); err != nil {
+				t.Fatalf("%s: os.Mkdir() returned %v", tt.desc, err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(entry, "installer.iso"), []byte("image"), 0644); err != nil {
+				t.Fatalf("%s: ioutil.WriteFile() returned %v", tt.desc, err)
+			}
+		}
+		i := &Installer{
+			cache:  root,
+			root:   root,
+			config: &fakeConfig{imagePath: url, imageFile: "installer.iso"},
+			pinned: tt.pinned,
+			digest: tt.digest,
+			reuse:  true,
+		}
+		if err := i.useCacheEntry(); err != nil {
+			t.Errorf("%s: useCacheEntry() returned %v", tt.desc, err)
+			continue
+		}
+		want := filepath.Join(root, cacheKey(url, tt.wantHash))
+		if tt.legacy && tt.wantReuse {
+			want = root
+		}
+		if i.cache != want {
+			t.Errorf("%s: useCacheEntry() cache got: %q, want: %q", tt.desc, i.cache, want)
+		}
+		fi, err := os.Stat(i.cache)
+		if err != nil {
+			t.Errorf("%s: os.Stat(%q) returned %v", tt.desc, i.cache, err)
+			continue
+		}
+		if i.cache != root && !fi.ModTime().Equal(now) {
+			t.Errorf("%s: useCacheEntry() last used got: %v, want: %v", tt.desc, fi.ModTime(), now)
+		}
+		_, err = os.Stat(filepath.Join(i.cache, "installer.iso"))
+		if got := err == nil; got != tt.wantReuse {
+			t.Errorf("%s: useCacheEntry() kept the cached image: %t, want: %t", tt.desc, got, tt.wantReuse)
+		}
+	}
+}
+
+func TestEvictCache(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cacheNow = func() time.Time { return now }
+	defer func() { cacheNow = time.Now }()
+
+	// Entries of 100 bytes, last used the given number of days ago.
+	entries := map[string]int{
+		"0000000000000001": 1,
+		"0000000000000002": 5,
+		"0000000000000003": 10,
+		"0000000000000004": 20,
+	}
+	inUse := "0000000000000004"
+
+	tests := []struct {
+		desc    string
+		maxAge  time.Duration
+		maxSize units.Size
+		want    []string
+	}{
+		{
+			desc: "no limits",
+			want: []string{"0000000000000001", "0000000000000002", "0000000000000003", "0000000000000004"},
+		},
+		{
+			desc:   "age",
+			maxAge: 7 * 24 * time.Hour,
+			want:   []string{"0000000000000001", "0000000000000002", "0000000000000004"},
+		},
+		{
+			desc:    "size",
+			maxSize: 250,
+			want:    []string{"0000000000000001", "0000000000000004"},
+		},
+		{
+			desc:    "entry in use is kept",
+			maxSize: 50,
+			want:    []string{"0000000000000004"},
+		},
+		{
+			desc:    "age and size",
+			maxAge:  2 * 24 * time.Hour,
+			maxSize: units.KB,
+			want:    []string{"0000000000000001", "0000000000000004"},
+		},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		for name, days := range entries {
+			dir := filepath.Join(root, name)
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("%s: os.Mkdir(%q) returned %v", tt.desc, dir, err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, "installer.iso"), make([]byte, 100), 0644); err != nil {
+				t.Fatalf("%s: ioutil.WriteFile() returned %v", tt.desc, err)
+			}
+			used := now.Add(-time.Duration(days) * 24 * time.Hour)
+			if err := os.Chtimes(dir, used, used); err != nil {
+				t.Fatalf("%s: os.Chtimes(%q) returned %v", tt.desc, dir, err)
+			}
+		}
+		// Other files in the directory are never evicted.
+		other := filepath.Join(root, "notes")
+		if err := os.Mkdir(other, 0755); err != nil {
+			t.Fatalf("%s: os.Mkdir(%q) returned %v", tt.desc, other, err)
+		}
+		i := &Installer{
+			cache:  filepath.Join(root, inUse),
+			root:   root,
+			config: &fakeConfig{cacheAge: tt.maxAge, cacheSize: tt.maxSize},
+		}
+		if err := i.evictCache(); err != nil {
+			t.Errorf("%s: evictCache() returned %v", tt.desc, err)
+			continue
+		}
+		got, err := cacheEntries(root)
+		if err != nil {
+			t.Fatalf("%s: cacheEntries() returned %v", tt.desc, err)
+		}
+		var names []string
+		for _, e := range got {
+			names = append(names, filepath.Base(e.path))
+		}
+		if len(names) != len(tt.want) {
+			t.Errorf("%s: evictCache() kept: %v, want: %v", tt.desc, names, tt.want)
+			continue
+		}
+		for n := range names {
+			if names[n] != tt.want[n] {
+				t.Errorf("%s: evictCache() kept: %v, want: %v", tt.desc, names, tt.want)
+				break
+			}
+		}
+		if _, err := os.Stat(other); err != nil {
+			t.Errorf("%s: evictCache() removed %q: %v", tt.desc, other, err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/version"
+	"github.com/google/winops/storage"
+)
+
+// The modes that devices can be captured in.
+const (
+	// CaptureBlock captures every byte of a device, so that it can be restored
+	// to other media exactly as it was.
+	CaptureBlock = "block"
+	// CaptureFiles captures the files on the partitions of a device, which is
+	// smaller but cannot be restored.
+	CaptureFiles = "files"
+)
+
+// The names of the entries of a capture, which is a gzip compressed tar file.
+const (
+	captureImage    = "disk.img"
+	captureFilesDir = "files"
+	captureMetadata = "metadata.json"
+)
+
+var (
+	// Dependency injections for testing.
+	selectCapturePart = selectPartitionQuietly
+	captureNow        = time.Now
+)
+
+// CaptureMetadata describes a capture and the device that it was made from.
+// It is stored as the last entry of the capture, once its contents are known.
+type CaptureMetadata struct {
+	Mode       string              `json:"mode"`
+	Captured   time.Time           `json:"captured"`
+	Device     string              `json:"device"`
+	Name       string              `json:"name"`
+	Size       uint64              `json:"size"`
+	Bytes      int64               `json:"bytes"`            // The size of the contents captured.
+	SHA256     string              `json:"sha256,omitempty"` // The hash of a block capture.
+	Partitions []CapturedPartition `json:"partitions,omitempty"`
+	Host       string              `json:"host"`
+	Build      version.Info        `json:"build"`
+	Note       string              `json:"note,omitempty"`
+}
+
+// CapturedPartition describes a partition whose files were captured.
+type CapturedPartition struct {
+	ID         string             `json:"id"`
+	Label      string             `json:"label"`
+	FileSystem storage.FileSystem `json:"file_system"`
+	Path       string             `json:"path"` // The directory of the capture holding its files.
+	Files      int                `json:"files"`
+}
+
+// Capture archives the contents of d to w as a gzip compressed tar file, so
+// that a device that fails in the field can be examined or reproduced. In
+// CaptureBlock mode every byte of d is captured, and in CaptureFiles mode the
+// files on its FAT32 and NTFS partitions are, which are mounted to read them
+// and dismounted afterwards. The note is recorded in the metadata of the
+// capture, which is returned. Nothing is written to d.
+func Capture(d Device, mode, note string, w io.Writer) (meta *CaptureMetadata, err error) {
+	host, _ := os.Hostname()
+	meta = &CaptureMetadata{
+		Mode:     mode,
+		Captured: captureNow().UTC(),
+		Device:   d.Identifier(),
+		Name:     d.FriendlyName(),
+		Size:     d.Size(),
+		Host:     host,
+		Build:    version.Get(config.DefaultsRevision()),
+		Note:     note,
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	switch mode {
+	case CaptureBlock:
+		err = captureBlock(d, tw, meta)
+	case CaptureFiles:
+		err = captureFiles(d, tw, meta)
+	default:
+		return nil, fmt.Errorf("capture mode %q: %w", mode, errUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent() returned %v: %w", err, errIO)
+	}
+	if err := writeTarFile(tw, captureMetadata, b); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing capture returned %v: %w", err, errIO)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("closing capture returned %v: %w", err, errIO)
+	}
+	return meta, nil
+}
+
+// captureBlock writes every byte of d to tw, recording their hash in meta.
+func captureBlock(d Device, tw *tar.Writer, meta *CaptureMetadata) error {
+	deck.InfofA("Opening %q for raw reading.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	dev, err := openForRead(d.Identifier())
+	if err != nil {
+		return fmt.Errorf("openForRead(%q) returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	defer dev.Close()
+	size := int64(d.Size())
+	hdr := &tar.Header{Name: captureImage, Mode: 0644, Size: size, ModTime: meta.Captured, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("WriteHeader(%q) returned %v: %w", captureImage, err, errIO)
+	}
+	h := sha256.New()
+	r := console.ProgressReader(io.NewSectionReader(dev, 0, size), "\nCapture of "+d.Identifier(), size)
+	n, err := copyBuffer(io.MultiWriter(tw, h), r)
+	if err != nil {
+		return fmt.Errorf("reading %q returned %v: %w", d.Identifier(), err, errIO)
+	}
+	if n != size {
+		return fmt.Errorf("%w: read %d of %d bytes of %q", errIO, n, size, d.Identifier())
+	}
+	meta.Bytes = n
+	meta.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// captureFiles writes the files on the FAT32 and NTFS partitions of d to tw,
+// beneath a directory for each partition. Partitions are mounted in a
+// temporary directory where the platform requires it.
+func captureFiles(d Device, tw *tar.Writer, meta *CaptureMetadata) (err error) {
+	base, err := ioutil.TempDir("", "capture_")
+	if err != nil {
+		return fmt.Errorf("ioutil.TempDir() returned %v: %w", err, errPath)
+	}
+	defer os.RemoveAll(base)
+	defer func() {
+		if err2 := d.Dismount(); err2 != nil && err == nil {
+			err = fmt.Errorf("Dismount() for %q returned %v: %w", d.Identifier(), err2, errDevice)
+		}
+	}()
+	seen := make(map[string]bool)
+	for _, fs := range []storage.FileSystem{storage.FAT32, storage.NTFS} {
+		p, err := selectCapturePart(d, fs)
+		if err != nil {
+			deck.InfofA("No %q partition of %q was captured: %v", fs, d.Identifier(), err).With(debug.V(debug.Storage, 2)).Go()
+			continue
+		}
+		if seen[p.Identifier()] {
+			continue
+		}
+		seen[p.Identifier()] = true
+		deck.InfofA("Mounting %q for capture.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := p.Mount(host.mountBase(base)); err != nil {
+			return fmt.Errorf("Mount() for %q returned %v: %w", p.Identifier(), err, errMount)
+		}
+		cp := CapturedPartition{
+			ID:         p.Identifier(),
+			Label:      p.Label(),
+			FileSystem: fs,
+			Path:       path.Join(captureFilesDir, fmt.Sprintf("p%d", len(meta.Partitions)+1)),
+		}
+		console.Printf("Capturing the files of partition %q (%s).", p.Identifier(), p.Label())
+		n, err := archiveDir(tw, host.root(p.MountPoint()), cp.Path, &cp.Files)
+		if err != nil {
+			return err
+		}
+		meta.Bytes += n
+		meta.Partitions = append(meta.Partitions, cp)
+	}
+	if len(meta.Partitions) == 0 {
+		return fmt.Errorf("%w: %q has no FAT32 or NTFS partitions to capture files from", errPartition, d.Identifier())
+	}
+	return nil
+}
+
+// selectPartitionQuietly selects a partition of d with the file system fs,
+// without explaining why none was found, as devices are not expected to have
+// partitions of every file system.
+func selectPartitionQuietly(d Device, fs storage.FileSystem) (partition, error) {
+	p, err := d.SelectPartition(0, fs)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no %q partition was found", fs)
+	}
+	return p, nil
+}
+
+// archiveDir writes the directories and regular files beneath root to tw,
+// named beneath prefix. It returns the size of the files written, and adds
+// their number to files.
+func archiveDir(tw *tar.Writer, root, prefix string, files *int) (int64, error) {
+	var written int64
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			deck.InfofA("Skipping %q, which is not a regular file.", p).With(debug.V(debug.Copy, 2)).Go()
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := copyBuffer(tw, f)
+		if err != nil {
+			return err
+		}
+		written += n
+		*files++
+		return nil
+	})
+	if err != nil {
+		return written, fmt.Errorf("archiving %q returned %v: %w", root, err, errIO)
+	}
+	return written, nil
+}
+
+// writeTarFile writes a file named name with contents b to tw.
+func writeTarFile(tw *tar.Writer, name string, b []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: captureNow(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("WriteHeader(%q) returned %v: %w", name, err, errIO)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("writing %q returned %v: %w", name, err, errIO)
+	}
+	return nil
+}
+
+// Restore writes a block capture read from r to d, so that a device captured
+// in the field can be reproduced on lab media. d must be at least as large as
+// the captured device, and is dismounted first. The contents written are
+// checked against the hash recorded when they were captured, and the metadata
+// of the capture is returned. Captures of files cannot be restored.
+func Restore(r io.Reader, d Device) (*CaptureMetadata, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the capture is not gzip compressed: %v", errFile, err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading the capture returned %v", errFile, err)
+	}
+	if hdr.Name != captureImage {
+		if strings.HasPrefix(hdr.Name, captureFilesDir+"/") {
+			return nil, fmt.Errorf("captures of files are extracted rather than restored: %w", errUnsupported)
+		}
+		return nil, fmt.Errorf("%w: the capture begins with %q rather than %q", errFile, hdr.Name, captureImage)
+	}
+	if uint64(hdr.Size) > d.Size() {
+		return nil, fmt.Errorf("%w: the capture (%s) is larger than %q (%s)", errDevice, humanize.Bytes(uint64(hdr.Size)), d.FriendlyName(), humanize.Bytes(d.Size()))
+	}
+	if err := d.Dismount(); err != nil {
+		return nil, fmt.Errorf("Dismount() for %q returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	deck.InfofA("Opening %q for raw writing.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	dev, err := openDevice(d.Identifier())
+	if err != nil {
+		return nil, fmt.Errorf("openDevice(%q) returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	defer dev.Close()
+	h := sha256.New()
+	src := console.ProgressReader(io.TeeReader(tr, h), "\nRestore to "+d.Identifier(), hdr.Size)
+	written, _, err := writeRaw(dev, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("writeRaw(%q) returned %v: %w", d.Identifier(), err, errIO)
+	}
+	if err := dev.Sync(); err != nil {
+		return nil, fmt.Errorf("Sync() for %q returned %v: %w", d.Identifier(), err, errIO)
+	}
+	if written != hdr.Size {
+		return nil, fmt.Errorf("%w: wrote %d of %d bytes to %q", errIO, written, hdr.Size, d.Identifier())
+	}
+	if hdr, err = tr.Next(); err != nil || hdr.Name != captureMetadata {
+		return nil, fmt.Errorf("%w: the capture has no metadata, it may be truncated: %v", errFile, err)
+	}
+	meta := &CaptureMetadata{}
+	if err := json.NewDecoder(tr).Decode(meta); err != nil {
+		return nil, fmt.Errorf("%w: decoding %q returned %v", errFile, captureMetadata, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, meta.SHA256) {
+		return meta, fmt.Errorf("%w: %q was restored with hash %q, but %q was captured", errVerify, d.Identifier(), got, meta.SHA256)
+	}
+	return meta, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/winops/storage"
+)
+
+// fakeDeviceReader is a device opened for reading that is backed by bytes.
+type fakeDeviceReader struct {
+	*bytes.Reader
+}
+
+func (f *fakeDeviceReader) Close() error {
+	return nil
+}
+
+// readCapture returns the names and contents of the entries of a capture.
+func readCapture(t *testing.T, b []byte) ([]string, map[string][]byte) {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() returned %v", err)
+	}
+	tr := tar.NewReader(zr)
+	var names []string
+	contents := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Next() returned %v", err)
+		}
+		names = append(names, hdr.Name)
+		if contents[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			t.Fatalf("reading %q returned %v", hdr.Name, err)
+		}
+	}
+	return names, contents
+}
+
+func TestCaptureRestore(t *testing.T) {
+	captureNow = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() {
+		captureNow = time.Now
+		openForRead = openRawDeviceRead
+		openDevice = openRawDevice
+	}()
+	disk := sparseImage()
+	openForRead = func(string) (readerAtCloser, error) { return &fakeDeviceReader{bytes.NewReader(disk)}, nil }
+
+	var capture bytes.Buffer
+	d := &fakeDevice{id: "sdc", name: "Field Stick", size: uint64(len(disk))}
+	meta, err := Capture(d, CaptureBlock, "does not boot", &capture)
+	if err != nil {
+		t.Fatalf("Capture() returned %v", err)
+	}
+	if meta.Device != "sdc" || meta.Bytes != int64(len(disk)) || meta.SHA256 == "" || meta.Note != "does not boot" {
+		t.Errorf("Capture() metadata got: %+v", meta)
+	}
+	names, contents := readCapture(t, capture.Bytes())
+	if len(names) != 2 || names[0] != captureImage || names[1] != captureMetadata {
+		t.Errorf("Capture() entries got: %v, want: [%s %s]", names, captureImage, captureMetadata)
+	}
+	if !bytes.Equal(contents[captureImage], disk) {
+		t.Errorf("Capture() %s does not match the device", captureImage)
+	}
+
+	// Restore the capture to a larger device.
+	f, err := ioutil.TempFile(t.TempDir(), "device")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile() returned %v", err)
+	}
+	openDevice = func(string) (rawDevice, error) { return &fakeRawDevice{File: f}, nil }
+	got, err := Restore(bytes.NewReader(capture.Bytes()), &fakeDevice{id: "sdd", size: uint64(len(disk)) * 2})
+	if err != nil {
+		t.Fatalf("Restore() returned %v", err)
+	}
+	if got.SHA256 != meta.SHA256 {
+		t.Errorf("Restore() metadata hash got: %q, want: %q", got.SHA256, meta.SHA256)
+	}
+	restored, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(%q) returned %v", f.Name(), err)
+	}
+	if !bytes.Equal(restored, disk) {
+		t.Errorf("Restore() wrote %d bytes that do not match the %d captured", len(restored), len(disk))
+	}
+}
+
+func TestCaptureFiles(t *testing.T) {
+	defer func() { selectCapturePart = selectPartitionQuietly }()
+	mnt := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mnt, "EFI", "BOOT"), 0755); err != nil {
+		t.Fatalf("os.MkdirAll() returned %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mnt, "EFI", "BOOT", "BOOTX64.EFI"), []byte("boot"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+
+	tests := []struct {
+		desc  string
+		parts map[storage.FileSystem]partition
+		want  error
+	}{
+		{desc: "no partitions", want: errPartition},
+		{
+			desc:  "boot partition",
+			parts: map[storage.FileSystem]partition{storage.FAT32: &fakePartition{id: "sdc1", label: "INSTALLER", mount: mnt}},
+		},
+		{
+			desc:  "mount error",
+			parts: map[storage.FileSystem]partition{storage.FAT32: &fakePartition{id: "sdc1", mountErr: errors.New("error")}},
+			want:  errMount,
+		},
+	}
+	for _, tt := range tests {
+		selectCapturePart = func(d Device, fs storage.FileSystem) (partition, error) {
+			if p, ok := tt.parts[fs]; ok {
+				return p, nil
+			}
+			return nil, errors.New("not found")
+		}
+		var capture bytes.Buffer
+		meta, err := Capture(&fakeDevice{id: "sdc"}, CaptureFiles, "", &capture)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Capture() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if len(meta.Partitions) != 1 || meta.Partitions[0].Files != 1 || meta.Bytes != 4 {
+			t.Errorf("%s: Capture() metadata got: %+v", tt.desc, meta)
+		}
+		_, contents := readCapture(t, capture.Bytes())
+		if got := string(contents["files/p1/EFI/BOOT/BOOTX64.EFI"]); got != "boot" {
+			t.Errorf("%s: Capture() files/p1/EFI/BOOT/BOOTX64.EFI got: %q, want: %q", tt.desc, got, "boot")
+		}
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	defer func() { openDevice = openRawDevice }()
+	openDevice = func(string) (rawDevice, error) {
+		f, err := ioutil.TempFile(t.TempDir(), "device")
+		return &fakeRawDevice{File: f}, err
+	}
+	// capture returns a capture of the given entries.
+	capture := func(entries ...string) []byte {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		tw := tar.NewWriter(zw)
+		for n := 0; n < len(entries); n += 2 {
+			if err := writeTarFile(tw, entries[n], []byte(entries[n+1])); err != nil {
+				t.Fatalf("writeTarFile() returned %v", err)
+			}
+		}
+		tw.Close()
+		zw.Close()
+		return b.Bytes()
+	}
+
+	tests := []struct {
+		desc    string
+		capture []byte
+		size    uint64
+		want    error
+	}{
+		{desc: "not compressed", capture: []byte("disk"), size: 1024, want: errFile},
+		{desc: "files", capture: capture("files/p1/boot.efi", "boot"), size: 1024, want: errUnsupported},
+		{desc: "device too small", capture: capture(captureImage, "disk"), size: 2, want: errDevice},
+		{desc: "truncated", capture: capture(captureImage, "disk"), size: 1024, want: errFile},
+		{desc: "hash mismatch", capture: capture(captureImage, "disk", captureMetadata, `{"sha256": "abcd"}`), size: 1024, want: errVerify},
+	}
+	for _, tt := range tests {
+		_, err := Restore(bytes.NewReader(tt.capture), &fakeDevice{id: "sdd", size: tt.size})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Restore() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/models"
+)
+
+// contentsDestFile is the name of the content manifest when it is stored on
+// media, alongside the seed.
+const contentsDestFile = `contents.json`
+
+// regExUnsafeName matches the characters of device identifiers that are
+// replaced to name the content manifest of the device in the cache, such as
+// the separators of Windows paths.
+var regExUnsafeName = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// recordContents completes the manifest m of the files written to d, and
+// writes it to the cache. When configured, it is also written to the boot
+// partition p, alongside the seed.
+func (i *Installer) recordContents(d Device, m *models.ContentManifest, p partition) error {
+	m.Created = time.Now()
+	m.Distro = i.config.Distro()
+	m.Track = i.config.Track()
+	m.Image = i.config.ImageFile()
+	m.ImageHash = i.ImageHash()
+	sort.Slice(m.Files, func(a, b int) bool {
+		if m.Files[a].Partition != m.Files[b].Partition {
+			return m.Files[a].Partition < m.Files[b].Partition
+		}
+		return m.Files[a].Path < m.Files[b].Path
+	})
+	m.Digest = contentsDigest(m.Files)
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent() returned %v: %w", err, errIO)
+	}
+	path := i.ContentsPath(d)
+	deck.InfofA("Recorded %d files written to %q with digest %q in %q.", len(m.Files), d.Identifier(), m.Digest, path).With(debug.V(debug.Copy, 2)).Go()
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", path, err, errIO)
+	}
+	i.contents = m
+	if !i.config.ContentsOnMedia() {
+		return nil
+	}
+	dir := filepath.Join(host.root(p.MountPoint()), i.config.SeedDest())
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dir, err, errPerm)
+	}
+	dest := filepath.Join(dir, contentsDestFile)
+	deck.InfofA("Writing content manifest: %q.", dest).With(debug.V(debug.Copy, 2)).Go()
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(dest, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", dest, err, errIO)
+	}
+	return nil
+}
+
+// contentsDigest returns the hex encoded SHA-256 hash of files, which must be
+// sorted. It identifies the contents of media independently of when they
+// were written.
+func contentsDigest(files []models.ContentFile) string {
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\t%s\t%d\t%s\n", f.Partition, f.Path, f.Size, f.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ContentsPath returns the path in the cache of the content manifest of d.
+func (i *Installer) ContentsPath(d Device) string {
+	return filepath.Join(i.cache, "contents-"+regExUnsafeName.ReplaceAllString(d.Identifier(), "_")+".json")
+}
+
+// Contents returns the manifest of the files written to the device that was
+// provisioned last. It is nil when the device was not provisioned from an
+// ISO image.
+func (i *Installer) Contents() *models.ContentManifest {
+	return i.contents
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/models"
+)
+
+func TestWriteISOContents(t *testing.T) {
+	src := t.TempDir()
+	boot := t.TempDir()
+	files := []string{"bootmgr", "sources/boot.wim"}
+	for _, f := range files {
+		path := filepath.Join(src, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+	}
+	m := &models.ContentManifest{}
+	iso := &fakeISO{mount: src, contents: files}
+	if err := writeISO(iso, map[string]partition{config.BootPartition: &fakePartition{mount: boot}}, copyOptions{contents: m}); err != nil {
+		t.Fatalf("writeISO() returned %v", err)
+	}
+	if len(m.Files) != len(files) {
+		t.Fatalf("writeISO() recorded %d files, want: %d", len(m.Files), len(files))
+	}
+	for _, f := range m.Files {
+		sum := sha256.Sum256([]byte(f.Path))
+		want := models.ContentFile{Path: f.Path, Partition: config.BootPartition, Size: int64(len(f.Path)), SHA256: hex.EncodeToString(sum[:])}
+		if f != want {
+			t.Errorf("writeISO() recorded %+v, want: %+v", f, want)
+		}
+		if _, err := os.Stat(filepath.Join(boot, filepath.FromSlash(f.Path))); err != nil {
+			t.Errorf("writeISO() did not copy %q: %v", f.Path, err)
+		}
+	}
+}
+
+func TestRecordContents(t *testing.T) {
+	files := []models.ContentFile{
+		{Path: "sources/install.wim", Partition: config.DataPartition, Size: 3, SHA256: "03"},
+		{Path: "sources/boot.wim", Partition: config.BootPartition, Size: 2, SHA256: "02"},
+		{Path: "bootmgr", Partition: config.BootPartition, Size: 1, SHA256: "01"},
+	}
+	tests := []struct {
+		desc    string
+		onMedia bool
+	}{
+		{desc: "cache only"},
+		{desc: "on media", onMedia: true},
+	}
+	var digest string
+	for _, tt := range tests {
+		cache, media := t.TempDir(), t.TempDir()
+		i := &Installer{
+			cache:  cache,
+			config: &fakeConfig{distro: "windows", track: "stable", imageFile: "installer.iso", seedDest: "seed", contents: tt.onMedia},
+		}
+		d := &fakeDevice{id: `\\.\PHYSICALDRIVE1`}
+		m := &models.ContentManifest{Files: append([]models.ContentFile(nil), files...)}
+		if err := i.recordContents(d, m, &fakePartition{mount: media}); err != nil {
+			t.Fatalf("%s: recordContents() returned %v", tt.desc, err)
+		}
+		if i.Contents() != m {
+			t.Errorf("%s: Contents() got: %v, want: %v", tt.desc, i.Contents(), m)
+		}
+		// Files are sorted by partition and path, so that the digest does not
+		// depend on the order they were written in.
+		if m.Files[0].Path != "bootmgr" || m.Files[2].Path != "sources/install.wim" {
+			t.Errorf("%s: recordContents() files got: %v, want them sorted", tt.desc, m.Files)
+		}
+		if digest == "" {
+			digest = m.Digest
+		} else if m.Digest != digest {
+			t.Errorf("%s: recordContents() digest got: %q, want: %q", tt.desc, m.Digest, digest)
+		}
+		path := i.ContentsPath(d)
+		if filepath.Dir(path) != cache || filepath.Base(path) != "contents-__._PHYSICALDRIVE1.json" {
+			t.Errorf("%s: ContentsPath() got: %q", tt.desc, path)
+		}
+		got := &models.ContentManifest{}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(%q) returned %v", tt.desc, path, err)
+		}
+		if err := json.Unmarshal(b, got); err != nil || got.Digest != m.Digest || got.Image != "installer.iso" {
+			t.Errorf("%s: recordContents() wrote %s (%v)", tt.desc, b, err)
+		}
+		_, err = os.Stat(filepath.Join(media, "seed", contentsDestFile))
+		if tt.onMedia != (err == nil) {
+			t.Errorf("%s: recordContents() on media: %t (%v), want: %t", tt.desc, err == nil, err, tt.onMedia)
+		}
+	}
+	if digest == contentsDigest(files[:2]) {
+		t.Errorf("contentsDigest() is the same for different files")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/units"
+)
+
+// copyBufferSize is the size of the buffers used to copy image contents.
+// Images are often several gigabytes, so a buffer larger than the 32KB used by
+// io.Copy substantially reduces the number of reads and writes made.
+const copyBufferSize = int(units.MB)
+
+// bufPool holds buffers that are reused between copies.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, copyBufferSize)
+		return &b
+	},
+}
+
+// copyBuffer copies from src to dst using a buffer from bufPool.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+// preallocator is implemented by writers that can reserve space for their
+// contents before they are written, reducing fragmentation.
+type preallocator interface {
+	Preallocate(size int64) error
+}
+
+// hashedFile writes to a file while computing the hash of its contents.
+type hashedFile struct {
+	f *os.File
+	h hash.Hash
+}
+
+// Write writes p to the file and adds it to the hash.
+func (w *hashedFile) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.h.Write(p[:n])
+	return n, err
+}
+
+// Preallocate reserves space for size bytes in the file.
+func (w *hashedFile) Preallocate(size int64) error {
+	return preallocate(w.f, size)
+}
+
+// Sum returns the hash of the contents written so far.
+func (w *hashedFile) Sum() []byte {
+	return w.h.Sum(nil)
+}
+
+// copyFile copies the file at src to dst, preallocating space for its
+// contents, and returns the number of bytes copied. When h is not nil, the
+// contents are added to it as they are copied. Both paths are opened as
+// extended-length paths on Windows.
+func copyFile(src, dst string, h hash.Hash) (int64, error) {
+	src, dst = extendedPath(src), extendedPath(dst)
+	source, err := os.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("%w: couldn't open file(%s): %v", errPath, src, err)
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("%w: couldn't stat file(%s): %v", errPath, src, err)
+	}
+	destination, err := os.Create(dst)
+	if err != nil {
+		return 0, fmt.Errorf("%w: couldn't create target file(%s): %v", errFile, dst, err)
+	}
+	defer destination.Close()
+	if err := preallocate(destination, info.Size()); err != nil {
+		deck.InfofA("preallocate(%d) for %q returned %v, continuing without preallocation.", info.Size(), dst, err).With(debug.V(debug.Copy, 2)).Go()
+	}
+	if h != nil {
+		return copyBuffer(&hashedFile{f: destination, h: h}, source)
+	}
+	return copyBuffer(destination, source)
+}
+
+// preserveAttributes gives dst the modification time of src, which is
+// described by info, and its basic attributes on platforms that have them.
+func preserveAttributes(src, dst string, info os.FileInfo) error {
+	if err := os.Chtimes(extendedPath(dst), info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("%w: os.Chtimes(%q) returned %v", errFile, dst, err)
+	}
+	if err := copyAttributes(extendedPath(src), extendedPath(dst)); err != nil {
+		return fmt.Errorf("%w: copying attributes of %q to %q: %v", errFile, src, dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocate reserves size bytes for f using fallocate, extending the file
+// to that size.
+func preallocate(f *os.File, size int64) error {
+	return syscall.Fallocate(int(f.Fd()), 0, 0, size)
+}
+
+// copyAttributes is a no-op, as the basic attributes of files on Windows have
+// no equivalent that is kept when copying.
+func copyAttributes(src, dst string) error {
+	return nil
+}
+
+// hideFile is a no-op, as names starting with a dot are already hidden.
+func hideFile(path string) error {
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/cli/config"
+)
+
+// writerOnly and readerOnly hide any io.ReaderFrom and io.WriterTo
+// implementations of the underlying types, as is the case for downloads, so
+// that the buffer provided to io.CopyBuffer is used.
+type writerOnly struct {
+	w io.Writer
+}
+
+func (w writerOnly) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+type readerOnly struct {
+	r io.Reader
+}
+
+func (r readerOnly) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestCopyBuffer(t *testing.T) {
+	src := bytes.Repeat([]byte("fresnel"), copyBufferSize)
+	var dst bytes.Buffer
+	n, err := copyBuffer(writerOnly{&dst}, readerOnly{bytes.NewReader(src)})
+	if err != nil {
+		t.Fatalf("copyBuffer() returned %v", err)
+	}
+	if n != int64(len(src)) {
+		t.Errorf("copyBuffer() copied %d bytes, want: %d", n, len(src))
+	}
+	if !bytes.Equal(dst.Bytes(), src) {
+		t.Errorf("copyBuffer() destination does not match source")
+	}
+}
+
+func TestHashedFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempFile("", "") returned %v`, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	content := []byte("test content")
+	hf := &hashedFile{f: f, h: sha256.New()}
+	if err := hf.Preallocate(int64(len(content))); err != nil {
+		t.Fatalf("Preallocate(%d) returned %v", len(content), err)
+	}
+	if runtime.GOOS != "darwin" {
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatalf("Stat() returned %v", err)
+		}
+		if info.Size() != int64(len(content)) {
+			t.Errorf("Preallocate(%d) size got: %d, want: %d", len(content), info.Size(), len(content))
+		}
+	}
+	if _, err := hf.Write(content); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	want := sha256.Sum256(content)
+	if !bytes.Equal(hf.Sum(), want[:]) {
+		t.Errorf("Sum() got: %x, want: %x", hf.Sum(), want)
+	}
+}
+
+// deepFixture returns a relative path below root that is longer than
+// MAX_PATH on Windows and contains non-ASCII names.
+func deepFixture(root string) string {
+	dirs := []string{"drivers", "Données", "日本語のドライバー"}
+	for len(filepath.Join(append([]string{root}, dirs...)...)) < 300 {
+		dirs = append(dirs, strings.Repeat("sous-répertoire", 3))
+	}
+	return filepath.Join(append(dirs, "pilote-ü-ñ-é.inf")...)
+}
+
+func TestCopyFileLongPath(t *testing.T) {
+	src, err := ioutil.TempDir("", "iso")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "iso") returned %v`, err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "part")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "part") returned %v`, err)
+	}
+	defer os.RemoveAll(dst)
+	rel := deepFixture(dst)
+	content := []byte("fresnel")
+	path := filepath.Join(src, rel)
+	if err := os.MkdirAll(extendedPath(filepath.Dir(path)), 0755); err != nil {
+		t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(extendedPath(path), content, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+	}
+
+	// Copy the tree as writeISO does, which walks the ISO and copies each file.
+	parts := map[string]partition{config.BootPartition: &fakePartition{mount: dst}}
+	if err := copyMapped(src, parts, copyOptions{}); err != nil {
+		t.Fatalf("copyMapped() returned %v", err)
+	}
+	got, err := ioutil.ReadFile(extendedPath(filepath.Join(dst, rel)))
+	if err != nil {
+		t.Fatalf("copyMapped() did not preserve %q: %v", rel, err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("copyMapped() copied %q, want: %q", got, content)
+	}
+	// Names must be written exactly, rather than mangled into another encoding.
+	dir := filepath.Join(dst, filepath.Dir(rel))
+	entries, err := ioutil.ReadDir(extendedPath(dir))
+	if err != nil {
+		t.Fatalf("ioutil.ReadDir(%q) returned %v", dir, err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(rel) {
+		t.Errorf("copyMapped() wrote %v, want: [%s]", entries, filepath.Base(rel))
+	}
+}
+
+func TestPreserveAttributes(t *testing.T) {
+	src, err := ioutil.TempDir("", "iso")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "iso") returned %v`, err)
+	}
+	defer os.RemoveAll(src)
+	old := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []string{"bootmgr", filepath.Join("sources", "boot.wim")} {
+		path := filepath.Join(src, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("os.Chtimes(%q) returned %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		desc     string
+		preserve bool
+		want     bool // Whether modification times are expected to match.
+	}{
+		{"preserve", true, true},
+		{"do not preserve", false, false},
+	}
+	for _, tt := range tests {
+		dst, err := ioutil.TempDir("", "part")
+		if err != nil {
+			t.Fatalf(`ioutil.TempDir("", "part") returned %v`, err)
+		}
+		defer os.RemoveAll(dst)
+		parts := map[string]partition{config.BootPartition: &fakePartition{mount: dst}}
+		if err := copyMapped(src, parts, copyOptions{preserve: tt.preserve}); err != nil {
+			t.Fatalf("%s: copyMapped() returned %v", tt.desc, err)
+		}
+		info, err := os.Stat(filepath.Join(dst, "sources", "boot.wim"))
+		if err != nil {
+			t.Fatalf("%s: os.Stat() returned %v", tt.desc, err)
+		}
+		if got := info.ModTime().Equal(old); got != tt.want {
+			t.Errorf("%s: copyMapped() modification time %v, want preserved: %t", tt.desc, info.ModTime(), tt.want)
+		}
+	}
+}
+
+// benchmarkFileCopy copies 64MB from memory to a temporary file using copy.
+func benchmarkFileCopy(b *testing.B, copy func(io.Writer, io.Reader) (int64, error), prealloc bool) {
+	src := make([]byte, 64*1024*1024)
+	b.SetBytes(int64(len(src)))
+	for n := 0; n < b.N; n++ {
+		f, err := ioutil.TempFile("", "")
+		if err != nil {
+			b.Fatalf(`ioutil.TempFile("", "") returned %v`, err)
+		}
+		if prealloc {
+			if err := preallocate(f, int64(len(src))); err != nil {
+				b.Fatalf("preallocate() returned %v", err)
+			}
+		}
+		if _, err := copy(writerOnly{f}, readerOnly{bytes.NewReader(src)}); err != nil {
+			b.Fatalf("copy() returned %v", err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func BenchmarkIOCopy(b *testing.B) {
+	benchmarkFileCopy(b, io.Copy, false)
+}
+
+func BenchmarkCopyBuffer(b *testing.B) {
+	benchmarkFileCopy(b, copyBuffer, false)
+}
+
+func BenchmarkCopyBufferPreallocated(b *testing.B) {
+	benchmarkFileCopy(b, copyBuffer, true)
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"os"
+	"syscall"
+)
+
+// preservedAttributes are the attributes of files that are kept when they are
+// copied. Files on mounted ISOs are always read-only, so the read-only
+// attribute is not kept, as it would prevent the files from being updated.
+const preservedAttributes = syscall.FILE_ATTRIBUTE_HIDDEN | syscall.FILE_ATTRIBUTE_SYSTEM | syscall.FILE_ATTRIBUTE_ARCHIVE
+
+// preallocate reserves size bytes for f by setting its end of file. This
+// allocates the clusters up front without the privileges required by
+// SetFileValidData, which would also expose stale data from the disk.
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
+
+// copyAttributes gives dst the hidden, system and archive attributes of src.
+func copyAttributes(src, dst string) error {
+	srcp, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	dstp, err := syscall.UTF16PtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	srcAttrs, err := syscall.GetFileAttributes(srcp)
+	if err != nil {
+		return err
+	}
+	dstAttrs, err := syscall.GetFileAttributes(dstp)
+	if err != nil {
+		return err
+	}
+	attrs := dstAttrs&^preservedAttributes | srcAttrs&preservedAttributes
+	if attrs == dstAttrs {
+		return nil
+	}
+	return syscall.SetFileAttributes(dstp, attrs)
+}
+
+// hideFile sets the hidden attribute of path, as names starting with a dot
+// are not hidden on Windows.
+func hideFile(path string) error {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	if attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0 {
+		return nil
+	}
+	return syscall.SetFileAttributes(p, attrs|syscall.FILE_ATTRIBUTE_HIDDEN)
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// Dependency injections for testing.
+	lookPath = exec.LookPath
+
+	// decompressors names the commands that decompress the formats that are
+	// not supported by the standard library. Each accepts the xz-style -d and
+	// -c flags and reads from stdin when no file is given.
+	decompressors = map[string]string{
+		".xz":  "xz",
+		".zst": "zstd",
+	}
+)
+
+// decompress returns a reader of the decompressed contents of r, which is
+// compressed in the format given by ext, such as ".gz". Gzip is decompressed
+// natively, while other formats require their decompressor to be installed.
+// Corrupt or truncated input is reported by Read rather than being mistaken
+// for the end of the image.
+func decompress(r io.Reader, ext string) (io.ReadCloser, error) {
+	if ext == ".gz" {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return gzipReader{zr}, nil
+	}
+	name, ok := decompressors[ext]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q compression", errUnsupported, ext)
+	}
+	bin, err := lookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decompressing %q images requires %s: %v", errUnsupported, ext, name, err)
+	}
+	cmd := exec.Command(bin, "-d", "-c")
+	cmd.Stdin = r
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("StdoutPipe() for %s returned %v", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s returned %v", name, err)
+	}
+	return &commandReader{cmd: cmd, out: out, stderr: stderr}, nil
+}
+
+// gzipReader reports truncated gzip streams with an error of its own, as
+// io.ErrUnexpectedEOF is taken to be the end of the image by writeRaw.
+type gzipReader struct {
+	*gzip.Reader
+}
+
+func (g gzipReader) Read(p []byte) (int, error) {
+	n, err := g.Reader.Read(p)
+	if err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: gzip stream is truncated", errFile)
+	}
+	return n, err
+}
+
+// commandReader reads the output of a decompressor.
+type commandReader struct {
+	cmd    *exec.Cmd
+	out    io.Reader
+	stderr *bytes.Buffer
+	done   bool
+}
+
+// Read reads the output of the decompressor. Once the output is exhausted,
+// the decompressor is waited for so that a failure is returned in place of
+// io.EOF.
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.out.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("%s returned %v: %s", c.cmd.Path, werr, strings.TrimSpace(c.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+// Close stops the decompressor if its output was not read to the end.
+func (c *commandReader) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
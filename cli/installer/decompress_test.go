@@ -0,0 +1,144 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	want := bytes.Repeat([]byte("fresnel"), 1024)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(want); err != nil {
+		t.Fatalf("Write() for gzip returned %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() for gzip returned %v", err)
+	}
+
+	tests := []struct {
+		desc     string
+		in       []byte
+		ext      string
+		lookPath func(string) (string, error)
+		want     error
+		wantRead bool
+	}{
+		{
+			desc: "gzip",
+			in:   gz.Bytes(),
+			ext:  ".gz",
+		},
+		{
+			desc: "not gzip",
+			in:   want,
+			ext:  ".gz",
+			want: gzip.ErrHeader,
+		},
+		{
+			desc:     "truncated gzip",
+			in:       gz.Bytes()[:gz.Len()-4],
+			ext:      ".gz",
+			wantRead: true,
+		},
+		{
+			desc: "unknown compression",
+			in:   want,
+			ext:  ".bz2",
+			want: errUnsupported,
+		},
+		{
+			desc:     "decompressor not installed",
+			in:       want,
+			ext:      ".xz",
+			lookPath: func(string) (string, error) { return "", exec.ErrNotFound },
+			want:     errUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		lookPath = exec.LookPath
+		if tt.lookPath != nil {
+			lookPath = tt.lookPath
+		}
+		r, err := decompress(bytes.NewReader(tt.in), tt.ext)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: decompress() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if tt.wantRead {
+			if err == nil {
+				t.Errorf("%s: ReadAll() returned nil, want error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ReadAll() returned %v", tt.desc, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decompress() contents do not match", tt.desc)
+		}
+	}
+	lookPath = exec.LookPath
+}
+
+// TestDecompressCommand decompresses with the xz command, when it is
+// installed.
+func TestDecompressCommand(t *testing.T) {
+	bin, err := exec.LookPath("xz")
+	if err != nil {
+		t.Skip("xz is not installed")
+	}
+	want := bytes.Repeat([]byte("fresnel"), 1024)
+	cmd := exec.Command(bin, "-z", "-c")
+	cmd.Stdin = bytes.NewReader(want)
+	xz, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("xz -z returned %v", err)
+	}
+
+	r, err := decompress(bytes.NewReader(xz), ".xz")
+	if err != nil {
+		t.Fatalf("decompress() returned %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Errorf("ReadAll() returned %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decompress() contents do not match")
+	}
+
+	// Corrupt input is reported rather than ending the image early.
+	r, err = decompress(bytes.NewReader(xz[:len(xz)/2]), ".xz")
+	if err != nil {
+		t.Fatalf("decompress() returned %v", err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Errorf("ReadAll() for truncated input returned nil, want error")
+	}
+	r.Close()
+}
@@ -0,0 +1,224 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/models"
+)
+
+// pinnedImage obtains the image manifest for the distribution and returns the
+// image it requires for the configured track, or the previous image for the
+// track when rolling back. It returns nil when the
+// distribution does not publish a manifest or the manifest does not list the
+// track. A manifest that is configured but cannot be obtained is an error, as
+// provisioning an unverified image is what the manifest exists to prevent.
+func (i *Installer) pinnedImage() (*models.TrackImage, error) {
+	path := i.config.DigestsPath()
+	if path == "" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := i.fetch(path, &buf); err != nil {
+		return nil, fmt.Errorf("%w: obtaining image manifest: %v", errDigest, err)
+	}
+	manifest := &models.ImageManifest{}
+	if err := json.Unmarshal(buf.Bytes(), manifest); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal(%q) returned %v", errDigest, path, err)
+	}
+	if i.config.Rollback() {
+		return i.rollback(manifest)
+	}
+	pinned, ok := manifest.Tracks[i.config.Track()]
+	if !ok {
+		deck.InfofA("Image manifest %q does not list track %q, no image is required.", path, i.config.Track()).With(debug.V(debug.Network, 2)).Go()
+		return nil, nil
+	}
+	if d, err := hex.DecodeString(pinned.Digest); err != nil || len(d) != sha256.Size {
+		return nil, fmt.Errorf("%w: image manifest %q lists an invalid digest %q for track %q", errDigest, path, pinned.Digest, i.config.Track())
+	}
+	// The manifest pins a build, so a track configured with a different image
+	// could never satisfy it.
+	if pinned.File != "" && imagefile.Name(pinned.File) != i.config.ImageFile() {
+		return nil, fmt.Errorf("%w: image manifest %q requires %q for track %q, but %q is configured", errDigest, path, pinned.File, i.config.Track(), i.config.ImageFile())
+	}
+	deck.InfofA("Track %q requires image digest %q.", i.config.Track(), pinned.Digest).With(deck.V(1)).Go()
+	return &pinned, nil
+}
+
+// rollback selects the most recent previous image for the configured track
+// from manifest, and replaces the image of the installer configuration with
+// it.
+func (i *Installer) rollback(manifest *models.ImageManifest) (*models.TrackImage, error) {
+	track := i.config.Track()
+	previous := manifest.Previous[track]
+	if len(previous) == 0 {
+		return nil, fmt.Errorf("%w: image manifest %q lists no previous image for track %q to roll back to", errDigest, i.config.DigestsPath(), track)
+	}
+	image := previous[0]
+	if d, err := hex.DecodeString(image.Digest); err != nil || len(d) != sha256.Size || image.File == "" {
+		return nil, fmt.Errorf("%w: image manifest %q lists an invalid previous image %+v for track %q", errDigest, i.config.DigestsPath(), image, track)
+	}
+	i.config = &rollbackConfig{Configuration: i.config, image: image}
+	console.Printf("Rolling back track %q to the previous image %q.", track, image.File)
+	deck.InfofA("Rolling back track %q to %q with digest %q.", track, i.config.ImagePath(), image.Digest).With(deck.V(1)).Go()
+	return &image, nil
+}
+
+// rollbackConfig replaces the image of a configuration with a previous image
+// listed in the image manifest. The files listed in the manifest are relative
+// to the manifest itself.
+type rollbackConfig struct {
+	Configuration
+	image models.TrackImage
+}
+
+// ImageFile returns the file name of the previous image.
+func (c *rollbackConfig) ImageFile() string {
+	return imagefile.Name(c.image.File)
+}
+
+// ImagePath returns the full path to the previous image.
+func (c *rollbackConfig) ImagePath() string {
+	manifest := c.Configuration.DigestsPath()
+	return manifest[:strings.LastIndex(manifest, "/")+1] + c.image.File
+}
+
+// ImageMirrors returns no mirrors, as they are not required to retain
+// previous images.
+func (c *rollbackConfig) ImageMirrors() []string {
+	return nil
+}
+
+// imageChecksum obtains the companion checksum file published alongside the
+// image and returns the digest that it lists. It returns an empty digest when
+// the distribution does not publish checksums. The file may contain just the
+// hex encoded SHA-256 digest, or the output of sha256sum for the image. It is
+// obtained from the first image server that provides it.
+func (i *Installer) imageChecksum() (string, error) {
+	if !i.config.Checksums() {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	var sum string
+	var err error
+	for n, p := range i.imagePaths() {
+		sum = checksumPath(p)
+		buf.Reset()
+		if err = i.fetch(sum, &buf); err == nil {
+			break
+		}
+		if n < len(i.imagePaths())-1 {
+			deck.Warningf("Obtaining checksum %q failed, trying the next image server: %v", imagefile.Redact(sum), err)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: obtaining checksum: %v", errDigest, err)
+	}
+	sum = imagefile.Redact(sum)
+	fields := strings.Fields(buf.String())
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: checksum %q is empty", errDigest, sum)
+	}
+	if d, err := hex.DecodeString(fields[0]); err != nil || len(d) != sha256.Size {
+		return "", fmt.Errorf("%w: checksum %q lists an invalid digest %q", errDigest, sum, fields[0])
+	}
+	// sha256sum marks binary files with a leading asterisk.
+	if len(fields) > 1 && imagefile.Name(strings.TrimPrefix(fields[1], "*")) != i.config.ImageFile() {
+		return "", fmt.Errorf("%w: checksum %q is for %q, not %q", errDigest, sum, fields[1], i.config.ImageFile())
+	}
+	deck.InfofA("Image %q has published digest %q.", i.config.ImageFile(), fields[0]).With(deck.V(1)).Go()
+	return fields[0], nil
+}
+
+// checksumPath returns the path of the companion checksum of the image at p,
+// which keeps the query string of an image URL.
+func checksumPath(p string) string {
+	u, err := url.Parse(p)
+	if err != nil || u.RawQuery == "" {
+		return p + checksumSuffix
+	}
+	u.Path += checksumSuffix
+	u.RawPath = ""
+	return u.String()
+}
+
+// verifyImage returns an error if the image at path does not match the
+// digest required by the image manifest or its published checksum, if any.
+func (i *Installer) verifyImage(path string) error {
+	var want []string
+	if i.pinned != nil {
+		want = append(want, i.pinned.Digest)
+	}
+	if i.digest != "" {
+		want = append(want, i.digest)
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	hash, err := i.fileHash(path, models.HashSHA256)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errDigest, err)
+	}
+	got := hex.EncodeToString(hash)
+	for _, w := range want {
+		if !strings.EqualFold(got, w) {
+			return fmt.Errorf("%w: %q has digest %q, want %q", errDigest, path, got, w)
+		}
+	}
+	return nil
+}
+
+// retrieveImage downloads the image for the configured track and verifies it
+// against the image manifest. An image that does not match is downloaded
+// once more, as a new build may not yet have reached every server, and is
+// removed from the cache if it still does not match.
+func (i *Installer) retrieveImage() error {
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	if err := i.retrieveFromSources(i.config.ImageFile(), i.imagePaths()); err != nil {
+		return err
+	}
+	err := i.verifyImage(path)
+	if err == nil {
+		return nil
+	}
+	deck.Warningf("%v, downloading the image again.", err)
+	// Remove the image so that one reused from a cache directory is not
+	// reused again.
+	os.Remove(path)
+	delete(i.hashes, path)
+	if err := i.retrieveFromSources(i.config.ImageFile(), i.imagePaths()); err != nil {
+		return err
+	}
+	if err := i.verifyImage(path); err != nil {
+		os.Remove(path)
+		delete(i.hashes, path)
+		return fmt.Errorf("refusing to provision %q: %w", i.config.ImageFile(), err)
+	}
+	return nil
+}
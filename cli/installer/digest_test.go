@@ -0,0 +1,323 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+// digestOf returns the hex encoded SHA-256 digest of s.
+func digestOf(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestPinnedImage(t *testing.T) {
+	good := digestOf("image")
+	tests := []struct {
+		desc     string
+		config   *fakeConfig
+		manifest string
+		dlErr    error
+		want     string // The pinned digest, empty for none.
+		wantErr  error
+	}{
+		{
+			desc:   "no manifest",
+			config: &fakeConfig{track: "stable"},
+		},
+		{
+			desc:    "download error",
+			config:  &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable"},
+			dlErr:   errors.New("error"),
+			wantErr: errDigest,
+		},
+		{
+			desc:     "invalid json",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable"},
+			manifest: "{",
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "track not listed",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable"},
+			manifest: `{"Tracks": {"unstable": {"Digest": "` + good + `"}}}`,
+		},
+		{
+			desc:     "invalid digest",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable"},
+			manifest: `{"Tracks": {"stable": {"Digest": "abc"}}}`,
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "different file",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable", imageFile: "a.iso"},
+			manifest: `{"Tracks": {"stable": {"File": "b.iso", "Digest": "` + good + `"}}}`,
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "no previous image",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable", rollback: true},
+			manifest: `{"Tracks": {"stable": {"Digest": "` + good + `"}}}`,
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "invalid previous image",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable", rollback: true},
+			manifest: `{"Previous": {"stable": [{"Digest": "` + good + `"}]}}`,
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "rollback",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/images/digests.json", track: "stable", rollback: true, imageFile: "b.iso"},
+			manifest: `{"Previous": {"stable": [{"File": "old/a.iso", "Digest": "` + good + `"}, {"File": "older.iso", "Digest": "` + digestOf("older") + `"}]}}`,
+			want:     good,
+		},
+		{
+			desc:     "pinned",
+			config:   &fakeConfig{digestsPath: "https://foo.bar/digests.json", track: "stable", imageFile: "a.iso"},
+			manifest: `{"Tracks": {"stable": {"File": "folder/a.iso", "Digest": "` + good + `"}}}`,
+			want:     good,
+		},
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	for _, tt := range tests {
+		manifest, dlErr := tt.manifest, tt.dlErr
+		downloadFile = func(_ httpDoer, _ string, w io.Writer) error {
+			if dlErr != nil {
+				return dlErr
+			}
+			_, err := io.WriteString(w, manifest)
+			return err
+		}
+		i := &Installer{config: tt.config}
+		got, err := i.pinnedImage()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: pinnedImage() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if (got == nil) != (tt.want == "") || (got != nil && got.Digest != tt.want) {
+			t.Errorf("%s: pinnedImage() got: %+v, want digest: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestImageChecksum(t *testing.T) {
+	good := digestOf("image")
+	tests := []struct {
+		desc     string
+		config   *fakeConfig
+		checksum string
+		dlErr    error
+		want     string
+		wantErr  error
+	}{
+		{
+			desc:   "not published",
+			config: &fakeConfig{imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+		},
+		{
+			desc:    "download error",
+			config:  &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			dlErr:   errors.New("error"),
+			wantErr: errDigest,
+		},
+		{
+			desc:    "empty",
+			config:  &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			wantErr: errDigest,
+		},
+		{
+			desc:     "invalid digest",
+			config:   &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			checksum: "abc  a.iso\n",
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "different file",
+			config:   &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			checksum: good + "  b.iso\n",
+			wantErr:  errDigest,
+		},
+		{
+			desc:     "digest only",
+			config:   &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			checksum: good + "\n",
+			want:     good,
+		},
+		{
+			desc:     "sha256sum binary mode",
+			config:   &fakeConfig{checksums: true, imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"},
+			checksum: good + " *out/a.iso\n",
+			want:     good,
+		},
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	for _, tt := range tests {
+		checksum, dlErr := tt.checksum, tt.dlErr
+		var gotPath string
+		downloadFile = func(_ httpDoer, path string, w io.Writer) error {
+			gotPath = path
+			if dlErr != nil {
+				return dlErr
+			}
+			_, err := io.WriteString(w, checksum)
+			return err
+		}
+		i := &Installer{config: tt.config}
+		got, err := i.imageChecksum()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: imageChecksum() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: imageChecksum() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+		if tt.config.checksums && gotPath != "https://foo.bar/a.iso.sha256" {
+			t.Errorf("%s: imageChecksum() downloaded %q, want: %q", tt.desc, gotPath, "https://foo.bar/a.iso.sha256")
+		}
+	}
+}
+
+func TestChecksumPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "https://foo.bar/a.iso", want: "https://foo.bar/a.iso.sha256"},
+		{path: "https://foo.bar/a.iso?generation=2", want: "https://foo.bar/a.iso.sha256?generation=2"},
+		{path: "https://foo.bar/my%20image.iso?generation=2", want: "https://foo.bar/my%20image.iso.sha256?generation=2"},
+		{path: "gs://bucket/a.iso", want: "gs://bucket/a.iso.sha256"},
+	}
+	for _, tt := range tests {
+		if got := checksumPath(tt.path); got != tt.want {
+			t.Errorf("checksumPath(%q) got: %q, want: %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRollbackConfig(t *testing.T) {
+	c := &rollbackConfig{
+		Configuration: &fakeConfig{digestsPath: "https://foo.bar/images/digests.json", imageFile: "new.iso"},
+		image:         models.TrackImage{File: "old/installer.iso"},
+	}
+	if got, want := c.ImageFile(), "installer.iso"; got != want {
+		t.Errorf("ImageFile() got: %q, want: %q", got, want)
+	}
+	if got, want := c.ImagePath(), "https://foo.bar/images/old/installer.iso"; got != want {
+		t.Errorf("ImagePath() got: %q, want: %q", got, want)
+	}
+}
+
+func TestRetrieveImage(t *testing.T) {
+	fakeCache, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(fakeCache)
+	path := filepath.Join(fakeCache, "a.iso")
+
+	tests := []struct {
+		desc      string
+		pinned    string   // The digest required, empty for none.
+		digest    string   // The digest published alongside the image, empty for none.
+		downloads []string // The contents served by each download.
+		want      error
+		wantFile  bool
+		wantTries int
+	}{
+		{
+			desc:      "not pinned",
+			downloads: []string{"old"},
+			wantFile:  true,
+			wantTries: 1,
+		},
+		{
+			desc:      "match",
+			pinned:    digestOf("new"),
+			downloads: []string{"new"},
+			wantFile:  true,
+			wantTries: 1,
+		},
+		{
+			desc:      "match on second download",
+			pinned:    digestOf("new"),
+			downloads: []string{"old", "new"},
+			wantFile:  true,
+			wantTries: 2,
+		},
+		{
+			desc:      "mismatch",
+			pinned:    digestOf("new"),
+			downloads: []string{"old", "old"},
+			want:      errDigest,
+			wantFile:  false,
+			wantTries: 2,
+		}, {
+			desc:      "truncated download",
+			digest:    digestOf("new"),
+			downloads: []string{"ne", "ne"},
+			want:      errDigest,
+			wantFile:  false,
+			wantTries: 2,
+		},
+		{
+			desc:      "pinned and published",
+			pinned:    digestOf("new"),
+			digest:    digestOf("new"),
+			downloads: []string{"new"},
+			wantFile:  true,
+			wantTries: 1,
+		},
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	for _, tt := range tests {
+		tries := 0
+		downloads := tt.downloads
+		downloadFile = func(_ httpDoer, _ string, w io.Writer) error {
+			contents := downloads[tries]
+			tries++
+			_, err := io.WriteString(w, contents)
+			return err
+		}
+		i := &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "a.iso", imagePath: "https://foo.bar/a.iso"}}
+		if tt.pinned != "" {
+			i.pinned = &models.TrackImage{Digest: tt.pinned}
+		}
+		i.digest = tt.digest
+		got := i.retrieveImage()
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: retrieveImage() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if tries != tt.wantTries {
+			t.Errorf("%s: retrieveImage() downloaded %d times, want: %d", tt.desc, tries, tt.wantTries)
+		}
+		if _, err := os.Stat(path); (err == nil) != tt.wantFile {
+			t.Errorf("%s: retrieveImage() left image in cache: %t, want: %t", tt.desc, err == nil, tt.wantFile)
+		}
+		// Prepare refuses an image that does not match.
+		if err := i.verifyImage(path); tt.want == nil && err != nil {
+			t.Errorf("%s: verifyImage() returned %v", tt.desc, err)
+		}
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+)
+
+// The methods by which the user requesting the installer is identified. The
+// username is recorded in the seeds that are issued, so the method is logged
+// alongside it.
+const (
+	identityCurrent = "the current user"
+	identitySudo    = "SUDO_USER"
+	identityDoas    = "DOAS_USER"
+	identityPkexec  = "PKEXEC_UID"
+
+	identityImpersonated = "service account impersonation"
+)
+
+// emailScope is the OAuth scope that the seed and sign servers require to
+// identify the principal that a request is made as.
+const emailScope = "https://www.googleapis.com/auth/userinfo.email"
+
+// seedUser returns the name that seeds are requested under. When a service
+// account is impersonated, seeds are issued to the principal it acts as: the
+// delegated user if one is configured, and otherwise the service account
+// itself. Otherwise, the user requesting the installer is returned.
+func seedUser(c Configuration) (string, error) {
+	if c.Impersonate() == "" {
+		return username(c.StripDomain())
+	}
+	name := c.Delegate()
+	if name == "" {
+		name = c.Impersonate()
+	}
+	deck.InfofA("Identified user %q using %s of %q.", name, identityImpersonated, c.Impersonate()).With(deck.V(1)).Go()
+	return name, nil
+}
+
+// machineMACs returns the MAC addresses of the physical network interfaces of
+// the machine, which identify it in sign requests. The sign server does not
+// require them, so requests are made without them when they cannot be found.
+func machineMACs() []string {
+	macs, err := interfaceMACs()
+	if err != nil {
+		deck.Warningf("MAC addresses will not be sent with sign requests: %v", err)
+		return nil
+	}
+	deck.InfofA("Identified MAC addresses %q.", macs).With(debug.V(debug.Network, 2)).Go()
+	return macs
+}
+
+// connectAs returns an httpDoer that authenticates to url as user, or as the
+// impersonated service account when one is configured, in which case user
+// is already the principal that the service account acts as.
+func connectAs(c Configuration, url, user string) (httpDoer, error) {
+	if c.Impersonate() == "" {
+		return connect(url, user, c.Proxy())
+	}
+	return connectImpersonated(c.Impersonate(), c.Delegate(), c.Proxy())
+}
+
+// impersonatedClient returns an httpDoer that authenticates as account, acting
+// as user through domain-wide delegation when user is provided. The
+// credentials of the environment, such as those of a scheduled job, are used
+// to impersonate the account, so that no interaction is required. A token is
+// obtained immediately so that missing permissions are reported before any
+// device is written. Tokens are obtained and requests are sent through proxy,
+// or through those of the environment when it is empty.
+func impersonatedClient(account, user, proxy string) (httpDoer, error) {
+	transport, err := proxyTransport(nil, proxy)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: account,
+		Scopes:          []string{emailScope},
+		Subject:         user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("impersonate.CredentialsTokenSource(%q) returned %v: %w", account, err, errConnect)
+	}
+	if _, err := ts.Token(); err != nil {
+		return nil, fmt.Errorf("impersonating %q returned %v: %w", account, err, errConnect)
+	}
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+// username obtains the username of the user requesting the installer. When
+// the binary is running as root through sudo, doas or pkexec, the user who
+// invoked them is returned instead. The username of Windows accounts is
+// always returned as DOMAIN\user, unless stripDomain is set, in which case
+// the domain is removed.
+func username(stripDomain bool) (string, error) {
+	name, method, err := invokingUser()
+	if err != nil {
+		return "", err
+	}
+	name = normalizeUser(name, stripDomain)
+	if name == "" {
+		return "", errEmptyUser
+	}
+	deck.InfofA("Identified user %q using %s.", name, method).With(deck.V(1)).Go()
+	return name, nil
+}
+
+// invokingUser returns the name of the user who ran the binary, and the
+// method used to identify them.
+func invokingUser() (string, string, error) {
+	u, err := currentUser()
+	if err != nil {
+		return "", "", fmt.Errorf("user.Current returned %v: %w", err, errUser)
+	}
+	if u.Username != "root" {
+		return u.Username, identityCurrent, nil
+	}
+	if name := os.Getenv(identitySudo); name != "" {
+		return name, identitySudo, nil
+	}
+	if name := os.Getenv(identityDoas); name != "" {
+		return name, identityDoas, nil
+	}
+	if uid := os.Getenv(identityPkexec); uid != "" {
+		pu, err := lookupUserID(uid)
+		if err != nil {
+			return "", "", fmt.Errorf("user.LookupId(%q) returned %v: %w", uid, err, errUser)
+		}
+		return pu.Username, identityPkexec, nil
+	}
+	// Root itself is not a person that a seed can be issued to.
+	return "", identityCurrent, errEmptyUser
+}
+
+// normalizeUser returns name with the domain in upper case, or without a
+// domain when stripDomain is set. Domains may be provided either as
+// DOMAIN\user or as user@domain.
+func normalizeUser(name string, stripDomain bool) string {
+	name = strings.TrimSpace(name)
+	domain := ""
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		domain, name = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, "@"); i >= 0 {
+		name, domain = name[:i], name[i+1:]
+		// The NetBIOS name is approximated by the first label of a DNS domain.
+		domain = strings.SplitN(domain, ".", 2)[0]
+	}
+	if stripDomain || domain == "" || name == "" {
+		return name
+	}
+	return strings.ToUpper(domain) + `\` + name
+}
@@ -23,17 +23,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
-	"regexp"
-	"runtime"
 	"strings"
+	"time"
 
+	"github.com/google/fresnel/cli/config"
 	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/netinfo"
+	"github.com/google/fresnel/cli/trace"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/cli/version"
+	"github.com/google/fresnel/client"
 	"github.com/google/fresnel/models"
 	"github.com/google/deck"
 	"github.com/dustin/go-humanize"
@@ -44,20 +53,41 @@ import (
 )
 
 const (
-	oneGB        = uint64(1073741824)
-	seedDestFile = `seed.json`
-	confDestFile = `startimage.yaml`
+	seedDestFile     = `seed.json`
+	confDestFile     = `startimage.yaml`
+	manifestDestFile = `manifest.json`
+	batchDestFile    = `batch.json`
+	bundleDestFile   = `seeds.json`
+	cacheReadmeFile  = `README.txt`
+	tmpSuffix        = `.tmp`
+	checksumSuffix   = `.sha256`
 )
 
 var (
 	// Dependency injections for testing.
-	currentUser     = user.Current
-	connect         = fetcherConnect
-	connectWithCert = tlsConnect
-	downloadFile    = download
-	mount           = mountISO
-	selectPart      = selectPartition
-	writeISOFunc    = writeISO
+	currentUser         = user.Current
+	lookupUserID        = user.LookupId
+	connect             = fetcherConnect
+	connectImpersonated = impersonatedClient
+	connectWithCert     = tlsConnect
+	downloadFile        = download
+	mount               = mountISO
+	selectPart          = selectPartition
+	selectMarkerPart    = selectPartitionQuietly
+	writeISOFunc        = writeISO
+	updateISOFunc       = updateISO
+	openDevice          = openRawDevice
+	discard             = discardDevice
+	zeroOut             = zeroRange
+	applyWIM            = dismApplyImage
+	applyFFU            = dismApplyFFU
+	extendFFU           = extendPartition
+	makeBootable        = bcdboot
+	partitionForApply   = partitionApply
+	partitionForSplit   = partitionSplit
+	splitWIM            = splitImage
+	makePersistence     = persistencePartition
+	interfaceMACs       = netinfo.MACs
 
 	// Wrapped errors for testing.
 	errCache       = errors.New("missing cache")
@@ -67,6 +97,7 @@ var (
 	errConnect     = errors.New("connect error")
 	errDownload    = errors.New("download error")
 	errDevice      = errors.New("device error")
+	errDigest      = errors.New("image digest error")
 	errElevation   = errors.New("elevation is required for this operation")
 	errEmpty       = errors.New("iso is empty")
 	errEmptyUser   = errors.New("could not determine username")
@@ -82,25 +113,31 @@ var (
 	errPartition   = errors.New("partitioning error")
 	errPath        = errors.New("path error")
 	errPerm        = errors.New("permissions error")
-	errPost        = errors.New("http post error")
 	errPrepare     = errors.New("preparation error")
 	errProvision   = errors.New("provisioning error")
 	errRename      = errors.New("file rename error")
-	errResponse    = errors.New("requested boot image is not in allowlist")
 	errStatus      = errors.New("invalid status code")
-	errSeed        = errors.New("invalid seed response")
+	errTooLarge    = errors.New("file too large for FAT32")
 	errUnmarshal   = errors.New("unmarshalling error")
 	errUnsupported = errors.New("unsupported")
 	errUser        = errors.New("user detection error")
+	errVerify      = errors.New("verification error")
 	errWipe        = errors.New("device wipe error")
 	errYAML        = errors.New("yaml retrieval error")
 
 	// ErrLabel is made public to that callers can warn on mismatches.
 	ErrLabel = errors.New(`label error`)
+	// ErrWriteProtected is made public so that callers can exclude devices that
+	// cannot be written to.
+	ErrWriteProtected = errors.New("device is write protected")
 
-	// Regex for file matching.
-	regExFileExt  = regexp.MustCompile(`\.[A-Za-z.]+`)
-	regExFileName = regexp.MustCompile(`[\w,\s-]+\.[A-Za-z.]+$`)
+	// partitionFileSystems maps the roles of partitions that files from ISO
+	// images can be placed on to the file system used to select them. The
+	// file system of the data partition can be set by the distribution.
+	partitionFileSystems = map[string]storage.FileSystem{
+		config.BootPartition: storage.FAT32,
+		config.DataPartition: storage.NTFS,
+	}
 )
 
 // httpDoer represents an http client that can retrieve files with the Do
@@ -111,19 +148,60 @@ type httpDoer interface {
 
 // Configuration represents config.Configuration.
 type Configuration interface {
+	Apply() bool
+	ApplyIndex() int
+	Checksums() bool
 	ConfFile() string
+	DigestsPath() string
+	Distro() string
 	DistroLabel() string
 	ImagePath() string
+	ImageMirrors() []string
 	ImageFile() string
-	Elevated() bool
+	StripDomain() bool
+	CanMount() error
+	CanWriteDevice() error
 	FFU() bool
 	PowerOff() bool
 	SeedDest() string
 	SeedFile() string
+	SeedFiles() []string
+	SeedHash() string
 	SeedServer() string
+	SeedRequired() bool
 	UpdateOnly() bool
+	SparseWrite() bool
+	Rollback() bool
+	Track() string
+	Trim() bool
 	FFUConfFile() string
 	FFUConfPath() string
+	SignServer() string
+	SignedImages() string
+	ManifestFiles() []string
+	PartitionRules() []config.PartitionRule
+	DataFileSystem() string
+	Scanner() []string
+	Persistence() bool
+	PersistenceSize() units.Size
+	PersistenceLabel() string
+	DevicePolicy() config.DevicePolicy
+	PreserveAttributes() bool
+	SeedShelfLife() time.Duration
+	Batch() string
+	SeedFields() map[string]string
+	Cleanup() bool
+	CacheDir() string
+	CacheMaxAge() time.Duration
+	CacheMaxSize() units.Size
+	ContentsOnMedia() bool
+	LogOnMedia() bool
+	Unattend() string
+	UnattendDest() string
+	ProbeMirrors() bool
+	Impersonate() string
+	Delegate() string
+	Proxy() string
 }
 
 // Device represents storage.Device.
@@ -162,8 +240,31 @@ type isoHandler interface {
 
 // Installer represents an operating system installer.
 type Installer struct {
-	cache  string        // The path where temporary files are cached.
-	config Configuration // The configuration for this installer.
+	cache  string             // The path where temporary files are cached.
+	root   string             // The cache directory provided by the user, which holds an entry per image.
+	config Configuration      // The configuration for this installer.
+	hashes map[string][]byte  // SHA-256 hashes of downloaded files and of files copied from ISOs, by path.
+	pinned *models.TrackImage // The image required for the track by the image manifest, if any.
+	digest string             // The digest of the image published alongside it, if any.
+	seeds  map[seedKey][]byte // Hashes of seed files, reused when provisioning several devices.
+	expiry time.Time          // The expiry of the seed written to the device last provisioned.
+	reuse  bool               // Whether the cache was provided by the user, and its files are reused.
+	// contents is the manifest of the files written to the device last
+	// provisioned from an ISO, if any.
+	contents *models.ContentManifest
+	// written are the partitions that an ISO was last written to, by role, so
+	// that they can be verified.
+	written map[string]partition
+	// sources are the paths that the image is obtained from, in the order
+	// they are tried.
+	sources []string
+}
+
+// seedKey identifies the hash of a seed file within an image.
+type seedKey struct {
+	image string
+	file  string
+	alg   models.HashAlgorithm
 }
 
 // New generates a new Installer from a configuration, with all the
@@ -175,10 +276,27 @@ func New(config Configuration) (*Installer, error) {
 
 	// Connect serves only to give an early warning if the SSO token is expired.
 	// It is only called if the config specifies that a seed is required.
-	if config.SeedServer() != "" {
-		if _, err := connect(config.ImagePath(), ""); err != nil {
-			return nil, fmt.Errorf("fetcher.Connect(%q) returned %v: %w", config.ImagePath(), err, errConnect)
+	if config.SeedRequired() {
+		if _, err := connectAs(config, config.ImagePath(), ""); err != nil {
+			return nil, fmt.Errorf("fetcher.Connect(%q) returned %v: %w", imagefile.Redact(config.ImagePath()), redactURLError(err), errConnect)
+		}
+	}
+
+	// Reuse the files retained by an earlier run when a cache directory is
+	// provided. It is never removed by Finalize.
+	if dir := config.CacheDir(); dir != "" {
+		// Permissions = owner:read/write/execute, group:read/execute"
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dir, err, errPath)
 		}
+		deck.InfofA("Using cache directory %q.", dir).With(deck.V(1)).Go()
+		return &Installer{
+			cache:  dir,
+			root:   dir,
+			config: config,
+			hashes: make(map[string][]byte),
+			reuse:  true,
+		}, nil
 	}
 
 	// Create a folder for temporary files. We do not need to worry about
@@ -192,34 +310,30 @@ func New(config Configuration) (*Installer, error) {
 	return &Installer{
 		cache:  temp,
 		config: config,
+		hashes: make(map[string][]byte),
 	}, nil
 }
 
-// fetcherConnect wraps fetcher.Connect and returns an httpDoer.
-func fetcherConnect(path, user string) (httpDoer, error) {
-	return fetcher.Connect(path, user)
-}
-
-// tlsConnect wraps fetcher.TLSClient and returns an httpDoer.
-func tlsConnect() (httpDoer, error) {
-	return fetcher.TLSClient(nil, nil)
+// fetcherConnect wraps fetcher.Connect and returns an httpDoer that sends its
+// requests through proxy, or through those of the environment when it is
+// empty.
+func fetcherConnect(path, user, proxy string) (httpDoer, error) {
+	client, err := fetcher.Connect(path, user)
+	if err != nil {
+		return nil, err
+	}
+	return withProxy(client, proxy)
 }
 
-// username obtains the username of the user requesting the installer. If the
-// binary is running under sudo, the user who ran sudo is returned instead.
-func username() (string, error) {
-	u, err := currentUser()
+// tlsConnect wraps fetcher.TLSClient and returns an httpDoer that sends its
+// requests through proxy, or through those of the environment when it is
+// empty.
+func tlsConnect(proxy string) (httpDoer, error) {
+	client, err := fetcher.TLSClient(nil, nil)
 	if err != nil {
-		return "", fmt.Errorf("user.Current returned %v: %w", err, errUser)
-	}
-	username := u.Username
-	if username == "root" {
-		username = os.Getenv("SUDO_USER")
+		return nil, err
 	}
-	if username == "" {
-		return "", errEmptyUser
-	}
-	return username, nil
+	return withProxy(client, proxy)
 }
 
 // retrieveFile locates and obtains the files,
@@ -227,32 +341,80 @@ func username() (string, error) {
 // Where additional metadata should be obtained or checked
 // (such as a signature or a seed) prior to returning.
 func (i *Installer) retrieveFile(fileName, filePath string) (err error) {
+	span := trace.Begin("download", trace.Attr("file", fileName))
+	defer func() { span.End(err) }()
 	path := filepath.Join(i.cache, fileName)
-	f, err := os.Create(path)
+	// Files are downloaded under a temporary name and only renamed once the
+	// download is complete, so that an interrupted download never leaves a
+	// truncated file that passes the existence checks in Prepare and Provision.
+	// Files already present under either name were not written by this
+	// download and cannot be trusted, so they are removed first.
+	tmp := path + tmpSuffix
+	// Files in a cache directory were retained by an earlier run, and are
+	// reused. Images are still checked against the image manifest.
+	if i.reuse {
+		if _, err := os.Stat(path); err == nil {
+			return i.reuseFile(path)
+		}
+	}
+	for _, p := range []string{path, tmp} {
+		if err := os.Remove(p); err == nil {
+			deck.Warningf("Removed unexpected file %q from the cache.", p)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove(%q) returned %w: %v", p, errFile, err)
+		}
+	}
+	f, err := os.Create(tmp)
 	if err != nil {
-		return fmt.Errorf("ioutil.TempFile(%q, %q) returned %w: %v", i.cache, fileName, errFile, err)
+		return fmt.Errorf("os.Create(%q) returned %w: %v", tmp, errFile, err)
 	}
-	// Close the file on return.
+	// Discard the partial file if the download does not complete.
 	defer func() {
-		if err2 := f.Close(); err2 != nil {
-			if err != nil {
-				err = fmt.Errorf("%w %v", err2, err)
-				return
-			}
-			err = err2
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
 		}
 	}()
 
-	// Connect to the download server and retrieve the file.
-	client, err := connectWithCert()
+	// Retrieve the file, hashing it as it is written so that it does not need
+	// to be read again when the hash is needed later.
+	hf := &hashedFile{f: f, h: sha256.New()}
+	if err := i.fetch(filePath, hf); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Close() for %q returned %w: %v", tmp, errFile, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("os.Rename(%q, %q) returned %w: %v", tmp, path, errFile, err)
+	}
+	if i.hashes == nil {
+		i.hashes = make(map[string][]byte)
+	}
+	i.hashes[path] = hf.Sum()
+	deck.InfofA("Hashed %q during download: %q.", path, hex.EncodeToString(i.hashes[path])).With(debug.V(debug.Network, 2)).Go()
+	return nil
+}
+
+// reuseFile records the hash of a file retained in the cache directory by an
+// earlier run, in place of downloading it.
+func (i *Installer) reuseFile(path string) error {
+	hash, err := fileHash(path, models.HashSHA256)
 	if err != nil {
-		return fmt.Errorf("fetcher.TLSClient() returned %w: %v", errConnect, err)
+		return fmt.Errorf("fileHash(%q) returned %w: %v", path, errFile, err)
 	}
-	return downloadFile(client, filePath, f)
+	if i.hashes == nil {
+		i.hashes = make(map[string][]byte)
+	}
+	i.hashes[path] = hash
+	console.Printf("Reusing %q from the cache directory.", path)
+	deck.InfofA("Reusing %q from the cache directory with hash %q.", path, hex.EncodeToString(hash)).With(deck.V(1)).Go()
+	return nil
 }
 
 // Retrieve passes the necessary parameters to retrieveFile
-// depending on whether or not the distribution will be FFU based.
+// depending on whether or not the distribution will be FFU based. The
+// retrieved files are then scanned when a scanner is configured.
 func (i *Installer) Retrieve() (err error) {
 	// Confirm that the Installer has what we need.
 	if i.config.ImagePath() == "" {
@@ -262,27 +424,58 @@ func (i *Installer) Retrieve() (err error) {
 		return errCache
 	}
 
-	// If FFU is false, retrieve only the image file.
-	// Otherwise retrieve the image file and FFU manifest.
-	if !i.config.FFU() {
-		return i.retrieveFile(i.config.ImageFile(), i.config.ImagePath())
+	// Determine whether a specific image is required before downloading
+	// anything, so that a misconfigured track is reported early.
+	if i.pinned, err = i.pinnedImage(); err != nil {
+		return err
+	}
+	// Images stored in the bucket of the sign server are obtained through a
+	// signed URL in place of the image server and its mirrors, and are
+	// verified against the digest in the image manifest alone. Images that
+	// are configured with a signed URL of their own need no other.
+	if i.config.SignedImages() != "" && !imagefile.Signed(i.config.ImagePath()) {
+		signed, err := i.signedImageURL()
+		if err != nil {
+			return err
+		}
+		i.sources = []string{signed}
+	} else {
+		i.sources = i.imageSources()
+		if i.digest, err = i.imageChecksum(); err != nil {
+			return err
+		}
 	}
-
-	// Check for missing conf file name.
-	if i.config.FFUConfFile() == "" {
-		return errConfName
+	// Files are kept in the entry of the cache directory for the image, which
+	// depends on the hash that it is expected to have.
+	if i.reuse {
+		if err := i.useCacheEntry(); err != nil {
+			return err
+		}
 	}
 
-	// Check conf path configuration.
-	if i.config.FFUConfPath() == "" {
-		return errConfPath
-	}
+	// If FFU is set, retrieve the FFU manifest as well as the image file.
+	if i.config.FFU() {
+		// Check for missing conf file name.
+		if i.config.FFUConfFile() == "" {
+			return errConfName
+		}
+
+		// Check conf path configuration.
+		if i.config.FFUConfPath() == "" {
+			return errConfPath
+		}
 
-	if err := i.retrieveFile(i.config.FFUConfFile(), i.config.FFUConfPath()); err != nil {
-		return fmt.Errorf("%w: %v", errYAML, err)
+		if err := i.retrieveFile(i.config.FFUConfFile(), i.config.FFUConfPath()); err != nil {
+			return fmt.Errorf("%w: %v", errYAML, err)
+		}
 	}
 
-	return i.retrieveFile(i.config.ImageFile(), i.config.ImagePath())
+	if err := i.retrieveImage(); err != nil {
+		return err
+	}
+	// Files are scanned once they are complete, whether or not they were
+	// reused from the cache directory.
+	return i.scanPayloads()
 }
 
 // download obtains the installer using the provided client and writes it
@@ -300,33 +493,62 @@ func download(client httpDoer, path string, w io.Writer) error {
 		return fmt.Errorf("no file to write to: %w", errFile)
 	}
 
+	// The tokens of signed URLs are kept out of logs and errors.
+	shown := imagefile.Redact(path)
+
 	// Obtain the file including status updates.
 	req, err := http.NewRequest("GET", path, nil)
 	if err != nil {
-		return fmt.Errorf(`http.NewRequest("GET", %q, nil) returned %v`, path, err)
+		return fmt.Errorf(`http.NewRequest("GET", %q, nil) returned %v`, shown, redactURLError(err))
 	}
+	version.SetHeaders(req)
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("get for %q returned %v: %w", path, err, errDownload)
+		return fmt.Errorf("get for %q returned %v: %w", shown, redactURLError(err), errDownload)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%w for %q with response %d", errStatus, path, resp.StatusCode)
+		return fmt.Errorf("%w for %q with response %d", errStatus, shown, resp.StatusCode)
+	}
+
+	// Servers that name the file, such as those behind redirects to signed
+	// URLs, are taken at their word for the label of the progress bar.
+	name := imagefile.FromContentDisposition(resp.Header.Get("Content-Disposition"))
+	if name == "" {
+		name = imagefile.Name(path)
 	}
+	return receive(w, resp.Body, resp.ContentLength, shown, name)
+}
 
+// receive writes the file at path from r to w, providing updates as it does
+// so. size is the expected size of the file, or -1 when it is unknown, and
+// fileName labels the updates.
+func receive(w io.Writer, r io.Reader, size int64, path, fileName string) error {
 	// Provide updates during the download.
-	fileName := regExFileName.FindString(path)
 	op := "\nDownload of " + fileName
-	r := console.ProgressReader(resp.Body, op, resp.ContentLength)
-	if _, err := io.Copy(w, r); err != nil {
+	r = console.ProgressReader(r, op, size)
+	// Reserve space for the file up front where the destination supports it.
+	if p, ok := w.(preallocator); ok && size > 0 {
+		if err := p.Preallocate(size); err != nil {
+			deck.InfofA("Preallocate(%d) for %q returned %v, continuing without preallocation.", size, fileName, err).With(debug.V(debug.Network, 2)).Go()
+		}
+	}
+	n, err := copyBuffer(w, r)
+	if err != nil {
 		return fmt.Errorf("failed to write body of %q, %v: %w", path, err, errIO)
 	}
+	// A body that ends early is not reported as an error by all servers.
+	if size > 0 && n != size {
+		return fmt.Errorf("%w: received %d of %d bytes of %q", errDownload, n, size, path)
+	}
 	return nil
 }
 
 // Prepare takes a device and prepares it for provisioning. It supports
 // device preparation based on the source image file format. Currently,
-// it supports preparation for the ISO and IMG (Raw) formats.
+// it supports preparation for the ISO and IMG (Raw) formats. VHD, VHDX,
+// qcow2 and compressed IMG images are prepared as raw images. WIM and FFU images are applied
+// directly to the device when apply is configured for the distribution.
 func (i *Installer) Prepare(d Device) error {
 	// Sanity check inputs.
 	if i.config == nil {
@@ -335,7 +557,9 @@ func (i *Installer) Prepare(d Device) error {
 	if i.config.ImageFile() == "" {
 		return fmt.Errorf("missing image: %w", errInput)
 	}
-	ext := regExFileExt.FindString(i.config.ImageFile())
+	i.expiry = time.Time{}
+	i.contents = nil
+	ext := imagefile.Ext(i.config.ImageFile())
 	if ext == "" {
 		return fmt.Errorf("could not find extension for %q: %w", i.config.ImageFile(), errFile)
 	}
@@ -343,11 +567,32 @@ func (i *Installer) Prepare(d Device) error {
 	if err != nil {
 		return fmt.Errorf("%v: %w", err, errPath)
 	}
+	// Refuse to touch the device if the image is not the one required. The
+	// hash of a retrieved image is the one computed while it was downloaded
+	// or reused, so the image is not read again for every device.
+	if err := i.verifyImage(filepath.Join(i.cache, i.config.ImageFile())); err != nil {
+		return err
+	}
+	// Persistence partitions follow the partitions of raw images, and are
+	// refused for other images before the device is touched.
+	if i.config.Persistence() && !isRawImage(ext) {
+		return fmt.Errorf("a persistence partition cannot be added after %q images: %w", ext, errUnsupported)
+	}
+	// Logs are stored on the boot partition written from ISO images, as the
+	// file systems of other images are not mounted.
+	if i.config.LogOnMedia() && ext != ".iso" {
+		return fmt.Errorf("the log cannot be stored on media provisioned from %q images: %w", ext, errUnsupported)
+	}
+	// Answer files are likewise copied to the boot partition written from ISO
+	// images.
+	if i.config.Unattend() != "" && ext != ".iso" {
+		return fmt.Errorf("an answer file cannot be added to media provisioned from %q images: %w", ext, errUnsupported)
+	}
 	// Compensate for very small image files that can cause the wrong partition
 	// to be selected.
 	size := uint64(f.Size())
-	if size < oneGB {
-		size = oneGB
+	if size < uint64(units.GB) {
+		size = uint64(units.GB)
 	}
 	// Prepare the devices for provisioning.
 	switch {
@@ -355,8 +600,10 @@ func (i *Installer) Prepare(d Device) error {
 		return i.prepareForISOWithoutElevation(d, size)
 	case ext == ".iso":
 		return i.prepareForISOWithElevation(d, size)
-	case ext == ".img":
+	case ext == ".img", ext == ".img.gz", ext == ".img.xz", ext == ".img.zst", ext == ".vhd", ext == ".vhdx", ext == ".qcow2":
 		return i.prepareForRaw(d)
+	case ext == ".wim", ext == ".ffu":
+		return i.prepareForApply(d)
 	}
 	return fmt.Errorf("%q is not a supported image type: %w", ext, errProvision)
 }
@@ -366,63 +613,72 @@ func (i *Installer) Prepare(d Device) error {
 // to be prepared for file copy operations. Elevated permissions are required
 // in order to prepare a device in this manner.
 func (i *Installer) prepareForISOWithElevation(d Device, size uint64) error {
-	deck.InfofA("Preparing %q for ISO with elevation.", d.FriendlyName()).With(deck.V(2)).Go()
-	if !i.config.Elevated() {
-		return errElevation
+	deck.InfofA("Preparing %q for ISO with elevation.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+	if err := i.config.CanMount(); err != nil {
+		return fmt.Errorf("%w: %v", errElevation, err)
 	}
 	// Preparing a device for an ISO follows these steps:
 	// Wipe -> Re-Partition -> Format
-	deck.InfofA("Wiping %q.", d.FriendlyName()).With(deck.V(2)).Go()
+	deck.InfofA("Wiping %q.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
 	if err := d.Wipe(); err != nil {
 		return fmt.Errorf("%w: Wipe() returned %v", errWipe, err)
 	}
-	deck.InfofA("Partitioning %q.", d.FriendlyName()).With(deck.V(2)).Go()
+	if i.splitLayout() {
+		return i.prepareSplit(d)
+	}
+	deck.InfofA("Partitioning %q.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
 	if err := d.Partition(i.config.DistroLabel()); err != nil {
 		return fmt.Errorf("Partition returned %v: %w", err, errPartition)
 	}
-	// Formatting is not needed on Darwin.
-	if runtime.GOOS == "darwin" {
+	if host.formatsOnPartition() {
+		deck.InfofA("Partition() formatted %q, skipping partition selection.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
 		return nil
 	}
-	deck.InfofA("Looking for a partition larger than %v on %q.", humanize.Bytes(size), d.FriendlyName()).With(deck.V(2)).Go()
+	deck.InfofA("Looking for a partition larger than %v on %q.", humanize.Bytes(size), d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
 	part, err := selectPart(d, size, "")
 	if err != nil {
 		return fmt.Errorf("SelectPartition(%d) returned %v: %w", size, err, errPrepare)
 	}
-	deck.InfofA("Formatting partition on %q and setting a label of %q.", d.FriendlyName(), i.config.DistroLabel()).With(deck.V(2)).Go()
+	deck.InfofA("Formatting partition on %q and setting a label of %q.", d.FriendlyName(), i.config.DistroLabel()).With(debug.V(debug.Storage, 2)).Go()
 	if err := part.Format(i.config.DistroLabel()); err != nil {
 		return fmt.Errorf("Format returned %v: %w", err, errFormat)
 	}
 	return nil
 }
 
+// fileSystem returns the file system of the partition that ISO-based images
+// are written to. Devices are partitioned with FAT32 when provisioned, while
+// updates reuse the existing partition, whose file system can be set by the
+// device policy of the distribution.
+func (i *Installer) fileSystem() storage.FileSystem {
+	if fs := i.config.DevicePolicy().FileSystem; i.config.UpdateOnly() && fs != "" {
+		return storage.FileSystem(fs)
+	}
+	return storage.FAT32
+}
+
 // prepareForISOWithoutElevation prepares a device to be provisioned with an
-// ISO-based image. It attempts to erase the contents of the installer
-// partition and checks for an appropriate label. A label mismatch suggests
-// that the device may or may not result in a fully bootable image, and a
-// warning is provided to state that the operation is considered "best effort"
-// when there is a label mismatch. Elevated permissions are not required for
-// this operation.
+// ISO-based image. It mounts the installer partition and checks for an
+// appropriate label. Existing contents are left in place so that unchanged
+// files do not need to be copied again by updateISO. A label mismatch
+// suggests that the device may or may not result in a fully bootable image,
+// and a warning is provided to state that the operation is considered "best
+// effort" when there is a label mismatch. Elevated permissions are not
+// required for this operation.
 func (i *Installer) prepareForISOWithoutElevation(d Device, size uint64) error {
-	deck.InfofA("Preparing %q for ISO without elevation.", d.FriendlyName()).With(deck.V(2)).Go()
+	deck.InfofA("Preparing %q for ISO without elevation.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
 	// Preparing the device for an ISO follows these steps:
-	// Erase default partition -> Check label (warn if necessary)
-	part, err := selectPart(d, size, storage.FAT32)
+	// Mount default partition -> Check label (warn if necessary)
+	fs := i.fileSystem()
+	part, err := selectPart(d, size, fs)
 	if err != nil {
-		return fmt.Errorf("SelectPartition(%d, %q) returned %v: %w", size, storage.FAT32, err, errPartition)
-	}
-	base := ""
-	if runtime.GOOS != "windows" {
-		base = i.cache
+		return fmt.Errorf("SelectPartition(%d, %q) returned %v: %w", size, fs, err, errPartition)
 	}
-	deck.InfofA("Mounting %q for erasing.", part.Identifier()).With(deck.V(2)).Go()
+	base := host.mountBase(i.cache)
+	deck.InfofA("Mounting %q for updating.", part.Identifier()).With(debug.V(debug.Storage, 2)).Go()
 	if err := part.Mount(base); err != nil {
 		return fmt.Errorf("Mount() for %q returned %v: %w", part.Identifier(), err, errMount)
 	}
-	deck.InfofA("Preparing to erase contents of %q (device: %q, partition %q).", part.Label(), d.FriendlyName(), part.Identifier()).With(deck.V(2)).Go()
-	if err := part.Erase(); err != nil {
-		return fmt.Errorf("%w: partition.Erase() returned %v", errWipe, err)
-	}
 	if !strings.Contains(part.Label(), i.config.DistroLabel()) {
 		console.Printf("\nWarning: Selected partition %q does not have a label that contains %q. Updating devices that were not previously provisioned by this tool is a best effort service. The device may not function as expected.\n", part.Identifier(), i.config.DistroLabel())
 		deck.Warningf("Selected partition %q does not have a label that contains %q. Updating devices that were not previously provisioned by this tool is a best effort service. The device may not function as expected.", part.Label(), i.config.DistroLabel())
@@ -432,43 +688,41 @@ func (i *Installer) prepareForISOWithoutElevation(d Device, size uint64) error {
 
 func fileCopy(srcFile, dest, cache string, p partition) error {
 	path := filepath.Join(cache, srcFile)
-	newPath := filepath.Join(p.MountPoint(), dest, srcFile)
-	// Add colon for windows paths if its a drive root.
-	if runtime.GOOS == "windows" && len(p.MountPoint()) < 2 {
-		newPath = filepath.Join(fmt.Sprintf("%s:", p.MountPoint()), dest, srcFile)
-	}
-	if err := os.MkdirAll(filepath.Dir(newPath), 0744); err != nil {
+	newPath := filepath.Join(host.root(p.MountPoint()), dest, srcFile)
+	if err := os.MkdirAll(extendedPath(filepath.Dir(newPath)), 0744); err != nil {
 		return fmt.Errorf("failed to create path: %v", err)
 	}
-	source, err := os.Open(path)
+	cBytes, err := copyFile(path, newPath, nil)
 	if err != nil {
-		return fmt.Errorf("%w: couldn't open file(%s) from cache: %v", errPath, path, err)
-	}
-	defer source.Close()
-	destination, err := os.Create(newPath)
-	if err != nil {
-		return fmt.Errorf("%w: couldn't create target file(%s): %v", errFile, path, err)
-	}
-	defer destination.Close()
-	cBytes, err := io.Copy(destination, source)
-	if err != nil {
-		return fmt.Errorf("failed to copy file to %s: %v", newPath, err)
+		return fmt.Errorf("failed to copy file to %s: %w", newPath, err)
 	}
 	console.Printf("Copied %d bytes", cBytes)
 	return nil
 }
 
 // selectPartition wraps device.SelectPartition and returns its output wrapped
-// in the partition interface.
+// in the partition interface. When no partition is suitable, the partitions
+// of the device and the reasons each was rejected are included in the error
+// and printed, as they are needed to resolve the failure.
 func selectPartition(d Device, size uint64, fs storage.FileSystem) (partition, error) {
-	return d.SelectPartition(size, fs)
+	p, err := d.SelectPartition(size, fs)
+	if err != nil {
+		why := explainSelection(d, size, fs)
+		console.Printf("No suitable partition was found, %s", why)
+		return nil, fmt.Errorf("%v\n%s", err, why)
+	}
+	return p, nil
 }
 
 // prepareForRaw prepares a device to be provisioned with an raw-based image.
 // Raw only requires the device to be dismounted so that the operating system
-// can write the directly to it. Though preparation does not require elevation,
-// direct writes to disk always do.
+// can write the directly to it. Direct writes require the ability to write to
+// the device, which is checked first so that it is reported before the device
+// is dismounted.
 func (i *Installer) prepareForRaw(d Device) error {
+	if err := i.config.CanWriteDevice(); err != nil {
+		return fmt.Errorf("%w: %v", errElevation, err)
+	}
 	return d.Dismount()
 }
 
@@ -476,7 +730,7 @@ func (i *Installer) prepareForRaw(d Device) error {
 // based on the source image file format. Each supported format enforces its
 // own requirements for the device. Provision only checks that all needed
 // configuration is present and that the image file has already been downloaded
-// to cache.
+// to cache, and that it still matches the digests it was retrieved with.
 func (i *Installer) Provision(d Device) error {
 	// Sanity check inputs and configuration. Device checks are left to the
 	// specific format based provisioning call itself.
@@ -489,19 +743,19 @@ func (i *Installer) Provision(d Device) error {
 	if i.config.ImageFile() == "" {
 		return fmt.Errorf("missing image: %w", errInput)
 	}
-	ext := regExFileExt.FindString(i.config.ImageFile())
+	ext := imagefile.Ext(i.config.ImageFile())
 	if ext == "" {
 		return fmt.Errorf("could not find extension for %q: %w", i.config.ImageFile(), errFile)
 	}
 	// Check that the image is already in the cache.
-	deck.InfofA("Checking %q for existence of %q.", i.cache, i.config.ImageFile()).With(deck.V(2)).Go()
+	deck.InfofA("Checking %q for existence of %q.", i.cache, i.config.ImageFile()).With(debug.V(debug.Storage, 2)).Go()
 	path := filepath.Join(i.cache, i.config.ImageFile())
 	if _, err := os.Stat(path); err != nil {
 		return fmt.Errorf("os.Stat(%q) returned %v: %w", path, err, errPath)
 	}
 	// Check that the FFU config is already in the cache.
 	if i.config.FFU() {
-		deck.InfofA("Checking %q for existence of %q.", i.cache, i.config.FFUConfFile()).With(deck.V(2)).Go()
+		deck.InfofA("Checking %q for existence of %q.", i.cache, i.config.FFUConfFile()).With(debug.V(debug.Storage, 2)).Go()
 		path := filepath.Join(i.cache, i.config.FFUConfFile())
 		if _, err := os.Stat(path); err != nil {
 			return fmt.Errorf("os.Stat(%q) returned %v: %w", path, err, errPath)
@@ -510,8 +764,19 @@ func (i *Installer) Provision(d Device) error {
 
 	// Provision the device.
 	switch ext {
-	case ".img":
-		return fmt.Errorf("img is not a supported image type: %w", errUnsupported)
+	case ".img", ".img.gz", ".img.xz", ".img.zst", ".vhd", ".vhdx", ".qcow2":
+		if err := i.provisionRaw(d); err != nil {
+			return err
+		}
+		if err := i.addPersistence(d); err != nil {
+			return err
+		}
+		return i.markDevice(d)
+	case ".wim", ".ffu":
+		if err := i.provisionApply(d); err != nil {
+			return err
+		}
+		return i.markDevice(d)
 	case ".iso":
 		return i.provisionISO(d)
 	}
@@ -523,17 +788,18 @@ func (i *Installer) Provision(d Device) error {
 // device. If a seedServer is configured, it is used to add a seed to the
 // device.
 func (i *Installer) provisionISO(d Device) (err error) {
+	i.written = nil
 	// Construct the path to the ISO.
 	path := filepath.Join(i.cache, i.config.ImageFile())
 	// Obtain an iso.Handler by mounting the ISO.
-	deck.InfofA("Mounting ISO at %q.", path).With(deck.V(2)).Go()
+	deck.InfofA("Mounting ISO at %q.", path).With(debug.V(debug.Storage, 2)).Go()
 	handler, err := mount(path)
 	if err != nil {
 		return fmt.Errorf("mount(%q) returned %v: %w", path, err, errMount)
 	}
 	// Close the handler on return, capturing the error if there is one.
 	defer func() {
-		deck.InfofA("Dismounting ISO at %q.", handler.MountPath()).With(deck.V(2)).Go()
+		deck.InfofA("Dismounting ISO at %q.", handler.MountPath()).With(debug.V(debug.Storage, 2)).Go()
 		if err2 := handler.Dismount(); err2 != nil {
 			if err != nil {
 				err = fmt.Errorf("Dismount() for %q returned %v: %w", handler.MountPath(), err, err2)
@@ -545,28 +811,74 @@ func (i *Installer) provisionISO(d Device) (err error) {
 	// Set a minimum partition size so that very small ISO's don't cause us to
 	// select an EFI partition unexpectedly.
 	minSize := handler.Size()
-	if handler.Size() < oneGB {
-		minSize = oneGB
+	// The boot partition of a split layout only holds part of the ISO.
+	if handler.Size() < uint64(units.GB) || i.splitLayout() {
+		minSize = uint64(units.GB)
 	}
 	// Find a compatible partition to write to and mount if necessary.
-	deck.InfofA("Searching %q for a %q partition larger than %v.", d.FriendlyName(), humanize.Bytes(minSize), storage.FAT32).With(deck.V(2)).Go()
-	p, err := selectPart(d, minSize, storage.FAT32)
+	fs := i.fileSystem()
+	deck.InfofA("Searching %q for a %q partition larger than %v.", d.FriendlyName(), fs, humanize.Bytes(minSize)).With(debug.V(debug.Storage, 2)).Go()
+	p, err := selectPart(d, minSize, fs)
 	if err != nil {
-		return fmt.Errorf("SelectPartition(%q, %q, %q) returned %v: %w", d.FriendlyName(), humanize.Bytes(minSize), storage.FAT32, err, errPartition)
-	}
-	// Specify the cache folder as the base mount directory for non-Windows.
-	base := ""
-	if runtime.GOOS != "windows" {
-		base = i.cache
+		return fmt.Errorf("SelectPartition(%q, %q, %q) returned %v: %w", d.FriendlyName(), humanize.Bytes(minSize), fs, err, errPartition)
 	}
-	deck.InfofA("Mounting %q for writing.", p.Identifier()).With(deck.V(2)).Go()
+	base := host.mountBase(i.cache)
+	deck.InfofA("Mounting %q for writing.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
 	if err := p.Mount(base); err != nil {
 		return fmt.Errorf("Mount() for %q returned %v: %w", p.Identifier(), err, errMount)
 	}
-	// Write the ISO.
-	deck.InfofA("Writing ISO at %q to %q.", handler.ImagePath(), d.FriendlyName()).With(deck.V(2)).Go()
-	if err := writeISOFunc(handler, p); err != nil {
-		return fmt.Errorf("writeISO() returned %v: %w", err, errProvision)
+	// Write the ISO, or refresh the changed files when updating.
+	if i.config.UpdateOnly() {
+		// Updates only refresh the boot partition, which would misplace files
+		// that belong elsewhere.
+		if len(i.config.PartitionRules()) > 0 {
+			return fmt.Errorf("updating distributions that place files on several partitions: %w", errUnsupported)
+		}
+		deck.InfofA("Updating %q from ISO at %q.", d.FriendlyName(), handler.ImagePath()).With(debug.V(debug.Copy, 2)).Go()
+		span := trace.Begin("copy", trace.Attr("image", handler.ImagePath()))
+		err = updateISOFunc(handler, p, i.copyOptions())
+		span.End(err)
+		if err != nil {
+			return fmt.Errorf("updateISO() returned %v: %w", err, errProvision)
+		}
+		i.written = map[string]partition{config.BootPartition: p}
+		if i.config.Unattend() != "" {
+			if err := i.writeUnattend(p, nil); err != nil {
+				return fmt.Errorf("writeUnattend() returned %v: %w", err, errProvision)
+			}
+		}
+	} else {
+		parts, err := i.rolePartitions(d, p, base)
+		if err != nil {
+			return err
+		}
+		deck.InfofA("Writing ISO at %q to %q.", handler.ImagePath(), d.FriendlyName()).With(debug.V(debug.Copy, 2)).Go()
+		opts := i.copyOptions()
+		opts.contents = &models.ContentManifest{}
+		// Files are hashed as they are copied, so the seed files are not read
+		// from the ISO again when seeds are requested.
+		if i.hashes == nil {
+			i.hashes = make(map[string][]byte)
+		}
+		opts.hashes = i.hashes
+		span := trace.Begin("copy", trace.Attr("image", handler.ImagePath()))
+		err = writeISOFunc(handler, parts, opts)
+		span.End(err)
+		if err != nil {
+			return fmt.Errorf("writeISO() returned %v: %w", err, errProvision)
+		}
+		i.written = parts
+		// The answer file is written after the ISO, which may provide its
+		// own, and before the contents are recorded, so that the manifest
+		// holds the answer file that is on the media.
+		if i.config.Unattend() != "" {
+			if err := i.writeUnattend(p, opts.contents); err != nil {
+				return fmt.Errorf("writeUnattend() returned %v: %w", err, errProvision)
+			}
+		}
+		if err := i.recordContents(d, opts.contents, p); err != nil {
+			return fmt.Errorf("recordContents() returned %v: %w", err, errProvision)
+		}
 	}
 
 	// If FFU, write config to disk.
@@ -577,9 +889,12 @@ func (i *Installer) provisionISO(d Device) (err error) {
 	}
 
 	// If no seed is required, return early, otherwise, retrieve and write
-	// the seed.
+	// the seed. The batch marker is otherwise written alongside the seed.
 	if i.config.SeedServer() == "" {
-		return nil
+		return i.markBatch(p)
+	}
+	if !i.config.SeedRequired() {
+		return i.skipSeed(p)
 	}
 	if err := i.writeSeed(handler, p); err != nil {
 		return fmt.Errorf("writeSeed() returned %v", err)
@@ -587,192 +902,798 @@ func (i *Installer) provisionISO(d Device) (err error) {
 	return nil
 }
 
+// copyOptions returns the options used to copy the contents of ISO images
+// for the configured distribution.
+func (i *Installer) copyOptions() copyOptions {
+	return copyOptions{
+		rules:    i.config.PartitionRules(),
+		preserve: i.config.PreserveAttributes(),
+	}
+}
+
+// rolePartitions returns the partitions of d that files from the ISO are
+// written to, keyed by role. boot is the partition already selected for
+// booting, and any other partitions named by the partition rules are selected
+// by file system and mounted beneath base.
+func (i *Installer) rolePartitions(d Device, boot partition, base string) (map[string]partition, error) {
+	parts := map[string]partition{config.BootPartition: boot}
+	for _, r := range i.config.PartitionRules() {
+		if _, ok := parts[r.Role]; ok {
+			continue
+		}
+		fs, ok := partitionFileSystems[r.Role]
+		if !ok {
+			return nil, fmt.Errorf("partition rule %q has unknown role %q: %w", r.Glob, r.Role, errConfig)
+		}
+		if r.Role == config.DataPartition {
+			fs = storage.FileSystem(i.config.DataFileSystem())
+		}
+		deck.InfofA("Searching %q for a %q partition for the %s role.", d.FriendlyName(), fs, r.Role).With(debug.V(debug.Storage, 2)).Go()
+		p, err := selectPart(d, 0, fs)
+		if err != nil {
+			return nil, fmt.Errorf("%q requires a %q partition for %s files, SelectPartition() returned %v: %w", d.FriendlyName(), fs, r.Role, err, errPartition)
+		}
+		deck.InfofA("Mounting %q for writing.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := p.Mount(base); err != nil {
+			return nil, fmt.Errorf("Mount() for %q returned %v: %w", p.Identifier(), err, errMount)
+		}
+		parts[r.Role] = p
+	}
+	return parts, nil
+}
+
 // mountISO wraps the concrete iso.Mount return value in an equivalent interface.
 func mountISO(path string) (isoHandler, error) {
 	return iso.Mount(path)
 }
 
-// writeISO takes an isoHandler and copies its contents to a partition. The
-// ISO is expected to be mounted and available. The contents are copied to
-// the device's default partition unless a destination partition has been
-// specified. The destination partition must be empty.
-func writeISO(iso isoHandler, part partition) error {
+// copyOptions controls how the contents of ISO images are copied.
+type copyOptions struct {
+	// rules place files on partitions other than the boot partition.
+	rules []config.PartitionRule
+	// preserve keeps the modification times and, on Windows, the basic
+	// attributes of copied files.
+	preserve bool
+	// contents, when not nil, records each file copied with its size and
+	// hash.
+	contents *models.ContentManifest
+	// hashes, when not nil, receives the SHA-256 hash of each file copied
+	// whole, keyed by its path beneath the mounted ISO.
+	hashes map[string][]byte
+	// split holds the WIM files, slash separated and relative to the root of
+	// the ISO, that are split into .swm parts rather than copied.
+	split map[string]bool
+}
+
+// writeISO takes an isoHandler and copies its contents to the partitions in
+// parts, which are keyed by role. The ISO is expected to be mounted and
+// available. Files are written to the boot partition unless one of the
+// partition rules in opts places them on another partition, and are recorded
+// in the content manifest of opts when it has one. WIM files that are too
+// large for the FAT32 boot partition are split into .swm parts, and any other
+// file that is too large fails the write before anything is copied. The
+// destination partitions must be empty.
+func writeISO(iso isoHandler, parts map[string]partition, opts copyOptions) error {
 	// Check inputs.
+	if parts[config.BootPartition] == nil {
+		return fmt.Errorf("partition was empty: %w", errPartition)
+	}
+	for role, part := range parts {
+		// Validate that the partition is ready for writing. If the drive is not
+		// mounted, attempt to mount it.
+		if part.MountPoint() == "" {
+			return fmt.Errorf("%s partition is not available: %w", role, errMount)
+		}
+		contents, err := part.Contents()
+		if err != nil {
+			return fmt.Errorf("Contents(%q) returned %v", part.MountPoint(), err)
+		}
+		// Some operating systems list the device or indexes.
+		if len(contents) > 2 {
+			deck.InfofA("contents of '%s(%s)'\n%v", part.Identifier(), part.Label(), contents).With(debug.V(debug.Copy, 3)).Go()
+			return fmt.Errorf("destination %s partition not empty: %w", role, errNotEmpty)
+		}
+	}
+	// Validate that the ISO is ready to be copied.
+	if iso.MountPath() == "" {
+		return fmt.Errorf("iso not mounted: %w", errInput)
+	}
+	if len(iso.Contents()) < 1 {
+		return errEmpty
+	}
+	split, err := oversizedFiles(iso.MountPath(), opts.rules)
+	if err != nil {
+		return err
+	}
+	opts.split = split
+	part := parts[config.BootPartition]
+	// Files are copied individually on Windows, so that every destination is
+	// written using an extended-length path, when their times and attributes
+	// are preserved, when they are recorded or hashed and when WIM files are
+	// split.
+	if len(opts.rules) == 0 && !opts.preserve && opts.contents == nil && opts.hashes == nil && len(opts.split) == 0 && !host.copiesPerFile() {
+		deck.InfofA("iso.Copy(): src(%s) dst(%s)", iso.MountPath(), part.MountPoint()).With(debug.V(debug.Copy, 3)).Go()
+		return iso.Copy(part.MountPoint())
+	}
+	return copyMapped(iso.MountPath(), parts, opts)
+}
+
+// copyMapped copies the files beneath src to the partitions in parts,
+// selecting the partition for each file using the partition rules in opts.
+// WIM files in the split set of opts are split into .swm parts instead.
+func copyMapped(src string, parts map[string]partition, opts copyOptions) error {
+	roots := make(map[string]string)
+	for role, part := range parts {
+		roots[role] = extendedPath(host.root(part.MountPoint()))
+	}
+	mount := src
+	src = extendedPath(src)
+	copied := make(map[string]int)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		role := partitionRole(filepath.ToSlash(rel), opts.rules)
+		root, ok := roots[role]
+		if !ok {
+			return fmt.Errorf("%q is placed on a %s partition, which is not available: %w", rel, role, errPartition)
+		}
+		dest := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0744); err != nil {
+			return err
+		}
+		if opts.split[filepath.ToSlash(rel)] {
+			if err := splitFile(file, dest, filepath.ToSlash(rel), opts.contents); err != nil {
+				return err
+			}
+			copied[role]++
+			return nil
+		}
+		deck.InfofA("Copying %q to the %s partition.", rel, role).With(debug.V(debug.Copy, 3)).Go()
+		var h hash.Hash
+		if opts.contents != nil || opts.hashes != nil {
+			h = sha256.New()
+		}
+		n, err := copyFile(file, dest, h)
+		if err != nil {
+			return err
+		}
+		if opts.hashes != nil {
+			opts.hashes[filepath.Join(mount, rel)] = h.Sum(nil)
+		}
+		if opts.contents != nil {
+			opts.contents.Files = append(opts.contents.Files, models.ContentFile{
+				Path:      filepath.ToSlash(rel),
+				Partition: role,
+				Size:      n,
+				SHA256:    hex.EncodeToString(h.Sum(nil)),
+			})
+		}
+		if opts.preserve {
+			if err := preserveAttributes(file, dest, info); err != nil {
+				return err
+			}
+		}
+		copied[role]++
+		return nil
+	})
+	if errors.Is(err, errPartition) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: copying %q: %v", errIO, src, err)
+	}
+	for role, n := range copied {
+		deck.InfofA("Copied %d files to the %s partition.", n, role).With(debug.V(debug.Copy, 2)).Go()
+	}
+	return nil
+}
+
+// partitionRole returns the role of the partition that the file at rel, a
+// slash separated path relative to the root of an ISO, is placed on. The
+// first matching rule is used. A rule matches a file when its glob matches
+// the path of the file or of any directory that contains it.
+func partitionRole(rel string, rules []config.PartitionRule) string {
+	rel = strings.ToLower(rel)
+	for _, r := range rules {
+		glob := strings.ToLower(r.Glob)
+		for p := rel; p != "."; p = path.Dir(p) {
+			if ok, _ := path.Match(glob, p); ok {
+				return r.Role
+			}
+		}
+	}
+	return config.BootPartition
+}
+
+// updateISO refreshes the contents of a previously provisioned partition from
+// a mounted ISO. Files whose size and hash match the ISO are left in place,
+// changed files are copied and files that are no longer present in the ISO
+// are removed. Only the preserve option of opts is used, as updates are not
+// supported with partition rules.
+func updateISO(iso isoHandler, part partition, opts copyOptions) error {
 	if part == nil {
 		return fmt.Errorf("partition was empty: %w", errPartition)
 	}
-	// Validate that the partition is ready for writing. If the drive is not
-	// mounted, attempt to mount it.
 	if part.MountPoint() == "" {
 		return fmt.Errorf("partition is not available: %w", errMount)
 	}
-	contents, err := part.Contents()
+	if iso.MountPath() == "" {
+		return fmt.Errorf("iso not mounted: %w", errInput)
+	}
+	root := host.root(part.MountPoint())
+	// Extended-length paths are used on Windows so that deep paths in the ISO
+	// are not limited by MAX_PATH.
+	root = extendedPath(root)
+	src := extendedPath(iso.MountPath())
+	wanted := make(map[string]bool)
+	copied, skipped := 0, 0
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(root, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0744)
+		}
+		wanted[strings.ToLower(rel)] = true
+		same, err := sameFile(path, dest, info.Size())
+		if err != nil {
+			return err
+		}
+		if same {
+			skipped++
+			return nil
+		}
+		deck.InfofA("Copying changed file %q.", rel).With(debug.V(debug.Copy, 3)).Go()
+		if _, err := copyFile(path, dest, nil); err != nil {
+			return err
+		}
+		if opts.preserve {
+			if err := preserveAttributes(path, dest, info); err != nil {
+				return err
+			}
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: copying %q to %q: %v", errIO, src, root, err)
+	}
+	// Remove files that are no longer part of the image. FAT32 is not case
+	// sensitive, so paths are compared without regard to case.
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "System Volume Information" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if wanted[strings.ToLower(rel)] {
+			return nil
+		}
+		deck.InfofA("Removing stale file %q.", rel).With(debug.V(debug.Copy, 3)).Go()
+		return os.Remove(path)
+	})
 	if err != nil {
-		return fmt.Errorf("Contents(%q) returned %v", part.MountPoint(), err)
+		return fmt.Errorf("%w: removing stale files from %q: %v", errIO, root, err)
 	}
-	// Some operating systems list the device or indexes.
-	if len(contents) > 2 {
-		deck.InfofA("contents of '%s(%s)'\n%v", part.Identifier(), part.Label(), contents).With(deck.V(3)).Go()
-		return fmt.Errorf("destination partition not empty: %w", errNotEmpty)
+	console.Printf("Updated %d files, %d files were unchanged.", copied, skipped)
+	return nil
+}
+
+// sameFile reports whether the file at dest exists and has the same size and
+// SHA-256 hash as the file at src, which is size bytes long.
+func sameFile(src, dest string, size int64) (bool, error) {
+	info, err := os.Stat(dest)
+	if os.IsNotExist(err) {
+		return false, nil
 	}
-	// Validate that the ISO is ready to be copied.
-	if iso.MountPath() == "" {
-		return fmt.Errorf("iso not mounted: %w", errInput)
+	if err != nil {
+		return false, fmt.Errorf("os.Stat(%q) returned %v", dest, err)
 	}
-	if len(iso.Contents()) < 1 {
-		return errEmpty
+	if info.IsDir() || info.Size() != size {
+		return false, nil
+	}
+	srcHash, err := fileHash(src, models.HashSHA256)
+	if err != nil {
+		return false, err
+	}
+	destHash, err := fileHash(dest, models.HashSHA256)
+	if err != nil {
+		return false, err
 	}
-	deck.InfofA("iso.Copy(): src(%s) dst(%s)", iso.MountPath(), part.MountPoint()).With(deck.V(3)).Go()
-	return iso.Copy(part.MountPoint())
+	return bytes.Equal(srcHash, destHash), nil
 }
 
 // writeSeed obtains a seed and writes it to a mounted partition.
-func (i *Installer) writeSeed(h isoHandler, p partition) error {
+func (i *Installer) writeSeed(h isoHandler, p partition) (err error) {
+	span := trace.Begin("seed", trace.Attr("partition", p.Label()))
+	defer func() { span.End(err) }()
 	// Input checks.
 	if p.MountPoint() == "" {
 		return fmt.Errorf("partition %q is not mounted: %w", p.Label(), errInput)
 	}
 	// We need to construct the path to the file to be hashed from configuration.
 	// Then we request a seed using that hash.
-	f := filepath.Join(h.MountPath(), i.config.SeedFile())
-	hash, err := fileHash(f)
+	alg := models.HashAlgorithm(i.config.SeedHash())
+	if alg == "" {
+		alg = models.HashSHA256
+	}
+	hash, err := i.seedHash(h, i.config.SeedFile(), alg)
 	if err != nil {
-		return fmt.Errorf("fileHash(%q) returned %w", err, errFile)
+		return fmt.Errorf("seedHash() returned %v: %w", err, errFile)
 	}
-	deck.InfofA("Hashed %q: %q.", f, hex.EncodeToString(hash)).With(deck.V(2)).Go()
 	// Connect to the seed server and request the seed.
-	u, err := username()
+	u, err := seedUser(i.config)
 	if err != nil {
-		return fmt.Errorf("username() returned %v: %w", err, errUser)
+		return fmt.Errorf("seedUser() returned %v: %w", err, errUser)
 	}
-	deck.InfofA("Connecting to seed endpoint as user %q: %q.", u, i.config.SeedServer()).With(deck.V(2)).Go()
-	client, err := connect(i.config.SeedServer(), u)
+	deck.InfofA("Connecting to seed endpoint as user %q: %q.", u, i.config.SeedServer()).With(debug.V(debug.Network, 2)).Go()
+	doer, err := connectAs(i.config, i.config.SeedServer(), u)
 	if err != nil {
 		return fmt.Errorf("fetcher.Connect(%q) returned %v: %w", i.config.SeedServer(), err, errConnect)
 	}
-	deck.InfofA("Requesting seed from %q.", i.config.SeedServer()).With(deck.V(2)).Go()
-	sr, err := seedRequest(client, string(hash), i.config)
+	deck.InfofA("Requesting seed from %q.", i.config.SeedServer()).With(debug.V(debug.Network, 2)).Go()
+	sr, hash, alg, err := i.requestSeed(doer, h, i.config.SeedFile(), hash, alg)
 	if err != nil {
+		reportMaintenance(err)
 		return fmt.Errorf("seedRequest returned %v: %w", err, errDownload)
 	}
 	seedFile := models.SeedFile{
 		Seed:      sr.Seed,
 		Signature: sr.Signature,
+		ExpiresAt: sr.ExpiresAt,
+		Algorithm: alg,
+	}
+	bundle, err := i.requestBundle(doer, h, seedFile)
+	if err != nil {
+		return err
+	}
+	// The media is only usable until the first of its seeds expires.
+	i.expiry = seedFile.ExpiresAt
+	if bundle != nil {
+		for _, b := range bundle.Seeds {
+			if !b.ExpiresAt.IsZero() && (i.expiry.IsZero() || b.ExpiresAt.Before(i.expiry)) {
+				i.expiry = b.ExpiresAt
+			}
+		}
 	}
+	checkSeedExpiry(i.expiry, i.config.SeedShelfLife())
 	// See that the seed contents are human readable.
 	content, err := json.MarshalIndent(seedFile, "", "")
 	if err != nil {
 		return fmt.Errorf("json.MarshalIndent(%v) returned: %v", seedFile, err)
 	}
-	deck.InfofA("Retrieved seed: %s", content).With(deck.V(3)).Go()
-	// Determine where the seed should be written to and write it. Accommodate
-	// for Windows not understanding drive letters vs relative paths.
-	root := p.MountPoint()
-	if runtime.GOOS == "windows" && !strings.Contains(root, `:`) {
-		root = root + `:`
-	}
-	path := filepath.Join(root, i.config.SeedDest())
-	deck.InfofA("Creating seed directory: %q.", path).With(deck.V(2)).Go()
+	deck.InfofA("Retrieved seed: %s", content).With(debug.V(debug.Seed, 3)).Go()
+	// Determine where the seed should be written to and write it.
+	path := filepath.Join(host.root(p.MountPoint()), i.config.SeedDest())
+	deck.InfofA("Creating seed directory: %q.", path).With(debug.V(debug.Seed, 2)).Go()
 	// Permissions = owner:read/write/execute, group:read/execute"
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", path, err, errPerm)
 	}
 	s := filepath.Join(path, seedDestFile)
-	deck.InfofA("Writing seed: %q.", s).With(deck.V(2)).Go()
+	deck.InfofA("Writing seed: %q.", s).With(debug.V(debug.Seed, 2)).Go()
 	// Permissions = owner:read/write, group:read"
 	if err := ioutil.WriteFile(s, content, 0644); err != nil {
 		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", s, err, errIO)
 	}
+	if err := writeBundle(path, bundle); err != nil {
+		return err
+	}
+	// Mark the media with the batch and the expiry of the seed alongside it.
+	if err := i.writeBatchMarker(path, false); err != nil {
+		return fmt.Errorf("writeBatchMarker() returned %v", err)
+	}
+	// If a manifest is configured, write it alongside the seed.
+	if len(i.config.ManifestFiles()) == 0 {
+		return nil
+	}
+	if err := i.writeManifest(sr, hash, alg, u, path); err != nil {
+		return fmt.Errorf("writeManifest() returned %v", err)
+	}
 	return nil
 }
 
-// writeConfig writes the FFU config file to disk using SeedDest directory.
-func (i *Installer) writeConfig(p partition) error {
-	source := filepath.Join(i.cache, i.config.FFUConfFile())
-	content, err := ioutil.ReadFile(source)
+// requestBundle obtains a seed for each seed file of the distribution after
+// the first, whose seed is primary, and returns them bundled together with
+// primary. Each file is requested separately, so that the seed server checks
+// each hash against its allowlist. It returns nil when the distribution has a
+// single seed file.
+func (i *Installer) requestBundle(doer httpDoer, h isoHandler, primary models.SeedFile) (*models.SeedBundle, error) {
+	files := i.config.SeedFiles()
+	if len(files) < 2 {
+		return nil, nil
+	}
+	bundle := &models.SeedBundle{Seeds: []models.BundledSeed{{File: files[0], SeedFile: primary}}}
+	for _, f := range files[1:] {
+		hash, err := i.seedHash(h, f, primary.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("seedHash(%q) returned %v: %w", f, err, errFile)
+		}
+		deck.InfofA("Requesting seed for %q from %q.", f, i.config.SeedServer()).With(debug.V(debug.Network, 2)).Go()
+		sr, _, alg, err := i.requestSeed(doer, h, f, hash, primary.Algorithm)
+		if err != nil {
+			reportMaintenance(err)
+			return nil, fmt.Errorf("seedRequest for %q returned %v: %w", f, err, errDownload)
+		}
+		bundle.Seeds = append(bundle.Seeds, models.BundledSeed{
+			File: f,
+			SeedFile: models.SeedFile{
+				Seed:      sr.Seed,
+				Signature: sr.Signature,
+				ExpiresAt: sr.ExpiresAt,
+				Algorithm: alg,
+			},
+		})
+	}
+	return bundle, nil
+}
+
+// writeBundle writes bundle to dir alongside the seed. When there is no
+// bundle, one left by an earlier provisioning of the media is removed so that
+// it is not mistaken for the seeds of the current image.
+func writeBundle(dir string, bundle *models.SeedBundle) error {
+	path := filepath.Join(dir, bundleDestFile)
+	if bundle == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("os.Remove(%q) returned %v: %w", path, err, errIO)
+		}
+		return nil
+	}
+	content, err := json.MarshalIndent(bundle, "", "  ")
 	if err != nil {
-		return fmt.Errorf("ioutil.ReadFile(%q) returned %v: %w", source, err, errIO)
+		return fmt.Errorf("json.MarshalIndent(%v) returned: %v", bundle, err)
 	}
-	root := p.MountPoint()
-	if runtime.GOOS == "windows" && !strings.Contains(root, `:`) {
-		root = root + `:`
+	deck.InfofA("Writing %d bundled seeds: %q.", len(bundle.Seeds), path).With(debug.V(debug.Seed, 2)).Go()
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", path, err, errIO)
 	}
-	dest := filepath.Join(root, i.config.SeedDest())
-	deck.InfofA("Creating config directory: %q.", dest).With(deck.V(2)).Go()
-	// Permissions = owner:read/write/execute, group:read/execute"
-	if err := os.MkdirAll(dest, 0755); err != nil {
-		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dest, err, errPerm)
+	return nil
+}
+
+// seedHash returns the hash of file in the ISO mounted by h, computed using
+// alg. The ISO is the cached image for every device that is provisioned, so
+// the hash is only computed once for each file and algorithm.
+func (i *Installer) seedHash(h isoHandler, file string, alg models.HashAlgorithm) ([]byte, error) {
+	key := seedKey{image: h.ImagePath(), file: file, alg: alg}
+	if hash, ok := i.seeds[key]; ok {
+		deck.InfofA("Reusing %s hash of %q: %q.", alg, key.file, hex.EncodeToString(hash)).With(debug.V(debug.Seed, 2)).Go()
+		return hash, nil
+	}
+	f := filepath.Join(h.MountPath(), key.file)
+	hash, err := i.fileHash(f, alg)
+	if err != nil {
+		return nil, fmt.Errorf("fileHash(%q) returned %v", f, err)
 	}
-	destFile := filepath.Join(dest, confDestFile)
-	deck.InfofA("Writing config: %q.", destFile).With(deck.V(2)).Go()
+	deck.InfofA("Hashed %q using %s: %q.", f, alg, hex.EncodeToString(hash)).With(debug.V(debug.Seed, 2)).Go()
+	if i.seeds == nil {
+		i.seeds = make(map[seedKey][]byte)
+	}
+	i.seeds[key] = hash
+	return hash, nil
+}
+
+// requestSeed requests a seed for hash, the hash of file in the ISO mounted
+// by h computed using alg. If the seed server does not accept alg, the file is
+// hashed again using an algorithm that the server does accept and the request
+// is made once more. The hash and algorithm that the seed was obtained with
+// are returned alongside it.
+func (i *Installer) requestSeed(doer httpDoer, h isoHandler, file string, hash []byte, alg models.HashAlgorithm) (*models.SeedResponse, []byte, models.HashAlgorithm, error) {
+	c := newClient(i.config.SeedServer(), doer)
+	c.Batch = i.config.Batch()
+	c.Fields = i.config.SeedFields()
+	return c.NegotiateSeed(alg, func(a models.HashAlgorithm) ([]byte, error) {
+		if a == alg {
+			return hash, nil
+		}
+		return i.seedHash(h, file, a)
+	})
+}
+
+// newClient returns a client for the endpoint at url that identifies itself
+// as this release of the CLI.
+func newClient(url string, doer httpDoer) *client.Client {
+	c := client.New(url, doer)
+	c.Version = version.Version
+	c.UserAgent = version.UserAgent()
+	return c
+}
+
+// reportMaintenance tells the user when a request was refused because the
+// server is in maintenance mode, so that planned maintenance is not mistaken
+// for a failure of the client. The message of the server is shown verbatim.
+func reportMaintenance(err error) {
+	if errors.Is(err, client.ErrMaintenance) {
+		console.Printf("\n%v\n", err)
+	}
+}
+
+// checkSeedExpiry warns when a seed expiring at expires is not expected to
+// remain valid for the shelfLife of the media being provisioned, and returns
+// whether a warning was issued. A zero expires indicates that the server did
+// not report an expiry.
+func checkSeedExpiry(expires time.Time, shelfLife time.Duration) bool {
+	if expires.IsZero() {
+		deck.InfofA("The seed server did not report a seed expiry.").With(debug.V(debug.Seed, 2)).Go()
+		return false
+	}
+	deck.InfofA("Seed expires at %v.", expires).With(debug.V(debug.Seed, 2)).Go()
+	if shelfLife <= 0 || !time.Now().Add(shelfLife).After(expires) {
+		return false
+	}
+	console.Printf("\nWarning: The seed expires at %v, before the expected shelf life of %v for this media. The media must be used or refreshed before then.\n", expires.Format(time.RFC1123), shelfLife)
+	deck.Warningf("Seed expires at %v, before the expected shelf life of %v.", expires, shelfLife)
+	return true
+}
+
+// writeManifest requests signed URLs for each of the configured manifest
+// files using the seed that was just obtained, and writes them as a bootstrap
+// manifest to dir.
+func (i *Installer) writeManifest(sr *models.SeedResponse, hash []byte, alg models.HashAlgorithm, user, dir string) error {
+	deck.InfofA("Connecting to sign endpoint as user %q: %q.", user, i.config.SignServer()).With(debug.V(debug.Network, 2)).Go()
+	doer, err := connectAs(i.config, i.config.SignServer(), user)
+	if err != nil {
+		return fmt.Errorf("fetcher.Connect(%q) returned %v: %w", i.config.SignServer(), err, errConnect)
+	}
+	c := newClient(i.config.SignServer(), doer)
+	macs := machineMACs()
+	manifest := models.BootstrapManifest{Created: time.Now()}
+	for _, f := range i.config.ManifestFiles() {
+		deck.InfofA("Requesting signed URL for %q.", f).With(debug.V(debug.Network, 2)).Go()
+		req := &models.SignRequest{
+			Seed:      sr.Seed,
+			Signature: sr.Signature,
+			Mac:       macs,
+			Path:      f,
+			Hash:      hash,
+			Algorithm: alg,
+		}
+		resp, err := c.Sign(req)
+		if err != nil {
+			reportMaintenance(err)
+			return fmt.Errorf("Sign(%q) returned %v: %w", f, err, errDownload)
+		}
+		manifest.Files = append(manifest.Files, models.BootstrapFile{
+			Path:      f,
+			SignedURL: resp.SignedURL,
+			Expires:   resp.Expires,
+			Size:      resp.Size,
+			MD5:       resp.MD5,
+			CRC32C:    resp.CRC32C,
+		})
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(%v) returned: %v", manifest, err)
+	}
+	m := filepath.Join(dir, manifestDestFile)
+	deck.InfofA("Writing manifest: %q.", m).With(debug.V(debug.Seed, 2)).Go()
 	// Permissions = owner:read/write, group:read"
-	if err := ioutil.WriteFile(destFile, content, 0644); err != nil {
-		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", destFile, err, errIO)
+	if err := ioutil.WriteFile(m, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", m, err, errIO)
 	}
 	return nil
 }
 
-// fileHash returns a the SHA-256 hash of the file at the provided path.
-func fileHash(path string) ([]byte, error) {
-	if path == "" {
-		return nil, fmt.Errorf("path was empty: %w", errInput)
+// skipSeed records that no seed was written to a mounted partition because
+// the configured track does not require one. The decision is logged, and
+// recorded in a marker where the seed would otherwise have been written.
+func (i *Installer) skipSeed(p partition) error {
+	if p.MountPoint() == "" {
+		return fmt.Errorf("partition %q is not mounted: %w", p.Label(), errInput)
 	}
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("os.Open(%q) returned %v: %w", path, err, errPath)
+	console.Printf("Track %q does not require a seed, none is written.", i.config.Track())
+	deck.InfofA("Skipping seed for track %q of %q, as the distribution does not require one.", i.config.Track(), i.config.Distro()).Go()
+	path := filepath.Join(host.root(p.MountPoint()), i.config.SeedDest())
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", path, err, errPerm)
 	}
-	defer f.Close()
+	if err := i.writeBatchMarker(path, true); err != nil {
+		return fmt.Errorf("writeBatchMarker() returned %v", err)
+	}
+	return nil
+}
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return nil, fmt.Errorf("hashing %q returned %v: %w", f.Name(), path, errIO)
+// markBatch writes the batch marker to where the seed would be written on the
+// mounted partition p, for media that receives no seed.
+func (i *Installer) markBatch(p partition) error {
+	if i.config.Batch() == "" {
+		return nil
 	}
-	hash := h.Sum(nil)
-	return hash, nil
+	path := filepath.Join(host.root(p.MountPoint()), i.config.SeedDest())
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", path, err, errPerm)
+	}
+	if err := i.writeBatchMarker(path, false); err != nil {
+		return fmt.Errorf("writeBatchMarker() returned %v", err)
+	}
+	return nil
 }
 
-// seedRequest obtains a signed seed for the installer and returns it for use.
-func seedRequest(client httpDoer, hash string, config Configuration) (*models.SeedResponse, error) {
-	if hash == "" {
-		return nil, fmt.Errorf("missing hash: %w", errInput)
+// markDevice writes the batch marker to media written from raw, WIM and FFU
+// images, which have no partition selected for a seed. The marker is written
+// to a partition of d with the file system of the distribution, and is
+// skipped with a warning when d has none that can be mounted, such as when a
+// raw image holds only file systems that the OS cannot mount.
+func (i *Installer) markDevice(d Device) error {
+	if i.config.Batch() == "" {
+		return nil
 	}
-	// Build the request.
-	sr := &models.SeedRequest{
-		Hash: []byte(hash),
+	skip := func(why error) error {
+		console.Printf("The batch marker was not written to %s: %v", d.FriendlyName(), why)
+		deck.Warningf("Skipped the batch marker of %q: %v", d.Identifier(), why)
+		return nil
 	}
-	reqBody, err := json.Marshal(sr)
+	deck.InfofA("Refreshing partition information for %q.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	if err := d.DetectPartitions(false); err != nil {
+		return skip(fmt.Errorf("DetectPartitions() returned %v", err))
+	}
+	p, err := selectMarkerPart(d, i.fileSystem())
 	if err != nil {
-		return nil, fmt.Errorf("could not marshal seed request(%+v): %v", sr, err)
+		return skip(err)
+	}
+	if p.MountPoint() == "" {
+		deck.InfofA("Mounting %q to write the batch marker.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := p.Mount(host.mountBase(i.cache)); err != nil {
+			return skip(fmt.Errorf("Mount() for %q returned %v", p.Identifier(), err))
+		}
 	}
-	req, err := http.NewRequest("POST", config.SeedServer(), bytes.NewReader(reqBody))
+	return i.markBatch(p)
+}
+
+// writeBatchMarker writes a marker naming the batch that the media was
+// provisioned in to dir, whether its seed was skipped and when the seed that
+// was written expires. Nothing is written when no batch was named, the seed
+// was not skipped and the expiry of the seed is unknown.
+func (i *Installer) writeBatchMarker(dir string, seedSkipped bool) error {
+	if i.config.Batch() == "" && !seedSkipped && i.expiry.IsZero() {
+		return nil
+	}
+	marker := models.BatchMarker{
+		Batch:       i.config.Batch(),
+		Distro:      i.config.Distro(),
+		Track:       i.config.Track(),
+		Version:     version.Version,
+		Created:     time.Now(),
+		SeedSkipped: seedSkipped,
+	}
+	if !seedSkipped {
+		marker.SeedExpiry = i.expiry
+	}
+	return writeMarker(dir, marker)
+}
+
+// writeMarker writes marker to dir.
+func writeMarker(dir string, marker models.BatchMarker) error {
+	content, err := json.MarshalIndent(marker, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("error composing post request %v: %w", err, errConnect)
+		return fmt.Errorf("json.MarshalIndent(%v) returned: %v", marker, err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	m := filepath.Join(dir, batchDestFile)
+	deck.InfofA("Writing batch marker: %q.", m).With(debug.V(debug.Seed, 2)).Go()
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(m, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", m, err, errIO)
+	}
+	return nil
+}
 
-	// Post the request and obtain a response.
-	resp, err := client.Do(req)
+// ReadMarker returns the marker written to d alongside its seed for the
+// distribution of c, which records the batch that d was provisioned in and
+// when its seed expires. The marker is only read from a partition that the OS
+// has already mounted, so that d is never changed by reading it.
+func ReadMarker(d Device, c Configuration) (*models.BatchMarker, error) {
+	if err := d.DetectPartitions(false); err != nil {
+		return nil, fmt.Errorf("DetectPartitions() for %q returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	// Media updated in place may use the file system of the device policy
+	// rather than the one that media is formatted with.
+	fss := []storage.FileSystem{storage.FAT32}
+	if fs := storage.FileSystem(c.DevicePolicy().FileSystem); fs != "" && fs != storage.FAT32 {
+		fss = append(fss, fs)
+	}
+	var err error
+	for _, fs := range fss {
+		var p partition
+		if p, err = selectMarkerPart(d, fs); err != nil {
+			continue
+		}
+		if p.MountPoint() == "" {
+			err = fmt.Errorf("partition %q is not mounted: %w", p.Identifier(), errMount)
+			continue
+		}
+		m := &models.BatchMarker{}
+		if err = readJSON(filepath.Join(host.root(p.MountPoint()), c.SeedDest(), batchDestFile), m); err != nil {
+			err = fmt.Errorf("%v: %w", err, errFile)
+			continue
+		}
+		return m, nil
+	}
+	return nil, err
+}
+
+// writeConfig writes the FFU config file to disk using SeedDest directory.
+func (i *Installer) writeConfig(p partition) error {
+	source := filepath.Join(i.cache, i.config.FFUConfFile())
+	content, err := ioutil.ReadFile(source)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", errPost, err)
+		return fmt.Errorf("ioutil.ReadFile(%q) returned %v: %w", source, err, errIO)
 	}
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
+	dest := filepath.Join(host.root(p.MountPoint()), i.config.SeedDest())
+	deck.InfofA("Creating config directory: %q.", dest).With(debug.V(debug.Seed, 2)).Go()
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dest, err, errPerm)
+	}
+	destFile := filepath.Join(dest, confDestFile)
+	deck.InfofA("Writing config: %q.", destFile).With(debug.V(debug.Seed, 2)).Go()
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(destFile, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", destFile, err, errIO)
+	}
+	if !i.config.PreserveAttributes() {
+		return nil
+	}
+	info, err := os.Stat(source)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return fmt.Errorf("os.Stat(%q) returned %v: %w", source, err, errIO)
 	}
-	// If the server responded that the hash is not in the allowlist, return.
-	if strings.Contains(fmt.Sprintf("%s", respBody), "not in allowlist") {
-		return nil, fmt.Errorf("%w: %q", errResponse, hex.EncodeToString([]byte(hash)))
+	return preserveAttributes(source, destFile, info)
+}
+
+// fileHash returns the hash of the file at the provided path, computed using
+// alg. SHA-256 hashes computed while the file was downloaded or copied from an
+// ISO are reused rather than reading the file again.
+func (i *Installer) fileHash(path string, alg models.HashAlgorithm) ([]byte, error) {
+	if hash, ok := i.hashes[path]; ok && alg == models.HashSHA256 {
+		return hash, nil
+	}
+	return fileHash(path, alg)
+}
+
+// fileHash returns a the hash of the file at the provided path, computed
+// using alg.
+func fileHash(path string, alg models.HashAlgorithm) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path was empty: %w", errInput)
 	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q) returned %v: %w", path, err, errPath)
+	}
+	defer f.Close()
 
-	r := &models.SeedResponse{}
-	if err := json.Unmarshal(respBody, r); err != nil {
-		return nil, fmt.Errorf("json.Unmarhsal(%s) returned %v: %w", respBody, err, errFormat)
+	h, err := client.NewHash(alg)
+	if err != nil {
+		return nil, err
 	}
-	if r.ErrorCode != models.StatusSuccess {
-		return nil, fmt.Errorf("%w: %v %d", errSeed, r.Status, r.ErrorCode)
+	if err := client.HashReader(h, f); err != nil {
+		return nil, fmt.Errorf("hashing %q returned %v: %w", f.Name(), err, errIO)
 	}
-	return r, nil
+	hash := h.Sum(nil)
+	return hash, nil
 }
 
 // Finalize performs post-provisioning tasks for a device. It is meant to
@@ -781,38 +1702,141 @@ func seedRequest(client httpDoer, hash string, config Configuration) (*models.Se
 // so that artifacts like downloaded images can be obtained just once and
 // re-used during Preparation and Provisioning steps. If the cache exists
 // it is automatically cleaned up. Optionally, the device can also be
-// dismounted and/or powered off during the Finalize step.
+// dismounted and/or powered off during the Finalize step, which are retried
+// while other processes briefly hold the device. A device that cannot be
+// finalized does not prevent the others from being finalized, and the error
+// of each is returned.
 func (i *Installer) Finalize(devices []Device, dismount bool) error {
+	var errs []error
 	for _, device := range devices {
-		if dismount {
-			deck.InfofA("Refreshing partition information for %q prior to dismount.", device.Identifier()).With(deck.V(2)).Go()
-			if err := device.DetectPartitions(false); err != nil {
-				return fmt.Errorf("DetectPartitions() for %q returned %v: %w", device.Identifier(), err, errFinalize)
-			}
-			console.Printf("Dismounting device %q.", device.Identifier())
-			deck.InfofA("Dismounting device %q.", device.Identifier()).With(deck.V(2)).Go()
-			if err := device.Dismount(); err != nil {
-				return fmt.Errorf("Dismount(%s) returned %v: %w", device.Identifier(), err, errDevice)
-			}
+		if err := i.finalizeDevice(device, dismount); err != nil {
+			deck.Warningf("Finalizing device %q failed: %v", device.Identifier(), err)
+			errs = append(errs, err)
 		}
-		if i.config.PowerOff() {
-			console.Printf("Ejecting device %q.", device.Identifier())
-			deck.InfofA("Ejecting device %q.", device.Identifier()).With(deck.V(2)).Go()
-			if err := device.Eject(); err != nil {
-				return fmt.Errorf("Eject(%s) returned %v: %w", device.Identifier(), err, errIO)
-			}
+	}
+	if err := i.finalizeCache(); err != nil {
+		errs = append(errs, err)
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return fmt.Errorf("%w: finalizing failed %d times: %v", errs[0], len(errs), errs)
+}
+
+// finalizeDevice dismounts and ejects device as configured.
+func (i *Installer) finalizeDevice(device Device, dismount bool) error {
+	if dismount {
+		deck.InfofA("Refreshing partition information for %q prior to dismount.", device.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := device.DetectPartitions(false); err != nil {
+			return fmt.Errorf("DetectPartitions() for %q returned %v: %w", device.Identifier(), err, errFinalize)
+		}
+		console.Printf("Dismounting device %q.", device.Identifier())
+		deck.InfofA("Dismounting device %q.", device.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := release(device, "Dismount", device.Dismount); err != nil {
+			return fmt.Errorf("Dismount(%s) returned %v: %w", device.Identifier(), err, errDevice)
+		}
+	}
+	if i.config.PowerOff() {
+		console.Printf("Ejecting device %q.", device.Identifier())
+		deck.InfofA("Ejecting device %q.", device.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		if err := release(device, "Eject", device.Eject); err != nil {
+			return fmt.Errorf("Eject(%s) returned %v: %w", device.Identifier(), err, errIO)
 		}
 	}
+	return nil
+}
+
+// finalizeCache removes the cache, or retains it when cleanup is disabled or
+// it was provided by the user.
+func (i *Installer) finalizeCache() error {
+	// Retain the cache when cleanup is disabled or it was provided by the
+	// user, describing its contents so that it can be reused.
+	if !i.config.Cleanup() || i.reuse {
+		if err := i.retainCache(); err != nil {
+			return err
+		}
+		// Eviction only limits the size of the cache, and a failure is not a
+		// failure to provision.
+		if err := i.evictCache(); err != nil {
+			deck.Warningf("Evicting entries from cache directory %q failed: %v", i.root, err)
+		}
+		return nil
+	}
 	// Clean up the cache if it still exists. os.RemoveAll returns nil if the
 	// path doesn't exist, which is convenient for us here.
-	deck.InfofA("Cleaning up installer cache %q.", i.cache).With(deck.V(2)).Go()
+	deck.InfofA("Cleaning up installer cache %q.", i.cache).With(debug.V(debug.Storage, 2)).Go()
 	if err := os.RemoveAll(i.cache); err != nil {
 		return fmt.Errorf("os.RemoveAll(%s) returned %v: %w", i.cache, err, errPath)
 	}
 	return nil
 }
 
+// retainCache reports the location of the cache, and writes a README to it
+// that describes its contents and how to reuse them.
+func (i *Installer) retainCache() error {
+	if i.cache == "" {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(i.cache)
+	if err != nil {
+		return fmt.Errorf("ioutil.ReadDir(%q) returned %v: %w", i.cache, err, errPath)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "This directory was retained by %s because --cleanup=false was set,\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(&b, "or because it was provided with --cache_dir.\n\n")
+	fmt.Fprintf(&b, "Retained:     %s\n", time.Now().Format(time.RFC1123))
+	fmt.Fprintf(&b, "Distribution: %s\n", i.config.Distro())
+	fmt.Fprintf(&b, "Track:        %s\n\n", i.config.Track())
+	fmt.Fprintf(&b, "Files:\n")
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == cacheReadmeFile || strings.HasSuffix(e.Name(), tmpSuffix) {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s (%s)", e.Name(), humanize.Bytes(uint64(e.Size())))
+		if hash, ok := i.hashes[filepath.Join(i.cache, e.Name())]; ok {
+			fmt.Fprintf(&b, " sha256:%s", hex.EncodeToString(hash))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	// Files in an entry of a cache directory are found from the directory.
+	dir := i.cache
+	if i.root != "" {
+		dir = i.root
+	}
+	fmt.Fprintf(&b, "\nTo provision devices from these files without downloading them again, run:\n\n")
+	fmt.Fprintf(&b, "  %s write --distro=%s --track=%s --cache_dir=%q [devices]\n\n", filepath.Base(os.Args[0]), i.config.Distro(), i.config.Track(), dir)
+	fmt.Fprintf(&b, "Images that do not match the image manifest of the track are downloaded\n")
+	fmt.Fprintf(&b, "again. Delete this directory when it is no longer needed.\n")
+	readme := filepath.Join(i.cache, cacheReadmeFile)
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(readme, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", readme, err, errIO)
+	}
+	console.Printf("The installer cache was retained at %q.", i.cache)
+	deck.InfofA("Retained installer cache %q.", i.cache).With(deck.V(1)).Go()
+	return nil
+}
+
 // Cache returns the location of the cache folder for a given installer.
 func (i *Installer) Cache() string {
 	return i.cache
 }
+
+// ImageHash returns the hex encoded SHA-256 hash of the retrieved image, or
+// an empty string when the image has not been retrieved.
+func (i *Installer) ImageHash() string {
+	if i.config == nil {
+		return ""
+	}
+	return hex.EncodeToString(i.hashes[filepath.Join(i.cache, i.config.ImageFile())])
+}
+
+// SeedExpiry returns the expiry of the seed written to the device that was
+// provisioned last. It is zero when no seed was written or the seed server
+// did not report an expiry.
+func (i *Installer) SeedExpiry() time.Time {
+	return i.expiry
+}
@@ -16,6 +16,8 @@ package installer
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -27,9 +29,14 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/netinfo"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/client"
 	"github.com/google/fresnel/models"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/winops/storage"
@@ -43,23 +50,76 @@ type fakeConfig struct {
 	// config.Configuration is embedded, fakeConfig inherits all its members.
 	config.Configuration
 
-	dismount bool
-	eject    bool
-	elevated bool
-	ffu      bool
-	update   bool
-	err      error // the error returned when isElevated is called.
+	apply     bool
+	checksums bool
+	dismount  bool
+	eject     bool
+	elevated  bool
+	rollback  bool
+	ffu       bool
+	update    bool
+	sparse    bool
+	trim      bool
+	err       error // the error returned when isElevated is called.
+
+	applyIndex int
 
 	confFile    string
+	digestsPath string
+	distro      string
 	distroLabel string
 	imagePath   string
 	imageFile   string
 	seedDest    string
 	seedFile    string
+	seedHash    string
 	seedServer  string
+	noSeed      bool
 	track       string
 	ffuConfFile string
 	ffuConfPath string
+	signServer  string
+	manifest    []string
+	partitions  []config.PartitionRule
+	devices     config.DevicePolicy
+	preserve    bool
+	shelfLife   time.Duration
+	batch       string
+	cleanup     bool
+	cacheDir    string
+	cacheAge    time.Duration
+	cacheSize   units.Size
+	contents    bool
+	logOnMedia  bool
+	unattend    string
+	unattendTo  string
+	impersonate string
+	delegate    string
+	mirrors     []string
+	probe       bool
+	seedFields  map[string]string
+	seedFiles   []string // Seed files after seedFile.
+
+	signedImages   string
+	dataFileSystem string
+
+	scanner []string
+
+	persistence      bool
+	persistenceSize  units.Size
+	persistenceLabel string
+}
+
+func (f *fakeConfig) Apply() bool {
+	return f.apply
+}
+
+func (f *fakeConfig) ApplyIndex() int {
+	return f.applyIndex
+}
+
+func (f *fakeConfig) Checksums() bool {
+	return f.checksums
 }
 
 func (f *fakeConfig) ConfFile() string {
@@ -70,6 +130,14 @@ func (f *fakeConfig) Dismount() bool {
 	return f.dismount
 }
 
+func (f *fakeConfig) DigestsPath() string {
+	return f.digestsPath
+}
+
+func (f *fakeConfig) Distro() string {
+	return f.distro
+}
+
 func (f *fakeConfig) DistroLabel() string {
 	return f.distroLabel
 }
@@ -78,12 +146,42 @@ func (f *fakeConfig) ImagePath() string {
 	return f.imagePath
 }
 
+func (f *fakeConfig) ImageMirrors() []string {
+	return f.mirrors
+}
+
+func (f *fakeConfig) ProbeMirrors() bool {
+	return f.probe
+}
+
 func (f *fakeConfig) ImageFile() string {
 	return f.imageFile
 }
 
-func (f *fakeConfig) Elevated() bool {
-	return f.elevated
+func (f *fakeConfig) CanMount() error {
+	if !f.elevated {
+		return errors.New("not elevated")
+	}
+	return nil
+}
+
+func (f *fakeConfig) CanWriteDevice() error {
+	if !f.elevated {
+		return errors.New("not elevated")
+	}
+	return nil
+}
+
+func (f *fakeConfig) StripDomain() bool {
+	return false
+}
+
+func (f *fakeConfig) Rollback() bool {
+	return f.rollback
+}
+
+func (f *fakeConfig) Track() string {
+	return f.track
 }
 
 func (f *fakeConfig) PowerOff() bool {
@@ -98,14 +196,37 @@ func (f *fakeConfig) SeedFile() string {
 	return f.seedFile
 }
 
+func (f *fakeConfig) SeedFiles() []string {
+	if f.seedFile == "" {
+		return nil
+	}
+	return append([]string{f.seedFile}, f.seedFiles...)
+}
+
+func (f *fakeConfig) SeedHash() string {
+	return f.seedHash
+}
+
 func (f *fakeConfig) SeedServer() string {
 	return f.seedServer
 }
 
+func (f *fakeConfig) SeedRequired() bool {
+	return f.seedServer != "" && !f.noSeed
+}
+
 func (f *fakeConfig) UpdateOnly() bool {
 	return f.update
 }
 
+func (f *fakeConfig) SparseWrite() bool {
+	return f.sparse
+}
+
+func (f *fakeConfig) Trim() bool {
+	return f.trim
+}
+
 func (f *fakeConfig) FFU() bool {
 	return f.ffu
 }
@@ -118,16 +239,120 @@ func (f *fakeConfig) FFUConfPath() string {
 	return f.ffuConfPath
 }
 
+func (f *fakeConfig) SignServer() string {
+	return f.signServer
+}
+
+func (f *fakeConfig) SignedImages() string {
+	return f.signedImages
+}
+
+func (f *fakeConfig) ManifestFiles() []string {
+	return f.manifest
+}
+
+func (f *fakeConfig) PartitionRules() []config.PartitionRule {
+	return f.partitions
+}
+
+func (f *fakeConfig) DataFileSystem() string {
+	if f.dataFileSystem == "" {
+		return "NTFS"
+	}
+	return f.dataFileSystem
+}
+
+func (f *fakeConfig) Persistence() bool {
+	return f.persistence
+}
+
+func (f *fakeConfig) PersistenceSize() units.Size {
+	return f.persistenceSize
+}
+
+func (f *fakeConfig) PersistenceLabel() string {
+	return f.persistenceLabel
+}
+
+func (f *fakeConfig) Scanner() []string {
+	return f.scanner
+}
+
+func (f *fakeConfig) DevicePolicy() config.DevicePolicy {
+	return f.devices
+}
+
+func (f *fakeConfig) PreserveAttributes() bool {
+	return f.preserve
+}
+
+func (f *fakeConfig) SeedShelfLife() time.Duration {
+	return f.shelfLife
+}
+
+func (f *fakeConfig) Batch() string {
+	return f.batch
+}
+
+func (f *fakeConfig) SeedFields() map[string]string {
+	return f.seedFields
+}
+
+func (f *fakeConfig) Cleanup() bool {
+	return f.cleanup
+}
+
+func (f *fakeConfig) CacheDir() string {
+	return f.cacheDir
+}
+
+func (f *fakeConfig) CacheMaxAge() time.Duration {
+	return f.cacheAge
+}
+
+func (f *fakeConfig) CacheMaxSize() units.Size {
+	return f.cacheSize
+}
+
+func (f *fakeConfig) ContentsOnMedia() bool {
+	return f.contents
+}
+
+func (f *fakeConfig) LogOnMedia() bool {
+	return f.logOnMedia
+}
+
+func (f *fakeConfig) Unattend() string {
+	return f.unattend
+}
+
+func (f *fakeConfig) UnattendDest() string {
+	return f.unattendTo
+}
+
+func (f *fakeConfig) Impersonate() string {
+	return f.impersonate
+}
+
+func (f *fakeConfig) Delegate() string {
+	return f.delegate
+}
+
 func TestNew(t *testing.T) {
 	// Generate a fake config to use with New.
 	c := &fakeConfig{
 		imagePath:  `https://foo.bar.com/test_installer.img`,
 		seedServer: `https://bar.baz.com/endpoint`,
 	}
+	// A cache directory cannot be created beneath a file.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", file, err)
+	}
 	tests := []struct {
 		desc          string
 		config        Configuration
-		fakeConnect   func(string, string) (httpDoer, error)
+		fakeConnect   func(string, string, string) (httpDoer, error)
 		wantInstaller bool
 		err           error
 	}{
@@ -139,16 +364,26 @@ func TestNew(t *testing.T) {
 		{
 			desc:        "connect error",
 			config:      c,
-			fakeConnect: func(string, string) (httpDoer, error) { return nil, errors.New("error") },
+			fakeConnect: func(string, string, string) (httpDoer, error) { return nil, errors.New("error") },
 			err:         errConnect,
 		},
 		{
 			desc:          "success",
 			config:        c,
-			fakeConnect:   func(string, string) (httpDoer, error) { return nil, nil },
+			fakeConnect:   func(string, string, string) (httpDoer, error) { return nil, nil },
 			wantInstaller: true,
 			err:           nil,
 		},
+		{
+			desc:          "cache directory",
+			config:        &fakeConfig{imagePath: c.imagePath, cacheDir: filepath.Join(t.TempDir(), "cache")},
+			wantInstaller: true,
+		},
+		{
+			desc:   "cache directory error",
+			config: &fakeConfig{imagePath: c.imagePath, cacheDir: filepath.Join(file, "cache")},
+			err:    errPath,
+		},
 	}
 	for _, tt := range tests {
 		connect = tt.fakeConnect
@@ -159,16 +394,26 @@ func TestNew(t *testing.T) {
 		if (got == nil) == tt.wantInstaller {
 			t.Errorf("%s: New() got: %t, want: %t", tt.desc, (got != nil), tt.wantInstaller)
 		}
+		if got == nil {
+			continue
+		}
+		if dir := tt.config.CacheDir(); dir != "" && (got.Cache() != dir || !got.reuse) {
+			t.Errorf("%s: New() cache got: %q (reuse: %t), want: %q (reuse: true)", tt.desc, got.Cache(), got.reuse, dir)
+		}
 	}
 }
 
 func TestUserName(t *testing.T) {
 	// stdUser represents the user actually running the binary.
 	stdUser := "stdUser"
+	root := func() (*user.User, error) { return &user.User{Username: "root"}, nil }
 
 	tests := []struct {
 		desc            string
 		fakeCurrentUser func() (*user.User, error)
+		fakeLookupID    func(string) (*user.User, error)
+		env             map[string]string
+		stripDomain     bool
 		want            string
 		err             error
 	}{
@@ -179,34 +424,201 @@ func TestUserName(t *testing.T) {
 			err:             errUser,
 		},
 		{
-			desc:            "as root",
-			fakeCurrentUser: func() (*user.User, error) { return &user.User{Username: "root"}, nil },
+			desc:            "as root with sudo",
+			fakeCurrentUser: root,
+			env:             map[string]string{"SUDO_USER": stdUser},
+			want:            stdUser,
+			err:             nil,
+		},
+		{
+			desc:            "as root with doas",
+			fakeCurrentUser: root,
+			env:             map[string]string{"DOAS_USER": stdUser},
+			want:            stdUser,
+			err:             nil,
+		},
+		{
+			desc:            "as root with pkexec",
+			fakeCurrentUser: root,
+			fakeLookupID:    func(string) (*user.User, error) { return &user.User{Username: stdUser}, nil },
+			env:             map[string]string{"PKEXEC_UID": "1000"},
 			want:            stdUser,
 			err:             nil,
 		},
+		{
+			desc:            "pkexec lookup error",
+			fakeCurrentUser: root,
+			fakeLookupID:    func(string) (*user.User, error) { return nil, errors.New("error") },
+			env:             map[string]string{"PKEXEC_UID": "1000"},
+			want:            "",
+			err:             errUser,
+		},
+		{
+			desc:            "as root without an invoking user",
+			fakeCurrentUser: root,
+			want:            "",
+			err:             errEmptyUser,
+		},
 		{
 			desc:            "as user",
 			fakeCurrentUser: func() (*user.User, error) { return &user.User{Username: stdUser}, nil },
 			want:            stdUser,
 			err:             nil,
 		},
-	}
-	if err := os.Setenv("SUDO_USER", stdUser); err != nil {
-		t.Fatalf(`os.SetEnv("SUDO_USER", %q) returned %v`, stdUser, err)
+		{
+			desc:            "domain user",
+			fakeCurrentUser: func() (*user.User, error) { return &user.User{Username: `corp\` + stdUser}, nil },
+			want:            `CORP\` + stdUser,
+			err:             nil,
+		},
+		{
+			desc:            "domain user stripping domain",
+			fakeCurrentUser: func() (*user.User, error) { return &user.User{Username: `CORP\` + stdUser}, nil },
+			stripDomain:     true,
+			want:            stdUser,
+			err:             nil,
+		},
 	}
 	for _, tt := range tests {
+		for _, v := range []string{"SUDO_USER", "DOAS_USER", "PKEXEC_UID"} {
+			if err := os.Setenv(v, tt.env[v]); err != nil {
+				t.Fatalf(`os.Setenv(%q) returned %v`, v, err)
+			}
+		}
 		currentUser = tt.fakeCurrentUser
-		got, err := username()
+		lookupUserID = tt.fakeLookupID
+		got, err := username(tt.stripDomain)
 		if !errors.Is(err, tt.err) {
-			t.Errorf("%s: isRoot() err: %v, want err: %v", tt.desc, err, tt.err)
+			t.Errorf("%s: username() err: %v, want err: %v", tt.desc, err, tt.err)
 		}
 		if got != tt.want {
-			t.Errorf("%s: isRoot() got: %q, want: %q", tt.desc, got, tt.want)
+			t.Errorf("%s: username() got: %q, want: %q", tt.desc, got, tt.want)
 		}
 	}
 	// Cleanup
-	if err := os.Unsetenv("SUDO_USER"); err != nil {
-		t.Errorf(`os.UnsetEnv("SUDO_USER") returned %v`, err)
+	for _, v := range []string{"SUDO_USER", "DOAS_USER", "PKEXEC_UID"} {
+		if err := os.Unsetenv(v); err != nil {
+			t.Errorf(`os.Unsetenv(%q) returned %v`, v, err)
+		}
+	}
+}
+
+func TestNormalizeUser(t *testing.T) {
+	tests := []struct {
+		desc        string
+		name        string
+		stripDomain bool
+		want        string
+	}{
+		{desc: "no domain", name: "user", want: "user"},
+		{desc: "no domain stripped", name: "user", stripDomain: true, want: "user"},
+		{desc: "netbios kept", name: `corp\user`, want: `CORP\user`},
+		{desc: "netbios stripped", name: `CORP\user`, stripDomain: true, want: "user"},
+		{desc: "upn kept", name: "user@corp.example.com", want: `CORP\user`},
+		{desc: "upn stripped", name: "user@corp.example.com", stripDomain: true, want: "user"},
+		{desc: "whitespace", name: " user ", want: "user"},
+	}
+	for _, tt := range tests {
+		if got := normalizeUser(tt.name, tt.stripDomain); got != tt.want {
+			t.Errorf("%s: normalizeUser(%q, %t) got: %q, want: %q", tt.desc, tt.name, tt.stripDomain, got, tt.want)
+		}
+	}
+}
+
+func TestSeedUser(t *testing.T) {
+	orig := currentUser
+	defer func() { currentUser = orig }()
+	currentUser = func() (*user.User, error) { return &user.User{Username: "operator"}, nil }
+	tests := []struct {
+		desc   string
+		config *fakeConfig
+		want   string
+	}{
+		{
+			desc:   "signed in user",
+			config: &fakeConfig{},
+			want:   "operator",
+		},
+		{
+			desc:   "service account",
+			config: &fakeConfig{impersonate: "writer@project.iam.gserviceaccount.com"},
+			want:   "writer@project.iam.gserviceaccount.com",
+		},
+		{
+			desc:   "delegated user",
+			config: &fakeConfig{impersonate: "writer@project.iam.gserviceaccount.com", delegate: "operator@example.com"},
+			want:   "operator@example.com",
+		},
+	}
+	for _, tt := range tests {
+		got, err := seedUser(tt.config)
+		if err != nil {
+			t.Errorf("%s: seedUser() returned %v", tt.desc, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: seedUser() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestConnectAs(t *testing.T) {
+	defer func() { connect = fetcherConnect; connectImpersonated = impersonatedClient }()
+	tests := []struct {
+		desc   string
+		config *fakeConfig
+		want   string
+	}{
+		{
+			desc:   "signed in user",
+			config: &fakeConfig{},
+			want:   "connect https://seed.example.com as operator",
+		},
+		{
+			desc:   "service account",
+			config: &fakeConfig{impersonate: "writer@project.iam.gserviceaccount.com"},
+			want:   "impersonate writer@project.iam.gserviceaccount.com as ",
+		},
+		{
+			desc:   "delegated user",
+			config: &fakeConfig{impersonate: "writer@project.iam.gserviceaccount.com", delegate: "operator@example.com"},
+			want:   "impersonate writer@project.iam.gserviceaccount.com as operator@example.com",
+		},
+	}
+	for _, tt := range tests {
+		got := ""
+		connect = func(url, user, _ string) (httpDoer, error) {
+			got = fmt.Sprintf("connect %s as %s", url, user)
+			return nil, nil
+		}
+		connectImpersonated = func(account, user, _ string) (httpDoer, error) {
+			got = fmt.Sprintf("impersonate %s as %s", account, user)
+			return nil, nil
+		}
+		if _, err := connectAs(tt.config, "https://seed.example.com", "operator"); err != nil {
+			t.Errorf("%s: connectAs() returned %v", tt.desc, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: connectAs() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMachineMACs(t *testing.T) {
+	defer func() { interfaceMACs = netinfo.MACs }()
+	tests := []struct {
+		desc string
+		macs []string
+		err  error
+		want []string
+	}{
+		{desc: "found", macs: []string{"3c:22:fb:12:34:56"}, want: []string{"3c:22:fb:12:34:56"}},
+		{desc: "error", err: errors.New("error")},
+	}
+	for _, tt := range tests {
+		interfaceMACs = func() ([]string, error) { return tt.macs, tt.err }
+		if diff := cmp.Diff(tt.want, machineMACs()); diff != "" {
+			t.Errorf("%s: machineMACs() returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
 	}
 }
 
@@ -298,16 +710,17 @@ func TestRetrieveFile(t *testing.T) {
 		filePath  string
 		fileName  string
 		installer *Installer
-		doer      func() (httpDoer, error)
+		doer      func(string) (httpDoer, error)
 		download  func(client httpDoer, path string, w io.Writer) error
 		want      error
+		wantReuse bool
 	}{
 		{
 			desc:      "connection error",
 			filePath:  "https://foo.bar.com/test_installer.img",
 			fileName:  "test_installer.img",
-			installer: &Installer{cache: fakeCache},
-			doer:      func() (httpDoer, error) { return &fakeHTTPDoer{}, errConnect },
+			installer: &Installer{config: &fakeConfig{}, cache: fakeCache},
+			doer:      func(string) (httpDoer, error) { return &fakeHTTPDoer{}, errConnect },
 			download:  func(client httpDoer, path string, w io.Writer) error { return nil },
 			want:      errConnect,
 		},
@@ -315,8 +728,8 @@ func TestRetrieveFile(t *testing.T) {
 			desc:      "download failure",
 			filePath:  "https://foo.bar.com/test_installer.img",
 			fileName:  "test_installer.img",
-			installer: &Installer{cache: fakeCache},
-			doer:      func() (httpDoer, error) { return &fakeHTTPDoer{}, nil },
+			installer: &Installer{config: &fakeConfig{}, cache: fakeCache},
+			doer:      func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil },
 			download:  func(client httpDoer, path string, w io.Writer) error { return errDownload },
 			want:      errDownload,
 		},
@@ -324,19 +737,49 @@ func TestRetrieveFile(t *testing.T) {
 			desc:      "download success",
 			filePath:  "https://foo.bar.com/test_installer.img",
 			fileName:  "test_installer.img",
-			installer: &Installer{cache: fakeCache},
-			doer:      func() (httpDoer, error) { return &fakeHTTPDoer{}, nil },
+			installer: &Installer{config: &fakeConfig{}, cache: fakeCache},
+			doer:      func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil },
 			download:  func(client httpDoer, path string, w io.Writer) error { return nil },
 			want:      nil,
 		},
+		{
+			desc:      "reused from cache directory",
+			filePath:  "https://foo.bar.com/test_installer.img",
+			fileName:  "test_installer.img",
+			installer: &Installer{config: &fakeConfig{}, cache: fakeCache, reuse: true},
+			doer:      func(string) (httpDoer, error) { return nil, errConnect },
+			wantReuse: true,
+		},
 	}
 	for _, tt := range tests {
+		// Leave a stale file from an earlier download in the cache.
+		path := filepath.Join(fakeCache, tt.fileName)
+		if err := ioutil.WriteFile(path, []byte("stale"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
 		downloadFile = tt.download
 		connectWithCert = tt.doer
 		got := tt.installer.retrieveFile(tt.fileName, tt.filePath)
 		if !errors.Is(got, tt.want) {
 			t.Errorf("%s: retrieveFile() got: %v, want: %v", tt.desc, got, tt.want)
 		}
+		// The temporary file never remains, and the stale file is replaced
+		// only on success.
+		if _, err := os.Stat(path + tmpSuffix); !os.IsNotExist(err) {
+			t.Errorf("%s: os.Stat(%q) got: %v, want: not exist", tt.desc, path+tmpSuffix, err)
+		}
+		b, err := ioutil.ReadFile(path)
+		switch {
+		case tt.want != nil && !os.IsNotExist(err):
+			t.Errorf("%s: ioutil.ReadFile(%q) got: %v, want: not exist", tt.desc, path, err)
+		case tt.wantReuse && string(b) != "stale":
+			t.Errorf("%s: ioutil.ReadFile(%q) got: %q, %v, want: reused file", tt.desc, path, b, err)
+		case tt.want == nil && !tt.wantReuse && (err != nil || string(b) == "stale"):
+			t.Errorf("%s: ioutil.ReadFile(%q) got: %q, %v, want: downloaded file", tt.desc, path, b, err)
+		}
+		if _, ok := tt.installer.hashes[path]; tt.want == nil && !ok {
+			t.Errorf("%s: retrieveFile() did not record the hash of %q", tt.desc, path)
+		}
 	}
 	// Cleanup
 	if err := os.RemoveAll(fakeCache); err != nil {
@@ -344,21 +787,56 @@ func TestRetrieveFile(t *testing.T) {
 	}
 }
 
+func TestRetrieveFileHash(t *testing.T) {
+	fakeCache, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "test") returned %v`, err)
+	}
+	defer os.RemoveAll(fakeCache)
+
+	downloadFile = func(client httpDoer, path string, w io.Writer) error {
+		_, err := w.Write([]byte("test content"))
+		return err
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	want := []byte{106, 232, 167, 85, 85, 32, 159, 214, 196, 65, 87, 192, 174, 216, 1, 110, 118, 63, 244, 53, 161, 156, 241, 134, 247, 104, 99, 20, 1, 67, 255, 114}
+
+	i := &Installer{config: &fakeConfig{}, cache: fakeCache}
+	if err := i.retrieveFile("test_installer.img", "https://foo.bar.com/test_installer.img"); err != nil {
+		t.Fatalf("retrieveFile() returned %v", err)
+	}
+	path := filepath.Join(fakeCache, "test_installer.img")
+	// Remove the file to confirm that the hash is not computed a second time.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("os.Remove(%q) returned %v", path, err)
+	}
+	got, err := i.fileHash(path, models.HashSHA256)
+	if err != nil {
+		t.Fatalf("fileHash(%q) returned %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("fileHash(%q) got: %q, want: %q", path, hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+}
+
 // fakeHTTPDoer serves as a replacemenet for an http client for testing.
 // The contents of body are returned when the Do is called. This method
 // is used instead of httptest as a workaround for b/122585482.
 type fakeHTTPDoer struct {
-	statusCode int
-	body       []byte
-	err        error
+	statusCode    int
+	body          []byte
+	contentLength int64
+	err           error
+	req           *http.Request
 }
 
 // Do provides the contents of fakeHTTPDoer.body as an http.Response by
-// wrapping it with an io.ReadCloser.
+// wrapping it with an io.ReadCloser. The request is retained for inspection.
 func (c *fakeHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
 	reader := bytes.NewReader(c.body)
 	readCloser := ioutil.NopCloser(reader)
-	return &http.Response{StatusCode: c.statusCode, Body: readCloser}, c.err
+	return &http.Response{StatusCode: c.statusCode, Body: readCloser, ContentLength: c.contentLength}, c.err
 }
 
 // fakeWriter serves as a replacement for an io.Writer for testing.
@@ -409,6 +887,20 @@ func TestDownload(t *testing.T) {
 			writer: &fakeWriter{},
 			want:   errStatus,
 		},
+		{
+			desc:   "truncated body",
+			doer:   &fakeHTTPDoer{statusCode: http.StatusOK, body: []byte("test"), contentLength: 10},
+			path:   path,
+			writer: &bytes.Buffer{},
+			want:   errDownload,
+		},
+		{
+			desc:   "success",
+			doer:   &fakeHTTPDoer{statusCode: http.StatusOK, body: []byte("test"), contentLength: 4},
+			path:   path,
+			writer: &bytes.Buffer{},
+			want:   nil,
+		},
 	}
 	for _, tt := range tests {
 		got := download(tt.doer, tt.path, tt.writer)
@@ -428,6 +920,10 @@ type fakeDevice struct {
 
 	part partition
 
+	id   string
+	name string
+	size uint64
+
 	dmErr     error
 	ejectErr  error
 	detectErr error
@@ -435,6 +931,8 @@ type fakeDevice struct {
 	selErr    error
 	wipeErr   error
 	writeErr  error
+
+	ejected bool
 }
 
 func (f *fakeDevice) Dismount() error {
@@ -442,6 +940,7 @@ func (f *fakeDevice) Dismount() error {
 }
 
 func (f *fakeDevice) Eject() error {
+	f.ejected = true
 	return f.ejectErr
 }
 
@@ -461,6 +960,18 @@ func (f *fakeDevice) Wipe() error {
 	return f.wipeErr
 }
 
+func (f *fakeDevice) Identifier() string {
+	return f.id
+}
+
+func (f *fakeDevice) FriendlyName() string {
+	return f.name
+}
+
+func (f *fakeDevice) Size() uint64 {
+	return f.size
+}
+
 // fakePartition represents storage.Partition.
 type fakePartition struct {
 	contents []string
@@ -501,18 +1012,24 @@ func (f *fakePartition) MountPoint() string {
 
 func TestPrepare(t *testing.T) {
 	// Prepare stand-ins for an image file.
-	badImage, err := ioutil.TempDir("", "bad.txt")
+	badImage, err := ioutil.TempDir("", "*.txt")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir('', '*.txt') returned %v", err)
+	}
+	goodISO, err := ioutil.TempDir("", "*.iso")
 	if err != nil {
-		t.Fatalf("ioutil.TempDir('', 'bad.txt') returned %v", err)
+		t.Fatalf("ioutil.TempDir('', '*.iso') returned %v", err)
 	}
-	goodISO, err := ioutil.TempDir("", "good.iso")
+	goodRaw, err := ioutil.TempDir("", "*.img")
 	if err != nil {
-		t.Fatalf("ioutil.TempDir('', 'good.iso') returned %v", err)
+		t.Fatalf("ioutil.TempDir('', '*.img') returned %v", err)
 	}
-	goodRaw, err := ioutil.TempDir("", "good.img")
+	staleISO, err := ioutil.TempFile("", "*.iso")
 	if err != nil {
-		t.Fatalf("ioutil.TempDir('', 'good.img') returned %v", err)
+		t.Fatalf("ioutil.TempFile('', '*.iso') returned %v", err)
 	}
+	staleISO.Close()
+	defer os.Remove(staleISO.Name())
 
 	tests := []struct {
 		desc      string
@@ -547,6 +1064,12 @@ func TestPrepare(t *testing.T) {
 			device:    &fakeDevice{},
 			want:      errPath,
 		},
+		{
+			desc:      "image does not match",
+			installer: &Installer{config: &fakeConfig{imageFile: staleISO.Name(), elevated: true}, digest: digestOf("image")},
+			device:    &fakeDevice{},
+			want:      errDigest,
+		},
 		{
 			desc:      "not a supported image",
 			installer: &Installer{config: &fakeConfig{imageFile: badImage}},
@@ -565,6 +1088,24 @@ func TestPrepare(t *testing.T) {
 			device:    &fakeDevice{},
 			want:      nil,
 		},
+		{
+			desc:      "persistence after iso",
+			installer: &Installer{config: &fakeConfig{imageFile: goodISO, elevated: true, persistence: true}},
+			device:    &fakeDevice{},
+			want:      errUnsupported,
+		},
+		{
+			desc:      "log on media after raw",
+			installer: &Installer{config: &fakeConfig{imageFile: goodRaw, elevated: true, logOnMedia: true}},
+			device:    &fakeDevice{},
+			want:      errUnsupported,
+		},
+		{
+			desc:      "answer file after raw",
+			installer: &Installer{config: &fakeConfig{imageFile: goodRaw, elevated: true, unattend: "autounattend.xml", unattendTo: "autounattend.xml"}},
+			device:    &fakeDevice{},
+			want:      errUnsupported,
+		},
 		{
 			desc:      "prepare for iso with elevation failure",
 			installer: &Installer{config: &fakeConfig{imageFile: goodISO, elevated: true}},
@@ -608,6 +1149,7 @@ func TestPrepareForISOWithElevation(t *testing.T) {
 		installer *Installer
 		device    *fakeDevice
 		selPart   func(Device, uint64, storage.FileSystem) (partition, error)
+		platform  platform
 		want      error
 	}{
 		{
@@ -633,12 +1175,15 @@ func TestPrepareForISOWithElevation(t *testing.T) {
 			installer: &Installer{config: &fakeConfig{elevated: true}},
 			device:    &fakeDevice{},
 			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return nil, errors.New("error") },
-			want: func() error {
-				if runtime.GOOS != "darwin" {
-					return errPrepare
-				}
-				return nil
-			}(),
+			want:      errPrepare,
+		},
+		{
+			desc:      "partition formatted by platform",
+			installer: &Installer{config: &fakeConfig{elevated: true}},
+			device:    &fakeDevice{},
+			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return nil, errors.New("error") },
+			platform:  darwinPlatform{},
+			want:      nil,
 		},
 		{
 			desc:      "format error",
@@ -647,12 +1192,7 @@ func TestPrepareForISOWithElevation(t *testing.T) {
 			selPart: func(Device, uint64, storage.FileSystem) (partition, error) {
 				return &fakePartition{err: errors.New("error")}, nil
 			},
-			want: func() error {
-				if runtime.GOOS != "darwin" {
-					return errFormat
-				}
-				return nil
-			}(),
+			want: errFormat,
 		},
 		{
 			desc:      "success",
@@ -662,8 +1202,13 @@ func TestPrepareForISOWithElevation(t *testing.T) {
 			want:      nil,
 		},
 	}
+	defer func() { host = platformFor(runtime.GOOS) }()
 	for _, tt := range tests {
 		selectPart = tt.selPart
+		host = tt.platform
+		if host == nil {
+			host = linuxPlatform{}
+		}
 		got := tt.installer.prepareForISOWithElevation(tt.device, uint64(1024))
 		if !errors.Is(got, tt.want) {
 			t.Errorf("%s: prepareForISOWithElevation() got: %v, want: %v", tt.desc, got, tt.want)
@@ -692,14 +1237,6 @@ func TestPrepareForISOWithoutElevation(t *testing.T) {
 			},
 			want: errMount,
 		},
-		{
-			desc:      "erase error",
-			installer: &Installer{config: &fakeConfig{}},
-			selPart: func(Device, uint64, storage.FileSystem) (partition, error) {
-				return &fakePartition{err: errors.New("error")}, nil
-			},
-			want: errWipe,
-		},
 		{
 			desc:      "success",
 			installer: &Installer{config: &fakeConfig{}},
@@ -716,25 +1253,64 @@ func TestPrepareForISOWithoutElevation(t *testing.T) {
 	}
 }
 
-func TestPrepareForRaw(t *testing.T) {
+func TestFileSystem(t *testing.T) {
 	tests := []struct {
 		desc   string
-		device *fakeDevice
+		config *fakeConfig
+		want   storage.FileSystem
+	}{
+		{
+			desc:   "provision",
+			config: &fakeConfig{devices: config.DevicePolicy{FileSystem: "NTFS"}},
+			want:   storage.FAT32,
+		},
+		{
+			desc:   "update without policy",
+			config: &fakeConfig{update: true},
+			want:   storage.FAT32,
+		},
+		{
+			desc:   "update with policy",
+			config: &fakeConfig{update: true, devices: config.DevicePolicy{FileSystem: "NTFS"}},
+			want:   storage.NTFS,
+		},
+	}
+	for _, tt := range tests {
+		i := &Installer{config: tt.config}
+		if got := i.fileSystem(); got != tt.want {
+			t.Errorf("%s: fileSystem() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareForRaw(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config *fakeConfig
+		device *fakeDevice
 		want   error
 	}{
+		{
+			desc:   "not elevated",
+			config: &fakeConfig{elevated: false},
+			device: &fakeDevice{},
+			want:   errElevation,
+		},
 		{
 			desc:   "dismount error",
+			config: &fakeConfig{elevated: true},
 			device: &fakeDevice{dmErr: ErrLabel},
 			want:   ErrLabel,
 		},
 		{
 			desc:   "success",
+			config: &fakeConfig{elevated: true},
 			device: &fakeDevice{},
 			want:   nil,
 		},
 	}
 	for _, tt := range tests {
-		installer := &Installer{}
+		installer := &Installer{config: tt.config}
 		got := installer.prepareForRaw(tt.device)
 		if !errors.Is(got, tt.want) {
 			t.Errorf("%s: prepareForRaw() got: %v, want: %v", tt.desc, got, tt.want)
@@ -791,7 +1367,7 @@ func TestProvision(t *testing.T) {
 		desc      string
 		installer *Installer
 		mount     func(string) (isoHandler, error)
-		writeISO  func(isoHandler, partition) error
+		writeISO  func(isoHandler, map[string]partition, copyOptions) error
 		want      error
 	}{
 		{
@@ -828,7 +1404,7 @@ func TestProvision(t *testing.T) {
 			desc:      "success",
 			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso"}},
 			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
-			writeISO:  func(isoHandler, partition) error { return nil },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return nil },
 			want:      nil,
 		},
 	}
@@ -860,7 +1436,8 @@ func TestProvisionISO(t *testing.T) {
 		device    *fakeDevice
 		mount     func(string) (isoHandler, error)
 		selPart   func(Device, uint64, storage.FileSystem) (partition, error)
-		writeISO  func(isoHandler, partition) error
+		writeISO  func(isoHandler, map[string]partition, copyOptions) error
+		updateISO func(isoHandler, partition, copyOptions) error
 		want      error
 	}{
 		{
@@ -885,7 +1462,7 @@ func TestProvisionISO(t *testing.T) {
 			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
 			device:    &fakeDevice{},
 			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
-			writeISO:  func(isoHandler, partition) error { return errPath },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return errPath },
 			want:      errProvision,
 		},
 		{
@@ -894,7 +1471,7 @@ func TestProvisionISO(t *testing.T) {
 			mount:     func(string) (isoHandler, error) { return &fakeHandler{err: errIO}, nil },
 			device:    &fakeDevice{},
 			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
-			writeISO:  func(isoHandler, partition) error { return nil },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return nil },
 			want:      errIO,
 		},
 		{
@@ -903,13 +1480,71 @@ func TestProvisionISO(t *testing.T) {
 			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
 			device:    &fakeDevice{},
 			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
-			writeISO:  func(isoHandler, partition) error { return nil },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return nil },
+			want:      nil,
+		},
+		{
+			desc:      "missing data partition",
+			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso", partitions: []config.PartitionRule{{Glob: "drivers", Role: config.DataPartition}}}},
+			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
+			device:    &fakeDevice{},
+			selPart: func(_ Device, _ uint64, fs storage.FileSystem) (partition, error) {
+				if fs == storage.NTFS {
+					return nil, errors.New("error")
+				}
+				return &fakePartition{label: "test"}, nil
+			},
+			writeISO: func(isoHandler, map[string]partition, copyOptions) error { return nil },
+			want:     errPartition,
+		},
+		{
+			desc:      "data partition success",
+			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso", partitions: []config.PartitionRule{{Glob: "drivers", Role: config.DataPartition}}}},
+			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
+			device:    &fakeDevice{},
+			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
+			writeISO: func(_ isoHandler, parts map[string]partition, _ copyOptions) error {
+				if parts[config.DataPartition] == nil {
+					return errPartition
+				}
+				return nil
+			},
+			want: nil,
+		},
+		{
+			desc:      "update with partition rules",
+			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso", update: true, partitions: []config.PartitionRule{{Glob: "drivers", Role: config.DataPartition}}}},
+			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
+			device:    &fakeDevice{},
+			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
+			updateISO: func(isoHandler, partition, copyOptions) error { return nil },
+			want:      errUnsupported,
+		},
+		{
+			desc:      "updateISO error",
+			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso", update: true}},
+			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
+			device:    &fakeDevice{},
+			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return nil },
+			updateISO: func(isoHandler, partition, copyOptions) error { return errIO },
+			want:      errProvision,
+		},
+		{
+			desc:      "update success",
+			installer: &Installer{cache: fakeCache, config: &fakeConfig{imageFile: "fake.iso", update: true}},
+			mount:     func(string) (isoHandler, error) { return &fakeHandler{}, nil },
+			device:    &fakeDevice{},
+			selPart:   func(Device, uint64, storage.FileSystem) (partition, error) { return &fakePartition{label: "test"}, nil },
+			writeISO:  func(isoHandler, map[string]partition, copyOptions) error { return errPath },
+			updateISO: func(isoHandler, partition, copyOptions) error { return nil },
 			want:      nil,
 		},
 	}
 	for _, tt := range tests {
 		mount = tt.mount
 		writeISOFunc = tt.writeISO
+		updateISOFunc = tt.updateISO
 		selectPart = tt.selPart
 		got := tt.installer.provisionISO(tt.device)
 		if !errors.Is(got, tt.want) {
@@ -1011,13 +1646,230 @@ func TestWriteISO(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := writeISO(tt.iso, tt.part)
+		got := writeISO(tt.iso, map[string]partition{config.BootPartition: tt.part}, copyOptions{})
 		if !errors.Is(got, tt.want) {
 			t.Errorf("%s: WriteISO got = %q, want = %q", tt.desc, got, tt.want)
 		}
 	}
 }
 
+func TestWriteISOPartitionRules(t *testing.T) {
+	src, err := ioutil.TempDir("", "iso")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "iso") returned %v`, err)
+	}
+	defer os.RemoveAll(src)
+	boot, err := ioutil.TempDir("", "boot")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "boot") returned %v`, err)
+	}
+	defer os.RemoveAll(boot)
+	data, err := ioutil.TempDir("", "data")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "data") returned %v`, err)
+	}
+	defer os.RemoveAll(data)
+
+	files := []string{"bootmgr", "sources/boot.wim", "sources/install.wim", "Drivers/net/e1000.inf"}
+	for _, f := range files {
+		path := filepath.Join(src, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+	}
+	iso := &fakeISO{mount: src, contents: files}
+	rules := []config.PartitionRule{
+		{Glob: "drivers", Role: config.DataPartition},
+		{Glob: "sources/boot.wim", Role: config.BootPartition},
+		{Glob: "sources/*.wim", Role: config.DataPartition},
+	}
+	parts := map[string]partition{
+		config.BootPartition: &fakePartition{mount: boot},
+		config.DataPartition: &fakePartition{mount: data},
+	}
+	if err := writeISO(iso, parts, copyOptions{rules: rules}); err != nil {
+		t.Fatalf("writeISO() returned %v", err)
+	}
+	// The first matching rule wins, so boot.wim stays on the boot partition
+	// while other WIM files are placed on the data partition.
+	want := map[string]string{
+		"bootmgr":               boot,
+		"sources/boot.wim":      boot,
+		"sources/install.wim":   data,
+		"Drivers/net/e1000.inf": data,
+	}
+	for f, dir := range want {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f))); err != nil {
+			t.Errorf("writeISO() did not place %q in %q: %v", f, dir, err)
+		}
+	}
+
+	// Rules that name a partition that is not available are an error.
+	if err := os.RemoveAll(boot); err != nil {
+		t.Fatalf("os.RemoveAll(%q) returned %v", boot, err)
+	}
+	if err := os.Mkdir(boot, 0755); err != nil {
+		t.Fatalf("os.Mkdir(%q) returned %v", boot, err)
+	}
+	got := writeISO(iso, map[string]partition{config.BootPartition: &fakePartition{mount: boot}}, copyOptions{rules: rules})
+	if !errors.Is(got, errPartition) {
+		t.Errorf("writeISO() without a data partition got: %v, want: %v", got, errPartition)
+	}
+}
+
+func TestPartitionRole(t *testing.T) {
+	rules := []config.PartitionRule{
+		{Glob: "drivers", Role: config.DataPartition},
+		{Glob: "sources/install.*", Role: config.DataPartition},
+	}
+	tests := []struct {
+		desc string
+		rel  string
+		want string
+	}{
+		{"no match", "bootmgr", config.BootPartition},
+		{"file glob", "sources/install.wim", config.DataPartition},
+		{"directory", "drivers/net/e1000.inf", config.DataPartition},
+		{"case insensitive", "Drivers/NET/E1000.INF", config.DataPartition},
+		{"sibling", "sources/boot.wim", config.BootPartition},
+		{"prefix only", "drivers2/e1000.inf", config.BootPartition},
+	}
+	for _, tt := range tests {
+		if got := partitionRole(tt.rel, rules); got != tt.want {
+			t.Errorf("%s: partitionRole(%q) got: %q, want: %q", tt.desc, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateISO(t *testing.T) {
+	src, err := ioutil.TempDir("", "iso")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "iso") returned %v`, err)
+	}
+	defer os.RemoveAll(src)
+	dest, err := ioutil.TempDir("", "part")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "part") returned %v`, err)
+	}
+	defer os.RemoveAll(dest)
+
+	// The ISO contains an unchanged, a changed and a new file. The partition
+	// also contains a stale file that is no longer part of the ISO.
+	files := []struct {
+		dir     string
+		name    string
+		content string
+	}{
+		{src, "same.txt", "same"},
+		{dest, "same.txt", "same"},
+		{src, filepath.Join("sources", "changed.txt"), "new"},
+		{dest, filepath.Join("sources", "changed.txt"), "old"},
+		{src, "added.txt", "added"},
+		{dest, "stale.txt", "stale"},
+	}
+	for _, f := range files {
+		path := filepath.Join(f.dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f.content), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		desc string
+		iso  isoHandler
+		part partition
+		want error
+	}{
+		{
+			desc: "empty partition",
+			iso:  &fakeISO{mount: src},
+			want: errPartition,
+		},
+		{
+			desc: "partition not mounted",
+			iso:  &fakeISO{mount: src},
+			part: &fakePartition{},
+			want: errMount,
+		},
+		{
+			desc: "iso not mounted",
+			iso:  &fakeISO{},
+			part: &fakePartition{mount: dest},
+			want: errInput,
+		},
+		{
+			desc: "success",
+			iso:  &fakeISO{mount: src},
+			part: &fakePartition{mount: dest},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		got := updateISO(tt.iso, tt.part, copyOptions{})
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: updateISO() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+
+	want := map[string]string{
+		"same.txt":                              "same",
+		filepath.Join("sources", "changed.txt"): "new",
+		"added.txt":                             "added",
+	}
+	for name, content := range want {
+		got, err := ioutil.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Errorf("updateISO() did not provide %q: %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("updateISO() %q got: %q, want: %q", name, got, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "stale.txt")); !os.IsNotExist(err) {
+		t.Errorf("updateISO() did not remove stale.txt, os.Stat() returned %v", err)
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{"a": "test", "b": "test", "c": "tests", "d": "tset"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		desc string
+		dest string
+		want bool
+	}{
+		{"identical", "b", true},
+		{"different size", "c", false},
+		{"different content", "d", false},
+		{"missing", "e", false},
+	}
+	for _, tt := range tests {
+		got, err := sameFile(filepath.Join(dir, "a"), filepath.Join(dir, tt.dest), 4)
+		if err != nil {
+			t.Errorf("%s: sameFile() returned %v", tt.desc, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: sameFile() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestWriteSeed(t *testing.T) {
 	// Create a temporary file and folder for the test.
 	tempDir, err := ioutil.TempDir("", "")
@@ -1042,7 +1894,7 @@ func TestWriteSeed(t *testing.T) {
 	tests := []struct {
 		desc        string
 		installer   *Installer
-		fakeConnect func(string, string) (httpDoer, error)
+		fakeConnect func(string, string, string) (httpDoer, error)
 		handler     *fakeHandler
 		part        *fakePartition
 		want        error
@@ -1062,7 +1914,7 @@ func TestWriteSeed(t *testing.T) {
 		{
 			desc:        "connect error",
 			installer:   &Installer{config: &fakeConfig{}},
-			fakeConnect: func(string, string) (httpDoer, error) { return nil, errors.New("error") },
+			fakeConnect: func(string, string, string) (httpDoer, error) { return nil, errors.New("error") },
 			handler:     &fakeHandler{},
 			part:        &fakePartition{label: "Test", mount: tempDir},
 			want:        errFile,
@@ -1070,7 +1922,7 @@ func TestWriteSeed(t *testing.T) {
 		{
 			desc:        "seed request error",
 			installer:   &Installer{config: &fakeConfig{seedServer: `:`, seedFile: f.Name()}},
-			fakeConnect: func(string, string) (httpDoer, error) { return nil, nil },
+			fakeConnect: func(string, string, string) (httpDoer, error) { return nil, nil },
 			handler:     &fakeHandler{},
 			part:        &fakePartition{label: "Test", mount: tempDir},
 			want:        errDownload,
@@ -1084,7 +1936,7 @@ func TestWriteSeed(t *testing.T) {
 					seedServer: `https://foo.bar.com/seed`,
 				},
 			},
-			fakeConnect: func(string, string) (httpDoer, error) { return &fakeHTTPDoer{body: good}, nil },
+			fakeConnect: func(string, string, string) (httpDoer, error) { return &fakeHTTPDoer{body: good}, nil },
 			handler:     &fakeHandler{mount: tempDir},
 			part:        &fakePartition{label: "Test", mount: tempDir},
 			want:        nil,
@@ -1099,6 +1951,271 @@ func TestWriteSeed(t *testing.T) {
 	}
 }
 
+// fakeSeedServer responds to successive seed requests with the contents of
+// responses, and retains the requests for inspection.
+type fakeSeedServer struct {
+	responses []models.SeedResponse
+	requests  []models.SeedRequest
+}
+
+func (s *fakeSeedServer) Do(req *http.Request) (*http.Response, error) {
+	sr := models.SeedRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+	s.requests = append(s.requests, sr)
+	body, err := json.Marshal(s.responses[len(s.requests)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestRequestSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("","") returned %v`, err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "seed.wim"), []byte("test content"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	sum := sha512.Sum512([]byte("test content"))
+
+	unsupported := func(accepted ...models.HashAlgorithm) models.SeedResponse {
+		return models.SeedResponse{ErrorCode: models.StatusUnsupportedHash, Algorithms: accepted}
+	}
+	success := models.SeedResponse{ErrorCode: models.StatusSuccess}
+
+	tests := []struct {
+		desc      string
+		responses []models.SeedResponse
+		wantAlgs  []models.HashAlgorithm
+		wantHash  []byte
+		want      error
+	}{
+		{
+			desc:      "accepted",
+			responses: []models.SeedResponse{success},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256},
+			wantHash:  []byte("hash"),
+		},
+		{
+			desc:      "negotiated",
+			responses: []models.SeedResponse{unsupported(models.HashSHA512), success},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256, models.HashSHA512},
+			wantHash:  sum[:],
+		},
+		{
+			desc:      "no common algorithm",
+			responses: []models.SeedResponse{unsupported("blake3")},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256},
+			want:      client.ErrAlgorithm,
+		},
+		{
+			desc:      "retried once",
+			responses: []models.SeedResponse{unsupported(models.HashSHA512), unsupported(models.HashSHA256)},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256, models.HashSHA512},
+			want:      client.ErrAlgorithm,
+		},
+	}
+	for _, tt := range tests {
+		server := &fakeSeedServer{responses: tt.responses}
+		fields := map[string]string{"cost_center": "1234"}
+		i := &Installer{config: &fakeConfig{seedFile: "seed.wim", seedFields: fields}}
+		handler := &fakeHandler{mount: dir, path: "image.iso"}
+		_, hash, alg, err := i.requestSeed(server, handler, "seed.wim", []byte("hash"), models.HashSHA256)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: requestSeed() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		var algs []models.HashAlgorithm
+		for _, r := range server.requests {
+			algs = append(algs, r.Algorithm)
+			if diff := cmp.Diff(fields, r.Fields); diff != "" {
+				t.Errorf("%s: requestSeed() fields mismatch (-want +got):\n%s", tt.desc, diff)
+			}
+		}
+		if diff := cmp.Diff(tt.wantAlgs, algs); diff != "" {
+			t.Errorf("%s: requestSeed() algorithms mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+		if err != nil {
+			continue
+		}
+		if alg != tt.wantAlgs[len(tt.wantAlgs)-1] {
+			t.Errorf("%s: requestSeed() algorithm: %q, want: %q", tt.desc, alg, tt.wantAlgs[len(tt.wantAlgs)-1])
+		}
+		if !bytes.Equal(hash, tt.wantHash) {
+			t.Errorf("%s: requestSeed() hash: %x, want: %x", tt.desc, hash, tt.wantHash)
+		}
+	}
+}
+
+func TestRequestBundle(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"boot.wim", "install.wim"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+	}
+	installHash := sha256.Sum256([]byte("install.wim"))
+	expires := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary := models.SeedFile{Seed: models.Seed{Username: "user"}, Algorithm: models.HashSHA256}
+	success := models.SeedResponse{ErrorCode: models.StatusSuccess, ExpiresAt: expires}
+
+	tests := []struct {
+		desc      string
+		seedFiles []string
+		responses []models.SeedResponse
+		wantFiles []string
+		want      error
+	}{
+		{
+			desc: "single seed file",
+		},
+		{
+			desc:      "bundled",
+			seedFiles: []string{"install.wim"},
+			responses: []models.SeedResponse{success},
+			wantFiles: []string{"boot.wim", "install.wim"},
+		},
+		{
+			desc:      "missing file",
+			seedFiles: []string{"missing.wim"},
+			want:      errFile,
+		},
+		{
+			desc:      "rejected",
+			seedFiles: []string{"install.wim"},
+			responses: []models.SeedResponse{{ErrorCode: models.StatusSeedInvalidHash, Status: "not in allowlist"}},
+			want:      errDownload,
+		},
+	}
+	for _, tt := range tests {
+		server := &fakeSeedServer{responses: tt.responses}
+		i := &Installer{config: &fakeConfig{seedFile: "boot.wim", seedFiles: tt.seedFiles}}
+		bundle, err := i.requestBundle(server, &fakeHandler{mount: dir, path: "image.iso"}, primary)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: requestBundle() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		var files []string
+		if bundle != nil {
+			for _, b := range bundle.Seeds {
+				files = append(files, b.File)
+			}
+		}
+		if diff := cmp.Diff(tt.wantFiles, files); diff != "" {
+			t.Errorf("%s: requestBundle() files mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+		if bundle == nil {
+			continue
+		}
+		if got := bundle.Seeds[0].SeedFile; !cmp.Equal(got, primary) {
+			t.Errorf("%s: requestBundle() first seed got: %+v, want: %+v", tt.desc, got, primary)
+		}
+		if got := bundle.Seeds[1].ExpiresAt; !got.Equal(expires) {
+			t.Errorf("%s: requestBundle() expiry got: %v, want: %v", tt.desc, got, expires)
+		}
+		if got := server.requests[0].Hash; !bytes.Equal(got, installHash[:]) {
+			t.Errorf("%s: requestBundle() requested hash: %x, want: %x", tt.desc, got, installHash)
+		}
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, bundleDestFile)
+	bundle := &models.SeedBundle{Seeds: []models.BundledSeed{{File: "boot.wim"}, {File: "install.wim"}}}
+	if err := writeBundle(dir, bundle); err != nil {
+		t.Fatalf("writeBundle() returned %v", err)
+	}
+	got := &models.SeedBundle{}
+	if err := readJSON(path, got); err != nil {
+		t.Fatalf("readJSON(%q) returned %v", path, err)
+	}
+	if diff := cmp.Diff(bundle, got); diff != "" {
+		t.Errorf("writeBundle() mismatch (-want +got):\n%s", diff)
+	}
+	// A bundle left by an earlier provisioning is removed when there is none.
+	if err := writeBundle(dir, nil); err != nil {
+		t.Fatalf("writeBundle(nil) returned %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("writeBundle(nil) left %q in place: %v", path, err)
+	}
+	if err := writeBundle(dir, nil); err != nil {
+		t.Errorf("writeBundle(nil) without a bundle returned %v", err)
+	}
+}
+
+func TestSeedHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("","") returned %v`, err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "seed.wim")
+	if err := ioutil.WriteFile(path, []byte("test content"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	want := sha512.Sum512([]byte("test content"))
+
+	i := &Installer{config: &fakeConfig{seedFile: "seed.wim"}}
+	handler := &fakeHandler{mount: dir, path: "image.iso"}
+	got, err := i.seedHash(handler, "seed.wim", models.HashSHA512)
+	if err != nil {
+		t.Fatalf("seedHash() returned %v", err)
+	}
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("seedHash() got: %x, want: %x", got, want)
+	}
+	// Remove the file to confirm that the hash is reused for the same image.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("os.Remove(%q) returned %v", path, err)
+	}
+	if got, err = i.seedHash(handler, "seed.wim", models.HashSHA512); err != nil {
+		t.Errorf("seedHash() for the same image returned %v", err)
+	}
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("seedHash() for the same image got: %x, want: %x", got, want)
+	}
+	if _, err := i.seedHash(&fakeHandler{mount: dir, path: "other.iso"}, "seed.wim", models.HashSHA512); err == nil {
+		t.Errorf("seedHash() for another image returned nil, want error")
+	}
+}
+
+func TestSeedHashAfterCopy(t *testing.T) {
+	src := t.TempDir()
+	path := filepath.Join(src, "sources", "boot.wim")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, []byte("test content"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+	}
+	want := sha256.Sum256([]byte("test content"))
+
+	i := &Installer{hashes: make(map[string][]byte)}
+	iso := &fakeISO{mount: src, contents: []string{"sources/boot.wim"}}
+	if err := writeISO(iso, map[string]partition{config.BootPartition: &fakePartition{mount: t.TempDir()}}, copyOptions{hashes: i.hashes}); err != nil {
+		t.Fatalf("writeISO() returned %v", err)
+	}
+	// Remove the file to confirm that the hash computed while it was copied
+	// is used rather than reading it from the ISO again.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("os.Remove(%q) returned %v", path, err)
+	}
+	got, err := i.seedHash(&fakeHandler{mount: src, path: "image.iso"}, "sources/boot.wim", models.HashSHA256)
+	if err != nil {
+		t.Fatalf("seedHash() returned %v", err)
+	}
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("seedHash() got: %x, want: %x", got, want)
+	}
+}
+
 func TestFileHash(t *testing.T) {
 	// Create a temporary file to test hashing.
 	f, err := ioutil.TempFile("", "")
@@ -1114,27 +2231,44 @@ func TestFileHash(t *testing.T) {
 	tests := []struct {
 		desc string
 		path string
+		alg  models.HashAlgorithm
 		out  []byte
 		want error
 	}{
 		{
 			desc: "empty path",
+			alg:  models.HashSHA256,
 			want: errInput,
 		},
 		{
 			desc: "bad path",
 			path: "nonexistent.iso",
+			alg:  models.HashSHA256,
 			want: errPath,
 		},
+		{
+			desc: "unsupported algorithm",
+			path: tempFile,
+			alg:  models.HashAlgorithm("md5"),
+			want: client.ErrAlgorithm,
+		},
 		{
 			desc: "good path",
 			path: tempFile,
+			alg:  models.HashSHA256,
 			out:  []byte{106, 232, 167, 85, 85, 32, 159, 214, 196, 65, 87, 192, 174, 216, 1, 110, 118, 63, 244, 53, 161, 156, 241, 134, 247, 104, 99, 20, 1, 67, 255, 114},
 			want: nil,
 		},
+		{
+			desc: "sha512",
+			path: tempFile,
+			alg:  models.HashSHA512,
+			out:  []byte{12, 191, 76, 174, 243, 128, 71, 187, 169, 162, 78, 98, 26, 150, 20, 132, 229, 210, 169, 33, 118, 168, 89, 231, 235, 39, 223, 52, 61, 211, 78, 185, 141, 83, 138, 108, 95, 77, 161, 206, 48, 46, 194, 80, 184, 33, 204, 0, 30, 70, 204, 151, 167, 4, 152, 130, 151, 24, 90, 77, 247, 233, 150, 2},
+			want: nil,
+		},
 	}
 	for _, tt := range tests {
-		out, got := fileHash(tt.path)
+		out, got := fileHash(tt.path, tt.alg)
 		if !errors.Is(got, tt.want) {
 			t.Errorf("%s: fileHash() err: %v, want: %v", tt.desc, got, tt.want)
 		}
@@ -1144,80 +2278,384 @@ func TestFileHash(t *testing.T) {
 	}
 }
 
-func TestSeedRequest(t *testing.T) {
-	// Model a bad response and a good response for testing.
-	bad, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSignError})
+func TestCheckSeedExpiry(t *testing.T) {
+	tests := []struct {
+		desc      string
+		expires   time.Time
+		shelfLife time.Duration
+		want      bool
+	}{
+		{
+			desc:      "unknown expiry",
+			shelfLife: time.Hour,
+			want:      false,
+		},
+		{
+			desc:    "no shelf life",
+			expires: time.Now().Add(time.Hour),
+			want:    false,
+		},
+		{
+			desc:      "expires after shelf life",
+			expires:   time.Now().Add(48 * time.Hour),
+			shelfLife: 24 * time.Hour,
+			want:      false,
+		},
+		{
+			desc:      "expires before shelf life",
+			expires:   time.Now().Add(12 * time.Hour),
+			shelfLife: 24 * time.Hour,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		if got := checkSeedExpiry(tt.expires, tt.shelfLife); got != tt.want {
+			t.Errorf("%s: checkSeedExpiry() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
-		t.Fatalf("json.Marshal of bad request returned %v", err)
+		t.Fatalf(`ioutil.TempDir("","") returned %v`, err)
 	}
-	good, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSuccess})
+	defer os.RemoveAll(tempDir)
+	good, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusSuccess, SignedURL: "https://signed.url"})
 	if err != nil {
-		t.Fatalf("json.Marshal of good request returned %v", err)
+		t.Fatalf("json.Marshal of good response returned %v", err)
+	}
+
+	tests := []struct {
+		desc        string
+		fakeConnect func(string, string, string) (httpDoer, error)
+		want        error
+	}{
+		{
+			desc:        "connect error",
+			fakeConnect: func(string, string, string) (httpDoer, error) { return nil, errors.New("error") },
+			want:        errConnect,
+		},
+		{
+			desc:        "sign request error",
+			fakeConnect: func(string, string, string) (httpDoer, error) { return &fakeHTTPDoer{err: errors.New("error")}, nil },
+			want:        errDownload,
+		},
+		{
+			desc:        "success",
+			fakeConnect: func(string, string, string) (httpDoer, error) { return &fakeHTTPDoer{body: good}, nil },
+			want:        nil,
+		},
+	}
+	for _, tt := range tests {
+		connect = tt.fakeConnect
+		i := &Installer{config: &fakeConfig{
+			signServer: `https://foo.bar.com/sign`,
+			manifest:   []string{"sources/boot.wim"},
+		}}
+		got := i.writeManifest(&models.SeedResponse{}, []byte("hash"), models.HashSHA256, "user", tempDir)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: writeManifest() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, manifestDestFile))
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile() returned %v", tt.desc, err)
+		}
+		m := models.BootstrapManifest{}
+		if err := json.Unmarshal(content, &m); err != nil {
+			t.Fatalf("%s: json.Unmarshal() returned %v", tt.desc, err)
+		}
+		if len(m.Files) != 1 || m.Files[0].SignedURL != "https://signed.url" {
+			t.Errorf("%s: manifest got: %+v, want one signed file", tt.desc, m)
+		}
+	}
+}
+
+func TestWriteBatchMarker(t *testing.T) {
+	expiry := time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc        string
+		batch       string
+		dir         string
+		seedSkipped bool
+		expiry      time.Time
+		want        error
+	}{
+		{
+			desc: "no batch",
+			dir:  filepath.Join("does", "not", "exist"),
+		},
+		{
+			desc:  "missing directory",
+			batch: "NYC-onboarding-June",
+			dir:   filepath.Join("does", "not", "exist"),
+			want:  errIO,
+		},
+		{
+			desc:  "success",
+			batch: "NYC-onboarding-June",
+		},
+		{
+			desc:        "seed skipped without batch",
+			seedSkipped: true,
+		},
+		{
+			desc:   "seed expiry without batch",
+			expiry: expiry,
+		},
+		{
+			desc:   "seed expiry with batch",
+			batch:  "NYC-onboarding-June",
+			expiry: expiry,
+		},
+	}
+	for _, tt := range tests {
+		dir := tt.dir
+		if dir == "" {
+			dir = t.TempDir()
+		}
+		i := &Installer{config: &fakeConfig{batch: tt.batch, distro: "windows", track: "stable"}, expiry: tt.expiry}
+		got := i.writeBatchMarker(dir, tt.seedSkipped)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: writeBatchMarker() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got != nil || (tt.batch == "" && !tt.seedSkipped && tt.expiry.IsZero()) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, batchDestFile))
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile() returned %v", tt.desc, err)
+		}
+		m := models.BatchMarker{}
+		if err := json.Unmarshal(content, &m); err != nil {
+			t.Fatalf("%s: json.Unmarshal() returned %v", tt.desc, err)
+		}
+		if m.Batch != tt.batch || m.Distro != "windows" || m.Track != "stable" || m.SeedSkipped != tt.seedSkipped {
+			t.Errorf("%s: marker got: %+v, want batch %q for windows/stable with seed skipped: %t", tt.desc, m, tt.batch, tt.seedSkipped)
+		}
+		if !m.SeedExpiry.Equal(tt.expiry) {
+			t.Errorf("%s: marker seed expiry got: %v, want: %v", tt.desc, m.SeedExpiry, tt.expiry)
+		}
+	}
+}
+
+func TestMarkDevice(t *testing.T) {
+	defer func() { selectMarkerPart = selectPartitionQuietly }()
+	tests := []struct {
+		desc       string
+		batch      string
+		device     *fakeDevice
+		part       *fakePartition
+		selErr     error
+		wantMarker bool
+	}{
+		{
+			desc:   "no batch",
+			device: &fakeDevice{id: "sdc"},
+			part:   &fakePartition{},
+		},
+		{
+			desc:       "success",
+			batch:      "NYC-onboarding-June",
+			device:     &fakeDevice{id: "sdc"},
+			part:       &fakePartition{},
+			wantMarker: true,
+		},
+		{
+			desc:   "detection failure is skipped",
+			batch:  "NYC-onboarding-June",
+			device: &fakeDevice{id: "sdc", detectErr: errors.New("error")},
+			part:   &fakePartition{},
+		},
+		{
+			desc:   "no mountable partition is skipped",
+			batch:  "NYC-onboarding-June",
+			device: &fakeDevice{id: "sdc"},
+			selErr: errors.New("no FAT32 partition"),
+		},
+		{
+			desc:   "mount failure is skipped",
+			batch:  "NYC-onboarding-June",
+			device: &fakeDevice{id: "sdc"},
+			part:   &fakePartition{mountErr: errors.New("error")},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		// Mounted partitions report where they were mounted.
+		if tt.part != nil && tt.part.mountErr == nil {
+			tt.part.mount = dir
+		}
+		part, selErr := tt.part, tt.selErr
+		selectMarkerPart = func(Device, storage.FileSystem) (partition, error) {
+			if selErr != nil {
+				return nil, selErr
+			}
+			return part, nil
+		}
+		i := &Installer{cache: dir, config: &fakeConfig{batch: tt.batch, seedDest: "seed"}}
+		if err := i.markDevice(tt.device); err != nil {
+			t.Errorf("%s: markDevice() returned %v", tt.desc, err)
+		}
+		_, err := os.Stat(filepath.Join(dir, "seed", batchDestFile))
+		if got := err == nil; got != tt.wantMarker {
+			t.Errorf("%s: markDevice() wrote a marker: %t, want: %t", tt.desc, got, tt.wantMarker)
+		}
 	}
+}
 
+func TestReadMarker(t *testing.T) {
+	defer func() { selectMarkerPart = selectPartitionQuietly }()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "seed"), 0755); err != nil {
+		t.Fatalf("os.MkdirAll() returned %v", err)
+	}
+	expiry := time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC)
+	writeJSON(t, filepath.Join(dir, "seed", batchDestFile), models.BatchMarker{Batch: "lab", SeedExpiry: expiry})
 	tests := []struct {
 		desc   string
-		client *fakeHTTPDoer
-		hash   string
-		config *fakeConfig
-		out    *models.SeedResponse
+		device *fakeDevice
+		parts  map[storage.FileSystem]*fakePartition
+		policy string
 		want   error
 	}{
 		{
-			desc:   "build request error",
-			hash:   "123",
-			config: &fakeConfig{seedServer: `:`},
-			want:   errConnect,
+			desc:   "detection error",
+			device: &fakeDevice{detectErr: errors.New("error")},
+			want:   errDevice,
 		},
 		{
-			desc:   "post error",
-			client: &fakeHTTPDoer{err: errors.New("error")},
-			hash:   "123",
-			config: &fakeConfig{},
-			want:   errPost,
+			desc:   "no partition",
+			device: &fakeDevice{},
+			want:   errPartition,
 		},
 		{
-			desc:   "not in allowlist",
-			client: &fakeHTTPDoer{body: []byte("not in allowlist")},
-			hash:   "123",
-			config: &fakeConfig{},
-			want:   errResponse,
+			desc:   "not mounted",
+			device: &fakeDevice{},
+			parts:  map[storage.FileSystem]*fakePartition{storage.FAT32: &fakePartition{}},
+			want:   errMount,
 		},
 		{
-			desc:   "unmarshal error",
-			client: &fakeHTTPDoer{body: []byte(`{"field":what?}`)},
-			hash:   "123",
-			config: &fakeConfig{},
-			want:   errFormat,
+			desc:   "no marker",
+			device: &fakeDevice{},
+			parts:  map[storage.FileSystem]*fakePartition{storage.FAT32: &fakePartition{mount: t.TempDir()}},
+			want:   errFile,
 		},
 		{
-			desc:   "status not successful",
-			client: &fakeHTTPDoer{body: bad},
-			hash:   "123",
-			config: &fakeConfig{},
-			want:   errSeed,
+			desc:   "success",
+			device: &fakeDevice{},
+			parts:  map[storage.FileSystem]*fakePartition{storage.FAT32: &fakePartition{mount: dir}},
 		},
 		{
-			desc:   "success",
-			client: &fakeHTTPDoer{body: good},
-			hash:   "123",
-			config: &fakeConfig{},
-			out:    &models.SeedResponse{ErrorCode: models.StatusSuccess},
-			want:   nil,
+			desc:   "policy file system",
+			device: &fakeDevice{},
+			parts:  map[storage.FileSystem]*fakePartition{storage.NTFS: &fakePartition{mount: dir}},
+			policy: string(storage.NTFS),
 		},
 	}
 	for _, tt := range tests {
-		out, got := seedRequest(tt.client, tt.hash, tt.config)
-		if !errors.Is(got, tt.want) {
-			t.Errorf("%s: Finalize() got: %v, want: %v", tt.desc, got, tt.want)
+		parts := tt.parts
+		selectMarkerPart = func(_ Device, fs storage.FileSystem) (partition, error) {
+			if p, ok := parts[fs]; ok {
+				return p, nil
+			}
+			return nil, errPartition
+		}
+		c := &fakeConfig{seedDest: "seed", devices: config.DevicePolicy{FileSystem: tt.policy}}
+		got, err := ReadMarker(tt.device, c)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: ReadMarker() err: %v, want: %v", tt.desc, err, tt.want)
 		}
-		if diff := cmp.Diff(tt.out, out); diff != "" {
-			t.Errorf("%s: seedRequest output mismatch (-want +got):\n%s", tt.desc, diff)
+		if err == nil && (got.Batch != "lab" || !got.SeedExpiry.Equal(expiry)) {
+			t.Errorf("%s: ReadMarker() got: %+v, want batch %q expiring %v", tt.desc, got, "lab", expiry)
+		}
+	}
+}
+
+func TestSkipSeed(t *testing.T) {
+	tempDir := t.TempDir()
+	tests := []struct {
+		desc string
+		part *fakePartition
+		want error
+	}{
+		{
+			desc: "not mounted",
+			part: &fakePartition{label: "Test"},
+			want: errInput,
+		},
+		{
+			desc: "success",
+			part: &fakePartition{label: "Test", mount: tempDir},
+		},
+	}
+	for _, tt := range tests {
+		i := &Installer{config: &fakeConfig{seedServer: "https://seed.example.com", noSeed: true, seedDest: "seed", distro: "windows", track: "lab"}}
+		if got := i.skipSeed(tt.part); !errors.Is(got, tt.want) {
+			t.Errorf("%s: skipSeed() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if tt.want != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "seed", seedDestFile)); err == nil {
+			t.Errorf("%s: skipSeed() wrote a seed", tt.desc)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, "seed", batchDestFile))
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile() returned %v", tt.desc, err)
+		}
+		m := models.BatchMarker{}
+		if err := json.Unmarshal(content, &m); err != nil {
+			t.Fatalf("%s: json.Unmarshal() returned %v", tt.desc, err)
+		}
+		if !m.SeedSkipped || m.Track != "lab" {
+			t.Errorf("%s: marker got: %+v, want seed skipped for track lab", tt.desc, m)
+		}
+	}
+}
+
+func TestImageHash(t *testing.T) {
+	cache := filepath.Join("cache", "dir")
+	tests := []struct {
+		desc      string
+		installer *Installer
+		want      string
+	}{
+		{
+			desc:      "no config",
+			installer: &Installer{},
+		},
+		{
+			desc:      "not retrieved",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "installer.iso"}},
+		},
+		{
+			desc: "retrieved",
+			installer: &Installer{
+				cache:  cache,
+				config: &fakeConfig{imageFile: "installer.iso"},
+				hashes: map[string][]byte{filepath.Join(cache, "installer.iso"): {0xab, 0xcd}},
+			},
+			want: "abcd",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.installer.ImageHash(); got != tt.want {
+			t.Errorf("%s: ImageHash() got: %q, want: %q", tt.desc, got, tt.want)
 		}
 	}
 }
 
 func TestFinalize(t *testing.T) {
+	sleep = func(time.Duration) {}
+	findHolders = func(Device) ([]holder, error) { return nil, errUnsupported }
+	defer func() {
+		sleep = time.Sleep
+		findHolders = holders
+	}()
 	tests := []struct {
 		desc      string
 		installer *Installer
@@ -1241,7 +2679,7 @@ func TestFinalize(t *testing.T) {
 		},
 		{
 			desc:      "cache removal error",
-			installer: &Installer{cache: `.`, config: &fakeConfig{}},
+			installer: &Installer{cache: `.`, config: &fakeConfig{cleanup: true}},
 			device:    &fakeDevice{},
 			want:      errPath,
 		},
@@ -1254,10 +2692,16 @@ func TestFinalize(t *testing.T) {
 		},
 		{
 			desc:      "success",
-			installer: &Installer{config: &fakeConfig{}},
+			installer: &Installer{config: &fakeConfig{cleanup: true}},
 			device:    &fakeDevice{},
 			want:      nil,
 		},
+		{
+			desc:      "retained cache error",
+			installer: &Installer{cache: filepath.Join("does", "not", "exist"), config: &fakeConfig{}},
+			device:    &fakeDevice{},
+			want:      errPath,
+		},
 	}
 	for _, tt := range tests {
 		got := tt.installer.Finalize([]Device{tt.device}, tt.dismount)
@@ -1266,3 +2710,75 @@ func TestFinalize(t *testing.T) {
 		}
 	}
 }
+
+func TestFinalizeContinues(t *testing.T) {
+	sleep = func(time.Duration) {}
+	findHolders = func(Device) ([]holder, error) { return nil, errUnsupported }
+	defer func() {
+		sleep = time.Sleep
+		findHolders = holders
+	}()
+	failed := &fakeDevice{id: "sdb", dmErr: errors.New("error")}
+	stuck := &fakeDevice{id: "sdc", ejectErr: errors.New("error")}
+	ok := &fakeDevice{id: "sdd"}
+	i := &Installer{config: &fakeConfig{cleanup: true, eject: true}}
+	err := i.Finalize([]Device{failed, stuck, ok}, true)
+	if !errors.Is(err, errDevice) {
+		t.Errorf("Finalize() got: %v, want: %v", err, errDevice)
+	}
+	for _, want := range []string{"sdb", "sdc"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Finalize() got: %v, want it to contain %q", err, want)
+		}
+	}
+	if !stuck.ejected || !ok.ejected {
+		t.Errorf("Finalize() ejected %q: %t and %q: %t, want both ejected", stuck.id, stuck.ejected, ok.id, ok.ejected)
+	}
+}
+
+func TestFinalizeRetainsCache(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cleanup bool
+		reuse   bool
+		want    bool
+	}{
+		{desc: "cleanup", cleanup: true},
+		{desc: "cleanup disabled", want: true},
+		{desc: "cache directory", cleanup: true, reuse: true, want: true},
+	}
+	for _, tt := range tests {
+		cache := filepath.Join(t.TempDir(), "cache")
+		if err := os.Mkdir(cache, 0755); err != nil {
+			t.Fatalf("os.Mkdir(%q) returned %v", cache, err)
+		}
+		image := filepath.Join(cache, "installer.iso")
+		if err := ioutil.WriteFile(image, []byte("image"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", image, err)
+		}
+		i := &Installer{
+			cache:  cache,
+			config: &fakeConfig{cleanup: tt.cleanup, distro: "windows", track: "stable"},
+			hashes: map[string][]byte{image: {0xab, 0xcd}},
+			reuse:  tt.reuse,
+		}
+		if err := i.Finalize(nil, false); err != nil {
+			t.Errorf("%s: Finalize() returned %v", tt.desc, err)
+		}
+		readme, err := ioutil.ReadFile(filepath.Join(cache, cacheReadmeFile))
+		if !tt.want {
+			if _, err := os.Stat(cache); !os.IsNotExist(err) {
+				t.Errorf("%s: Finalize() left %q in place: %v", tt.desc, cache, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile() returned %v", tt.desc, err)
+		}
+		for _, want := range []string{"installer.iso", "sha256:abcd", "--distro=windows --track=stable", "--cache_dir="} {
+			if !strings.Contains(string(readme), want) {
+				t.Errorf("%s: README got:\n%s\nwant it to contain %q", tt.desc, readme, want)
+			}
+		}
+	}
+}
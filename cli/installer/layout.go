@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/debug"
+)
+
+const (
+	// splitBootMB is the size of the FAT32 boot partition of a device that is
+	// split into a boot and a data partition. It holds the files needed to
+	// boot, while the data partition fills the remainder of the device.
+	splitBootMB = 2048
+	// splitDataLabel is the label of the data partition.
+	splitDataLabel = "DATA"
+)
+
+// splitLayout reports whether devices are partitioned with a data partition
+// alongside the boot partition, which is the case when partition rules place
+// files from ISO images on it, such as WIM files that are too large for
+// FAT32.
+func (i *Installer) splitLayout() bool {
+	for _, r := range i.config.PartitionRules() {
+		if r.Role == config.DataPartition {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareSplit partitions a wiped device with a small FAT32 boot partition
+// and a data partition that fills the remainder of the device, formatting
+// both, and then detects the new partitions so that they can be selected.
+func (i *Installer) prepareSplit(d Device) error {
+	fs := i.config.DataFileSystem()
+	deck.InfofA("Partitioning %q with a %dMB boot partition and a %s data partition.", d.FriendlyName(), splitBootMB, fs).With(debug.V(debug.Storage, 2)).Go()
+	if err := partitionForSplit(d.Identifier(), i.config.DistroLabel(), fs); err != nil {
+		return fmt.Errorf("partitionForSplit(%q, %q) returned %v: %w", d.Identifier(), fs, err, errPartition)
+	}
+	if err := d.DetectPartitions(false); err != nil {
+		return fmt.Errorf("DetectPartitions() for %q returned %v: %w", d.FriendlyName(), err, errPartition)
+	}
+	return nil
+}
+
+// run executes a command, including its output in the returned error on
+// failure.
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %v returned %v: %s", name, args, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "fmt"
+
+// partitionSplit lays out the disk with the provided identifier, such as
+// 'disk2', as a GPT disk with a FAT32 boot partition labeled label and a data
+// partition formatted with fs, using diskutil. diskutil cannot create NTFS
+// partitions, so only exFAT data partitions are supported.
+func partitionSplit(id, label, fs string) error {
+	if fs != "exFAT" {
+		return fmt.Errorf("creating a %s data partition: %w", fs, errUnsupported)
+	}
+	return run("diskutil", "partitionDisk", id, "2", "GPT",
+		"FAT32", label, fmt.Sprintf("%dM", splitBootMB),
+		"ExFAT", splitDataLabel, "R")
+}
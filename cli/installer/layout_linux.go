@@ -0,0 +1,52 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// partitionSplit lays out the block device with the provided identifier, such
+// as 'sdb', as a GPT disk with a FAT32 boot partition labeled label and a data
+// partition formatted with fs, using parted and mkfs.
+func partitionSplit(id, label, fs string) error {
+	dev := devicePath(id)
+	if err := run("parted", "--script", dev,
+		"mklabel", "gpt",
+		"mkpart", "boot", "fat32", "1MiB", fmt.Sprintf("%dMiB", splitBootMB+1),
+		"mkpart", "data", fmt.Sprintf("%dMiB", splitBootMB+1), "100%"); err != nil {
+		return err
+	}
+	if err := run("mkfs.vfat", "-F", "32", "-n", label, partitionPath(id, 1)); err != nil {
+		return err
+	}
+	switch fs {
+	case "exFAT":
+		return run("mkfs.exfat", "-n", splitDataLabel, partitionPath(id, 2))
+	default:
+		return run("mkfs.ntfs", "--quick", "-L", splitDataLabel, partitionPath(id, 2))
+	}
+}
+
+// partitionPath returns the path of partition n of the block device with the
+// provided identifier. Devices whose names end in a digit, such as 'nvme0n1'
+// or 'mmcblk0', separate the partition number with a 'p'.
+func partitionPath(id string, n int) string {
+	if r := []rune(id); len(r) > 0 && unicode.IsDigit(r[len(r)-1]) {
+		return fmt.Sprintf("%sp%d", devicePath(id), n)
+	}
+	return fmt.Sprintf("%s%d", devicePath(id), n)
+}
@@ -0,0 +1,35 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "testing"
+
+func TestPartitionPath(t *testing.T) {
+	tests := []struct {
+		id   string
+		n    int
+		want string
+	}{
+		{"sdb", 1, "/dev/sdb1"},
+		{"sdb", 2, "/dev/sdb2"},
+		{"nvme0n1", 2, "/dev/nvme0n1p2"},
+		{"mmcblk0", 1, "/dev/mmcblk0p1"},
+	}
+	for _, tt := range tests {
+		if got := partitionPath(tt.id, tt.n); got != tt.want {
+			t.Errorf("partitionPath(%q, %d) got: %q, want: %q", tt.id, tt.n, got, tt.want)
+		}
+	}
+}
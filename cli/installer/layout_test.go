@@ -0,0 +1,98 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+)
+
+func TestSplitLayout(t *testing.T) {
+	tests := []struct {
+		desc  string
+		rules []config.PartitionRule
+		want  bool
+	}{
+		{
+			desc: "no rules",
+			want: false,
+		},
+		{
+			desc:  "boot rules only",
+			rules: []config.PartitionRule{{Glob: "efi", Role: config.BootPartition}},
+			want:  false,
+		},
+		{
+			desc:  "data rule",
+			rules: []config.PartitionRule{{Glob: "sources/boot.wim", Role: config.BootPartition}, {Glob: "sources/*.wim", Role: config.DataPartition}},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		i := &Installer{config: &fakeConfig{partitions: tt.rules}}
+		if got := i.splitLayout(); got != tt.want {
+			t.Errorf("%s: splitLayout() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareSplit(t *testing.T) {
+	rules := []config.PartitionRule{{Glob: "sources/install.wim", Role: config.DataPartition}}
+	tests := []struct {
+		desc      string
+		config    *fakeConfig
+		device    *fakeDevice
+		partition func(string, string, string) error
+		want      error
+	}{
+		{
+			desc:      "partition error",
+			config:    &fakeConfig{elevated: true, partitions: rules},
+			device:    &fakeDevice{},
+			partition: func(string, string, string) error { return errors.New("error") },
+			want:      errPartition,
+		},
+		{
+			desc:      "detect error",
+			config:    &fakeConfig{elevated: true, partitions: rules},
+			device:    &fakeDevice{detectErr: errors.New("error")},
+			partition: func(string, string, string) error { return nil },
+			want:      errPartition,
+		},
+		{
+			// The device is not partitioned by Partition, which would fail.
+			desc:   "success",
+			config: &fakeConfig{elevated: true, partitions: rules, distroLabel: "INSTALLER", dataFileSystem: "exFAT"},
+			device: &fakeDevice{id: "2", partErr: errors.New("error")},
+			partition: func(id, label, fs string) error {
+				if id != "2" || label != "INSTALLER" || fs != "exFAT" {
+					return errors.New("unexpected layout")
+				}
+				return nil
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		partitionForSplit = tt.partition
+		i := &Installer{config: tt.config}
+		if got := i.prepareForISOWithElevation(tt.device, uint64(1024)); !errors.Is(got, tt.want) {
+			t.Errorf("%s: prepareForISOWithElevation() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+	partitionForSplit = partitionSplit
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// splitScript lays out a disk as a GPT disk with a FAT32 boot partition and
+// a data partition that fills the remainder of the disk. Both partitions are
+// assigned a free drive letter.
+const splitScript = `select disk %s
+clean
+convert gpt
+create partition primary size=%d
+format quick fs=fat32 label="%s"
+assign
+create partition primary
+format quick fs=%s label="%s"
+assign
+exit
+`
+
+// partitionSplit lays out the disk with the provided number with a FAT32 boot
+// partition labeled label and a data partition formatted with fs.
+func partitionSplit(id, label, fs string) error {
+	return diskpart(fmt.Sprintf(splitScript, id, splitBootMB, label, strings.ToLower(fs), splitDataLabel))
+}
+
+// diskpart runs a diskpart script.
+func diskpart(script string) error {
+	f, err := ioutil.TempFile("", "diskpart_*.txt")
+	if err != nil {
+		return fmt.Errorf("ioutil.TempFile() returned %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		return fmt.Errorf("writing diskpart script: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Close() for %q returned %v", f.Name(), err)
+	}
+	return run("diskpart.exe", "/s", f.Name())
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+const (
+	// locateBlock is the size of each read made to blink a device. It is a
+	// multiple of the sector size, as required for reads from raw devices on
+	// Windows.
+	locateBlock = 4096
+)
+
+var (
+	// Dependency injections for testing.
+	openForRead = openRawDeviceRead
+
+	// blinkInterval is how long the activity LED of a device is kept on, and
+	// then off, while it is located.
+	blinkInterval = 250 * time.Millisecond
+)
+
+// readerAtCloser is a device that can be read from at any offset.
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// openRawDeviceRead opens the block device with the provided identifier for
+// reading.
+func openRawDeviceRead(id string) (readerAtCloser, error) {
+	return os.Open(devicePath(id))
+}
+
+// Locate blinks the activity LED of d so that it can be physically
+// identified, such as among several identical devices. Small blocks are read
+// from random offsets in bursts, so that the reads are not satisfied from the
+// cache of the operating system. Nothing is written to d. Locate returns when
+// ctx is done, or after d has blinked for duration if it is not zero.
+func Locate(ctx context.Context, d Device, duration time.Duration) error {
+	if d.Size() < locateBlock {
+		return fmt.Errorf("%q is too small to locate: %w", d.FriendlyName(), errDevice)
+	}
+	f, err := openForRead(d.Identifier())
+	if err != nil {
+		return fmt.Errorf("opening %q for reading returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	defer f.Close()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	deck.InfofA("Blinking %q, duration: %v (0 is until cancelled).", d.FriendlyName(), duration).With(debug.V(debug.Storage, 2)).Go()
+
+	blocks := int64(d.Size() / locateBlock)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	buf := make([]byte, locateBlock)
+	for {
+		// Keep the LED on with back to back reads.
+		for end := time.Now().Add(blinkInterval); time.Now().Before(end); {
+			if ctx.Err() != nil {
+				return nil
+			}
+			off := r.Int63n(blocks) * locateBlock
+			if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+				return fmt.Errorf("reading %q at %d returned %v: %w", d.Identifier(), off, err, errIO)
+			}
+		}
+		// Then leave it off.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(blinkInterval):
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeReaderAt records the offsets that are read.
+type fakeReaderAt struct {
+	err     error
+	offsets []int64
+	closed  bool
+}
+
+func (f *fakeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	f.offsets = append(f.offsets, off)
+	return len(p), f.err
+}
+
+func (f *fakeReaderAt) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestLocate(t *testing.T) {
+	defer func(i time.Duration) {
+		openForRead = openRawDeviceRead
+		blinkInterval = i
+	}(blinkInterval)
+	blinkInterval = time.Millisecond
+	size := uint64(64 * locateBlock)
+
+	tests := []struct {
+		desc      string
+		device    *fakeDevice
+		reader    *fakeReaderAt
+		openErr   error
+		wantReads bool
+		want      error
+	}{
+		{
+			desc:   "too small",
+			device: &fakeDevice{id: "1", size: 512},
+			want:   errDevice,
+		},
+		{
+			desc:    "open error",
+			device:  &fakeDevice{id: "1", size: size},
+			openErr: errors.New("error"),
+			want:    errDevice,
+		},
+		{
+			desc:      "read error",
+			device:    &fakeDevice{id: "1", size: size},
+			reader:    &fakeReaderAt{err: errors.New("error")},
+			wantReads: true,
+			want:      errIO,
+		},
+		{
+			desc:      "success",
+			device:    &fakeDevice{id: "1", size: size},
+			reader:    &fakeReaderAt{},
+			wantReads: true,
+		},
+	}
+	for _, tt := range tests {
+		reader, openErr := tt.reader, tt.openErr
+		openForRead = func(string) (readerAtCloser, error) { return reader, openErr }
+		got := Locate(context.Background(), tt.device, 20*time.Millisecond)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Locate() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if reader == nil {
+			continue
+		}
+		if !reader.closed {
+			t.Errorf("%s: Locate() did not close the device", tt.desc)
+		}
+		if (len(reader.offsets) > 0) != tt.wantReads {
+			t.Errorf("%s: Locate() made %d reads, want reads: %t", tt.desc, len(reader.offsets), tt.wantReads)
+		}
+		for _, off := range reader.offsets {
+			if off%locateBlock != 0 || off < 0 || uint64(off)+locateBlock > size {
+				t.Errorf("%s: Locate() read at %d, want aligned offsets within %d bytes", tt.desc, off, size)
+			}
+		}
+	}
+}
+
+func TestLocateCancel(t *testing.T) {
+	defer func() { openForRead = openRawDeviceRead }()
+	openForRead = func(string) (readerAtCloser, error) { return &fakeReaderAt{}, nil }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- Locate(ctx, &fakeDevice{id: "1", size: locateBlock}, 0) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Locate() returned %v after being cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Locate() did not return after being cancelled")
+	}
+}
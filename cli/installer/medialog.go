@@ -0,0 +1,113 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/debug"
+)
+
+// logDestDir is the hidden directory on the boot partition that copies of
+// the log and report of each run are stored in.
+const logDestDir = `.fresnel`
+
+// redacted replaces values that are removed from logs stored on media.
+const redacted = `REDACTED`
+
+// redactions are the patterns of values that are removed from logs stored on
+// media, which travel beyond the control of the operator, and their
+// replacements. They are applied in order, and email addresses are not
+// matched where they follow the scheme of a URL, so that the host of a URL
+// whose credentials were removed is kept.
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Credentials in URLs, such as those of proxies.
+	{regexp.MustCompile(`://[^/\s@"']+@`), "://" + redacted + "@"},
+	// Query strings, which carry the signatures of signed URLs.
+	{regexp.MustCompile(`(://[^\s?"']+)\?[^\s"']*`), "${1}?" + redacted},
+	// Bearer tokens.
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + redacted},
+	// Email addresses, such as those of the users that seeds are issued to.
+	{regexp.MustCompile(`(^|[^A-Za-z0-9._%+/:-])[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+`), "${1}" + redacted},
+}
+
+// redact returns b with credentials, signatures and email addresses replaced.
+func redact(b []byte) []byte {
+	for _, r := range redactions {
+		b = r.re.ReplaceAll(b, []byte(r.repl))
+	}
+	return b
+}
+
+// StoreLog writes redacted copies of log and report, which describe the run
+// that provisioned d, to a hidden directory on the boot partition of d. It
+// must follow Provision, while the partitions written remain mounted. The
+// copies of each run are kept in files named for when they were stored,
+// which are never overwritten and are read-only, so that the history of the
+// media is retained when it is updated.
+func (i *Installer) StoreLog(d Device, log, report []byte) error {
+	if i.config == nil {
+		return errConfig
+	}
+	p, ok := i.written[config.BootPartition]
+	if !ok || p.MountPoint() == "" {
+		return fmt.Errorf("no mounted partition was provisioned on %q: %w", d.Identifier(), errInput)
+	}
+	dir := filepath.Join(host.root(p.MountPoint()), logDestDir)
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dir, err, errPerm)
+	}
+	if err := hideFile(dir); err != nil {
+		deck.Warningf("Hiding %q failed: %v", dir, err)
+	}
+	name := "provision-" + time.Now().UTC().Format("20060102T150405Z")
+	if err := writeOnce(filepath.Join(dir, name+".log"), redact(log)); err != nil {
+		return err
+	}
+	if err := writeOnce(filepath.Join(dir, name+".json"), redact(report)); err != nil {
+		return err
+	}
+	deck.InfofA("Stored the log and report of this run in %q on %q.", dir, d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	return nil
+}
+
+// writeOnce writes content to a new read-only file at path, failing rather
+// than overwriting an existing file.
+func writeOnce(path string, content []byte) (err error) {
+	// Permissions = owner:read, group:read"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%q) returned %v: %w", path, err, errIO)
+	}
+	defer func() {
+		if err2 := f.Close(); err2 != nil && err == nil {
+			err = fmt.Errorf("Close() for %q returned %v: %w", path, err2, errIO)
+		}
+	}()
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("writing %q returned %v: %w", path, err, errIO)
+	}
+	return nil
+}
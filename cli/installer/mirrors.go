@@ -0,0 +1,129 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/version"
+)
+
+// probeTimeout limits the time that each image server is given to respond to
+// a HEAD request when mirrors are probed.
+const probeTimeout = 5 * time.Second
+
+var (
+	// Dependency injections for testing.
+	probeServer = headLatency
+)
+
+// imageSources returns the paths that the image is obtained from, in the
+// order they are tried: the image server followed by each of its mirrors.
+// When probing is configured, they are ordered by the time each takes to
+// confirm that it has the image, such as by responding to a HEAD request,
+// and those that do not respond are tried last in their configured order.
+func (i *Installer) imageSources() []string {
+	sources := append([]string{i.config.ImagePath()}, i.config.ImageMirrors()...)
+	if len(sources) == 1 || !i.config.ProbeMirrors() {
+		return sources
+	}
+	latency := make(map[string]time.Duration)
+	for _, s := range sources {
+		r, err := retrieverFor(s, i.config.Proxy())
+		if err != nil {
+			deck.Warningf("Image server %q was not probed, it will be tried last: %v", imagefile.Redact(s), err)
+			continue
+		}
+		d, err := r.Probe(s)
+		if err != nil {
+			deck.InfofA("Probing %q returned %v, it will be tried last.", imagefile.Redact(s), err).With(debug.V(debug.Network, 2)).Go()
+			continue
+		}
+		deck.InfofA("Image server %q responded in %v.", imagefile.Redact(s), d).With(debug.V(debug.Network, 2)).Go()
+		latency[s] = d
+	}
+	sort.SliceStable(sources, func(a, b int) bool {
+		da, oka := latency[sources[a]]
+		db, okb := latency[sources[b]]
+		if oka != okb {
+			return oka
+		}
+		return oka && da < db
+	})
+	deck.InfofA("Image servers will be tried in the order %v.", sources).With(debug.V(debug.Network, 1)).Go()
+	return sources
+}
+
+// imagePaths returns the paths that the image is obtained from, which are
+// only the image path until Retrieve orders the image server and its mirrors.
+func (i *Installer) imagePaths() []string {
+	if len(i.sources) == 0 {
+		return []string{i.config.ImagePath()}
+	}
+	return i.sources
+}
+
+// headLatency returns the time taken for a HEAD request for path to succeed.
+func headLatency(client httpDoer, path string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	shown := imagefile.Redact(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, path, nil)
+	if err != nil {
+		return 0, fmt.Errorf(`http.NewRequest("HEAD", %q) returned %v`, shown, redactURLError(err))
+	}
+	version.SetHeaders(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: head for %q returned %v", errDownload, shown, redactURLError(err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w for %q with response %d", errStatus, shown, resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// retrieveFromSources downloads fileName from each of sources in turn until
+// one succeeds, so that an outage of a single server does not prevent
+// provisioning. The error of each source is returned when all of them fail.
+func (i *Installer) retrieveFromSources(fileName string, sources []string) error {
+	var errs []error
+	for n, s := range sources {
+		err := i.retrieveFile(fileName, s)
+		if err == nil {
+			if n > 0 {
+				deck.InfofA("Obtained %q from %q.", fileName, imagefile.Redact(s)).With(deck.V(1)).Go()
+			}
+			return nil
+		}
+		if n < len(sources)-1 {
+			deck.Warningf("Obtaining %q from %q failed, trying the next image server: %v", fileName, imagefile.Redact(s), err)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("%w: every image server failed: %v", errs[len(errs)-1], errs)
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestImageSources(t *testing.T) {
+	const (
+		primary = "https://images.example.com/a.iso"
+		mirror1 = "https://mirror1.example.com/a.iso"
+		mirror2 = "https://mirror2.example.com/a.iso"
+	)
+	tests := []struct {
+		desc    string
+		config  *fakeConfig
+		latency map[string]time.Duration // Servers that are not listed do not respond.
+		want    []string
+	}{
+		{
+			desc:   "no mirrors",
+			config: &fakeConfig{imagePath: primary, probe: true},
+			want:   []string{primary},
+		},
+		{
+			desc:   "configured order",
+			config: &fakeConfig{imagePath: primary, mirrors: []string{mirror1, mirror2}},
+			want:   []string{primary, mirror1, mirror2},
+		},
+		{
+			desc:    "fastest first",
+			config:  &fakeConfig{imagePath: primary, mirrors: []string{mirror1, mirror2}, probe: true},
+			latency: map[string]time.Duration{primary: 3 * time.Second, mirror1: 2 * time.Second, mirror2: time.Second},
+			want:    []string{mirror2, mirror1, primary},
+		},
+		{
+			desc:    "unresponsive last",
+			config:  &fakeConfig{imagePath: primary, mirrors: []string{mirror1, mirror2}, probe: true},
+			latency: map[string]time.Duration{mirror2: time.Second},
+			want:    []string{mirror2, primary, mirror1},
+		},
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	defer func() { probeServer = headLatency }()
+	for _, tt := range tests {
+		latency := tt.latency
+		probeServer = func(_ httpDoer, path string) (time.Duration, error) {
+			d, ok := latency[path]
+			if !ok {
+				return 0, errors.New("timeout")
+			}
+			return d, nil
+		}
+		i := &Installer{config: tt.config}
+		if got := i.imageSources(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: imageSources() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRetrieveFromSources(t *testing.T) {
+	fakeCache, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "test") returned %v`, err)
+	}
+	defer os.RemoveAll(fakeCache)
+
+	sources := []string{"https://images.example.com/a.iso", "https://mirror.example.com/a.iso"}
+	tests := []struct {
+		desc      string
+		failing   map[string]bool
+		want      error
+		wantTried []string
+	}{
+		{
+			desc:      "first succeeds",
+			wantTried: sources[:1],
+		},
+		{
+			desc:      "fallback",
+			failing:   map[string]bool{sources[0]: true},
+			wantTried: sources,
+		},
+		{
+			desc:      "all fail",
+			failing:   map[string]bool{sources[0]: true, sources[1]: true},
+			want:      errDownload,
+			wantTried: sources,
+		},
+	}
+	connectWithCert = func(string) (httpDoer, error) { return &fakeHTTPDoer{}, nil }
+	for _, tt := range tests {
+		var tried []string
+		failing := tt.failing
+		downloadFile = func(_ httpDoer, path string, w io.Writer) error {
+			tried = append(tried, path)
+			if failing[path] {
+				return fmt.Errorf("%w: outage", errDownload)
+			}
+			_, err := io.WriteString(w, "image")
+			return err
+		}
+		i := &Installer{config: &fakeConfig{}, cache: fakeCache}
+		got := i.retrieveFromSources("a.iso", sources)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: retrieveFromSources() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if !reflect.DeepEqual(tried, tt.wantTried) {
+			t.Errorf("%s: retrieveFromSources() tried %v, want: %v", tt.desc, tried, tt.wantTried)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+)
+
+const (
+	// bootScriptFile is the name of the iPXE script written by Stage.
+	bootScriptFile = "boot.ipxe"
+)
+
+// wimbootFiles are the files, relative to the root of a Windows ISO, that
+// are loaded by wimboot, and the names they are provided to it as. Entries
+// that are not present in the ISO are skipped, except for boot.wim.
+var wimbootFiles = [][2]string{
+	{"bootmgr", "bootmgr"},
+	{"bootmgr.efi", "bootmgr.efi"},
+	{"boot/bcd", "BCD"},
+	{"boot/boot.sdi", "boot.sdi"},
+	{"sources/boot.wim", "boot.wim"},
+}
+
+// bootScript is the iPXE script written alongside the contents of the ISO.
+// Paths are relative to the script, so the directory can be served from any
+// location. Files injected with wimboot, such as the seed, are available in
+// X:\Windows\System32 once WinPE has started.
+var bootScript = template.Must(template.New(bootScriptFile).Parse(`#!ipxe
+# Generated by fresnel for {{.Distro}} ({{.Track}}) at {{.Created.Format "2006-01-02T15:04:05Z07:00"}}.
+# wimboot is not included, obtain it from https://ipxe.org/wimboot and place
+# it alongside this script.
+kernel wimboot
+{{range .Files}}initrd {{index . 0}} {{index . 1}}
+{{end}}boot
+`))
+
+// bootScriptData is provided to bootScript.
+type bootScriptData struct {
+	Distro  string
+	Track   string
+	Created time.Time
+	Files   [][2]string
+}
+
+// dirPartition presents a directory as a partition that has already been
+// mounted, so that the contents of a device can be staged to it instead.
+type dirPartition string
+
+func (d dirPartition) Contents() ([]string, error) {
+	entries, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var contents []string
+	for _, e := range entries {
+		contents = append(contents, e.Name())
+	}
+	return contents, nil
+}
+
+func (d dirPartition) Erase() error { return fmt.Errorf("erase %q: %w", string(d), errUnsupported) }
+func (d dirPartition) Format(string) error {
+	return fmt.Errorf("format %q: %w", string(d), errUnsupported)
+}
+func (d dirPartition) Identifier() string { return string(d) }
+func (d dirPartition) Label() string      { return filepath.Base(string(d)) }
+func (d dirPartition) Mount(string) error { return nil }
+func (d dirPartition) MountPoint() string { return string(d) }
+
+// Stage prepares dir to be served for network boot (PXE or UEFI HTTP boot)
+// rather than provisioning a device. The contents of the ISO are extracted to
+// dir, along with the seed and FFU configuration when the distribution
+// requires them, and an iPXE script that boots them using wimboot. Retrieve
+// must be called first. dir must be empty or not yet exist.
+func (i *Installer) Stage(dir string) (err error) {
+	if i.config == nil {
+		return errConfig
+	}
+	if dir == "" {
+		return fmt.Errorf("missing staging directory: %w", errInput)
+	}
+	if ext := imagefile.Ext(i.config.ImageFile()); ext != ".iso" {
+		return fmt.Errorf("%q is not an ISO, only ISO images can be staged for network boot: %w", i.config.ImageFile(), errUnsupported)
+	}
+	// Seeds are written relative to the root, which must include the drive
+	// on Windows.
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("filepath.Abs(%q) returned %v: %w", dir, err, errPath)
+	}
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("os.Stat(%q) returned %v: %w", path, err, errPath)
+	}
+	if err := i.verifyImage(path); err != nil {
+		return err
+	}
+	// Permissions = owner:read/write/execute, group:read/execute"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll(%q, 0755) returned %v: %w", dir, err, errPerm)
+	}
+	part := dirPartition(dir)
+	contents, err := part.Contents()
+	if err != nil {
+		return fmt.Errorf("Contents(%q) returned %v: %w", dir, err, errIO)
+	}
+	if len(contents) > 0 {
+		return fmt.Errorf("staging directory %q is not empty: %w", dir, errNotEmpty)
+	}
+
+	deck.InfofA("Mounting ISO at %q.", path).With(debug.V(debug.Storage, 2)).Go()
+	handler, err := mount(path)
+	if err != nil {
+		return fmt.Errorf("mount(%q) returned %v: %w", path, err, errMount)
+	}
+	// Close the handler on return, capturing the error if there is one.
+	defer func() {
+		deck.InfofA("Dismounting ISO at %q.", handler.MountPath()).With(debug.V(debug.Storage, 2)).Go()
+		if err2 := handler.Dismount(); err2 != nil {
+			if err != nil {
+				err = fmt.Errorf("Dismount() for %q returned %v: %w", handler.MountPath(), err, err2)
+				return
+			}
+			err = err2
+		}
+	}()
+	deck.InfofA("Extracting ISO at %q to %q.", handler.ImagePath(), dir).With(debug.V(debug.Copy, 2)).Go()
+	// Network boot serves every file from dir, so partition rules do not apply.
+	opts := copyOptions{preserve: i.config.PreserveAttributes()}
+	if err := writeISOFunc(handler, map[string]partition{config.BootPartition: part}, opts); err != nil {
+		return fmt.Errorf("writeISO() returned %v: %w", err, errProvision)
+	}
+	if i.config.FFU() {
+		if err := i.writeConfig(part); err != nil {
+			return fmt.Errorf("writeConfig() returned %v", err)
+		}
+	}
+	if i.config.SeedServer() != "" {
+		if !i.config.SeedRequired() {
+			if err := i.skipSeed(part); err != nil {
+				return err
+			}
+		} else if err := i.writeSeed(handler, part); err != nil {
+			return fmt.Errorf("writeSeed() returned %v", err)
+		}
+	}
+	return i.writeBootScript(dir)
+}
+
+// writeBootScript writes an iPXE script to dir that boots the staged contents
+// of a Windows ISO with wimboot. The seed, manifest and FFU configuration are
+// injected into WinPE when they were staged.
+func (i *Installer) writeBootScript(dir string) error {
+	present, err := stagedFiles(dir)
+	if err != nil {
+		return err
+	}
+	files := append([][2]string{}, wimbootFiles...)
+	for _, f := range []string{seedDestFile, bundleDestFile, manifestDestFile, confDestFile} {
+		files = append(files, [2]string{filepath.ToSlash(filepath.Join(i.config.SeedDest(), f)), f})
+	}
+	data := bootScriptData{
+		Distro:  i.config.Distro(),
+		Track:   i.config.Track(),
+		Created: time.Now(),
+	}
+	for _, f := range files {
+		rel, ok := present[strings.ToLower(f[0])]
+		if !ok {
+			if f[1] == "boot.wim" {
+				return fmt.Errorf("%q was not found in the image: %w", f[0], errFile)
+			}
+			continue
+		}
+		data.Files = append(data.Files, [2]string{rel, f[1]})
+	}
+	s := filepath.Join(dir, bootScriptFile)
+	f, err := os.Create(s)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q) returned %v: %w", s, err, errIO)
+	}
+	defer f.Close()
+	deck.InfofA("Writing boot script: %q.", s).With(debug.V(debug.Seed, 2)).Go()
+	if err := bootScript.Execute(f, data); err != nil {
+		return fmt.Errorf("writing %q returned %v: %w", s, err, errIO)
+	}
+	return f.Close()
+}
+
+// stagedFiles returns the paths of the files in dir, relative to dir and
+// using forward slashes, keyed by their lower case equivalent. ISOs are not
+// case sensitive, but the web servers that network boot files are served
+// from often are.
+func stagedFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files[strings.ToLower(rel)] = rel
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %q returned %v: %w", dir, err, errIO)
+	}
+	return files, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+)
+
+// fakeISOContents creates the files of a Windows ISO in the partition that it
+// is written to.
+func fakeISOContents(files ...string) func(isoHandler, map[string]partition, copyOptions) error {
+	return func(_ isoHandler, parts map[string]partition, _ copyOptions) error {
+		p := parts[config.BootPartition]
+		for _, f := range files {
+			path := filepath.Join(p.MountPoint(), filepath.FromSlash(f))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestStage(t *testing.T) {
+	cache, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir('', '') returned %v", err)
+	}
+	defer os.RemoveAll(cache)
+	if err := ioutil.WriteFile(filepath.Join(cache, "fake.iso"), []byte("iso"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	notEmpty, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir('', '') returned %v", err)
+	}
+	defer os.RemoveAll(notEmpty)
+	if err := ioutil.WriteFile(filepath.Join(notEmpty, "file"), nil, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	windows := []string{"bootmgr", "Boot/BCD", "boot/boot.sdi", "sources/boot.wim", "sources/install.wim"}
+	defer func() {
+		mount = mountISO
+		writeISOFunc = writeISO
+	}()
+
+	tests := []struct {
+		desc       string
+		installer  *Installer
+		dir        string
+		mountErr   error
+		contents   []string
+		wantScript []string
+		want       error
+	}{
+		{
+			desc:      "missing config",
+			installer: &Installer{},
+			want:      errConfig,
+		},
+		{
+			desc:      "raw image",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "fake.img.gz"}},
+			want:      errUnsupported,
+		},
+		{
+			desc:      "missing image",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "missing.iso"}},
+			want:      errPath,
+		},
+		{
+			desc:      "not empty",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "fake.iso"}},
+			dir:       notEmpty,
+			want:      errNotEmpty,
+		},
+		{
+			desc:      "mount error",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "fake.iso"}},
+			mountErr:  errors.New("error"),
+			want:      errMount,
+		},
+		{
+			desc:      "missing boot.wim",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "fake.iso"}},
+			contents:  []string{"bootmgr"},
+			want:      errFile,
+		},
+		{
+			desc:      "success",
+			installer: &Installer{cache: cache, config: &fakeConfig{imageFile: "fake.iso", distro: "windows", track: "stable"}},
+			contents:  windows,
+			wantScript: []string{
+				"#!ipxe",
+				"for windows (stable)",
+				"kernel wimboot",
+				"initrd bootmgr bootmgr\n",
+				"initrd Boot/BCD BCD\n",
+				"initrd boot/boot.sdi boot.sdi\n",
+				"initrd sources/boot.wim boot.wim\n",
+				"boot\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		dir := tt.dir
+		if dir == "" {
+			if dir, err = ioutil.TempDir("", ""); err != nil {
+				t.Fatalf("ioutil.TempDir('', '') returned %v", err)
+			}
+			defer os.RemoveAll(dir)
+		}
+		mountErr := tt.mountErr
+		mount = func(string) (isoHandler, error) { return &fakeHandler{}, mountErr }
+		writeISOFunc = fakeISOContents(tt.contents...)
+		got := tt.installer.Stage(dir)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Stage() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got != nil {
+			continue
+		}
+		script, err := ioutil.ReadFile(filepath.Join(dir, bootScriptFile))
+		if err != nil {
+			t.Errorf("%s: reading boot script returned %v", tt.desc, err)
+			continue
+		}
+		for _, w := range tt.wantScript {
+			if !strings.Contains(string(script), w) {
+				t.Errorf("%s: boot script does not contain %q:\n%s", tt.desc, w, script)
+			}
+		}
+		if strings.Contains(string(script), "bootmgr.efi") {
+			t.Errorf("%s: boot script includes files missing from the image:\n%s", tt.desc, script)
+		}
+	}
+}
+
+func TestWriteBootScriptSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir('', '') returned %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := fakeISOContents("sources/boot.wim", "seed/seed.json", "seed/manifest.json")(nil, map[string]partition{config.BootPartition: dirPartition(dir)}, copyOptions{}); err != nil {
+		t.Fatalf("creating staged files returned %v", err)
+	}
+	i := &Installer{config: &fakeConfig{seedDest: "seed"}}
+	if err := i.writeBootScript(dir); err != nil {
+		t.Fatalf("writeBootScript() returned %v", err)
+	}
+	script, err := ioutil.ReadFile(filepath.Join(dir, bootScriptFile))
+	if err != nil {
+		t.Fatalf("reading boot script returned %v", err)
+	}
+	for _, w := range []string{"initrd seed/seed.json seed.json\n", "initrd seed/manifest.json manifest.json\n"} {
+		if !strings.Contains(string(script), w) {
+			t.Errorf("writeBootScript() script does not contain %q:\n%s", w, script)
+		}
+	}
+	if strings.Contains(string(script), confDestFile) {
+		t.Errorf("writeBootScript() script includes a config that was not staged:\n%s", script)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/winops/storage"
+)
+
+var (
+	// Dependency injections for testing.
+	listPartitions = partitionsOf
+)
+
+// partitionInfo describes a partition of a device as reported by the
+// operating system, for explaining why it could not be selected.
+type partitionInfo struct {
+	id         string
+	size       uint64
+	fileSystem string // Empty when the partition is not formatted or it is unknown.
+	mount      string // Empty when the partition is not mounted.
+}
+
+// String describes the partition, such as 'sdb1 (8.0 GB, FAT32, mounted at /media/usb)'.
+func (p partitionInfo) String() string {
+	fs := p.fileSystem
+	if fs == "" {
+		fs = "unformatted"
+	}
+	mount := "not mounted"
+	if p.mount != "" {
+		mount = "mounted at " + p.mount
+	}
+	return fmt.Sprintf("%s (%s, %s, %s)", p.id, humanize.Bytes(p.size), fs, mount)
+}
+
+// normalizeFileSystem returns the name used by storage for the file system
+// reported by the operating system, such as 'FAT32' for 'vfat'.
+func normalizeFileSystem(fs string) string {
+	switch strings.ToLower(fs) {
+	case "vfat", "fat32", "msdos":
+		return string(storage.FAT32)
+	case "ntfs":
+		return string(storage.NTFS)
+	}
+	return fs
+}
+
+// rejections returns the reasons that p is not suitable as a partition of at
+// least size bytes formatted with fs. An empty fs accepts any file system.
+func rejections(p partitionInfo, size uint64, fs storage.FileSystem) []string {
+	var reasons []string
+	if p.size < size {
+		reasons = append(reasons, fmt.Sprintf("too small, need %s", humanize.Bytes(size)))
+	}
+	if fs != "" && !strings.EqualFold(normalizeFileSystem(p.fileSystem), string(fs)) {
+		reasons = append(reasons, fmt.Sprintf("wrong file system, need %s", fs))
+	}
+	if p.mount == "" {
+		reasons = append(reasons, "not mounted")
+	}
+	return reasons
+}
+
+// explainSelection describes each of the partitions of d and why it is not
+// suitable as a partition of at least size bytes formatted with fs, so that
+// selection failures can be diagnosed without further logs.
+func explainSelection(d Device, size uint64, fs storage.FileSystem) string {
+	want := "any file system"
+	if fs != "" {
+		want = string(fs)
+	}
+	parts, err := listPartitions(d.Identifier())
+	if err != nil {
+		return fmt.Sprintf("the partitions of %q could not be listed: %v", d.FriendlyName(), err)
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%q has no partitions", d.FriendlyName())
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "partitions considered on %q for %s with %s:", d.FriendlyName(), humanize.Bytes(size), want)
+	for _, p := range parts {
+		reasons := rejections(p, size, fs)
+		if len(reasons) == 0 {
+			reasons = []string{"suitable"}
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", p, strings.Join(reasons, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// lsblkDevice is a block device in the JSON output of lsblk.
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Size       lsblkSize     `json:"size"`
+	FSType     string        `json:"fstype"`
+	MountPoint string        `json:"mountpoint"`
+	Children   []lsblkDevice `json:"children"`
+}
+
+// lsblkSize is a size in bytes, which older releases of lsblk report as a
+// string.
+type lsblkSize uint64
+
+func (s *lsblkSize) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, `"`)
+	if string(b) == "null" || len(b) == 0 {
+		return nil
+	}
+	n, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return err
+	}
+	*s = lsblkSize(n)
+	return nil
+}
+
+// partitionsOf lists the partitions of the block device with the provided
+// identifier, such as 'sdb', using lsblk.
+func partitionsOf(id string) ([]partitionInfo, error) {
+	out, err := exec.Command("lsblk", "--json", "--bytes", "--output", "NAME,SIZE,FSTYPE,MOUNTPOINT", devicePath(id)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("lsblk returned %v", err)
+	}
+	return parseLsblk(out)
+}
+
+// parseLsblk returns the partitions of the devices in the JSON output of
+// lsblk.
+func parseLsblk(out []byte) ([]partitionInfo, error) {
+	var result struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(%q) returned %v", out, err)
+	}
+	var parts []partitionInfo
+	for _, d := range result.BlockDevices {
+		for _, c := range d.Children {
+			parts = append(parts, partitionInfo{
+				id:         c.Name,
+				size:       uint64(c.Size),
+				fileSystem: c.FSType,
+				mount:      c.MountPoint,
+			})
+		}
+	}
+	return parts, nil
+}
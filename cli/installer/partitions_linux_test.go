@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLsblk(t *testing.T) {
+	tests := []struct {
+		desc    string
+		out     string
+		want    []partitionInfo
+		wantErr bool
+	}{
+		{
+			desc: "numeric sizes",
+			out: `{"blockdevices": [{"name":"sdb", "size":16000000000, "fstype":null, "mountpoint":null,
+				"children": [
+					{"name":"sdb1", "size":536870912, "fstype":"vfat", "mountpoint":"/media/efi"},
+					{"name":"sdb2", "size":15000000000, "fstype":"ntfs", "mountpoint":null}
+				]}]}`,
+			want: []partitionInfo{
+				{id: "sdb1", size: 536870912, fileSystem: "vfat", mount: "/media/efi"},
+				{id: "sdb2", size: 15000000000, fileSystem: "ntfs"},
+			},
+		},
+		{
+			desc: "string sizes",
+			out:  `{"blockdevices": [{"name":"sdb", "size":"16000000000", "children": [{"name":"sdb1", "size":"16000000000", "fstype":null, "mountpoint":null}]}]}`,
+			want: []partitionInfo{{id: "sdb1", size: 16000000000}},
+		},
+		{
+			desc: "no partitions",
+			out:  `{"blockdevices": [{"name":"sdb", "size":16000000000}]}`,
+		},
+		{
+			desc:    "invalid",
+			out:     `lsblk: /dev/sdz: not a block device`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseLsblk([]byte(tt.out))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseLsblk() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseLsblk() got: %+v, want: %+v", tt.desc, got, tt.want)
+		}
+	}
+}
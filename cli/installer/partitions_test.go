@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/winops/storage"
+)
+
+func TestRejections(t *testing.T) {
+	tests := []struct {
+		desc string
+		part partitionInfo
+		size uint64
+		fs   storage.FileSystem
+		want []string
+	}{
+		{
+			desc: "suitable",
+			part: partitionInfo{id: "sdb1", size: 8e9, fileSystem: "vfat", mount: "/media/usb"},
+			size: 4e9,
+			fs:   storage.FAT32,
+		},
+		{
+			desc: "any file system",
+			part: partitionInfo{id: "sdb1", size: 8e9, fileSystem: "ext4", mount: "/media/usb"},
+			size: 4e9,
+		},
+		{
+			desc: "too small",
+			part: partitionInfo{id: "sdb1", size: 1e9, fileSystem: "FAT32", mount: "E:"},
+			size: 4e9,
+			fs:   storage.FAT32,
+			want: []string{"too small, need " + humanize.Bytes(4e9)},
+		},
+		{
+			desc: "wrong file system and not mounted",
+			part: partitionInfo{id: "sdb2", size: 8e9, fileSystem: "ntfs"},
+			size: 4e9,
+			fs:   storage.FAT32,
+			want: []string{"wrong file system, need FAT32", "not mounted"},
+		},
+		{
+			desc: "unformatted",
+			part: partitionInfo{id: "sdb3", size: 1e9},
+			size: 4e9,
+			fs:   storage.NTFS,
+			want: []string{"too small, need " + humanize.Bytes(4e9), "wrong file system, need NTFS", "not mounted"},
+		},
+	}
+	for _, tt := range tests {
+		got := rejections(tt.part, tt.size, tt.fs)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: rejections() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSelectPartitionExplains(t *testing.T) {
+	defer func() { listPartitions = partitionsOf }()
+	tests := []struct {
+		desc   string
+		parts  []partitionInfo
+		err    error
+		selErr error
+		want   []string
+	}{
+		{
+			desc: "selected",
+		},
+		{
+			desc:   "list error",
+			err:    errors.New("lsblk not found"),
+			selErr: errors.New("no partition"),
+			want:   []string{"no partition", "could not be listed: lsblk not found"},
+		},
+		{
+			desc:   "no partitions",
+			selErr: errors.New("no partition"),
+			want:   []string{`"Flash Drive" has no partitions`},
+		},
+		{
+			desc: "rejected partitions",
+			parts: []partitionInfo{
+				{id: "sdb1", size: 5e8, fileSystem: "vfat", mount: "/media/efi"},
+				{id: "sdb2", size: 15e9, fileSystem: "ntfs"},
+			},
+			selErr: errors.New("no partition"),
+			want: []string{
+				`partitions considered on "Flash Drive" for ` + humanize.Bytes(4e9) + ` with FAT32:`,
+				"sdb1 (" + humanize.Bytes(5e8) + ", vfat, mounted at /media/efi): too small, need " + humanize.Bytes(4e9),
+				"sdb2 (" + humanize.Bytes(15e9) + ", ntfs, not mounted): wrong file system, need FAT32, not mounted",
+			},
+		},
+	}
+	for _, tt := range tests {
+		parts, err := tt.parts, tt.err
+		listPartitions = func(string) ([]partitionInfo, error) { return parts, err }
+		d := &fakeDevice{id: "sdb", name: "Flash Drive", partErr: tt.selErr}
+		_, got := selectPartition(d, 4e9, storage.FAT32)
+		if (got == nil) != (tt.selErr == nil) {
+			t.Errorf("%s: selectPartition() got: %v, want error: %t", tt.desc, got, tt.selErr != nil)
+		}
+		for _, w := range tt.want {
+			if got == nil || !strings.Contains(got.Error(), w) {
+				t.Errorf("%s: selectPartition() got: %v, want it to contain: %q", tt.desc, got, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// partitionScript lists the partitions of a disk and their volumes as JSON.
+const partitionScript = `ConvertTo-Json -InputObject @(Get-Partition -DiskNumber %s | ForEach-Object {
+  $v = $_ | Get-Volume -ErrorAction SilentlyContinue
+  [pscustomobject]@{Number=$_.PartitionNumber; Size=$_.Size; FileSystem=[string]$v.FileSystem; DriveLetter=[string]$_.DriveLetter}
+})`
+
+// psPartition is a partition in the output of partitionScript.
+type psPartition struct {
+	Number      int
+	Size        uint64
+	FileSystem  string
+	DriveLetter string
+}
+
+// partitionsOf lists the partitions of the disk with the provided number
+// using PowerShell.
+func partitionsOf(id string) ([]partitionInfo, error) {
+	script := fmt.Sprintf(partitionScript, id)
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Get-Partition returned %v", err)
+	}
+	return parsePartitions(out)
+}
+
+// parsePartitions returns the partitions in the output of partitionScript.
+func parsePartitions(out []byte) ([]partitionInfo, error) {
+	var ps []psPartition
+	if err := json.Unmarshal(out, &ps); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(%q) returned %v", out, err)
+	}
+	var parts []partitionInfo
+	for _, p := range ps {
+		info := partitionInfo{
+			id:         fmt.Sprintf("partition %d", p.Number),
+			size:       p.Size,
+			fileSystem: p.FileSystem,
+		}
+		// Partitions without a drive letter report a NUL character.
+		if l := strings.Trim(p.DriveLetter, "\x00 "); l != "" {
+			info.mount = l + `:`
+		}
+		parts = append(parts, info)
+	}
+	return parts, nil
+}
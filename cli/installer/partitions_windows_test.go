@@ -0,0 +1,57 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePartitions(t *testing.T) {
+	tests := []struct {
+		desc    string
+		out     string
+		want    []partitionInfo
+		wantErr bool
+	}{
+		{
+			desc: "partitions",
+			out: `[{"Number":1,"Size":536870912,"FileSystem":"FAT32","DriveLetter":"E"},
+				{"Number":2,"Size":15000000000,"FileSystem":"NTFS","DriveLetter":"\u0000"}]`,
+			want: []partitionInfo{
+				{id: "partition 1", size: 536870912, fileSystem: "FAT32", mount: "E:"},
+				{id: "partition 2", size: 15000000000, fileSystem: "NTFS"},
+			},
+		},
+		{
+			desc: "no partitions",
+			out:  `[]`,
+		},
+		{
+			desc:    "invalid",
+			out:     `Get-Partition : No MSFT_Partition objects found`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := parsePartitions([]byte(tt.out))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parsePartitions() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parsePartitions() got: %+v, want: %+v", tt.desc, got, tt.want)
+		}
+	}
+}
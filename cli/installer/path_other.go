@@ -0,0 +1,38 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package installer
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// extendedPath returns p unchanged, as paths are only limited in length on
+// Windows.
+func extendedPath(p string) string {
+	return p
+}
+
+// localPath returns the path of the local file named by a file:// URL. Files
+// on other hosts are only supported on Windows, where they are shares.
+func localPath(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("%w: %q names the host %q, mount it and name the local path instead", errInput, u, u.Host)
+	}
+	return u.Path, nil
+}
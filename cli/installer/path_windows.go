@@ -0,0 +1,55 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// extendedPath returns p as an extended-length path. Extended-length paths are
+// not limited to MAX_PATH (260 characters) and are passed to the file system
+// without normalization, so that deep paths and names with non-ASCII or
+// trailing characters are preserved exactly. Paths that are already extended
+// or cannot be made absolute are returned unchanged.
+func extendedPath(p string) string {
+	if p == "" || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
+
+// localPath returns the path of the file named by a file:// URL, such as
+// 'C:\images\installer.iso' for file:///C:/images/installer.iso. URLs that
+// name a host, such as file://server/share/installer.iso, are returned as UNC
+// paths.
+func localPath(u *url.URL) (string, error) {
+	p := filepath.FromSlash(u.Path)
+	if u.Host != "" && u.Host != "localhost" {
+		return `\\` + u.Host + p, nil
+	}
+	if len(p) > 2 && p[0] == '\\' && p[2] == ':' {
+		p = p[1:]
+	}
+	return p, nil
+}
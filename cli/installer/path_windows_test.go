@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtendedPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd() returned %v", err)
+	}
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"drive", `E:\sources\boot.wim`, `\\?\E:\sources\boot.wim`},
+		{"drive root", `E:\`, `\\?\E:\`},
+		{"unicode", `E:\Données\日本語.inf`, `\\?\E:\Données\日本語.inf`},
+		{"unc", `\\server\share\boot.wim`, `\\?\UNC\server\share\boot.wim`},
+		{"already extended", `\\?\E:\a\..\b`, `\\?\E:\a\..\b`},
+		{"relative", `sources\boot.wim`, `\\?\` + filepath.Join(wd, `sources\boot.wim`)},
+	}
+	for _, tt := range tests {
+		if got := extendedPath(tt.in); got != tt.want {
+			t.Errorf("%s: extendedPath(%q) got: %q, want: %q", tt.desc, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"drive", "file:///C:/images/installer.iso", `C:\images\installer.iso`},
+		{"localhost", "file://localhost/C:/images/installer.iso", `C:\images\installer.iso`},
+		{"share", "file://server/share/installer.iso", `\\server\share\installer.iso`},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatalf("%s: url.Parse(%q) returned %v", tt.desc, tt.in, err)
+		}
+		got, err := localPath(u)
+		if err != nil {
+			t.Errorf("%s: localPath(%q) returned %v", tt.desc, tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: localPath(%q) got: %q, want: %q", tt.desc, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+)
+
+// isRawImage reports whether images with the extension ext are written to
+// devices as raw disks.
+func isRawImage(ext string) bool {
+	switch ext {
+	case ".img", ".img.gz", ".img.xz", ".img.zst", ".vhd", ".vhdx", ".qcow2":
+		return true
+	}
+	return false
+}
+
+// addPersistence adds an ext4 persistence partition after the partitions of a
+// raw image that was written to d, when persistence is configured. Live
+// images that find a partition with the configured label keep changes made
+// while they run on it.
+func (i *Installer) addPersistence(d Device) error {
+	if !i.config.Persistence() {
+		return nil
+	}
+	size, label := uint64(i.config.PersistenceSize()), i.config.PersistenceLabel()
+	desc := "the remainder of the device"
+	if size > 0 {
+		desc = humanize.Bytes(size)
+	}
+	console.Printf("Adding a persistence partition of %s to %s.", desc, d.FriendlyName())
+	deck.InfofA("Adding persistence partition %q of %s to %q.", label, desc, d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	if err := makePersistence(d.Identifier(), size, label); err != nil {
+		return fmt.Errorf("makePersistence(%q, %d, %q) returned %v: %w", d.Identifier(), size, label, err, errPartition)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/fresnel/cli/config"
+)
+
+const (
+	// persistenceConf is the file that live-boot reads from persistence
+	// partitions, and persistenceUnion persists the whole root file system.
+	persistenceConf  = "persistence.conf"
+	persistenceUnion = "/ union\n"
+	// nodeAttempts is the number of times the node of a new partition is
+	// looked for while udev creates it.
+	nodeAttempts = 20
+)
+
+// nodeInterval is the time between looks for the node of a new partition.
+var nodeInterval = 250 * time.Millisecond
+
+// sfdiskTable is the JSON output of 'sfdisk --json'.
+type sfdiskTable struct {
+	PartitionTable struct {
+		Label      string `json:"label"`
+		Partitions []struct {
+			Node string `json:"node"`
+		} `json:"partitions"`
+	} `json:"partitiontable"`
+}
+
+// parseSfdisk returns the label type of a partition table, such as 'gpt' or
+// 'dos', and the node of its last partition from the output of
+// 'sfdisk --json'.
+func parseSfdisk(out []byte) (string, string, error) {
+	var t sfdiskTable
+	if err := json.Unmarshal(out, &t); err != nil {
+		return "", "", fmt.Errorf("json.Unmarshal(%q) returned %v", out, err)
+	}
+	parts := t.PartitionTable.Partitions
+	if len(parts) == 0 {
+		return t.PartitionTable.Label, "", nil
+	}
+	return t.PartitionTable.Label, parts[len(parts)-1].Node, nil
+}
+
+// partitionTable reads the partition table of dev.
+func partitionTable(dev string) (string, string, error) {
+	out, err := exec.Command("sfdisk", "--json", dev).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("sfdisk --json %s returned %v", dev, err)
+	}
+	return parseSfdisk(out)
+}
+
+// persistenceSpec returns the sfdisk script that appends a linux partition of
+// size bytes, or one that fills the remaining space when size is zero.
+func persistenceSpec(size uint64) string {
+	if size == 0 {
+		return ",,L\n"
+	}
+	return fmt.Sprintf(",%dMiB,L\n", size>>20)
+}
+
+// persistencePartition appends an ext4 partition labeled label to the block
+// device with the provided identifier, such as 'sdb', using sfdisk and
+// mkfs.ext4. Images with a GPT carry its backup at the end of the image,
+// which is first moved to the end of the device. When label is
+// config.DefaultPersistenceLabel, live-boot is configured to persist the
+// whole root file system.
+func persistencePartition(id string, size uint64, label string) error {
+	dev := devicePath(id)
+	table, last, err := partitionTable(dev)
+	if err != nil {
+		return err
+	}
+	if table == "gpt" {
+		if err := run("sfdisk", "--relocate", "gpt-bak-std", dev); err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command("sfdisk", "--append", dev)
+	cmd.Stdin = strings.NewReader(persistenceSpec(size))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sfdisk --append %s returned %v: %s", dev, err, out)
+	}
+	_, node, err := partitionTable(dev)
+	if err != nil {
+		return err
+	}
+	if node == "" || node == last {
+		return fmt.Errorf("no partition was added to %s", dev)
+	}
+	if err := waitNode(node); err != nil {
+		return err
+	}
+	if err := run("mkfs.ext4", "-F", "-L", label, node); err != nil {
+		return err
+	}
+	if label != config.DefaultPersistenceLabel {
+		return nil
+	}
+	return writePersistenceConf(node)
+}
+
+// waitNode waits for udev to create the device node of a new partition.
+func waitNode(node string) error {
+	var err error
+	for attempt := 0; attempt < nodeAttempts; attempt++ {
+		if _, err = os.Stat(node); err == nil {
+			return nil
+		}
+		time.Sleep(nodeInterval)
+	}
+	return fmt.Errorf("%s was not created: %v", node, err)
+}
+
+// writePersistenceConf mounts the ext4 file system at node and writes the
+// configuration of live-boot to it.
+func writePersistenceConf(node string) (err error) {
+	dir, err := ioutil.TempDir("", "persistence")
+	if err != nil {
+		return fmt.Errorf("ioutil.TempDir() returned %v", err)
+	}
+	defer os.Remove(dir)
+	if err := syscall.Mount(node, dir, "ext4", 0, ""); err != nil {
+		return fmt.Errorf("mounting %s returned %v", node, err)
+	}
+	defer func() {
+		if err2 := syscall.Unmount(dir, 0); err2 != nil && err == nil {
+			err = fmt.Errorf("unmounting %s returned %v", node, err2)
+		}
+	}()
+	// Permissions = owner:read/write, group:read"
+	path := filepath.Join(dir, persistenceConf)
+	if err := ioutil.WriteFile(path, []byte(persistenceUnion), 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "testing"
+
+func TestParseSfdisk(t *testing.T) {
+	tests := []struct {
+		desc      string
+		out       string
+		wantLabel string
+		wantNode  string
+		wantErr   bool
+	}{
+		{
+			desc:      "gpt",
+			out:       `{"partitiontable": {"label": "gpt", "device": "/dev/sdb", "partitions": [{"node": "/dev/sdb1", "start": 2048}, {"node": "/dev/sdb2", "start": 4096}]}}`,
+			wantLabel: "gpt",
+			wantNode:  "/dev/sdb2",
+		},
+		{
+			desc:      "no partitions",
+			out:       `{"partitiontable": {"label": "dos", "device": "/dev/sdb"}}`,
+			wantLabel: "dos",
+		},
+		{
+			desc:    "invalid",
+			out:     "sfdisk: cannot open /dev/sdb",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		label, node, err := parseSfdisk([]byte(tt.out))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseSfdisk() err: %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if label != tt.wantLabel || node != tt.wantNode {
+			t.Errorf("%s: parseSfdisk() got: (%q, %q), want: (%q, %q)", tt.desc, label, node, tt.wantLabel, tt.wantNode)
+		}
+	}
+}
+
+func TestPersistenceSpec(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want string
+	}{
+		{0, ",,L\n"},
+		{4 << 30, ",4096MiB,L\n"},
+	}
+	for _, tt := range tests {
+		if got := persistenceSpec(tt.size); got != tt.want {
+			t.Errorf("persistenceSpec(%d) got: %q, want: %q", tt.size, got, tt.want)
+		}
+	}
+}
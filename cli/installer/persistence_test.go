@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/fresnel/cli/units"
+)
+
+func TestAddPersistence(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  *fakeConfig
+		persist func(string, uint64, string) error
+		want    error
+	}{
+		{
+			desc:    "not configured",
+			config:  &fakeConfig{},
+			persist: func(string, uint64, string) error { return errors.New("unexpected call") },
+			want:    nil,
+		},
+		{
+			desc:    "error",
+			config:  &fakeConfig{persistence: true, persistenceLabel: "persistence"},
+			persist: func(string, uint64, string) error { return errors.New("error") },
+			want:    errPartition,
+		},
+		{
+			desc:   "success",
+			config: &fakeConfig{persistence: true, persistenceSize: 4 * units.GB, persistenceLabel: "casper-rw"},
+			persist: func(id string, size uint64, label string) error {
+				if id != "sdb" || size != uint64(4*units.GB) || label != "casper-rw" {
+					return errors.New("unexpected partition")
+				}
+				return nil
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		makePersistence = tt.persist
+		i := &Installer{config: tt.config}
+		if got := i.addPersistence(&fakeDevice{id: "sdb"}); !errors.Is(got, tt.want) {
+			t.Errorf("%s: addPersistence() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+	makePersistence = persistencePartition
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"runtime"
+	"strings"
+)
+
+var (
+	// host is the platform that devices are provisioned on, and is replaced
+	// to test the behavior of other platforms.
+	host = platformFor(runtime.GOOS)
+)
+
+// platform describes how devices are prepared, mounted and written on an
+// operating system, so that the differences between them are chosen in one
+// place rather than by checks of runtime.GOOS throughout the installer.
+type platform interface {
+	// formatsOnPartition reports whether partitioning a device also formats
+	// the partition that is created, so that it is not formatted again.
+	formatsOnPartition() bool
+	// mountBase returns the directory that partitions are mounted beneath
+	// given the cache of the installer. An empty base leaves the choice of
+	// mount point, such as a drive letter, to the operating system.
+	mountBase(cache string) string
+	// root returns the root directory of a partition mounted at mountPoint.
+	root(mountPoint string) string
+	// copiesPerFile reports whether ISO contents are always copied one file
+	// at a time, rather than by the iso package.
+	copiesPerFile() bool
+}
+
+// platformFor returns the platform for the operating system named goos.
+// Unix-like systems other than darwin are treated as linux.
+func platformFor(goos string) platform {
+	switch goos {
+	case "darwin":
+		return darwinPlatform{}
+	case "windows":
+		return windowsPlatform{}
+	}
+	return linuxPlatform{}
+}
+
+// linuxPlatform partitions and formats devices in separate steps, and mounts
+// partitions beneath the cache.
+type linuxPlatform struct{}
+
+func (linuxPlatform) formatsOnPartition() bool { return false }
+
+func (linuxPlatform) mountBase(cache string) string { return cache }
+
+func (linuxPlatform) root(mountPoint string) string { return mountPoint }
+
+func (linuxPlatform) copiesPerFile() bool { return false }
+
+// darwinPlatform partitions devices using diskutil, which also formats the
+// new partition, so no partition is selected and formatted afterwards.
+type darwinPlatform struct{}
+
+func (darwinPlatform) formatsOnPartition() bool { return true }
+
+func (darwinPlatform) mountBase(cache string) string { return cache }
+
+func (darwinPlatform) root(mountPoint string) string { return mountPoint }
+
+func (darwinPlatform) copiesPerFile() bool { return false }
+
+// windowsPlatform mounts partitions at drive letters chosen by the operating
+// system, and copies files individually so that every destination is written
+// using an extended-length path.
+type windowsPlatform struct{}
+
+func (windowsPlatform) formatsOnPartition() bool { return false }
+
+func (windowsPlatform) mountBase(string) string { return "" }
+
+// root adds the colon and separator to a bare drive letter, which would
+// otherwise be treated as a relative path.
+func (windowsPlatform) root(mountPoint string) string {
+	if strings.Contains(mountPoint, `:`) {
+		return mountPoint
+	}
+	return mountPoint + `:\`
+}
+
+func (windowsPlatform) copiesPerFile() bool { return true }
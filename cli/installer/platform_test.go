@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlatformFor(t *testing.T) {
+	tests := []struct {
+		goos string
+		want platform
+	}{
+		{goos: "darwin", want: darwinPlatform{}},
+		{goos: "linux", want: linuxPlatform{}},
+		{goos: "windows", want: windowsPlatform{}},
+		{goos: "freebsd", want: linuxPlatform{}},
+	}
+	for _, tt := range tests {
+		if got := platformFor(tt.goos); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("platformFor(%q) got: %T, want: %T", tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestPlatforms(t *testing.T) {
+	tests := []struct {
+		desc         string
+		platform     platform
+		formats      bool
+		base         string
+		mountPoint   string
+		root         string
+		copiesByFile bool
+	}{
+		{
+			desc:       "linux",
+			platform:   linuxPlatform{},
+			base:       "cache",
+			mountPoint: "/mnt/sdb1",
+			root:       "/mnt/sdb1",
+		},
+		{
+			desc:       "darwin formats when partitioning",
+			platform:   darwinPlatform{},
+			formats:    true,
+			base:       "cache",
+			mountPoint: "/Volumes/INSTALLER",
+			root:       "/Volumes/INSTALLER",
+		},
+		{
+			desc:         "windows drive letter",
+			platform:     windowsPlatform{},
+			mountPoint:   "E",
+			root:         `E:\`,
+			copiesByFile: true,
+		},
+		{
+			desc:         "windows drive root",
+			platform:     windowsPlatform{},
+			mountPoint:   `E:\`,
+			root:         `E:\`,
+			copiesByFile: true,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.platform.formatsOnPartition(); got != tt.formats {
+			t.Errorf("%s: formatsOnPartition() got: %t, want: %t", tt.desc, got, tt.formats)
+		}
+		if got := tt.platform.mountBase("cache"); got != tt.base {
+			t.Errorf("%s: mountBase() got: %q, want: %q", tt.desc, got, tt.base)
+		}
+		if got := tt.platform.root(tt.mountPoint); got != tt.root {
+			t.Errorf("%s: root(%q) got: %q, want: %q", tt.desc, tt.mountPoint, got, tt.root)
+		}
+		if got := tt.platform.copiesPerFile(); got != tt.copiesByFile {
+			t.Errorf("%s: copiesPerFile() got: %t, want: %t", tt.desc, got, tt.copiesByFile)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+var (
+	// Dependency injections for testing.
+	protectionOf = writeProtection
+)
+
+// CheckWritable returns an error wrapping ErrWriteProtected when the device
+// with the provided identifier is write protected, such as by a lock switch
+// on the device or a read-only attribute set by the operating system. The
+// error explains how the protection can be cleared. Devices whose protection
+// cannot be determined are assumed to be writable, as writing to them reports
+// any protection that was missed.
+func CheckWritable(id string) error {
+	reason, err := protectionOf(id)
+	if err != nil {
+		deck.InfofA("Write protection of %q could not be determined: %v", id, err).With(debug.V(debug.Storage, 2)).Go()
+		return nil
+	}
+	if reason == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: %q %s, %s", ErrWriteProtected, id, reason, clearProtection(id))
+}
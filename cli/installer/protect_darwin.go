@@ -0,0 +1,60 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// writeProtection returns why the disk with the provided identifier, such as
+// 'disk2', is write protected, or an empty string when it is not, using
+// diskutil.
+func writeProtection(id string) (string, error) {
+	out, err := exec.Command("diskutil", "info", id).Output()
+	if err != nil {
+		return "", fmt.Errorf("diskutil info returned %v", err)
+	}
+	return parseDiskutilInfo(out), nil
+}
+
+// parseDiskutilInfo returns why a disk is write protected from the output of
+// diskutil info, which reports read-only media under either of two names
+// depending on the release of macOS.
+func parseDiskutilInfo(out []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "Media Read-Only", "Read-Only Media":
+			if strings.TrimSpace(v) == "Yes" {
+				return "is read-only media"
+			}
+		}
+	}
+	return ""
+}
+
+// clearProtection explains how to make the disk with the provided identifier
+// writable.
+func clearProtection(string) string {
+	return "slide the lock switch on the device to unlocked and reinsert it"
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// sysBlockDir is where the kernel describes block devices.
+var sysBlockDir = "/sys/block"
+
+// writeProtection returns why the block device with the provided identifier,
+// such as 'sdb', is write protected, or an empty string when it is not. The
+// kernel marks devices as read-only when their lock switch is set or when
+// they were made read-only with blockdev.
+func writeProtection(id string) (string, error) {
+	ro, err := ioutil.ReadFile(filepath.Join(sysBlockDir, id, "ro"))
+	if err != nil {
+		return "", fmt.Errorf("reading the read-only flag of %q: %v", id, err)
+	}
+	if strings.TrimSpace(string(ro)) != "1" {
+		return "", nil
+	}
+	return "is marked read-only by the kernel", nil
+}
+
+// clearProtection explains how to make the device with the provided
+// identifier writable.
+func clearProtection(id string) string {
+	return fmt.Sprintf("slide the lock switch on the device to unlocked and reinsert it, or run 'blockdev --setrw %s'", devicePath(id))
+}
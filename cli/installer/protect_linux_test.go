@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteProtection(t *testing.T) {
+	defer func(d string) { sysBlockDir = d }(sysBlockDir)
+	sysBlockDir = t.TempDir()
+	for id, ro := range map[string]string{"sda": "0\n", "sdb": "1\n"} {
+		if err := os.Mkdir(filepath.Join(sysBlockDir, id), 0755); err != nil {
+			t.Fatalf("os.Mkdir() returned %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sysBlockDir, id, "ro"), []byte(ro), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+	}
+	tests := []struct {
+		desc          string
+		id            string
+		wantProtected bool
+		wantErr       bool
+	}{
+		{
+			desc: "writable",
+			id:   "sda",
+		},
+		{
+			desc:          "read-only",
+			id:            "sdb",
+			wantProtected: true,
+		},
+		{
+			desc:    "missing device",
+			id:      "sdc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := writeProtection(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: writeProtection() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if (got != "") != tt.wantProtected {
+			t.Errorf("%s: writeProtection() got: %q, want protected: %t", tt.desc, got, tt.wantProtected)
+		}
+	}
+}
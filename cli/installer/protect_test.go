@@ -0,0 +1,54 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckWritable(t *testing.T) {
+	defer func() { protectionOf = writeProtection }()
+	tests := []struct {
+		desc   string
+		reason string
+		err    error
+		want   error
+	}{
+		{
+			desc: "writable",
+		},
+		{
+			desc:   "write protected",
+			reason: "is marked read-only",
+			want:   ErrWriteProtected,
+		},
+		{
+			desc: "unknown protection is writable",
+			err:  errors.New("error"),
+		},
+	}
+	for _, tt := range tests {
+		protectionOf = func(string) (string, error) { return tt.reason, tt.err }
+		got := CheckWritable("1")
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: CheckWritable() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if got != nil && !strings.Contains(got.Error(), tt.reason) {
+			t.Errorf("%s: CheckWritable() got: %v, want it to contain %q", tt.desc, got, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// protectionScript reports the read-only attribute of a disk as JSON.
+const protectionScript = `Get-Disk -Number %s | Select-Object IsReadOnly | ConvertTo-Json`
+
+// writeProtection returns why the disk with the provided number is write
+// protected, or an empty string when it is not. Windows marks disks as
+// read-only when their lock switch is set or when the readonly attribute was
+// set with diskpart.
+func writeProtection(id string) (string, error) {
+	script := fmt.Sprintf(protectionScript, id)
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return "", fmt.Errorf("Get-Disk returned %v", err)
+	}
+	return parseProtection(out)
+}
+
+// parseProtection returns why a disk is write protected from the output of
+// protectionScript.
+func parseProtection(out []byte) (string, error) {
+	var disk struct {
+		IsReadOnly bool
+	}
+	if err := json.Unmarshal(out, &disk); err != nil {
+		return "", fmt.Errorf("json.Unmarshal(%q) returned %v", out, err)
+	}
+	if !disk.IsReadOnly {
+		return "", nil
+	}
+	return "is marked read-only by Windows", nil
+}
+
+// clearProtection explains how to make the disk with the provided number
+// writable.
+func clearProtection(id string) string {
+	return fmt.Sprintf("slide the lock switch on the device to unlocked and reinsert it, or run 'diskpart', then 'select disk %s' and 'attributes disk clear readonly'", id)
+}
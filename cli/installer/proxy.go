@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// proxyFunc returns the function that selects the proxy for each request: the
+// proxy at rawURL, or those named by the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// environment variables when rawURL is empty.
+func proxyFunc(rawURL string) (func(*http.Request) (*url.URL, error), error) {
+	if rawURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: proxy: %v", errConnect, err)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// proxyTransport returns a copy of base that sends its requests through the
+// proxy at rawURL, or through those of the environment when it is empty. The
+// default transport is copied when base is nil.
+func proxyTransport(base http.RoundTripper, rawURL string) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("%w: proxies are not supported by transport %T", errConnect, base)
+	}
+	proxy, err := proxyFunc(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	t = t.Clone()
+	t.Proxy = proxy
+	return t, nil
+}
+
+// withProxy configures client to send its requests through the proxy at
+// rawURL, or through those of the environment when it is empty. The clients
+// returned by fetcher do not consult the environment themselves, so they are
+// always configured. Clients that authenticate with OAuth have the transport
+// beneath the authentication configured.
+func withProxy(client *http.Client, rawURL string) (*http.Client, error) {
+	var err error
+	switch t := client.Transport.(type) {
+	case *oauth2.Transport:
+		base := *t
+		if base.Base, err = proxyTransport(t.Base, rawURL); err != nil {
+			return nil, err
+		}
+		client.Transport = &base
+	default:
+		if client.Transport, err = proxyTransport(t, rawURL); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// otherTransport is a transport whose proxy cannot be configured.
+type otherTransport struct{}
+
+func (otherTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestWithProxy(t *testing.T) {
+	tests := []struct {
+		desc      string
+		transport http.RoundTripper
+		wantErr   error
+	}{
+		{
+			desc: "default transport",
+		},
+		{
+			desc:      "transport",
+			transport: &http.Transport{},
+		},
+		{
+			desc:      "oauth transport",
+			transport: &oauth2.Transport{},
+		},
+		{
+			desc:      "unsupported transport",
+			transport: otherTransport{},
+			wantErr:   errConnect,
+		},
+	}
+	for _, tt := range tests {
+		client, err := withProxy(&http.Client{Transport: tt.transport}, "http://proxy.example.com:3128")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: withProxy() got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		transport := client.Transport
+		if o, ok := transport.(*oauth2.Transport); ok {
+			transport = o.Base
+		}
+		ht, ok := transport.(*http.Transport)
+		if !ok {
+			t.Errorf("%s: withProxy() transport is %T, want *http.Transport", tt.desc, transport)
+			continue
+		}
+		req, err := http.NewRequest(http.MethodGet, "https://images.example.com/installer.iso", nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest() returned %v", err)
+		}
+		got, err := ht.Proxy(req)
+		if err != nil || got == nil || got.Host != "proxy.example.com:3128" {
+			t.Errorf("%s: withProxy() proxy got: %v, %v, want: proxy.example.com:3128", tt.desc, got, err)
+		}
+	}
+}
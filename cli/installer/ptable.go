@@ -0,0 +1,210 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// tableSector is the sector size that partition tables are addressed in.
+	tableSector = 512
+	// mbrProtective is the MBR partition type that marks a GPT disk.
+	mbrProtective = 0xee
+	// gptHeaderSize is the size of the GPT header fields covered by its CRC.
+	gptHeaderSize = 92
+)
+
+// gptSignature starts the header of a GUID partition table.
+var gptSignature = []byte("EFI PART")
+
+// partitionedSize returns the number of bytes at the start of a device of
+// size bytes, read with r, that hold its partitions, as described by its MBR
+// or GPT partition table. GPT disks also keep a backup of their table at the
+// end of the device, which is rewritten by relocateGPT, so room is left for
+// it. The whole device is used when it has no partition table, or when the
+// table describes no partitions.
+func partitionedSize(r io.ReaderAt, size int64) (int64, error) {
+	mbr := make([]byte, tableSector)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return 0, fmt.Errorf("reading the MBR returned %v: %w", err, errIO)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return size, nil
+	}
+	if protective(mbr) {
+		return gptSize(r, size)
+	}
+	var end int64
+	for n := 0; n < 4; n++ {
+		e := mbr[446+16*n : 446+16*(n+1)]
+		start, count := binary.LittleEndian.Uint32(e[8:]), binary.LittleEndian.Uint32(e[12:])
+		if e[4] == 0 || count == 0 {
+			continue
+		}
+		if last := (int64(start) + int64(count)) * tableSector; last > end {
+			end = last
+		}
+	}
+	if end == 0 || end > size {
+		return size, nil
+	}
+	return end, nil
+}
+
+// protective returns whether mbr is the protective MBR of a GPT disk.
+func protective(mbr []byte) bool {
+	for n := 0; n < 4; n++ {
+		if mbr[446+16*n+4] == mbrProtective {
+			return true
+		}
+	}
+	return false
+}
+
+// gptTable is the primary header of a GUID partition table and its entries.
+type gptTable struct {
+	header  []byte // The header sector.
+	entries []byte // The partition entries.
+}
+
+// readGPT reads the primary GUID partition table with r.
+func readGPT(r io.ReaderAt) (*gptTable, error) {
+	h := &gptTable{header: make([]byte, tableSector)}
+	if _, err := r.ReadAt(h.header, tableSector); err != nil {
+		return nil, fmt.Errorf("reading the GPT header returned %v: %w", err, errIO)
+	}
+	if !bytes.Equal(h.header[:8], gptSignature) {
+		return nil, fmt.Errorf("the protective MBR is not followed by a GPT header: %w", errDevice)
+	}
+	lba := binary.LittleEndian.Uint64(h.header[72:])
+	count, size := binary.LittleEndian.Uint32(h.header[80:]), binary.LittleEndian.Uint32(h.header[84:])
+	if size < 128 || count == 0 || count > 1024 {
+		return nil, fmt.Errorf("the GPT header describes %d entries of %d bytes: %w", count, size, errDevice)
+	}
+	h.entries = make([]byte, int(count)*int(size))
+	if _, err := r.ReadAt(h.entries, int64(lba)*tableSector); err != nil {
+		return nil, fmt.Errorf("reading the GPT entries returned %v: %w", err, errIO)
+	}
+	return h, nil
+}
+
+// entrySectors returns the number of sectors that the entries occupy.
+func (h *gptTable) entrySectors() int64 {
+	return (int64(len(h.entries)) + tableSector - 1) / tableSector
+}
+
+// lastLBA returns the last sector used by a partition, or zero when the table
+// describes none.
+func (h *gptTable) lastLBA() uint64 {
+	size := int(binary.LittleEndian.Uint32(h.header[84:]))
+	var last uint64
+	for off := 0; off+size <= len(h.entries); off += size {
+		e := h.entries[off : off+size]
+		if bytes.Equal(e[:16], make([]byte, 16)) {
+			continue
+		}
+		if l := binary.LittleEndian.Uint64(e[40:]); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+// gptSize returns the size needed to hold the partitions of a GPT disk of
+// size bytes, and a backup of its table after them.
+func gptSize(r io.ReaderAt, size int64) (int64, error) {
+	h, err := readGPT(r)
+	if err != nil {
+		return 0, err
+	}
+	last := h.lastLBA()
+	if last == 0 {
+		return size, nil
+	}
+	end := (int64(last) + 1 + h.entrySectors() + 1) * tableSector
+	if end > size {
+		return size, nil
+	}
+	return end, nil
+}
+
+// relocateGPT moves the end of the GUID partition table of a device of size
+// bytes, read with r and written with w, to the end of the device. A table
+// copied from a device of another size describes the end of that device, so
+// the primary header is updated and the backup header and entries are
+// written to the last sectors. Devices without a GUID partition table are
+// left unchanged.
+func relocateGPT(r io.ReaderAt, w io.WriteSeeker, size int64) error {
+	mbr := make([]byte, tableSector)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return fmt.Errorf("reading the MBR returned %v: %w", err, errIO)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa || !protective(mbr) {
+		return nil
+	}
+	h, err := readGPT(r)
+	if err != nil {
+		return err
+	}
+	last := uint64(size/tableSector - 1)
+	backupEntries := last - uint64(h.entrySectors())
+	if h.lastLBA() >= backupEntries {
+		return fmt.Errorf("the partitions end at sector %d, leaving no room for the backup GPT of a device of %d sectors: %w", h.lastLBA(), last+1, errDevice)
+	}
+	primary := h.header
+	binary.LittleEndian.PutUint64(primary[32:], last)
+	binary.LittleEndian.PutUint64(primary[48:], backupEntries-1)
+	setHeaderCRC(primary)
+
+	backup := append([]byte(nil), primary...)
+	binary.LittleEndian.PutUint64(backup[24:], last)
+	binary.LittleEndian.PutUint64(backup[32:], 1)
+	binary.LittleEndian.PutUint64(backup[72:], backupEntries)
+	setHeaderCRC(backup)
+
+	entries := make([]byte, h.entrySectors()*tableSector)
+	copy(entries, h.entries)
+	for _, write := range []struct {
+		off int64
+		b   []byte
+	}{
+		{tableSector, primary},
+		{int64(backupEntries) * tableSector, entries},
+		{int64(last) * tableSector, backup},
+	} {
+		if _, err := w.Seek(write.off, io.SeekStart); err != nil {
+			return fmt.Errorf("Seek(%d) returned %v: %w", write.off, err, errIO)
+		}
+		if _, err := w.Write(write.b); err != nil {
+			return fmt.Errorf("writing the GPT at %d returned %v: %w", write.off, err, errIO)
+		}
+	}
+	return nil
+}
+
+// setHeaderCRC updates the CRC of a GPT header.
+func setHeaderCRC(header []byte) {
+	size := binary.LittleEndian.Uint32(header[12:])
+	if size < gptHeaderSize || size > tableSector {
+		size = gptHeaderSize
+	}
+	binary.LittleEndian.PutUint32(header[16:], 0)
+	binary.LittleEndian.PutUint32(header[16:], crc32.ChecksumIEEE(header[:size]))
+}
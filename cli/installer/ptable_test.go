@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mbrDisk returns the first sector of a disk partitioned with an MBR, with a
+// partition of each of the start and count pairs in parts.
+func mbrDisk(parts ...[2]uint32) []byte {
+	mbr := make([]byte, tableSector)
+	for n, p := range parts {
+		e := mbr[446+16*n:]
+		e[4] = 0x0c
+		binary.LittleEndian.PutUint32(e[8:], p[0])
+		binary.LittleEndian.PutUint32(e[12:], p[1])
+	}
+	mbr[510], mbr[511] = 0x55, 0xaa
+	return mbr
+}
+
+// gptDisk returns a disk of sectors partitioned with a GPT, with a single
+// partition from sector 34 to last.
+func gptDisk(sectors int, last uint64) []byte {
+	disk := make([]byte, sectors*tableSector)
+	copy(disk, mbrDisk())
+	disk[446+4] = mbrProtective
+	h := disk[tableSector:]
+	copy(h, gptSignature)
+	binary.LittleEndian.PutUint32(h[12:], gptHeaderSize)
+	binary.LittleEndian.PutUint64(h[24:], 1)
+	binary.LittleEndian.PutUint64(h[32:], uint64(sectors-1))
+	binary.LittleEndian.PutUint64(h[72:], 2)
+	binary.LittleEndian.PutUint32(h[80:], 128)
+	binary.LittleEndian.PutUint32(h[84:], 128)
+	e := disk[2*tableSector:]
+	e[0] = 1
+	binary.LittleEndian.PutUint64(e[32:], 34)
+	binary.LittleEndian.PutUint64(e[40:], last)
+	setHeaderCRC(h)
+	return disk
+}
+
+func TestPartitionedSize(t *testing.T) {
+	tests := []struct {
+		desc string
+		disk []byte
+		size int64
+		want int64
+	}{
+		{"no partition table", make([]byte, tableSector), 1 << 20, 1 << 20},
+		{"empty MBR", mbrDisk(), 1 << 20, 1 << 20},
+		{"MBR", mbrDisk([2]uint32{2048, 100}, [2]uint32{4, 10}), 1 << 30, 2148 * tableSector},
+		{"MBR beyond the device", mbrDisk([2]uint32{2048, 100}), 1 << 20, 1 << 20},
+		{"GPT", gptDisk(200, 99), 200 * tableSector, (100 + 32 + 1) * tableSector},
+		{"GPT without room for the backup", gptDisk(120, 99), 120 * tableSector, 120 * tableSector},
+	}
+	for _, tt := range tests {
+		got, err := partitionedSize(bytes.NewReader(tt.disk), tt.size)
+		if err != nil {
+			t.Errorf("%s: partitionedSize() returned %v", tt.desc, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: partitionedSize() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRelocateGPT(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk")
+	// The stream of a GPT disk of 200 sectors, received by one of 300.
+	disk := gptDisk(200, 99)
+	if err := ioutil.WriteFile(path, append(disk[:133*tableSector], make([]byte, 167*tableSector)...), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("os.OpenFile() returned %v", err)
+	}
+	defer f.Close()
+	if err := relocateGPT(f, f, 300*tableSector); err != nil {
+		t.Fatalf("relocateGPT() returned %v", err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile() returned %v", err)
+	}
+	valid := func(h []byte) bool {
+		c := append([]byte(nil), h[:gptHeaderSize]...)
+		want := binary.LittleEndian.Uint32(c[16:])
+		binary.LittleEndian.PutUint32(c[16:], 0)
+		return crc32.ChecksumIEEE(c) == want
+	}
+	primary, backup := got[tableSector:2*tableSector], got[299*tableSector:]
+	if !valid(primary) || binary.LittleEndian.Uint64(primary[32:]) != 299 || binary.LittleEndian.Uint64(primary[48:]) != 266 {
+		t.Errorf("relocateGPT() primary header does not describe a device of 300 sectors")
+	}
+	if !bytes.Equal(backup[:8], gptSignature) || !valid(backup) || binary.LittleEndian.Uint64(backup[24:]) != 299 || binary.LittleEndian.Uint64(backup[72:]) != 267 {
+		t.Errorf("relocateGPT() backup header is not valid")
+	}
+	if !bytes.Equal(got[267*tableSector:299*tableSector], disk[2*tableSector:34*tableSector]) {
+		t.Errorf("relocateGPT() backup entries do not match the primary entries")
+	}
+
+	// Devices too small for the partitions and the backup are refused.
+	if err := relocateGPT(f, f, 130*tableSector); !errors.Is(err, errDevice) {
+		t.Errorf("relocateGPT() of a small device got: %v, want: %v", err, errDevice)
+	}
+	// Devices without a GPT are left unchanged.
+	if err := relocateGPT(bytes.NewReader(mbrDisk([2]uint32{1, 1})), nil, 300*tableSector); err != nil {
+		t.Errorf("relocateGPT() of an MBR disk returned %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/diskimage"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/trace"
+)
+
+// sparseBlockSize is the size of the blocks that are inspected for zeros when
+// writing sparsely. Only blocks that are entirely zero are skipped.
+const sparseBlockSize = copyBufferSize
+
+// rawDevice represents a block device opened for writing.
+type rawDevice interface {
+	io.WriteSeeker
+	Close() error
+	Sync() error
+}
+
+// openRawDevice opens the block device with the provided identifier for
+// writing.
+func openRawDevice(id string) (rawDevice, error) {
+	return os.OpenFile(devicePath(id), os.O_WRONLY, 0)
+}
+
+// DevicePath returns the path of the block device with the provided
+// identifier, such as a Windows disk number or a Linux device name.
+func DevicePath(id string) string {
+	return devicePath(id)
+}
+
+// rawImage provides the raw disk contents of an image file.
+type rawImage struct {
+	io.Reader
+	io.Closer
+	size int64
+	// compression is the extension of the format that the image is compressed
+	// with, such as ".gz", or empty for uncompressed images. The contents of
+	// compressed images are only available through decompress, and size is
+	// that of the compressed file.
+	compression string
+}
+
+// openRawImage opens the image at path for raw writing. Virtual disk images
+// are read through diskimage, so that the contents of the virtual disk are
+// written rather than the image file itself.
+func openRawImage(path string) (*rawImage, error) {
+	switch imagefile.Ext(path) {
+	case ".vhd", ".vhdx", ".qcow2":
+		img, err := diskimage.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("diskimage.Open(%q) returned %v: %w", path, err, errFile)
+		}
+		return &rawImage{Reader: io.NewSectionReader(img, 0, img.Size()), Closer: img, size: img.Size()}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%q) returned %v: %w", path, err, errPath)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Stat(%q) returned %v: %w", path, err, errPath)
+	}
+	img := &rawImage{Reader: f, Closer: f, size: info.Size()}
+	img.compression = imagefile.Compression(path)
+	return img, nil
+}
+
+// provisionRaw writes a raw image to a device, byte for byte. Virtual disk
+// images are expanded to their raw contents as they are written, and
+// compressed images are decompressed as they are streamed. When configured,
+// the device is trimmed first and zero-filled regions of the image are zeroed
+// rather than written.
+func (i *Installer) provisionRaw(d Device) (err error) {
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	img, err := openRawImage(path)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+	if uint64(img.size) > d.Size() {
+		return fmt.Errorf("%w: image %q (%s) is larger than %q (%s)", errProvision, path, humanize.Bytes(uint64(img.size)), d.FriendlyName(), humanize.Bytes(d.Size()))
+	}
+
+	deck.InfofA("Opening %q for raw writing.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	dev, err := openDevice(d.Identifier())
+	if err != nil {
+		return fmt.Errorf("openDevice(%q) returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	defer func() {
+		if err2 := dev.Close(); err2 != nil && err == nil {
+			err = fmt.Errorf("Close() for %q returned %v: %w", d.Identifier(), err2, errDevice)
+		}
+	}()
+
+	if i.config.Trim() {
+		deck.InfofA("Discarding the contents of %q.", d.FriendlyName()).With(debug.V(debug.Storage, 2)).Go()
+		if err := discard(dev, d.Size()); err != nil {
+			deck.Warningf("Discard of %q failed: %v", d.FriendlyName(), err)
+		}
+	}
+	// Discarded blocks are not guaranteed to read back as zeros, so regions that
+	// are skipped are explicitly zeroed. Only those regions are zeroed, as
+	// devices that cannot offload zeroing have the zeros written by the kernel.
+	var zero func(off, n int64) error
+	sparse := i.config.SparseWrite()
+	if sparse {
+		zero = func(off, n int64) error { return zeroOut(dev, off, n) }
+	}
+
+	deck.InfofA("Writing %q to %q (sparse: %t).", path, d.FriendlyName(), sparse).With(debug.V(debug.Storage, 2)).Go()
+	// Progress is measured against the image file, as the decompressed size of
+	// compressed images is not known up front.
+	var r io.Reader = console.ProgressReader(img, "\nWrite of "+i.config.ImageFile(), img.size)
+	if img.compression != "" {
+		dr, err := decompress(r, img.compression)
+		if err != nil {
+			return fmt.Errorf("decompress(%q) returned %v: %w", path, err, errFile)
+		}
+		defer dr.Close()
+		r = dr
+	}
+	span := trace.Begin("copy", trace.Attr("image", i.config.ImageFile()))
+	written, skipped, err := writeRaw(dev, r, zero)
+	span.End(err)
+	if err != nil {
+		return fmt.Errorf("writeRaw(%q) returned %v: %w", d.Identifier(), err, errIO)
+	}
+	if err := dev.Sync(); err != nil {
+		return fmt.Errorf("Sync() for %q returned %v: %w", d.Identifier(), err, errIO)
+	}
+	console.Printf("Wrote %s, skipped %s of zeros.", humanize.Bytes(uint64(written)), humanize.Bytes(uint64(skipped)))
+	return nil
+}
+
+// writeRaw copies src to dst. When zero is provided, runs of blocks that
+// contain only zeros are zeroed using it and skipped by seeking past them,
+// rather than written. If zeroing fails, the run and the remainder of src are
+// written in full. It returns the number of bytes written and skipped.
+func writeRaw(dst io.WriteSeeker, src io.Reader, zero func(off, n int64) error) (written, skipped int64, err error) {
+	buf := bufPool.Get().(*[]byte)
+	defer bufPool.Put(buf)
+	zeros := make([]byte, sparseBlockSize)
+	// off is the offset of the next block, and run the length of the zero
+	// blocks before it that have not been zeroed or written yet.
+	var off, run int64
+	flush := func() error {
+		if run == 0 {
+			return nil
+		}
+		n := run
+		run = 0
+		if zero != nil {
+			zerr := zero(off-n, n)
+			if zerr == nil {
+				if _, err := dst.Seek(n, io.SeekCurrent); err != nil {
+					return fmt.Errorf("seek: %v", err)
+				}
+				skipped += n
+				return nil
+			}
+			deck.Warningf("Zeroing %d bytes at offset %d failed, writing the remainder of the image in full: %v", n, off-n, zerr)
+			zero = nil
+		}
+		for n > 0 {
+			chunk := int64(len(zeros))
+			if n < chunk {
+				chunk = n
+			}
+			if _, err := dst.Write(zeros[:chunk]); err != nil {
+				return fmt.Errorf("write: %v", err)
+			}
+			written += chunk
+			n -= chunk
+		}
+		return nil
+	}
+	// last is the length of the final block if it contains only zeros, which
+	// is written so that the destination has the full length of the source.
+	last := 0
+	for {
+		n, rerr := io.ReadFull(src, (*buf)[:sparseBlockSize])
+		if n > 0 {
+			block := (*buf)[:n]
+			if zero != nil && bytes.Equal(block, zeros[:n]) {
+				run += int64(n)
+				last = n
+			} else {
+				if err := flush(); err != nil {
+					return written, skipped, err
+				}
+				if _, err := dst.Write(block); err != nil {
+					return written, skipped, fmt.Errorf("write: %v", err)
+				}
+				written += int64(n)
+				last = 0
+			}
+			off += int64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return written, skipped, fmt.Errorf("read: %v", rerr)
+		}
+	}
+	if last > 0 {
+		run -= int64(last)
+		off -= int64(last)
+		if err := flush(); err != nil {
+			return written, skipped, err
+		}
+		if _, err := dst.Write(zeros[:last]); err != nil {
+			return written, skipped, fmt.Errorf("write: %v", err)
+		}
+		written += int64(last)
+	}
+	return written, skipped, nil
+}
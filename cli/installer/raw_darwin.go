@@ -0,0 +1,34 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "fmt"
+
+// devicePath returns the path of the raw device with the provided identifier,
+// such as 'disk2'. The raw device avoids the buffer cache and is
+// significantly faster to write to.
+func devicePath(id string) string {
+	return "/dev/r" + id
+}
+
+// discardDevice is not supported on darwin.
+func discardDevice(dev rawDevice, size uint64) error {
+	return fmt.Errorf("discard on darwin: %w", errUnsupported)
+}
+
+// zeroRange is not supported on darwin.
+func zeroRange(dev rawDevice, off, n int64) error {
+	return fmt.Errorf("zeroing on darwin: %w", errUnsupported)
+}
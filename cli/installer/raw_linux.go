@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// blkDiscard is the BLKDISCARD ioctl from linux/fs.h.
+	blkDiscard = 0x1277
+	// blkZeroOut is the BLKZEROOUT ioctl from linux/fs.h.
+	blkZeroOut = 0x127f
+)
+
+// devicePath returns the path of the block device with the provided
+// identifier, such as 'sdb'.
+func devicePath(id string) string {
+	return "/dev/" + id
+}
+
+// discardDevice discards the first size bytes of a block device, which is
+// opened for writing.
+func discardDevice(dev rawDevice, size uint64) error {
+	f, ok := dev.(interface{ Fd() uintptr })
+	if !ok {
+		return fmt.Errorf("%T is not a file: %w", dev, errUnsupported)
+	}
+	r := [2]uint64{0, size}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blkDiscard, uintptr(unsafe.Pointer(&r))); errno != 0 {
+		return fmt.Errorf("BLKDISCARD returned %v", errno)
+	}
+	return nil
+}
+
+// zeroRange zeroes n bytes at offset off of a block device, which is opened
+// for writing. Unlike a discard, the zeroed range is guaranteed to read back as
+// zeros. The kernel offloads the zeroing to the device where it is able to,
+// and otherwise writes the zeros itself. The range must be aligned to the
+// logical sector size of the device.
+func zeroRange(dev rawDevice, off, n int64) error {
+	f, ok := dev.(interface{ Fd() uintptr })
+	if !ok {
+		return fmt.Errorf("%T is not a file: %w", dev, errUnsupported)
+	}
+	r := [2]uint64{uint64(off), uint64(n)}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), blkZeroOut, uintptr(unsafe.Pointer(&r))); errno != 0 {
+		return fmt.Errorf("BLKZEROOUT returned %v", errno)
+	}
+	return nil
+}
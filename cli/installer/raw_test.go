@@ -0,0 +1,269 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/fresnel/cli/units"
+)
+
+// fakeRawDevice is a rawDevice backed by a temporary file.
+type fakeRawDevice struct {
+	*os.File
+	closeErr error
+}
+
+func (f *fakeRawDevice) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	return f.closeErr
+}
+
+// sparseImage returns image contents with zero-filled regions at the start,
+// middle and end.
+func sparseImage() []byte {
+	var img []byte
+	img = append(img, make([]byte, sparseBlockSize)...)
+	img = append(img, bytes.Repeat([]byte("fresnel"), sparseBlockSize/7)...)
+	img = append(img, make([]byte, 2*sparseBlockSize)...)
+	img = append(img, []byte("end")...)
+	img = append(img, make([]byte, sparseBlockSize)...)
+	return img
+}
+
+func TestWriteRaw(t *testing.T) {
+	img := sparseImage()
+	tests := []struct {
+		desc        string
+		zeroErr     error
+		sparse      bool
+		wantSkipped bool
+	}{
+		{
+			desc:        "full write",
+			sparse:      false,
+			wantSkipped: false,
+		},
+		{
+			desc:        "sparse write",
+			sparse:      true,
+			wantSkipped: true,
+		},
+		{
+			desc:        "zeroing failure writes zeros",
+			sparse:      true,
+			zeroErr:     errUnsupported,
+			wantSkipped: false,
+		},
+	}
+	for _, tt := range tests {
+		f, err := ioutil.TempFile("", "")
+		if err != nil {
+			t.Fatalf(`ioutil.TempFile("", "") returned %v`, err)
+		}
+		var zero func(off, n int64) error
+		var zeroed [][2]int64
+		if tt.sparse {
+			zeroErr := tt.zeroErr
+			zero = func(off, n int64) error {
+				zeroed = append(zeroed, [2]int64{off, n})
+				return zeroErr
+			}
+		}
+		written, skipped, err := writeRaw(f, readerOnly{bytes.NewReader(img)}, zero)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: writeRaw() returned %v", tt.desc, err)
+		}
+		if written+skipped != int64(len(img)) {
+			t.Errorf("%s: writeRaw() wrote %d and skipped %d bytes, want: %d total", tt.desc, written, skipped, len(img))
+		}
+		if (skipped > 0) != tt.wantSkipped {
+			t.Errorf("%s: writeRaw() skipped %d bytes, want skipped: %t", tt.desc, skipped, tt.wantSkipped)
+		}
+		// Only the skipped ranges are zeroed, each zero run at once, and
+		// zeroing is not attempted again once it fails.
+		var total int64
+		for _, z := range zeroed {
+			if z[0] < 0 || z[0]+z[1] > int64(len(img)) || !bytes.Equal(img[z[0]:z[0]+z[1]], make([]byte, z[1])) {
+				t.Errorf("%s: writeRaw() zeroed %d bytes at %d, which are not zeros in the image", tt.desc, z[1], z[0])
+			}
+			total += z[1]
+		}
+		if tt.zeroErr == nil && total != skipped {
+			t.Errorf("%s: writeRaw() zeroed %d bytes, want: %d skipped bytes", tt.desc, total, skipped)
+		}
+		if tt.zeroErr != nil && len(zeroed) != 1 {
+			t.Errorf("%s: writeRaw() zeroed %d times, want: 1", tt.desc, len(zeroed))
+		}
+		got, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(%q) returned %v", tt.desc, f.Name(), err)
+		}
+		if !bytes.Equal(got, img) {
+			t.Errorf("%s: writeRaw() destination (%d bytes) does not match image (%d bytes)", tt.desc, len(got), len(img))
+		}
+		os.Remove(f.Name())
+	}
+}
+
+func TestProvisionRaw(t *testing.T) {
+	fakeCache, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(fakeCache)
+	img := sparseImage()
+	if err := ioutil.WriteFile(filepath.Join(fakeCache, "fake.img"), img, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	// A raw image is not a valid virtual disk.
+	if err := ioutil.WriteFile(filepath.Join(fakeCache, "fake.vhdx"), img, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(img); err != nil {
+		t.Fatalf("Write() for gzip returned %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() for gzip returned %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fakeCache, "fake.img.gz"), gz.Bytes(), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fakeCache, "truncated.img.gz"), gz.Bytes()[:gz.Len()/2], 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() returned %v", err)
+	}
+	dest := filepath.Join(fakeCache, "device")
+
+	tests := []struct {
+		desc       string
+		config     *fakeConfig
+		device     *fakeDevice
+		open       func(string) (rawDevice, error)
+		zeroOut    func(rawDevice, int64, int64) error
+		want       error
+		wantDevice bool
+	}{
+		{
+			desc:   "missing image",
+			config: &fakeConfig{imageFile: "missing.img"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			want:   errPath,
+		},
+		{
+			desc:   "invalid virtual disk",
+			config: &fakeConfig{imageFile: "fake.vhdx"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			want:   errFile,
+		},
+		{
+			desc:   "device too small",
+			config: &fakeConfig{imageFile: "fake.img"},
+			device: &fakeDevice{size: 1024},
+			want:   errProvision,
+		},
+		{
+			desc:   "open error",
+			config: &fakeConfig{imageFile: "fake.img"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open:   func(string) (rawDevice, error) { return nil, errors.New("error") },
+			want:   errDevice,
+		},
+		{
+			desc:   "close error",
+			config: &fakeConfig{imageFile: "fake.img"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open: func(string) (rawDevice, error) {
+				f, err := os.Create(dest)
+				return &fakeRawDevice{File: f, closeErr: errors.New("error")}, err
+			},
+			want: errDevice,
+		},
+		{
+			desc:   "zeroing failure falls back to full write",
+			config: &fakeConfig{imageFile: "fake.img", sparse: true},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open: func(string) (rawDevice, error) {
+				f, err := os.Create(dest)
+				return &fakeRawDevice{File: f}, err
+			},
+			zeroOut:    func(rawDevice, int64, int64) error { return errUnsupported },
+			want:       nil,
+			wantDevice: true,
+		},
+		{
+			desc:   "gzip image",
+			config: &fakeConfig{imageFile: "fake.img.gz"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open: func(string) (rawDevice, error) {
+				f, err := os.Create(dest)
+				return &fakeRawDevice{File: f}, err
+			},
+			want:       nil,
+			wantDevice: true,
+		},
+		{
+			desc:   "truncated gzip image",
+			config: &fakeConfig{imageFile: "truncated.img.gz"},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open: func(string) (rawDevice, error) {
+				f, err := os.Create(dest)
+				return &fakeRawDevice{File: f}, err
+			},
+			want: errIO,
+		},
+		{
+			desc:   "sparse success",
+			config: &fakeConfig{imageFile: "fake.img", sparse: true},
+			device: &fakeDevice{size: uint64(units.GB)},
+			open: func(string) (rawDevice, error) {
+				f, err := os.Create(dest)
+				return &fakeRawDevice{File: f}, err
+			},
+			zeroOut:    func(rawDevice, int64, int64) error { return nil },
+			want:       nil,
+			wantDevice: true,
+		},
+	}
+	for _, tt := range tests {
+		openDevice = tt.open
+		zeroOut = tt.zeroOut
+		i := &Installer{cache: fakeCache, config: tt.config}
+		got := i.provisionRaw(tt.device)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: provisionRaw() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if !tt.wantDevice {
+			continue
+		}
+		contents, err := ioutil.ReadFile(dest)
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(%q) returned %v", tt.desc, dest, err)
+		}
+		if !bytes.Equal(contents, img) {
+			t.Errorf("%s: provisionRaw() device contents do not match the image", tt.desc)
+		}
+	}
+}
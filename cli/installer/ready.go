@@ -0,0 +1,104 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+const (
+	// DefaultReadyTimeout is a reasonable time to wait for newly inserted
+	// devices to settle.
+	DefaultReadyTimeout = 30 * time.Second
+)
+
+var (
+	// readyInterval is the time between readiness checks.
+	readyInterval = 500 * time.Millisecond
+	// readyStable is how long partitions must be detected on a device without
+	// its size or path changing before it is considered ready. Devices that
+	// are still settling can detect successfully while reporting no size, or
+	// briefly disappear and return under another path.
+	readyStable = 3 * time.Second
+)
+
+// Detector represents a device whose partitions can be detected, such as
+// storage.Device.
+type Detector interface {
+	DetectPartitions(bool) error
+	FriendlyName() string
+	Identifier() string
+	Size() uint64
+}
+
+// readyState is what is expected to remain unchanged on a device that is
+// ready.
+type readyState struct {
+	path string
+	size uint64
+}
+
+// WaitReady waits for a device to become ready for provisioning. Newly
+// inserted devices often report transient errors while drivers settle and the
+// operating system mounts them, so partitions are detected repeatedly until
+// detection has succeeded with the same size and path for readyStable, or for
+// timeout when it is shorter. An error is returned if the device is not ready
+// within timeout. A timeout of zero checks the device once.
+func WaitReady(d Detector, timeout time.Duration) error {
+	stable := readyStable
+	if timeout < stable {
+		stable = timeout
+	}
+	deadline := time.Now().Add(timeout)
+	var since time.Time
+	var last readyState
+	for {
+		checked := time.Now()
+		err := d.DetectPartitions(false)
+		if err == nil && d.Size() == 0 {
+			err = errors.New("the device reports a size of zero")
+		}
+		if err == nil {
+			cur := readyState{path: d.Identifier(), size: d.Size()}
+			switch {
+			case since.IsZero():
+				since, last = checked, cur
+			case cur != last:
+				err = fmt.Errorf("the device changed from %q (%d bytes) to %q (%d bytes)", last.path, last.size, cur.path, cur.size)
+				since, last = checked, cur
+			}
+			if err == nil && time.Since(since) >= stable {
+				return nil
+			}
+		} else {
+			since = time.Time{}
+		}
+		if err != nil {
+			deck.InfofA("Device %q is not ready yet: %v", d.FriendlyName(), err).With(debug.V(debug.Storage, 2)).Go()
+		}
+		if !time.Now().Before(deadline) {
+			if err == nil {
+				err = fmt.Errorf("the device was only unchanged for %v", time.Since(since).Round(time.Millisecond))
+			}
+			return fmt.Errorf("%w: %q was not ready after %v, last error: %v", errDevice, d.FriendlyName(), timeout, err)
+		}
+		time.Sleep(readyInterval)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// flakyDevice fails partition detection a set number of times before
+// succeeding, and changes its path each time it is detected until a set
+// number of changes is reached.
+type flakyDevice struct {
+	failures int
+	changes  int
+	size     uint64
+	calls    int
+}
+
+func (f *flakyDevice) DetectPartitions(bool) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func (f *flakyDevice) FriendlyName() string {
+	return "flaky"
+}
+
+func (f *flakyDevice) Identifier() string {
+	if f.calls <= f.changes {
+		return fmt.Sprintf("sd%d", f.calls)
+	}
+	return "sdd"
+}
+
+func (f *flakyDevice) Size() uint64 {
+	return f.size
+}
+
+func TestWaitReady(t *testing.T) {
+	readyInterval = time.Millisecond
+	readyStable = 20 * time.Millisecond
+	defer func() {
+		readyInterval = 500 * time.Millisecond
+		readyStable = 3 * time.Second
+	}()
+	tests := []struct {
+		desc     string
+		failures int
+		changes  int
+		size     uint64
+		timeout  time.Duration
+		minCalls int
+		maxCalls int
+		want     error
+	}{
+		{
+			desc:     "ready immediately",
+			size:     8 << 30,
+			timeout:  time.Second,
+			minCalls: 2,
+			want:     nil,
+		},
+		{
+			desc:     "ready after transient errors",
+			failures: 3,
+			size:     8 << 30,
+			timeout:  time.Second,
+			minCalls: 3 + 2,
+			want:     nil,
+		},
+		{
+			desc:     "ready after path changes",
+			changes:  3,
+			size:     8 << 30,
+			timeout:  time.Second,
+			minCalls: 3 + 2,
+			want:     nil,
+		},
+		{
+			desc:     "path never stable",
+			changes:  1 << 30,
+			size:     8 << 30,
+			timeout:  50 * time.Millisecond,
+			minCalls: 2,
+			want:     errDevice,
+		},
+		{
+			desc:     "no size",
+			timeout:  50 * time.Millisecond,
+			minCalls: 2,
+			want:     errDevice,
+		},
+		{
+			desc:     "never ready",
+			failures: 1 << 30,
+			size:     8 << 30,
+			timeout:  10 * time.Millisecond,
+			want:     errDevice,
+		},
+		{
+			desc:     "timeout shorter than stable",
+			size:     8 << 30,
+			timeout:  5 * time.Millisecond,
+			minCalls: 2,
+			want:     nil,
+		},
+		{
+			desc:     "no timeout",
+			size:     8 << 30,
+			timeout:  0,
+			minCalls: 1,
+			maxCalls: 1,
+			want:     nil,
+		},
+		{
+			desc:     "no timeout not ready",
+			failures: 1,
+			size:     8 << 30,
+			timeout:  0,
+			minCalls: 1,
+			maxCalls: 1,
+			want:     errDevice,
+		},
+	}
+	for _, tt := range tests {
+		d := &flakyDevice{failures: tt.failures, changes: tt.changes, size: tt.size}
+		if got := WaitReady(d, tt.timeout); !errors.Is(got, tt.want) {
+			t.Errorf("%s: WaitReady() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if d.calls < tt.minCalls || (tt.maxCalls > 0 && d.calls > tt.maxCalls) {
+			t.Errorf("%s: WaitReady() detected partitions %d times, want: %d to %d", tt.desc, d.calls, tt.minCalls, tt.maxCalls)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+const (
+	// releaseAttempts is the number of times a device is dismounted or ejected
+	// before giving up.
+	releaseAttempts = 5
+	// releaseMaxBackoff limits the time between attempts.
+	releaseMaxBackoff = 8 * time.Second
+)
+
+var (
+	// Dependency injections for testing.
+	releaseBackoff = time.Second
+	sleep          = time.Sleep
+	findHolders    = holders
+)
+
+// holder is a process that holds files open on a device.
+type holder struct {
+	pid  int
+	name string
+}
+
+// String describes the process, such as 'indexer (pid 1234)'.
+func (h holder) String() string {
+	return fmt.Sprintf("%s (pid %d)", h.name, h.pid)
+}
+
+// release calls f, which dismounts or ejects d, until it succeeds or
+// releaseAttempts is reached. Indexers and antivirus software briefly hold
+// newly written volumes open, so the time between attempts doubles each time.
+// When all attempts fail, the processes holding the device are described in
+// the error where the platform allows them to be found.
+func release(d Device, op string, f func() error) error {
+	backoff := releaseBackoff
+	var err error
+	for attempt := 1; attempt <= releaseAttempts; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		if attempt == releaseAttempts {
+			break
+		}
+		deck.Warningf("%s of %q failed (attempt %d of %d), retrying in %v: %v", op, d.Identifier(), attempt, releaseAttempts, backoff, err)
+		sleep(backoff)
+		if backoff *= 2; backoff > releaseMaxBackoff {
+			backoff = releaseMaxBackoff
+		}
+	}
+	return fmt.Errorf("%s failed after %d attempts: %v%s", op, releaseAttempts, err, describeHolders(d))
+}
+
+// describeHolders returns a sentence naming the processes that hold d open,
+// or an empty string when they cannot be found.
+func describeHolders(d Device) string {
+	hs, err := findHolders(d)
+	if err != nil {
+		deck.InfofA("Processes holding %q could not be found: %v", d.Identifier(), err).With(debug.V(debug.Storage, 2)).Go()
+		return ""
+	}
+	if len(hs) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(hs))
+	for _, h := range hs {
+		names = append(names, h.String())
+	}
+	return fmt.Sprintf("; %q is in use by %s, close them and try again", d.Identifier(), strings.Join(names, ", "))
+}
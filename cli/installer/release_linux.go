@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is the mount point of procfs, replaced in tests.
+var procRoot = "/proc"
+
+// holders returns the processes with open files, or working directories,
+// on the mounted partitions of d, found from procfs.
+func holders(d Device) ([]holder, error) {
+	parts, err := listPartitions(d.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for _, p := range parts {
+		if p.mount != "" {
+			mounts = append(mounts, p.mount)
+		}
+	}
+	return procHolders(procRoot, mounts)
+}
+
+// procHolders returns the processes under root with open files or working
+// directories beneath any of mounts. Processes that cannot be inspected, such
+// as those of other users without elevation, are skipped.
+func procHolders(root string, mounts []string) ([]holder, error) {
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var hs []holder
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		links := []string{filepath.Join(dir, "cwd")}
+		if fds, err := ioutil.ReadDir(filepath.Join(dir, "fd")); err == nil {
+			for _, fd := range fds {
+				links = append(links, filepath.Join(dir, "fd", fd.Name()))
+			}
+		}
+		if !anyBeneath(links, mounts) {
+			continue
+		}
+		name := "unknown"
+		if comm, err := ioutil.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			name = strings.TrimSpace(string(comm))
+		}
+		hs = append(hs, holder{pid: pid, name: name})
+	}
+	return hs, nil
+}
+
+// anyBeneath reports whether any of the symbolic links resolves to a path
+// beneath one of mounts.
+func anyBeneath(links, mounts []string) bool {
+	for _, l := range links {
+		target, err := os.Readlink(l)
+		if err != nil {
+			continue
+		}
+		for _, m := range mounts {
+			if target == m || strings.HasPrefix(target, strings.TrimSuffix(m, "/")+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
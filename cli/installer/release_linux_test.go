@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProcHolders(t *testing.T) {
+	root := t.TempDir()
+	// Each process is described by its name and the targets of its links.
+	procs := map[string]struct {
+		comm  string
+		links map[string]string
+	}{
+		"100": {comm: "indexer", links: map[string]string{"cwd": "/", "fd/3": "/media/usb/sources/install.wim"}},
+		"200": {comm: "bash", links: map[string]string{"cwd": "/media/usb"}},
+		"300": {comm: "editor", links: map[string]string{"cwd": "/home/user", "fd/4": "/media/usb2/notes.txt"}},
+	}
+	for pid, p := range procs {
+		dir := filepath.Join(root, pid)
+		if err := os.MkdirAll(filepath.Join(dir, "fd"), 0755); err != nil {
+			t.Fatalf("os.MkdirAll() returned %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "comm"), []byte(p.comm+"\n"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+		for name, target := range p.links {
+			if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+				t.Fatalf("os.Symlink(%q) returned %v", target, err)
+			}
+		}
+	}
+	// Entries that are not processes are ignored.
+	if err := os.Mkdir(filepath.Join(root, "sys"), 0755); err != nil {
+		t.Fatalf("os.Mkdir() returned %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		mounts []string
+		want   []holder
+	}{
+		{
+			desc: "no mounts",
+		},
+		{
+			desc:   "held",
+			mounts: []string{"/media/usb"},
+			want:   []holder{{pid: 100, name: "indexer"}, {pid: 200, name: "bash"}},
+		},
+		{
+			desc:   "not held",
+			mounts: []string{"/media/other"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := procHolders(root, tt.mounts)
+		if err != nil {
+			t.Errorf("%s: procHolders() returned %v", tt.desc, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: procHolders() got: %+v, want: %+v", tt.desc, got, tt.want)
+		}
+	}
+}
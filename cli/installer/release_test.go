@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelease(t *testing.T) {
+	tests := []struct {
+		desc        string
+		failures    int // The number of calls that fail before one succeeds.
+		holders     []holder
+		holdersErr  error
+		wantCalls   int
+		wantSleeps  []time.Duration
+		wantErr     bool
+		wantMessage string
+	}{
+		{
+			desc:      "success",
+			wantCalls: 1,
+		},
+		{
+			desc:       "transient failure",
+			failures:   2,
+			wantCalls:  3,
+			wantSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			desc:        "persistent failure with holders",
+			failures:    releaseAttempts,
+			holders:     []holder{{pid: 42, name: "indexer"}, {pid: 7, name: "scanner"}},
+			wantCalls:   releaseAttempts,
+			wantSleeps:  []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+			wantErr:     true,
+			wantMessage: "indexer (pid 42), scanner (pid 7)",
+		},
+		{
+			desc:        "persistent failure without holders",
+			failures:    releaseAttempts,
+			holdersErr:  errUnsupported,
+			wantCalls:   releaseAttempts,
+			wantSleeps:  []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+			wantErr:     true,
+			wantMessage: "failed after 5 attempts",
+		},
+	}
+	defer func() {
+		sleep = time.Sleep
+		findHolders = holders
+	}()
+	for _, tt := range tests {
+		var sleeps []time.Duration
+		sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+		findHolders = func(Device) ([]holder, error) { return tt.holders, tt.holdersErr }
+		calls := 0
+		f := func() error {
+			calls++
+			if calls <= tt.failures {
+				return errors.New("device busy")
+			}
+			return nil
+		}
+		err := release(&fakeDevice{id: "sdz"}, "Dismount", f)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: release() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), tt.wantMessage) {
+			t.Errorf("%s: release() returned %q, want it to contain %q", tt.desc, err, tt.wantMessage)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: release() made %d calls, want %d", tt.desc, calls, tt.wantCalls)
+		}
+		if !reflect.DeepEqual(sleeps, tt.wantSleeps) {
+			t.Errorf("%s: release() slept %v, want %v", tt.desc, sleeps, tt.wantSleeps)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/cli/version"
+	"github.com/google/fresnel/models"
+)
+
+// bulkSeedPath is the path of the bulk seed endpoint, relative to the seed
+// endpoint.
+const bulkSeedPath = "/bulk"
+
+// Reseed replaces the seeds of devices that were duplicated byte for byte
+// from the same provisioned master, such as by Receive or Restore, which
+// otherwise all hold the seed of the master. The seed of the first device
+// records the hash it was issued for, and a distinct seed is obtained for
+// every device with a single bulk seed request for that hash. The seeds in a
+// seed bundle are replaced in the same way. Nothing is done when the
+// distribution does not use seeds or the image is not seeded.
+func (i *Installer) Reseed(devices []Device) error {
+	if i.config == nil {
+		return errConfig
+	}
+	if i.config.SeedServer() == "" || !i.config.SeedRequired() || imagefile.Ext(i.config.ImageFile()) != ".iso" || len(devices) == 0 {
+		deck.InfofA("%s %s does not use seeds, the seeds of %d devices are not replaced.", i.config.Distro(), i.config.Track(), len(devices)).With(debug.V(debug.Seed, 1)).Go()
+		return nil
+	}
+	var dirs []string
+	for _, d := range devices {
+		dir, err := i.seedDir(d)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+	}
+	primary := models.SeedFile{}
+	if err := readJSON(filepath.Join(dirs[0], seedDestFile), &primary); err != nil {
+		return fmt.Errorf("reading the seed of %q: %v: %w", devices[0].FriendlyName(), err, errFile)
+	}
+	var bundle *models.SeedBundle
+	if _, err := os.Stat(filepath.Join(dirs[0], bundleDestFile)); err == nil {
+		bundle = &models.SeedBundle{}
+		if err := readJSON(filepath.Join(dirs[0], bundleDestFile), bundle); err != nil {
+			return fmt.Errorf("reading the seed bundle of %q: %v: %w", devices[0].FriendlyName(), err, errFile)
+		}
+	}
+
+	u, err := seedUser(i.config)
+	if err != nil {
+		return fmt.Errorf("seedUser() returned %v: %w", err, errUser)
+	}
+	url := strings.TrimSuffix(i.config.SeedServer(), "/") + bulkSeedPath
+	deck.InfofA("Connecting to bulk seed endpoint as user %q: %q.", u, url).With(debug.V(debug.Network, 2)).Go()
+	doer, err := connectAs(i.config, url, u)
+	if err != nil {
+		return fmt.Errorf("fetcher.Connect(%q) returned %v: %w", url, err, errConnect)
+	}
+	c := newClient(url, doer)
+	c.Batch = i.config.Batch()
+	c.Fields = i.config.SeedFields()
+	// Seeds are requested once for each hash, as the seed bundle also holds
+	// the seed of the primary seed file.
+	issued := make(map[string][]models.SeedFile)
+	seeds := func(sf models.SeedFile) ([]models.SeedFile, error) {
+		key := string(sf.Algorithm) + ":" + string(sf.Seed.Hash)
+		if s, ok := issued[key]; ok {
+			return s, nil
+		}
+		alg := sf.Algorithm
+		if alg == "" {
+			alg = models.HashSHA256
+		}
+		deck.InfofA("Requesting %d seeds for the %s hash %x.", len(devices), alg, sf.Seed.Hash).With(debug.V(debug.Network, 2)).Go()
+		resp, err := c.BulkSeed(sf.Seed.Hash, alg, len(devices))
+		if err != nil {
+			reportMaintenance(err)
+			return nil, fmt.Errorf("BulkSeed() returned %v: %w", err, errDownload)
+		}
+		if len(resp.Seeds) != len(devices) {
+			return nil, fmt.Errorf("BulkSeed() returned %d seeds, want %d: %w", len(resp.Seeds), len(devices), errDownload)
+		}
+		var s []models.SeedFile
+		for _, r := range resp.Seeds {
+			s = append(s, models.SeedFile{Seed: r.Seed, Signature: r.Signature, ExpiresAt: r.ExpiresAt, Algorithm: alg})
+		}
+		issued[key] = s
+		return s, nil
+	}
+	primaries, err := seeds(primary)
+	if err != nil {
+		return err
+	}
+	var bundles []*models.SeedBundle
+	if bundle != nil {
+		for range devices {
+			bundles = append(bundles, &models.SeedBundle{})
+		}
+		for _, b := range bundle.Seeds {
+			s, err := seeds(b.SeedFile)
+			if err != nil {
+				return err
+			}
+			for n := range devices {
+				bundles[n].Seeds = append(bundles[n].Seeds, models.BundledSeed{File: b.File, SeedFile: s[n]})
+			}
+		}
+	}
+
+	i.expiry = primaries[0].ExpiresAt
+	for n, d := range devices {
+		content, err := json.MarshalIndent(primaries[n], "", "")
+		if err != nil {
+			return fmt.Errorf("json.MarshalIndent(%v) returned: %v", primaries[n], err)
+		}
+		s := filepath.Join(dirs[n], seedDestFile)
+		deck.InfofA("Replacing the seed of %q: %q.", d.Identifier(), s).With(debug.V(debug.Seed, 2)).Go()
+		// Permissions = owner:read/write, group:read"
+		if err := ioutil.WriteFile(s, content, 0644); err != nil {
+			return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", s, err, errIO)
+		}
+		if bundle != nil {
+			if err := writeBundle(dirs[n], bundles[n]); err != nil {
+				return err
+			}
+		}
+		if err := i.markSeedExpiry(dirs[n], primaries[n].ExpiresAt); err != nil {
+			return fmt.Errorf("markSeedExpiry() returned %v", err)
+		}
+		if e := primaries[n].ExpiresAt; !e.IsZero() && e.Before(i.expiry) {
+			i.expiry = e
+		}
+	}
+	checkSeedExpiry(i.expiry, i.config.SeedShelfLife())
+	console.Printf("Replaced the seeds of %d devices duplicated from the seed issued to %s.", len(devices), primary.Seed.Username)
+	return nil
+}
+
+// markSeedExpiry records expiry in the marker in dir. A marker duplicated from
+// other media holds the expiry of the seed that was replaced, so it is updated
+// in place, keeping the batch that the media was provisioned in. A marker is
+// written when there is none and the expiry is known.
+func (i *Installer) markSeedExpiry(dir string, expiry time.Time) error {
+	marker := models.BatchMarker{}
+	if err := readJSON(filepath.Join(dir, batchDestFile), &marker); err != nil {
+		if expiry.IsZero() {
+			return nil
+		}
+		marker = models.BatchMarker{
+			Distro:  i.config.Distro(),
+			Track:   i.config.Track(),
+			Version: version.Version,
+			Created: time.Now(),
+		}
+	}
+	marker.SeedSkipped = false
+	marker.SeedExpiry = expiry
+	return writeMarker(dir, marker)
+}
+
+// seedDir mounts the partition of d that holds its seed, and returns the
+// directory that the seed is written to. The partitions of d are detected
+// again first, as it was written to without the knowledge of the OS.
+func (i *Installer) seedDir(d Device) (string, error) {
+	deck.InfofA("Refreshing partition information for %q.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	if err := d.DetectPartitions(false); err != nil {
+		return "", fmt.Errorf("DetectPartitions() for %q returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	p, err := selectPart(d, uint64(units.GB), i.fileSystem())
+	if err != nil {
+		return "", fmt.Errorf("selectPartition() for %q returned %v: %w", d.Identifier(), err, errPartition)
+	}
+	deck.InfofA("Mounting %q to replace its seed.", p.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	if err := p.Mount(host.mountBase(i.cache)); err != nil {
+		return "", fmt.Errorf("Mount() for %q returned %v: %w", p.Identifier(), err, errMount)
+	}
+	return filepath.Join(host.root(p.MountPoint()), i.config.SeedDest()), nil
+}
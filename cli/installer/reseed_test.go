@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"github.com/google/winops/storage"
+)
+
+// fakeBulkSeedServer issues distinct seeds for bulk seed requests, and
+// retains the requests for inspection.
+type fakeBulkSeedServer struct {
+	status   models.StatusCode
+	requests []models.BulkSeedRequest
+}
+
+func (s *fakeBulkSeedServer) Do(req *http.Request) (*http.Response, error) {
+	br := models.BulkSeedRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&br); err != nil {
+		return nil, err
+	}
+	s.requests = append(s.requests, br)
+	resp := models.BulkSeedResponse{ErrorCode: s.status, Algorithm: br.Request.Algorithm}
+	if s.status == models.StatusSuccess {
+		for n := 0; n < br.Count; n++ {
+			seed := models.Seed{Hash: br.Request.Hash, Nonce: []byte(fmt.Sprintf("%d-%d", len(s.requests), n)), Username: "operator@example.com"}
+			resp.Seeds = append(resp.Seeds, models.SeedResponse{Seed: seed, Signature: []byte("signed"), ExpiresAt: time.Now().Add(time.Hour)})
+		}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestReseed(t *testing.T) {
+	defer func() {
+		connectImpersonated = impersonatedClient
+		selectPart = selectPartition
+	}()
+	master := models.SeedFile{Seed: models.Seed{Hash: []byte("boot"), Username: "user@example.com"}, Algorithm: models.HashSHA256}
+	bundle := &models.SeedBundle{Seeds: []models.BundledSeed{
+		{File: "sources/boot.wim", SeedFile: master},
+		{File: "bootmgr", SeedFile: models.SeedFile{Seed: models.Seed{Hash: []byte("bootmgr")}, Algorithm: models.HashSHA256}},
+	}}
+	tests := []struct {
+		desc         string
+		noSeedServer bool
+		noSeed       bool
+		bundle       *models.SeedBundle
+		marker       *models.BatchMarker
+		status       models.StatusCode
+		wantRequests int
+		want         error
+	}{
+		{desc: "no seed server", noSeedServer: true},
+		{desc: "missing seed", noSeed: true, want: errFile},
+		{desc: "refused", status: models.StatusBulkNotAllowed, wantRequests: 1, want: errDownload},
+		{desc: "success", wantRequests: 1},
+		{desc: "bundle", bundle: bundle, wantRequests: 2},
+		{desc: "duplicated marker", marker: &models.BatchMarker{Batch: "lab", SeedExpiry: time.Now().Add(-time.Hour)}, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		dirs := map[string]string{"sdd": t.TempDir(), "sde": t.TempDir()}
+		for _, dir := range dirs {
+			if err := os.MkdirAll(filepath.Join(dir, "seed"), 0755); err != nil {
+				t.Fatalf("os.MkdirAll() returned %v", err)
+			}
+			if !tt.noSeed {
+				writeJSON(t, filepath.Join(dir, "seed", seedDestFile), master)
+			}
+			if tt.bundle != nil {
+				writeJSON(t, filepath.Join(dir, "seed", bundleDestFile), tt.bundle)
+			}
+			if tt.marker != nil {
+				writeJSON(t, filepath.Join(dir, "seed", batchDestFile), tt.marker)
+			}
+		}
+		selectPart = func(d Device, _ uint64, _ storage.FileSystem) (partition, error) {
+			return &fakePartition{id: d.Identifier() + "1", mount: dirs[d.Identifier()]}, nil
+		}
+		server := &fakeBulkSeedServer{status: tt.status}
+		connectImpersonated = func(string, string, string) (httpDoer, error) { return server, nil }
+		seedServer := "https://seed.example.com/seed"
+		if tt.noSeedServer {
+			seedServer = ""
+		}
+		i := &Installer{config: &fakeConfig{imageFile: "fake.iso", seedDest: "seed", seedServer: seedServer, delegate: "operator@example.com", impersonate: "writer@project.iam.gserviceaccount.com"}}
+		devices := []Device{&fakeDevice{id: "sdd"}, &fakeDevice{id: "sde"}}
+		if err := i.Reseed(devices); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Reseed() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if len(server.requests) != tt.wantRequests {
+			t.Errorf("%s: Reseed() made %d bulk seed requests, want: %d", tt.desc, len(server.requests), tt.wantRequests)
+		}
+		if tt.want != nil || tt.wantRequests == 0 {
+			continue
+		}
+		nonces := make(map[string]bool)
+		for id, dir := range dirs {
+			sf := models.SeedFile{}
+			if err := readJSON(filepath.Join(dir, "seed", seedDestFile), &sf); err != nil {
+				t.Fatalf("%s: readJSON() returned %v", tt.desc, err)
+			}
+			if nonces[string(sf.Seed.Nonce)] || !bytes.Equal(sf.Seed.Hash, master.Seed.Hash) {
+				t.Errorf("%s: Reseed() wrote seed %+v to %q, want a distinct seed for %q", tt.desc, sf.Seed, id, master.Seed.Hash)
+			}
+			nonces[string(sf.Seed.Nonce)] = true
+			m := models.BatchMarker{}
+			if err := readJSON(filepath.Join(dir, "seed", batchDestFile), &m); err != nil {
+				t.Fatalf("%s: readJSON() returned %v", tt.desc, err)
+			}
+			if !m.SeedExpiry.Equal(sf.ExpiresAt) || (tt.marker != nil && m.Batch != tt.marker.Batch) {
+				t.Errorf("%s: Reseed() wrote marker %+v to %q, want the expiry %v of the new seed", tt.desc, m, id, sf.ExpiresAt)
+			}
+			if tt.bundle == nil {
+				continue
+			}
+			b := models.SeedBundle{}
+			if err := readJSON(filepath.Join(dir, "seed", bundleDestFile), &b); err != nil {
+				t.Fatalf("%s: readJSON() returned %v", tt.desc, err)
+			}
+			if len(b.Seeds) != 2 || !bytes.Equal(b.Seeds[0].Seed.Nonce, sf.Seed.Nonce) || b.Seeds[1].File != "bootmgr" || nonces[string(b.Seeds[1].Seed.Nonce)] {
+				t.Errorf("%s: Reseed() wrote bundle %+v to %q, want the seed of the device and a distinct bootmgr seed", tt.desc, b, id)
+			}
+			nonces[string(b.Seeds[1].Seed.Nonce)] = true
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// storageScope is the OAuth scope required to read objects from Cloud
+	// Storage.
+	storageScope = "https://www.googleapis.com/auth/devstorage.read_only"
+	// gcsHost serves the objects of every Cloud Storage bucket.
+	gcsHost = "storage.googleapis.com"
+	// defaultS3Region is the region of S3 buckets when none is configured in
+	// the environment.
+	defaultS3Region = "us-east-1"
+	// emptyPayloadHash is the SHA-256 hash of an empty request body, which S3
+	// requires in each signed request.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var (
+	// Dependency injections for testing.
+	getenv         = os.Getenv
+	gcsTokenSource = google.DefaultTokenSource
+
+	// retrievers create the retriever for each URL scheme that images can be
+	// obtained from. Paths without a scheme are obtained over HTTPS.
+	retrievers = map[string]func(proxy string) (retriever, error){
+		"":      newHTTPRetriever,
+		"http":  newHTTPRetriever,
+		"https": newHTTPRetriever,
+		"gs":    newGCSRetriever,
+		"s3":    newS3Retriever,
+		"file":  newFileRetriever,
+	}
+)
+
+// retriever obtains files from the servers that images are published to.
+type retriever interface {
+	// Retrieve writes the file at path to w.
+	Retrieve(path string, w io.Writer) error
+	// Probe returns the time taken to confirm that the file at path can be
+	// obtained.
+	Probe(path string) (time.Duration, error)
+}
+
+// retrieverFor returns the retriever for the scheme of path, which sends its
+// requests through proxy, or through those of the environment when it is
+// empty.
+func retrieverFor(path, proxy string) (retriever, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a valid URL: %v", errInput, imagefile.Redact(path), redactURLError(err))
+	}
+	// Signed URLs carry their own authorization, and are requested without
+	// the credentials of the retriever for their scheme.
+	if imagefile.Signed(path) {
+		return newSignedRetriever(proxy)
+	}
+	newRetriever, ok := retrievers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q cannot be obtained, the scheme %q is not supported", errUnsupported, imagefile.Redact(path), u.Scheme)
+	}
+	return newRetriever(proxy)
+}
+
+// newSignedRetriever returns a retriever for signed URLs, such as pre-signed
+// Cloud Storage or S3 URLs, which sends requests without a client
+// certificate or other credentials.
+func newSignedRetriever(proxy string) (retriever, error) {
+	transport, err := proxyTransport(nil, proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &httpRetriever{client: &http.Client{Transport: transport}}, nil
+}
+
+// redactURLError removes the query string from the URL named by err, as
+// returned by an HTTP client, so that the tokens of signed URLs are not
+// logged. Other errors are returned unchanged.
+func redactURLError(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = imagefile.Redact(ue.URL)
+	}
+	return err
+}
+
+// fetch writes the file at path to w, obtaining it with the retriever for the
+// scheme of path.
+func (i *Installer) fetch(path string, w io.Writer) error {
+	r, err := retrieverFor(path, i.config.Proxy())
+	if err != nil {
+		return err
+	}
+	return r.Retrieve(path, w)
+}
+
+// httpRetriever obtains files over HTTP. Storage services that serve objects
+// over HTTPS resolve their paths to the URL that is requested, so that they
+// report progress and detect truncated files in the same way.
+type httpRetriever struct {
+	client  httpDoer
+	resolve func(path string) (string, error)
+}
+
+// newHTTPRetriever returns a retriever for https:// paths.
+func newHTTPRetriever(proxy string) (retriever, error) {
+	client, err := connectWithCert(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher.TLSClient() returned %w: %v", errConnect, err)
+	}
+	return &httpRetriever{client: client}, nil
+}
+
+// url returns the URL that is requested for path.
+func (r *httpRetriever) url(path string) (string, error) {
+	if r.resolve == nil {
+		return path, nil
+	}
+	return r.resolve(path)
+}
+
+// Retrieve writes the file at path to w.
+func (r *httpRetriever) Retrieve(path string, w io.Writer) error {
+	u, err := r.url(path)
+	if err != nil {
+		return err
+	}
+	return downloadFile(r.client, u, w)
+}
+
+// Probe returns the time taken for a HEAD request for path to succeed.
+func (r *httpRetriever) Probe(path string) (time.Duration, error) {
+	u, err := r.url(path)
+	if err != nil {
+		return 0, err
+	}
+	return probeServer(r.client, u)
+}
+
+// newGCSRetriever returns a retriever for gs:// paths. Objects are obtained
+// from Cloud Storage with the application default credentials, such as those
+// of 'gcloud auth application-default login' or of the service account of a
+// VM, so that buckets do not need to be made public or fronted by a proxy.
+func newGCSRetriever(proxy string) (retriever, error) {
+	transport, err := proxyTransport(nil, proxy)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	ts, err := gcsTokenSource(ctx, storageScope)
+	if err != nil {
+		return nil, fmt.Errorf("google.DefaultTokenSource() returned %v: %w", err, errConnect)
+	}
+	return &httpRetriever{client: oauth2.NewClient(ctx, ts), resolve: gcsURL}, nil
+}
+
+// gcsURL returns the URL that the object at a gs:// path is served from.
+func gcsURL(path string) (string, error) {
+	bucket, object, err := bucketObject(path)
+	if err != nil {
+		return "", err
+	}
+	u := &url.URL{Scheme: "https", Host: gcsHost, Path: "/" + bucket + "/" + object}
+	return u.String(), nil
+}
+
+// newS3Retriever returns a retriever for s3:// paths. Requests are signed
+// with the credentials in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables, and are sent unsigned when none
+// are set so that public buckets can be read. Buckets are expected in the
+// region named by AWS_REGION or AWS_DEFAULT_REGION, or in us-east-1.
+func newS3Retriever(proxy string) (retriever, error) {
+	client, err := connectWithCert(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher.TLSClient() returned %w: %v", errConnect, err)
+	}
+	s := &s3Signer{
+		client: client,
+		region: defaultS3Region,
+		keyID:  getenv("AWS_ACCESS_KEY_ID"),
+		secret: getenv("AWS_SECRET_ACCESS_KEY"),
+		token:  getenv("AWS_SESSION_TOKEN"),
+	}
+	for _, env := range []string{"AWS_DEFAULT_REGION", "AWS_REGION"} {
+		if r := getenv(env); r != "" {
+			s.region = r
+		}
+	}
+	if (s.keyID == "") != (s.secret == "") {
+		return nil, fmt.Errorf("%w: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together", errConfig)
+	}
+	if s.keyID == "" {
+		deck.InfofA("No AWS credentials are set, objects are requested from S3 without signing.").With(debug.V(debug.Network, 1)).Go()
+	}
+	return &httpRetriever{client: s, resolve: s.url}, nil
+}
+
+// s3Signer is an httpDoer that signs requests for S3 objects with AWS
+// Signature Version 4.
+type s3Signer struct {
+	client httpDoer
+	region string
+	keyID  string
+	secret string
+	token  string
+}
+
+// url returns the URL that the object at an s3:// path is served from.
+// Buckets with dots in their names cannot be addressed as hosts over HTTPS,
+// so they are addressed by path instead.
+func (s *s3Signer) url(path string) (string, error) {
+	bucket, object, err := bucketObject(path)
+	if err != nil {
+		return "", err
+	}
+	host := fmt.Sprintf("s3.%s.amazonaws.com", s.region)
+	p := "/" + object
+	if strings.Contains(bucket, ".") {
+		p = "/" + bucket + p
+	} else {
+		host = bucket + "." + host
+	}
+	u := &url.URL{Scheme: "https", Host: host, Path: p, RawPath: awsEscape(p)}
+	return u.String(), nil
+}
+
+// Do signs req, when credentials are available, and sends it.
+func (s *s3Signer) Do(req *http.Request) (*http.Response, error) {
+	if s.keyID != "" {
+		s.sign(req, time.Now())
+	}
+	return s.client.Do(req)
+}
+
+// sign adds the headers that authenticate req, which has no body, as made
+// at t. The host, the range and any x-amz-* headers are signed.
+func (s *s3Signer) sign(req *http.Request, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", t.Format("20060102"), s.region)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "range" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signed,
+		emptyPayloadHash,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(sum[:])}, "\n")
+
+	key := []byte("AWS4" + s.secret)
+	for _, part := range []string{t.Format("20060102"), s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.keyID, scope, signed, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted and escaped as AWS
+// signatures require.
+func canonicalQuery(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsEscape percent-encodes every byte of s other than unreserved characters
+// and '/', as AWS signatures require.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-._~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// bucketObject returns the bucket and object named by a gs:// or s3:// path.
+func bucketObject(path string) (string, string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %q is not a valid URL: %v", errInput, path, err)
+	}
+	object := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || object == "" {
+		return "", "", fmt.Errorf("%w: %q does not name a bucket and an object", errInput, path)
+	}
+	return u.Host, object, nil
+}
+
+// fileRetriever obtains files from file:// paths, such as a network share
+// mounted on a depot workstation.
+type fileRetriever struct{}
+
+// newFileRetriever returns a retriever for file:// paths. Proxies do not
+// apply to local files.
+func newFileRetriever(string) (retriever, error) {
+	return fileRetriever{}, nil
+}
+
+// Retrieve writes the file at path to w.
+func (fileRetriever) Retrieve(path string, w io.Writer) error {
+	p, err := filePath(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("%w: os.Open(%q) returned %v", errDownload, p, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: Stat() for %q returned %v", errDownload, p, err)
+	}
+	return receive(w, f, fi.Size(), path, imagefile.Name(path))
+}
+
+// Probe returns the time taken to find the file at path.
+func (fileRetriever) Probe(path string) (time.Duration, error) {
+	p, err := filePath(path)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if _, err := os.Stat(p); err != nil {
+		return 0, fmt.Errorf("%w: os.Stat(%q) returned %v", errDownload, p, err)
+	}
+	return time.Since(start), nil
+}
+
+// filePath returns the local path named by a file:// path.
+func filePath(path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not a valid URL: %v", errInput, path, err)
+	}
+	if u.Path == "" {
+		return "", fmt.Errorf("%w: %q does not name a file", errInput, path)
+	}
+	return localPath(u)
+}
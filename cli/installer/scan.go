@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+)
+
+var (
+	// Dependency injections for testing.
+	scanFile = runScanner
+
+	// ErrScan is made public so that callers can tell files that were rejected
+	// by the scanner apart from other failures.
+	ErrScan = errors.New("file rejected by scanner")
+)
+
+// scanPayloads scans each file that is written to media from the cache with
+// the configured scanner, such as the image and the FFU configuration, so that
+// provisioning fails before a device is touched when any of them is rejected.
+// Nothing is scanned when no scanner is configured.
+func (i *Installer) scanPayloads() error {
+	command := i.config.Scanner()
+	if len(command) == 0 {
+		return nil
+	}
+	files := []string{i.config.ImageFile()}
+	if i.config.FFU() {
+		files = append(files, i.config.FFUConfFile())
+	}
+	for _, f := range files {
+		path := filepath.Join(i.cache, f)
+		console.Printf("Scanning %s with %s.", f, filepath.Base(command[0]))
+		deck.InfofA("Scanning %q with %v.", path, command).With(debug.V(debug.Storage, 2)).Go()
+		if err := scanFile(command, path); err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrScan, f, err)
+		}
+	}
+	return nil
+}
+
+// scanArgs returns the arguments of command with the path of the file being
+// scanned in place of config.ScanPath, or appended when no argument contains
+// it.
+func scanArgs(command []string, path string) []string {
+	args := make([]string, 0, len(command)+1)
+	found := false
+	for _, a := range command[1:] {
+		if strings.Contains(a, config.ScanPath) {
+			a = strings.ReplaceAll(a, config.ScanPath, path)
+			found = true
+		}
+		args = append(args, a)
+	}
+	if !found {
+		args = append(args, path)
+	}
+	return args
+}
+
+// runScanner scans the file at path with command, returning an error that
+// includes the output of the scanner when it exits with a status other than
+// zero, which scanners use to report detections.
+func runScanner(command []string, path string) error {
+	out, err := exec.Command(command[0], scanArgs(command, path)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s returned %v: %s", command[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScanArgs(t *testing.T) {
+	tests := []struct {
+		desc    string
+		command []string
+		want    []string
+	}{
+		{
+			desc:    "appended",
+			command: []string{"clamscan", "--no-summary"},
+			want:    []string{"--no-summary", "image.iso"},
+		},
+		{
+			desc:    "replaced",
+			command: []string{"MpCmdRun.exe", "-Scan", "-File", config.ScanPath, "-DisableRemediation"},
+			want:    []string{"-Scan", "-File", "image.iso", "-DisableRemediation"},
+		},
+		{
+			desc:    "within an argument",
+			command: []string{"scan", "--file=" + config.ScanPath},
+			want:    []string{"--file=image.iso"},
+		},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, scanArgs(tt.command, "image.iso")); diff != "" {
+			t.Errorf("%s: scanArgs() returned unexpected arguments (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestScanPayloads(t *testing.T) {
+	scanner := []string{"scan"}
+	tests := []struct {
+		desc    string
+		config  *fakeConfig
+		scanErr error
+		want    []string
+		wantErr error
+	}{
+		{
+			desc:   "no scanner",
+			config: &fakeConfig{imageFile: "image.iso"},
+		},
+		{
+			desc:   "image",
+			config: &fakeConfig{imageFile: "image.iso", scanner: scanner},
+			want:   []string{filepath.Join("cache", "image.iso")},
+		},
+		{
+			desc:   "ffu config",
+			config: &fakeConfig{imageFile: "image.iso", ffu: true, ffuConfFile: "sfu.yaml", scanner: scanner},
+			want:   []string{filepath.Join("cache", "image.iso"), filepath.Join("cache", "sfu.yaml")},
+		},
+		{
+			desc:    "rejected",
+			config:  &fakeConfig{imageFile: "image.iso", scanner: scanner},
+			scanErr: errors.New("Win.Test.EICAR_HDB-1 FOUND"),
+			want:    []string{filepath.Join("cache", "image.iso")},
+			wantErr: ErrScan,
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		scanFile = func(_ []string, path string) error {
+			got = append(got, path)
+			return tt.scanErr
+		}
+		i := &Installer{cache: "cache", config: tt.config}
+		if err := i.scanPayloads(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: scanPayloads() got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: scanPayloads() scanned unexpected files (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+	scanFile = runScanner
+}
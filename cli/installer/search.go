@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultSearchTimeout is a reasonable time to wait for a search for devices
+// to complete. Searches enumerate every device attached to the host, and a
+// single device that does not respond can otherwise block them indefinitely.
+const DefaultSearchTimeout = time.Minute
+
+// ErrSearchTimeout is returned when a search for devices does not complete in
+// time.
+var ErrSearchTimeout = errors.New("device search timed out")
+
+// Search calls search, returning its result unless ctx is done or timeout
+// elapses first. A timeout of zero waits for search without limit. Device
+// enumeration cannot be interrupted, so a search that does not complete in
+// time is left to finish in the background and its result is discarded.
+func Search[T any](ctx context.Context, timeout time.Duration, search func() (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	// The channel is buffered so that an abandoned search does not block.
+	done := make(chan result, 1)
+	go func() {
+		v, err := search()
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, fmt.Errorf("%w after %v, a device may not be responding", ErrSearchTimeout, timeout)
+		}
+		return zero, ctx.Err()
+	}
+}
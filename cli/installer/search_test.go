@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		timeout time.Duration
+		search  func() (int, error)
+		want    int
+		wantErr error
+	}{
+		{
+			desc:    "success",
+			ctx:     context.Background(),
+			timeout: time.Second,
+			search:  func() (int, error) { return 2, nil },
+			want:    2,
+		},
+		{
+			desc:   "no timeout",
+			ctx:    context.Background(),
+			search: func() (int, error) { return 3, nil },
+			want:   3,
+		},
+		{
+			desc:    "search error",
+			ctx:     context.Background(),
+			timeout: time.Second,
+			search:  func() (int, error) { return 0, errDevice },
+			wantErr: errDevice,
+		},
+		{
+			desc:    "timeout",
+			ctx:     context.Background(),
+			timeout: 10 * time.Millisecond,
+			search:  func() (int, error) { <-block; return 1, nil },
+			wantErr: ErrSearchTimeout,
+		},
+		{
+			desc:    "cancelled",
+			ctx:     cancelled,
+			timeout: time.Second,
+			search:  func() (int, error) { <-block; return 1, nil },
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Search(tt.ctx, tt.timeout, tt.search)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Search() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Search() got: %d, want: %d", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+var (
+	// Dependency injections for testing.
+	serialOf = deviceSerial
+)
+
+// SerialNumber returns the serial number that the device with the provided
+// identifier reports to the operating system, or an empty string when it
+// reports none or it cannot be determined.
+func SerialNumber(id string) string {
+	serial, err := serialOf(id)
+	if err != nil {
+		deck.InfofA("Serial number of %q could not be determined: %v", id, err).With(debug.V(debug.Storage, 2)).Go()
+		return ""
+	}
+	return serial
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// deviceSerial returns the serial number of the USB device that holds the
+// disk with the provided identifier, such as 'disk2', from the IOKit
+// registry.
+func deviceSerial(id string) (string, error) {
+	out, err := exec.Command("ioreg", "-r", "-l", "-c", "IOUSBHostDevice").Output()
+	if err != nil {
+		return "", fmt.Errorf("ioreg returned %v", err)
+	}
+	return parseIORegSerial(out, id), nil
+}
+
+// parseIORegSerial returns the serial number of the USB device that holds
+// the disk id from the output of ioreg, which lists each USB device at the
+// start of a line followed by its properties and those of the devices it
+// holds, including the BSD names of its disks.
+func parseIORegSerial(out []byte, id string) string {
+	var serial string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "+-o") {
+			serial = ""
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k = strings.Trim(strings.TrimLeft(k, " |"), "\" ")
+		v = strings.Trim(v, "\" ")
+		switch k {
+		case "USB Serial Number", "kUSBSerialNumberString":
+			serial = v
+		case "BSD Name":
+			if v == id {
+				return serial
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// udevDataDir is where udev records the properties of devices.
+var udevDataDir = "/run/udev/data"
+
+// deviceSerial returns the serial number of the block device with the
+// provided identifier, such as 'sdb', from the ID_SERIAL_SHORT property that
+// udev records for it, or from ID_SERIAL when that is all it records.
+func deviceSerial(id string) (string, error) {
+	dev, err := ioutil.ReadFile(filepath.Join(sysBlockDir, id, "dev"))
+	if err != nil {
+		return "", fmt.Errorf("reading the device number of %q: %v", id, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(udevDataDir, "b"+strings.TrimSpace(string(dev))))
+	if err != nil {
+		return "", fmt.Errorf("reading the udev properties of %q: %v", id, err)
+	}
+	return parseUdevSerial(data), nil
+}
+
+// parseUdevSerial returns the serial number from the udev data of a device,
+// which records properties as lines such as 'E:ID_SERIAL_SHORT=1234'.
+func parseUdevSerial(data []byte) string {
+	props := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if k, v, ok := strings.Cut(strings.TrimPrefix(s.Text(), "E:"), "="); ok {
+			props[k] = v
+		}
+	}
+	if serial := props["ID_SERIAL_SHORT"]; serial != "" {
+		return serial
+	}
+	return props["ID_SERIAL"]
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceSerial(t *testing.T) {
+	defer func(s, u string) { sysBlockDir, udevDataDir = s, u }(sysBlockDir, udevDataDir)
+	sysBlockDir, udevDataDir = t.TempDir(), t.TempDir()
+	for id, dev := range map[string]string{"sda": "8:0\n", "sdb": "8:16\n", "sdc": "8:32\n", "sdd": "8:48\n"} {
+		if err := os.Mkdir(filepath.Join(sysBlockDir, id), 0755); err != nil {
+			t.Fatalf("os.Mkdir() returned %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sysBlockDir, id, "dev"), []byte(dev), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+	}
+	for dev, data := range map[string]string{
+		"b8:0":  "S:disk/by-id/usb-Fresnel_Flash_SN123-0:0\nE:ID_SERIAL=Fresnel_Flash_SN123-0:0\nE:ID_SERIAL_SHORT=SN123\n",
+		"b8:16": "E:ID_SERIAL=Fresnel_Flash_SN456-0:0\n",
+		"b8:32": "E:ID_BUS=usb\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(udevDataDir, dev), []byte(data), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+	}
+	tests := []struct {
+		desc    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{desc: "short serial", id: "sda", want: "SN123"},
+		{desc: "serial", id: "sdb", want: "Fresnel_Flash_SN456-0:0"},
+		{desc: "no serial", id: "sdc"},
+		{desc: "no udev data", id: "sdd", wantErr: true},
+		{desc: "missing device", id: "sde", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := deviceSerial(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: deviceSerial() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: deviceSerial() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
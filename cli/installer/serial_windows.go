@@ -0,0 +1,48 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// serialScript reports the serial number of a disk as JSON.
+const serialScript = `Get-Disk -Number %s | Select-Object SerialNumber | ConvertTo-Json`
+
+// deviceSerial returns the serial number of the disk with the provided
+// number, as reported by Windows.
+func deviceSerial(id string) (string, error) {
+	script := fmt.Sprintf(serialScript, id)
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Output()
+	if err != nil {
+		return "", fmt.Errorf("Get-Disk returned %v", err)
+	}
+	return parseSerial(out)
+}
+
+// parseSerial returns the serial number of a disk from the output of
+// serialScript. Windows pads the serial numbers of some disks with spaces.
+func parseSerial(out []byte) (string, error) {
+	var disk struct {
+		SerialNumber string
+	}
+	if err := json.Unmarshal(out, &disk); err != nil {
+		return "", fmt.Errorf("json.Unmarshal(%q) returned %v", out, err)
+	}
+	return strings.TrimSpace(disk.SerialNumber), nil
+}
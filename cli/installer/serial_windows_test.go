@@ -0,0 +1,40 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "testing"
+
+func TestParseSerial(t *testing.T) {
+	tests := []struct {
+		desc    string
+		out     string
+		want    string
+		wantErr bool
+	}{
+		{desc: "serial", out: `{"SerialNumber":"SN123"}`, want: "SN123"},
+		{desc: "padded serial", out: `{"SerialNumber":"  SN123  "}`, want: "SN123"},
+		{desc: "no serial", out: `{"SerialNumber":null}`},
+		{desc: "invalid output", out: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSerial([]byte(tt.out))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseSerial() returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: parseSerial() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
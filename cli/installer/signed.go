@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/models"
+)
+
+// signedImageURL obtains a signed URL for the image from the sign server,
+// for distributions that store images in its bucket rather than on an image
+// server. Sign requests must present a seed, so one is first obtained for the
+// digest of the image listed in the image manifest. The seed server only
+// issues it when the digest is in its allowlist, so that only approved images
+// can be obtained, and the image is verified against the same digest once it
+// is downloaded.
+func (i *Installer) signedImageURL() (string, error) {
+	if i.pinned == nil || i.pinned.Digest == "" {
+		return "", fmt.Errorf("%w: the image manifest does not list a digest for track %q, which is required to obtain a signed URL", errDigest, i.config.Track())
+	}
+	hash, err := hex.DecodeString(i.pinned.Digest)
+	if err != nil {
+		return "", fmt.Errorf("%w: the image manifest lists an invalid digest %q: %v", errDigest, i.pinned.Digest, err)
+	}
+	u, err := seedUser(i.config)
+	if err != nil {
+		return "", fmt.Errorf("seedUser() returned %v: %w", err, errUser)
+	}
+
+	deck.InfofA("Requesting seed for image digest %q from %q.", i.pinned.Digest, i.config.SeedServer()).With(debug.V(debug.Network, 2)).Go()
+	doer, err := connectAs(i.config, i.config.SeedServer(), u)
+	if err != nil {
+		return "", fmt.Errorf("fetcher.Connect(%q) returned %v: %w", i.config.SeedServer(), err, errConnect)
+	}
+	c := newClient(i.config.SeedServer(), doer)
+	c.Batch = i.config.Batch()
+	c.Fields = i.config.SeedFields()
+	sr, err := c.Seed(hash, models.HashSHA256)
+	if err != nil {
+		reportMaintenance(err)
+		return "", fmt.Errorf("%w: requesting a seed for the image: %v", errImage, err)
+	}
+
+	object := path.Join(i.config.SignedImages(), i.config.ImageFile())
+	deck.InfofA("Requesting signed URL for %q from %q.", object, i.config.SignServer()).With(debug.V(debug.Network, 2)).Go()
+	if doer, err = connectAs(i.config, i.config.SignServer(), u); err != nil {
+		return "", fmt.Errorf("fetcher.Connect(%q) returned %v: %w", i.config.SignServer(), err, errConnect)
+	}
+	resp, err := newClient(i.config.SignServer(), doer).Sign(&models.SignRequest{
+		Seed:      sr.Seed,
+		Signature: sr.Signature,
+		Mac:       machineMACs(),
+		Path:      object,
+		Hash:      hash,
+		Algorithm: models.HashSHA256,
+	})
+	if err != nil {
+		reportMaintenance(err)
+		return "", fmt.Errorf("%w: requesting a signed URL for %q: %v", errImage, object, err)
+	}
+	console.Printf("Obtained a signed URL for %q, valid until %v.", object, resp.Expires.Local())
+	return resp.SignedURL, nil
+}
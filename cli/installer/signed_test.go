@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/cli/netinfo"
+	"github.com/google/fresnel/models"
+)
+
+func TestSignedImageURL(t *testing.T) {
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	seed, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSuccess, Signature: []byte("signature")})
+	if err != nil {
+		t.Fatalf("json.Marshal of seed response returned %v", err)
+	}
+	signed, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusSuccess, SignedURL: "https://signed.url/image.iso"})
+	if err != nil {
+		t.Fatalf("json.Marshal of sign response returned %v", err)
+	}
+	refused, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusSignError, Status: "refused"})
+	if err != nil {
+		t.Fatalf("json.Marshal of refused response returned %v", err)
+	}
+	interfaceMACs = func() ([]string, error) { return []string{"3c:22:fb:12:34:56"}, nil }
+	defer func() { connect = fetcherConnect; interfaceMACs = netinfo.MACs }()
+
+	tests := []struct {
+		desc     string
+		pinned   *models.TrackImage
+		seedErr  error
+		signBody []byte
+		wantPath string
+		want     error
+	}{
+		{
+			desc: "no pinned image",
+			want: errDigest,
+		},
+		{
+			desc:   "invalid digest",
+			pinned: &models.TrackImage{Digest: "not hex"},
+			want:   errDigest,
+		},
+		{
+			desc:    "seed request error",
+			pinned:  &models.TrackImage{Digest: digest},
+			seedErr: errors.New("error"),
+			want:    errImage,
+		},
+		{
+			desc:     "sign request refused",
+			pinned:   &models.TrackImage{Digest: digest},
+			signBody: refused,
+			want:     errImage,
+		},
+		{
+			desc:     "success",
+			pinned:   &models.TrackImage{Digest: digest},
+			signBody: signed,
+			wantPath: "images/stable/image.iso",
+		},
+	}
+	for _, tt := range tests {
+		seedDoer := &fakeHTTPDoer{body: seed, err: tt.seedErr}
+		signDoer := &fakeHTTPDoer{body: tt.signBody}
+		connect = func(url, _, _ string) (httpDoer, error) {
+			if strings.Contains(url, "sign") {
+				return signDoer, nil
+			}
+			return seedDoer, nil
+		}
+		i := &Installer{
+			pinned: tt.pinned,
+			config: &fakeConfig{
+				imageFile:    "image.iso",
+				seedServer:   "https://foo.bar.com/seed",
+				signServer:   "https://foo.bar.com/sign",
+				signedImages: "images/stable",
+			},
+		}
+		got, err := i.signedImageURL()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: signedImageURL() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		if got != "https://signed.url/image.iso" {
+			t.Errorf("%s: signedImageURL() got: %q, want: %q", tt.desc, got, "https://signed.url/image.iso")
+		}
+		req := &models.SignRequest{}
+		if err := json.NewDecoder(signDoer.req.Body).Decode(req); err != nil {
+			t.Fatalf("%s: decoding sign request returned %v", tt.desc, err)
+		}
+		if req.Path != tt.wantPath || string(req.Signature) != "signature" || len(req.Mac) != 1 {
+			t.Errorf("%s: sign request got: %+v, want path %q with the seed signature and MAC address", tt.desc, req, tt.wantPath)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/units"
+	"github.com/google/fresnel/cli/version"
+)
+
+const (
+	// DefaultStreamChunkSize is the size of the chunks that devices are
+	// streamed in. A chunk that fails to arrive is requested again as a whole,
+	// so smaller chunks lose less progress over unreliable links.
+	DefaultStreamChunkSize = 32 * units.MB
+
+	// StreamTokenEnv is the environment variable holding the token that is
+	// shared by a station streaming a device and the stations receiving it.
+	StreamTokenEnv = "FRESNEL_STREAM_TOKEN"
+
+	// The paths served by a StreamSource.
+	streamManifestPath = "/v1/manifest"
+	streamChunkPath    = "/v1/chunks/"
+
+	// streamSignatureHeader holds the HMAC-SHA256 of the manifest, keyed with
+	// the token, so that receivers know the hashes of the chunks are those of
+	// the station they share the token with.
+	streamSignatureHeader = "X-Fresnel-Signature"
+
+	// streamSector is the size that chunks must be a multiple of, as raw
+	// devices are read and written in whole sectors.
+	streamSector = 512
+
+	// streamAttempts is the number of times each request is made before the
+	// transfer is abandoned.
+	streamAttempts = 5
+	// streamMaxBackoff limits the time between attempts.
+	streamMaxBackoff = 30 * time.Second
+)
+
+var (
+	// Dependency injections for testing.
+	streamBackoff          = time.Second
+	streamClient  httpDoer = &http.Client{
+		// Stalled transfers are abandoned and retried, long after a chunk
+		// would have arrived over the slowest of links.
+		Timeout:   10 * time.Minute,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, ResponseHeaderTimeout: time.Minute},
+	}
+
+	// Wrapped errors for testing.
+	errStream = errors.New("stream error")
+	errToken  = errors.New("stream token refused")
+)
+
+// StreamManifest describes the contents of a device that is streamed to other
+// stations, as a list of the hashes of its chunks.
+type StreamManifest struct {
+	Device     string       `json:"device"`
+	Name       string       `json:"name"`
+	Size       int64        `json:"size"`        // The size of the partitioned part of the device, which is streamed.
+	DeviceSize int64        `json:"device_size"` // The size of the whole device.
+	ChunkSize  int64        `json:"chunk_size"`
+	Chunks     []string     `json:"chunks"` // The hex encoded SHA-256 hash of each chunk.
+	Host       string       `json:"host"`
+	Build      version.Info `json:"build"`
+	Created    time.Time    `json:"created"`
+}
+
+// ID identifies the contents described by m, regardless of when or where
+// they were hashed, so that transfers resume when the streaming station is
+// restarted.
+func (m *StreamManifest) ID() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\t%d\n", m.Size, m.ChunkSize)
+	for _, c := range m.Chunks {
+		fmt.Fprintf(h, "%s\n", c)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chunk returns the offset and length of chunk n.
+func (m *StreamManifest) chunk(n int) (int64, int64) {
+	off := int64(n) * m.ChunkSize
+	if rem := m.Size - off; rem < m.ChunkSize {
+		return off, rem
+	}
+	return off, m.ChunkSize
+}
+
+// check returns an error when m does not describe a whole number of sectors
+// in chunks that cover its size.
+func (m *StreamManifest) check() error {
+	if m.Size <= 0 || m.Size%streamSector != 0 || m.ChunkSize <= 0 || m.ChunkSize%streamSector != 0 {
+		return fmt.Errorf("%w: the manifest describes %d bytes in chunks of %d bytes", errStream, m.Size, m.ChunkSize)
+	}
+	if want := (m.Size + m.ChunkSize - 1) / m.ChunkSize; int64(len(m.Chunks)) != want {
+		return fmt.Errorf("%w: the manifest lists %d chunks, want %d", errStream, len(m.Chunks), want)
+	}
+	return nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of b, keyed with token.
+func sign(token, b []byte) string {
+	mac := hmac.New(sha256.New, token)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StreamSource serves the contents of a provisioned device to the stations
+// that receive it, over HTTP. Requests must present the shared token as a
+// bearer token. Each chunk is read from the device as it is requested, and is
+// only served when it still matches the hash taken when the device was first
+// read.
+type StreamSource struct {
+	manifest StreamManifest
+	body     []byte // The manifest, as it is served.
+	token    []byte
+	dev      readerAtCloser
+}
+
+// NewStreamSource reads d to build the manifest that it is streamed with, in
+// chunks of chunkSize, which must be a multiple of the sector size. Only the
+// part of d holding the partitions described by its partition table is
+// streamed, so that the stream can be received by devices that are smaller
+// than d but large enough for its partitions. The device is held open until
+// Close is called, and must not be written to meanwhile.
+func NewStreamSource(d Device, chunkSize int64, token string) (*StreamSource, error) {
+	if token == "" {
+		return nil, fmt.Errorf("a token is required to stream devices: %w", errInput)
+	}
+	if chunkSize <= 0 || chunkSize%streamSector != 0 {
+		return nil, fmt.Errorf("the chunk size of %d bytes is not a multiple of %d: %w", chunkSize, streamSector, errInput)
+	}
+	if d.Size() == 0 || d.Size()%streamSector != 0 {
+		return nil, fmt.Errorf("%q reports a size of %d bytes: %w", d.Identifier(), d.Size(), errDevice)
+	}
+	deck.InfofA("Opening %q for raw reading.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+	dev, err := openForRead(d.Identifier())
+	if err != nil {
+		return nil, fmt.Errorf("openForRead(%q) returned %v: %w", d.Identifier(), err, errDevice)
+	}
+	size, err := partitionedSize(dev, int64(d.Size()))
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("reading the partition table of %q: %w", d.Identifier(), err)
+	}
+	host, _ := os.Hostname()
+	s := &StreamSource{
+		manifest: StreamManifest{
+			Device:     d.Identifier(),
+			Name:       d.FriendlyName(),
+			Size:       size,
+			DeviceSize: int64(d.Size()),
+			ChunkSize:  chunkSize,
+			Host:       host,
+			Build:      version.Get(config.DefaultsRevision()),
+			Created:    time.Now().UTC(),
+		},
+		token: []byte(token),
+		dev:   dev,
+	}
+	r := console.ProgressReader(io.NewSectionReader(dev, 0, s.manifest.Size), "\nHashing "+d.Identifier(), s.manifest.Size)
+	buf := make([]byte, chunkSize)
+	for off := int64(0); off < s.manifest.Size; off += chunkSize {
+		n, err := io.ReadFull(r, buf[:s.manifest.ChunkSize])
+		if err != nil && !(errors.Is(err, io.ErrUnexpectedEOF) && off+int64(n) == s.manifest.Size) {
+			dev.Close()
+			return nil, fmt.Errorf("reading %q returned %v: %w", d.Identifier(), err, errIO)
+		}
+		sum := sha256.Sum256(buf[:n])
+		s.manifest.Chunks = append(s.manifest.Chunks, hex.EncodeToString(sum[:]))
+	}
+	if s.body, err = json.MarshalIndent(s.manifest, "", "  "); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("json.MarshalIndent() returned %v: %w", err, errIO)
+	}
+	deck.InfofA("Hashed %d chunks holding the partitions of %q (%s of %s), manifest %q.", len(s.manifest.Chunks), d.Identifier(), humanize.Bytes(uint64(size)), humanize.Bytes(d.Size()), s.manifest.ID()).With(debug.V(debug.Network, 1)).Go()
+	return s, nil
+}
+
+// Manifest returns the manifest that the device is streamed with.
+func (s *StreamSource) Manifest() StreamManifest {
+	return s.manifest
+}
+
+// Close closes the device.
+func (s *StreamSource) Close() error {
+	return s.dev.Close()
+}
+
+// ServeHTTP serves the manifest and the chunks of the device.
+func (s *StreamSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(auth), s.token) != 1 {
+		deck.Warningf("Refused a request for %q from %s without the stream token.", r.URL.Path, r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.URL.Path == streamManifestPath:
+		deck.InfofA("Serving the manifest to %s.", r.RemoteAddr).With(debug.V(debug.Network, 1)).Go()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(streamSignatureHeader, sign(s.token, s.body))
+		w.Write(s.body)
+	case strings.HasPrefix(r.URL.Path, streamChunkPath):
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, streamChunkPath))
+		if err != nil || n < 0 || n >= len(s.manifest.Chunks) {
+			http.NotFound(w, r)
+			return
+		}
+		b, err := s.readChunk(n)
+		if err != nil {
+			deck.Errorf("Chunk %d was not served to %s: %v", n, r.RemoteAddr, err)
+			http.Error(w, "the chunk could not be read", http.StatusInternalServerError)
+			return
+		}
+		deck.InfofA("Serving chunk %d of %d to %s.", n+1, len(s.manifest.Chunks), r.RemoteAddr).With(debug.V(debug.Network, 3)).Go()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.Write(b)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// readChunk reads chunk n from the device, and checks that it has not
+// changed since the device was hashed.
+func (s *StreamSource) readChunk(n int) ([]byte, error) {
+	off, l := s.manifest.chunk(n)
+	b := make([]byte, l)
+	if read, err := s.dev.ReadAt(b, off); int64(read) != l {
+		return nil, fmt.Errorf("reading %q returned %v: %w", s.manifest.Device, err, errIO)
+	}
+	sum := sha256.Sum256(b)
+	if got := hex.EncodeToString(sum[:]); got != s.manifest.Chunks[n] {
+		return nil, fmt.Errorf("%w: chunk %d of %q has changed since it was hashed", errVerify, n, s.manifest.Device)
+	}
+	return b, nil
+}
+
+// FetchStreamManifest obtains the manifest of the device streamed from
+// source, such as 'http://station:8080', and checks that it was signed with
+// token.
+func FetchStreamManifest(source, token string) (*StreamManifest, error) {
+	if token == "" {
+		return nil, fmt.Errorf("a token is required to receive devices: %w", errInput)
+	}
+	b, h, err := streamGet(strings.TrimSuffix(source, "/")+streamManifestPath, token)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(h.Get(streamSignatureHeader)), []byte(sign([]byte(token), b))) {
+		return nil, fmt.Errorf("%w: the manifest from %q was not signed with the stream token", errStream, source)
+	}
+	m := &StreamManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%w: the manifest from %q is not valid: %v", errStream, source, err)
+	}
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// streamGet requests url with token, retrying until streamAttempts is
+// reached. The time between attempts doubles each time. Requests that are
+// refused for their token are not retried.
+func streamGet(url, token string) ([]byte, http.Header, error) {
+	backoff := streamBackoff
+	for attempt := 1; ; attempt++ {
+		b, h, err := streamGetOnce(url, token)
+		if err == nil || errors.Is(err, errToken) || attempt == streamAttempts {
+			return b, h, err
+		}
+		deck.Warningf("Requesting %q failed (attempt %d of %d), retrying in %v: %v", url, attempt, streamAttempts, backoff, err)
+		sleep(backoff)
+		if backoff *= 2; backoff > streamMaxBackoff {
+			backoff = streamMaxBackoff
+		}
+	}
+}
+
+// streamGetOnce requests url with token, and returns the body and headers of
+// the response.
+func streamGetOnce(url, token string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: http.NewRequest(%q) returned %v", errStream, url, err)
+	}
+	version.SetHeaders(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: requesting %q returned %v", errStream, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, nil, fmt.Errorf("%w by %q, check %s", errToken, url, StreamTokenEnv)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%w for %q with response %d", errStatus, url, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: reading %q returned %v", errStream, url, err)
+	}
+	return b, resp.Header, nil
+}
+
+// fetchChunk obtains chunk n of m from source, requesting it again when it
+// does not match its hash.
+func fetchChunk(source, token string, m *StreamManifest, n int) ([]byte, error) {
+	url := fmt.Sprintf("%s%s%d", strings.TrimSuffix(source, "/"), streamChunkPath, n)
+	_, l := m.chunk(n)
+	var err error
+	for attempt := 1; attempt <= streamAttempts; attempt++ {
+		var b []byte
+		if b, _, err = streamGet(url, token); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		if int64(len(b)) == l && hex.EncodeToString(sum[:]) == m.Chunks[n] {
+			return b, nil
+		}
+		err = fmt.Errorf("%w: chunk %d of %d bytes does not match the manifest", errVerify, n, len(b))
+		deck.Warningf("Chunk %d from %q was corrupted (attempt %d of %d): %v", n, source, attempt, streamAttempts, err)
+	}
+	return nil, err
+}
+
+// streamState records the chunks of a stream that have been written to a
+// device, so that an interrupted transfer can resume.
+type streamState struct {
+	path    string       // Empty when the state is not saved.
+	ID      string       `json:"id"`
+	Written map[int]bool `json:"written"`
+}
+
+// loadStreamState returns the chunks of m written to d, as recorded in dir by
+// an earlier transfer. Nothing is recorded when dir is empty.
+func loadStreamState(dir string, m *StreamManifest, d Device) (*streamState, error) {
+	id := m.ID()
+	s := &streamState{ID: id, Written: make(map[int]bool)}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, fmt.Sprintf("stream-%s-%s.json", id[:16], regExUnsafeName.ReplaceAllString(d.Identifier(), "_")))
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile(%q) returned %v: %w", s.path, err, errIO)
+	}
+	saved := &streamState{}
+	if err := json.Unmarshal(b, saved); err != nil || saved.ID != id {
+		deck.Warningf("Ignoring the transfer state in %q, which is not valid for this stream: %v", s.path, err)
+		return s, nil
+	}
+	for n, ok := range saved.Written {
+		s.Written[n] = ok
+	}
+	return s, nil
+}
+
+// save records the chunks written.
+func (s *streamState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("json.Marshal() returned %v: %w", err, errIO)
+	}
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(s.path, b, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", s.path, err, errIO)
+	}
+	return nil
+}
+
+// remove deletes the record of the chunks written, once the transfer is
+// complete.
+func (s *streamState) remove() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove(%q) returned %v: %w", s.path, err, errIO)
+	}
+	return nil
+}
+
+// streamTarget is a device that a stream is written to.
+type streamTarget struct {
+	d     Device
+	dev   rawDevice
+	state *streamState
+}
+
+// Receive writes the device described by m, streamed from source, to each of
+// devices, which must be at least as large as it and are dismounted first.
+// Each chunk is requested once however many devices it is written to, and is
+// checked against its hash in m before it is written. The chunks written to
+// each device are recorded in stateDir, so that an interrupted transfer
+// resumes where it stopped when Receive is called again for the same devices.
+// Every device is read back and compared with m once all chunks are written,
+// and chunks that do not match are written again by the next transfer. The
+// backup GPT of devices partitioned with one is then moved to their end, as
+// the stream only holds the partitions of the streamed device.
+func Receive(source, token string, m *StreamManifest, devices []Device, stateDir string) (err error) {
+	if err := m.check(); err != nil {
+		return err
+	}
+	var targets []*streamTarget
+	defer func() {
+		for _, t := range targets {
+			if err2 := t.dev.Close(); err2 != nil && err == nil {
+				err = fmt.Errorf("Close() for %q returned %v: %w", t.d.Identifier(), err2, errIO)
+			}
+		}
+	}()
+	for _, d := range devices {
+		if int64(d.Size()) < m.Size {
+			return fmt.Errorf("%w: the stream (%s) is larger than %q (%s)", errDevice, humanize.Bytes(uint64(m.Size)), d.FriendlyName(), humanize.Bytes(d.Size()))
+		}
+		state, err := loadStreamState(stateDir, m, d)
+		if err != nil {
+			return err
+		}
+		if len(state.Written) > 0 {
+			console.Printf("Resuming the transfer to %q, %d of %d chunks were already written.", d.FriendlyName(), len(state.Written), len(m.Chunks))
+		}
+		if err := d.Dismount(); err != nil {
+			return fmt.Errorf("Dismount() for %q returned %v: %w", d.Identifier(), err, errDevice)
+		}
+		deck.InfofA("Opening %q for raw writing.", d.Identifier()).With(debug.V(debug.Storage, 2)).Go()
+		dev, err := openDevice(d.Identifier())
+		if err != nil {
+			return fmt.Errorf("openDevice(%q) returned %v: %w", d.Identifier(), err, errDevice)
+		}
+		targets = append(targets, &streamTarget{d: d, dev: dev, state: state})
+	}
+	for n := range m.Chunks {
+		if err := receiveChunk(source, token, m, n, targets); err != nil {
+			return err
+		}
+		if pct, prev := (n+1)*100/len(m.Chunks), n*100/len(m.Chunks); pct/10 != prev/10 {
+			console.Printf("Received %d%% of %s from %q.", pct, humanize.Bytes(uint64(m.Size)), source)
+		}
+	}
+	for _, t := range targets {
+		if err := verifyStream(t, m); err != nil {
+			return err
+		}
+		if err := relocateTarget(t); err != nil {
+			return err
+		}
+		if err := t.state.remove(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveChunk obtains chunk n of m from source and writes it to each of
+// targets that it has not already been written to.
+func receiveChunk(source, token string, m *StreamManifest, n int, targets []*streamTarget) error {
+	var pending []*streamTarget
+	for _, t := range targets {
+		if !t.state.Written[n] {
+			pending = append(pending, t)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	b, err := fetchChunk(source, token, m, n)
+	if err != nil {
+		return err
+	}
+	off, _ := m.chunk(n)
+	for _, t := range pending {
+		if _, err := t.dev.Seek(off, io.SeekStart); err != nil {
+			return fmt.Errorf("Seek(%d) for %q returned %v: %w", off, t.d.Identifier(), err, errIO)
+		}
+		if _, err := t.dev.Write(b); err != nil {
+			return fmt.Errorf("writing chunk %d to %q returned %v: %w", n, t.d.Identifier(), err, errIO)
+		}
+		if err := t.dev.Sync(); err != nil {
+			return fmt.Errorf("Sync() for %q returned %v: %w", t.d.Identifier(), err, errIO)
+		}
+		t.state.Written[n] = true
+		if err := t.state.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relocateTarget moves the end of the GUID partition table of t to the end of
+// the device, when it has one.
+func relocateTarget(t *streamTarget) error {
+	r, err := openForRead(t.d.Identifier())
+	if err != nil {
+		return fmt.Errorf("openForRead(%q) returned %v: %w", t.d.Identifier(), err, errDevice)
+	}
+	defer r.Close()
+	if err := relocateGPT(r, t.dev, int64(t.d.Size())); err != nil {
+		return fmt.Errorf("relocating the GPT of %q: %w", t.d.FriendlyName(), err)
+	}
+	if err := t.dev.Sync(); err != nil {
+		return fmt.Errorf("Sync() for %q returned %v: %w", t.d.Identifier(), err, errIO)
+	}
+	return nil
+}
+
+// verifyStream reads back the chunks of m written to t and compares them with
+// their hashes. Chunks that do not match are removed from the state of t, so
+// that they are written again.
+func verifyStream(t *streamTarget, m *StreamManifest) error {
+	path := devicePath(t.d.Identifier())
+	f, err := openUncachedFunc(path)
+	if err != nil {
+		return fmt.Errorf("openUncached(%q) returned %v: %w", path, err, errDevice)
+	}
+	defer f.Close()
+	r := console.ProgressReader(io.LimitReader(newAlignedReader(f), m.Size), "\nVerification of "+t.d.FriendlyName(), m.Size)
+	buf := make([]byte, m.ChunkSize)
+	var bad []int
+	for n := range m.Chunks {
+		_, l := m.chunk(n)
+		if _, err := io.ReadFull(r, buf[:l]); err != nil {
+			return fmt.Errorf("reading %q returned %v: %w", path, err, errIO)
+		}
+		sum := sha256.Sum256(buf[:l])
+		if hex.EncodeToString(sum[:]) != m.Chunks[n] {
+			bad = append(bad, n)
+			delete(t.state.Written, n)
+		}
+	}
+	if len(bad) == 0 {
+		console.Printf("Verified %s received by %q.", humanize.Bytes(uint64(m.Size)), t.d.FriendlyName())
+		return nil
+	}
+	if err := t.state.save(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %d chunks of %q do not match the stream, such as chunk %d, run the transfer again to rewrite them", errVerify, len(bad), t.d.FriendlyName(), bad[0])
+}
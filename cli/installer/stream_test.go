@@ -0,0 +1,263 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// streamDisk returns the contents of a device of 2.5 chunks of chunkSize.
+func streamDisk(chunkSize int) []byte {
+	disk := bytes.Repeat([]byte("fresnel!"), chunkSize*5/16)
+	return append(disk, make([]byte, chunkSize*5/2-len(disk))...)
+}
+
+// serveStream streams disk from a test server with token.
+func serveStream(t *testing.T, disk []byte, chunkSize int64, token string) (*StreamSource, *httptest.Server) {
+	t.Helper()
+	openForRead = func(string) (readerAtCloser, error) { return &fakeDeviceReader{bytes.NewReader(disk)}, nil }
+	src, err := NewStreamSource(&fakeDevice{id: "sdc", name: "Master", size: uint64(len(disk))}, chunkSize, token)
+	if err != nil {
+		t.Fatalf("NewStreamSource() returned %v", err)
+	}
+	return src, httptest.NewServer(src)
+}
+
+func TestNewStreamSource(t *testing.T) {
+	defer func() { openForRead = openRawDeviceRead }()
+	disk := streamDisk(1024)
+	tests := []struct {
+		desc      string
+		size      uint64
+		chunkSize int64
+		token     string
+		want      error
+	}{
+		{desc: "no token", size: uint64(len(disk)), chunkSize: 1024, want: errInput},
+		{desc: "unaligned chunks", size: uint64(len(disk)), chunkSize: 1000, token: "t", want: errInput},
+		{desc: "unaligned device", size: 1000, chunkSize: 1024, token: "t", want: errDevice},
+		{desc: "success", size: uint64(len(disk)), chunkSize: 1024, token: "t"},
+	}
+	openForRead = func(string) (readerAtCloser, error) { return &fakeDeviceReader{bytes.NewReader(disk)}, nil }
+	for _, tt := range tests {
+		src, err := NewStreamSource(&fakeDevice{id: "sdc", size: tt.size}, tt.chunkSize, tt.token)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: NewStreamSource() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		m := src.Manifest()
+		if err := m.check(); err != nil || len(m.Chunks) != 3 {
+			t.Errorf("%s: NewStreamSource() manifest got %d chunks, %v, want 3", tt.desc, len(m.Chunks), err)
+		}
+		src.Close()
+	}
+
+	// Only the partitions of a partitioned device are streamed.
+	copy(disk, mbrDisk([2]uint32{1, 3}))
+	src, err := NewStreamSource(&fakeDevice{id: "sdc", size: uint64(len(disk))}, 1024, "t")
+	if err != nil {
+		t.Fatalf("NewStreamSource() of a partitioned device returned %v", err)
+	}
+	defer src.Close()
+	if m := src.Manifest(); m.Size != 2048 || m.DeviceSize != int64(len(disk)) || len(m.Chunks) != 2 {
+		t.Errorf("NewStreamSource() of a partitioned device streams %d of %d bytes in %d chunks, want 2048 of %d in 2", m.Size, m.DeviceSize, len(m.Chunks), len(disk))
+	}
+}
+
+func TestStreamSourceServeHTTP(t *testing.T) {
+	defer func() { openForRead = openRawDeviceRead }()
+	disk := streamDisk(1024)
+	src, srv := serveStream(t, disk, 1024, "secret")
+	defer srv.Close()
+	tests := []struct {
+		desc  string
+		path  string
+		token string
+		want  int
+	}{
+		{desc: "no token", path: streamManifestPath, want: http.StatusUnauthorized},
+		{desc: "wrong token", path: streamManifestPath, token: "guess", want: http.StatusUnauthorized},
+		{desc: "manifest", path: streamManifestPath, token: "secret", want: http.StatusOK},
+		{desc: "chunk", path: streamChunkPath + "2", token: "secret", want: http.StatusOK},
+		{desc: "chunk out of range", path: streamChunkPath + "3", token: "secret", want: http.StatusNotFound},
+		{desc: "unknown path", path: "/", token: "secret", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest() returned %v", err)
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: Do() returned %v", tt.desc, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: ServeHTTP() status got: %d, want: %d", tt.desc, resp.StatusCode, tt.want)
+		}
+	}
+
+	// Chunks that change after the device was hashed are not served.
+	copy(disk[2048:], "changed")
+	if _, err := src.readChunk(2); !errors.Is(err, errVerify) {
+		t.Errorf("readChunk() of a changed chunk got: %v, want: %v", err, errVerify)
+	}
+}
+
+func TestFetchStreamManifest(t *testing.T) {
+	defer func() { openForRead = openRawDeviceRead }()
+	src, srv := serveStream(t, streamDisk(1024), 1024, "secret")
+	defer srv.Close()
+	defer src.Close()
+	tests := []struct {
+		desc  string
+		token string
+		want  error
+	}{
+		{desc: "no token", want: errInput},
+		{desc: "wrong token", token: "guess", want: errToken},
+		{desc: "success", token: "secret"},
+	}
+	for _, tt := range tests {
+		m, err := FetchStreamManifest(srv.URL+"/", tt.token)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: FetchStreamManifest() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err == nil && m.ID() != src.manifest.ID() {
+			t.Errorf("%s: FetchStreamManifest() got manifest %q, want: %q", tt.desc, m.ID(), src.manifest.ID())
+		}
+	}
+}
+
+func TestFetchStreamManifestUnsigned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"size": 1024, "chunk_size": 1024, "chunks": ["00"]}`))
+	}))
+	defer srv.Close()
+	if _, err := FetchStreamManifest(srv.URL, "secret"); !errors.Is(err, errStream) {
+		t.Errorf("FetchStreamManifest() of an unsigned manifest got: %v, want: %v", err, errStream)
+	}
+}
+
+func TestReceive(t *testing.T) {
+	defer func() {
+		openForRead = openRawDeviceRead
+		openDevice = openRawDevice
+		openUncachedFunc = openUncached
+		streamBackoff = time.Second
+	}()
+	streamBackoff = 0
+	disk := streamDisk(1024)
+	src, srv := serveStream(t, disk, 1024, "secret")
+	defer srv.Close()
+	defer src.Close()
+	m := src.Manifest()
+
+	devices := t.TempDir()
+	state := t.TempDir()
+	for _, id := range []string{"sdd", "sde"} {
+		if err := ioutil.WriteFile(filepath.Join(devices, id), make([]byte, 4096), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() returned %v", err)
+		}
+	}
+	openDevice = func(id string) (rawDevice, error) {
+		f, err := os.OpenFile(filepath.Join(devices, id), os.O_WRONLY, 0)
+		return &fakeRawDevice{File: f}, err
+	}
+	openUncachedFunc = func(path string) (*os.File, error) { return os.Open(filepath.Join(devices, filepath.Base(path))) }
+	targets := []Device{&fakeDevice{id: "sdd", size: 4096}, &fakeDevice{id: "sde", size: 4096}}
+
+	if err := Receive(srv.URL, "secret", &m, []Device{&fakeDevice{id: "sdf", size: 1024}}, state); !errors.Is(err, errDevice) {
+		t.Errorf("Receive() to a small device got: %v, want: %v", err, errDevice)
+	}
+	if err := Receive(srv.URL, "secret", &m, targets, state); err != nil {
+		t.Fatalf("Receive() returned %v", err)
+	}
+	for _, id := range []string{"sdd", "sde"} {
+		got, err := ioutil.ReadFile(filepath.Join(devices, id))
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile() returned %v", err)
+		}
+		if !bytes.Equal(got[:len(disk)], disk) {
+			t.Errorf("Receive() wrote %q that does not match the stream", id)
+		}
+	}
+	if left, _ := filepath.Glob(filepath.Join(state, "stream-*")); len(left) != 0 {
+		t.Errorf("Receive() left transfer state %v", left)
+	}
+
+	// A corrupted chunk is found when the device is read back, and is the only
+	// one written when the transfer is resumed.
+	corrupt := func() {
+		f, err := os.OpenFile(filepath.Join(devices, "sdd"), os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("os.OpenFile() returned %v", err)
+		}
+		f.WriteAt([]byte("corrupt"), 1024)
+		f.Close()
+	}
+	s, err := loadStreamState(state, &m, targets[0])
+	if err != nil {
+		t.Fatalf("loadStreamState() returned %v", err)
+	}
+	s.Written = map[int]bool{0: true, 1: true, 2: true}
+	if err := s.save(); err != nil {
+		t.Fatalf("save() returned %v", err)
+	}
+	corrupt()
+	if err := Receive(srv.URL, "secret", &m, targets[:1], state); !errors.Is(err, errVerify) {
+		t.Errorf("Receive() to a corrupted device got: %v, want: %v", err, errVerify)
+	}
+	if s, _ = loadStreamState(state, &m, targets[0]); len(s.Written) != 2 || s.Written[1] {
+		t.Errorf("Receive() recorded chunks %v as written, want 0 and 2", s.Written)
+	}
+	if err := Receive(srv.URL, "secret", &m, targets[:1], state); err != nil {
+		t.Errorf("Receive() resuming the transfer returned %v", err)
+	}
+}
+
+func TestReceiveToken(t *testing.T) {
+	defer func() {
+		openForRead = openRawDeviceRead
+		openDevice = openRawDevice
+		streamBackoff = time.Second
+	}()
+	streamBackoff = 0
+	src, srv := serveStream(t, streamDisk(1024), 1024, "secret")
+	defer srv.Close()
+	defer src.Close()
+	m := src.Manifest()
+	f, err := ioutil.TempFile(t.TempDir(), "device")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile() returned %v", err)
+	}
+	openDevice = func(string) (rawDevice, error) { return &fakeRawDevice{File: f}, nil }
+	if err := Receive(srv.URL, "guess", &m, []Device{&fakeDevice{id: "sdd", size: 4096}}, ""); !errors.Is(err, errToken) {
+		t.Errorf("Receive() with the wrong token got: %v, want: %v", err, errToken)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/models"
+)
+
+// writeUnattend copies the configured answer file to the root of the boot
+// partition as autounattend.xml, where Windows Setup on the media looks for it.
+// It is written after the ISO so that it takes the place of any answer file
+// that the ISO provides, which is also replaced in the content manifest m
+// when m is not nil.
+func (i *Installer) writeUnattend(p partition, m *models.ContentManifest) error {
+	source := i.config.Unattend()
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("ioutil.ReadFile(%q) returned %v: %w", source, err, errIO)
+	}
+	if p.MountPoint() == "" {
+		return fmt.Errorf("partition %q is not mounted: %w", p.Identifier(), errInput)
+	}
+	dest := filepath.Join(host.root(p.MountPoint()), i.config.UnattendDest())
+	deck.InfofA("Writing answer file %q to %q.", source, dest).With(debug.V(debug.Copy, 2)).Go()
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(dest, content, 0644); err != nil {
+		return fmt.Errorf("ioutil.WriteFile(%q) returned %v: %w", dest, err, errIO)
+	}
+	if m != nil {
+		recordUnattend(m, i.config.UnattendDest(), content)
+	}
+	if !i.config.PreserveAttributes() {
+		return nil
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("os.Stat(%q) returned %v: %w", source, err, errIO)
+	}
+	return preserveAttributes(source, dest, info)
+}
+
+// recordUnattend replaces the entry of the answer file named name in the
+// content manifest m, which holds that of the ISO when it provides its own,
+// with that of content.
+func recordUnattend(m *models.ContentManifest, name string, content []byte) {
+	var files []models.ContentFile
+	for _, f := range m.Files {
+		if f.Partition == config.BootPartition && strings.EqualFold(f.Path, name) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sum := sha256.Sum256(content)
+	m.Files = append(files, models.ContentFile{
+		Path:      name,
+		Partition: config.BootPartition,
+		Size:      int64(len(content)),
+		SHA256:    hex.EncodeToString(sum[:]),
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteUnattend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unattend")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "answers.xml")
+	if err := ioutil.WriteFile(source, []byte("<unattend/>"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) err: %v", source, err)
+	}
+	media := filepath.Join(dir, "media")
+	if err := os.Mkdir(media, 0755); err != nil {
+		t.Fatalf("os.Mkdir(%q) err: %v", media, err)
+	}
+
+	tests := []struct {
+		desc   string
+		source string
+		part   *fakePartition
+		want   error
+	}{
+		{
+			desc:   "missing answer file",
+			source: filepath.Join(dir, "missing.xml"),
+			part:   &fakePartition{mount: media},
+			want:   errIO,
+		},
+		{
+			desc:   "not mounted",
+			source: source,
+			part:   &fakePartition{},
+			want:   errInput,
+		},
+		{
+			desc:   "success",
+			source: source,
+			part:   &fakePartition{mount: media},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		i := &Installer{config: &fakeConfig{unattend: tt.source, unattendTo: "autounattend.xml"}}
+		m := &models.ContentManifest{Files: []models.ContentFile{
+			{Path: "AutoUnattend.xml", Partition: config.BootPartition, Size: 3, SHA256: "iso"},
+			{Path: "setup.exe", Partition: config.BootPartition, Size: 1, SHA256: "setup"},
+		}}
+		err := i.writeUnattend(tt.part, m)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: writeUnattend() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(media, "autounattend.xml"))
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile() err: %v", tt.desc, err)
+		}
+		if got, want := string(b), "<unattend/>"; got != want {
+			t.Errorf("%s: writeUnattend() wrote %q, want: %q", tt.desc, got, want)
+		}
+		sum := sha256.Sum256(b)
+		want := []models.ContentFile{
+			{Path: "setup.exe", Partition: config.BootPartition, Size: 1, SHA256: "setup"},
+			{Path: "autounattend.xml", Partition: config.BootPartition, Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])},
+		}
+		if diff := cmp.Diff(want, m.Files); diff != "" {
+			t.Errorf("%s: writeUnattend() recorded unexpected files (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/cli/imagefile"
+	"github.com/google/fresnel/cli/units"
+)
+
+// uncachedAlign is the alignment of the buffers, offsets and lengths of
+// uncached reads, which covers the logical block size of common devices.
+const uncachedAlign = 4 * int(units.KB)
+
+var (
+	// Dependency injections for testing.
+	openUncachedFunc = openUncached
+)
+
+// Verify reads back what Provision wrote to d and compares it with the
+// image, so that media that silently corrupts writes is detected. Raw images
+// are compared by the SHA-256 hash of the blocks written to the device, and
+// ISO images by the SHA-256 hash of each file copied from the ISO. Reads
+// bypass the cache of the operating system where possible, so that the
+// contents of the device are read rather than what was written to it.
+// Images that are applied to the device are not verified.
+func (i *Installer) Verify(d Device) error {
+	if i.config == nil {
+		return errConfig
+	}
+	switch ext := imagefile.Ext(i.config.ImageFile()); ext {
+	case ".img", ".img.gz", ".img.xz", ".img.zst", ".vhd", ".vhdx", ".qcow2":
+		return i.verifyRaw(d)
+	case ".iso":
+		return i.verifyISO()
+	case ".wim", ".ffu":
+		console.Printf("Skipping verification of %q, applied images cannot be verified.", d.FriendlyName())
+		deck.Warningf("Skipping verification of %q, applied images cannot be verified.", d.FriendlyName())
+		return nil
+	default:
+		return fmt.Errorf("%q is an unknown image type: %w", ext, errVerify)
+	}
+}
+
+// verifyRaw compares the SHA-256 hash of the contents of a raw image with
+// that of the same number of bytes read back from the start of d.
+func (i *Installer) verifyRaw(d Device) error {
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	img, err := openRawImage(path)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+	var src io.Reader = img
+	if img.compression != "" {
+		dr, err := decompress(img, img.compression)
+		if err != nil {
+			return fmt.Errorf("decompress(%q) returned %v: %w", path, err, errFile)
+		}
+		defer dr.Close()
+		src = dr
+	}
+	want, size, err := hashOf(src)
+	if err != nil {
+		return fmt.Errorf("hashing %q returned %v: %w", path, err, errIO)
+	}
+
+	dev := devicePath(d.Identifier())
+	f, err := openUncachedFunc(dev)
+	if err != nil {
+		return fmt.Errorf("openUncached(%q) returned %v: %w", dev, err, errDevice)
+	}
+	defer f.Close()
+	r := console.ProgressReader(io.LimitReader(newAlignedReader(f), size), "\nVerification of "+d.FriendlyName(), size)
+	got, n, err := hashOf(r)
+	if err != nil {
+		return fmt.Errorf("reading %q returned %v: %w", dev, err, errIO)
+	}
+	if n != size {
+		return fmt.Errorf("%w: read %s of %s from %q", errVerify, humanize.Bytes(uint64(n)), humanize.Bytes(uint64(size)), d.FriendlyName())
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: %q has SHA-256 %s, want %s from %q", errVerify, d.FriendlyName(), hex.EncodeToString(got), hex.EncodeToString(want), path)
+	}
+	console.Printf("Verified %s written to %q.", humanize.Bytes(uint64(size)), d.FriendlyName())
+	return nil
+}
+
+// verifyISO compares the SHA-256 hash of each file in the ISO with that of
+// the file that it was copied to, on the partitions that it was last written
+// to by provisionISO.
+func (i *Installer) verifyISO() (err error) {
+	if len(i.written) == 0 {
+		return fmt.Errorf("no partitions were written: %w", errVerify)
+	}
+	roots := make(map[string]string)
+	for role, part := range i.written {
+		if part.MountPoint() == "" {
+			return fmt.Errorf("%s partition is not available: %w", role, errMount)
+		}
+		roots[role] = extendedPath(host.root(part.MountPoint()))
+	}
+	path := filepath.Join(i.cache, i.config.ImageFile())
+	handler, err := mount(path)
+	if err != nil {
+		return fmt.Errorf("mount(%q) returned %v: %w", path, err, errMount)
+	}
+	defer func() {
+		if err2 := handler.Dismount(); err2 != nil && err == nil {
+			err = fmt.Errorf("Dismount() for %q returned %v: %w", handler.MountPath(), err2, errMount)
+		}
+	}()
+
+	src := extendedPath(handler.MountPath())
+	verified := 0
+	err = filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		role := partitionRole(filepath.ToSlash(rel), i.config.PartitionRules())
+		root, ok := roots[role]
+		if !ok {
+			return fmt.Errorf("%q is placed on a %s partition, which was not written", rel, role)
+		}
+		dest := filepath.Join(root, rel)
+		// An answer file of the ISO was replaced by the configured one.
+		if i.config.Unattend() != "" && role == config.BootPartition && strings.EqualFold(filepath.ToSlash(rel), i.config.UnattendDest()) {
+			deck.InfofA("%q was replaced by the answer file %q.", rel, i.config.Unattend()).With(debug.V(debug.Copy, 2)).Go()
+			return nil
+		}
+		// Split WIM files cannot be compared with their parts, which are
+		// only checked for existence.
+		if needsSplit(filepath.ToSlash(rel), info.Size(), i.config.PartitionRules()) {
+			deck.InfofA("%q was split, checking that %q exists.", rel, swmPath(dest)).With(debug.V(debug.Copy, 2)).Go()
+			if _, err := os.Stat(swmPath(dest)); err != nil {
+				return err
+			}
+			verified++
+			return nil
+		}
+		if err := verifyFile(file, dest); err != nil {
+			return err
+		}
+		verified++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errVerify, err)
+	}
+	console.Printf("Verified %d files.", verified)
+	return nil
+}
+
+// verifyFile compares the SHA-256 hash of the file at src with that of the
+// file at dest, which is read without the cache where possible.
+func verifyFile(src, dest string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	want, _, err := hashOf(s)
+	if err != nil {
+		return fmt.Errorf("hashing %q returned %v", src, err)
+	}
+	d, err := openUncachedFunc(dest)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	got, _, err := hashOf(newAlignedReader(d))
+	if err != nil {
+		return fmt.Errorf("hashing %q returned %v", dest, err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%q has SHA-256 %s, want %s", dest, hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+	deck.InfofA("Verified %q.", dest).With(debug.V(debug.Copy, 3)).Go()
+	return nil
+}
+
+// hashOf returns the SHA-256 hash of the contents of r and their length.
+func hashOf(r io.Reader) ([]byte, int64, error) {
+	h := sha256.New()
+	n, err := copyBuffer(h, r)
+	if err != nil {
+		return nil, n, err
+	}
+	return h.Sum(nil), n, nil
+}
+
+// alignedReader reads from a file opened for uncached reads using a buffer
+// whose address and length are multiples of uncachedAlign, as direct reads
+// require.
+type alignedReader struct {
+	r       io.Reader
+	buf     []byte
+	pending []byte
+	err     error
+}
+
+func newAlignedReader(r io.Reader) *alignedReader {
+	b := make([]byte, copyBufferSize+uncachedAlign)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) % uintptr(uncachedAlign)); rem != 0 {
+		off = uncachedAlign - rem
+	}
+	return &alignedReader{r: r, buf: b[off : off+copyBufferSize]}
+}
+
+func (a *alignedReader) Read(p []byte) (int, error) {
+	for len(a.pending) == 0 {
+		if a.err != nil {
+			return 0, a.err
+		}
+		n, err := a.r.Read(a.buf)
+		a.pending, a.err = a.buf[:n], err
+	}
+	n := copy(p, a.pending)
+	a.pending = a.pending[n:]
+	return n, nil
+}
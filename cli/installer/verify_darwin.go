@@ -0,0 +1,36 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+// openUncached opens the file or device at path for reading with F_NOCACHE
+// set, so that reads are not served from the unified buffer cache.
+func openUncached(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_NOCACHE, 1); errno != 0 {
+		deck.InfofA("F_NOCACHE for %q returned %v, reading through the cache.", path, errno).With(debug.V(debug.Storage, 2)).Go()
+	}
+	return f, nil
+}
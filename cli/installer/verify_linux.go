@@ -0,0 +1,36 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/debug"
+)
+
+// openUncached opens the file or device at path for reading with O_DIRECT,
+// so that reads bypass the page cache. File systems that do not support
+// direct reads fall back to cached reads.
+func openUncached(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_DIRECT, 0)
+	if errors.Is(err, syscall.EINVAL) {
+		deck.InfofA("Direct reads of %q are not available, reading through the cache: %v", path, err).With(debug.V(debug.Storage, 2)).Go()
+		return os.Open(path)
+	}
+	return f, err
+}
@@ -0,0 +1,268 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/fresnel/cli/config"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		desc      string
+		installer *Installer
+		want      error
+	}{
+		{
+			desc:      "missing config",
+			installer: &Installer{},
+			want:      errConfig,
+		},
+		{
+			desc:      "applied image",
+			installer: &Installer{config: &fakeConfig{imageFile: "install.wim"}},
+		},
+		{
+			desc:      "unknown image type",
+			installer: &Installer{config: &fakeConfig{imageFile: "image.zip"}},
+			want:      errVerify,
+		},
+		{
+			desc:      "iso without written partitions",
+			installer: &Installer{config: &fakeConfig{imageFile: "image.iso"}},
+			want:      errVerify,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.installer.Verify(&fakeDevice{}); !errors.Is(got, tt.want) {
+			t.Errorf("%s: Verify() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyRaw(t *testing.T) {
+	fakeCache, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(fakeCache)
+	img := sparseImage()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(img); err != nil {
+		t.Fatalf("Write() for gzip returned %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() for gzip returned %v", err)
+	}
+	corrupt := append([]byte{}, img...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	files := map[string][]byte{
+		"fake.img":    img,
+		"fake.img.gz": gz.Bytes(),
+		// Devices are usually larger than the image written to them.
+		"device":  append(append([]byte{}, img...), bytes.Repeat([]byte{1}, 4096)...),
+		"corrupt": corrupt,
+		"short":   img[:len(img)/2],
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(fakeCache, name), contents, 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		image  string
+		device string
+		want   error
+	}{
+		{
+			desc:   "match",
+			image:  "fake.img",
+			device: "device",
+		},
+		{
+			desc:   "compressed image match",
+			image:  "fake.img.gz",
+			device: "device",
+		},
+		{
+			desc:   "corrupt device",
+			image:  "fake.img",
+			device: "corrupt",
+			want:   errVerify,
+		},
+		{
+			desc:   "device shorter than image",
+			image:  "fake.img",
+			device: "short",
+			want:   errVerify,
+		},
+		{
+			desc:   "missing image",
+			image:  "missing.img",
+			device: "device",
+			want:   errPath,
+		},
+		{
+			desc:   "device open error",
+			image:  "fake.img",
+			device: "missing",
+			want:   errDevice,
+		},
+	}
+	for _, tt := range tests {
+		openUncachedFunc = func(string) (*os.File, error) { return os.Open(filepath.Join(fakeCache, tt.device)) }
+		i := &Installer{cache: fakeCache, config: &fakeConfig{imageFile: tt.image}}
+		if got := i.verifyRaw(&fakeDevice{id: "sdz"}); !errors.Is(got, tt.want) {
+			t.Errorf("%s: verifyRaw() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+	openUncachedFunc = openUncached
+}
+
+func TestVerifyISO(t *testing.T) {
+	iso, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+	}
+	defer os.RemoveAll(iso)
+	files := map[string]string{
+		"autounattend.xml": "iso answers",
+		"bootmgr":          "boot manager",
+		"sources/boot.wim": "boot image",
+		"drivers/net.inf":  "driver",
+	}
+	for name, contents := range files {
+		p := filepath.Join(iso, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(p), err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", p, err)
+		}
+	}
+	rules := []config.PartitionRule{{Glob: "drivers", Role: config.DataPartition}}
+
+	tests := []struct {
+		desc     string
+		rules    []config.PartitionRule
+		unattend string
+		boot     map[string]string
+		data     map[string]string
+		noMount  bool
+		want     error
+	}{
+		{
+			desc: "match",
+			boot: files,
+		},
+		{
+			desc:  "match with partition rules",
+			rules: rules,
+			boot:  map[string]string{"autounattend.xml": "iso answers", "bootmgr": "boot manager", "sources/boot.wim": "boot image"},
+			data:  map[string]string{"drivers/net.inf": "driver"},
+		},
+		{
+			desc:     "replaced answer file",
+			unattend: "answers.xml",
+			boot:     map[string]string{"autounattend.xml": "answers", "bootmgr": "boot manager", "sources/boot.wim": "boot image", "drivers/net.inf": "driver"},
+		},
+		{
+			desc: "changed answer file",
+			boot: map[string]string{"autounattend.xml": "answers", "bootmgr": "boot manager", "sources/boot.wim": "boot image", "drivers/net.inf": "driver"},
+			want: errVerify,
+		},
+		{
+			desc: "corrupt file",
+			boot: map[string]string{"autounattend.xml": "iso answers", "bootmgr": "boot manager", "sources/boot.wim": "boot imagf", "drivers/net.inf": "driver"},
+			want: errVerify,
+		},
+		{
+			desc: "missing file",
+			boot: map[string]string{"autounattend.xml": "iso answers", "bootmgr": "boot manager", "drivers/net.inf": "driver"},
+			want: errVerify,
+		},
+		{
+			desc:  "file on the wrong partition",
+			rules: rules,
+			boot:  files,
+			want:  errVerify,
+		},
+		{
+			desc:    "partition not mounted",
+			boot:    files,
+			noMount: true,
+			want:    errMount,
+		},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatalf(`ioutil.TempDir("", "") returned %v`, err)
+		}
+		written := make(map[string]partition)
+		for role, contents := range map[string]map[string]string{config.BootPartition: tt.boot, config.DataPartition: tt.data} {
+			if contents == nil {
+				continue
+			}
+			root := filepath.Join(dir, role)
+			for name, c := range contents {
+				p := filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(p), err)
+				}
+				if err := ioutil.WriteFile(p, []byte(c), 0644); err != nil {
+					t.Fatalf("ioutil.WriteFile(%q) returned %v", p, err)
+				}
+			}
+			if tt.noMount {
+				root = ""
+			}
+			written[role] = &fakePartition{mount: root}
+		}
+		mount = func(string) (isoHandler, error) { return &fakeHandler{mount: iso}, nil }
+		conf := &fakeConfig{imageFile: "fake.iso", partitions: tt.rules}
+		if tt.unattend != "" {
+			conf.unattend, conf.unattendTo = tt.unattend, "autounattend.xml"
+		}
+		i := &Installer{config: conf, written: written}
+		if got := i.verifyISO(); !errors.Is(got, tt.want) {
+			t.Errorf("%s: verifyISO() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		os.RemoveAll(dir)
+	}
+	mount = mountISO
+}
+
+func TestAlignedReader(t *testing.T) {
+	want := bytes.Repeat([]byte("fresnel"), copyBufferSize/3)
+	got, err := ioutil.ReadAll(newAlignedReader(iotest.HalfReader(bytes.NewReader(want))))
+	if err != nil {
+		t.Fatalf("ReadAll() returned %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("alignedReader read %d bytes that do not match the %d written", len(got), len(want))
+	}
+}
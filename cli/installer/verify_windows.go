@@ -0,0 +1,37 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileFlagNoBuffering is FILE_FLAG_NO_BUFFERING from winbase.h.
+const fileFlagNoBuffering = 0x20000000
+
+// openUncached opens the file or device at path for reading without
+// buffering by the system cache.
+func openUncached(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL|fileFlagNoBuffering, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "CreateFile", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/deck"
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/cli/console"
+	"github.com/google/fresnel/cli/debug"
+	"github.com/google/fresnel/models"
+)
+
+const (
+	// fat32MaxFileSize is the size of the largest file that FAT32 can hold.
+	fat32MaxFileSize = 1<<32 - 1
+	// swmPartMB is the largest size of each part of a split WIM, leaving
+	// headroom below the FAT32 limit.
+	swmPartMB = 3800
+)
+
+// needsSplit reports whether the file at rel, a slash separated path relative
+// to the root of an ISO, is a WIM that is split into .swm parts rather than
+// copied, because it is too large for the FAT32 boot partition that it is
+// placed on.
+func needsSplit(rel string, size int64, rules []config.PartitionRule) bool {
+	return size > fat32MaxFileSize && strings.EqualFold(path.Ext(rel), ".wim") && partitionRole(rel, rules) == config.BootPartition
+}
+
+// swmPath returns the path of the first part of the split WIM at path, such
+// as 'sources/install.swm' for 'sources/install.wim'. Setup finds further
+// parts, such as 'install2.swm', beside it.
+func swmPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".swm"
+}
+
+// oversizedFiles returns the WIM files beneath src, slash separated and
+// relative to it, that are too large for the FAT32 boot partition they are
+// placed on by rules and are split rather than copied. Any other file that is
+// too large is an error, as it can only be written when a partition rule
+// places it on the data partition.
+func oversizedFiles(src string, rules []config.PartitionRule) (map[string]bool, error) {
+	split := make(map[string]bool)
+	src = extendedPath(src)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Size() <= fat32MaxFileSize {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if partitionRole(rel, rules) != config.BootPartition {
+			return nil
+		}
+		if !needsSplit(rel, info.Size(), rules) {
+			return fmt.Errorf("%w: %q is %s, larger than FAT32 allows, place it on the data partition with a partition rule", errTooLarge, rel, humanize.Bytes(uint64(info.Size())))
+		}
+		deck.InfofA("%q is %s and will be split for FAT32.", rel, humanize.Bytes(uint64(info.Size()))).With(debug.V(debug.Copy, 2)).Go()
+		split[rel] = true
+		return nil
+	})
+	if errors.Is(err, errTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: checking file sizes in %q: %v", errIO, src, err)
+	}
+	return split, nil
+}
+
+// splitFile splits the WIM at file, which is placed at dest, into .swm parts
+// beside dest, recording each part in contents when it is not nil.
+func splitFile(file, dest, rel string, contents *models.ContentManifest) error {
+	swm := swmPath(dest)
+	console.Printf("Splitting %s into parts small enough for FAT32. This can take some time.", rel)
+	deck.InfofA("Splitting %q into %q.", file, swm).With(debug.V(debug.Copy, 2)).Go()
+	if err := splitWIM(file, swm, swmPartMB); err != nil {
+		return fmt.Errorf("splitting %q returned %v", rel, err)
+	}
+	if contents == nil {
+		return nil
+	}
+	base := strings.TrimSuffix(filepath.Base(swm), ".swm")
+	parts, err := filepath.Glob(filepath.Join(filepath.Dir(swm), base+"*.swm"))
+	if err != nil {
+		return fmt.Errorf("filepath.Glob() for the parts of %q returned %v", swm, err)
+	}
+	for _, p := range parts {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		h, err := fileHash(p, models.HashSHA256)
+		if err != nil {
+			return err
+		}
+		contents.Files = append(contents.Files, models.ContentFile{
+			Path:      path.Join(path.Dir(rel), filepath.Base(p)),
+			Partition: config.BootPartition,
+			Size:      info.Size(),
+			SHA256:    hex.EncodeToString(h),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package installer
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// splitImage splits the WIM at path into .swm parts of at most sizeMB,
+// the first of which is written to swm, using wimlib-imagex from wimlib.
+func splitImage(path, swm string, sizeMB int) error {
+	if _, err := exec.LookPath("wimlib-imagex"); err != nil {
+		return fmt.Errorf("splitting a WIM requires wimlib-imagex, install wimlib: %w", errUnsupported)
+	}
+	return run("wimlib-imagex", "split", path, swm, fmt.Sprint(sizeMB))
+}
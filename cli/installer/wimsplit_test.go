@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/cli/config"
+	"github.com/google/fresnel/models"
+)
+
+func TestNeedsSplit(t *testing.T) {
+	rules := []config.PartitionRule{{Glob: "sources/install.wim", Role: config.DataPartition}}
+	tests := []struct {
+		desc  string
+		rel   string
+		size  int64
+		rules []config.PartitionRule
+		want  bool
+	}{
+		{
+			desc: "small wim",
+			rel:  "sources/install.wim",
+			size: fat32MaxFileSize,
+			want: false,
+		},
+		{
+			desc: "large wim",
+			rel:  "sources/install.WIM",
+			size: fat32MaxFileSize + 1,
+			want: true,
+		},
+		{
+			desc: "large other file",
+			rel:  "sources/install.esd",
+			size: fat32MaxFileSize + 1,
+			want: false,
+		},
+		{
+			desc:  "large wim on the data partition",
+			rel:   "sources/install.wim",
+			size:  fat32MaxFileSize + 1,
+			rules: rules,
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		if got := needsSplit(tt.rel, tt.size, tt.rules); got != tt.want {
+			t.Errorf("%s: needsSplit(%q, %d) got: %t, want: %t", tt.desc, tt.rel, tt.size, got, tt.want)
+		}
+	}
+}
+
+// fakeLargeISO returns a directory with the contents of an ISO, in which the
+// file at large is sparse and too large for FAT32.
+func fakeLargeISO(t *testing.T, large string) string {
+	t.Helper()
+	src, err := ioutil.TempDir("", "iso")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "iso") returned %v`, err)
+	}
+	for _, f := range []string{"bootmgr", "sources/boot.wim", large} {
+		path := filepath.Join(src, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(%q) returned %v", path, err)
+		}
+	}
+	if err := os.Truncate(filepath.Join(src, filepath.FromSlash(large)), fat32MaxFileSize+1); err != nil {
+		t.Fatalf("os.Truncate(%q) returned %v", large, err)
+	}
+	return src
+}
+
+func TestWriteISOSplit(t *testing.T) {
+	src := fakeLargeISO(t, "sources/install.wim")
+	defer os.RemoveAll(src)
+	boot, err := ioutil.TempDir("", "boot")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "boot") returned %v`, err)
+	}
+	defer os.RemoveAll(boot)
+	splitWIM = func(path, swm string, sizeMB int) error {
+		if sizeMB != swmPartMB {
+			return errors.New("unexpected part size")
+		}
+		for _, p := range []string{swm, strings.TrimSuffix(swm, ".swm") + "2.swm"} {
+			if err := ioutil.WriteFile(p, []byte("part"), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	defer func() { splitWIM = splitImage }()
+
+	iso := &fakeISO{mount: src, contents: []string{"bootmgr", "sources"}}
+	parts := map[string]partition{config.BootPartition: &fakePartition{mount: boot}}
+	opts := copyOptions{contents: &models.ContentManifest{}}
+	if err := writeISO(iso, parts, opts); err != nil {
+		t.Fatalf("writeISO() returned %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(boot, "sources", "install.wim")); !os.IsNotExist(err) {
+		t.Errorf("writeISO() copied install.wim, want it split")
+	}
+	var got []string
+	for _, f := range opts.contents.Files {
+		got = append(got, f.Path)
+	}
+	want := "bootmgr sources/boot.wim sources/install.swm sources/install2.swm"
+	if strings.Join(got, " ") != want {
+		t.Errorf("writeISO() recorded %v, want: %s", got, want)
+	}
+}
+
+func TestWriteISOTooLarge(t *testing.T) {
+	src := fakeLargeISO(t, "sources/install.esd")
+	defer os.RemoveAll(src)
+	boot, err := ioutil.TempDir("", "boot")
+	if err != nil {
+		t.Fatalf(`ioutil.TempDir("", "boot") returned %v`, err)
+	}
+	defer os.RemoveAll(boot)
+
+	iso := &fakeISO{mount: src, contents: []string{"bootmgr", "sources"}}
+	parts := map[string]partition{config.BootPartition: &fakePartition{mount: boot}}
+	if err := writeISO(iso, parts, copyOptions{}); !errors.Is(err, errTooLarge) {
+		t.Errorf("writeISO() got: %v, want: %v", err, errTooLarge)
+	}
+	// Nothing is copied when a file cannot be written.
+	if _, err := os.Stat(filepath.Join(boot, "bootmgr")); !os.IsNotExist(err) {
+		t.Errorf("writeISO() copied files before failing")
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installer
+
+import "fmt"
+
+// splitImage splits the WIM at path into .swm parts of at most sizeMB,
+// the first of which is written to swm.
+func splitImage(path, swm string, sizeMB int) error {
+	return run("dism.exe", "/Split-Image", "/ImageFile:"+path, "/SWMFile:"+swm, fmt.Sprintf("/FileSize:%d", sizeMB))
+}
@@ -26,8 +26,21 @@ import (
 	"syscall"
 
 	// Register subcommands.
+	_ "github.com/google/fresnel/cli/commands/capture"
+	_ "github.com/google/fresnel/cli/commands/eject"
+	_ "github.com/google/fresnel/cli/commands/endpoints"
 	_ "github.com/google/fresnel/cli/commands/list"
+	_ "github.com/google/fresnel/cli/commands/locate"
+	_ "github.com/google/fresnel/cli/commands/netboot"
+	_ "github.com/google/fresnel/cli/commands/receive"
+	_ "github.com/google/fresnel/cli/commands/restore"
+	_ "github.com/google/fresnel/cli/commands/stream"
+	_ "github.com/google/fresnel/cli/commands/verify"
+	_ "github.com/google/fresnel/cli/commands/verifyseed"
+	"github.com/google/fresnel/cli/commands/version"
 	_ "github.com/google/fresnel/cli/commands/write"
+	"github.com/google/fresnel/cli/schema"
+	"github.com/google/fresnel/cli/wsl"
 	"github.com/google/deck/backends/logger"
 	"github.com/google/deck"
 
@@ -38,8 +51,30 @@ import (
 var (
 	binaryName = filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
 	logFile    *os.File
+
+	showVersion = flag.Bool("version", false, "report the version and build information and exit")
+	showSchema  = flag.String("schema", "", "print the JSON schema of a machine-readable output and exit, one of "+strings.Join(schema.Names(), ", "))
+	wslNative   = flag.Bool("wsl_native", false, "under WSL, access devices attached with usbipd directly rather than through the Windows build")
 )
 
+// deviceCommand is implemented by subcommands that access devices, which are
+// delegated to the Windows build of the binary when running under WSL.
+type deviceCommand interface {
+	AccessesDevices() bool
+}
+
+// deviceCommands returns the names of the registered subcommands that access
+// devices, including every alias of the write subcommand.
+func deviceCommands() map[string]bool {
+	names := make(map[string]bool)
+	subcommands.DefaultCommander.VisitCommands(func(_ *subcommands.CommandGroup, c subcommands.Command) {
+		if d, ok := c.(deviceCommand); ok && d.AccessesDevices() {
+			names[c.Name()] = true
+		}
+	})
+	return names
+}
+
 func setupLogging() error {
 	// Initialize logging with the bare binary name as the source.
 	lp := filepath.Join(os.TempDir(), fmt.Sprintf(`%s.log`, binaryName))
@@ -72,6 +107,37 @@ func main() {
 	subcommands.Register(subcommands.FlagsCommand(), "")
 	subcommands.Register(subcommands.CommandsCommand(), "")
 
+	flag.Parse()
+	if *showVersion {
+		if err := version.Write(os.Stdout, false); err != nil {
+			deck.Errorf("version.Write() returned %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if *showSchema != "" {
+		if err := schema.Write(os.Stdout, *showSchema); err != nil {
+			deck.Errorf("schema.Write() returned %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Devices attached to Windows are not visible under WSL, so device
+	// operations are delegated to the Windows build through interop. The
+	// Windows build runs with the rights of the user that started WSL, and
+	// cannot elevate itself, so WSL must be started from an elevated prompt
+	// for commands that write to devices.
+	if deviceCommands()[flag.Arg(0)] && !*wslNative && wsl.Detected() {
+		deck.InfofA("Running under WSL, delegating %q to the Windows build of %s.", flag.Arg(0), binaryName).With(deck.V(1)).Go()
+		code, err := wsl.Delegate(binaryName, flag.Args())
+		if err != nil {
+			fmt.Println(err)
+			deck.Error(err)
+		}
+		os.Exit(code)
+	}
+
 	if flag.NArg() < 1 {
 		deck.Error("ERROR: No command specified.")
 	}
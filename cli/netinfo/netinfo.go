@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netinfo describes the network interfaces of the machine running the
+// CLI, so that requests to the sign server can identify it.
+package netinfo
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+var (
+	// Dependency injections for testing.
+	interfaces = net.Interfaces
+)
+
+// virtualOUIs are the vendor prefixes that hypervisors assign to the virtual
+// adapters they create, which do not identify the machine.
+var virtualOUIs = map[string]bool{
+	"00:05:69": true, // VMware
+	"00:0c:29": true, // VMware
+	"00:1c:14": true, // VMware
+	"00:50:56": true, // VMware
+	"00:15:5d": true, // Hyper-V
+	"00:16:3e": true, // Xen
+	"00:1c:42": true, // Parallels
+	"08:00:27": true, // VirtualBox
+}
+
+// MACs returns the MAC addresses of the physical network interfaces of the
+// machine, formatted as colon separated lowercase hex and in sorted order.
+// Interfaces that are down are included, as an unplugged network adapter
+// still identifies the machine. Loopback interfaces, virtual adapters such as
+// those of hypervisors, VPNs and containers, and locally administered
+// addresses, which are often randomized, are excluded.
+func MACs() ([]string, error) {
+	ifaces, err := interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("net.Interfaces() returned %v", err)
+	}
+	seen := make(map[string]bool)
+	var macs []string
+	for _, iface := range ifaces {
+		if !hardware(iface) || !physical(iface) {
+			continue
+		}
+		mac := iface.HardwareAddr.String()
+		if seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+	return macs, nil
+}
+
+// hardware reports whether iface has a universally administered unicast
+// Ethernet address that was not assigned by a hypervisor, regardless of the
+// platform.
+func hardware(iface net.Interface) bool {
+	addr := iface.HardwareAddr
+	if iface.Flags&net.FlagLoopback != 0 || len(addr) != 6 {
+		return false
+	}
+	// The least significant bits of the first octet mark multicast and
+	// locally administered addresses.
+	if addr[0]&0x03 != 0 {
+		return false
+	}
+	return !virtualOUIs[addr[:3].String()]
+}
@@ -0,0 +1,27 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"strings"
+)
+
+// physical reports whether iface is an Ethernet or Wi-Fi adapter, which macOS
+// names en0, en1 and so on. Virtual interfaces use other names, such as
+// bridge0, utun0, awdl0 and vmenet0.
+func physical(iface net.Interface) bool {
+	return strings.HasPrefix(iface.Name, "en")
+}
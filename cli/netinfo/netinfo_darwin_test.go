@@ -0,0 +1,40 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPhysical(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "en0", want: true},
+		{name: "en7", want: true},
+		{name: "bridge0"},
+		{name: "utun3"},
+		{name: "awdl0"},
+		{name: "llw0"},
+		{name: "vmenet0"},
+	}
+	for _, tt := range tests {
+		if got := physical(net.Interface{Name: tt.name}); got != tt.want {
+			t.Errorf("physical(%q) got: %t, want: %t", tt.name, got, tt.want)
+		}
+	}
+}
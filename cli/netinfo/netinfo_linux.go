@@ -0,0 +1,33 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// sysClassNet lists the network interfaces known to the kernel. It is replaced
+// for testing.
+var sysClassNet = "/sys/class/net"
+
+// physical reports whether iface is backed by a device, such as a PCI or USB
+// network adapter. Virtual interfaces, such as bridges, tunnels and those of
+// containers, have no device.
+func physical(iface net.Interface) bool {
+	_, err := os.Stat(filepath.Join(sysClassNet, iface.Name, "device"))
+	return err == nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMACs(t *testing.T) {
+	origInterfaces, origSys := interfaces, sysClassNet
+	defer func() { interfaces, sysClassNet = origInterfaces, origSys }()
+	sysClassNet = t.TempDir()
+	// Only physical interfaces have a device in sysfs.
+	for _, name := range []string{"eth0", "wlan0", "eth1"} {
+		if err := os.MkdirAll(filepath.Join(sysClassNet, name, "device"), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(%q) returned %v", name, err)
+		}
+	}
+	interfaces = func() ([]net.Interface, error) {
+		return []net.Interface{
+			{Name: "lo", Flags: net.FlagLoopback},
+			{Name: "wlan0", HardwareAddr: mustMAC(t, "f0:18:98:ab:cd:ef")},
+			{Name: "eth0", HardwareAddr: mustMAC(t, "3c:22:fb:12:34:56")},
+			{Name: "br0", HardwareAddr: mustMAC(t, "3c:22:fb:12:34:56")},
+			{Name: "virbr0", HardwareAddr: mustMAC(t, "52:54:00:12:34:56")},
+			{Name: "docker0", HardwareAddr: mustMAC(t, "02:42:ac:11:00:02")},
+			{Name: "eth1", HardwareAddr: mustMAC(t, "02:00:00:00:00:01")},
+		}, nil
+	}
+	got, err := MACs()
+	if err != nil {
+		t.Fatalf("MACs() returned %v", err)
+	}
+	want := []string{"3c:22:fb:12:34:56", "f0:18:98:ab:cd:ef"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MACs() got: %q, want: %q", got, want)
+	}
+}
+
+func TestPhysical(t *testing.T) {
+	orig := sysClassNet
+	defer func() { sysClassNet = orig }()
+	sysClassNet = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(sysClassNet, "enp3s0", "device"), 0755); err != nil {
+		t.Fatalf("os.MkdirAll() returned %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(sysClassNet, "veth1a2b"), 0755); err != nil {
+		t.Fatalf("os.MkdirAll() returned %v", err)
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "enp3s0", want: true},
+		{name: "veth1a2b"},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		if got := physical(net.Interface{Name: tt.name}); got != tt.want {
+			t.Errorf("physical(%q) got: %t, want: %t", tt.name, got, tt.want)
+		}
+	}
+}
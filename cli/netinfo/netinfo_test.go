@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func mustMAC(t *testing.T, s string) net.HardwareAddr {
+	t.Helper()
+	addr, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatalf("net.ParseMAC(%q) returned %v", s, err)
+	}
+	return addr
+}
+
+func TestHardware(t *testing.T) {
+	tests := []struct {
+		desc  string
+		iface net.Interface
+		want  bool
+	}{
+		{desc: "ethernet", iface: net.Interface{Name: "eth0", HardwareAddr: mustMAC(t, "3c:22:fb:12:34:56"), Flags: net.FlagUp}, want: true},
+		{desc: "ethernet down", iface: net.Interface{Name: "eth0", HardwareAddr: mustMAC(t, "3c:22:fb:12:34:56"), Flags: 0}, want: true},
+		{desc: "loopback", iface: net.Interface{Name: "lo", HardwareAddr: mustMAC(t, "3c:22:fb:12:34:56"), Flags: net.FlagLoopback}},
+		{desc: "no address", iface: net.Interface{Name: "tun0"}},
+		{desc: "infiniband", iface: net.Interface{Name: "ib0", HardwareAddr: mustMAC(t, "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01")}},
+		{desc: "locally administered", iface: net.Interface{Name: "docker0", HardwareAddr: mustMAC(t, "02:42:ac:11:00:02")}},
+		{desc: "multicast", iface: net.Interface{Name: "eth1", HardwareAddr: mustMAC(t, "01:00:5e:00:00:01")}},
+		{desc: "hypervisor", iface: net.Interface{Name: "vmnet1", HardwareAddr: mustMAC(t, "00:50:56:c0:00:01")}},
+	}
+	for _, tt := range tests {
+		if got := hardware(tt.iface); got != tt.want {
+			t.Errorf("%s: hardware() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMACsError(t *testing.T) {
+	orig := interfaces
+	defer func() { interfaces = orig }()
+	interfaces = func() ([]net.Interface, error) { return nil, errors.New("error") }
+	if _, err := MACs(); err == nil {
+		t.Errorf("MACs() got: nil error, want: error")
+	}
+}
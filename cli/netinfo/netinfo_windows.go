@@ -0,0 +1,52 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"strings"
+)
+
+// virtualNames are found in the names that Windows and common software give
+// to virtual adapters, compared without regard to case.
+var virtualNames = []string{
+	"vethernet",
+	"virtual",
+	"vmware",
+	"virtualbox",
+	"hyper-v",
+	"loopback",
+	"pseudo",
+	"teredo",
+	"isatap",
+	"bluetooth",
+	"vpn",
+	"tap-",
+	"wsl",
+}
+
+// physical reports whether iface is a network adapter rather than a virtual
+// adapter, such as the vEthernet adapters of Hyper-V and WSL or those of VPN
+// clients. Windows does not report whether an adapter is backed by hardware
+// through net.Interfaces, so virtual adapters are recognized by name.
+func physical(iface net.Interface) bool {
+	name := strings.ToLower(iface.Name)
+	for _, v := range virtualNames {
+		if strings.Contains(name, v) {
+			return false
+		}
+	}
+	return true
+}
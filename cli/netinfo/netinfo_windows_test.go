@@ -0,0 +1,43 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netinfo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPhysical(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "Ethernet", want: true},
+		{name: "Wi-Fi", want: true},
+		{name: "Ethernet 2", want: true},
+		{name: "vEthernet (Default Switch)"},
+		{name: "vEthernet (WSL)"},
+		{name: "VirtualBox Host-Only Network"},
+		{name: "VMware Network Adapter VMnet8"},
+		{name: "Loopback Pseudo-Interface 1"},
+		{name: "Bluetooth Network Connection"},
+		{name: "Corp VPN"},
+	}
+	for _, tt := range tests {
+		if got := physical(net.Interface{Name: tt.name}); got != tt.want {
+			t.Errorf("physical(%q) got: %t, want: %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify shows desktop notifications, so that a user who has walked
+// away from a long running operation can see that it has finished.
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+var (
+	// Dependency injections for testing.
+	getenv     = os.Getenv
+	isTerminal = stdinIsTerminal
+	run        = runCommand
+)
+
+// Enabled reports whether notifications can be shown in this session.
+// Notifications are disabled in non-interactive sessions, such as scheduled
+// tasks and scripts, where there is nobody to see them.
+func Enabled() bool {
+	return isTerminal() && hasDesktop()
+}
+
+// Send shows a desktop notification with the provided title and message.
+func Send(title, message string) error {
+	name, args := command(title, message)
+	return run(name, args...)
+}
+
+// stdinIsTerminal reports whether standard input is attached to a terminal.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// runCommand runs name with args, returning its output on failure.
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %v returned %v: %s", name, args, err, out)
+	}
+	return nil
+}
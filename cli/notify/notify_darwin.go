@@ -0,0 +1,41 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+// +build darwin
+
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hasDesktop reports whether a graphical session is available. Sessions on
+// macOS always have one, unless connected remotely.
+func hasDesktop() bool {
+	return getenv("SSH_CONNECTION") == ""
+}
+
+// command returns an osascript command that displays a notification.
+func command(title, message string) (string, []string) {
+	script := fmt.Sprintf("display notification %s with title %s", quote(message), quote(title))
+	return "osascript", []string{"-e", script}
+}
+
+// quote returns s as an AppleScript string literal.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package notify
+
+import (
+	"os"
+	"strconv"
+)
+
+// appName identifies the source of notifications to the notification daemon.
+const appName = "fresnel"
+
+// Dependency injections for testing.
+var geteuid = os.Geteuid
+
+// hasDesktop reports whether a graphical session is available.
+func hasDesktop() bool {
+	return getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != ""
+}
+
+// command returns a notify-send command, which is provided by libnotify. Root
+// has no desktop session, so when running as root through sudo the command is
+// run as the user who invoked sudo, on the session bus of that user.
+func command(title, message string) (string, []string) {
+	args := []string{"--app-name=" + appName, "--", title, message}
+	user, uid := getenv("SUDO_USER"), getenv("SUDO_UID")
+	if geteuid() != 0 || user == "" {
+		return "notify-send", args
+	}
+	if _, err := strconv.Atoi(uid); err != nil {
+		return "notify-send", args
+	}
+	// sudo removes the address of the session bus from the environment
+	// unless asked to preserve it, in which case it is already that of the
+	// user. Otherwise, the bus is at its default location.
+	bus := getenv("DBUS_SESSION_BUS_ADDRESS")
+	if bus == "" {
+		bus = "unix:path=/run/user/" + uid + "/bus"
+	}
+	return "sudo", append([]string{"-u", user, "env", "DBUS_SESSION_BUS_ADDRESS=" + bus, "notify-send"}, args...)
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package notify
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestHasDesktop(t *testing.T) {
+	tests := []struct {
+		desc string
+		env  map[string]string
+		want bool
+	}{
+		{
+			desc: "no display",
+			want: false,
+		},
+		{
+			desc: "x11",
+			env:  map[string]string{"DISPLAY": ":0"},
+			want: true,
+		},
+		{
+			desc: "wayland",
+			env:  map[string]string{"WAYLAND_DISPLAY": "wayland-0"},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		env := tt.env
+		getenv = func(key string) string { return env[key] }
+		if got := hasDesktop(); got != tt.want {
+			t.Errorf("%s: hasDesktop() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	defer func() { geteuid = os.Geteuid }()
+	tests := []struct {
+		desc     string
+		euid     int
+		env      map[string]string
+		wantName string
+		wantArgs []string
+	}{
+		{
+			desc:     "user",
+			euid:     1000,
+			env:      map[string]string{"SUDO_USER": "user", "SUDO_UID": "1000"},
+			wantName: "notify-send",
+			// Titles beginning with a dash must not be treated as flags.
+			wantArgs: []string{"--app-name=fresnel", "--", "-title", "message"},
+		},
+		{
+			desc:     "root",
+			wantName: "notify-send",
+			wantArgs: []string{"--app-name=fresnel", "--", "-title", "message"},
+		},
+		{
+			desc:     "root with an invalid uid",
+			env:      map[string]string{"SUDO_USER": "user", "SUDO_UID": "user"},
+			wantName: "notify-send",
+			wantArgs: []string{"--app-name=fresnel", "--", "-title", "message"},
+		},
+		{
+			desc:     "sudo",
+			env:      map[string]string{"SUDO_USER": "user", "SUDO_UID": "1000"},
+			wantName: "sudo",
+			wantArgs: []string{"-u", "user", "env", "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus", "notify-send", "--app-name=fresnel", "--", "-title", "message"},
+		},
+		{
+			desc:     "sudo preserving the session bus",
+			env:      map[string]string{"SUDO_USER": "user", "SUDO_UID": "1000", "DBUS_SESSION_BUS_ADDRESS": "unix:path=/tmp/bus"},
+			wantName: "sudo",
+			wantArgs: []string{"-u", "user", "env", "DBUS_SESSION_BUS_ADDRESS=unix:path=/tmp/bus", "notify-send", "--app-name=fresnel", "--", "-title", "message"},
+		},
+	}
+	for _, tt := range tests {
+		env, euid := tt.env, tt.euid
+		getenv = func(key string) string { return env[key] }
+		geteuid = func() int { return euid }
+		name, args := command("-title", "message")
+		if name != tt.wantName {
+			t.Errorf("%s: command() name got: %q, want: %q", tt.desc, name, tt.wantName)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: command() args got: %v, want: %v", tt.desc, args, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		desc     string
+		terminal bool
+		env      map[string]string
+		want     bool
+	}{
+		{
+			desc:     "non-interactive",
+			terminal: false,
+			env:      map[string]string{"DISPLAY": ":0", "SESSIONNAME": "Console"},
+			want:     false,
+		},
+		{
+			desc:     "interactive",
+			terminal: true,
+			env:      map[string]string{"DISPLAY": ":0", "SESSIONNAME": "Console"},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		terminal, env := tt.terminal, tt.env
+		isTerminal = func() bool { return terminal }
+		getenv = func(key string) string { return env[key] }
+		if got := Enabled(); got != tt.want {
+			t.Errorf("%s: Enabled() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		desc   string
+		runErr error
+		want   error
+	}{
+		{
+			desc:   "command error",
+			runErr: errors.New("error"),
+			want:   errors.New("error"),
+		},
+		{
+			desc: "success",
+		},
+	}
+	for _, tt := range tests {
+		var gotArgs []string
+		runErr := tt.runErr
+		run = func(name string, args ...string) error {
+			gotArgs = args
+			return runErr
+		}
+		err := Send("Provisioning complete", "It's done")
+		if (err != nil) != (tt.want != nil) {
+			t.Errorf("%s: Send() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if len(gotArgs) == 0 {
+			t.Errorf("%s: Send() ran a command with no arguments", tt.desc)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resources tracks the resources held by the CLI, such as open file
+// handles, mounts and goroutines, at the boundaries between the phases of
+// provisioning. Comparing them at exit with those held at startup finds leaks
+// that accumulate over long sessions and eventually break provisioning.
+// Nothing is tracked until Enable is called.
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/deck"
+)
+
+var (
+	// Dependency injections for testing.
+	now          = time.Now
+	numGoroutine = runtime.NumGoroutine
+	openHandles  = countHandles
+	listMounts   = mountPoints
+
+	mu      sync.Mutex
+	samples []Usage // nil when tracking is disabled.
+)
+
+// Usage describes the resources held at the end of a phase.
+type Usage struct {
+	Phase      string
+	Time       time.Time
+	Goroutines int
+	Handles    int      // Open file descriptors or handles, -1 when unknown.
+	Mounts     []string // The mount points of the system, sorted. nil when unknown.
+}
+
+// String describes the resources in u for logging.
+func (u Usage) String() string {
+	handles := "unknown"
+	if u.Handles >= 0 {
+		handles = fmt.Sprint(u.Handles)
+	}
+	mounts := "unknown"
+	if u.Mounts != nil {
+		mounts = fmt.Sprint(len(u.Mounts))
+	}
+	return fmt.Sprintf("%d goroutines, %s open handles, %s mounts", u.Goroutines, handles, mounts)
+}
+
+// Enable begins tracking resources, recording those held at startup.
+func Enable() {
+	mu.Lock()
+	samples = []Usage{}
+	mu.Unlock()
+	Sample("start")
+}
+
+// Enabled reports whether resources are being tracked.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return samples != nil
+}
+
+// Sample records the resources held at the end of phase, and logs any change
+// from the previous phase. It does nothing when tracking is disabled.
+func Sample(phase string) {
+	if !Enabled() {
+		return
+	}
+	u := usage(phase)
+	mu.Lock()
+	defer mu.Unlock()
+	if n := len(samples); n > 0 {
+		prev := samples[n-1]
+		deck.InfofA("Resources after %s: %v (%+d goroutines, %s handles, %d mounts added, %d removed since %s).",
+			phase, u, u.Goroutines-prev.Goroutines, handleDelta(prev, u), len(added(prev.Mounts, u.Mounts)), len(added(u.Mounts, prev.Mounts)), prev.Phase).Go()
+	} else {
+		deck.InfofA("Resources at %s: %v.", phase, u).Go()
+	}
+	samples = append(samples, u)
+}
+
+// Samples returns the resources recorded so far, in the order they were
+// recorded.
+func Samples() []Usage {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Usage(nil), samples...)
+}
+
+// Leaks records the resources held at exit and describes those that were not
+// held at startup. Goroutines are only reported when there are more than at
+// startup, and their stacks are logged to help find where they were started.
+// It returns nil when nothing leaked or tracking is disabled.
+func Leaks() []string {
+	if !Enabled() {
+		return nil
+	}
+	Sample("exit")
+	mu.Lock()
+	start, exit := samples[0], samples[len(samples)-1]
+	mu.Unlock()
+
+	var leaks []string
+	if d := exit.Goroutines - start.Goroutines; d > 0 {
+		leaks = append(leaks, fmt.Sprintf("%d goroutines were started and not stopped (%d at start, %d at exit)", d, start.Goroutines, exit.Goroutines))
+		var b bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&b, 1); err == nil {
+			deck.InfofA("Goroutines at exit:\n%s", b.String()).Go()
+		}
+	}
+	if start.Handles >= 0 && exit.Handles > start.Handles {
+		leaks = append(leaks, fmt.Sprintf("%d file handles were opened and not closed (%d at start, %d at exit)", exit.Handles-start.Handles, start.Handles, exit.Handles))
+	}
+	if start.Mounts != nil && exit.Mounts != nil {
+		for _, m := range added(start.Mounts, exit.Mounts) {
+			leaks = append(leaks, fmt.Sprintf("%q was mounted and not dismounted", m))
+		}
+	}
+	return leaks
+}
+
+// Disable stops tracking resources and discards those recorded.
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	samples = nil
+}
+
+// usage returns the resources currently held, recorded for phase. Resources
+// that cannot be counted on this platform are logged and reported as unknown.
+func usage(phase string) Usage {
+	u := Usage{Phase: phase, Time: now(), Goroutines: numGoroutine(), Handles: -1}
+	if h, err := openHandles(); err != nil {
+		deck.Warningf("Counting open handles after %s: %v", phase, err)
+	} else {
+		u.Handles = h
+	}
+	if m, err := listMounts(); err != nil {
+		deck.Warningf("Listing mounts after %s: %v", phase, err)
+	} else {
+		sort.Strings(m)
+		u.Mounts = append([]string{}, m...)
+	}
+	return u
+}
+
+// handleDelta describes the change in open handles from prev to u.
+func handleDelta(prev, u Usage) string {
+	if prev.Handles < 0 || u.Handles < 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%+d", u.Handles-prev.Handles)
+}
+
+// added returns the mount points in after that are not in before.
+func added(before, after []string) []string {
+	seen := make(map[string]bool)
+	for _, m := range before {
+		seen[m] = true
+	}
+	var out []string
+	for _, m := range after {
+		if !seen[m] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
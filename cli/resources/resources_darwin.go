@@ -0,0 +1,52 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// regExMount matches the mount point in a line of output from mount, such as
+// '/dev/disk4s1 on /Volumes/INSTALLER (msdos, local, nodev, nosuid)'.
+var regExMount = regexp.MustCompile(` on (.+) \(`)
+
+// countHandles returns the number of file descriptors open in the process,
+// excluding the one used to list them.
+func countHandles() (int, error) {
+	fds, err := ioutil.ReadDir("/dev/fd")
+	if err != nil {
+		return 0, fmt.Errorf("ioutil.ReadDir(/dev/fd) returned %v", err)
+	}
+	return len(fds) - 1, nil
+}
+
+// mountPoints returns the mount points listed by mount.
+func mountPoints() ([]string, error) {
+	out, err := exec.Command("mount").Output()
+	if err != nil {
+		return nil, fmt.Errorf("mount returned %v", err)
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := regExMount.FindStringSubmatch(line); m != nil {
+			mounts = append(mounts, m[1])
+		}
+	}
+	return mounts, nil
+}
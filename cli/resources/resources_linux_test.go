@@ -0,0 +1,36 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import "testing"
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{desc: "plain", in: "/media/usb", want: "/media/usb"},
+		{desc: "space", in: `/media/USB\040DISK`, want: "/media/USB DISK"},
+		{desc: "tab and backslash", in: `/a\011b\134c`, want: "/a\tb\\c"},
+		{desc: "trailing backslash", in: `/a\`, want: `/a\`},
+		{desc: "not octal", in: `/a\9xy`, want: `/a\9xy`},
+	}
+	for _, tt := range tests {
+		if got := unescape(tt.in); got != tt.want {
+			t.Errorf("%s: unescape(%q) got: %q, want: %q", tt.desc, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLeaks(t *testing.T) {
+	defer func() {
+		numGoroutine = runtime.NumGoroutine
+		openHandles = countHandles
+		listMounts = mountPoints
+		Disable()
+	}()
+	type usage struct {
+		goroutines int
+		handles    int
+		handleErr  error
+		mounts     []string
+		mountErr   error
+	}
+	tests := []struct {
+		desc  string
+		start usage
+		exit  usage
+		want  []string // Substrings of the leaks expected, in order.
+	}{
+		{
+			desc:  "no leaks",
+			start: usage{goroutines: 2, handles: 5, mounts: []string{"/", "/boot"}},
+			exit:  usage{goroutines: 2, handles: 5, mounts: []string{"/boot", "/"}},
+		},
+		{
+			desc:  "fewer at exit",
+			start: usage{goroutines: 4, handles: 8, mounts: []string{"/", "/media/usb"}},
+			exit:  usage{goroutines: 2, handles: 5, mounts: []string{"/"}},
+		},
+		{
+			desc:  "goroutines",
+			start: usage{goroutines: 2, handles: 5, mounts: []string{"/"}},
+			exit:  usage{goroutines: 5, handles: 5, mounts: []string{"/"}},
+			want:  []string{"3 goroutines"},
+		},
+		{
+			desc:  "handles and mounts",
+			start: usage{goroutines: 2, handles: 5, mounts: []string{"/"}},
+			exit:  usage{goroutines: 2, handles: 7, mounts: []string{"/", "/tmp/cache/sdc1", "/tmp/cache/iso"}},
+			want:  []string{"2 file handles", "/tmp/cache/iso", "/tmp/cache/sdc1"},
+		},
+		{
+			desc:  "unknown handles and mounts",
+			start: usage{goroutines: 2, handleErr: errors.New("error"), mountErr: errors.New("error")},
+			exit:  usage{goroutines: 2, handles: 9, mounts: []string{"/media/usb"}},
+		},
+	}
+	for _, tt := range tests {
+		u := tt.start
+		numGoroutine = func() int { return u.goroutines }
+		openHandles = func() (int, error) { return u.handles, u.handleErr }
+		listMounts = func() ([]string, error) { return u.mounts, u.mountErr }
+		Enable()
+		// Phases between start and exit do not affect the result.
+		u = usage{goroutines: 50, handles: 50, mounts: []string{"/mnt"}}
+		Sample("retrieve")
+		u = tt.exit
+		got := Leaks()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Leaks() got: %q, want: %q", tt.desc, got, tt.want)
+			continue
+		}
+		for n, w := range tt.want {
+			if !strings.Contains(got[n], w) {
+				t.Errorf("%s: Leaks()[%d] got: %q, want it to contain: %q", tt.desc, n, got[n], w)
+			}
+		}
+		if phases := len(Samples()); phases != 3 {
+			t.Errorf("%s: Leaks() recorded %d phases, want: 3", tt.desc, phases)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Disable()
+	Sample("retrieve")
+	if Enabled() || len(Samples()) != 0 {
+		t.Errorf("Sample() while disabled recorded %v", Samples())
+	}
+	if got := Leaks(); got != nil {
+		t.Errorf("Leaks() while disabled got: %q, want: nil", got)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	// The resources of the test itself can be counted on every platform.
+	if _, err := countHandles(); err != nil {
+		t.Errorf("countHandles() returned %v", err)
+	}
+	if _, err := mountPoints(); err != nil {
+		t.Errorf("mountPoints() returned %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"unsafe"
+
+	win "golang.org/x/sys/windows"
+)
+
+var procGetProcessHandleCount = win.NewLazySystemDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+
+// countHandles returns the number of handles open in the process.
+func countHandles() (int, error) {
+	var count uint32
+	r, _, err := procGetProcessHandleCount.Call(uintptr(win.CurrentProcess()), uintptr(unsafe.Pointer(&count)))
+	if r == 0 {
+		return 0, fmt.Errorf("GetProcessHandleCount() returned %v", err)
+	}
+	return int(count), nil
+}
+
+// mountPoints returns the drive letters that are in use, such as 'E:\'.
+func mountPoints() ([]string, error) {
+	drives, err := win.GetLogicalDrives()
+	if err != nil {
+		return nil, fmt.Errorf("GetLogicalDrives() returned %v", err)
+	}
+	var mounts []string
+	for i := 0; i < 26; i++ {
+		if drives&(1<<uint(i)) != 0 {
+			mounts = append(mounts, string(rune('A'+i))+`:\`)
+		}
+	}
+	return mounts, nil
+}
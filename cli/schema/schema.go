@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema publishes the JSON schemas of the machine-readable output of
+// the CLI, so that automation can validate what it consumes. Schemas are
+// versioned by major version: within a major version, fields are only ever
+// added, and existing fields keep their names, types and meaning. Removing or
+// changing a field requires a new major version, published alongside the
+// previous one.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Version is the current major version of the schemas.
+const Version = 1
+
+// The names of the schemas.
+const (
+	// Devices describes the devices listed by the list sub-command.
+	Devices = "devices"
+	// Inventory describes the inventory written by the write sub-command.
+	Inventory = "inventory"
+	// Events describes each of the progress events written by the write
+	// sub-command.
+	Events = "events"
+	// Marker describes the batch marker stored on media.
+	Marker = "marker"
+)
+
+var (
+	//go:embed v1/*.json
+	files embed.FS
+
+	// Wrapped errors for testing.
+	errSchema = errors.New("unknown schema")
+)
+
+// Names returns the names of the schemas of the current version, in order.
+func Names() []string {
+	entries, err := files.ReadDir(dir(Version))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the schema of the current version with the provided name.
+func Get(name string) ([]byte, error) {
+	b, err := files.ReadFile(path.Join(dir(Version), name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not one of %v", errSchema, name, Names())
+	}
+	return b, nil
+}
+
+// Properties returns the names of the properties of the objects described by
+// the schema with the provided name, or of the items of a schema of an array,
+// in order. Producers of output use them to check that every field they write
+// is described.
+func Properties(name string) ([]string, error) {
+	b, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	var d document
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", errSchema, name, err)
+	}
+	if d.Items != nil {
+		d = *d.Items
+	}
+	var props []string
+	for p := range d.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+	return props, nil
+}
+
+// document models the parts of a schema that describe its properties.
+type document struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Items      *document                  `json:"items"`
+}
+
+// Write writes the schema with the provided name to w.
+func Write(w io.Writer, name string) error {
+	b, err := Get(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// dir returns the directory of the schemas of major version v.
+func dir(v int) string {
+	return fmt.Sprintf("v%d", v)
+}
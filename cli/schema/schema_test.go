@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+// header models the fields of a schema that identify it.
+type header struct {
+	ID    string `json:"$id"`
+	Type  string `json:"type"`
+	Items *struct {
+		Type string `json:"type"`
+	} `json:"items"`
+}
+
+func TestNames(t *testing.T) {
+	want := []string{Devices, Events, Inventory, Marker}
+	if got := Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() got: %v, want: %v", got, want)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	for _, name := range Names() {
+		b, err := Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q) returned %v", name, err)
+		}
+		var h header
+		if err := json.Unmarshal(b, &h); err != nil {
+			t.Errorf("%s: json.Unmarshal() returned %v", name, err)
+			continue
+		}
+		if want := fmt.Sprintf("https://github.com/google/fresnel/cli/schema/v%d/%s.json", Version, name); h.ID != want {
+			t.Errorf("%s: $id got: %q, want: %q", name, h.ID, want)
+		}
+		typ := h.Type
+		if h.Items != nil {
+			typ = h.Items.Type
+		}
+		if typ != "object" {
+			t.Errorf("%s: got type %q, want objects", name, typ)
+		}
+		if props, err := Properties(name); err != nil || len(props) == 0 {
+			t.Errorf("%s: Properties() got: %v, %v, want properties", name, props, err)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, Events); err != nil {
+		t.Errorf("Write(%q) returned %v", Events, err)
+	}
+	if !json.Valid(b.Bytes()) {
+		t.Errorf("Write(%q) got: %q, want valid JSON", Events, b.String())
+	}
+	if err := Write(&b, "unknown"); !errors.Is(err, errSchema) {
+		t.Errorf("Write(%q) got: %v, want: %v", "unknown", err, errSchema)
+	}
+}
+
+// TestMarker checks that the marker schema describes every field of the
+// marker, so that fields added to it are also added to the schema.
+func TestMarker(t *testing.T) {
+	b, err := json.Marshal(models.BatchMarker{})
+	if err != nil {
+		t.Fatalf("json.Marshal() returned %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("json.Unmarshal() returned %v", err)
+	}
+	var want []string
+	for f := range fields {
+		want = append(want, f)
+	}
+	sort.Strings(want)
+	got, err := Properties(Marker)
+	if err != nil {
+		t.Fatalf("Properties(%q) returned %v", Marker, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("marker schema properties got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace records timing spans for the steps of provisioning, and
+// writes them to a local file in the OpenTelemetry Protocol (OTLP) JSON
+// encoding, so that runs can be compared in standard trace viewers.
+//
+// Provisioning is sequential, so spans do not carry a context. A span that is
+// begun while another is open becomes its child. Nothing is recorded until
+// Enable is called, and the functions are safe to call when tracing is
+// disabled.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/fresnel/cli/version"
+)
+
+// scopeName identifies the instrumentation that produced the spans.
+const scopeName = "github.com/google/fresnel/cli"
+
+var (
+	// Dependency injection for testing.
+	now      = time.Now
+	randRead = rand.Read
+
+	mu  sync.Mutex
+	rec *recorder // nil when tracing is disabled.
+
+	// ErrTrace is returned when a trace cannot be written.
+	ErrTrace = errors.New("trace error")
+)
+
+// Attribute is a key and value recorded with a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attr returns an Attribute.
+func Attr(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a timed step of provisioning. A nil Span is valid, and is returned
+// when tracing is disabled.
+type Span struct {
+	name   string
+	id     string
+	parent string
+	start  time.Time
+	end    time.Time
+	attrs  []Attribute
+	err    error
+}
+
+// recorder holds the spans of a single trace.
+type recorder struct {
+	traceID string
+	open    []*Span // Spans that have begun but not ended, innermost last.
+	spans   []*Span // Every span, in the order they began.
+}
+
+// Enable begins recording a new trace, discarding any recorded previously.
+func Enable() error {
+	id, err := randomID(16)
+	if err != nil {
+		return fmt.Errorf("%w: generating trace ID: %v", ErrTrace, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	rec = &recorder{traceID: id}
+	return nil
+}
+
+// Disable stops recording and discards the trace.
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	rec = nil
+}
+
+// Begin starts a span named name, as a child of the innermost span that is
+// still open. It returns nil when tracing is disabled.
+func Begin(name string, attrs ...Attribute) *Span {
+	mu.Lock()
+	defer mu.Unlock()
+	if rec == nil {
+		return nil
+	}
+	id, err := randomID(8)
+	if err != nil {
+		return nil
+	}
+	s := &Span{name: name, id: id, start: now(), attrs: attrs}
+	if n := len(rec.open); n > 0 {
+		s.parent = rec.open[n-1].id
+	}
+	rec.open = append(rec.open, s)
+	rec.spans = append(rec.spans, s)
+	return s
+}
+
+// SetAttr records an additional attribute with the span.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.attrs = append(s.attrs, Attr(key, value))
+}
+
+// End ends the span, recording err as its status. Spans that began within
+// it and are still open are ended with it.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = now()
+	s.err = err
+	if rec == nil {
+		return
+	}
+	for n := len(rec.open) - 1; n >= 0; n-- {
+		o := rec.open[n]
+		rec.open = rec.open[:n]
+		if o == s {
+			break
+		}
+		o.end = s.end
+	}
+}
+
+// Write writes the spans recorded so far to path. Spans that are still open
+// are written as ending now.
+func Write(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if rec == nil {
+		return fmt.Errorf("%w: tracing is not enabled", ErrTrace)
+	}
+	content, err := json.MarshalIndent(rec.export(now()), "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: json.MarshalIndent() returned %v", ErrTrace, err)
+	}
+	// Permissions = owner:read/write, group:read"
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("%w: ioutil.WriteFile(%q) returned %v", ErrTrace, path, err)
+	}
+	return nil
+}
+
+// The following types model the parts of the OTLP JSON encoding that are
+// needed to describe spans.
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpTrace struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// Span kinds and status codes used in the OTLP encoding.
+const (
+	kindInternal = 1
+	statusOK     = 1
+	statusError  = 2
+)
+
+// export converts the recorded spans to the OTLP encoding. Open spans end at
+// end.
+func (r *recorder) export(end time.Time) otlpTrace {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: scopeName, Version: version.Version}}
+	for _, s := range r.spans {
+		e := s.end
+		if e.IsZero() {
+			e = end
+		}
+		o := otlpSpan{
+			TraceID:           r.traceID,
+			SpanID:            s.id,
+			ParentSpanID:      s.parent,
+			Name:              s.name,
+			Kind:              kindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(e.UnixNano(), 10),
+			Attributes:        attributes(s.attrs),
+			Status:            otlpStatus{Code: statusOK},
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, o)
+	}
+	return otlpTrace{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: attributes([]Attribute{
+			Attr("service.name", "fresnel"),
+			Attr("service.version", version.Version),
+		})},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+func attributes(attrs []Attribute) []otlpAttribute {
+	var o []otlpAttribute
+	for _, a := range attrs {
+		o = append(o, otlpAttribute{Key: a.Key, Value: otlpValue{StringValue: a.Value}})
+	}
+	return o
+}
+
+// randomID returns n random bytes, hex encoded as OTLP JSON expects.
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := randRead(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
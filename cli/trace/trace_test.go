@@ -0,0 +1,177 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeClock returns times that advance by a second on each call.
+func fakeClock() func() time.Time {
+	t := time.Unix(1700000000, 0)
+	return func() time.Time {
+		t = t.Add(time.Second)
+		return t
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Disable()
+	s := Begin("download")
+	if s != nil {
+		t.Errorf("Begin() while disabled got: %v, want: nil", s)
+	}
+	// Methods of a nil span must be safe to call.
+	s.SetAttr("file", "image.iso")
+	s.End(errors.New("error"))
+	if err := Write(filepath.Join(t.TempDir(), "trace.json")); !errors.Is(err, ErrTrace) {
+		t.Errorf("Write() while disabled got: %v, want: %v", err, ErrTrace)
+	}
+}
+
+func TestBegin(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = fakeClock()
+	if err := Enable(); err != nil {
+		t.Fatalf("Enable() returned %v", err)
+	}
+	defer Disable()
+
+	root := Begin("provision")
+	device := Begin("device", Attr("device.id", "1"))
+	cp := Begin("copy")
+	cp.End(nil)
+	seed := Begin("seed")
+	// Ending a span ends any spans within it that are still open.
+	device.End(errors.New("error"))
+	next := Begin("device", Attr("device.id", "2"))
+	next.End(nil)
+	root.End(nil)
+
+	tests := []struct {
+		desc       string
+		span       *Span
+		wantParent *Span
+		wantErr    bool
+	}{
+		{desc: "root", span: root},
+		{desc: "device", span: device, wantParent: root, wantErr: true},
+		{desc: "copy", span: cp, wantParent: device},
+		{desc: "seed", span: seed, wantParent: device},
+		{desc: "next device", span: next, wantParent: root},
+	}
+	for _, tt := range tests {
+		want := ""
+		if tt.wantParent != nil {
+			want = tt.wantParent.id
+		}
+		if tt.span.parent != want {
+			t.Errorf("%s: Begin() parent got: %q, want: %q", tt.desc, tt.span.parent, want)
+		}
+		if tt.span.end.IsZero() || !tt.span.end.After(tt.span.start) {
+			t.Errorf("%s: End() got start: %v, end: %v", tt.desc, tt.span.start, tt.span.end)
+		}
+		if (tt.span.err != nil) != tt.wantErr {
+			t.Errorf("%s: End() err: %v, want error: %t", tt.desc, tt.span.err, tt.wantErr)
+		}
+	}
+	if !seed.end.Equal(device.end) {
+		t.Errorf("End() of parent ended child at %v, want: %v", seed.end, device.end)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = fakeClock()
+	if err := Enable(); err != nil {
+		t.Fatalf("Enable() returned %v", err)
+	}
+	defer Disable()
+	root := Begin("provision", Attr("distribution", "windows"))
+	Begin("download", Attr("file", "image.iso")).End(errors.New("not found"))
+	// root is left open, and is written as ending at the time of Write.
+
+	path := filepath.Join(t.TempDir(), "trace.json")
+	if err := Write(path); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(%q) returned %v", path, err)
+	}
+	var got otlpTrace
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("json.Unmarshal() returned %v", err)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Write() got %+v, want a single resource and scope", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Write() got %d spans, want: 2", len(spans))
+	}
+	tests := []struct {
+		desc       string
+		span       otlpSpan
+		wantName   string
+		wantParent string
+		wantStatus int
+		wantAttr   string
+	}{
+		{
+			desc:       "open root",
+			span:       spans[0],
+			wantName:   "provision",
+			wantStatus: statusOK,
+			wantAttr:   "distribution",
+		},
+		{
+			desc:       "failed child",
+			span:       spans[1],
+			wantName:   "download",
+			wantParent: root.id,
+			wantStatus: statusError,
+			wantAttr:   "file",
+		},
+	}
+	for _, tt := range tests {
+		if tt.span.Name != tt.wantName {
+			t.Errorf("%s: Write() name got: %q, want: %q", tt.desc, tt.span.Name, tt.wantName)
+		}
+		if len(tt.span.TraceID) != 32 || len(tt.span.SpanID) != 16 {
+			t.Errorf("%s: Write() got trace ID %q and span ID %q, want 32 and 16 hex digits", tt.desc, tt.span.TraceID, tt.span.SpanID)
+		}
+		if tt.span.ParentSpanID != tt.wantParent {
+			t.Errorf("%s: Write() parent got: %q, want: %q", tt.desc, tt.span.ParentSpanID, tt.wantParent)
+		}
+		if tt.span.Status.Code != tt.wantStatus {
+			t.Errorf("%s: Write() status got: %d, want: %d", tt.desc, tt.span.Status.Code, tt.wantStatus)
+		}
+		if len(tt.span.Attributes) != 1 || tt.span.Attributes[0].Key != tt.wantAttr {
+			t.Errorf("%s: Write() attributes got: %+v, want: %q", tt.desc, tt.span.Attributes, tt.wantAttr)
+		}
+		if tt.span.EndTimeUnixNano <= tt.span.StartTimeUnixNano {
+			t.Errorf("%s: Write() got start: %s, end: %s", tt.desc, tt.span.StartTimeUnixNano, tt.span.EndTimeUnixNano)
+		}
+	}
+	if err := Write(filepath.Join(t.TempDir(), "missing", "trace.json")); !errors.Is(err, ErrTrace) {
+		t.Errorf("Write() to a missing directory got: %v, want: %v", err, ErrTrace)
+	}
+}
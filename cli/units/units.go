@@ -0,0 +1,139 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package units provides typed byte sizes, along with helpers for parsing
+// them from human-readable strings such as "8G" or "1.5T".
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of bytes.
+type Size uint64
+
+// Sizes are binary, so that a KB is 1024 bytes.
+const (
+	Byte Size = 1
+	KB        = 1024 * Byte
+	MB        = 1024 * KB
+	GB        = 1024 * MB
+	TB        = 1024 * GB
+	PB        = 1024 * TB
+)
+
+var (
+	// Wrapped errors for testing.
+	errSize = errors.New("invalid size")
+
+	// suffixes maps the accepted suffixes, in upper case, to their size.
+	suffixes = map[string]Size{
+		"B": Byte,
+		"K": KB, "KB": KB, "KIB": KB,
+		"M": MB, "MB": MB, "MIB": MB,
+		"G": GB, "GB": GB, "GIB": GB,
+		"T": TB, "TB": TB, "TIB": TB,
+		"P": PB, "PB": PB, "PIB": PB,
+	}
+
+	// names lists the units used when formatting a size, largest first.
+	names = []struct {
+		size Size
+		name string
+	}{
+		{PB, "P"},
+		{TB, "T"},
+		{GB, "G"},
+		{MB, "M"},
+		{KB, "K"},
+	}
+)
+
+// Parse parses a size such as "8G", "1.5T" or "512MB". Suffixes are case
+// insensitive and binary, so "K", "KB" and "KiB" all represent 1024 bytes.
+// Numbers without a suffix are a count of unit, which allows callers to keep
+// the meaning of values that were previously a plain number of, for example,
+// GB. Fractional sizes are rounded to the nearest byte, and negative sizes are
+// refused.
+func Parse(s string, unit Size) (Size, error) {
+	s = strings.TrimSpace(s)
+	// Sizes are unsigned, so a negative size would otherwise wrap around to a
+	// very large one.
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("%w: %q is negative", errSize, s)
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end == -1 {
+		end = len(s)
+	}
+	if end == 0 {
+		return 0, fmt.Errorf("%w: %q does not start with a number", errSize, s)
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q: %v", errSize, s, err)
+	}
+	mult := unit
+	if suffix := strings.ToUpper(strings.TrimSpace(s[end:])); suffix != "" {
+		var ok bool
+		if mult, ok = suffixes[suffix]; !ok {
+			return 0, fmt.Errorf("%w: %q has an unknown suffix %q", errSize, s, s[end:])
+		}
+	}
+	bytes := math.Round(n * float64(mult))
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("%w: %q is too large", errSize, s)
+	}
+	return Size(bytes), nil
+}
+
+// String formats the size using the largest unit that it contains at least
+// one of, with up to two decimal places, such as "1.5G".
+func (s Size) String() string {
+	for _, n := range names {
+		if s >= n.size {
+			v := strconv.FormatFloat(float64(s)/float64(n.size), 'f', 2, 64)
+			return strings.TrimRight(strings.TrimRight(v, "0"), ".") + n.name
+		}
+	}
+	return strconv.FormatUint(uint64(s), 10) + "B"
+}
+
+// Value is a Size that implements flag.Value, so that sizes can be provided
+// on the command line. Values without a suffix are a count of Unit.
+type Value struct {
+	Size Size
+	Unit Size
+}
+
+// Set parses s as a size and implements flag.Value.
+func (v *Value) Set(s string) error {
+	size, err := Parse(s, v.Unit)
+	if err != nil {
+		return err
+	}
+	v.Size = size
+	return nil
+}
+
+// String implements flag.Value.
+func (v *Value) String() string {
+	return v.Size.String()
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package units
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		desc    string
+		in      string
+		unit    Size
+		want    Size
+		wantErr error
+	}{
+		{
+			desc: "bare number",
+			in:   "8",
+			unit: GB,
+			want: 8 * GB,
+		},
+		{
+			desc: "bare number in bytes",
+			in:   "512",
+			unit: Byte,
+			want: 512,
+		},
+		{
+			desc: "short suffix",
+			in:   "512M",
+			unit: GB,
+			want: 512 * MB,
+		},
+		{
+			desc: "long suffix",
+			in:   "2GB",
+			unit: Byte,
+			want: 2 * GB,
+		},
+		{
+			desc: "binary suffix",
+			in:   "4KiB",
+			unit: Byte,
+			want: 4 * KB,
+		},
+		{
+			desc: "lower case with space",
+			in:   " 1 t ",
+			unit: Byte,
+			want: TB,
+		},
+		{
+			desc: "fractional",
+			in:   "1.5G",
+			unit: Byte,
+			want: GB + 512*MB,
+		},
+		{
+			desc: "fractional bare number",
+			in:   "0.5",
+			unit: GB,
+			want: 512 * MB,
+		},
+		{
+			desc:    "empty",
+			in:      "",
+			unit:    GB,
+			wantErr: errSize,
+		},
+		{
+			desc:    "negative",
+			in:      "-1G",
+			unit:    GB,
+			wantErr: errSize,
+		},
+		{
+			desc:    "unknown suffix",
+			in:      "8X",
+			unit:    GB,
+			wantErr: errSize,
+		},
+		{
+			desc:    "malformed number",
+			in:      "1.2.3G",
+			unit:    GB,
+			wantErr: errSize,
+		},
+		{
+			desc:    "too large",
+			in:      "100000P",
+			unit:    GB,
+			wantErr: errSize,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.unit)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Parse(%q) returned error: %v, want: %v", tt.desc, tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Parse(%q) got: %d, want: %d", tt.desc, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Size
+		want string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{KB, "1K"},
+		{GB + 512*MB, "1.5G"},
+		{2 * TB, "2T"},
+		{1000 * 1000 * 1000, "953.67M"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Size(%d).String() got: %q, want: %q", uint64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	v := &Value{Size: 2 * GB, Unit: GB}
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.Var(v, "size", "")
+	if err := f.Parse([]string{"--size=1.5T"}); err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	if want := TB + 512*GB; v.Size != want {
+		t.Errorf("Value got: %d, want: %d", v.Size, want)
+	}
+	for _, in := range []string{"bad", "-1"} {
+		if err := v.Set(in); !errors.Is(err, errSize) {
+			t.Errorf("Set(%q) got: %v, want: %v", in, err, errSize)
+		}
+	}
+	if err := f.Parse([]string{"--size=-8G"}); err == nil {
+		t.Errorf("Parse(%q) returned nil, want an error", "--size=-8G")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version identifies the running build of the CLI to users and to
+// the servers it makes requests to.
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/google/fresnel/models"
+)
+
+// Version is the release version of the CLI. It is injected at build time
+// using '-ldflags "-X github.com/google/fresnel/cli/version.Version=1.2.3"'.
+var Version = "dev"
+
+// Commit and BuildDate identify the source revision that the CLI was built
+// from and when, and are injected at build time in the same way as Version.
+// When not injected, they are read from the version control information
+// embedded by the Go toolchain, if any.
+var (
+	Commit    = ""
+	BuildDate = ""
+)
+
+// readBuildInfo is replaced for testing.
+var readBuildInfo = debug.ReadBuildInfo
+
+// Info describes the build of the CLI, for reporting to users and fleet
+// dashboards.
+type Info struct {
+	Binary         string `json:"binary"`
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	BuildDate      string `json:"build_date"`
+	GoVersion      string `json:"go_version"`
+	Platform       string `json:"platform"`
+	ConfigRevision string `json:"config_revision"` // Identifies the built-in distribution config.
+}
+
+// Get returns the Info of the running build. configRevision identifies the
+// distribution config built into the CLI, which this package cannot import.
+func Get(configRevision string) Info {
+	i := Info{
+		Binary:         filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``)),
+		Version:        Version,
+		Commit:         Commit,
+		BuildDate:      BuildDate,
+		GoVersion:      runtime.Version(),
+		Platform:       runtime.GOOS + "/" + runtime.GOARCH,
+		ConfigRevision: configRevision,
+	}
+	bi, ok := readBuildInfo()
+	if !ok {
+		return i
+	}
+	modified := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if i.Commit == "" {
+				i.Commit = s.Value
+			}
+		case "vcs.time":
+			if i.BuildDate == "" {
+				i.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	// Builds from a modified tree do not match the commit they report.
+	if modified && Commit == "" && i.Commit != "" {
+		i.Commit += "-dirty"
+	}
+	return i
+}
+
+// String describes i for display to users.
+func (i Info) String() string {
+	unknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+	return fmt.Sprintf("%s version %s\n  Commit:          %s\n  Built:           %s\n  Go:              %s %s\n  Config revision: %s\n",
+		i.Binary, i.Version, unknown(i.Commit), unknown(i.BuildDate), i.GoVersion, i.Platform, unknown(i.ConfigRevision))
+}
+
+// UserAgent returns a descriptive User-Agent for outbound requests, composed
+// of the binary name, version and platform.
+func UserAgent() string {
+	binaryName := filepath.Base(strings.ReplaceAll(os.Args[0], `.exe`, ``))
+	return fmt.Sprintf("%s/%s (%s; %s)", binaryName, Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// SetHeaders adds the User-Agent and client version headers to an outbound
+// request.
+func SetHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set(models.ClientVersionHeader, Version)
+}
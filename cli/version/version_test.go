@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+func TestUserAgent(t *testing.T) {
+	Version = "1.2.3"
+	got := UserAgent()
+	for _, want := range []string{"/1.2.3", runtime.GOOS, runtime.GOARCH} {
+		if !strings.Contains(got, want) {
+			t.Errorf("UserAgent() got: %q, want contains: %q", got, want)
+		}
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	Version = "1.2.3"
+	req, err := http.NewRequest("GET", "https://foo.bar.com", nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest() returned %v", err)
+	}
+	SetHeaders(req)
+	if got := req.Header.Get(models.ClientVersionHeader); got != Version {
+		t.Errorf("SetHeaders() version header got: %q, want: %q", got, Version)
+	}
+	if got := req.Header.Get("User-Agent"); got != UserAgent() {
+		t.Errorf("SetHeaders() User-Agent got: %q, want: %q", got, UserAgent())
+	}
+}
+
+func TestGet(t *testing.T) {
+	orig := readBuildInfo
+	defer func() { readBuildInfo = orig; Commit = ""; BuildDate = "" }()
+	settings := func(modified string) func() (*debug.BuildInfo, bool) {
+		return func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+				{Key: "vcs.modified", Value: modified},
+			}}, true
+		}
+	}
+	tests := []struct {
+		desc          string
+		commit        string
+		buildDate     string
+		buildInfo     func() (*debug.BuildInfo, bool)
+		wantCommit    string
+		wantBuildDate string
+	}{
+		{
+			desc:      "no build info",
+			buildInfo: func() (*debug.BuildInfo, bool) { return nil, false },
+		},
+		{
+			desc:          "from build info",
+			buildInfo:     settings("false"),
+			wantCommit:    "abc123",
+			wantBuildDate: "2026-01-02T03:04:05Z",
+		},
+		{
+			desc:          "modified tree",
+			buildInfo:     settings("true"),
+			wantCommit:    "abc123-dirty",
+			wantBuildDate: "2026-01-02T03:04:05Z",
+		},
+		{
+			desc:          "injected at build time",
+			commit:        "def456",
+			buildDate:     "2026-02-03",
+			buildInfo:     settings("true"),
+			wantCommit:    "def456",
+			wantBuildDate: "2026-02-03",
+		},
+	}
+	for _, tt := range tests {
+		Version = "1.2.3"
+		Commit = tt.commit
+		BuildDate = tt.buildDate
+		readBuildInfo = tt.buildInfo
+		got := Get("rev")
+		if got.Version != "1.2.3" || got.ConfigRevision != "rev" || got.GoVersion != runtime.Version() {
+			t.Errorf("%s: Get() got: %+v, want version 1.2.3, config revision rev and go version %s", tt.desc, got, runtime.Version())
+		}
+		if got.Commit != tt.wantCommit {
+			t.Errorf("%s: Get() commit got: %q, want: %q", tt.desc, got.Commit, tt.wantCommit)
+		}
+		if got.BuildDate != tt.wantBuildDate {
+			t.Errorf("%s: Get() build date got: %q, want: %q", tt.desc, got.BuildDate, tt.wantBuildDate)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	i := Info{Binary: "writer", Version: "1.2.3", Commit: "abc123", GoVersion: "go1.18", Platform: "linux/amd64"}
+	got := i.String()
+	for _, want := range []string{"writer version 1.2.3", "abc123", "Built:           unknown", "go1.18 linux/amd64", "Config revision: unknown"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("String() got: %q, want contains: %q", got, want)
+		}
+	}
+}
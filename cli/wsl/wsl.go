@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wsl detects when the CLI is running under the Windows Subsystem for
+// Linux, where removable devices attached to Windows are not visible, and
+// delegates device operations to a Windows build of the CLI through WSL
+// interop.
+package wsl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var (
+	// ErrUnsupported is returned when devices cannot be reached from WSL.
+	ErrUnsupported = errors.New("devices attached to Windows cannot be accessed from WSL")
+
+	// Dependency injections for testing.
+	executable = os.Executable
+	lookPath   = exec.LookPath
+	stat       = os.Stat
+	run        = runHelper
+)
+
+// Detected reports whether the CLI is running under WSL.
+func Detected() bool {
+	return detect()
+}
+
+// Helper returns the path of the Windows build of binary, which device
+// operations can be delegated to. It is looked for alongside the running
+// binary, and then on the PATH, which WSL extends with the PATH of Windows.
+func Helper(binary string) (string, error) {
+	name := binary + ".exe"
+	if self, err := executable(); err == nil {
+		p := filepath.Join(filepath.Dir(self), name)
+		if _, err := stat(p); err == nil {
+			return p, nil
+		}
+	}
+	p, err := lookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%q was not found alongside %s or on the PATH: %w", name, binary, ErrUnsupported)
+	}
+	return p, nil
+}
+
+// Delegate runs the Windows build of binary with args through WSL interop,
+// connected to the standard streams of the CLI, and returns its exit code.
+// When the Windows build cannot be found, the error describes the
+// alternatives that are available to the user.
+func Delegate(binary string, args []string) (int, error) {
+	helper, err := Helper(binary)
+	if err != nil {
+		return 1, fmt.Errorf("%w\n\nTo provision devices from WSL, either:\n"+
+			"  - Place %s.exe, the Windows build of this tool, alongside %s or on the Windows PATH. It is then used automatically.\n"+
+			"  - Run %s.exe from PowerShell or a Windows command prompt instead.\n"+
+			"  - Attach the device to WSL with 'usbipd attach --wsl', then run this command again with --wsl_native.\n"+
+			"  - Use a native Linux, macOS or Windows machine", err, binary, binary, binary)
+	}
+	return run(helper, args)
+}
+
+// runHelper runs helper with args, and returns its exit code.
+func runHelper(helper string, args []string) (int, error) {
+	cmd := exec.Command(helper, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return exit.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("running %q returned %v", helper, err)
+	}
+	return 0, nil
+}
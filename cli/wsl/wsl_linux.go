@@ -0,0 +1,32 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wsl
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// osRelease describes the running kernel. The kernels used by WSL identify
+// themselves as built by Microsoft.
+var osRelease = "/proc/sys/kernel/osrelease"
+
+func detect() bool {
+	b, err := ioutil.ReadFile(osRelease)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(b)), "microsoft")
+}
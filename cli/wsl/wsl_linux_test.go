@@ -0,0 +1,48 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wsl
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	orig := osRelease
+	defer func() { osRelease = orig }()
+	dir := t.TempDir()
+	tests := []struct {
+		desc    string
+		release string
+		want    bool
+	}{
+		{desc: "wsl2", release: "5.15.153.1-microsoft-standard-WSL2\n", want: true},
+		{desc: "wsl1", release: "4.4.0-19041-Microsoft\n", want: true},
+		{desc: "linux", release: "6.8.0-45-generic\n", want: false},
+		{desc: "unreadable", want: false},
+	}
+	for _, tt := range tests {
+		osRelease = filepath.Join(dir, tt.desc)
+		if tt.release != "" {
+			if err := ioutil.WriteFile(osRelease, []byte(tt.release), 0644); err != nil {
+				t.Fatalf("ioutil.WriteFile(%q) returned %v", osRelease, err)
+			}
+		}
+		if got := detect(); got != tt.want {
+			t.Errorf("%s: detect() got: %t, want: %t", tt.desc, got, tt.want)
+		}
+	}
+}
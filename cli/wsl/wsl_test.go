@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wsl
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDelegate(t *testing.T) {
+	defer func() {
+		executable = os.Executable
+		lookPath = exec.LookPath
+		stat = os.Stat
+		run = runHelper
+	}()
+	executable = func() (string, error) { return "/opt/fresnel/cli", nil }
+	tests := []struct {
+		desc       string
+		alongside  bool
+		onPath     bool
+		wantHelper string
+		want       error
+	}{
+		{
+			desc:       "alongside",
+			alongside:  true,
+			onPath:     true,
+			wantHelper: filepath.Join("/opt/fresnel", "cli.exe"),
+		},
+		{
+			desc:       "on path",
+			onPath:     true,
+			wantHelper: "/mnt/c/tools/cli.exe",
+		},
+		{
+			desc: "not found",
+			want: ErrUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		alongside, onPath := tt.alongside, tt.onPath
+		stat = func(string) (os.FileInfo, error) {
+			if alongside {
+				return nil, nil
+			}
+			return nil, os.ErrNotExist
+		}
+		lookPath = func(string) (string, error) {
+			if onPath {
+				return "/mnt/c/tools/cli.exe", nil
+			}
+			return "", exec.ErrNotFound
+		}
+		helper := ""
+		var args []string
+		run = func(h string, a []string) (int, error) {
+			helper, args = h, a
+			return 3, nil
+		}
+		code, err := Delegate("cli", []string{"write", "--all"})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Delegate() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), "--wsl_native") {
+				t.Errorf("%s: Delegate() got: %v, want the alternatives listed", tt.desc, err)
+			}
+			continue
+		}
+		if helper != tt.wantHelper || code != 3 || strings.Join(args, " ") != "write --all" {
+			t.Errorf("%s: Delegate() ran %q %v with exit code %d, want: %q [write --all] with exit code 3", tt.desc, helper, args, code, tt.wantHelper)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/fresnel/models"
+)
+
+// ErrBulkNotAllowed is returned when the server refuses a bulk seed request,
+// because the user is not authorized to make them or asked for too many seeds.
+var ErrBulkNotAllowed = errors.New("bulk seed request not allowed")
+
+// BulkSeed obtains count signed seeds for hash, computed using alg, in a
+// single request. It is intended for duplicators that provision many devices
+// from one verified master, and the Client must be created for the /seed/bulk
+// endpoint. Every seed is distinct, so each device is still provisioned with
+// its own seed. As with Seed, the response is returned alongside
+// ErrAlgorithm when the server does not accept alg.
+func (c *Client) BulkSeed(hash []byte, alg models.HashAlgorithm, count int) (*models.BulkSeedResponse, error) {
+	if len(hash) == 0 {
+		return nil, fmt.Errorf("missing hash: %w", ErrInput)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("%d seeds requested: %w", count, ErrInput)
+	}
+	br := &models.BulkSeedRequest{
+		Request: models.SeedRequest{
+			Hash:      hash,
+			Algorithm: alg,
+			Hostname:  c.Hostname,
+			OS:        c.OS,
+			Version:   c.Version,
+			Batch:     c.Batch,
+			Fields:    c.Fields,
+		},
+		Count: count,
+	}
+	respBody, err := c.post(br)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(respBody), "not in allowlist") {
+		return nil, fmt.Errorf("%w: %q", ErrNotAllowed, hex.EncodeToString(hash))
+	}
+
+	r := &models.BulkSeedResponse{}
+	if err := json.Unmarshal(respBody, r); err != nil {
+		return nil, fmt.Errorf("json.Unmarhsal(%s) returned %v: %w", respBody, err, ErrFormat)
+	}
+	switch r.ErrorCode {
+	case models.StatusSuccess:
+	case models.StatusUnsupportedHash:
+		return r, fmt.Errorf("%w: %v", ErrAlgorithm, r.Status)
+	case models.StatusMaintenance:
+		return nil, maintenanceError(r.Status, r.MaintenanceEnd)
+	case models.StatusBulkNotAllowed:
+		return nil, fmt.Errorf("%w: %v", ErrBulkNotAllowed, r.Status)
+	default:
+		return nil, fmt.Errorf("%w: %v %d", ErrSeed, r.Status, r.ErrorCode)
+	}
+	if len(r.Seeds) != count {
+		return nil, fmt.Errorf("%w: %d seeds requested, %d issued", ErrFormat, count, len(r.Seeds))
+	}
+	return r, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+func TestBulkSeed(t *testing.T) {
+	marshal := func(r models.BulkSeedResponse) []byte {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("json.Marshal(%+v) returned %v", r, err)
+		}
+		return b
+	}
+	seeds := []models.SeedResponse{{Seed: models.Seed{Nonce: []byte("1")}}, {Seed: models.Seed{Nonce: []byte("2")}}}
+	tests := []struct {
+		desc   string
+		client *fakeHTTPDoer
+		hash   []byte
+		count  int
+		want   error
+	}{
+		{
+			desc:  "missing hash",
+			count: 2,
+			want:  ErrInput,
+		},
+		{
+			desc: "missing count",
+			hash: []byte("123"),
+			want: ErrInput,
+		},
+		{
+			desc:   "post error",
+			client: &fakeHTTPDoer{err: errors.New("error")},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrPost,
+		},
+		{
+			desc:   "not in allowlist",
+			client: &fakeHTTPDoer{body: []byte("not in allowlist")},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrNotAllowed,
+		},
+		{
+			desc:   "not allowed",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{ErrorCode: models.StatusBulkNotAllowed})},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrBulkNotAllowed,
+		},
+		{
+			desc:   "maintenance",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{ErrorCode: models.StatusMaintenance})},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrMaintenance,
+		},
+		{
+			desc:   "unsupported algorithm",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{ErrorCode: models.StatusUnsupportedHash})},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrAlgorithm,
+		},
+		{
+			desc:   "status not successful",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{ErrorCode: models.StatusSignError})},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrSeed,
+		},
+		{
+			desc:   "too few seeds",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{Seeds: seeds[:1]})},
+			hash:   []byte("123"),
+			count:  2,
+			want:   ErrFormat,
+		},
+		{
+			desc:   "success",
+			client: &fakeHTTPDoer{body: marshal(models.BulkSeedResponse{Seeds: seeds})},
+			hash:   []byte("123"),
+			count:  2,
+		},
+	}
+	for _, tt := range tests {
+		out, err := New("https://seed.foo.com/seed/bulk", tt.client).BulkSeed(tt.hash, models.HashSHA256, tt.count)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: BulkSeed() got: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err == nil && len(out.Seeds) != tt.count {
+			t.Errorf("%s: BulkSeed() got %d seeds, want: %d", tt.desc, len(out.Seeds), tt.count)
+		}
+		if tt.want != nil || tt.client == nil {
+			continue
+		}
+		var req models.BulkSeedRequest
+		if err := json.NewDecoder(tt.client.req.Body).Decode(&req); err != nil {
+			t.Errorf("%s: decoding request: %v", tt.desc, err)
+		}
+		if req.Count != tt.count || string(req.Request.Hash) != string(tt.hash) {
+			t.Errorf("%s: BulkSeed() request got: %+v", tt.desc, req)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client obtains seeds and signed URLs from the Fresnel endpoints. It
+// is used by the CLI when provisioning media, and can be used by other tools,
+// such as network boot servers, that need to do the same.
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/deck"
+	"github.com/google/fresnel/models"
+
+	fetcher "github.com/google/splice/cli/appclient"
+)
+
+const (
+	// attempts is the number of times a request is made before transient
+	// failures are returned to the caller.
+	attempts = 4
+	// maxBackoff limits the time between attempts.
+	maxBackoff = 8 * time.Second
+)
+
+var (
+	// Dependency injections for testing.
+	hostname     = os.Hostname
+	fetchConnect = fetcher.Connect
+	backoff      = time.Second
+	sleep        = time.Sleep
+
+	// ErrAlgorithm is returned when a hash algorithm is not supported by the
+	// client or not accepted by the server.
+	ErrAlgorithm = errors.New("unsupported hash algorithm")
+	// ErrConnect is returned when a connection to the server could not be
+	// established or a request to it could not be composed.
+	ErrConnect = errors.New("connect error")
+	// ErrFormat is returned when the response from the server is not valid.
+	ErrFormat = errors.New("format error")
+	// ErrInput is returned when a request is missing required fields.
+	ErrInput = errors.New("input error")
+	// ErrMaintenance is returned when the server refuses requests during
+	// planned maintenance. The message of the server is included verbatim.
+	ErrMaintenance = errors.New("the server is in maintenance mode")
+	// ErrNotAllowed is returned when the hash of a seed request is not in the
+	// allowlist of the server.
+	ErrNotAllowed = errors.New("requested boot image is not in allowlist")
+	// ErrPost is returned when a request could not be made.
+	ErrPost = errors.New("http post error")
+	// ErrSeed is returned when the server rejects a seed request.
+	ErrSeed = errors.New("invalid seed response")
+	// ErrSign is returned when the server rejects a sign request.
+	ErrSign = errors.New("invalid sign response")
+)
+
+// Doer makes HTTP requests to the server, and is responsible for
+// authenticating them.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client makes requests to a single Fresnel endpoint, such as /seed or /sign.
+type Client struct {
+	// Version and UserAgent identify the client to the server. Version is
+	// checked against MIN_CLIENT_VERSION when the server is configured with
+	// one.
+	Version   string
+	UserAgent string
+	// Hostname and OS describe the machine that seeds are requested from, and
+	// are logged by the server.
+	Hostname string
+	OS       string
+	// Batch is the operator-defined batch that seeds are requested for, if
+	// any, and is logged by the server.
+	Batch string
+	// Fields are additional values that the deployment requires with seed
+	// requests, such as a cost center, and are logged by the server.
+	Fields map[string]string
+
+	url  string
+	doer Doer
+}
+
+// New returns a Client for the endpoint at url that makes requests using doer.
+// The Hostname and OS of the Client describe the local machine.
+func New(url string, doer Doer) *Client {
+	host, err := hostname()
+	if err != nil {
+		deck.Warningf("could not determine hostname for seed request: %v", err)
+	}
+	return &Client{
+		Hostname: host,
+		OS:       runtime.GOOS,
+		url:      url,
+		doer:     doer,
+	}
+}
+
+// Connect returns a Client for the endpoint at url, authenticated as user.
+func Connect(url, user string) (*Client, error) {
+	doer, err := fetchConnect(url, user)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher.Connect(%q) returned %v: %w", url, err, ErrConnect)
+	}
+	return New(url, doer), nil
+}
+
+// Seed obtains a signed seed for hash, computed using alg. The response of the
+// server is returned alongside ErrAlgorithm when it does not accept alg, so
+// that the caller can choose one of the algorithms it lists.
+func (c *Client) Seed(hash []byte, alg models.HashAlgorithm) (*models.SeedResponse, error) {
+	if len(hash) == 0 {
+		return nil, fmt.Errorf("missing hash: %w", ErrInput)
+	}
+	sr := &models.SeedRequest{
+		Hash:      hash,
+		Algorithm: alg,
+		Hostname:  c.Hostname,
+		OS:        c.OS,
+		Version:   c.Version,
+		Batch:     c.Batch,
+		Fields:    c.Fields,
+	}
+	respBody, err := c.post(sr)
+	if err != nil {
+		return nil, err
+	}
+	// If the server responded that the hash is not in the allowlist, return.
+	if strings.Contains(string(respBody), "not in allowlist") {
+		return nil, fmt.Errorf("%w: %q", ErrNotAllowed, hex.EncodeToString(hash))
+	}
+
+	r := &models.SeedResponse{}
+	if err := json.Unmarshal(respBody, r); err != nil {
+		return nil, fmt.Errorf("json.Unmarhsal(%s) returned %v: %w", respBody, err, ErrFormat)
+	}
+	if r.ErrorCode == models.StatusUnsupportedHash {
+		return r, fmt.Errorf("%w: %v", ErrAlgorithm, r.Status)
+	}
+	if r.ErrorCode == models.StatusMaintenance {
+		return nil, maintenanceError(r.Status, r.MaintenanceEnd)
+	}
+	if r.ErrorCode != models.StatusSuccess {
+		return nil, fmt.Errorf("%w: %v %d", ErrSeed, r.Status, r.ErrorCode)
+	}
+	return r, nil
+}
+
+// NegotiateSeed obtains a signed seed for the hash returned by hash when
+// called with alg. If the server does not accept alg, hash is called again
+// with an algorithm that the server does accept and the request is made once
+// more. The hash and algorithm that the seed was obtained with are returned
+// alongside it.
+func (c *Client) NegotiateSeed(alg models.HashAlgorithm, hash func(models.HashAlgorithm) ([]byte, error)) (*models.SeedResponse, []byte, models.HashAlgorithm, error) {
+	h, err := hash(alg)
+	if err != nil {
+		return nil, nil, alg, fmt.Errorf("hashing using %s: %w", alg, err)
+	}
+	sr, err := c.Seed(h, alg)
+	if !errors.Is(err, ErrAlgorithm) {
+		return sr, h, alg, err
+	}
+	next, nerr := Negotiate(sr.Algorithms, alg)
+	if nerr != nil {
+		return nil, nil, alg, fmt.Errorf("%v: %w", err, nerr)
+	}
+	deck.Warningf("The seed server does not accept %s hashes, retrying with %s.", alg, next)
+	if h, err = hash(next); err != nil {
+		return nil, nil, alg, fmt.Errorf("hashing using %s: %w", next, err)
+	}
+	sr, err = c.Seed(h, next)
+	return sr, h, next, err
+}
+
+// Sign obtains a signed URL for the object described by req.
+func (c *Client) Sign(req *models.SignRequest) (*models.SignResponse, error) {
+	if req.Path == "" {
+		return nil, fmt.Errorf("missing path: %w", ErrInput)
+	}
+	respBody, err := c.post(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &models.SignResponse{}
+	if err := json.Unmarshal(respBody, r); err != nil {
+		return nil, fmt.Errorf("json.Unmarhsal(%s) returned %v: %w", respBody, err, ErrFormat)
+	}
+	if r.ErrorCode == models.StatusUnsupportedHash {
+		return r, fmt.Errorf("%w: %v", ErrAlgorithm, r.Status)
+	}
+	if r.ErrorCode == models.StatusMaintenance {
+		return nil, maintenanceError(r.Status, r.MaintenanceEnd)
+	}
+	if r.ErrorCode != models.StatusSuccess {
+		return nil, fmt.Errorf("%w: %v %d", ErrSign, r.Status, r.ErrorCode)
+	}
+	return r, nil
+}
+
+// maintenanceError returns ErrMaintenance with the message of the server and,
+// when known, the time that maintenance is expected to end in local time.
+func maintenanceError(msg string, end time.Time) error {
+	if end.IsZero() {
+		return fmt.Errorf("%w: %s", ErrMaintenance, msg)
+	}
+	return fmt.Errorf("%w: %s (expected to end %s)", ErrMaintenance, msg, end.Local().Format(time.RFC1123))
+}
+
+// post sends the JSON encoding of v to the endpoint and returns the body of
+// the response.
+func (c *Client) post(v interface{}) ([]byte, error) {
+	reqBody, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal request(%+v): %v", v, err)
+	}
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("error composing post request %v: %w", err, ErrConnect)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
+
+// get requests the endpoint and returns the body of the response.
+func (c *Client) get() ([]byte, error) {
+	req, err := http.NewRequest("GET", c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error composing get request %v: %w", err, ErrConnect)
+	}
+	return c.do(req)
+}
+
+// do identifies the client in req, sends it and returns the body of the
+// response. Requests that fail transiently, such as when the connection is
+// reset or the server responds with a 5xx status, are made again until
+// attempts is reached. The time between attempts doubles each time.
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Version != "" {
+		req.Header.Set(models.ClientVersionHeader, c.Version)
+	}
+
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		respBody, retry, err := c.doOnce(req)
+		if !retry {
+			return respBody, err
+		}
+		if attempt == attempts {
+			// The last response of the server is returned as usual, so that
+			// the status in its body is reported.
+			if respBody != nil {
+				return respBody, nil
+			}
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, fmt.Errorf("%w: rewinding request body returned %v", ErrPost, berr)
+			}
+			req.Body = body
+		}
+		deck.Warningf("Requesting %q failed (attempt %d of %d), retrying in %v: %v", c.url, attempt, attempts, wait, err)
+		sleep(wait)
+		if wait *= 2; wait > maxBackoff {
+			wait = maxBackoff
+		}
+	}
+}
+
+// doOnce sends req and returns the body of the response, and whether the
+// request failed transiently and is likely to succeed when made again. The
+// server responds with 503 during maintenance, which is reported to the
+// caller rather than retried, as it outlasts every attempt.
+func (c *Client) doOnce(req *http.Request) ([]byte, bool, error) {
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return nil, transient(err), fmt.Errorf("%w: %v", ErrPost, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, transient(err), fmt.Errorf("error reading response body: %v", err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusServiceUnavailable {
+		return respBody, true, fmt.Errorf("%w: server responded with %d", ErrPost, resp.StatusCode)
+	}
+	return respBody, false, nil
+}
+
+// transient determines whether err is a failure of the connection that is
+// likely to clear when the request is made again, such as a reset or a
+// timeout.
+func transient(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
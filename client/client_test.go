@@ -0,0 +1,531 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeHTTPDoer responds to requests with the contents of body, and retains
+// the last request for inspection.
+type fakeHTTPDoer struct {
+	body []byte
+	err  error
+	req  *http.Request
+}
+
+func (c *fakeHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(c.body))}, c.err
+}
+
+// flakyDoer responds to successive requests with the statuses and errors in
+// responses, and retains the bodies of the requests for inspection.
+type flakyDoer struct {
+	responses []flakyResponse
+	bodies    []string
+}
+
+// flakyResponse is a response of flakyDoer.
+type flakyResponse struct {
+	status int
+	err    error
+}
+
+func (d *flakyDoer) Do(req *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	d.bodies = append(d.bodies, string(b))
+	r := d.responses[len(d.bodies)-1]
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &http.Response{StatusCode: r.status, Body: ioutil.NopCloser(bytes.NewReader([]byte("body")))}, nil
+}
+
+// fakeSeedServer responds to successive seed requests with the contents of
+// responses, and retains the requests for inspection.
+type fakeSeedServer struct {
+	responses []models.SeedResponse
+	requests  []models.SeedRequest
+}
+
+func (s *fakeSeedServer) Do(req *http.Request) (*http.Response, error) {
+	sr := models.SeedRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+	s.requests = append(s.requests, sr)
+	body, err := json.Marshal(s.responses[len(s.requests)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		desc    string
+		connect func(string, string) (*http.Client, error)
+		want    error
+	}{
+		{
+			desc:    "connect error",
+			connect: func(string, string) (*http.Client, error) { return nil, errors.New("error") },
+			want:    ErrConnect,
+		},
+		{
+			desc:    "success",
+			connect: func(string, string) (*http.Client, error) { return &http.Client{}, nil },
+		},
+	}
+	for _, tt := range tests {
+		fetchConnect = tt.connect
+		c, err := Connect("https://seed.foo.com/seed", "user")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Connect() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err == nil && c.url != "https://seed.foo.com/seed" {
+			t.Errorf("%s: Connect() url: %q, want: %q", tt.desc, c.url, "https://seed.foo.com/seed")
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	// Model a bad response and a good response for testing.
+	bad, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSignError})
+	if err != nil {
+		t.Fatalf("json.Marshal of bad request returned %v", err)
+	}
+	good, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSuccess})
+	if err != nil {
+		t.Fatalf("json.Marshal of good request returned %v", err)
+	}
+	unsupported, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusUnsupportedHash, Algorithms: []models.HashAlgorithm{models.HashSHA512}})
+	if err != nil {
+		t.Fatalf("json.Marshal of unsupported request returned %v", err)
+	}
+	maintenance, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusMaintenance, Status: "upgrading"})
+	if err != nil {
+		t.Fatalf("json.Marshal of maintenance response returned %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		url    string
+		client *fakeHTTPDoer
+		hash   []byte
+		out    *models.SeedResponse
+		want   error
+	}{
+		{
+			desc: "missing hash",
+			want: ErrInput,
+		},
+		{
+			desc: "build request error",
+			url:  ":",
+			hash: []byte("123"),
+			want: ErrConnect,
+		},
+		{
+			desc:   "post error",
+			client: &fakeHTTPDoer{err: errors.New("error")},
+			hash:   []byte("123"),
+			want:   ErrPost,
+		},
+		{
+			desc:   "not in allowlist",
+			client: &fakeHTTPDoer{body: []byte("not in allowlist")},
+			hash:   []byte("123"),
+			want:   ErrNotAllowed,
+		},
+		{
+			desc:   "unmarshal error",
+			client: &fakeHTTPDoer{body: []byte(`{"field":what?}`)},
+			hash:   []byte("123"),
+			want:   ErrFormat,
+		},
+		{
+			desc:   "status not successful",
+			client: &fakeHTTPDoer{body: bad},
+			hash:   []byte("123"),
+			want:   ErrSeed,
+		},
+		{
+			desc:   "maintenance",
+			client: &fakeHTTPDoer{body: maintenance},
+			hash:   []byte("123"),
+			want:   ErrMaintenance,
+		},
+		{
+			desc:   "unsupported algorithm",
+			client: &fakeHTTPDoer{body: unsupported},
+			hash:   []byte("123"),
+			out:    &models.SeedResponse{ErrorCode: models.StatusUnsupportedHash, Algorithms: []models.HashAlgorithm{models.HashSHA512}},
+			want:   ErrAlgorithm,
+		},
+		{
+			desc:   "success",
+			client: &fakeHTTPDoer{body: good},
+			hash:   []byte("123"),
+			out:    &models.SeedResponse{ErrorCode: models.StatusSuccess},
+		},
+	}
+	for _, tt := range tests {
+		url := tt.url
+		if url == "" {
+			url = "https://seed.foo.com/seed"
+		}
+		out, got := New(url, tt.client).Seed(tt.hash, models.HashSHA256)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Seed() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+		if diff := cmp.Diff(tt.out, out); diff != "" {
+			t.Errorf("%s: Seed() output mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+	}
+}
+
+func TestSeedContext(t *testing.T) {
+	good, err := json.Marshal(&models.SeedResponse{ErrorCode: models.StatusSuccess})
+	if err != nil {
+		t.Fatalf("json.Marshal of good request returned %v", err)
+	}
+	defer func() { hostname = os.Hostname }()
+
+	tests := []struct {
+		desc     string
+		hostname func() (string, error)
+		batch    string
+		fields   map[string]string
+		want     *models.SeedRequest
+	}{
+		{
+			desc:     "hostname error",
+			hostname: func() (string, error) { return "", errors.New("error") },
+			want:     &models.SeedRequest{Hash: []byte("123"), Algorithm: models.HashSHA256, OS: runtime.GOOS, Version: "1.2.3"},
+		},
+		{
+			desc:     "success",
+			hostname: func() (string, error) { return "station-1", nil },
+			want:     &models.SeedRequest{Hash: []byte("123"), Algorithm: models.HashSHA256, Hostname: "station-1", OS: runtime.GOOS, Version: "1.2.3"},
+		},
+		{
+			desc:     "batch",
+			hostname: func() (string, error) { return "station-1", nil },
+			batch:    "NYC-onboarding-June",
+			want:     &models.SeedRequest{Hash: []byte("123"), Algorithm: models.HashSHA256, Hostname: "station-1", OS: runtime.GOOS, Version: "1.2.3", Batch: "NYC-onboarding-June"},
+		},
+		{
+			desc:     "fields",
+			hostname: func() (string, error) { return "station-1", nil },
+			fields:   map[string]string{"cost_center": "1234"},
+			want:     &models.SeedRequest{Hash: []byte("123"), Algorithm: models.HashSHA256, Hostname: "station-1", OS: runtime.GOOS, Version: "1.2.3", Fields: map[string]string{"cost_center": "1234"}},
+		},
+	}
+	for _, tt := range tests {
+		hostname = tt.hostname
+		doer := &fakeHTTPDoer{body: good}
+		c := New("https://seed.foo.com/seed", doer)
+		c.Version = "1.2.3"
+		c.UserAgent = "fresnel-test/1.2.3"
+		c.Batch = tt.batch
+		c.Fields = tt.fields
+		if _, err := c.Seed([]byte("123"), models.HashSHA256); err != nil {
+			t.Errorf("%s: Seed() returned %v", tt.desc, err)
+			continue
+		}
+		got := &models.SeedRequest{}
+		if err := json.NewDecoder(doer.req.Body).Decode(got); err != nil {
+			t.Errorf("%s: decoding request body returned %v", tt.desc, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: seed request mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+		if v := doer.req.Header.Get(models.ClientVersionHeader); v != c.Version {
+			t.Errorf("%s: %s header: %q, want: %q", tt.desc, models.ClientVersionHeader, v, c.Version)
+		}
+		if ua := doer.req.Header.Get("User-Agent"); ua != c.UserAgent {
+			t.Errorf("%s: User-Agent header: %q, want: %q", tt.desc, ua, c.UserAgent)
+		}
+	}
+}
+
+func TestNegotiateSeed(t *testing.T) {
+	unsupported := func(accepted ...models.HashAlgorithm) models.SeedResponse {
+		return models.SeedResponse{ErrorCode: models.StatusUnsupportedHash, Algorithms: accepted}
+	}
+	success := models.SeedResponse{ErrorCode: models.StatusSuccess}
+	hashErr := errors.New("hash error")
+
+	tests := []struct {
+		desc      string
+		responses []models.SeedResponse
+		hashErr   error
+		wantAlgs  []models.HashAlgorithm
+		want      error
+	}{
+		{
+			desc:      "accepted",
+			responses: []models.SeedResponse{success},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256},
+		},
+		{
+			desc:      "negotiated",
+			responses: []models.SeedResponse{unsupported(models.HashSHA512), success},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256, models.HashSHA512},
+		},
+		{
+			desc:     "hash error",
+			hashErr:  hashErr,
+			wantAlgs: nil,
+			want:     hashErr,
+		},
+		{
+			desc:      "no common algorithm",
+			responses: []models.SeedResponse{unsupported("blake3")},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256},
+			want:      ErrAlgorithm,
+		},
+		{
+			desc:      "retried once",
+			responses: []models.SeedResponse{unsupported(models.HashSHA512), unsupported(models.HashSHA256)},
+			wantAlgs:  []models.HashAlgorithm{models.HashSHA256, models.HashSHA512},
+			want:      ErrAlgorithm,
+		},
+	}
+	for _, tt := range tests {
+		server := &fakeSeedServer{responses: tt.responses}
+		hash := func(alg models.HashAlgorithm) ([]byte, error) {
+			return []byte(alg), tt.hashErr
+		}
+		_, h, alg, err := New("https://seed.foo.com/seed", server).NegotiateSeed(models.HashSHA256, hash)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: NegotiateSeed() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		var algs []models.HashAlgorithm
+		for _, r := range server.requests {
+			algs = append(algs, r.Algorithm)
+			if string(r.Hash) != string(r.Algorithm) {
+				t.Errorf("%s: NegotiateSeed() sent hash %q with algorithm %q", tt.desc, r.Hash, r.Algorithm)
+			}
+		}
+		if diff := cmp.Diff(tt.wantAlgs, algs); diff != "" {
+			t.Errorf("%s: NegotiateSeed() algorithms mismatch (-want +got):\n%s", tt.desc, diff)
+		}
+		if err != nil {
+			continue
+		}
+		if want := tt.wantAlgs[len(tt.wantAlgs)-1]; alg != want || string(h) != string(want) {
+			t.Errorf("%s: NegotiateSeed() got: (%q, %q), want: (%q, %q)", tt.desc, h, alg, want, want)
+		}
+	}
+}
+
+func TestMaintenanceError(t *testing.T) {
+	end := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc string
+		end  time.Time
+		want string
+	}{
+		{
+			desc: "unknown end",
+			want: ErrMaintenance.Error() + ": Rotating signing keys.",
+		},
+		{
+			desc: "known end",
+			end:  end,
+			want: ErrMaintenance.Error() + ": Rotating signing keys. (expected to end " + end.Local().Format(time.RFC1123) + ")",
+		},
+	}
+	for _, tt := range tests {
+		got := maintenanceError("Rotating signing keys.", tt.end)
+		if !errors.Is(got, ErrMaintenance) {
+			t.Errorf("%s: maintenanceError() got: %v, want: %v", tt.desc, got, ErrMaintenance)
+		}
+		if got.Error() != tt.want {
+			t.Errorf("%s: maintenanceError() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	bad, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusSignError})
+	if err != nil {
+		t.Fatalf("json.Marshal of bad response returned %v", err)
+	}
+	good, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusSuccess})
+	if err != nil {
+		t.Fatalf("json.Marshal of good response returned %v", err)
+	}
+	maintenance, err := json.Marshal(&models.SignResponse{ErrorCode: models.StatusMaintenance, Status: "upgrading"})
+	if err != nil {
+		t.Fatalf("json.Marshal of maintenance response returned %v", err)
+	}
+
+	tests := []struct {
+		desc   string
+		url    string
+		client *fakeHTTPDoer
+		req    *models.SignRequest
+		want   error
+	}{
+		{
+			desc: "missing path",
+			req:  &models.SignRequest{},
+			want: ErrInput,
+		},
+		{
+			desc: "build request error",
+			url:  ":",
+			req:  &models.SignRequest{Path: "file"},
+			want: ErrConnect,
+		},
+		{
+			desc:   "post error",
+			client: &fakeHTTPDoer{err: errors.New("error")},
+			req:    &models.SignRequest{Path: "file"},
+			want:   ErrPost,
+		},
+		{
+			desc:   "unmarshal error",
+			client: &fakeHTTPDoer{body: []byte(`{"field":what?}`)},
+			req:    &models.SignRequest{Path: "file"},
+			want:   ErrFormat,
+		},
+		{
+			desc:   "status not successful",
+			client: &fakeHTTPDoer{body: bad},
+			req:    &models.SignRequest{Path: "file"},
+			want:   ErrSign,
+		},
+		{
+			desc:   "maintenance",
+			client: &fakeHTTPDoer{body: maintenance},
+			req:    &models.SignRequest{Path: "file"},
+			want:   ErrMaintenance,
+		},
+		{
+			desc:   "success",
+			client: &fakeHTTPDoer{body: good},
+			req:    &models.SignRequest{Path: "file"},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		url := tt.url
+		if url == "" {
+			url = "https://seed.foo.com/sign"
+		}
+		_, got := New(url, tt.client).Sign(tt.req)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: Sign() got: %v, want: %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	defer func() { sleep = time.Sleep }()
+	reset := flakyResponse{err: syscall.ECONNRESET}
+	unavailable := flakyResponse{status: http.StatusServiceUnavailable}
+	failed := flakyResponse{status: http.StatusInternalServerError}
+	ok := flakyResponse{status: http.StatusOK}
+	tests := []struct {
+		desc      string
+		responses []flakyResponse
+		wantErr   error
+		wantBody  string
+		wantWaits []time.Duration
+	}{
+		{
+			desc:      "success",
+			responses: []flakyResponse{ok},
+			wantBody:  "body",
+		},
+		{
+			desc:      "connection reset",
+			responses: []flakyResponse{reset, failed, ok},
+			wantBody:  "body",
+			wantWaits: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			desc:      "unexpected eof",
+			responses: []flakyResponse{{err: io.ErrUnexpectedEOF}, ok},
+			wantBody:  "body",
+			wantWaits: []time.Duration{time.Second},
+		},
+		{
+			desc:      "not transient",
+			responses: []flakyResponse{{err: errors.New("error")}},
+			wantErr:   ErrPost,
+		},
+		{
+			desc:      "maintenance",
+			responses: []flakyResponse{unavailable},
+			wantBody:  "body",
+		},
+		{
+			desc:      "server errors",
+			responses: []flakyResponse{failed, failed, failed, failed},
+			wantBody:  "body",
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			desc:      "connection resets",
+			responses: []flakyResponse{reset, reset, reset, reset},
+			wantErr:   ErrPost,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		var waits []time.Duration
+		sleep = func(d time.Duration) { waits = append(waits, d) }
+		d := &flakyDoer{responses: tt.responses}
+		c := New("https://example.com/seed", d)
+		got, err := c.post(map[string]string{"k": "v"})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: post() err: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if string(got) != tt.wantBody {
+			t.Errorf("%s: post() got: %q, want: %q", tt.desc, got, tt.wantBody)
+		}
+		if diff := cmp.Diff(tt.wantWaits, waits); diff != "" {
+			t.Errorf("%s: post() waits returned unexpected diff (-want +got):\n%s", tt.desc, diff)
+		}
+		for i, b := range d.bodies {
+			if b != `{"k":"v"}` {
+				t.Errorf("%s: post() attempt %d sent %q, want: %q", tt.desc, i+1, b, `{"k":"v"}`)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/google/fresnel/models"
+)
+
+const (
+	// hashReadAhead is the number of blocks that are read ahead of hashing.
+	hashReadAhead = 4
+)
+
+var (
+	// hashBlockSize is the size of the blocks read when hashing a file. Large
+	// blocks keep reads sequential on slow media such as mounted ISOs on
+	// spinning disks.
+	hashBlockSize = 4 << 20
+)
+
+// hashAlgorithms maps the hash algorithms that the client can negotiate with
+// the seed server to their implementations. Algorithms are listed here as
+// support for them is added to the server.
+var hashAlgorithms = map[models.HashAlgorithm]func() hash.Hash{
+	models.HashSHA256: sha256.New,
+	models.HashSHA512: sha512.New,
+}
+
+// NewHash returns a new hash.Hash computing alg.
+func NewHash(alg models.HashAlgorithm) (hash.Hash, error) {
+	h, ok := hashAlgorithms[alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrAlgorithm, alg)
+	}
+	return h(), nil
+}
+
+// Negotiate returns the first of the algorithms accepted by the server that
+// the client supports, other than the algorithm that was already tried.
+func Negotiate(accepted []models.HashAlgorithm, tried models.HashAlgorithm) (models.HashAlgorithm, error) {
+	for _, alg := range accepted {
+		if _, ok := hashAlgorithms[alg]; ok && alg != tried {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("%w: none of %v are supported", ErrAlgorithm, accepted)
+}
+
+// HashReader writes the contents of r to h. Blocks are read from r in the
+// background while earlier blocks are hashed, so that time spent waiting on
+// slow media overlaps with time spent hashing.
+func HashReader(h hash.Hash, r io.Reader) error {
+	free := make(chan []byte, hashReadAhead+1)
+	for n := 0; n < hashReadAhead+1; n++ {
+		free <- make([]byte, hashBlockSize)
+	}
+	blocks := make(chan []byte, hashReadAhead)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(blocks)
+		for {
+			buf := <-free
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				blocks <- buf[:n]
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				errc <- nil
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	for b := range blocks {
+		h.Write(b)
+		free <- b[:cap(b)]
+	}
+	return <-errc
+}
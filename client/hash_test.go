@@ -0,0 +1,176 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/fresnel/models"
+)
+
+func TestNewHash(t *testing.T) {
+	tests := []struct {
+		desc string
+		alg  models.HashAlgorithm
+		size int
+		want error
+	}{
+		{
+			desc: "sha256",
+			alg:  models.HashSHA256,
+			size: 32,
+		},
+		{
+			desc: "sha512",
+			alg:  models.HashSHA512,
+			size: 64,
+		},
+		{
+			desc: "unsupported",
+			alg:  models.HashAlgorithm("md5"),
+			want: ErrAlgorithm,
+		},
+	}
+	for _, tt := range tests {
+		h, err := NewHash(tt.alg)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: NewHash() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err == nil && h.Size() != tt.size {
+			t.Errorf("%s: NewHash() size: %d, want: %d", tt.desc, h.Size(), tt.size)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		desc     string
+		accepted []models.HashAlgorithm
+		tried    models.HashAlgorithm
+		out      models.HashAlgorithm
+		want     error
+	}{
+		{
+			desc:     "server preference",
+			accepted: []models.HashAlgorithm{models.HashSHA512, models.HashSHA256},
+			tried:    "",
+			out:      models.HashSHA512,
+		},
+		{
+			desc:     "skips tried",
+			accepted: []models.HashAlgorithm{models.HashSHA512, models.HashSHA256},
+			tried:    models.HashSHA512,
+			out:      models.HashSHA256,
+		},
+		{
+			desc:     "skips unsupported",
+			accepted: []models.HashAlgorithm{"blake3", models.HashSHA256},
+			tried:    models.HashSHA512,
+			out:      models.HashSHA256,
+		},
+		{
+			desc:     "none supported",
+			accepted: []models.HashAlgorithm{"blake3"},
+			tried:    models.HashSHA256,
+			want:     ErrAlgorithm,
+		},
+	}
+	for _, tt := range tests {
+		out, err := Negotiate(tt.accepted, tt.tried)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Negotiate() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if out != tt.out {
+			t.Errorf("%s: Negotiate() got: %q, want: %q", tt.desc, out, tt.out)
+		}
+	}
+}
+
+// errAfterReader returns n bytes of data, followed by err.
+type errAfterReader struct {
+	n   int
+	err error
+}
+
+func (r *errAfterReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, r.err
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'a'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func TestHashReader(t *testing.T) {
+	hashBlockSize = 16
+	defer func() { hashBlockSize = 4 << 20 }()
+	readErr := errors.New("read error")
+
+	tests := []struct {
+		desc string
+		r    io.Reader
+		size int
+		want error
+	}{
+		{
+			desc: "empty",
+			r:    strings.NewReader(""),
+		},
+		{
+			desc: "partial block",
+			r:    strings.NewReader(strings.Repeat("a", 10)),
+			size: 10,
+		},
+		{
+			desc: "whole blocks",
+			r:    strings.NewReader(strings.Repeat("a", 64)),
+			size: 64,
+		},
+		{
+			desc: "more blocks than read ahead",
+			r:    strings.NewReader(strings.Repeat("a", 16*hashReadAhead*3+5)),
+			size: 16*hashReadAhead*3 + 5,
+		},
+		{
+			desc: "read error",
+			r:    &errAfterReader{n: 40, err: readErr},
+			want: readErr,
+		},
+	}
+	for _, tt := range tests {
+		h := sha256.New()
+		err := HashReader(h, tt.r)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: HashReader() err: %v, want: %v", tt.desc, err, tt.want)
+		}
+		if err != nil {
+			continue
+		}
+		want := sha256.Sum256([]byte(strings.Repeat("a", tt.size)))
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("%s: HashReader() got: %x, want: %x", tt.desc, got, want)
+		}
+	}
+}
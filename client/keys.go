@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+// ErrSignature is returned when a seed is not signed by any of the
+// certificates that it is verified against.
+var ErrSignature = errors.New("seed signature is not valid")
+
+// Keys obtains the public certificates that the server currently signs seeds
+// with. The Client must be created for the /keys endpoint.
+func (c *Client) Keys() (*models.KeysResponse, error) {
+	respBody, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	r := &models.KeysResponse{}
+	if err := json.Unmarshal(respBody, r); err != nil {
+		return nil, fmt.Errorf("json.Unmarhsal(%s) returned %v: %w", respBody, err, ErrFormat)
+	}
+	if len(r.Certs) == 0 {
+		return nil, fmt.Errorf("%w: the server returned no certificates", ErrFormat)
+	}
+	return r, nil
+}
+
+// VerifySeed checks that sig is a signature of seed made with one of certs,
+// and returns the name of the key that made it. Seeds are signed with the
+// hash of their seed file included, which the server omits from the seed it
+// returns, so hash must be the hash of the seed file that the seed was issued
+// for. The certificates included in the seed itself are never trusted, as
+// they are replaced as easily as the seed.
+func VerifySeed(seed models.Seed, sig, hash []byte, certs []appengine.Certificate) (string, error) {
+	if len(hash) == 0 {
+		return "", fmt.Errorf("missing seed file hash: %w", ErrInput)
+	}
+	seed.Hash = hash
+	b, err := json.Marshal(seed)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal(seed) returned %v: %w", err, ErrFormat)
+	}
+	sum := sha256.Sum256(b)
+	for _, cert := range certs {
+		block, _ := pem.Decode(cert.Data)
+		if block == nil {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		pub, ok := c.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil {
+			return cert.KeyName, nil
+		}
+	}
+	return "", fmt.Errorf("%w: seed issued on %v to %s does not match any of %d certificates", ErrSignature, seed.Issued, seed.Username, len(certs))
+}
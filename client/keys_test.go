@@ -0,0 +1,188 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/fresnel/models"
+	"google.golang.org/appengine"
+)
+
+// testKey generates a key and a self-signed certificate for it named name.
+func testKey(t *testing.T, name string) (*rsa.PrivateKey, appengine.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() err: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() err: %v", err)
+	}
+	return key, appengine.Certificate{KeyName: name, Data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// testSign signs seed with key the same way as the seed endpoint.
+func testSign(t *testing.T, key *rsa.PrivateKey, seed models.Seed) []byte {
+	t.Helper()
+	b, err := json.Marshal(seed)
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("rsa.SignPKCS1v15() err: %v", err)
+	}
+	return sig
+}
+
+func TestKeys(t *testing.T) {
+	keys, err := json.Marshal(models.KeysResponse{AppID: "fresnel", Certs: []appengine.Certificate{{KeyName: "key1", Data: []byte("cert")}}})
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	empty, err := json.Marshal(models.KeysResponse{AppID: "fresnel"})
+	if err != nil {
+		t.Fatalf("json.Marshal() err: %v", err)
+	}
+	tests := []struct {
+		desc    string
+		doer    *fakeHTTPDoer
+		wantErr error
+	}{
+		{
+			desc: "success",
+			doer: &fakeHTTPDoer{body: keys},
+		},
+		{
+			desc:    "request error",
+			doer:    &fakeHTTPDoer{err: errors.New("test")},
+			wantErr: ErrPost,
+		},
+		{
+			desc:    "not json",
+			doer:    &fakeHTTPDoer{body: []byte("<html>")},
+			wantErr: ErrFormat,
+		},
+		{
+			desc:    "no certificates",
+			doer:    &fakeHTTPDoer{body: empty},
+			wantErr: ErrFormat,
+		},
+	}
+	for _, tt := range tests {
+		c := &Client{url: "https://fresnel.example.com/keys", doer: tt.doer}
+		got, err := c.Keys()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Keys() err got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if tt.doer.req.Method != http.MethodGet {
+			t.Errorf("%s: Keys() method got: %q, want: %q", tt.desc, tt.doer.req.Method, http.MethodGet)
+		}
+		if err == nil && (got.AppID != "fresnel" || len(got.Certs) != 1) {
+			t.Errorf("%s: Keys() got: %+v", tt.desc, got)
+		}
+	}
+}
+
+func TestVerifySeed(t *testing.T) {
+	key, cert := testKey(t, "key1")
+	other, otherCert := testKey(t, "key2")
+	hash := []byte("seed file hash")
+	seed := models.Seed{Issued: time.Now().UTC(), Username: "user@example.com", Certs: []appengine.Certificate{cert}, Hash: hash}
+	sig := testSign(t, key, seed)
+	// The server returns the seed without its hash.
+	seed.Hash = nil
+	tampered := seed
+	tampered.Username = "other@example.com"
+	tests := []struct {
+		desc    string
+		seed    models.Seed
+		sig     []byte
+		hash    []byte
+		certs   []appengine.Certificate
+		want    string
+		wantErr error
+	}{
+		{
+			desc:  "valid",
+			seed:  seed,
+			sig:   sig,
+			hash:  hash,
+			certs: []appengine.Certificate{{KeyName: "bad", Data: []byte("not pem")}, otherCert, cert},
+			want:  "key1",
+		},
+		{
+			desc:    "missing hash",
+			seed:    seed,
+			sig:     sig,
+			certs:   []appengine.Certificate{cert},
+			wantErr: ErrInput,
+		},
+		{
+			desc:    "wrong hash",
+			seed:    seed,
+			sig:     sig,
+			hash:    []byte("other hash"),
+			certs:   []appengine.Certificate{cert},
+			wantErr: ErrSignature,
+		},
+		{
+			desc:    "tampered seed",
+			seed:    tampered,
+			sig:     sig,
+			hash:    hash,
+			certs:   []appengine.Certificate{cert},
+			wantErr: ErrSignature,
+		},
+		{
+			desc:    "unknown key",
+			seed:    seed,
+			sig:     testSign(t, other, models.Seed{Issued: seed.Issued, Username: seed.Username, Certs: seed.Certs, Hash: hash}),
+			hash:    hash,
+			certs:   []appengine.Certificate{cert},
+			wantErr: ErrSignature,
+		},
+	}
+	for _, tt := range tests {
+		got, err := VerifySeed(tt.seed, tt.sig, tt.hash, tt.certs)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: VerifySeed() err got: %v, want: %v", tt.desc, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: VerifySeed() got: %q, want: %q", tt.desc, got, tt.want)
+		}
+	}
+}
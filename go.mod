@@ -14,7 +14,9 @@ require (
 	github.com/google/winops v0.0.0-20210803215038-c8511b84de2b
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	golang.org/x/oauth2 v0.7.0
 	golang.org/x/sys v0.13.0
+	google.golang.org/api v0.114.0
 	google.golang.org/appengine v1.6.7
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -37,10 +39,8 @@ require (
 	github.com/scjalliance/comshim v0.0.0-20190308082608-cf06d2532c4e // indirect
 	go.opencensus.io v0.24.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/oauth2 v0.7.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	google.golang.org/api v0.114.0 // indirect
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 	google.golang.org/grpc v1.56.3 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
@@ -13,7 +13,9 @@
 // limitations under the License.
 
 // Package models provides data structures for imaging requests, responses,
-// and status codes.
+// and status codes. Fields exchanged with the server are annotated with a doc
+// tag, which describes them in the OpenAPI specification served at
+// /openapi.json.
 package models
 
 import (
@@ -22,6 +24,10 @@ import (
 	"google.golang.org/appengine"
 )
 
+// ClientVersionHeader is the HTTP header used by clients to identify their
+// release version to the server.
+const ClientVersionHeader = "X-Fresnel-Client-Version"
+
 // StatusCode represents an appengine status code, and is used to communicate
 // reasons for request and result rejections, as well as internal failures.
 type StatusCode int
@@ -36,55 +42,357 @@ const (
 	StatusSeedError
 	StatusSeedInvalidHash
 	StatusInvalidUser
+	StatusReqTooLarge
+	StatusReqTimeout
+	StatusObjectChanged
+	StatusObjectNotFound
+	StatusClientTooOld
+	StatusUnsupportedHash
+	StatusMaintenance
+	StatusBulkNotAllowed
+	StatusNotAdmin
+)
+
+// HashAlgorithm identifies the algorithm used to compute the Hash submitted
+// with seed and sign requests. An empty HashAlgorithm is treated as
+// HashSHA256, which is assumed by clients that predate negotiation.
+type HashAlgorithm string
+
+// Hash algorithms that may be negotiated between clients and the server.
+const (
+	HashSHA256 HashAlgorithm = "sha256"
+	HashSHA512 HashAlgorithm = "sha512"
 )
 
 // SignRequest models the data that a client can submit as part
-// of a sign request.
+// of a sign request. Generation and MD5 are optional, and when present
+// pin the request to a specific version of the object at Path. Algorithm
+// identifies how Hash was computed.
 type SignRequest struct {
-	Seed      Seed
-	Signature []byte
-	Mac       []string
-	Path      string
-	Hash      []byte
+	Seed       Seed          `doc:"The seed obtained from /seed when the media was provisioned."`
+	Signature  []byte        `doc:"The signature of Seed, as returned by /seed."`
+	Mac        []string      `doc:"The MAC addresses of the requesting machine."`
+	Path       string        `doc:"The path of the object in the bucket to obtain a signed URL for."`
+	Hash       []byte        `doc:"The hash of the seed file, computed using Algorithm."`
+	Algorithm  HashAlgorithm `doc:"The algorithm used to compute Hash. Defaults to sha256."`
+	Generation int64         `doc:"Optional. The generation of the object that the request is pinned to."`
+	MD5        []byte        `doc:"Optional. The MD5 digest of the object that the request is pinned to."`
 }
 
-// SignResponse models the response to a client sign request.
+// SignResponse models the response to a client sign request. Size, MD5 and
+// CRC32C describe the signed object, and are left empty when its metadata
+// could not be obtained. MaintenanceEnd is only set when the request is
+// rejected with StatusMaintenance.
 type SignResponse struct {
-	Status    string
-	ErrorCode StatusCode
-	SignedURL string
+	Status         string     `doc:"A human readable description of the result."`
+	ErrorCode      StatusCode `doc:"The result of the request, see StatusCode."`
+	SignedURL      string     `doc:"A signed URL for the requested object."`
+	Expires        time.Time  `doc:"The time after which SignedURL is no longer valid."`
+	Size           int64      `doc:"The size of the object in bytes, when known."`
+	MD5            []byte     `doc:"The MD5 digest of the object, when known."`
+	CRC32C         uint32     `doc:"The CRC32C checksum of the object, when known."`
+	MaintenanceEnd time.Time  `doc:"The time that maintenance is expected to end, zero when unknown or not in maintenance."`
 }
 
 // SeedRequest models the data that a client must submit as part of a Seed
-// request
+// request. Hostname, OS and Version are optional client context that the
+// server logs to help trace which provisioning station issued which media.
+// Algorithm identifies how Hash was computed. Fields carries any additional
+// values that a deployment requires, such as a cost center or site code,
+// within the limits of MaxSeedFields, MaxSeedFieldKey and MaxSeedFieldValue.
 type SeedRequest struct {
-	Hash []byte
+	Hash      []byte            `doc:"The hash of the seed file, computed using Algorithm."`
+	Algorithm HashAlgorithm     `doc:"The algorithm used to compute Hash. Defaults to sha256."`
+	Hostname  string            `doc:"Optional. The hostname of the client, for logging."`
+	OS        string            `doc:"Optional. The operating system of the client, for logging."`
+	Version   string            `doc:"Optional. The release version of the client, for logging."`
+	Batch     string            `doc:"Optional. The operator-defined batch the media is provisioned in, for logging."`
+	Fields    map[string]string `json:",omitempty" doc:"Optional. Additional values required by the deployment, such as a cost center, for logging."`
 }
 
+// The limits on the Fields of a SeedRequest. Requests that exceed them are
+// rejected by the server.
+const (
+	MaxSeedFields     = 16  // The number of fields.
+	MaxSeedFieldKey   = 64  // The length of the name of a field.
+	MaxSeedFieldValue = 256 // The length of the value of a field.
+)
+
 // SeedResponse models the data that is passed back to the client when a seed
-// request is successfully processed.
+// request is successfully processed. ExpiresAt is the time after which the
+// seed is no longer accepted by the server, and is zero when unknown.
+// Algorithm is the hash algorithm that the seed was issued for, and
+// Algorithms lists those accepted by the server in order of preference. Both
+// are also provided when a request is rejected with StatusUnsupportedHash.
+// MaintenanceEnd is only set when the request is rejected with
+// StatusMaintenance.
 type SeedResponse struct {
-	Status    string
-	ErrorCode StatusCode
-	Seed      Seed
-	Signature []byte
+	Status         string          `doc:"A human readable description of the result."`
+	ErrorCode      StatusCode      `doc:"The result of the request, see StatusCode."`
+	Seed           Seed            `doc:"The issued seed."`
+	Signature      []byte          `doc:"The signature of the JSON encoding of Seed."`
+	ExpiresAt      time.Time       `doc:"The time after which the seed is no longer accepted, zero when unknown."`
+	Algorithm      HashAlgorithm   `doc:"The hash algorithm that the seed was issued for."`
+	Algorithms     []HashAlgorithm `doc:"The hash algorithms accepted by the server, in order of preference."`
+	MaintenanceEnd time.Time       `doc:"The time that maintenance is expected to end, zero when unknown or not in maintenance."`
+}
+
+// BulkSeedRequest models a request for Count seeds for the same seed file, as
+// made by duplicators that provision many devices from one verified master.
+// Each seed is issued as though it was requested with Request.
+type BulkSeedRequest struct {
+	Request SeedRequest `doc:"The seed request that each seed is issued for."`
+	Count   int         `doc:"The number of seeds to issue, up to BULK_SEED_MAX."`
+}
+
+// BulkSeedResponse models the response to a BulkSeedRequest. Seeds are each
+// signed separately and carry a distinct nonce, so that every device is still
+// provisioned with its own seed. Requests that are rejected are described in
+// the same way as for SeedResponse, and have no Seeds.
+type BulkSeedResponse struct {
+	Status         string          `doc:"A human readable description of the result."`
+	ErrorCode      StatusCode      `doc:"The result of the request, see StatusCode."`
+	Seeds          []SeedResponse  `doc:"The issued seeds."`
+	Algorithm      HashAlgorithm   `doc:"The hash algorithm that the seeds were issued for."`
+	Algorithms     []HashAlgorithm `doc:"The hash algorithms accepted by the server, in order of preference."`
+	MaintenanceEnd time.Time       `doc:"The time that maintenance is expected to end, zero when unknown or not in maintenance."`
 }
 
 // SeedFile models the file that is stored on disk by the bootstraper. It is
 // similar to SeedResponse, but does not contain the uneccessary Status and
 // ErrorCode fields, which can contain data not intended to be stored on
-// disk.
+// disk. ExpiresAt is recorded so that tools can display the remaining
+// validity of provisioned media. Algorithm records how the seed hash was
+// computed, so that installers can compute it the same way for sign requests.
 type SeedFile struct {
 	Seed      Seed
 	Signature []byte
+	ExpiresAt time.Time
+	Algorithm HashAlgorithm
+}
+
+// SeedBundle models the file that is stored on disk alongside the SeedFile
+// when seeds are obtained for more than one file in the image, such as both
+// boot.wim and install.wim. It holds a seed for each file, including the one
+// in the SeedFile, so that installers can present a proof for any of them.
+type SeedBundle struct {
+	Seeds []BundledSeed
+}
+
+// BundledSeed is the seed in a SeedBundle for File, the path of the file in
+// the image that the seed was issued for.
+type BundledSeed struct {
+	File string
+	SeedFile
+}
+
+// BootstrapManifest models the file that is stored on disk alongside the
+// seed. It lists signed URLs obtained at provisioning time so that the
+// installer does not need to make its own sign requests on first boot.
+type BootstrapManifest struct {
+	Created time.Time
+	Files   []BootstrapFile
+}
+
+// BatchMarker models the file that is stored on disk alongside the seed when
+// media is provisioned as part of a named batch, or with a seed whose expiry
+// is known. It allows media to be reconciled with asset deployment records
+// after provisioning, and shows when the media must be reseeded without
+// reading the seed. It is also stored in place of the seed when the track
+// does not require one, with SeedSkipped set, so that the missing seed is not
+// mistaken for a failure. SeedExpiry is zero when no seed was written or its
+// expiry is unknown.
+type BatchMarker struct {
+	Batch       string
+	Distro      string
+	Track       string
+	Version     string
+	Created     time.Time
+	SeedSkipped bool
+	SeedExpiry  time.Time
+}
+
+// BootstrapFile represents a single signed object in a BootstrapManifest.
+type BootstrapFile struct {
+	Path      string
+	SignedURL string
+	Expires   time.Time
+	Size      int64
+	MD5       []byte
+	CRC32C    uint32
+}
+
+// ContentManifest models the record of every file written to media from an
+// ISO image. It is kept in the cache for the run report, and optionally stored
+// on the media, so that field media can later be compared with the intended
+// contents.
+type ContentManifest struct {
+	Created   time.Time
+	Distro    string
+	Track     string
+	Image     string
+	ImageHash string // The SHA-256 hash of the image that the files were copied from.
+	// Digest is the SHA-256 hash of the list of files, which is the same for
+	// all media written with the same contents.
+	Digest string
+	Files  []ContentFile
+}
+
+// ContentFile represents a single file in a ContentManifest.
+type ContentFile struct {
+	Path      string // The path of the file relative to the root of its partition.
+	Partition string // The role of the partition that the file was written to.
+	Size      int64
+	SHA256    string
 }
 
 // Seed represents the data that validates proof of origin for a request. It
 // is always accompanied by a signature that is used to decrypt and validate
 // its contents.
 type Seed struct {
-	Issued   time.Time
-	Username string
-	Certs    []appengine.Certificate
-	Hash     []byte
+	Issued   time.Time               `doc:"The time that the seed was issued."`
+	Username string                  `doc:"The user that the seed was issued to."`
+	Certs    []appengine.Certificate `doc:"The public certificates of the signer at the time of issue."`
+	Hash     []byte                  `doc:"The hash of the seed file."`
+	Nonce    []byte                  `json:",omitempty" doc:"Optional. A random value that distinguishes seeds issued together by /seed/bulk."`
+}
+
+// AllowlistHealth models the response to an allowlist health check. Updated
+// is the time that the allowlist was last modified, and Entries is the number
+// of hashes it lists. Problems describes why the allowlist is not Healthy.
+type AllowlistHealth struct {
+	Healthy  bool      `doc:"Whether the allowlist has no problems."`
+	Path     string    `doc:"The path of the allowlist in the bucket, reported to administrators and cron only."`
+	Updated  time.Time `doc:"The time that the allowlist was last modified."`
+	Entries  int       `doc:"The number of hashes listed in the allowlist."`
+	Problems []string  `doc:"Descriptions of the problems found with the allowlist, summarized for callers other than administrators and cron."`
+}
+
+// KeysResponse models the response to a /keys request. Certs are the public
+// certificates that seeds are currently signed with. They can be saved and
+// used later to verify the signature of a seed file without contacting the
+// server.
+type KeysResponse struct {
+	AppID string                  `doc:"The identifier of the application that signs seeds."`
+	Certs []appengine.Certificate `doc:"The public certificates used to sign seeds."`
+}
+
+// DownloadRecord models the record that is stored when a signed URL is
+// issued for an object. URLID is the hex encoded SHA-256 digest of the
+// signature in SignedURL, which identifies requests made with the URL in the
+// bucket access logs without storing the URL itself.
+type DownloadRecord struct {
+	Time      time.Time     `doc:"The time that the signed URL was issued."`
+	User      string        `doc:"The user that the seed presented with the request was issued to."`
+	Hash      []byte        `doc:"The hash of the seed file of the installer that requested the object."`
+	Algorithm HashAlgorithm `doc:"The algorithm used to compute Hash."`
+	Path      string        `doc:"The path of the object in the bucket."`
+	Size      int64         `doc:"The size of the object in bytes, when known."`
+	URLID     string        `doc:"The hex encoded SHA-256 digest of the signature in the signed URL."`
+}
+
+// SeedRecord models the record that is stored when seeds are issued. Count is
+// the number of seeds issued by the request, which is above one for bulk
+// requests.
+type SeedRecord struct {
+	Time      time.Time     `doc:"The time that the seeds were issued."`
+	User      string        `doc:"The user that the seeds were issued to."`
+	Hash      []byte        `doc:"The hash of the seed file that the seeds were issued for."`
+	Algorithm HashAlgorithm `doc:"The algorithm used to compute Hash."`
+	Hostname  string        `doc:"The hostname of the provisioning station, when reported."`
+	Version   string        `doc:"The release version of the client, when reported."`
+	Batch     string        `doc:"The provisioning batch, when reported."`
+	Count     int           `doc:"The number of seeds issued."`
+}
+
+// DownloadSummary models the response to an /admin/summary request. Images
+// aggregates the signed URLs issued between Since and Until. Correlated is
+// true when bucket access logs were read to determine which downloads
+// completed, and Completed and BytesServed are otherwise zero.
+type DownloadSummary struct {
+	Status     string           `doc:"A human readable description of the result."`
+	ErrorCode  StatusCode       `doc:"The result of the request, see StatusCode."`
+	Since      time.Time        `doc:"The start of the period that is summarized."`
+	Until      time.Time        `doc:"The end of the period that is summarized."`
+	Correlated bool             `doc:"Whether access logs were read to determine completed downloads."`
+	Images     []ImageDownloads `doc:"The downloads of each image, most signed first."`
+}
+
+// ImageDownloads aggregates the downloads of the object at Path by installers
+// with the same seed Hash, which identifies an installer build.
+type ImageDownloads struct {
+	Path        string        `doc:"The path of the object in the bucket."`
+	Hash        []byte        `doc:"The hash of the seed file of the installer that requested the object."`
+	Algorithm   HashAlgorithm `doc:"The algorithm used to compute Hash."`
+	Signed      int           `doc:"The number of signed URLs issued for the object."`
+	Users       int           `doc:"The number of distinct users that seeds were issued to."`
+	Completed   int           `doc:"The number of signed URLs that the whole object was downloaded with."`
+	BytesServed int64         `doc:"The number of bytes served using the signed URLs."`
+}
+
+// AdminAllowlist models the response to an /admin/allowlist request. Entries
+// lists each hash in the allowlist in the order it was written, including
+// those that are not valid, so that mistakes can be found and corrected.
+type AdminAllowlist struct {
+	Status    string           `doc:"A human readable description of the result."`
+	ErrorCode StatusCode       `doc:"The result of the request, see StatusCode."`
+	Path      string           `doc:"The path of the allowlist in the bucket."`
+	Updated   time.Time        `doc:"The time that the allowlist was last modified."`
+	Entries   []AllowlistEntry `doc:"The hashes listed in the allowlist."`
+}
+
+// AllowlistEntry models a hash listed in the allowlist. Problem is empty for
+// entries that are valid.
+type AllowlistEntry struct {
+	Algorithm HashAlgorithm `doc:"The algorithm of the hash."`
+	Hash      string        `doc:"The hex encoded hash."`
+	Problem   string        `doc:"Why the entry is not valid, empty when it is."`
+}
+
+// AdminActivity models the response to an /admin/activity request. Seeds and
+// Downloads are the records made between Since and Until, most recent first.
+// Truncated is true when older records were left out to limit the size of the
+// response.
+type AdminActivity struct {
+	Status    string           `doc:"A human readable description of the result."`
+	ErrorCode StatusCode       `doc:"The result of the request, see StatusCode."`
+	Since     time.Time        `doc:"The start of the period that is reported."`
+	Until     time.Time        `doc:"The end of the period that is reported."`
+	Seeds     []SeedRecord     `doc:"The seeds issued, most recent first."`
+	Downloads []DownloadRecord `doc:"The signed URLs issued, most recent first."`
+	Truncated bool             `doc:"Whether older records were left out."`
+}
+
+// AdminChecks models the response to an /admin/checks request, which checks
+// the configuration of the service for common mistakes. Healthy is true when
+// every check passed.
+type AdminChecks struct {
+	Status    string        `doc:"A human readable description of the result."`
+	ErrorCode StatusCode    `doc:"The result of the request, see StatusCode."`
+	Healthy   bool          `doc:"Whether every check passed."`
+	Checks    []ConfigCheck `doc:"The result of each check, in the order they were made."`
+}
+
+// ConfigCheck models the result of one of the configuration checks.
+type ConfigCheck struct {
+	Name   string `doc:"What was checked."`
+	OK     bool   `doc:"Whether the check passed."`
+	Detail string `doc:"The configured value, or what must be corrected."`
+}
+
+// ImageManifest models the manifest that is published alongside the images
+// for a distribution. It identifies the image that must be provisioned for
+// each track, allowing an organization to require a specific build, such as
+// during a security incident. Previous lists the prior known-good images for
+// each track, most recent first, so that a bad release can be rolled back.
+type ImageManifest struct {
+	Tracks   map[string]TrackImage
+	Previous map[string][]TrackImage
+}
+
+// TrackImage identifies the image required for a track. File is the name of
+// the image and Digest is its hex encoded SHA-256 digest.
+type TrackImage struct {
+	File   string
+	Digest string
 }